@@ -3,6 +3,9 @@ package main
 //导入包含负载测试的包
 import (
 	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	_ "github.com/informalsystems/tm-load-test/pkg/loadtest/gcs" // gs:// statistics outputs
+	_ "github.com/informalsystems/tm-load-test/pkg/loadtest/k8s" // k8s:// endpoints
+	_ "github.com/informalsystems/tm-load-test/pkg/loadtest/s3"  // s3:// statistics outputs
 )
 
 // 使用说明常量
@@ -12,7 +15,7 @@ transactions to one or more Tendermint endpoints. By default, it assumes that
 you are running the kvstore ABCI application on your Tendermint network.
 
 To run the application in a similar fashion to tm-bench (STANDALONE mode):
-    tm-load-test -c 1 -T 10 -r 1000 -s 250 \
+    tm-load-test standalone -c 1 -T 10s -r 1000 -s 250 \
         --broadcast-tx-method async \
         --endpoints ws://tm-endpoint1.somewhere.com:26657/websocket,ws://tm-endpoint2.somewhere.com:26657/websocket
 
@@ -21,8 +24,8 @@ To run the application in COORDINATOR mode:
         coordinator \
         --expect-workers 2 \
         --bind localhost:26670 \
-        --shutdown-wait 60 \
-        -c 1 -T 10 -r 1000 -s 250 \
+        --shutdown-wait 60s \
+        -c 1 -T 10s -r 1000 -s 250 \
         --broadcast-tx-method async \
         --endpoints ws://tm-endpoint1.somewhere.com:26657/websocket,ws://tm-endpoint2.somewhere.com:26657/websocket
 
@@ -37,8 +40,10 @@ NOTES:
 * The "--shutdown-wait" flag in COORDINATOR mode is specifically to allow your
   monitoring system some time to obtain the final Prometheus metrics from the
   metrics endpoint.
-* In WORKER mode, all load testing-related flags are ignored. The worker always
+* In WORKER mode, there are no load testing-related flags. The worker always
   takes instructions from the coordinator node it's connected to.
+* Running without a subcommand (e.g. "tm-load-test -c 1 ...") still runs a
+  STANDALONE load test, but is deprecated.
 `
 
 func main() {
@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The file to which our logs are being written by OpenFile, if any.
+var (
+	logFileMtx sync.Mutex
+	logFile    *rotatingFile
+)
+
+// FileOptions configures how OpenFile writes our logs to a file.
+type FileOptions struct {
+	MaxSizeMB  int  // The size (in megabytes) beyond which to rotate the file. 0 means it's never rotated.
+	MaxBackups int  // The number of rotated files (e.g. "worker.log.1", "worker.log.2", etc.) to keep.
+	Quiet      bool // Only write our logs to the file, and not to stderr as well.
+}
+
+// OpenFile writes the logs of all of our loggers with a context to the file at
+// the given path (appending to it if it exists), as well as to stderr unless
+// opts.Quiet is set. Once the file would grow beyond opts.MaxSizeMB, it's
+// rotated: it's renamed with the suffix ".1" (shifting any previous backups
+// along, up to opts.MaxBackups) and a new file is started. On platforms that
+// support it, the file is reopened whenever SIGHUP is received, so that it
+// can be rotated by an external tool such as logrotate instead.
+func OpenFile(path string, opts FileOptions) error {
+	if opts.MaxSizeMB < 0 {
+		return fmt.Errorf("expected log file max size to be >= 0, but was %d", opts.MaxSizeMB)
+	}
+	if opts.MaxBackups < 0 {
+		return fmt.Errorf("expected log file max backups to be >= 0, but was %d", opts.MaxBackups)
+	}
+	f, err := newRotatingFile(path, int64(opts.MaxSizeMB)<<20, opts.MaxBackups)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	var out io.Writer = f
+	if !opts.Quiet {
+		out = io.MultiWriter(os.Stderr, f)
+	}
+	logrus.SetOutput(out)
+
+	logFileMtx.Lock()
+	prev := logFile
+	logFile = f
+	logFileMtx.Unlock()
+	// nothing's written to the previous file once the output's been replaced
+	if prev != nil {
+		_ = prev.Close()
+	}
+	trapReopenSignal()
+	return nil
+}
+
+// ReopenFile closes and reopens the file given to OpenFile (e.g. once it's
+// been moved aside by logrotate), if any.
+func ReopenFile() error {
+	logFileMtx.Lock()
+	defer logFileMtx.Unlock()
+	if logFile == nil {
+		return nil
+	}
+	return logFile.Reopen()
+}
+
+// CloseFile stops writing our logs to the file given to OpenFile, if any, and
+// writes them to stderr alone again.
+func CloseFile() error {
+	logrus.SetOutput(os.Stderr)
+
+	logFileMtx.Lock()
+	defer logFileMtx.Unlock()
+	if logFile == nil {
+		return nil
+	}
+	err := logFile.Close()
+	logFile = nil
+	return err
+}
+
+// rotatingFile is a log file that's rotated once it grows beyond a maximum
+// size. It's safe for concurrent use, although logrus already serializes the
+// writes of all of our loggers, so its mutex only guards writes against
+// concurrent reopening.
+type rotatingFile struct {
+	mtx        sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write writes p to the file, rotating it beforehand if p would take it
+// beyond its maximum size. Since logrus writes each entry in one go, entries
+// are never split across files.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups along (discarding the oldest), moves the file
+// aside as the first backup and starts a new file.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	if f.maxBackups == 0 {
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return f.open()
+	}
+	for i := f.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(f.backupPath(i), f.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(f.path, f.backupPath(1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+// Reopen closes the file and opens the file at its path, creating it if it's
+// been moved aside.
+func (f *rotatingFile) Reopen() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) Close() error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
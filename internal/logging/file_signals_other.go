@@ -0,0 +1,6 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package logging
+
+// Reopening the log file via signals is not supported on this platform.
+func trapReopenSignal() {}
@@ -0,0 +1,31 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package logging
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+var reopenTrapOnce sync.Once
+
+// trapReopenSignal reopens the file given to OpenFile whenever SIGHUP is
+// received (e.g. from logrotate's postrotate script), from the first time
+// it's called onwards.
+func trapReopenSignal() {
+	reopenTrapOnce.Do(func() {
+		sigc := make(chan os.Signal, 1)
+		signal.Notify(sigc, syscall.SIGHUP)
+		go func() {
+			for range sigc {
+				if err := ReopenFile(); err != nil {
+					logrus.WithField("ctx", "logging").WithField("err", err).Errorln("Failed to reopen log file")
+				}
+			}
+		}()
+	})
+}
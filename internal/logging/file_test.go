@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// readLines returns the lines of the file at the given path.
+func readLines(t *testing.T, path string) []string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.log")
+	f, err := newRotatingFile(path, 1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	logger := logrus.New()
+	logger.SetOutput(f)
+	logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	const entries = 100
+	for i := 0; i < entries; i++ {
+		logger.WithField("i", i).Info("Sent batch of transactions")
+	}
+
+	var lines []string
+	for _, name := range []string{path + ".2", path + ".1", path} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Expected %s to exist: %v", name, err)
+		}
+		if info.Size() > 1024 {
+			t.Errorf("Expected %s to be at most 1024 bytes, but was %d", name, info.Size())
+		}
+		lines = append(lines, readLines(t, name)...)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 backups to be kept")
+	}
+	// the files hold the latest entries, each of them intact and in order
+	first := entries - len(lines)
+	if first <= 0 {
+		t.Fatalf("Expected the oldest entries to have been discarded, but got %d lines", len(lines))
+	}
+	for i, line := range lines {
+		expected := fmt.Sprintf("level=info msg=\"Sent batch of transactions\" i=%d", first+i)
+		if line != expected {
+			t.Errorf("Expected line %d to be %q, but got %q", i, expected, line)
+		}
+	}
+}
+
+func TestRotatingFileNoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.log")
+	f, err := newRotatingFile(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, line := range []string{"first line\n", "second line\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if lines := readLines(t, path); len(lines) != 1 || lines[0] != "second line" {
+		t.Errorf("Expected the file to have been started again, but got %q", lines)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("Expected no backups to be kept")
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	prevLevel, prevFormatter := logrus.GetLevel(), logrus.StandardLogger().Formatter
+	defer func() {
+		logrus.SetLevel(prevLevel)
+		logrus.SetFormatter(prevFormatter)
+		_ = CloseFile()
+	}()
+	if err := Configure("info", "json"); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "worker.log")
+	if err := OpenFile(path, FileOptions{MaxSizeMB: 1, MaxBackups: 1, Quiet: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// write a little over 1MB
+	logger := NewLogrusLogger("test")
+	padding := strings.Repeat("x", 1000)
+	for i := 0; i < 1100; i++ {
+		logger.Info("Sent batch of transactions", "i", i, "padding", padding)
+	}
+	backup := readLines(t, path+".1")
+	current := readLines(t, path)
+	if len(backup)+len(current) != 1100 {
+		t.Errorf("Expected 1100 entries across both files, but got %d", len(backup)+len(current))
+	}
+	for _, line := range append(backup, current...) {
+		if !strings.HasPrefix(line, "{") || !strings.HasSuffix(line, "}") {
+			t.Fatalf("Expected every entry to be intact, but got %q", line)
+		}
+	}
+
+	// logrotate moves the file aside before asking for it to be reopened
+	if err := os.Rename(path, filepath.Join(dir, "worker.log.rotated")); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("Before reopening")
+	if err := ReopenFile(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("After reopening")
+	if lines := readLines(t, path); len(lines) != 1 || !strings.Contains(lines[0], "After reopening") {
+		t.Errorf("Expected reopened file to hold only the latest entry, but got %q", lines)
+	}
+	if lines := readLines(t, filepath.Join(dir, "worker.log.rotated")); !strings.Contains(lines[len(lines)-1], "Before reopening") {
+		t.Errorf("Expected rotated file to end with the entry logged before reopening, but got %q", lines[len(lines)-1])
+	}
+}
+
+func TestOpenFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.log")
+	for _, opts := range []FileOptions{{MaxSizeMB: -1}, {MaxBackups: -1}} {
+		if err := OpenFile(path, opts); err == nil {
+			t.Errorf("Expected options %+v to be rejected", opts)
+		}
+	}
+	if err := OpenFile(filepath.Join(path, "missing", "worker.log"), FileOptions{}); err == nil {
+		t.Errorf("Expected a file in a missing directory to be rejected")
+	}
+}
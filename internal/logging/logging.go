@@ -1,19 +1,45 @@
 package logging
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
+// The log levels and formats that can be given to Configure.
+var (
+	levels = map[string]logrus.Level{
+		"trace": logrus.TraceLevel,
+		"debug": logrus.DebugLevel,
+		"info":  logrus.InfoLevel,
+		"warn":  logrus.WarnLevel,
+		"error": logrus.ErrorLevel,
+	}
+	formats = map[string]func() logrus.Formatter{
+		"text": func() logrus.Formatter { return &logrus.TextFormatter{} },
+		"json": func() logrus.Formatter { return &logrus.JSONFormatter{} },
+	}
+)
+
 // Logger is the interface to our internal logger.
+//
+// Warn was added after the other levels, for non-fatal degradations (e.g. an
+// endpoint being blacklisted) that used to be logged by Error with a
+// "WARNING:" prefix. Implementations of Logger (e.g. test fakes) need to add
+// it, and alerting on error-level log entries no longer fires for them. The
+// same goes for Trace, which was added for per-transaction diagnostics, and
+// ClearFields.
 type Logger interface {
+	Trace(msg string, kvpairs ...interface{}) //逐笔交易的诊断信息
 	Debug(msg string, kvpairs ...interface{}) //内部调试日志信息
 	Info(msg string, kvpairs ...interface{})  //一般操作信息
+	Warn(msg string, kvpairs ...interface{})  //警告信息
 	Error(msg string, kvpairs ...interface{}) //错误信息
 	SetField(key string, val interface{})     //设置键值对，记录日志消息外的上下文状态
 	PushFields()                              //压入上下文状态
 	PopFields()                               //推出
+	ClearFields()                             //清除当前键值对，保留已压入的
 }
 
 // LogrusLogger is a thread-safe logger whose properties persist and can be modified.
@@ -32,6 +58,37 @@ type NoopLogger struct{}
 var _ Logger = (*LogrusLogger)(nil)
 var _ Logger = (*NoopLogger)(nil)
 
+// Validate checks that the given log level (trace, debug, info, warn or
+// error) and format (text or json) can be given to Configure. Either may be
+// empty.
+func Validate(level, format string) error {
+	if _, ok := levels[level]; len(level) > 0 && !ok {
+		return fmt.Errorf("expected log level to be one of trace, debug, info, warn or error, but was %s", level)
+	}
+	if _, ok := formats[format]; len(format) > 0 && !ok {
+		return fmt.Errorf("expected log format to be one of text or json, but was %s", format)
+	}
+	return nil
+}
+
+// Configure sets the level (trace, debug, info, warn or error) and format
+// (text or json) of the logs written by all of our loggers with a context. In
+// JSON format, each entry's context and key/value pairs are top-level keys
+// alongside its "level", "msg" and "time". An empty level or format leaves the
+// current one as it is.
+func Configure(level, format string) error {
+	if err := Validate(level, format); err != nil {
+		return err
+	}
+	if len(level) > 0 {
+		logrus.SetLevel(levels[level])
+	}
+	if len(format) > 0 {
+		logrus.SetFormatter(formats[format]())
+	}
+	return nil
+}
+
 //
 // LogrusLogger
 //
@@ -47,18 +104,11 @@ func NewLogrusLogger(ctx string, kvpairs ...interface{}) Logger { //接收ctx和
 	return &LogrusLogger{
 		logger:          logger,
 		ctx:             ctx,
-		fields:          serializeKVPairs(kvpairs),
+		fields:          serializeKVPairs(kvpairs...),
 		pushedFieldSets: []map[string]interface{}{},
 	}
 }
 
-func (l *LogrusLogger) withFields() *logrus.Entry {
-	if len(l.fields) > 0 {
-		return l.logger.WithFields(l.fields) //返回带ctx的字段
-	}
-	return l.logger //返回原字段
-}
-
 func serializeKVPairs(kvpairs ...interface{}) map[string]interface{} { //存储键值对
 	res := make(map[string]interface{})
 	if (len(kvpairs) % 2) == 0 {
@@ -69,29 +119,49 @@ func serializeKVPairs(kvpairs ...interface{}) map[string]interface{} { //存储
 	return res
 }
 
+// copyFields returns a copy of the given fields, to which further fields can
+// be added without affecting them.
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		res[k] = v
+	}
+	return res
+}
+
+// withKVPairs returns the entry with which to log, with a snapshot of our
+// fields taken under the mutex, so that they can be changed concurrently
+// while it's being logged, along with the given key/value pairs.
 func (l *LogrusLogger) withKVPairs(kvpairs ...interface{}) *logrus.Entry {
-	fields := serializeKVPairs(kvpairs...)
+	l.mtx.Lock()
+	fields := copyFields(l.fields)
+	l.mtx.Unlock()
+	for k, v := range serializeKVPairs(kvpairs...) {
+		fields[k] = v
+	}
 	if len(fields) > 0 {
-		return l.withFields().WithFields(fields) //返回带键值对的字段
+		return l.logger.WithFields(fields) //返回带键值对的字段
 	}
-	return l.withFields() //返回原字段
+	return l.logger //返回原字段
+}
+
+func (l *LogrusLogger) Trace(msg string, kvpairs ...interface{}) {
+	l.withKVPairs(kvpairs...).Traceln(msg)
 }
 
 func (l *LogrusLogger) Debug(msg string, kvpairs ...interface{}) {
-	l.mtx.Lock()
-	defer l.mtx.Unlock()
 	l.withKVPairs(kvpairs...).Debugln(msg)
 }
 
 func (l *LogrusLogger) Info(msg string, kvpairs ...interface{}) {
-	l.mtx.Lock()
-	defer l.mtx.Unlock()
 	l.withKVPairs(kvpairs...).Infoln(msg)
 }
 
+func (l *LogrusLogger) Warn(msg string, kvpairs ...interface{}) {
+	l.withKVPairs(kvpairs...).Warnln(msg)
+}
+
 func (l *LogrusLogger) Error(msg string, kvpairs ...interface{}) {
-	l.mtx.Lock()
-	defer l.mtx.Unlock()
 	l.withKVPairs(kvpairs...).Errorln(msg)
 }
 
@@ -101,10 +171,12 @@ func (l *LogrusLogger) SetField(key string, val interface{}) {
 	l.fields[key] = val
 }
 
+// PushFields saves a copy of the logger's current fields, to be restored
+// exactly as they are now by PopFields.
 func (l *LogrusLogger) PushFields() {
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
-	l.pushedFieldSets = append(l.pushedFieldSets, l.fields)
+	l.pushedFieldSets = append(l.pushedFieldSets, copyFields(l.fields))
 }
 
 func (l *LogrusLogger) PopFields() {
@@ -117,6 +189,20 @@ func (l *LogrusLogger) PopFields() {
 	}
 }
 
+// ClearFields removes all of the logger's current fields, leaving any pushed
+// by PushFields to be restored by PopFields.
+func (l *LogrusLogger) ClearFields() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.fields = make(map[string]interface{})
+}
+
+// TraceEnabled returns whether the logger logs at trace level, so that
+// callers can avoid gathering details that would only be discarded.
+func (l *LogrusLogger) TraceEnabled() bool {
+	return l.logger.Logger.IsLevelEnabled(logrus.TraceLevel)
+}
+
 //
 // NoopLogger
 //
@@ -126,9 +212,13 @@ func NewNoopLogger() Logger {
 	return &NoopLogger{}
 }
 
+func (l *NoopLogger) Trace(msg string, kvpairs ...interface{}) {}
 func (l *NoopLogger) Debug(msg string, kvpairs ...interface{}) {}
 func (l *NoopLogger) Info(msg string, kvpairs ...interface{})  {}
+func (l *NoopLogger) Warn(msg string, kvpairs ...interface{})  {}
 func (l *NoopLogger) Error(msg string, kvpairs ...interface{}) {}
 func (l *NoopLogger) SetField(key string, val interface{})     {}
 func (l *NoopLogger) PushFields()                              {}
 func (l *NoopLogger) PopFields()                               {}
+func (l *NoopLogger) ClearFields()                             {}
+func (l *NoopLogger) TraceEnabled() bool                       { return false }
@@ -1,8 +1,15 @@
 package logging
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/sirupsen/logrus"
 )
 
 func TestKVPairSerialization(t *testing.T) {
@@ -34,3 +41,193 @@ func TestKVPairSerialization(t *testing.T) {
 		}
 	}
 }
+
+// captureLogs configures logging with the given level and format, and returns
+// everything written by the given function's logging.
+func captureLogs(t *testing.T, level, format string, fn func()) string {
+	prevLevel, prevFormatter, prevOut := logrus.GetLevel(), logrus.StandardLogger().Formatter, logrus.StandardLogger().Out
+	defer func() {
+		logrus.SetLevel(prevLevel)
+		logrus.SetFormatter(prevFormatter)
+		logrus.SetOutput(prevOut)
+	}()
+
+	var buf bytes.Buffer
+	logrus.SetOutput(&buf)
+	if err := Configure(level, format); err != nil {
+		t.Fatalf("Failed to configure logging: %v", err)
+	}
+	fn()
+	return buf.String()
+}
+
+func TestConfigure(t *testing.T) {
+	messages := []string{"trace message", "debug message", "info message", "warn message", "error message"}
+	testCases := []struct {
+		level    string
+		expected []string
+	}{
+		{"trace", messages},
+		{"debug", messages[1:]},
+		{"info", messages[2:]},
+		{"warn", messages[3:]},
+		{"error", messages[4:]},
+	}
+
+	for _, tc := range testCases {
+		for _, format := range []string{"text", "json"} {
+			t.Run(tc.level+"/"+format, func(t *testing.T) {
+				out := captureLogs(t, tc.level, format, func() {
+					logger := NewLogrusLogger("test", "worker", "w1")
+					logger.Trace("trace message", "n", 0)
+					logger.Debug("debug message", "n", 1)
+					logger.Info("info message", "n", 2)
+					logger.Warn("warn message", "n", 3)
+					logger.Error("error message", "n", 4)
+				})
+				lines := strings.Split(strings.TrimSpace(out), "\n")
+				if len(lines) != len(tc.expected) {
+					t.Fatalf("Expected %d log entries, but got %d: %q", len(tc.expected), len(lines), out)
+				}
+				for i, line := range lines {
+					if format == "text" {
+						for _, s := range []string{"msg=\"" + tc.expected[i] + "\"", "ctx=test"} {
+							if !strings.Contains(line, s) {
+								t.Errorf("Expected log entry %q to contain %s", line, s)
+							}
+						}
+						continue
+					}
+					var entry map[string]interface{}
+					if err := json.Unmarshal([]byte(line), &entry); err != nil {
+						t.Fatalf("Expected log entry %q to be JSON: %v", line, err)
+					}
+					if entry["msg"] != tc.expected[i] {
+						t.Errorf("Expected message %q, but got %v", tc.expected[i], entry["msg"])
+					}
+					if entry["ctx"] != "test" {
+						t.Errorf("Expected ctx to be a top-level key in %q", line)
+					}
+					// our fields and key/value pairs are top-level keys too
+					if entry["worker"] != "w1" || entry["n"] == nil {
+						t.Errorf("Expected worker and n to be top-level keys in %q", line)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestConfigureInvalid(t *testing.T) {
+	prevLevel := logrus.GetLevel()
+	for _, tc := range [][2]string{{"verbose", ""}, {"", "xml"}, {"warning", "text"}, {"fatal", ""}} {
+		if err := Configure(tc[0], tc[1]); err == nil {
+			t.Errorf("Expected level %q and format %q to be rejected", tc[0], tc[1])
+		}
+	}
+	if logrus.GetLevel() != prevLevel {
+		t.Errorf("Expected invalid configuration to leave the log level as it was")
+	}
+	if err := Configure("", ""); err != nil {
+		t.Errorf("Expected empty level and format to be accepted: %v", err)
+	}
+}
+
+func TestWarn(t *testing.T) {
+	out := captureLogs(t, "info", "text", func() {
+		logger := NewLogrusLogger("test", "endpoint", "ws://node0:26657/websocket")
+		logger.SetField("worker", "w1")
+		logger.Warn("blacklisting endpoint", "failures", 3)
+		// while the no-op logger logs nothing
+		NewNoopLogger().Warn("blacklisting endpoint", "failures", 4)
+	})
+	for _, s := range []string{"level=warning", "msg=\"blacklisting endpoint\"", "ctx=test", "endpoint=\"ws://node0:26657/websocket\"", "worker=w1", "failures=3"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected warning %q to contain %s", out, s)
+		}
+	}
+	if strings.Contains(out, "failures=4") {
+		t.Errorf("Expected no-op logger not to log anything, but got %q", out)
+	}
+}
+
+func TestPushPopFields(t *testing.T) {
+	logger := NewLogrusLogger("test", "a", 1).(*LogrusLogger)
+	logger.SetField("b", 2)
+	logger.PushFields()
+	// changes after the push mustn't leak into the pushed field set
+	logger.SetField("a", 10)
+	logger.SetField("c", 3)
+	logger.PushFields()
+	logger.ClearFields()
+	logger.SetField("d", 4)
+
+	expected := []map[string]interface{}{
+		{"d": 4},
+		{"a": 10, "b": 2, "c": 3},
+		{"a": 1, "b": 2},
+		// popping with nothing pushed leaves the fields as they are
+		{"a": 1, "b": 2},
+	}
+	for i, fields := range expected {
+		if i > 0 {
+			logger.PopFields()
+		}
+		if !reflect.DeepEqual(logger.fields, fields) {
+			t.Errorf("Expected fields %v after %d pops, but got %v", fields, i, logger.fields)
+		}
+	}
+}
+
+func TestClearFields(t *testing.T) {
+	out := captureLogs(t, "info", "text", func() {
+		logger := NewLogrusLogger("test", "worker", "w1")
+		logger.ClearFields()
+		logger.Info("cleared", "n", 1)
+	})
+	if strings.Contains(out, "worker=w1") || !strings.Contains(out, "n=1") || !strings.Contains(out, "ctx=test") {
+		t.Errorf("Expected only the context and key/value pairs to be logged, but got %q", out)
+	}
+}
+
+// Run with -race to check that the fields can be changed while logging.
+func TestConcurrentFields(t *testing.T) {
+	captureLogs(t, "debug", "json", func() {
+		logger := NewLogrusLogger("test", "a", 0)
+		logger.PushFields()
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 200; j++ {
+					logger.SetField(fmt.Sprintf("k%d", i), j)
+					logger.Debug("concurrent", "j", j)
+					logger.PushFields()
+					logger.SetField("a", j)
+					logger.PopFields()
+				}
+			}(i)
+		}
+		wg.Wait()
+		logger.PopFields()
+	})
+}
+
+func TestTraceEnabled(t *testing.T) {
+	captureLogs(t, "debug", "text", func() {
+		logger := NewLogrusLogger("test").(*LogrusLogger)
+		if logger.TraceEnabled() {
+			t.Errorf("Expected trace level to be disabled at debug level")
+		}
+		if err := Configure("trace", ""); err != nil {
+			t.Fatal(err)
+		}
+		if !logger.TraceEnabled() {
+			t.Errorf("Expected trace level to be enabled at trace level")
+		}
+	})
+	if NewNoopLogger().(*NoopLogger).TraceEnabled() {
+		t.Errorf("Expected no-op logger not to trace")
+	}
+}
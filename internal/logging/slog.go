@@ -0,0 +1,101 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// SlogLogger implements Logger by way of a log/slog logger.
+type SlogLogger struct {
+	mtx             sync.Mutex
+	logger          *slog.Logger
+	fields          map[string]interface{}
+	pushedFieldSets []map[string]interface{}
+}
+
+var _ Logger = (*SlogLogger)(nil)
+
+// NewSlogLogger will instantiate a logger with the given context that writes
+// to the given slog logger, with its context as the "ctx" attribute.
+func NewSlogLogger(logger *slog.Logger, ctx string, kvpairs ...interface{}) Logger {
+	if len(ctx) > 0 {
+		logger = logger.With("ctx", ctx)
+	}
+	return &SlogLogger{
+		logger:          logger,
+		fields:          serializeKVPairs(kvpairs...),
+		pushedFieldSets: []map[string]interface{}{},
+	}
+}
+
+func (l *SlogLogger) log(level slog.Level, msg string, kvpairs ...interface{}) {
+	l.mtx.Lock()
+	args := make([]interface{}, 0, 2*len(l.fields)+len(kvpairs))
+	for k, v := range l.fields {
+		args = append(args, k, v)
+	}
+	l.mtx.Unlock()
+	args = append(args, kvpairs...)
+	l.logger.Log(context.Background(), level, msg, args...)
+}
+
+// LevelTrace is the slog level at which SlogLogger logs at trace level.
+const LevelTrace = slog.LevelDebug - 4
+
+func (l *SlogLogger) Trace(msg string, kvpairs ...interface{}) {
+	l.log(LevelTrace, msg, kvpairs...)
+}
+
+func (l *SlogLogger) Debug(msg string, kvpairs ...interface{}) {
+	l.log(slog.LevelDebug, msg, kvpairs...)
+}
+
+func (l *SlogLogger) Info(msg string, kvpairs ...interface{}) {
+	l.log(slog.LevelInfo, msg, kvpairs...)
+}
+
+func (l *SlogLogger) Warn(msg string, kvpairs ...interface{}) {
+	l.log(slog.LevelWarn, msg, kvpairs...)
+}
+
+func (l *SlogLogger) Error(msg string, kvpairs ...interface{}) {
+	l.log(slog.LevelError, msg, kvpairs...)
+}
+
+func (l *SlogLogger) SetField(key string, val interface{}) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.fields[key] = val
+}
+
+func (l *SlogLogger) PushFields() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.pushedFieldSets = append(l.pushedFieldSets, copyFields(l.fields))
+}
+
+func (l *SlogLogger) PopFields() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	pfsLen := len(l.pushedFieldSets)
+	if pfsLen > 0 {
+		l.fields = l.pushedFieldSets[pfsLen-1]
+		l.pushedFieldSets = l.pushedFieldSets[:pfsLen-1]
+	}
+}
+
+// ClearFields removes all of the logger's current fields, leaving any pushed
+// by PushFields to be restored by PopFields.
+func (l *SlogLogger) ClearFields() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.fields = make(map[string]interface{})
+}
+
+// TraceEnabled returns whether the slog logger logs at LevelTrace.
+func (l *SlogLogger) TraceEnabled() bool {
+	return l.logger.Enabled(context.Background(), LevelTrace)
+}
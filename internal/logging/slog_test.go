@@ -0,0 +1,71 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := NewSlogLogger(slog.New(handler), "worker[w1]", "labels", "region=eu")
+	logger.SetField("run", 2)
+	logger.Trace("filtered out by the handler")
+	logger.Debug("filtered out by the handler")
+	logger.Info("Successfully registered with coordinator", "attempts", 1)
+	logger.Warn("Lost connection to coordinator - attempting to reconnect")
+	logger.Error("Failed during load testing", "err", "connection reset")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []struct {
+		level, msg string
+	}{
+		{"INFO", "Successfully registered with coordinator"},
+		{"WARN", "Lost connection to coordinator - attempting to reconnect"},
+		{"ERROR", "Failed during load testing"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d log entries, but got %d: %q", len(expected), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected log entry %q to be JSON: %v", line, err)
+		}
+		if entry["level"] != expected[i].level || entry["msg"] != expected[i].msg {
+			t.Errorf("Expected %s %q, but got %q", expected[i].level, expected[i].msg, line)
+		}
+		if entry["ctx"] != "worker[w1]" || entry["labels"] != "region=eu" || entry["run"] != 2.0 {
+			t.Errorf("Expected log entry %q to carry the logger's context and fields", line)
+		}
+	}
+	if !strings.Contains(lines[0], `"attempts":1`) || !strings.Contains(lines[2], `"err":"connection reset"`) {
+		t.Errorf("Expected log entries to carry their key/value pairs, but got %q", buf.String())
+	}
+}
+
+func TestSlogLoggerClearFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)), "test", "worker", "w1")
+	logger.PushFields()
+	logger.ClearFields()
+	logger.Info("cleared", "n", 1)
+	logger.PopFields()
+	logger.Info("restored")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log entries, but got %q", buf.String())
+	}
+	if strings.Contains(lines[0], `"worker"`) || !strings.Contains(lines[0], `"n":1`) || !strings.Contains(lines[0], `"ctx":"test"`) {
+		t.Errorf("Expected only the context and key/value pairs to be logged, but got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], `"worker":"w1"`) {
+		t.Errorf("Expected the pushed fields to be restored, but got %q", lines[1])
+	}
+}
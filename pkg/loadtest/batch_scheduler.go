@@ -0,0 +1,35 @@
+package loadtest
+
+import "math"
+
+// batchScheduler determines how many transactions to send in each send
+// period for a possibly fractional rate. Fractions of a transaction are
+// carried over from one period to the next, so a rate of 0.1 results in one
+// transaction every 10 periods, and a rate of 2.5 alternates between batches
+// of 2 and 3 transactions.
+type batchScheduler struct {
+	rate   float64 // The number of transactions to send per period.
+	credit float64 // The fraction of a transaction carried over from previous periods.
+}
+
+func newBatchScheduler(rate float64) *batchScheduler {
+	return &batchScheduler{rate: rate}
+}
+
+// setRate changes the number of transactions to send per period, keeping any
+// credit carried over so far.
+func (s *batchScheduler) setRate(rate float64) {
+	s.rate = rate
+}
+
+// Next returns the number of transactions to send in the next send period.
+func (s *batchScheduler) Next() int {
+	s.credit += s.rate
+	// guard against floating point error (e.g. 10 * 0.1 summing to 0.9999...)
+	n := math.Floor(s.credit + 1e-9)
+	s.credit -= n
+	if s.credit < 0 {
+		s.credit = 0
+	}
+	return int(n)
+}
@@ -0,0 +1,49 @@
+package loadtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchScheduler(t *testing.T) {
+	testCases := []struct {
+		rate     float64
+		periods  int
+		expected int
+	}{
+		// 0.1 tx/s for a minute
+		{0.1, 60, 6},
+		{0.5, 60, 30},
+		{1, 60, 60},
+		{2.5, 60, 150},
+		{1000, 10, 10000},
+	}
+	for _, tc := range testCases {
+		s := newBatchScheduler(tc.rate)
+		total, batches := 0, 0
+		for i := 0; i < tc.periods; i++ {
+			n := s.Next()
+			assert.LessOrEqual(t, float64(n), tc.rate+1, "rate %v", tc.rate)
+			if n > 0 {
+				batches++
+			}
+			total += n
+		}
+		assert.Equal(t, tc.expected, total, "rate %v", tc.rate)
+		if tc.rate < 1 {
+			// sub-1 rates must be spread out over time, not sent all at once
+			assert.Equal(t, tc.expected, batches, "rate %v", tc.rate)
+		}
+	}
+}
+
+// Changing the rate keeps the credit carried over so far.
+func TestBatchSchedulerSetRate(t *testing.T) {
+	s := newBatchScheduler(0.5)
+	assert.Equal(t, 0, s.Next())
+	s.setRate(1.5)
+	assert.Equal(t, 2, s.Next())
+	assert.Equal(t, 1, s.Next())
+	assert.Equal(t, 2, s.Next())
+}
@@ -0,0 +1,122 @@
+package loadtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BroadcastMethod describes how transactions are submitted to an endpoint via
+// a particular JSON-RPC method, and how the endpoint's responses to them are
+// interpreted. Custom methods (e.g. those exposed by patched nodes) can be
+// registered with RegisterBroadcastMethod.
+type BroadcastMethod struct {
+	// RPCMethod is the JSON-RPC method to call, e.g. "broadcast_tx_sync".
+	RPCMethod string
+
+	// AwaitsResponse indicates whether the endpoint only responds once it's
+	// processed the transaction (e.g. once CheckTx has completed), in which
+	// case broadcast latencies are the round-trip times of the requests.
+	// Otherwise only the time taken to write each request counts.
+	AwaitsResponse bool
+
+	// BuildParams builds the parameters of the request submitting the given
+	// raw transaction. Defaults to {"tx": "<base64-encoded transaction>"}.
+	BuildParams func(tx []byte) (json.RawMessage, error)
+
+	// InterpretResponse must return an error if the given response to a
+	// request indicates that the transaction was rejected. Defaults to
+	// rejecting responses that carry an RPC error.
+	InterpretResponse func(res RPCResponse) error
+}
+
+// Our global registry of broadcast methods, keyed by the names given to
+// --broadcast-tx-method.
+var broadcastMethods = map[string]BroadcastMethod{
+	"async":  {RPCMethod: "broadcast_tx_async"},
+	"sync":   {RPCMethod: "broadcast_tx_sync", AwaitsResponse: true},
+	"commit": {RPCMethod: "broadcast_tx_commit", AwaitsResponse: true},
+}
+
+// RegisterBroadcastMethod allows us to programmatically register custom
+// broadcast methods, which can then be selected by name via the
+// broadcast_tx_method configuration. In coordinator/worker mode, the method
+// must be registered by the workers as well as the coordinator.
+func RegisterBroadcastMethod(name string, method BroadcastMethod) error {
+	if len(name) == 0 {
+		return fmt.Errorf("broadcast method name must be specified")
+	}
+	if len(method.RPCMethod) == 0 {
+		return fmt.Errorf("RPC method must be specified for broadcast method %s", name)
+	}
+	if _, exists := broadcastMethods[name]; exists {
+		return fmt.Errorf("broadcast method with the specified name already exists: %s", name)
+	}
+	broadcastMethods[name] = method
+	return nil
+}
+
+// BroadcastMethodNames returns the names of the registered broadcast methods,
+// in alphabetical order.
+func BroadcastMethodNames() []string {
+	names := make([]string, 0, len(broadcastMethods))
+	for name := range broadcastMethods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupBroadcastMethod returns the registered broadcast method with the given
+// name, with any defaults filled in.
+func lookupBroadcastMethod(name string) (BroadcastMethod, error) {
+	method, ok := broadcastMethods[name]
+	if !ok {
+		return BroadcastMethod{}, fmt.Errorf(
+			"expected broadcast_tx method to be one of %s, but was %s",
+			strings.Join(BroadcastMethodNames(), ", "),
+			name,
+		)
+	}
+	if method.BuildParams == nil {
+		method.BuildParams = buildBroadcastTxParams
+	}
+	if method.InterpretResponse == nil {
+		method.InterpretResponse = interpretBroadcastTxResponse
+	}
+	return method, nil
+}
+
+// buildRequest builds the JSON-RPC request with the given ID submitting the
+// given raw transaction.
+func (m BroadcastMethod) buildRequest(id int, tx []byte) (RPCRequest, error) {
+	params, err := m.BuildParams(tx)
+	if err != nil {
+		return RPCRequest{}, err
+	}
+	return m.request(id, params), nil
+}
+
+// request returns the JSON-RPC request with the given ID and (already built)
+// parameters.
+func (m BroadcastMethod) request(id int, params json.RawMessage) RPCRequest {
+	return RPCRequest{
+		JSONRPC: "2.0",
+		ID:      id,
+		Method:  m.RPCMethod,
+		Params:  params,
+	}
+}
+
+func buildBroadcastTxParams(tx []byte) (json.RawMessage, error) {
+	return json.Marshal(map[string]interface{}{"tx": base64.StdEncoding.EncodeToString(tx)})
+}
+
+func interpretBroadcastTxResponse(res RPCResponse) error {
+	if res.Error != nil {
+		return fmt.Errorf("error response: %s", res.Error.Message)
+	}
+	return nil
+}
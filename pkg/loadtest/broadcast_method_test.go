@@ -0,0 +1,109 @@
+package loadtest_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The number of responses interpreted by the test-unsafe broadcast method.
+var unsafeResponses atomic.Int64
+
+func init() {
+	// emulates a patched node's method that skips CheckTx
+	if err := loadtest.RegisterBroadcastMethod("test-unsafe", loadtest.BroadcastMethod{
+		RPCMethod:      "broadcast_tx_unsafe",
+		AwaitsResponse: true,
+		BuildParams: func(tx []byte) (json.RawMessage, error) {
+			return json.Marshal(map[string]interface{}{"tx": base64.StdEncoding.EncodeToString(tx), "skip_check": true})
+		},
+		InterpretResponse: func(res loadtest.RPCResponse) error {
+			unsafeResponses.Add(1)
+			return nil
+		},
+	}); err != nil {
+		panic(err)
+	}
+	// rejects every transaction the endpoint doesn't explicitly accept
+	if err := loadtest.RegisterBroadcastMethod("test-rejecting", loadtest.BroadcastMethod{
+		RPCMethod: "submit_tx",
+		InterpretResponse: func(res loadtest.RPCResponse) error {
+			var result struct {
+				Accepted bool `json:"accepted"`
+			}
+			if err := json.Unmarshal(res.Result, &result); err != nil || !result.Accepted {
+				return errors.New("transaction not accepted")
+			}
+			return nil
+		},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func TestCustomBroadcastMethod(t *testing.T) {
+	svr := newMockRPCServer(t, 200*time.Millisecond)
+	cfg := mockTestConfig(svr.URL())
+	cfg.BroadcastTxMethod = "test-unsafe"
+	cfg.DrainTimeout = seconds(2)
+	require.NoError(t, cfg.Validate())
+
+	before := unsafeResponses.Load()
+	tr, err := loadtest.NewTransactor(svr.URL(), &cfg)
+	require.NoError(t, err)
+	tr.Start()
+	require.NoError(t, tr.Wait())
+
+	assert.Equal(t, cfg.Count, svr.MethodRequests("broadcast_tx_unsafe"))
+	assert.Equal(t, cfg.Count, tr.GetTxResponseCount())
+	assert.Equal(t, int64(cfg.Count), unsafeResponses.Load()-before)
+	assert.Equal(t, 0, tr.GetTxFailureCount())
+	// the method awaits responses, so latencies are round trips
+	stats := tr.GetBroadcastLatencyStats()
+	assert.Equal(t, cfg.Count, stats.Count)
+	assert.GreaterOrEqual(t, stats.P50, 0.198)
+}
+
+func TestCustomBroadcastMethodRejections(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.BroadcastTxMethod = "test-rejecting"
+	cfg.DrainTimeout = seconds(2)
+
+	tr, err := loadtest.NewTransactor(svr.URL(), &cfg)
+	require.NoError(t, err)
+	tr.Start()
+	require.NoError(t, tr.Wait())
+
+	assert.Equal(t, cfg.Count, svr.MethodRequests("submit_tx"))
+	assert.Equal(t, cfg.Count, tr.GetTxResponseCount())
+	// the mock endpoint's empty results don't accept any transactions
+	assert.Equal(t, cfg.Count, tr.GetTxFailureCount())
+}
+
+func TestUnknownBroadcastMethod(t *testing.T) {
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.BroadcastTxMethod = "unsafe"
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "async, commit, sync, test-rejecting, test-unsafe")
+	assert.Contains(t, err.Error(), "but was unsafe")
+
+	_, err = loadtest.NewTransactor("ws://localhost:26657/websocket", &cfg)
+	assert.Error(t, err)
+}
+
+func TestRegisterBroadcastMethod(t *testing.T) {
+	assert.Error(t, loadtest.RegisterBroadcastMethod("sync", loadtest.BroadcastMethod{RPCMethod: "broadcast_tx_sync"}))
+	assert.Error(t, loadtest.RegisterBroadcastMethod("test-no-rpc-method", loadtest.BroadcastMethod{}))
+	assert.Error(t, loadtest.RegisterBroadcastMethod("", loadtest.BroadcastMethod{RPCMethod: "broadcast_tx_sync"}))
+	assert.Subset(t, loadtest.BroadcastMethodNames(), []string{"async", "commit", "sync", "test-rejecting", "test-unsafe"})
+	assert.NotContains(t, loadtest.BroadcastMethodNames(), "test-no-rpc-method")
+}
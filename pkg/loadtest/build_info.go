@@ -0,0 +1,100 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"text/tabwriter"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The build metadata of tm-load-test, which must be set through linker
+// settings (see the Makefile), e.g.
+//
+//	-ldflags "-X github.com/informalsystems/tm-load-test/pkg/loadtest.cliVersionCommitID=$(git rev-parse --short HEAD)"
+//
+// See https://stackoverflow.com/a/11355611/1156132 for details.
+var (
+	cliVersionCommitID string // The commit from which tm-load-test was built.
+	cliBuildDate       string // When tm-load-test was built (e.g. in RFC 3339 format).
+)
+
+// buildInfoUnknown stands in for build metadata that wasn't set at build time.
+const buildInfoUnknown = "dev"
+
+// BuildInfo describes the build of tm-load-test.
+type BuildInfo struct {
+	Version   string `json:"version"`    // The semantic version of tm-load-test.
+	GitCommit string `json:"git_commit"` // The commit from which tm-load-test was built, or "dev" if unknown.
+	BuildDate string `json:"build_date"` // When tm-load-test was built, or "dev" if unknown.
+	GoVersion string `json:"go_version"` // The version of Go with which tm-load-test was built.
+}
+
+// GetBuildInfo returns the build metadata of this binary. Metadata not set
+// through linker settings is taken from the version control information Go
+// embeds in binaries built from a repository, if any, or is otherwise "dev".
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{
+		Version:   CLIVersion,
+		GitCommit: cliVersionCommitID,
+		BuildDate: cliBuildDate,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && len(info.GitCommit) == 0:
+				info.GitCommit = s.Value
+				if len(info.GitCommit) > 7 {
+					info.GitCommit = info.GitCommit[:7]
+				}
+			case s.Key == "vcs.time" && len(info.BuildDate) == 0:
+				info.BuildDate = s.Value
+			}
+		}
+	}
+	if len(info.GitCommit) == 0 {
+		info.GitCommit = buildInfoUnknown
+	}
+	if len(info.BuildDate) == 0 {
+		info.BuildDate = buildInfoUnknown
+	}
+	return info
+}
+
+// String returns the version along with the commit from which it was built,
+// e.g. "v1.3.0 (commit 1a2b3c4)".
+func (b BuildInfo) String() string {
+	return fmt.Sprintf("%s (commit %s)", b.Version, b.GitCommit)
+}
+
+// registerBuildInfo registers the tmloadtest_build_info metric, which is always
+// 1 and describes the build through its labels, with the given registry.
+func registerBuildInfo(reg prometheus.Registerer) {
+	info := GetBuildInfo()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "tmloadtest_build_info",
+		Help: "The build of tm-load-test, described by its labels (always 1)",
+		ConstLabels: prometheus.Labels{
+			"version":    info.Version,
+			"git_commit": info.GitCommit,
+			"build_date": info.BuildDate,
+			"go_version": info.GoVersion,
+		},
+	})
+	gauge.Set(1)
+	reg.MustRegister(gauge)
+}
+
+// writeBuildInfo writes the given build metadata to w, as displayed by the
+// version subcommand.
+func writeBuildInfo(w io.Writer, info BuildInfo) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "tm-load-test %s\n", info.Version)
+	fmt.Fprintf(tw, "  Git commit\t%s\n", info.GitCommit)
+	fmt.Fprintf(tw, "  Build date\t%s\n", info.BuildDate)
+	fmt.Fprintf(tw, "  Go version\t%s\n", info.GoVersion)
+	_ = tw.Flush()
+}
@@ -0,0 +1,95 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBuildInfo(t *testing.T) {
+	info := GetBuildInfo()
+	assert.Equal(t, CLIVersion, info.Version)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+	// test binaries have no version control information, so fall back
+	assert.NotEmpty(t, info.GitCommit)
+	assert.NotEmpty(t, info.BuildDate)
+
+	// linker settings take precedence
+	defer func(commit, date string) { cliVersionCommitID, cliBuildDate = commit, date }(cliVersionCommitID, cliBuildDate)
+	cliVersionCommitID, cliBuildDate = "1a2b3c4", "2024-01-01T00:00:00Z"
+	info = GetBuildInfo()
+	assert.Equal(t, BuildInfo{Version: CLIVersion, GitCommit: "1a2b3c4", BuildDate: "2024-01-01T00:00:00Z", GoVersion: runtime.Version()}, info)
+	assert.Equal(t, CLIVersion+" (commit 1a2b3c4)", info.String())
+
+	var buf bytes.Buffer
+	writeBuildInfo(&buf, info)
+	out := strings.Join(strings.Fields(buf.String()), " ")
+	assert.Equal(t, "tm-load-test "+CLIVersion+" Git commit 1a2b3c4 Build date 2024-01-01T00:00:00Z Go version "+runtime.Version(), out)
+}
+
+// gatherBuildInfo returns the labels of the tmloadtest_build_info metric in
+// the given registry.
+func gatherBuildInfo(t *testing.T, reg prometheus.Gatherer) map[string]string {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "tmloadtest_build_info" {
+			continue
+		}
+		require.Len(t, family.GetMetric(), 1)
+		m := family.GetMetric()[0]
+		assert.Equal(t, float64(1), m.GetGauge().GetValue())
+		labels := make(map[string]string)
+		for _, pair := range m.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		return labels
+	}
+	t.Fatal("metric tmloadtest_build_info not found")
+	return nil
+}
+
+func TestBuildInfoMetric(t *testing.T) {
+	info := GetBuildInfo()
+	expected := map[string]string{
+		"version":    info.Version,
+		"git_commit": info.GitCommit,
+		"build_date": info.BuildDate,
+		"go_version": info.GoVersion,
+	}
+	coord := NewCoordinator(&Config{}, &CoordinatorConfig{ExpectWorkers: 1})
+	assert.Equal(t, expected, gatherBuildInfo(t, coord.registry))
+	// as served by workers and standalone load tests
+	assert.Equal(t, expected, gatherBuildInfo(t, newMetricsRegistry()))
+}
+
+func TestBuildInfoInReport(t *testing.T) {
+	coord := NewCoordinator(&Config{}, &CoordinatorConfig{ExpectWorkers: 2})
+	workerBuild := BuildInfo{Version: "v1.2.0", GitCommit: "abcdef0", BuildDate: "dev", GoVersion: "go1.20"}
+	require.NoError(t, coord.registerRemoteWorker(&remoteWorker{id: "w1", build: &workerBuild}))
+	// workers from before build metadata was exchanged don't say
+	require.NoError(t, coord.registerRemoteWorker(&remoteWorker{id: "w2"}))
+
+	report := NewReport(Config{RunID: "nightly"}, AggregateStats{}, coord.workerStats())
+	assert.Equal(t, GetBuildInfo(), report.Build)
+	require.Len(t, report.Workers, 2)
+	assert.Equal(t, &workerBuild, report.Workers[0].Build)
+	assert.Nil(t, report.Workers[1].Build)
+
+	b, err := json.Marshal(report)
+	require.NoError(t, err)
+	var loaded Report
+	require.NoError(t, json.Unmarshal(b, &loaded))
+	assert.Equal(t, report.Build, loaded.Build)
+	assert.Equal(t, &workerBuild, loaded.Workers[0].Build)
+
+	var buf bytes.Buffer
+	require.NoError(t, renderReport(&buf, loaded, ReportFormatTable, &StatsWriter{}))
+	assert.Contains(t, buf.String(), "Run nightly (tm-load-test "+report.Build.String()+")")
+}
@@ -0,0 +1,164 @@
+package loadtest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// The maximum number of block metadata entries returned by a single query to
+// the blockchain RPC API.
+const blockchainPageSize = 20
+
+// How often to poll for a block committed after the load test's end.
+const chainStatsPollInterval = 100 * time.Millisecond
+
+// ChainStats summarizes the blocks committed by the chain during a load test,
+// as reported by one of the endpoints after the load test completes.
+type ChainStats struct {
+	Endpoint     string `json:"endpoint"`      // The endpoint from which the block metadata was obtained.
+	StartHeight  int64  `json:"start_height"`  // The height of the first block committed during the load test.
+	EndHeight    int64  `json:"end_height"`    // The height of the last block committed during the load test.
+	Blocks       int    `json:"blocks"`        // The number of blocks committed during the load test.
+	CommittedTxs int64  `json:"committed_txs"` // The number of transactions in those blocks (including transactions not sent by this load test).
+
+	// Computed statistics
+	AvgBlockIntervalSeconds float64 `json:"avg_block_interval_seconds"` // The average time between consecutive blocks.
+	AvgTxsPerBlock          float64 `json:"avg_txs_per_block"`          // The average number of transactions per block.
+	CommittedTxRate         float64 `json:"committed_tx_rate"`          // The rate at which transactions were committed between the start of the load test and the last block (tx/sec).
+}
+
+// BlockchainInfo corresponds to the subset of the JSON-RPC response format
+// produced by the Tendermint/CometBFT blockchain RPC API that we need.
+type BlockchainInfo struct {
+	LastHeight JSONStrInt64 `json:"last_height"`
+	BlockMetas []BlockMeta  `json:"block_metas"`
+}
+
+// BlockMeta holds the metadata of a single block.
+type BlockMeta struct {
+	Header BlockHeader `json:"header"`
+	NumTxs JSONStrInt  `json:"num_txs"`
+}
+
+// BlockHeader holds the subset of a block's header that we need.
+type BlockHeader struct {
+	Height JSONStrInt64 `json:"height"`
+	Time   time.Time    `json:"time"`
+}
+
+// blockchain returns the metadata of the blocks with heights in the range
+// [minHeight, maxHeight], in descending order of height. Nodes limit the
+// number of blocks returned by a single query, so fewer blocks than requested
+// may be returned.
+func (c *httpClient) blockchain(minHeight, maxHeight int64) (*BlockchainInfo, error) {
+	res := &BlockchainInfo{}
+	if err := c.get(fmt.Sprintf("blockchain?minHeight=%d&maxHeight=%d", minHeight, maxHeight), res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// queryChainStats obtains the metadata of the blocks committed since the
+// given start time from the given WebSockets endpoint's node, walking
+// backwards from its latest block. So that the transactions sent last have a
+// chance to be committed, it first waits for the chain to produce a block
+// after the given end time (at which sending stopped). Gives up once the given
+// timeout elapses.
+func queryChainStats(wsAddr string, start, end time.Time, timeout time.Duration) (*ChainStats, error) {
+	addr, err := rpcHTTPAddr(wsAddr)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	client := newHttpRpcClient(addr)
+	client.client.Timeout = timeout
+	defer client.close()
+
+	status, err := client.status()
+	if err != nil {
+		return nil, err
+	}
+	// if the chain does not produce a block in time, make do with the blocks
+	// committed thus far
+	for !status.SyncInfo.LatestBlockTime.After(end) && time.Now().Add(chainStatsPollInterval).Before(deadline) {
+		time.Sleep(chainStatsPollInterval)
+		if status, err = client.status(); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		blocks      []BlockMeta // In descending order of height.
+		maxHeight   = int64(status.SyncInfo.LatestBlockHeight)
+		reachedTail bool
+	)
+	for maxHeight > 0 && !reachedTail {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out after %s while querying blocks from %s", timeout, addr)
+		}
+		minHeight := maxHeight - blockchainPageSize + 1
+		if minHeight < 1 {
+			minHeight = 1
+		}
+		info, err := client.blockchain(minHeight, maxHeight)
+		if err != nil {
+			return nil, err
+		}
+		if len(info.BlockMetas) == 0 {
+			break
+		}
+		for _, meta := range info.BlockMetas {
+			blockTime := meta.Header.Time
+			if blockTime.Before(start) {
+				reachedTail = true
+				break
+			}
+			blocks = append(blocks, meta)
+		}
+		maxHeight = int64(info.BlockMetas[len(info.BlockMetas)-1].Header.Height) - 1
+	}
+
+	stats := &ChainStats{Endpoint: wsAddr}
+	if len(blocks) == 0 {
+		return stats, nil
+	}
+	stats.Blocks = len(blocks)
+	stats.StartHeight = int64(blocks[len(blocks)-1].Header.Height)
+	stats.EndHeight = int64(blocks[0].Header.Height)
+	for _, meta := range blocks {
+		stats.CommittedTxs += int64(meta.NumTxs)
+	}
+	if len(blocks) > 1 {
+		span := blocks[0].Header.Time.Sub(blocks[len(blocks)-1].Header.Time)
+		stats.AvgBlockIntervalSeconds = span.Seconds() / float64(len(blocks)-1)
+	}
+	stats.AvgTxsPerBlock = float64(stats.CommittedTxs) / float64(stats.Blocks)
+	if window := blocks[0].Header.Time.Sub(start).Seconds(); window > 0 {
+		stats.CommittedTxRate = float64(stats.CommittedTxs) / window
+	}
+	return stats, nil
+}
+
+// collectChainStats queries the chain statistics for a load test that ran
+// between the given start and end times if enabled in the given configuration,
+// logging a warning and returning nil if they cannot be obtained.
+func collectChainStats(cfg Config, wsAddr string, start, end time.Time, logger logging.Logger) *ChainStats {
+	if !cfg.ChainStats {
+		return nil
+	}
+	logger.Info("Querying block statistics from the chain", "endpoint", wsAddr)
+	stats, err := queryChainStats(wsAddr, start, end, time.Duration(cfg.ChainStatsTimeout))
+	if err != nil {
+		logger.Warn("Failed to query block statistics from the chain - skipping", "endpoint", wsAddr, "err", err)
+		return nil
+	}
+	logger.Info(
+		"Chain statistics",
+		"blocks", stats.Blocks,
+		"committedTxs", stats.CommittedTxs,
+		"avgBlockInterval", fmt.Sprintf("%.3fs", stats.AvgBlockIntervalSeconds),
+		"avgTxsPerBlock", fmt.Sprintf("%.3f", stats.AvgTxsPerBlock),
+	)
+	return stats
+}
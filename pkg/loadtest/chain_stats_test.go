@@ -0,0 +1,94 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCannedChainServer serves the status and blockchain RPC APIs for a chain
+// of the given number of blocks, one per second from the given base time,
+// where block h holds h transactions.
+func newCannedChainServer(t *testing.T, height int, base time.Time) *httptest.Server {
+	blockTime := func(h int) string {
+		return base.Add(time.Duration(h) * time.Second).Format(time.RFC3339Nano)
+	}
+	writeResult := func(w http.ResponseWriter, result string) {
+		res, _ := json.Marshal(RPCResponse{JSONRPC: "2.0", ID: -1, Result: json.RawMessage(result)})
+		_, _ = w.Write(res)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeResult(w, fmt.Sprintf(`{"sync_info":{"latest_block_height":"%d","latest_block_time":"%s"}}`, height, blockTime(height)))
+	})
+	mux.HandleFunc("/blockchain", func(w http.ResponseWriter, r *http.Request) {
+		minHeight, _ := strconv.Atoi(r.URL.Query().Get("minHeight"))
+		maxHeight, _ := strconv.Atoi(r.URL.Query().Get("maxHeight"))
+		if maxHeight-minHeight >= blockchainPageSize {
+			minHeight = maxHeight - blockchainPageSize + 1
+		}
+		metas := make([]string, 0, blockchainPageSize)
+		for h := maxHeight; h >= minHeight; h-- {
+			metas = append(metas, fmt.Sprintf(`{"header":{"height":"%d","time":"%s"},"num_txs":"%d"}`, h, blockTime(h), h))
+		}
+		writeResult(w, fmt.Sprintf(`{"last_height":"%d","block_metas":[%s]}`, height, strings.Join(metas, ",")))
+	})
+	svr := httptest.NewServer(mux)
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func TestQueryChainStats(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	svr := newCannedChainServer(t, 60, base)
+	wsAddr := "ws" + strings.TrimPrefix(svr.URL, "http") + "/websocket"
+
+	// spans heights 11 to 60 (the first block after the end), such that
+	// multiple pages must be queried
+	start := base.Add(10500 * time.Millisecond)
+	end := base.Add(59500 * time.Millisecond)
+	stats, err := queryChainStats(wsAddr, start, end, 5*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, wsAddr, stats.Endpoint)
+	assert.Equal(t, int64(11), stats.StartHeight)
+	assert.Equal(t, int64(60), stats.EndHeight)
+	assert.Equal(t, 50, stats.Blocks)
+	assert.Equal(t, int64(1775), stats.CommittedTxs) // 11 + 12 + ... + 60
+	assert.InDelta(t, 1.0, stats.AvgBlockIntervalSeconds, 1e-9)
+	assert.InDelta(t, 35.5, stats.AvgTxsPerBlock, 1e-9)
+	assert.InDelta(t, 1775/49.5, stats.CommittedTxRate, 1e-9)
+}
+
+func TestQueryChainStatsWithoutBlocks(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	svr := newCannedChainServer(t, 10, base)
+	wsAddr := "ws" + strings.TrimPrefix(svr.URL, "http") + "/websocket"
+
+	// the chain has halted, so there is no block after the end
+	stats, err := queryChainStats(wsAddr, base.Add(time.Minute), base.Add(2*time.Minute), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Blocks)
+	assert.Equal(t, int64(0), stats.CommittedTxs)
+}
+
+func TestCollectChainStatsUnreachable(t *testing.T) {
+	svr := httptest.NewServer(http.NotFoundHandler())
+	wsAddr := "ws" + strings.TrimPrefix(svr.URL, "http") + "/websocket"
+	svr.Close()
+
+	start := time.Now()
+	_, err := queryChainStats(wsAddr, start, start.Add(time.Second), time.Second)
+	require.Error(t, err)
+	// the load test's statistics are still reported, just without the chain
+	// section
+	assert.Nil(t, collectChainStats(Config{ChainStats: true, ChainStatsTimeout: Duration(time.Second)}, wsAddr, start, start.Add(time.Second), logging.NewNoopLogger()))
+}
@@ -0,0 +1,313 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How often the coordinator checkpoints the load test to its state file by
+// default.
+const defaultCheckpointInterval = 5 * time.Second
+
+// coordCheckpoint is the state of a load test underway, as periodically
+// written by the coordinator to its state file, so that a coordinator that
+// crashes can be restarted to resume the load test.
+type coordCheckpoint struct {
+	LoadTestID     int                         `json:"load_test_id"`
+	Run            int                         `json:"run"`                   // The index of the run underway.
+	Config         Config                      `json:"config"`                // The configuration of the run underway, including any overrides.
+	BaseConfig     *Config                     `json:"base_config,omitempty"` // The configuration to which each run's overrides are applied, if there are several runs.
+	StartTime      time.Time                   `json:"start_time"`
+	StartedWorkers int                         `json:"started_workers"`
+	SendEndTime    time.Time                   `json:"send_end_time"`
+	PausedSeconds  float64                     `json:"paused_seconds"` // How long the load test had been paused for in total.
+	Cancelling     bool                        `json:"cancelling"`     // Whether the load test was being cancelled.
+	Workers        map[string]workerCheckpoint `json:"workers"`        // The workers taking part in the run, including those that have already completed or failed.
+
+	ExcludedEndpoints []ExcludedEndpoint `json:"excluded_endpoints,omitempty"` // The endpoints left out of the load test for failing their health checks.
+}
+
+// workerCheckpoint is the accumulated state of a worker taking part in the
+// load test, as of the coordinator's last checkpoint.
+type workerCheckpoint struct {
+	Labels                  map[string]string        `json:"labels,omitempty"`
+	State                   workerState              `json:"state"`
+	Failed                  bool                     `json:"failed,omitempty"`         // Whether the load test carries on without the worker.
+	ReconnectTime           Duration                 `json:"reconnect_time,omitempty"` // How long the worker keeps trying to reconnect once its connection is lost.
+	TxCount                 int                      `json:"tx_count"`
+	TotalTxBytes            int64                    `json:"total_tx_bytes"`
+	Progress                float64                  `json:"progress"`
+	Failures                int                      `json:"failures"`
+	Interval                *statsInterval           `json:"interval,omitempty"` // The totals of the statistics intervals pushed by the worker, as a full interval.
+	BroadcastLatency        *latencySketch           `json:"broadcast_latency,omitempty"`
+	CommitLatency           *latencySketch           `json:"commit_latency,omitempty"`
+	CommitLatencyByPriority map[int64]*latencySketch `json:"commit_latency_by_priority,omitempty"`
+	Mempool                 *MempoolStats            `json:"mempool,omitempty"`
+	EndpointHealth          *EndpointHealthStats     `json:"endpoint_health,omitempty"`
+	Resources               *ResourceUsage           `json:"resources,omitempty"` // The worker's peak resource usage.
+	Endpoints               []EndpointStats          `json:"endpoints,omitempty"`
+	Stats                   *WorkerStats             `json:"stats,omitempty"` // The worker's final statistics, if it has completed.
+	IntervalTxs             []int                    `json:"interval_txs,omitempty"`
+	JoinedAt                *float64                 `json:"joined_at,omitempty"` // How far into the load test (in seconds) the worker joined, if it joined once underway.
+	StartOffset             float64                  `json:"start_offset,omitempty"`
+	EndpointShard           []string                 `json:"endpoint_shard,omitempty"`
+}
+
+// checkpoint writes the state of the load test underway to the coordinator's
+// state file (if it has one), replacing the previous checkpoint atomically.
+// Failing to do so doesn't affect the load test. Must only be called from the
+// coordinator's event loop.
+func (c *Coordinator) checkpoint() {
+	if len(c.coordCfg.StateFile) == 0 {
+		return
+	}
+	b, err := json.Marshal(c.newCheckpoint())
+	if err != nil {
+		c.logger.Warn("Failed to checkpoint load test", "err", err)
+		return
+	}
+	if err := writeFileAtomic(c.coordCfg.StateFile, b, 0o600); err != nil {
+		c.logger.Warn("Failed to checkpoint load test", "err", err)
+		return
+	}
+	c.logger.Debug("Checkpointed load test", "stateFile", c.coordCfg.StateFile)
+}
+
+func (c *Coordinator) newCheckpoint() coordCheckpoint {
+	now := time.Now()
+	cp := coordCheckpoint{
+		LoadTestID:     c.coordCfg.LoadTestID,
+		Run:            c.run,
+		Config:         *c.cfg,
+		StartTime:      c.startTime,
+		StartedWorkers: c.startedWorkers,
+		SendEndTime:    c.sendEndTime,
+		PausedSeconds:  c.pauseClk.pausedDuration(now).Seconds(),
+		Cancelling:     c.cancelling,
+		Workers:        make(map[string]workerCheckpoint, len(c.totalTxsPerWorker)),
+
+		ExcludedEndpoints: c.excludedEndpoints,
+	}
+	if c.runs > 1 {
+		base := c.baseCfg
+		cp.BaseConfig = &base
+	}
+	for id := range c.totalTxsPerWorker {
+		wc := workerCheckpoint{
+			Labels:                  c.workerInfo.get(id),
+			State:                   c.statePerWorker[id],
+			Failed:                  c.failedWorkers[id],
+			TxCount:                 c.totalTxsPerWorker[id],
+			TotalTxBytes:            c.totalBytesPerWorker[id],
+			Progress:                c.progressPerWorker[id].Ratio,
+			Failures:                c.progressPerWorker[id].Failures,
+			BroadcastLatency:        c.broadcastLatPerWorker[id],
+			CommitLatency:           c.commitLatPerWorker[id],
+			CommitLatencyByPriority: c.priorityLatPerWorker[id],
+			Endpoints:               c.endpointsPerWorker[id],
+			IntervalTxs:             c.intervalTxsPerWorker[id],
+			StartOffset:             c.startOffsetPerWorker[id],
+			EndpointShard:           c.endpointShard(id),
+		}
+		if rw, ok := c.workers[id]; ok {
+			wc.ReconnectTime = rw.reconnectTime
+		} else if deadline, ok := c.reconnectDeadlines[id]; ok {
+			// the worker keeps trying to reconnect until then
+			wc.ReconnectTime = Duration(time.Until(deadline))
+		}
+		if totals, ok := c.intervalsPerWorker[id]; ok {
+			wc.Interval = &statsInterval{
+				Seq:              totals.seq,
+				Full:             true,
+				Txs:              totals.txs,
+				Bytes:            totals.bytes,
+				Failures:         totals.failures,
+				BroadcastLatency: totals.broadcastLatency,
+				CommitLatency:    totals.commitLatency,
+			}
+		}
+		if mempool, ok := c.mempoolPerWorker[id]; ok {
+			wc.Mempool = &mempool
+		}
+		if health, ok := c.healthPerWorker[id]; ok {
+			wc.EndpointHealth = &health
+		}
+		if resources, ok := c.resourcesPerWorker[id]; ok {
+			wc.Resources = &resources
+		}
+		if stats, ok := c.statsPerWorker[id]; ok {
+			wc.Stats = &stats
+		}
+		if joinedAt, ok := c.joinedAtPerWorker[id]; ok {
+			wc.JoinedAt = &joinedAt
+		}
+		cp.Workers[id] = wc
+	}
+	return cp
+}
+
+// loadCheckpoint reads the load test's last checkpoint from the coordinator's
+// state file, returning nil if there is none. Fields of the configuration that
+// are never serialized (i.e. secrets) keep their current values.
+func (c *Coordinator) loadCheckpoint() (*coordCheckpoint, error) {
+	b, err := os.ReadFile(c.coordCfg.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	cp := coordCheckpoint{Config: *c.cfg}
+	if c.runs > 1 {
+		base := *c.cfg
+		cp.BaseConfig = &base
+	}
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", c.coordCfg.StateFile, err)
+	}
+	if cp.LoadTestID != c.coordCfg.LoadTestID {
+		return nil, fmt.Errorf("state file %s is for load test ID %d, not %d", c.coordCfg.StateFile, cp.LoadTestID, c.coordCfg.LoadTestID)
+	}
+	if cp.Run < 0 || cp.Run >= c.runs {
+		return nil, fmt.Errorf("state file %s is for run %d, but there are only %d runs", c.coordCfg.StateFile, cp.Run, c.runs)
+	}
+	if err := c.coordCfg.ValidateConfig(cp.Config); err != nil {
+		return nil, fmt.Errorf("invalid configuration in state file %s: %w", c.coordCfg.StateFile, err)
+	}
+	if err := cp.Config.ParseEndpointRateLimits(); err != nil {
+		return nil, fmt.Errorf("invalid configuration in state file %s: %w", c.coordCfg.StateFile, err)
+	}
+	return &cp, nil
+}
+
+// restoreCheckpoint resumes the load test from the given checkpoint. The
+// workers that were taking part in it (and hadn't completed or failed) are
+// expected to reconnect within the time they said they'd keep trying to, as
+// if they'd just lost their connections.
+func (c *Coordinator) restoreCheckpoint(cp *coordCheckpoint) {
+	if cp.BaseConfig != nil {
+		c.baseCfg = *cp.BaseConfig
+	}
+	c.run = cp.Run
+	c.setConfig(cp.Config)
+	c.resetRunState()
+	c.startTime = cp.StartTime
+	c.startedWorkers = cp.StartedWorkers
+	c.sendEndTime = cp.SendEndTime
+	c.pauseClk.restore(time.Duration(cp.PausedSeconds * float64(time.Second)))
+	c.cancelling = cp.Cancelling
+	c.excludedEndpoints = cp.ExcludedEndpoints
+
+	now := time.Now()
+	c.mtx.Lock()
+	for id, wc := range cp.Workers {
+		if len(wc.EndpointShard) > 0 {
+			c.endpointShards[id] = wc.EndpointShard
+		}
+	}
+	c.mtx.Unlock()
+	for id, wc := range cp.Workers {
+		c.workerInfo.set(id, wc.Labels)
+		c.statePerWorker[id] = wc.State
+		c.totalTxsPerWorker[id] = wc.TxCount
+		c.totalBytesPerWorker[id] = wc.TotalTxBytes
+		c.progressPerWorker[id] = progressStatus{Ratio: wc.Progress, Failures: wc.Failures}
+		if wc.Interval != nil {
+			totals := &intervalTotals{}
+			totals.fold(wc.Interval)
+			c.intervalsPerWorker[id] = totals
+		}
+		if wc.BroadcastLatency != nil {
+			c.broadcastLatPerWorker[id] = wc.BroadcastLatency
+		}
+		if wc.CommitLatency != nil {
+			c.commitLatPerWorker[id] = wc.CommitLatency
+		}
+		if wc.CommitLatencyByPriority != nil {
+			c.priorityLatPerWorker[id] = wc.CommitLatencyByPriority
+		}
+		if wc.Mempool != nil {
+			c.mempoolPerWorker[id] = *wc.Mempool
+		}
+		if wc.EndpointHealth != nil {
+			c.healthPerWorker[id] = *wc.EndpointHealth
+		}
+		if wc.Resources != nil {
+			c.resourcesPerWorker[id] = *wc.Resources
+		}
+		if wc.Endpoints != nil {
+			c.endpointsPerWorker[id] = wc.Endpoints
+		}
+		if wc.Stats != nil {
+			c.statsPerWorker[id] = *wc.Stats
+		}
+		if wc.IntervalTxs != nil {
+			c.intervalTxsPerWorker[id] = wc.IntervalTxs
+		}
+		if wc.JoinedAt != nil {
+			c.joinedAtPerWorker[id] = *wc.JoinedAt
+		}
+		if wc.StartOffset > 0 {
+			c.startOffsetPerWorker[id] = wc.StartOffset
+		}
+		switch {
+		case wc.Failed:
+			c.failedWorkers[id] = true
+		case wc.State != workerCompleted && wc.State != workerCancelled:
+			c.reconnectDeadlines[id] = now.Add(time.Duration(wc.ReconnectTime))
+		}
+	}
+	// workers that reconnect are told their share of the total rate anew
+	c.splitTotalRate()
+	c.resumed = true
+	c.logger.Info(
+		"Resuming load test from state file",
+		"stateFile", c.coordCfg.StateFile,
+		"run", c.run,
+		"workers", len(cp.Workers),
+		"awaiting", len(c.reconnectDeadlines),
+		"elapsed", time.Since(c.startTime).Round(time.Second).String(),
+	)
+}
+
+// removeCheckpoint removes the coordinator's state file (if it has one) once
+// the load test is over, so that it can't be resumed again.
+func (c *Coordinator) removeCheckpoint() {
+	if len(c.coordCfg.StateFile) == 0 {
+		return
+	}
+	if err := os.Remove(c.coordCfg.StateFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.logger.Warn("Failed to remove state file", "stateFile", c.coordCfg.StateFile, "err", err)
+	}
+}
+
+// writeFileAtomic writes the given data to a temporary file alongside the
+// given file (with the given permissions), and then renames it over the file,
+// so that readers only ever see either the old or the new contents in full.
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
@@ -2,21 +2,29 @@ package loadtest //pkg包含项目使用的各种Go包和库
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/informalsystems/tm-load-test/internal/logging"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // CLIVersion must be manually updated as new versions are released.
 const CLIVersion = "v1.3.0"
 
-// cliVersionCommitID must be set through linker settings. See
-// https://stackoverflow.com/a/11355611/1156132 for details.
-var cliVersionCommitID string
+// Version returns the full version of tm-load-test, including the commit ID
+// if it was set at build time.
+func Version() string {
+	if len(cliVersionCommitID) > 0 {
+		return fmt.Sprintf("%s-%s", CLIVersion, cliVersionCommitID)
+	}
+	return CLIVersion
+}
 
 // CLIConfig allows developers to customize their own load testing tool.
 type CLIConfig struct {
@@ -26,111 +34,595 @@ type CLIConfig struct {
 	DefaultClientFactory string
 }
 
+// Exit codes of the tm-load-test CLI. Load tests exit with the code of the
+// class of failure with which they end (see ClassifyError).
+const (
+	ExitCodeSuccess       = 0 // The load test completed successfully.
+	ExitCodeFailure       = 1 // A command other than a load test failed.
+	ExitCodeInvalidConfig = 2 // The command line or configuration is invalid, so no load test was attempted.
+	ExitCodeConnectivity  = 3 // The endpoints (or coordinator) couldn't be reached, or another pre-flight check failed.
+	ExitCodeRuntime       = 4 // The load test failed while sending transactions, or while reporting on them.
+	ExitCodeCancelled     = 5 // The load test was cancelled (e.g. by an interrupt) before it completed.
+	ExitCodeThreshold     = 6 // The results fell short of a threshold (e.g. --min-success-ratio, or regressed beyond report --threshold).
+)
+
 var flagVerbose bool
 
-func buildCLI(cli *CLIConfig, logger logging.Logger) *cobra.Command {
+// buildCLI builds the command line interface with which to parse the given
+// command line arguments, reading any configuration file they give with
+// --config beforehand.
+func buildCLI(cli *CLIConfig, args []string, logger logging.Logger) (*cobra.Command, error) {
 	cobra.OnInitialize(func() { initLogLevel(logger) })
 	var cfg Config
+	runStandalone := func() {
+		configureLogFile(cfg.LogFile, cfg.LogMaxSizeMB, cfg.LogMaxBackups, cfg.LogQuiet, logger)
+		configureLogging(cfg.LogLevel, cfg.LogFormat, logger)
+		if err := cfg.LoadEndpointsFile(os.Stdin); err != nil {
+			logger.Error(err.Error())
+			os.Exit(ExitCodeInvalidConfig)
+		}
+		logger.Debug(fmt.Sprintf("Configuration: %s", cfg.ToJSON()))
+		if err := cfg.Validate(); err != nil {
+			logger.Error(err.Error())
+			os.Exit(ExitCodeInvalidConfig)
+		}
+		if err := cfg.ValidateOutputFiles(); err != nil {
+			logger.Error(err.Error())
+			os.Exit(ExitCodeInvalidConfig)
+		}
+		txs, bytes := cfg.offeredLoad()
+		confirmLoad(cfg, txs, bytes, logger)
+
+		// keep stdout free for the summary, if requested
+		barOut := os.Stdout
+		if cfg.PrintSummaryJSON {
+			barOut = os.Stderr
+		}
+		if err := ExecuteStandalone(cfg, withProgressDisplay(barOut, os.Stderr, attachProgressBar)); err != nil {
+			os.Exit(ExitCodeFor(err))
+		}
+	}
 	rootCmd := &cobra.Command{
 		Use:   cli.AppName,
 		Short: cli.AppShortDesc,
 		Long:  cli.AppLongDesc,
+		Args:  cobra.NoArgs,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			warnBareSeconds(cmd.Flags(), logger)
+		},
+		// for backwards compatibility, a load test configured by flags given
+		// without a subcommand runs standalone
 		Run: func(cmd *cobra.Command, args []string) {
-			logger.Debug(fmt.Sprintf("Configuration: %s", cfg.ToJSON()))
-			if err := cfg.Validate(); err != nil {
-				logger.Error(err.Error())
-				os.Exit(1)
-			}
-
-			if err := ExecuteStandalone(cfg); err != nil {
-				os.Exit(1)
+			if cmd.Flags().NFlag() == 0 {
+				_ = cmd.Help()
+				return
 			}
+			logger.Warn(fmt.Sprintf("Running a load test without a subcommand is deprecated - use \"%s standalone\" instead", cli.AppName))
+			runStandalone()
 		},
 	}
-	rootCmd.PersistentFlags().StringVar(&cfg.ClientFactory, "client-factory", cli.DefaultClientFactory, "The identifier of the client factory to use for generating load testing transactions")
-	rootCmd.PersistentFlags().IntVarP(&cfg.Connections, "connections", "c", 1, "The number of connections to open to each endpoint simultaneously")
-	rootCmd.PersistentFlags().IntVarP(&cfg.Time, "time", "T", 60, "The duration (in seconds) for which to handle the load test")
-	rootCmd.PersistentFlags().IntVarP(&cfg.SendPeriod, "send-period", "p", 1, "The period (in seconds) at which to send batches of transactions")
-	rootCmd.PersistentFlags().IntVarP(&cfg.Rate, "rate", "r", 1000, "The number of transactions to generate each second on each connection, to each endpoint")
-	rootCmd.PersistentFlags().IntVarP(&cfg.Size, "size", "s", 250, "The size of each transaction, in bytes - must be greater than 40")
-	rootCmd.PersistentFlags().IntVarP(&cfg.Count, "count", "N", -1, "The maximum number of transactions to send - set to -1 to turn off this limit")
-	rootCmd.PersistentFlags().StringVar(&cfg.BroadcastTxMethod, "broadcast-tx-method", "async", "The broadcast_tx method to use when submitting transactions - can be async, sync or commit")
-	rootCmd.PersistentFlags().StringSliceVar(&cfg.Endpoints, "endpoints", []string{}, "A comma-separated list of URLs indicating Tendermint WebSockets RPC endpoints to which to connect")
-	rootCmd.PersistentFlags().StringVar(&cfg.EndpointSelectMethod, "endpoint-select-method", SelectSuppliedEndpoints, "The method by which to select endpoints")
-	rootCmd.PersistentFlags().IntVar(&cfg.ExpectPeers, "expect-peers", 0, "The minimum number of peers to expect when crawling the P2P network from the specified endpoint(s) prior to waiting for workers to connect")
-	rootCmd.PersistentFlags().IntVar(&cfg.MaxEndpoints, "max-endpoints", 0, "The maximum number of endpoints to use for testing, where 0 means unlimited")
-	rootCmd.PersistentFlags().IntVar(&cfg.PeerConnectTimeout, "peer-connect-timeout", 600, "The number of seconds to wait for all required peers to connect if expect-peers > 0")
-	rootCmd.PersistentFlags().IntVar(&cfg.MinConnectivity, "min-peer-connectivity", 0, "The minimum number of peers to which each peer must be connected before starting the load test")
-	rootCmd.PersistentFlags().StringVar(&cfg.StatsOutputFile, "stats-output", "", "Where to store aggregate statistics (in CSV format) for the load test")
+	addLoadTestFlags(rootCmd.Flags(), &cfg, cli)
+	addStandaloneFlags(rootCmd.Flags(), &cfg)
+	addCoordinatorLoadTestFlags(rootCmd.Flags(), &cfg)
+	addLogFlags(rootCmd.Flags(), &cfg.LogLevel, &cfg.LogFormat)
+	addLogFileFlags(rootCmd.Flags(), &cfg.LogFile, &cfg.LogMaxSizeMB, &cfg.LogMaxBackups, &cfg.LogQuiet)
+	rootCmd.Flags().VisitAll(func(f *pflag.Flag) { f.Hidden = true })
 	rootCmd.PersistentFlags().BoolVarP(&flagVerbose, "verbose", "v", false, "Increase output logging verbosity to DEBUG level")
+	// these are handled by configFileArgs, and only defined for the usage
+	rootCmd.PersistentFlags().String("config", "", "A YAML (.yaml or .yml), TOML (.toml) or JSON (.json) file from which to read the configuration, with the coordinator's and workers' configurations in \"coordinator\" and \"worker\" sections - flags given explicitly override its values")
+	rootCmd.PersistentFlags().Bool("strict-config", false, "Fail if the --config file has keys that don't match any configuration field (e.g. because of typos), instead of ignoring them")
+
+	standaloneCmd := &cobra.Command{
+		Use:   "standalone",
+		Short: "Run a load test from this process alone",
+		Example: fmt.Sprintf(`  # send 1000 tx/sec on each of 2 connections to two endpoints for 5 minutes
+  %[1]s standalone -c 2 -T 5m -r 1000 \
+      --endpoints ws://node0:26657/websocket,ws://node1:26657/websocket
+
+  # crawl the network from one endpoint, and send to every peer found
+  %[1]s standalone --endpoints ws://node0:26657/websocket \
+      --endpoint-select-method discovered --expect-peers 4
+
+  # read the configuration from a file, overriding its rate
+  %[1]s standalone --config loadtest.yaml -r 500`, cli.AppName),
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			runStandalone()
+		},
+	}
+	addLoadTestFlags(standaloneCmd.Flags(), &cfg, cli)
+	addStandaloneFlags(standaloneCmd.Flags(), &cfg)
+	addLogFlags(standaloneCmd.Flags(), &cfg.LogLevel, &cfg.LogFormat)
+	addLogFileFlags(standaloneCmd.Flags(), &cfg.LogFile, &cfg.LogMaxSizeMB, &cfg.LogMaxBackups, &cfg.LogQuiet)
 
 	var coordCfg CoordinatorConfig
+	var workerOverridesFile, runsFile string
+	// whether the configuration file or environment sets the rate
+	var rateConfigured bool
 	coordCmd := &cobra.Command{
 		Use:   "coordinator",
 		Short: "Start load test application in COORDINATOR mode",
+		Example: fmt.Sprintf(`  # wait for 4 workers, then have each send 500 tx/sec on each connection for 10 minutes
+  %[1]s coordinator --bind 0.0.0.0:26670 --expect-workers 4 \
+      -T 10m -r 500 --endpoints ws://node0:26657/websocket,ws://node1:26657/websocket
+
+  # split 10000 tx/sec amongst however many workers take part
+  %[1]s coordinator --expect-workers 4 --max-workers 8 --total-rate 10000 \
+      --endpoints ws://node0:26657/websocket`, cli.AppName),
+		Args: cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
+			configureLogFile(cfg.LogFile, cfg.LogMaxSizeMB, cfg.LogMaxBackups, cfg.LogQuiet, logger)
+			configureLogging(cfg.LogLevel, cfg.LogFormat, logger)
+			if len(runsFile) > 0 {
+				runs, err := LoadRuns(runsFile)
+				if err != nil {
+					logger.Error(err.Error())
+					os.Exit(ExitCodeInvalidConfig)
+				}
+				for i, run := range runs {
+					for _, field := range bareSecondsFields(run) {
+						logger.Warn(fmt.Sprintf("%s of run %d is given as a bare number of seconds, which is deprecated - use a duration string (e.g. \"60s\") instead", field, i))
+					}
+				}
+				cfg.Runs = runs
+			}
+			// read once here, so that the workers are sent the endpoints
+			if err := cfg.LoadEndpointsFile(os.Stdin); err != nil {
+				logger.Error(err.Error())
+				os.Exit(ExitCodeInvalidConfig)
+			}
+			// the default rate doesn't apply when splitting a total rate
+			// amongst the workers
+			if coordCfg.TotalRate > 0 && !cmd.Flags().Changed("rate") && !rateConfigured {
+				cfg.Rate = 0
+			}
 			logger.Debug(fmt.Sprintf("Configuration: %s", cfg.ToJSON()))
 			logger.Debug(fmt.Sprintf("Coordinator configuration: %s", coordCfg.ToJSON()))
-			if err := cfg.Validate(); err != nil {
+			// the overrides count towards the load the workers send
+			if len(workerOverridesFile) > 0 {
+				overrides, err := LoadWorkerOverrides(workerOverridesFile)
+				if err != nil {
+					logger.Error(err.Error())
+					os.Exit(ExitCodeInvalidConfig)
+				}
+				coordCfg.WorkerOverrides = overrides
+			}
+			if err := coordCfg.ValidateConfig(cfg); err != nil {
 				logger.Error(err.Error())
-				os.Exit(1)
+				os.Exit(ExitCodeInvalidConfig)
+			}
+			// a resumed load test carries on writing the files of the load
+			// test that was checkpointed
+			if !coordCfg.Resume {
+				if err := cfg.ValidateOutputFiles(); err != nil {
+					logger.Error(err.Error())
+					os.Exit(ExitCodeInvalidConfig)
+				}
 			}
 			if err := coordCfg.Validate(); err != nil {
 				logger.Error(err.Error())
-				os.Exit(1)
+				os.Exit(ExitCodeInvalidConfig)
+			}
+			if err := coordCfg.ValidateWorkerOverrides(cfg); err != nil {
+				logger.Error(err.Error())
+				os.Exit(ExitCodeInvalidConfig)
 			}
+			txs, bytes := coordCfg.offeredLoad(cfg)
+			confirmLoad(cfg, txs, bytes, logger)
 			coord := NewCoordinator(&cfg, &coordCfg)
 			if err := coord.Run(); err != nil {
-				os.Exit(1)
+				os.Exit(ExitCodeFor(err))
 			}
 		},
 	}
+	addLoadTestFlags(coordCmd.Flags(), &cfg, cli)
+	addCoordinatorLoadTestFlags(coordCmd.Flags(), &cfg)
+	addLogFlags(coordCmd.Flags(), &cfg.LogLevel, &cfg.LogFormat)
+	addLogFileFlags(coordCmd.Flags(), &cfg.LogFile, &cfg.LogMaxSizeMB, &cfg.LogMaxBackups, &cfg.LogQuiet)
+	// accepted for backwards compatibility with the flags coordinators used
+	// to share with standalone load tests
+	legacyCoordFlags := pflag.NewFlagSet("coordinator", pflag.ContinueOnError)
+	addStandaloneFlags(legacyCoordFlags, &Config{})
+	deprecateFlags(legacyCoordFlags, "it only applies to standalone load tests")
+	coordCmd.Flags().AddFlagSet(legacyCoordFlags)
 	coordCmd.PersistentFlags().StringVar(&coordCfg.BindAddr, "bind", "localhost:26670", "A host:port combination to which to bind the coordinator on which to listen for worker connections")
 	coordCmd.PersistentFlags().IntVar(&coordCfg.ExpectWorkers, "expect-workers", 2, "The number of workers to expect to connect to the coordinator before starting load testing")
-	coordCmd.PersistentFlags().IntVar(&coordCfg.WorkerConnectTimeout, "connect-timeout", 180, "The maximum number of seconds to wait for all workers to connect")
-	coordCmd.PersistentFlags().IntVar(&coordCfg.ShutdownWait, "shutdown-wait", 0, "The number of seconds to wait after testing completes prior to shutting down the web server")
+	coordCmd.PersistentFlags().IntVar(&coordCfg.MinWorkers, "min-workers", 0, "The minimum number of workers with which to start the load test if --expect-workers haven't connected within --start-grace-period of the minimum connecting (0 to require --expect-workers)")
+	coordCmd.PersistentFlags().Var(newDurationValue(30*time.Second, &coordCfg.StartGracePeriod), "start-grace-period", "How long to keep waiting for --expect-workers once --min-workers have connected (e.g. 30s), before starting the load test without the missing workers")
+	coordCmd.PersistentFlags().IntVar(&coordCfg.MaxWorkers, "max-workers", 0, "The maximum number of workers that may take part in the load test - workers beyond --expect-workers join the test while it's underway (0 for unlimited)")
+	coordCmd.PersistentFlags().Var(newDurationValue(180*time.Second, &coordCfg.WorkerConnectTimeout), "connect-timeout", "The maximum time to wait for all workers to connect (e.g. 3m)")
+	coordCmd.PersistentFlags().Var(newDurationValue(0, &coordCfg.ShutdownWait), "shutdown-wait", "How long to wait after testing completes prior to shutting down the web server (e.g. 30s)")
 	coordCmd.PersistentFlags().IntVar(&coordCfg.LoadTestID, "load-test-id", 0, "The ID of the load test currently underway")
+	coordCmd.PersistentFlags().BoolVar(&coordCfg.ManualStart, "manual-start", false, "Once all the expected workers have connected, wait for the load test to be started via the control API (POST /v1/test/start) instead of starting it immediately")
+	coordCmd.PersistentFlags().Var(newDurationValue(0, &coordCfg.StartStagger), "start-stagger", "The delay (e.g. 500ms) between successive workers' starts, to avoid a synchronized burst of transactions at the start of the load test (0 to start all workers at once)")
+	coordCmd.PersistentFlags().BoolVar(&coordCfg.StartStaggerRandom, "start-stagger-random", false, "Start each worker at a random offset within the --start-stagger window (the stagger times one less than the number of workers) instead of at successive multiples of the stagger")
+	coordCmd.PersistentFlags().Var(newDurationValue(time.Second, &coordCfg.HeartbeatInterval), "heartbeat-interval", "How often workers send heartbeats to the coordinator (e.g. 1s)")
+	coordCmd.PersistentFlags().Var(newDurationValue(10*time.Second, &coordCfg.HeartbeatTimeout), "heartbeat-timeout", "How long without hearing from a worker after which the coordinator considers it failed (e.g. 10s - must be greater than --heartbeat-interval)")
+	coordCmd.PersistentFlags().BoolVar(&coordCfg.ContinueOnWorkerFailure, "continue-on-worker-failure", false, "Carry on with the load test without workers that fail (or stop sending heartbeats) once it's underway, instead of failing the load test")
+	coordCmd.PersistentFlags().BoolVar(&coordCfg.ShutdownWorkersOnCompletion, "shutdown-workers", false, "Tell the connected workers to shut down (exiting with code 0) once the load test completes or is cancelled, instead of cancelling or failing them")
+	coordCmd.PersistentFlags().StringVar(&coordCfg.StateFile, "state-file", "", "A file to which to periodically checkpoint the load test underway (removed once it's over), so that it can be resumed with --resume if the coordinator crashes")
+	coordCmd.PersistentFlags().Var(newDurationValue(5*time.Second, &coordCfg.CheckpointInterval), "checkpoint-interval", "How often to checkpoint the load test to --state-file (e.g. 5s)")
+	coordCmd.PersistentFlags().BoolVar(&coordCfg.Resume, "resume", false, "Resume the load test checkpointed to --state-file (if any), waiting for its workers to reconnect (see the workers' --max-reconnect-time), instead of starting afresh")
+	coordCmd.PersistentFlags().BoolVar(&coordCfg.ShardEndpoints, "shard-endpoints", false, "Partition the endpoints amongst the workers (round-robin, in order of worker ID), so that each worker only connects to its share of them, instead of every worker connecting to all of them")
+	coordCmd.PersistentFlags().BoolVar(&coordCfg.RedistributeShards, "redistribute-shards", false, "Give the endpoints of workers that fail during the load test (see --continue-on-worker-failure) to workers that join it later - requires --shard-endpoints")
+	coordCmd.PersistentFlags().StringVar(&coordCfg.TLSCertFile, "tls-cert", "", "A PEM-encoded TLS certificate (chain) with which to serve workers (wss://) and metrics (https://) - requires --tls-key")
+	coordCmd.PersistentFlags().StringVar(&coordCfg.TLSKeyFile, "tls-key", "", "The PEM-encoded private key of the TLS certificate given by --tls-cert")
+	coordCmd.PersistentFlags().BoolVar(&coordCfg.EnableCompression, "enable-compression", false, "Compress the messages exchanged with workers that also enable compression (permessage-deflate)")
+	coordCmd.PersistentFlags().Float64Var(&coordCfg.TotalRate, "total-rate", 0, "The overall transaction rate (tx/sec) to split evenly amongst the workers and their connections, rebalanced as workers join or fail - mutually exclusive with --rate (0 to give each connection --rate)")
+	coordCmd.PersistentFlags().StringVar(&workerOverridesFile, "worker-overrides", "", "A JSON file mapping worker IDs or label selectors to overrides of the endpoints, rate and/or connections given to those workers (e.g. {\"worker1\":{\"endpoints\":[\"ws://host:26657/websocket\"],\"rate\":500},\"region=us\":{\"connections\":2}})")
+	coordCmd.PersistentFlags().StringVar(&runsFile, "runs", "", "A JSON file listing runs to execute back to back with the same workers, each as overrides of the load testing configuration (e.g. [{\"rate\":100},{\"rate\":200}]) - each run's statistics are written to files suffixed with its index (e.g. stats-run0.csv)")
+	coordCmd.PersistentFlags().StringVar(&coordCfg.AuthToken, "auth-token", "", "A shared token that workers must present (via their --auth-token flag) in order to register - if not set, any worker may register")
 
 	var workerCfg WorkerConfig
 	workerCmd := &cobra.Command{
 		Use:   "worker",
 		Short: "Start load test application in WORKER mode",
+		Example: fmt.Sprintf(`  # connect to the coordinator, which gives the worker its load testing configuration
+  %[1]s worker --coordinator ws://coordinator:26670
+
+  # identify the worker in the coordinator's statistics and metrics
+  %[1]s worker --coordinator wss://coordinator:26670 --id eu-west-1a \
+      --labels region=eu-west-1,instance=c5.xlarge --auth-token "$TOKEN"`, cli.AppName),
+		Args: cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
+			// our own --verbose takes precedence over the coordinator's level
+			if flagVerbose && len(workerCfg.LogLevel) == 0 {
+				workerCfg.LogLevel = "debug"
+			}
+			configureLogFile(workerCfg.LogFile, workerCfg.LogMaxSizeMB, workerCfg.LogMaxBackups, workerCfg.LogQuiet, logger)
+			configureLogging(workerCfg.LogLevel, workerCfg.LogFormat, logger)
 			logger.Debug(fmt.Sprintf("Worker configuration: %s", workerCfg.ToJSON()))
 			if err := workerCfg.Validate(); err != nil {
 				logger.Error(err.Error())
-				os.Exit(1)
+				os.Exit(ExitCodeInvalidConfig)
 			}
 			worker, err := NewWorker(&workerCfg)
 			if err != nil {
 				logger.Error("Failed to create new worker", "err", err)
-				os.Exit(1)
+				os.Exit(ExitCodeInvalidConfig)
 			}
 			if err := worker.Run(); err != nil {
-				os.Exit(1)
+				os.Exit(ExitCodeFor(err))
 			}
 		},
 	}
-	workerCmd.PersistentFlags().StringVar(&workerCfg.ID, "id", "", "An optional unique ID for this worker. Will show up in metrics and logs. If not specified, a UUID will be generated.")
+	workerCmd.PersistentFlags().StringVar(&workerCfg.ID, "id", "", "An optional unique ID for this worker. Will show up in metrics and logs. If not specified, one will be generated from the host name and a short random suffix.")
+	workerCmd.PersistentFlags().StringToStringVar(&workerCfg.Labels, "labels", nil, "Optional comma-separated name=value labels (e.g. region=eu-west-1,instance=c5.large) identifying this worker in the coordinator's logs, metrics and statistics")
 	workerCmd.PersistentFlags().StringVar(&workerCfg.CoordAddr, "coordinator", "ws://localhost:26670", "The WebSockets URL on which to find the coordinator node")
-	workerCmd.PersistentFlags().IntVar(&workerCfg.CoordConnectTimeout, "connect-timeout", 180, "The maximum number of seconds to keep trying to connect to the coordinator")
+	workerCmd.PersistentFlags().StringVar(&workerCfg.MetricsAddr, "metrics-addr", "", "The host:port at which to serve this worker's Prometheus metrics at /metrics, overriding the coordinator's --worker-metrics-addr")
+	workerCmd.PersistentFlags().Var(newDurationValue(180*time.Second, &workerCfg.CoordConnectTimeout), "connect-timeout", "The maximum time to keep trying to connect to the coordinator, across all attempts (e.g. 3m)")
+	workerCmd.PersistentFlags().Var(newDurationValue(time.Second, &workerCfg.CoordRetryInterval), "retry-interval", "The initial interval between attempts to connect to the coordinator (e.g. 1s), which backs off (with jitter) with each failed attempt")
+	workerCmd.PersistentFlags().Var(newDurationValue(30*time.Second, &workerCfg.MaxReconnectTime), "max-reconnect-time", "The maximum time to keep trying to reconnect to the coordinator if the connection is lost during the load test (e.g. 1m), while continuing to generate load (0 to abort immediately)")
+	workerCmd.PersistentFlags().StringVar(&workerCfg.TLSCAFile, "tls-ca", "", "A PEM-encoded CA certificate bundle with which to verify the coordinator's TLS certificate (defaults to the system's CAs)")
+	workerCmd.PersistentFlags().BoolVar(&workerCfg.TLSInsecure, "tls-insecure", false, "Skip verification of the coordinator's TLS certificate (insecure - only for testing)")
+	workerCmd.PersistentFlags().StringVar(&workerCfg.AuthToken, "auth-token", "", "The shared token to present to the coordinator when registering, if it requires one")
+	workerCmd.PersistentFlags().BoolVar(&workerCfg.EnableCompression, "enable-compression", false, "Ask the coordinator to compress the messages exchanged with it (permessage-deflate), which it does if it also enables compression")
+	addLogFlags(workerCmd.PersistentFlags(), &workerCfg.LogLevel, &workerCfg.LogFormat)
+	addLogFileFlags(workerCmd.PersistentFlags(), &workerCfg.LogFile, &workerCfg.LogMaxSizeMB, &workerCfg.LogMaxBackups, &workerCfg.LogQuiet)
+	workerCmd.PersistentFlags().BoolVar(&workerCfg.JSONMessages, "json-messages", false, "Exchange messages with the coordinator as JSON rather than MessagePack (e.g. for debugging)")
+
+	// workers are given the load testing configuration by the coordinator,
+	// but used to accept its flags
+	legacyWorkerFlags := pflag.NewFlagSet("worker", pflag.ContinueOnError)
+	var legacyWorkerCfg Config
+	addLoadTestFlags(legacyWorkerFlags, &legacyWorkerCfg, cli)
+	addStandaloneFlags(legacyWorkerFlags, &legacyWorkerCfg)
+	addCoordinatorLoadTestFlags(legacyWorkerFlags, &legacyWorkerCfg)
+	deprecateFlags(legacyWorkerFlags, "workers are given the load testing configuration by the coordinator")
+	workerCmd.Flags().AddFlagSet(legacyWorkerFlags)
+
+	var reportFormat string
+	var reportCompare bool
+	var reportMetrics []string
+	var reportThreshold float64
+	reportWriter := &StatsWriter{}
+	reportCmd := &cobra.Command{
+		Use:   "report FILE...",
+		Short: "Display (or compare) the results of load tests from their statistics output files, in CSV or JSON format",
+		Example: fmt.Sprintf(`  # display a summary of the results
+  %[1]s report stats.json
+
+  # convert the results to CSV, as if written with --stats-output-format csv
+  %[1]s report stats.json --format csv --stats-csv-header > stats.csv
+
+  # fail if the transaction rate or p99 broadcast latency got more than 10%% worse
+  %[1]s report baseline.csv candidate.csv --compare --metric tx_rate,broadcast_latency_p99 --threshold 10`, cli.AppName),
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if reportCompare && len(args) != 2 {
+				logger.Error(fmt.Sprintf("--compare requires exactly 2 statistics files (a baseline and a candidate), but got %d", len(args)))
+				os.Exit(ExitCodeInvalidConfig)
+			}
+			if err := validateReportMetrics(reportMetrics); err != nil {
+				logger.Error(err.Error())
+				os.Exit(ExitCodeInvalidConfig)
+			}
+			reports := make([]Report, len(args))
+			for i, filename := range args {
+				report, err := loadReportFile(filename)
+				if err != nil {
+					logger.Error(err.Error())
+					os.Exit(ExitCodeInvalidConfig)
+				}
+				reports[i] = report
+			}
+			if reportCompare {
+				deltas := compareReports(reports[0], reports[1])
+				writeComparison(os.Stdout, deltas)
+				if err := checkRegressions(deltas, reportMetrics, reportThreshold); err != nil {
+					logger.Error(err.Error())
+					os.Exit(ExitCodeThreshold)
+				}
+				return
+			}
+			for i, report := range reports {
+				if len(reports) > 1 {
+					if i > 0 {
+						fmt.Println()
+					}
+					fmt.Printf("%s:\n", args[i])
+				}
+				if err := renderReport(os.Stdout, report, reportFormat, reportWriter); err != nil {
+					logger.Error(err.Error())
+					os.Exit(ExitCodeInvalidConfig)
+				}
+			}
+		},
+	}
+	reportCmd.Flags().StringVar(&reportFormat, "format", ReportFormatTable, "The format in which to display the results - can be table, csv (the aggregate statistics) or summary (a single-line JSON summary)")
+	reportCmd.Flags().BoolVar(&reportWriter.Header, "stats-csv-header", false, "Write a machine-readable header row and normalized unit names, if the format is csv")
+	reportCmd.Flags().Var(newRuneValue(',', &reportWriter.Delimiter), "stats-csv-delimiter", "The field delimiter, if the format is csv - a single character, or \\t for a tab")
+	reportCmd.Flags().BoolVar(&reportCompare, "compare", false, "Compare the results of two load tests (a baseline, followed by a candidate), displaying the change in each metric")
+	reportCmd.Flags().StringSliceVar(&reportMetrics, "metric", []string{"tx_rate"}, "The metrics which, when comparing results, must not regress by more than --threshold - any of "+strings.Join(reportMetricNames(), ", "))
+	reportCmd.Flags().Float64Var(&reportThreshold, "threshold", 5, "The percentage by which the --metric metrics may regress from the baseline to the candidate before exiting with an error")
+
+	listClientsCmd := &cobra.Command{
+		Use:   "list-clients",
+		Short: "List the client factories available to --client-factory and exit",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			writeClientFactories(os.Stdout, cli.DefaultClientFactory)
+		},
+	}
+
+	presetsCmd := &cobra.Command{
+		Use:   "presets",
+		Short: "List the built-in configuration presets available to --preset, with their effective values, and exit",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			writePresets(os.Stdout)
+		},
+	}
 
 	versionCmd := &cobra.Command{
 		Use:   "version",
-		Short: "Display the version of tm-load-test and exit",
+		Short: "Display the version of tm-load-test, with its build metadata, and exit",
+		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			version := CLIVersion
-			if len(cliVersionCommitID) > 0 {
-				version = fmt.Sprintf("%s-%s", version, cliVersionCommitID)
+			writeBuildInfo(os.Stdout, GetBuildInfo())
+		},
+	}
+
+	configEnvCmd := &cobra.Command{
+		Use:   "config-env",
+		Short: "Display the " + EnvPrefix + " environment variables from which the configuration can be read, with their effective values, and exit",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := writeConfigEnv(os.Stdout, &ConfigFile{Config: cfg, Coordinator: coordCfg, Worker: workerCfg}); err != nil {
+				logger.Error(err.Error())
+				os.Exit(ExitCodeFailure)
 			}
-			fmt.Println("tm-load-test", version)
 		},
 	}
 
 	rootCmd.AddCommand(coordCmd)
 	rootCmd.AddCommand(workerCmd)
+	rootCmd.AddCommand(standaloneCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(listClientsCmd)
+	rootCmd.AddCommand(presetsCmd)
 	rootCmd.AddCommand(versionCmd)
-	return rootCmd
+	rootCmd.AddCommand(configEnvCmd)
+
+	// any preset, the configuration file and then the environment are read
+	// over the defaults before the command line is parsed, so that flags given
+	// explicitly override their values
+	if name := presetArg(args); len(name) > 0 {
+		p, err := lookupPreset(name)
+		if err != nil {
+			return nil, err
+		}
+		p.apply(&cfg)
+	}
+	if filename, strict := configFileArgs(args); len(filename) > 0 {
+		file := ConfigFile{Config: cfg, Coordinator: coordCfg, Worker: workerCfg}
+		if err := LoadConfigFromFile(filename, strict, &file); err != nil {
+			return nil, err
+		}
+		cfg, coordCfg, workerCfg = file.Config, file.Coordinator, file.Worker
+		if doc, err := readConfigFile(filename); err == nil {
+			_, rateConfigured = doc["rate"]
+		}
+		for _, field := range configFileBareSeconds(filename) {
+			logger.Warn(fmt.Sprintf("%s in %s is given as a bare number of seconds, which is deprecated - use a duration string (e.g. \"60s\") instead", field, filename))
+		}
+	}
+	file := ConfigFile{Config: cfg, Coordinator: coordCfg, Worker: workerCfg}
+	bare, err := loadConfigFromEnv(os.LookupEnv, &file)
+	if err != nil {
+		return nil, err
+	}
+	cfg, coordCfg, workerCfg = file.Config, file.Coordinator, file.Worker
+	if rate, ok := os.LookupEnv(EnvPrefix + "RATE"); ok && len(strings.TrimSpace(rate)) > 0 {
+		rateConfigured = true
+	}
+	for _, name := range bare {
+		logger.Warn(fmt.Sprintf("%s is given as a bare number of seconds, which is deprecated - use a duration string (e.g. \"60s\") instead", name))
+	}
+	rootCmd.SetArgs(args)
+	return rootCmd, nil
+}
+
+// addLoadTestFlags adds the flags of the load testing configuration that
+// apply both to standalone load tests and to coordinators to the given flag
+// set.
+func addLoadTestFlags(fs *pflag.FlagSet, cfg *Config, cli *CLIConfig) {
+	// handled by presetArg, and only defined for the usage
+	fs.String("preset", "", "A built-in configuration preset to start from - can be "+strings.Join(PresetNames(), ", ")+" (see the presets command) - flags given explicitly, the --config file and the environment override its values")
+	fs.StringVar(&cfg.RunID, "run-id", "", "An identifier for this load test run, included in exported metrics and appended statistics (generated automatically if not specified)")
+	fs.StringVar(&cfg.ClientFactory, "client-factory", cli.DefaultClientFactory, "The identifier of the client factory to use for generating load testing transactions")
+	fs.IntVarP(&cfg.Connections, "connections", "c", 1, "The number of connections to open to each endpoint simultaneously")
+	fs.VarP(newDurationValue(60*time.Second, &cfg.Time), "time", "T", "The duration for which to handle the load test (e.g. 90s or 5m) - set to 0 to send --count transactions on each connection, however long it takes")
+	fs.Var(newDurationValue(0, &cfg.MaxRunTime), "max-run-time", "A safety cap on the duration of a load test with a --time of 0, after which it ends even if not all transactions have been sent (e.g. 1h) - 0 means no cap")
+	fs.VarP(newDurationValue(time.Second, &cfg.SendPeriod), "send-period", "p", "The period at which to send batches of transactions (e.g. 1s or 250ms)")
+	fs.Float64VarP(&cfg.Rate, "rate", "r", 1000, "The number of transactions to generate each second on each connection, to each endpoint (may be fractional, e.g. 0.1 for one transaction every 10 seconds)")
+	fs.IntVarP(&cfg.Size, "size", "s", 250, "The size of each transaction, in bytes - must be greater than 40")
+	fs.IntVarP(&cfg.Count, "count", "N", -1, "The maximum number of transactions to send - set to -1 to turn off this limit")
+	fs.BoolVar(&cfg.PregenerateTxs, "pregenerate-txs", false, "Generate each connection's transactions before the load test starts, so that generating them doesn't limit the rate")
+	fs.IntVar(&cfg.PregenerateCount, "pregenerate-count", 0, "The number of transactions to pre-generate per connection with --pregenerate-txs, after which the rest are generated as they're sent (0 for --count)")
+	fs.IntVar(&cfg.PregenerateMemoryLimitMB, "pregenerate-memory-limit-mb", 1024, "Refuse to pre-generate transactions estimated to take up more than this much memory (in megabytes)")
+	fs.StringVar(&cfg.BroadcastTxMethod, "broadcast-tx-method", "async", "The broadcast_tx method to use when submitting transactions - can be async, sync, commit or any registered custom method")
+	fs.StringSliceVar(&cfg.Endpoints, "endpoints", []string{}, "A comma-separated list of URLs indicating Tendermint WebSockets RPC endpoints to which to connect (or dns+ws:// and dnssrv+ws:// URLs to expand via A/AAAA or SRV records, or k8s://namespace/label=value:port URLs to expand into the ready pods matching the label via the Kubernetes API), each optionally suffixed with |maxrate=N to cap the rate (tx/sec) sent to that endpoint, |weight=N to give it a share of the connections proportional to N, and/or |name=ALIAS to label it in statistics, metrics and logs")
+	fs.StringVar(&cfg.EndpointsFile, "endpoints-file", "", "A file listing further endpoints to which to connect, one per line with the same options as --endpoints (blank lines and lines starting with # are ignored), merged with --endpoints - use - to read them from standard input")
+	fs.BoolVar(&cfg.StrictEndpoints, "strict-endpoints", false, "Fail if any endpoint has no path (e.g. ws://host:26657), rather than appending the default /websocket path to it")
+	fs.StringVar(&cfg.EndpointSelectMethod, "endpoint-select-method", SelectSuppliedEndpoints, "The method by which to select endpoints (supplied, discovered, any or lowest-latency)")
+	fs.StringVar(&cfg.RPCVersion, "rpc-version", RPCVersionAuto, "The RPC version of the endpoints - can be auto (detect per endpoint), legacy (e.g. /websocket) or v1 (e.g. /v1/websocket)")
+	fs.IntVar(&cfg.ExpectPeers, "expect-peers", 0, "The minimum number of peers to expect when crawling the P2P network from the specified endpoint(s) prior to waiting for workers to connect")
+	fs.IntVar(&cfg.MaxEndpoints, "max-endpoints", 0, "The maximum number of endpoints to use for testing, where 0 means unlimited - if more are discovered, this many are sampled at random")
+	fs.Int64Var(&cfg.Seed, "seed", 0, "The seed with which to sample discovered endpoints if there are more than max-endpoints, for reproducibility - 0 for a random seed")
+	fs.Var(newDurationValue(600*time.Second, &cfg.PeerConnectTimeout), "peer-connect-timeout", "How long to wait for all required peers to connect if expect-peers > 0 (e.g. 10m)")
+	fs.Var(newDurationValue(time.Second, &cfg.PeerPollInterval), "peer-poll-interval", "How often to query the peers while waiting for them to connect if expect-peers > 0 (e.g. 5s)")
+	fs.IntVar(&cfg.MinConnectivity, "min-peer-connectivity", 0, "The minimum number of peers to which each peer must be connected before starting the load test")
+	fs.StringArrayVar(&cfg.DiscoveryIncludePatterns, "discovery-include", nil, "A regular expression that the moniker or listen address of discovered peers must match for them to be used (may be given more than once, in which case peers must match any of them)")
+	fs.StringArrayVar(&cfg.DiscoveryExcludePatterns, "discovery-exclude", nil, "A regular expression matching the moniker or listen address of discovered peers (e.g. sentries or seeds) to leave out, even if they match --discovery-include (may be given more than once)")
+	fs.StringVar(&cfg.DiscoveryChainID, "discovery-chain-id", "", "Leave out discovered peers whose nodes report a chain ID other than this one")
+	fs.BoolVar(&cfg.HealthCheck, "health-check", false, "Check the health of each endpoint (supplied or discovered) before the load test, leaving out those that fail")
+	fs.Var(newDurationValue(0, &cfg.HealthCheckTimeout), "health-check-timeout", "How long each endpoint has to pass its health check (e.g. 2s), if health-check is set - 0 for the default of 5s")
+	fs.IntVar(&cfg.MinHealthyEndpoints, "min-healthy-endpoints", 0, "The minimum number of endpoints that must pass their health checks for the load test to go ahead, if health-check is set - 0 for at least one")
+	fs.Var(newDurationValue(0, &cfg.RediscoveryInterval), "rediscovery-interval", "How often to crawl the network again for endpoints that joined during the load test (e.g. 5m), adding those that are healthy - requires an endpoint-select-method of discovered or any, or DNS or Kubernetes endpoints, 0 to disable")
+	fs.IntVar(&cfg.MaxRediscoveredEndpoints, "max-rediscovered-endpoints", 0, "The maximum number of endpoints that re-discovery may add - 0 for no limit")
+	fs.IntVar(&cfg.PreferredEndpointCount, "preferred-endpoint-count", 1, "The number of endpoints with the lowest latency to which to send, if the endpoint-select-method is lowest-latency")
+	fs.IntVar(&cfg.EndpointFailureThreshold, "endpoint-failure-threshold", 0, "Blacklist an endpoint after this many consecutive failures (error responses, or failures to send or connect), redistributing its load amongst the healthy endpoints - 0 to never blacklist endpoints")
+	fs.Var(newDurationValue(0, &cfg.EndpointRecoveryInterval), "endpoint-recovery-interval", "How often to probe blacklisted endpoints' health (e.g. 10s), resuming sending to those that recover - 0 to never probe them")
+	fs.StringVar(&cfg.StatsOutputFile, "stats-output", "", "Where to store aggregate statistics (in CSV format) for the load test - may be an s3://bucket/key or gs://bucket/key URL to which to upload them")
+	fs.StringVar(&cfg.WorkerMetricsAddr, "worker-metrics-addr", "", "The host:port at which each worker (or the standalone load test) should serve its own Prometheus metrics at /metrics (e.g. :9100)")
+	fs.Float64SliceVar(&cfg.BroadcastLatencyBuckets, "broadcast-latency-buckets", nil, "Comma-separated bucket boundaries (in seconds) for broadcast latency histograms (default: exponential buckets from 1ms to ~16s)")
+	fs.StringVar(&cfg.StatsdAddr, "statsd-addr", "", "The host:port of a StatsD server (e.g. the Datadog agent) to which to send metrics over UDP")
+	fs.StringVar(&cfg.StatsdPrefix, "statsd-prefix", defaultStatsdPrefix, "The prefix to prepend to all StatsD metric names")
+	fs.StringVar(&cfg.InfluxDBURL, "influxdb-url", "", "The URL of an InfluxDB v2 server to which to export per-second statistics (e.g. http://localhost:8086)")
+	fs.StringVar(&cfg.InfluxDBToken, "influxdb-token", "", "The API token with which to authenticate to InfluxDB")
+	fs.StringVar(&cfg.InfluxDBOrg, "influxdb-org", "", "The InfluxDB organization that owns the bucket")
+	fs.StringVar(&cfg.InfluxDBBucket, "influxdb-bucket", "", "The InfluxDB bucket to which to write statistics")
+	fs.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", "", "The URL of an OpenTelemetry collector to which to export metrics using OTLP/HTTP (e.g. http://localhost:4318)")
+	fs.Var(newDurationValue(10*time.Second, &cfg.OTLPExportInterval), "otlp-export-interval", "How often to export metrics to the OpenTelemetry collector (e.g. 10s)")
+	fs.BoolVar(&cfg.StatsOverwrite, "overwrite-stats", false, "Overwrite any existing stats-output, raw-stats-output and latency-sample-output files, rather than refusing to run the load test")
+	fs.Var(newDurationValue(10*time.Second, &cfg.StatsFlushInterval), "stats-flush-interval", "How often to rewrite the stats-output file with the statistics gathered so far while the load test is underway (e.g. 10s), so that a crashed load test leaves them behind (0 to only write it once the load test is over)")
+	fs.BoolVar(&cfg.StatsAppend, "stats-append", false, "Append one row of aggregate statistics per run to the stats-output file (in CSV format), rather than overwriting it")
+	fs.StringVar(&cfg.StatsOutputFormat, "stats-output-format", StatsFormatCSV, "The format in which to write the aggregate statistics - can be csv or json")
+	fs.BoolVar(&cfg.RequireStatsUpload, "require-stats-upload", false, "Fail the load test if statistics cannot be uploaded to an s3:// or gs:// URL (by default, upload failures are only logged)")
+	fs.BoolVar(&cfg.StatsCSVHeader, "stats-csv-header", false, "Write a machine-readable header row (parameter,value,unit) and normalized unit names to the CSV aggregate statistics, rather than descriptive ones")
+	fs.Var(newRuneValue(',', &cfg.StatsCSVDelimiter), "stats-csv-delimiter", "The field delimiter of the CSV aggregate statistics - a single character, or \\t for a tab")
+	fs.Var(newDurationValue(10*time.Second, &cfg.ProgressInterval), "progress-interval", "How often to report progress during the load test (e.g. 10s - 0 to disable)")
+	fs.StringVar(&cfg.RawStatsOutputFile, "raw-stats-output", "", "Where to store per-interval timeseries statistics (in CSV format) for the load test - may be an s3://bucket/key or gs://bucket/key URL to which to upload them")
+	fs.Var(newDurationValue(time.Second, &cfg.RawStatsInterval), "raw-stats-interval", "How often to sample timeseries statistics (e.g. 5s - at least 1s), if raw-stats-output is set")
+	fs.StringVar(&cfg.LatencySampleFile, "latency-sample-output", "", "Where to store a uniform random sample of raw broadcast latencies (in CSV format) for offline analysis")
+	fs.Float64Var(&cfg.LatencySampleRate, "latency-sample-rate", 1, "The fraction of broadcast latencies to consider for inclusion in the raw latency sample (between 0 and 1)")
+	fs.Float64Var(&cfg.TraceSampleRate, "trace-sample-rate", 1, "The fraction of transactions whose details to log at the trace level (between 0 and 1), to keep the volume of trace logs manageable at high rates")
+	fs.IntVar(&cfg.LatencySampleCap, "latency-sample-cap", defaultLatencySampleCap, "The maximum number of raw broadcast latencies to retain (and write), which bounds memory usage")
+	fs.Var(newDurationValue(time.Second, &cfg.RateWindow), "rate-window", "The window (e.g. 1s) over which to measure transaction rates when computing the peak and minimum rates (0 to disable)")
+	fs.StringVar(&cfg.ResultWebhookURL, "result-webhook-url", "", "A URL to which to post the final results as JSON once the load test completes or fails")
+	fs.StringVar(&cfg.ResultWebhookSecret, "result-webhook-secret", "", "A shared secret with which to sign result webhook requests (HMAC-SHA256, in the "+WebhookSignatureHeader+" header)")
+	fs.BoolVar(&cfg.PrintSummaryJSON, "summary-json", false, "Print a single-line JSON summary of the load test to stdout on completion (logs are always written to stderr)")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "Validate the configuration, client factory and endpoints (and, for a coordinator, wait for the workers to register), then print the load that would be generated and exit without sending any transactions")
+	fs.Int64Var(&cfg.MaxTotalTxs, "max-total-txs", 0, "Refuse to run a load test that would send more than this many transactions in total, across all workers (0 for no limit)")
+	fs.Int64Var(&cfg.MaxTotalBytes, "max-total-bytes", 0, "Refuse to run a load test that would send more than this many transaction bytes in total, across all workers (0 for no limit)")
+	fs.Int64Var(&cfg.ConfirmTotalTxs, "confirm-total-txs", 0, "Ask for confirmation before running a load test that would send more than this many transactions in total (0 to never ask)")
+	fs.Int64Var(&cfg.ConfirmTotalBytes, "confirm-total-bytes", 0, "Ask for confirmation before running a load test that would send more than this many transaction bytes in total (0 to never ask)")
+	fs.BoolVarP(&cfg.AssumeYes, "yes", "y", false, "Go ahead with the load test without asking for confirmation, even if it exceeds --confirm-total-txs or --confirm-total-bytes")
+	fs.BoolVar(&cfg.StrictFeasibility, "strict-feasibility", false, "Fail, rather than just warn, if the rate looks unachievable given the send period and number of connections")
+	fs.BoolVar(&cfg.SkipCalibration, "skip-calibration", false, "Don't measure how long it takes to generate transactions in a dry run (e.g. in CI), assuming a rough default when estimating whether the rate is achievable")
+	fs.Float64Var(&cfg.MaxRateDeviation, "max-rate-deviation", 5, "Warn if the achieved transaction rate falls short of the target rate by more than this percentage (0 to disable)")
+	fs.Var(newDurationValue(3*time.Second, &cfg.DrainTimeout), "drain-timeout", "The maximum time to keep reading in-flight responses after sending stops (e.g. 3s - 0 disables draining)")
+	fs.Var(newDurationValue(0, &cfg.ErrorLogInterval), "error-log-interval", "How often each category of error that can recur with every transaction (e.g. rejections, or failures to send to an endpoint that's down) is logged on each connection, at most, with how many times it was repeated (e.g. 30s) - 0 for the default of 10s, or negative to log every error")
+	fs.IntVar(&cfg.MempoolPauseThreshold, "mempool-pause-threshold", 0, "Pause sending to an endpoint while its mempool holds more than this many transactions (0 disables mempool monitoring)")
+	fs.IntVar(&cfg.MempoolResumeThreshold, "mempool-resume-threshold", 0, "Resume sending to a paused endpoint once its mempool holds fewer than this many transactions (defaults to half the pause threshold)")
+	fs.Var(newDurationValue(time.Second, &cfg.MempoolPollInterval), "mempool-poll-interval", "How often to poll endpoints' mempool sizes (e.g. 500ms)")
+	fs.BoolVar(&cfg.IgnoreRateLimitShortfall, "ignore-rate-limit-shortfall", false, "Allow endpoint rate limits (maxrate) to cap the overall rate below the requested rate")
+	fs.BoolVar(&cfg.TrackCommitLatency, "track-commit-latency", false, "Subscribe to new blocks on one endpoint to measure send-to-commit latency")
+	fs.BoolVar(&cfg.ChainStats, "chain-stats", false, "Query block-level statistics over the load test's time window from one endpoint once the load test completes")
+	fs.Var(newDurationValue(30*time.Second, &cfg.ChainStatsTimeout), "chain-stats-timeout", "The maximum time to spend querying block-level statistics (e.g. 30s), if chain-stats is set")
+}
+
+// addStandaloneFlags adds the flags of the load testing configuration that
+// only apply to standalone load tests to the given flag set.
+func addStandaloneFlags(fs *pflag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.ProgressMode, "progress", ProgressModeBar, "How to display progress in standalone mode - can be bar (a status line, if stdout is a terminal), log or none")
+	fs.Float64Var(&cfg.MinSuccessRatio, "min-success-ratio", 0, "The minimum fraction of transactions that must succeed (between 0 and 1) for a standalone load test to exit successfully")
+}
+
+// addCoordinatorLoadTestFlags adds the flags of the load testing
+// configuration that only apply to coordinators to the given flag set.
+func addCoordinatorLoadTestFlags(fs *pflag.FlagSet, cfg *Config) {
+	fs.Var(newDurationValue(3*time.Second, &cfg.StatsPushInterval), "stats-push-interval", "How often workers push the statistics they gathered since their previous push to the coordinator (e.g. 3s)")
+}
+
+// addLogFlags adds the flags configuring our logging to the given flag set.
+func addLogFlags(fs *pflag.FlagSet, level, format *string) {
+	fs.StringVar(level, "log-level", "", "The minimum level of the log entries to write - can be trace (which logs the details of each transaction - see --trace-sample-rate), debug, info, warn or error (defaults to info, or debug with --verbose, which this overrides - workers default to the coordinator's)")
+	fs.StringVar(format, "log-format", "", "The format in which to write log entries - can be text or json, with each entry's fields as top-level keys (defaults to text - workers default to the coordinator's)")
+}
+
+// addLogFileFlags adds the flags configuring the file to which to write log
+// entries to the given flag set.
+func addLogFileFlags(fs *pflag.FlagSet, file *string, maxSizeMB, maxBackups *int, quiet *bool) {
+	fs.StringVar(file, "log-file", "", "A file to which to write log entries as well as stderr, which is reopened on SIGHUP (e.g. from logrotate)")
+	fs.IntVar(maxSizeMB, "log-max-size-mb", 100, "The size (in megabytes) beyond which to rotate the --log-file, keeping the previous one as FILE.1 (0 to never rotate it)")
+	fs.IntVar(maxBackups, "log-max-backups", 5, "The number of rotated --log-files (FILE.1, FILE.2, etc.) to keep")
+	fs.BoolVar(quiet, "log-quiet", false, "Only write log entries to the --log-file, and not to stderr")
+}
+
+// deprecateFlags marks all of the flags in the given flag set as deprecated
+// (which hides them from the usage) for the given reason.
+func deprecateFlags(fs *pflag.FlagSet, reason string) {
+	fs.VisitAll(func(f *pflag.Flag) {
+		f.Deprecated = reason
+		f.Hidden = true
+	})
+}
+
+// writeClientFactories writes the names of the registered client factories to
+// w, one per line, marking the default.
+func writeClientFactories(w io.Writer, defaultFactory string) {
+	for _, name := range registeredClientFactories() {
+		if name == defaultFactory {
+			name += " (default)"
+		}
+		fmt.Fprintln(w, name)
+	}
+}
+
+// configureLogging configures our logging with the given level and format, if
+// given, exiting if either is invalid.
+func configureLogging(level, format string, logger logging.Logger) {
+	if err := logging.Configure(level, format); err != nil {
+		logger.Error(err.Error())
+		os.Exit(ExitCodeInvalidConfig)
+	}
+}
+
+// configureLogFile starts writing log entries to the given file, if any,
+// exiting if it can't be opened.
+func configureLogFile(file string, maxSizeMB, maxBackups int, quiet bool, logger logging.Logger) {
+	if err := validateLogFile(file, maxSizeMB, maxBackups, quiet); err != nil {
+		logger.Error(err.Error())
+		os.Exit(ExitCodeInvalidConfig)
+	}
+	if len(file) == 0 {
+		return
+	}
+	if err := logging.OpenFile(file, logging.FileOptions{MaxSizeMB: maxSizeMB, MaxBackups: maxBackups, Quiet: quiet}); err != nil {
+		logger.Error(err.Error())
+		os.Exit(ExitCodeInvalidConfig)
+	}
 }
 
 func initLogLevel(logger logging.Logger) {
@@ -140,29 +632,152 @@ func initLogLevel(logger logging.Logger) {
 	}
 }
 
+// progressBarLogHook clears the progress bar before each log entry is
+// written, so that log output doesn't get mixed up with the status line. The
+// bar is redrawn on its next refresh.
+type progressBarLogHook struct {
+	bar *progressBar
+}
+
+var _ logrus.Hook = (*progressBarLogHook)(nil)
+
+func (h *progressBarLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *progressBarLogHook) Fire(*logrus.Entry) error {
+	h.bar.Clear()
+	return nil
+}
+
+// attachProgressBar suppresses informational logging (unless debug logging is
+// enabled) while the given progress bar is active, and ensures that any other
+// log output doesn't interfere with it. The returned function undoes this.
+func attachProgressBar(bar *progressBar) func() {
+	std := logrus.StandardLogger()
+	level := std.GetLevel()
+	if level == logrus.InfoLevel {
+		std.SetLevel(logrus.WarnLevel)
+	}
+	hooks := make(logrus.LevelHooks)
+	for lvl, hs := range std.Hooks {
+		hooks[lvl] = append(hooks[lvl], hs...)
+	}
+	std.AddHook(&progressBarLogHook{bar: bar})
+	return func() {
+		std.ReplaceHooks(hooks)
+		std.SetLevel(level)
+	}
+}
+
 // Run must be executed from your `main` function in your Go code. This can be
 // used to fast-track the construction of your own load testing tool for your
-// Tendermint ABCI application.
+// Tendermint ABCI application. Unlike the rest of the package, it logs via
+// logrus unless a logger was given to SetLogger beforehand.
 func Run(cli *CLIConfig) {
+	defaultToLogrus()
 	logger := logging.NewLogrusLogger("main") //初始化日志
-	logger.Info("---start Run----")
-	if err := buildCLI(cli, logger).Execute(); err != nil { //调用buildCLI，然后在
+	cmd, err := buildCLI(cli, os.Args[1:], logger)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(ExitCodeInvalidConfig)
+	}
+	if err := cmd.Execute(); err != nil { //调用buildCLI，然后在
 		logger.Error("Error", "err", err)
+		// usually a command line parsing error
+		os.Exit(ExitCodeInvalidConfig)
 	}
 }
 
+// confirmLoad logs the total number of transactions and of transaction bytes
+// that the load test would send and, if that exceeds the thresholds above which
+// it must be confirmed, asks the user to confirm it, exiting if they don't.
+func confirmLoad(cfg Config, txs, bytes uint64, logger logging.Logger) {
+	logger.Info("Offered load", "totalTxs", txs, "totalBytes", bytes)
+	if !cfg.needsConfirmation(txs, bytes) {
+		return
+	}
+	if err := confirmOfferedLoad(txs, bytes, os.Stdin, os.Stderr); err != nil {
+		logger.Error(err.Error())
+		os.Exit(ExitCodeInvalidConfig)
+	}
+}
+
+// trapInterrupts calls onKill when SIGINT or SIGTERM is received, until the
+// returned channel is closed (see trapSignals).
 func trapInterrupts(onKill func(), logger logging.Logger) chan struct{} {
-	sigc := make(chan os.Signal, 1)
-	cancelTrap := make(chan struct{})
-	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		select {
-		case <-sigc:
-			logger.Info("Caught kill signal")
-			onKill()
-		case <-cancelTrap:
-			logger.Debug("Interrupt trap cancelled")
+	return trapSignals(onKill, onKill, logger)
+}
+
+// runeValue is a command line flag holding a single character.
+type runeValue struct {
+	r *rune
+}
+
+func newRuneValue(val rune, r *rune) *runeValue {
+	*r = val
+	return &runeValue{r: r}
+}
+
+func (v *runeValue) String() string {
+	if *v.r == '\t' {
+		return `\t`
+	}
+	return string(*v.r)
+}
+
+func (v *runeValue) Set(s string) error {
+	if s == `\t` {
+		*v.r = '\t'
+		return nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return fmt.Errorf("expected a single character, but got %q", s)
+	}
+	*v.r, _ = utf8.DecodeRuneInString(s)
+	return nil
+}
+
+func (v *runeValue) Type() string {
+	return "char"
+}
+
+// durationValue is a command line flag holding a duration, given either as a
+// duration string (e.g. "250ms") or as a (deprecated) bare number of seconds.
+type durationValue struct {
+	d           *Duration
+	bareSeconds bool // Was the flag last set to a bare number of seconds?
+}
+
+func newDurationValue(val time.Duration, d *Duration) *durationValue {
+	*d = Duration(val)
+	return &durationValue{d: d}
+}
+
+func (v *durationValue) String() string {
+	return v.d.String()
+}
+
+func (v *durationValue) Set(s string) error {
+	d, bareSeconds, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v.d, v.bareSeconds = d, bareSeconds
+	return nil
+}
+
+func (v *durationValue) Type() string {
+	return "duration"
+}
+
+// warnBareSeconds warns about any duration flags that were given as bare
+// numbers of seconds.
+func warnBareSeconds(flags *pflag.FlagSet, logger logging.Logger) {
+	flags.Visit(func(f *pflag.Flag) {
+		// a --time of 0 or -1 (no time limit) means the same either way
+		if v, ok := f.Value.(*durationValue); ok && v.bareSeconds && *v.d != 0 && *v.d != Duration(-time.Second) {
+			logger.Warn(fmt.Sprintf("--%s was given as a bare number of seconds (%s), which is deprecated - use a duration string (e.g. --%s=%s) instead", f.Name, v.d, f.Name, v.d))
 		}
-	}()
-	return cancelTrap
+	})
 }
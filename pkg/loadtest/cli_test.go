@@ -0,0 +1,198 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testCLI = &CLIConfig{AppName: "tm-load-test", DefaultClientFactory: "kvstore"}
+
+// parseTestCLI parses the given command line arguments, returning the
+// subcommand they select.
+func parseTestCLI(t *testing.T, args ...string) (*cobra.Command, error) {
+	root, err := buildCLI(testCLI, args, logging.NewNoopLogger())
+	require.NoError(t, err)
+	cmd, flags, err := root.Find(args)
+	require.NoError(t, err, args)
+	return cmd, cmd.ParseFlags(flags)
+}
+
+func assertFlags(t *testing.T, cmd *cobra.Command, expected map[string]string) {
+	for name, value := range expected {
+		f := cmd.Flags().Lookup(name)
+		require.NotNil(t, f, "%s --%s", cmd.Name(), name)
+		assert.Equal(t, value, f.Value.String(), "%s --%s", cmd.Name(), name)
+	}
+}
+
+func TestCLIStandalone(t *testing.T) {
+	cmd, err := parseTestCLI(t, "standalone", "-c", "2", "-T", "5m", "-r", "0.5", "--progress", "log", "--min-success-ratio", "0.99", "--endpoints", "ws://node0:26657/websocket")
+	require.NoError(t, err)
+	assert.Equal(t, "standalone", cmd.Name())
+	assertFlags(t, cmd, map[string]string{
+		"connections":       "2",
+		"time":              "5m0s",
+		"rate":              "0.5",
+		"progress":          "log",
+		"min-success-ratio": "0.99",
+		"endpoints":         "[ws://node0:26657/websocket]",
+		"client-factory":    "kvstore",
+	})
+	assert.Contains(t, cmd.Example, "tm-load-test standalone -c 2")
+
+	// only standalone load testing flags are accepted
+	for _, flag := range []string{"--expect-workers=2", "--coordinator=ws://localhost:26670", "--stats-push-interval=1s"} {
+		_, err := parseTestCLI(t, "standalone", flag)
+		assert.ErrorContains(t, err, "unknown flag", flag)
+	}
+}
+
+func TestCLICoordinator(t *testing.T) {
+	cmd, err := parseTestCLI(t, "coordinator", "--expect-workers", "4", "-r", "500", "--stats-push-interval", "1s", "--endpoints", "ws://node0:26657/websocket")
+	require.NoError(t, err)
+	assert.Equal(t, "coordinator", cmd.Name())
+	assertFlags(t, cmd, map[string]string{
+		"expect-workers":      "4",
+		"rate":                "500",
+		"stats-push-interval": "1s",
+		"bind":                "localhost:26670",
+	})
+	for _, flag := range []string{"--id=worker1", "--coordinator=ws://localhost:26670"} {
+		_, err := parseTestCLI(t, "coordinator", flag)
+		assert.ErrorContains(t, err, "unknown flag", flag)
+	}
+
+	// standalone-only flags are still accepted, but deprecated and hidden
+	cmd, err = parseTestCLI(t, "coordinator", "--progress", "none")
+	require.NoError(t, err)
+	assert.NotEmpty(t, cmd.Flags().Lookup("progress").Deprecated)
+	assert.NotContains(t, cmd.UsageString(), "--progress string")
+	assert.Contains(t, cmd.UsageString(), "--total-rate")
+}
+
+func TestCLIWorker(t *testing.T) {
+	cmd, err := parseTestCLI(t, "worker", "--coordinator", "ws://coordinator:26670", "--labels", "region=eu-west-1")
+	require.NoError(t, err)
+	assert.Equal(t, "worker", cmd.Name())
+	assertFlags(t, cmd, map[string]string{
+		"coordinator": "ws://coordinator:26670",
+		"labels":      "[region=eu-west-1]",
+	})
+	usage := cmd.UsageString()
+	assert.Contains(t, usage, "--coordinator")
+	assert.NotContains(t, usage, "--rate")
+	assert.NotContains(t, usage, "--endpoints")
+
+	// the load testing flags workers used to accept (and ignore) are still
+	// accepted, but deprecated
+	cmd, err = parseTestCLI(t, "worker", "-c", "2", "-r", "100", "--endpoints", "ws://node0:26657/websocket", "--coordinator", "ws://coordinator:26670")
+	require.NoError(t, err)
+	for _, name := range []string{"connections", "rate", "endpoints"} {
+		assert.NotEmpty(t, cmd.Flags().Lookup(name).Deprecated, name)
+	}
+	_, err = parseTestCLI(t, "worker", "--expect-workers", "2")
+	assert.ErrorContains(t, err, "unknown flag")
+}
+
+func TestCLILegacyStandalone(t *testing.T) {
+	// flags given without a subcommand configure a standalone load test
+	cmd, err := parseTestCLI(t, "-c", "4", "-T", "10s", "--progress", "none", "--stats-push-interval", "2s", "--endpoints", "ws://node0:26657/websocket")
+	require.NoError(t, err)
+	assert.Equal(t, "tm-load-test", cmd.Name())
+	assertFlags(t, cmd, map[string]string{
+		"connections": "4",
+		"time":        "10s",
+		"progress":    "none",
+		"endpoints":   "[ws://node0:26657/websocket]",
+	})
+	assert.Equal(t, 5, cmd.Flags().NFlag())
+	// but they're hidden from the root command's usage, which lists the
+	// subcommands
+	usage := cmd.UsageString()
+	assert.NotContains(t, usage, "--connections")
+	assert.Contains(t, usage, "--config")
+	for _, sub := range []string{"standalone", "coordinator", "worker", "report", "list-clients", "presets", "config-env", "version"} {
+		assert.Contains(t, usage, "  "+sub+" ", sub)
+	}
+
+	_, err = parseTestCLI(t, "--expect-workers", "2")
+	assert.ErrorContains(t, err, "unknown flag")
+}
+
+func TestCLIReport(t *testing.T) {
+	cmd, err := parseTestCLI(t, "report", "stats.json", "--format", "csv", "--stats-csv-delimiter", `\t`)
+	require.NoError(t, err)
+	assert.Equal(t, "report", cmd.Name())
+	assertFlags(t, cmd, map[string]string{"format": "csv", "stats-csv-delimiter": `\t`})
+	assert.NoError(t, cmd.ValidateArgs([]string{"stats.json"}))
+	assert.Error(t, cmd.ValidateArgs(nil))
+	_, err = parseTestCLI(t, "report", "--rate", "1")
+	assert.ErrorContains(t, err, "unknown flag")
+
+	cmd, err = parseTestCLI(t, "report", "baseline.csv", "candidate.json", "--compare", "--metric", "tx_rate,broadcast_latency_p99", "--threshold", "10")
+	require.NoError(t, err)
+	assertFlags(t, cmd, map[string]string{"compare": "true", "metric": "[tx_rate,broadcast_latency_p99]", "threshold": "10"})
+	assert.NoError(t, cmd.ValidateArgs([]string{"baseline.csv", "candidate.json"}))
+}
+
+func TestRenderReport(t *testing.T) {
+	report := NewReport(
+		Config{RunID: "nightly", Rate: 10},
+		AggregateStats{
+			TotalTxs:         100,
+			TotalTimeSeconds: 10,
+			TotalBytes:       25000,
+			FailedTxs:        5,
+			BroadcastLatency: &LatencyStats{Count: 100, P50: 0.01, P99: 0.04, Max: 0.05},
+		},
+		[]WorkerStats{{ID: "worker1", TotalTxs: 100, TotalTimeSeconds: 10}},
+	)
+	b, err := json.Marshal(report)
+	require.NoError(t, err)
+	filename := filepath.Join(t.TempDir(), "stats.json")
+	require.NoError(t, os.WriteFile(filename, b, 0o600))
+	loaded, err := LoadReport(filename)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, renderReport(&buf, loaded, ReportFormatTable, &StatsWriter{}))
+	table := strings.Join(strings.Fields(buf.String()), " ")
+	assert.Contains(t, table, "Run nightly")
+	assert.Contains(t, table, "Transactions 100 (10.00 txs/sec)")
+	assert.Contains(t, table, "Failures 5 (95.00% success)")
+
+	buf.Reset()
+	require.NoError(t, renderReport(&buf, loaded, ReportFormatCSV, &StatsWriter{Header: true, Delimiter: ';'}))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "parameter;value;unit", lines[0])
+	assert.Contains(t, buf.String(), "\ntotal_txs;100;")
+	assert.Contains(t, buf.String(), "worker1")
+
+	buf.Reset()
+	require.NoError(t, renderReport(&buf, loaded, ReportFormatSummary, &StatsWriter{}))
+	var summary Summary
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &summary))
+	assert.Equal(t, Summary{RunID: "nightly", Success: true, TotalTxs: 100, TotalBytes: 25000, TotalTimeSeconds: 10, AchievedTxRate: 10, FailedTxs: 5}, summary)
+
+	assert.ErrorContains(t, renderReport(&buf, loaded, "xml", &StatsWriter{}), "unsupported report format: xml")
+
+	// CSV statistics can't be read back
+	require.NoError(t, os.WriteFile(filename, []byte("Parameter,Value,Units\n"), 0o600))
+	_, err = LoadReport(filename)
+	assert.ErrorContains(t, err, "--stats-output-format json")
+}
+
+func TestWriteClientFactories(t *testing.T) {
+	var buf bytes.Buffer
+	writeClientFactories(&buf, "kvstore")
+	assert.Contains(t, strings.Split(buf.String(), "\n"), "kvstore (default)")
+}
@@ -1,6 +1,9 @@
 package loadtest
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 // ClientFactory produces load testing clients.
 type ClientFactory interface {
@@ -20,6 +23,19 @@ type Client interface {
 	GenerateTx() ([]byte, error)
 }
 
+// PrioritizedClient is a Client that can also generate transactions with a
+// priority hint, e.g. derived from a fee embedded in the transaction, for
+// testing prioritized mempools. If commit latency tracking is enabled,
+// latencies are additionally reported per priority, so priorities should be
+// drawn from a small set of bands (e.g. low, medium and high fees).
+type PrioritizedClient interface {
+	Client
+
+	// GeneratePrioritizedTx must generate a raw transaction along with the
+	// priority we expect the application to assign to it.
+	GeneratePrioritizedTx() ([]byte, int64, error)
+}
+
 // Our global registry of client factories
 var clientFactories = map[string]ClientFactory{}
 
@@ -32,3 +48,14 @@ func RegisterClientFactory(name string, factory ClientFactory) error {
 	clientFactories[name] = factory
 	return nil
 }
+
+// registeredClientFactories returns the names of the registered client
+// factories, in alphabetical order.
+func registeredClientFactories() []string {
+	names := make([]string, 0, len(clientFactories))
+	for name := range clientFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
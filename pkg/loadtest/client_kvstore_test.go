@@ -11,25 +11,25 @@ func TestKVStoreClientFactoryConfigValidation(t *testing.T) {
 		config loadtest.Config
 		err    bool
 	}{
-		{loadtest.Config{Size: 1, Rate: 1000, Time: 1000, Count: -1}, true},  // invalid tx size
-		{loadtest.Config{Size: 10, Rate: 1000, Time: 1000, Count: -1}, true}, // tx size is too small
+		{loadtest.Config{Size: 1, Rate: 1000, Time: seconds(1000), Count: -1}, true},  // invalid tx size
+		{loadtest.Config{Size: 10, Rate: 1000, Time: seconds(1000), Count: -1}, true}, // tx size is too small
 
-		{loadtest.Config{Size: 14, Rate: 1000, Time: 10000, Count: -1}, false}, // just right for parameters
+		{loadtest.Config{Size: 14, Rate: 1000, Time: seconds(10000), Count: -1}, false}, // just right for parameters
 
-		{loadtest.Config{Size: 20, Rate: 1000, Time: 10, Count: -1}, false},   // 10k txs @ 20 bytes each
-		{loadtest.Config{Size: 20, Rate: 1000, Time: 100, Count: -1}, false},  // 100k txs @ 20 bytes each
-		{loadtest.Config{Size: 20, Rate: 1000, Time: 1000, Count: -1}, false}, // 1m txs @ 20 bytes each
+		{loadtest.Config{Size: 20, Rate: 1000, Time: seconds(10), Count: -1}, false},   // 10k txs @ 20 bytes each
+		{loadtest.Config{Size: 20, Rate: 1000, Time: seconds(100), Count: -1}, false},  // 100k txs @ 20 bytes each
+		{loadtest.Config{Size: 20, Rate: 1000, Time: seconds(1000), Count: -1}, false}, // 1m txs @ 20 bytes each
 
-		{loadtest.Config{Size: 100, Rate: 1000, Time: 10, Count: -1}, false},
-		{loadtest.Config{Size: 100, Rate: 1000, Time: 100000, Count: -1}, false}, // 100m txs @ 100 bytes each
+		{loadtest.Config{Size: 100, Rate: 1000, Time: seconds(10), Count: -1}, false},
+		{loadtest.Config{Size: 100, Rate: 1000, Time: seconds(100000), Count: -1}, false}, // 100m txs @ 100 bytes each
 
-		{loadtest.Config{Size: 250, Rate: 1000, Time: 10, Count: -1}, false},
+		{loadtest.Config{Size: 250, Rate: 1000, Time: seconds(10), Count: -1}, false},
 
-		{loadtest.Config{Size: 10240, Rate: 1000, Time: 10, Count: -1}, false},     // 10k txs @ 10kB each
-		{loadtest.Config{Size: 10240, Rate: 1000, Time: 100, Count: -1}, false},    // 100k txs @ 10kB each
-		{loadtest.Config{Size: 10240, Rate: 1000, Time: 1000, Count: -1}, false},   // 1m txs @ 10kB each
-		{loadtest.Config{Size: 10240, Rate: 1000, Time: 10000, Count: -1}, false},  // 10m txs @ 10kB each
-		{loadtest.Config{Size: 10240, Rate: 1000, Time: 100000, Count: -1}, false}, // 100m txs @ 10kB each
+		{loadtest.Config{Size: 10240, Rate: 1000, Time: seconds(10), Count: -1}, false},     // 10k txs @ 10kB each
+		{loadtest.Config{Size: 10240, Rate: 1000, Time: seconds(100), Count: -1}, false},    // 100k txs @ 10kB each
+		{loadtest.Config{Size: 10240, Rate: 1000, Time: seconds(1000), Count: -1}, false},   // 1m txs @ 10kB each
+		{loadtest.Config{Size: 10240, Rate: 1000, Time: seconds(10000), Count: -1}, false},  // 10m txs @ 10kB each
+		{loadtest.Config{Size: 10240, Rate: 1000, Time: seconds(100000), Count: -1}, false}, // 100m txs @ 10kB each
 	}
 	factory := loadtest.NewKVStoreClientFactory()
 	for i, tc := range testCases {
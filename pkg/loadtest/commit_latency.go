@@ -0,0 +1,336 @@
+package loadtest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	// The maximum number of sent transactions awaiting commitment to track at
+	// any one time. This bounds the memory used for correlating commits.
+	commitTrackerMaxPending = 100000
+
+	// Transactions that have not been committed within this time are no
+	// longer tracked.
+	commitTrackerStaleTimeout = 5 * time.Minute
+
+	// Beyond this transaction rate (per TransactorGroup), only a sample of
+	// transactions is tracked.
+	commitTrackerMaxSampleRate = 1000
+
+	// The maximum number of distinct priorities for which to report separate
+	// latencies.
+	commitTrackerMaxPriorityBands = 32
+
+	commitTrackerReconnectInterval = 1 * time.Second
+	commitTrackerSubscribeID       = 1
+	commitTrackerQuery             = "tm.event='NewBlock'"
+)
+
+// commitTracker subscribes to new block events on a single endpoint and
+// correlates the transactions in each block with the times at which they were
+// sent, to measure send-to-commit latency.
+type commitTracker struct {
+	remoteAddr  string
+	sampleEvery uint64 // Only track every N-th transaction.
+	logger      logging.Logger
+
+	sent atomic.Uint64 // The total number of transactions sent.
+
+	mtx                 sync.Mutex
+	pending             map[[32]byte]pendingTx // Tracked transactions, keyed by tx hash.
+	dropped             int                    // Transactions not tracked because we were already tracking too many.
+	latencies           *latencySketch
+	latenciesByPriority map[int64]*latencySketch // Only populated for transactions from a PrioritizedClient.
+	tooManyPriorities   bool                     // Have we seen more than commitTrackerMaxPriorityBands distinct priorities?
+	reconnects          int
+	conn                *websocket.Conn
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// PriorityLatencyStats summarizes the distribution of latencies for
+// transactions of a particular priority.
+type PriorityLatencyStats struct {
+	Priority int64 `json:"priority"`
+	LatencyStats
+}
+
+// priorityLatencyStats summarizes the given per-priority latency sketches,
+// ordered by ascending priority.
+func priorityLatencyStats(sketches map[int64]*latencySketch) []PriorityLatencyStats {
+	if len(sketches) == 0 {
+		return nil
+	}
+	stats := make([]PriorityLatencyStats, 0, len(sketches))
+	for priority, sketch := range sketches {
+		stats = append(stats, PriorityLatencyStats{Priority: priority, LatencyStats: *sketch.Stats()})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Priority < stats[j].Priority })
+	return stats
+}
+
+type pendingTx struct {
+	sentAt      time.Time
+	priority    int64
+	prioritized bool // Does the transaction have a priority?
+}
+
+func newCommitTracker(remoteAddr string, expectedTxRate float64, logger logging.Logger) *commitTracker {
+	sampleEvery := uint64(1)
+	if expectedTxRate > commitTrackerMaxSampleRate {
+		sampleEvery = uint64(expectedTxRate/commitTrackerMaxSampleRate) + 1
+	}
+	return &commitTracker{
+		remoteAddr:          remoteAddr,
+		sampleEvery:         sampleEvery,
+		logger:              logger,
+		pending:             make(map[[32]byte]pendingTx),
+		latencies:           newLatencySketch(),
+		latenciesByPriority: make(map[int64]*latencySketch),
+		stop:                make(chan struct{}),
+		stopped:             make(chan struct{}),
+	}
+}
+
+// Start connects and subscribes to new block events, failing if the initial
+// subscription fails. Subsequent connection failures are retried until the
+// tracker is stopped.
+func (ct *commitTracker) Start() error {
+	conn, err := ct.subscribe()
+	if err != nil {
+		return err
+	}
+	ct.logger.Info("Subscribed to new block events for commit latency tracking", "endpoint", ct.remoteAddr)
+	go ct.run(conn)
+	return nil
+}
+
+// Stop waits for up to the given timeout for all tracked transactions to be
+// committed, and then terminates the subscription.
+func (ct *commitTracker) Stop(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for ct.pendingCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	close(ct.stop)
+	ct.mtx.Lock()
+	if ct.conn != nil {
+		_ = ct.conn.Close()
+	}
+	if ct.dropped > 0 {
+		ct.logger.Info("Some transactions were not tracked for commit latency", "dropped", ct.dropped)
+	}
+	ct.mtx.Unlock()
+	<-ct.stopped
+}
+
+// TrackSent records the time at which the given transaction was sent, if it
+// falls within our sample.
+func (ct *commitTracker) TrackSent(tx []byte, sentAt time.Time) {
+	ct.trackSent(tx, pendingTx{sentAt: sentAt})
+}
+
+// TrackSentPrioritized records the time at which the given transaction was
+// sent, along with its priority, if it falls within our sample.
+func (ct *commitTracker) TrackSentPrioritized(tx []byte, priority int64, sentAt time.Time) {
+	ct.trackSent(tx, pendingTx{sentAt: sentAt, priority: priority, prioritized: true})
+}
+
+func (ct *commitTracker) trackSent(tx []byte, ptx pendingTx) {
+	if ct.sent.Add(1)%ct.sampleEvery != 0 {
+		return
+	}
+	ct.mtx.Lock()
+	defer ct.mtx.Unlock()
+	if len(ct.pending) >= commitTrackerMaxPending {
+		ct.dropped++
+		return
+	}
+	ct.pending[sha256.Sum256(tx)] = ptx
+}
+
+// Latencies returns a copy of the send-to-commit latency sketch.
+func (ct *commitTracker) Latencies() *latencySketch {
+	ct.mtx.Lock()
+	defer ct.mtx.Unlock()
+	return ct.latencies.Copy()
+}
+
+// LatenciesByPriority returns copies of the send-to-commit latency sketches
+// for each transaction priority, or nil if no prioritized transactions have
+// been tracked.
+func (ct *commitTracker) LatenciesByPriority() map[int64]*latencySketch {
+	ct.mtx.Lock()
+	defer ct.mtx.Unlock()
+	if len(ct.latenciesByPriority) == 0 {
+		return nil
+	}
+	latencies := make(map[int64]*latencySketch, len(ct.latenciesByPriority))
+	for priority, sketch := range ct.latenciesByPriority {
+		latencies[priority] = sketch.Copy()
+	}
+	return latencies
+}
+
+func (ct *commitTracker) pendingCount() int {
+	ct.mtx.Lock()
+	defer ct.mtx.Unlock()
+	return len(ct.pending)
+}
+
+func (ct *commitTracker) subscribe() (*websocket.Conn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(ct.remoteAddr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s for commit latency tracking: %w", ct.remoteAddr, err)
+	}
+	params, err := json.Marshal(map[string]interface{}{"query": commitTrackerQuery})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(connSendTimeout))
+	if err := conn.WriteJSON(RPCRequest{
+		JSONRPC: "2.0",
+		ID:      commitTrackerSubscribeID,
+		Method:  "subscribe",
+		Params:  json.RawMessage(params),
+	}); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to subscribe to new block events on %s: %w", ct.remoteAddr, err)
+	}
+	ct.mtx.Lock()
+	ct.conn = conn
+	ct.mtx.Unlock()
+	return conn, nil
+}
+
+func (ct *commitTracker) run(conn *websocket.Conn) {
+	defer close(ct.stopped)
+	for {
+		ct.receiveEvents(conn)
+		// keep trying to resubscribe until we're stopped
+		for {
+			select {
+			case <-ct.stop:
+				return
+			case <-time.After(commitTrackerReconnectInterval):
+			}
+			var err error
+			conn, err = ct.subscribe()
+			if err == nil {
+				ct.mtx.Lock()
+				ct.reconnects++
+				ct.mtx.Unlock()
+				ct.logger.Info("Resubscribed to new block events", "endpoint", ct.remoteAddr)
+				break
+			}
+			ct.logger.Debug("Failed to resubscribe to new block events - retrying", "err", err)
+		}
+	}
+}
+
+// receiveEvents handles incoming new block events until the connection fails.
+func (ct *commitTracker) receiveEvents(conn *websocket.Conn) {
+	defer conn.Close()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			select {
+			case <-ct.stop:
+			default:
+				ct.logger.Error("Lost new block event subscription", "endpoint", ct.remoteAddr, "err", err)
+			}
+			return
+		}
+		receivedAt := time.Now()
+		var res RPCResponse
+		if err := json.Unmarshal(data, &res); err != nil {
+			ct.logger.Debug("Failed to parse new block event", "err", err)
+			continue
+		}
+		if res.Error != nil {
+			ct.logger.Error("Error from new block event subscription", "code", res.Error.Code, "message", res.Error.Message)
+			continue
+		}
+		var event newBlockEvent
+		if err := json.Unmarshal(res.Result, &event); err != nil {
+			ct.logger.Debug("Failed to parse new block event result", "err", err)
+			continue
+		}
+		// the initial subscription response is empty
+		if event.Data.Value.Block == nil {
+			continue
+		}
+		ct.trackCommitted(event.Data.Value.Block.Data.Txs, receivedAt)
+	}
+}
+
+func (ct *commitTracker) trackCommitted(txs []string, committedAt time.Time) {
+	ct.mtx.Lock()
+	defer ct.mtx.Unlock()
+	for _, txBase64 := range txs {
+		tx, err := base64.StdEncoding.DecodeString(txBase64)
+		if err != nil {
+			continue
+		}
+		hash := sha256.Sum256(tx)
+		if ptx, ok := ct.pending[hash]; ok {
+			latency := committedAt.Sub(ptx.sentAt)
+			ct.latencies.Add(latency)
+			if ptx.prioritized {
+				ct.trackPriorityLatency(ptx.priority, latency)
+			}
+			delete(ct.pending, hash)
+		}
+	}
+	// stop tracking transactions that are unlikely to ever be committed
+	for hash, ptx := range ct.pending {
+		if committedAt.Sub(ptx.sentAt) > commitTrackerStaleTimeout {
+			delete(ct.pending, hash)
+		}
+	}
+}
+
+// Must be called with the mutex held.
+func (ct *commitTracker) trackPriorityLatency(priority int64, latency time.Duration) {
+	sketch, exists := ct.latenciesByPriority[priority]
+	if !exists {
+		if len(ct.latenciesByPriority) >= commitTrackerMaxPriorityBands {
+			if !ct.tooManyPriorities {
+				ct.logger.Warn("Too many distinct transaction priorities - only tracking overall commit latency for new priorities", "max", commitTrackerMaxPriorityBands)
+				ct.tooManyPriorities = true
+			}
+			return
+		}
+		sketch = newLatencySketch()
+		ct.latenciesByPriority[priority] = sketch
+	}
+	sketch.Add(latency)
+}
+
+// newBlockEvent corresponds to the subset of the Tendermint Core v0.34.x
+// NewBlock event that we need for commit latency tracking.
+type newBlockEvent struct {
+	Query string `json:"query"`
+	Data  struct {
+		Type  string `json:"type"`
+		Value struct {
+			Block *struct {
+				Data struct {
+					Txs []string `json:"txs"`
+				} `json:"data"`
+			} `json:"block"`
+		} `json:"value"`
+	} `json:"data"`
+}
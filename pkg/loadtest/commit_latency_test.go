@@ -0,0 +1,135 @@
+package loadtest_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitLatencyTracking(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	svr.StartBlocks(200 * time.Millisecond)
+	cfg := mockTestConfig(svr.URL())
+	cfg.TrackCommitLatency = true
+	cfg.DrainTimeout = seconds(2)
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	require.NoError(t, tg.Wait())
+
+	stats := tg.CommitLatencyStats()
+	require.NotNil(t, stats)
+	assert.Equal(t, cfg.Count, stats.Count)
+	assert.Greater(t, stats.P50, 0.0)
+	assert.LessOrEqual(t, stats.P50, stats.P95)
+	assert.LessOrEqual(t, stats.P95, stats.P99)
+	// transactions are committed within a block interval (with some leeway)
+	assert.Less(t, stats.Max, 0.5)
+}
+
+func TestCommitLatencyTrackingSurvivesReconnects(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	svr.StartBlocks(100 * time.Millisecond)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(4)
+	cfg.Rate = 5
+	cfg.Count = -1
+	cfg.TrackCommitLatency = true
+	cfg.DrainTimeout = seconds(2)
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	time.AfterFunc(1500*time.Millisecond, svr.DropSubscriptions)
+	require.NoError(t, tg.Wait())
+
+	stats := tg.CommitLatencyStats()
+	require.NotNil(t, stats)
+	// batches sent after resubscribing must still be tracked
+	assert.GreaterOrEqual(t, stats.Count, 10)
+}
+
+// testPrioritizedClient cycles through three priority bands (1, 2 and 3).
+type testPrioritizedClient struct {
+	next int
+}
+
+type testPrioritizedClientFactory struct{}
+
+var _ loadtest.PrioritizedClient = (*testPrioritizedClient)(nil)
+
+func init() {
+	if err := loadtest.RegisterClientFactory("test-prioritized", testPrioritizedClientFactory{}); err != nil {
+		panic(err)
+	}
+}
+
+func (testPrioritizedClientFactory) ValidateConfig(_ loadtest.Config) error { return nil }
+
+func (testPrioritizedClientFactory) NewClient(_ loadtest.Config) (loadtest.Client, error) {
+	return &testPrioritizedClient{}, nil
+}
+
+func (c *testPrioritizedClient) GenerateTx() ([]byte, error) {
+	tx, _, err := c.GeneratePrioritizedTx()
+	return tx, err
+}
+
+func (c *testPrioritizedClient) GeneratePrioritizedTx() ([]byte, int64, error) {
+	c.next++
+	priority := int64(c.next%3 + 1)
+	return []byte(fmt.Sprintf("%p-%d=fee%d", c, c.next, priority)), priority, nil
+}
+
+func TestCommitLatencyByPriority(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	svr.StartBlocks(200 * time.Millisecond)
+	cfg := mockTestConfig(svr.URL())
+	cfg.ClientFactory = "test-prioritized"
+	cfg.Count = 30
+	cfg.Rate = 15
+	cfg.TrackCommitLatency = true
+	cfg.DrainTimeout = seconds(2)
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	require.NoError(t, tg.Wait())
+
+	bands := tg.CommitLatencyByPriority()
+	require.Len(t, bands, 3)
+	for i, band := range bands {
+		assert.Equal(t, int64(i+1), band.Priority)
+		assert.Equal(t, 10, band.Count)
+		assert.Greater(t, band.P50, 0.0)
+		assert.LessOrEqual(t, band.P50, band.Max)
+	}
+
+	statsFile := filepath.Join(t.TempDir(), "stats.csv")
+	require.NoError(t, tg.WriteAggregateStats(statsFile))
+	f, err := os.Open(statsFile)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	params := make(map[string]string)
+	for _, record := range records {
+		params[record[0]] = record[1]
+	}
+	assert.Equal(t, "30", params["commit_latency_samples"])
+	for _, priority := range []int{1, 2, 3} {
+		prefix := fmt.Sprintf("commit_latency_priority_%d", priority)
+		assert.Equal(t, "10", params[prefix+"_samples"])
+		for _, suffix := range []string{"_p50", "_p90", "_p95", "_p99", "_max"} {
+			assert.Contains(t, params, prefix+suffix)
+		}
+	}
+}
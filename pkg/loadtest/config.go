@@ -1,66 +1,258 @@
 package loadtest
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	SelectSuppliedEndpoints      = "supplied"       // Select only the supplied endpoint(s) for load testing (the default).
+	SelectDiscoveredEndpoints    = "discovered"     // Select newly discovered endpoints only (excluding supplied endpoints).
+	SelectAnyEndpoints           = "any"            // Select from any of supplied and/or discovered endpoints.
+	SelectLowestLatencyEndpoints = "lowest-latency" // Select from any of supplied and/or discovered endpoints, sending only to the PreferredEndpointCount with the lowest latency.
 )
 
 const (
-	SelectSuppliedEndpoints   = "supplied"   // Select only the supplied endpoint(s) for load testing (the default).
-	SelectDiscoveredEndpoints = "discovered" // Select newly discovered endpoints only (excluding supplied endpoints).
-	SelectAnyEndpoints        = "any"        // Select from any of supplied and/or discovered endpoints.
+	// countBoundedRunTimeFactor is the multiple of its expected run time for
+	// which a count-bounded load test without a MaxRunTime is allowed to run
+	// before it's given up on (e.g. by a worker waiting for the coordinator).
+	countBoundedRunTimeFactor = 10
+	// countBoundedMaxRunTime is how long a count-bounded load test without a
+	// MaxRunTime could run for if its expected run time is unknown (e.g. for
+	// a coordinator splitting a total rate amongst its workers).
+	countBoundedMaxRunTime = 24 * time.Hour
 )
 
 var validEndpointSelectMethods = map[string]interface{}{
-	SelectSuppliedEndpoints:   nil,
-	SelectDiscoveredEndpoints: nil,
-	SelectAnyEndpoints:        nil,
+	SelectSuppliedEndpoints:      nil,
+	SelectDiscoveredEndpoints:    nil,
+	SelectAnyEndpoints:           nil,
+	SelectLowestLatencyEndpoints: nil,
 }
 
 // Config represents the configuration for a single client (i.e. standalone or
 // worker).
 type Config struct {
-	ClientFactory        string   `json:"client_factory"`         // Which client factory should we use for load testing?
-	Connections          int      `json:"connections"`            // The number of WebSockets connections to make to each target endpoint.
-	Time                 int      `json:"time"`                   // The total time, in seconds, for which to handle the load test.
-	SendPeriod           int      `json:"send_period"`            // The period (in seconds) at which to send batches of transactions.
-	Rate                 int      `json:"rate"`                   // The number of transactions to generate, per send period.
-	Size                 int      `json:"size"`                   // The desired size of each generated transaction, in bytes.
-	Count                int      `json:"count"`                  // The maximum number of transactions to send. Set to -1 for unlimited.
-	BroadcastTxMethod    string   `json:"broadcast_tx_method"`    // The broadcast_tx method to use (can be "sync", "async" or "commit").
-	Endpoints            []string `json:"endpoints"`              // A list of the Tendermint node endpoints to which to connect for this load test.
-	EndpointSelectMethod string   `json:"endpoint_select_method"` // The method by which to select endpoints for load testing.
-	ExpectPeers          int      `json:"expect_peers"`           // The minimum number of peers to expect before starting a load test. Set to 0 by default (no minimum).
-	MaxEndpoints         int      `json:"max_endpoints"`          // The maximum number of endpoints to use for load testing. Set to 0 by default (no maximum).
-	MinConnectivity      int      `json:"min_connectivity"`       // The minimum number of peers to which each peer must be connected before starting the load test. Set to 0 by default (no minimum).
-	PeerConnectTimeout   int      `json:"peer_connect_timeout"`   // The maximum time to wait (in seconds) for all peers to connect, if ExpectPeers > 0.
-	StatsOutputFile      string   `json:"stats_output_file"`      // Where to store the final aggregate statistics file (in CSV format).
-	NoTrapInterrupts     bool     `json:"no_trap_interrupts"`     // Should we avoid trapping Ctrl+Break? Only relevant for standalone execution mode.
+	RunID                    string   `json:"run_id"`                     // An identifier for this load test run. Generated automatically if not supplied.
+	ClientFactory            string   `json:"client_factory"`             // Which client factory should we use for load testing?
+	Connections              int      `json:"connections"`                // The number of WebSockets connections to make to each target endpoint.
+	Time                     Duration `json:"time"`                       // The total time for which to handle the load test. Set to 0 (or -1) for no time limit, in which case the load test ends once every connection has sent Count transactions.
+	MaxRunTime               Duration `json:"max_run_time"`               // A safety cap on the duration of a load test with no time limit, after which it ends even if not all transactions have been sent. 0 means no cap.
+	SendPeriod               Duration `json:"send_period"`                // The period at which to send batches of transactions. May be less than a second.
+	Rate                     float64  `json:"rate"`                       // The number of transactions to generate, per send period. May be fractional (e.g. 0.1 to send one transaction every 10 send periods).
+	Size                     int      `json:"size"`                       // The desired size of each generated transaction, in bytes.
+	Count                    int      `json:"count"`                      // The maximum number of transactions to send. Set to -1 for unlimited.
+	BroadcastTxMethod        string   `json:"broadcast_tx_method"`        // The broadcast_tx method to use (can be "sync", "async", "commit" or any registered custom method).
+	Endpoints                []string `json:"endpoints"`                  // A list of the Tendermint node endpoints to which to connect for this load test.
+	EndpointsFile            string   `json:"endpoints_file,omitempty"`   // A file listing further endpoints, one per line ("-" for standard input), merged into Endpoints by LoadEndpointsFile.
+	StrictEndpoints          bool     `json:"strict_endpoints"`           // Fail validation for endpoints without a path (e.g. "ws://host:26657"), rather than appending the default "/websocket" path to them.
+	EndpointSelectMethod     string   `json:"endpoint_select_method"`     // The method by which to select endpoints for load testing.
+	ExpectPeers              int      `json:"expect_peers"`               // The minimum number of peers to expect before starting a load test. Set to 0 by default (no minimum).
+	MaxEndpoints             int      `json:"max_endpoints"`              // The maximum number of endpoints to use for load testing. Set to 0 by default (no maximum). If more are discovered, this many are sampled at random.
+	Seed                     int64    `json:"seed"`                       // The seed with which to sample endpoints if more than MaxEndpoints are discovered, for reproducibility. 0 means a random seed.
+	MinConnectivity          int      `json:"min_connectivity"`           // The minimum number of peers to which each peer must be connected before starting the load test. Set to 0 by default (no minimum).
+	PeerConnectTimeout       Duration `json:"peer_connect_timeout"`       // The maximum time to wait for all peers to connect, if ExpectPeers > 0.
+	PeerPollInterval         Duration `json:"peer_poll_interval"`         // How often to query the peers while waiting for them to connect, if ExpectPeers > 0. 0 means every second.
+	DiscoveryIncludePatterns []string `json:"discovery_include_patterns"` // If any, discovered peers must have a moniker or listen address matching at least one of these regular expressions.
+	DiscoveryExcludePatterns []string `json:"discovery_exclude_patterns"` // Discovered peers with a moniker or listen address matching any of these regular expressions are left out, even if included.
+	DiscoveryChainID         string   `json:"discovery_chain_id"`         // If set, discovered peers whose nodes report a different chain ID (via the status RPC API) are left out.
+	RediscoveryInterval      Duration `json:"rediscovery_interval"`       // How often to crawl the network again for endpoints that joined during the load test (if endpoints are discovered), adding those that are healthy. 0 disables re-discovery.
+	MaxRediscoveredEndpoints int      `json:"max_rediscovered_endpoints"` // The maximum number of endpoints that re-discovery may add. 0 means no limit.
+	PreferredEndpointCount   int      `json:"preferred_endpoint_count"`   // The number of endpoints with the lowest latency to which to send, if endpoints are selected by latency. 0 means 1.
+	DiscoverySeeds           []string `json:"discovery_seeds,omitempty"`  // The endpoints originally supplied for peer discovery, which re-discovery must not add if only discovered endpoints are selected. Set automatically.
+	ResolvedEndpoints        []string `json:"resolved_endpoints"`         // The endpoints (e.g. "dns+ws://validators.ns.svc:26657/websocket") from which Endpoints were expanded by an EndpointResolver, which re-discovery resolves again. Set automatically.
+	HealthCheck              bool     `json:"health_check"`               // Check the health of each endpoint (via the health and status RPC APIs) before the load test, leaving out those that fail.
+	HealthCheckTimeout       Duration `json:"health_check_timeout"`       // How long each endpoint has to pass its health check. 0 means the default of 5 seconds.
+	MinHealthyEndpoints      int      `json:"min_healthy_endpoints"`      // The minimum number of endpoints that must pass their health checks for the load test to go ahead. 0 means at least one.
+	EndpointFailureThreshold int      `json:"endpoint_failure_threshold"` // The number of consecutive failures (error responses, or failures to send or connect) after which an endpoint is blacklisted and its load redistributed. 0 means endpoints are never blacklisted.
+	EndpointRecoveryInterval Duration `json:"endpoint_recovery_interval"` // How often to probe the health of blacklisted endpoints, resuming sending to those that recover. 0 means blacklisted endpoints are never probed.
+	StatsOutputFile          string   `json:"stats_output_file"`          // Where to store the final aggregate statistics file (in CSV format). May be a URL with a scheme registered with RegisterStatsUploader (e.g. s3:// or gs://), to which the file is uploaded.
+	StatsOutputFormat        string   `json:"stats_output_format"`        // The format of the statistics output file ("csv" or "json").
+	StatsAppend              bool     `json:"stats_append"`               // Append a single row per run to the statistics output file (in CSV format), rather than overwriting it.
+	StatsOverwrite           bool     `json:"stats_overwrite"`            // Overwrite existing statistics, timeseries and latency sample files, rather than refusing to run the load test.
+	StatsFlushInterval       Duration `json:"stats_flush_interval"`       // How often to rewrite the statistics output file with the statistics gathered so far while the load test is underway. Set to 0 to only write it once the load test is over.
+	StatsCSVHeader           bool     `json:"stats_csv_header"`           // Write a machine-readable header row and normalized unit names to the statistics output file (in CSV format).
+	StatsCSVDelimiter        rune     `json:"stats_csv_delimiter"`        // The field delimiter of the statistics output file (in CSV format). Defaults to a comma if zero.
+	RawStatsOutputFile       string   `json:"raw_stats_output_file"`      // Where to store per-interval timeseries statistics (in CSV format), if at all. May be a URL with a scheme registered with RegisterStatsUploader (e.g. s3:// or gs://), to which the file is uploaded.
+	RequireStatsUpload       bool     `json:"require_stats_upload"`       // Fail the load test if statistics cannot be uploaded to an s3:// or gs:// URL, rather than just logging a warning.
+	RawStatsInterval         Duration `json:"raw_stats_interval"`         // How often to sample timeseries statistics. Must be at least a second.
+	RateWindow               Duration `json:"rate_window"`                // The window over which to measure transaction rates when computing the peak and minimum rates. Set to 0 to disable.
+	ProgressInterval         Duration `json:"progress_interval"`          // How often to report progress during the load test. Set to 0 to disable progress reporting.
+	StatsPushInterval        Duration `json:"stats_push_interval"`        // How often workers push the statistics they gathered since their previous push to the coordinator. 0 means every 3 seconds.
+	ProgressMode             string   `json:"progress_mode"`              // How the CLI displays progress in standalone mode ("bar", "log" or "none"). The CLI's --progress flag defaults to "bar", whereas an empty mode means "log". Progress is only logged (as progress records) when the package is embedded.
+	NoTrapInterrupts         bool     `json:"no_trap_interrupts"`         // Should we avoid trapping Ctrl+Break? Only relevant for standalone execution mode.
+	DrainTimeout             Duration `json:"drain_timeout"`              // The maximum time to keep reading in-flight responses after the last send. Set to 0 to disable draining.
+	ErrorLogInterval         Duration `json:"error_log_interval"`         // How often each category of error that can recur with every transaction (e.g. rejections, or failures to send to an endpoint that's down) is logged, at most. 0 means the default of 10 seconds, and a negative interval logs every error.
+	MinSuccessRatio          float64  `json:"min_success_ratio"`          // The minimum fraction of transactions that must succeed for a standalone load test to pass. Set to 0 by default (no minimum).
+	MaxRateDeviation         float64  `json:"max_rate_deviation"`         // Warn if the achieved transaction rate falls short of the target rate by more than this percentage. Set to 0 to disable the warning.
+	PrintSummaryJSON         bool     `json:"print_summary_json"`         // Print a single-line JSON summary of the load test to stdout on completion.
+	DryRun                   bool     `json:"dry_run"`                    // Validate the configuration, the client factory and the endpoints (and, with a coordinator, the workers' registration), and print the load that would be generated, without sending any transactions.
+	LatencySampleFile        string   `json:"latency_sample_file"`        // Where to store a uniform random sample of raw broadcast latencies (in CSV format), if at all.
+	LatencySampleRate        float64  `json:"latency_sample_rate"`        // The fraction of broadcast latencies to consider for inclusion in the raw latency sample.
+	LatencySampleCap         int      `json:"latency_sample_cap"`         // The maximum number of raw broadcast latencies to retain, which bounds memory usage.
+	TraceSampleRate          float64  `json:"trace_sample_rate"`          // The fraction of transactions whose details to log at the trace level (0 means the default of 1, i.e. all of them).
+
+	LogLevel      string `json:"log_level,omitempty"`       // The minimum level of the log entries to write ("trace", "debug", "info", "warn" or "error"). Workers use the coordinator's unless given their own. Empty leaves it as it is.
+	LogFormat     string `json:"log_format,omitempty"`      // The format in which to write log entries ("text" or "json"). Workers use the coordinator's unless given their own. Empty leaves it as it is.
+	LogFile       string `json:"log_file,omitempty"`        // A file to which to write log entries as well as stderr (unless LogQuiet is set). Only applies to this process, not to the coordinator's workers.
+	LogMaxSizeMB  int    `json:"log_max_size_mb,omitempty"` // The size (in megabytes) beyond which to rotate the LogFile. 0 means it's never rotated.
+	LogMaxBackups int    `json:"log_max_backups,omitempty"` // The number of rotated LogFiles to keep.
+	LogQuiet      bool   `json:"log_quiet,omitempty"`       // Only write log entries to the LogFile, and not to stderr.
+
+	MempoolPauseThreshold  int      `json:"mempool_pause_threshold"`  // Pause sending to an endpoint while its mempool holds more than this many transactions. Set to 0 by default (no mempool monitoring).
+	MempoolResumeThreshold int      `json:"mempool_resume_threshold"` // Resume sending to a paused endpoint once its mempool holds fewer than this many transactions. Defaults to half the pause threshold.
+	MempoolPollInterval    Duration `json:"mempool_poll_interval"`    // How often to poll endpoints' mempool sizes.
+
+	TrackCommitLatency bool `json:"track_commit_latency"` // Should we subscribe to new blocks to measure send-to-commit latency?
+
+	ChainStats        bool     `json:"chain_stats"`         // Should we query block-level statistics from the chain once the load test completes?
+	ChainStatsTimeout Duration `json:"chain_stats_timeout"` // The maximum time to spend querying block-level statistics.
+
+	WorkerMetricsAddr       string    `json:"worker_metrics_addr"`                 // The "host:port" at which each worker (or the standalone load test) should serve its own Prometheus metrics, if at all.
+	BroadcastLatencyBuckets []float64 `json:"broadcast_latency_buckets,omitempty"` // The bucket boundaries (in seconds) for broadcast latency histograms. Defaults to exponential buckets from 1ms to ~16s.
+
+	StatsdAddr   string `json:"statsd_addr"`   // The "host:port" of a StatsD server (e.g. the Datadog agent) to which to send metrics over UDP, if at all.
+	StatsdPrefix string `json:"statsd_prefix"` // The prefix to prepend to all StatsD metric names.
+
+	InfluxDBURL    string `json:"influxdb_url"`    // The URL of an InfluxDB v2 server to which to export per-interval statistics, if at all.
+	InfluxDBToken  string `json:"influxdb_token"`  // The API token with which to authenticate to InfluxDB.
+	InfluxDBOrg    string `json:"influxdb_org"`    // The InfluxDB organization that owns the bucket.
+	InfluxDBBucket string `json:"influxdb_bucket"` // The InfluxDB bucket to which to write statistics.
+
+	OTLPEndpoint       string   `json:"otlp_endpoint"`        // The URL of an OpenTelemetry collector to which to export metrics using OTLP/HTTP, if at all.
+	OTLPExportInterval Duration `json:"otlp_export_interval"` // How often to export metrics to the OpenTelemetry collector.
+
+	ResultWebhookURL    string `json:"result_webhook_url"` // The URL to which to post the final results as JSON once the load test completes or fails, if at all.
+	ResultWebhookSecret string `json:"-"`                  // The shared secret from which to derive the HMAC signature of result webhook requests, if any. Never serialized, so that it doesn't leak into reports.
+
+	RPCVersion string `json:"rpc_version"` // The RPC version of the endpoints ("auto", "legacy" or "v1"). Detected per endpoint by default.
+
+	EndpointRateLimits       map[string]float64 `json:"endpoint_rate_limits,omitempty"` // The maximum rate (tx/sec, across all connections) at which to send transactions to specific endpoints, keyed by endpoint address.
+	IgnoreRateLimitShortfall bool               `json:"ignore_rate_limit_shortfall"`    // Allow endpoint rate limits to cap the overall rate below the requested rate.
+	EndpointWeights          map[string]float64 `json:"endpoint_weights,omitempty"`     // The relative weights of specific endpoints, keyed by endpoint address, in proportion to which connections (and, with endpoint rate limits, the rate) are divided amongst the endpoints. Endpoints without a weight have a weight of 1.
+	EndpointNames            map[string]string  `json:"endpoint_names,omitempty"`       // The aliases of specific endpoints, keyed by endpoint address, with which they're labelled instead of their addresses in stats, metrics and logs. Must be unique.
+
+	MaxTotalTxs       int64 `json:"max_total_txs"`       // Refuse to run a load test that would send more than this many transactions in total (across all workers). Set to 0 by default (no limit).
+	MaxTotalBytes     int64 `json:"max_total_bytes"`     // Refuse to run a load test that would send more than this many transaction bytes in total (across all workers). Set to 0 by default (no limit).
+	ConfirmTotalTxs   int64 `json:"confirm_total_txs"`   // Ask for confirmation before running a load test that would send more than this many transactions in total. Set to 0 by default (never ask).
+	ConfirmTotalBytes int64 `json:"confirm_total_bytes"` // Ask for confirmation before running a load test that would send more than this many transaction bytes in total. Set to 0 by default (never ask).
+	AssumeYes         bool  `json:"assume_yes"`          // Skip the confirmation (e.g. in non-interactive runs), going ahead with the load test.
+
+	PregenerateTxs           bool `json:"pregenerate_txs"`             // Generate each connection's transactions (and the parameters of the requests submitting them) before the load test starts, so that sending only involves writing them.
+	PregenerateCount         int  `json:"pregenerate_count"`           // The number of transactions to pre-generate per connection, after which the rest are generated as they're sent. 0 means Count.
+	PregenerateMemoryLimitMB int  `json:"pregenerate_memory_limit_mb"` // Refuse to pre-generate transactions estimated to take up more than this much memory (in megabytes) in total. 0 means the default of 1024.
+
+	StrictFeasibility bool `json:"strict_feasibility"` // Fail validation, rather than just warning, if the rate looks unachievable given the send period and number of connections.
+	SkipCalibration   bool `json:"skip_calibration"`   // Skip measuring how long it takes to generate transactions in a dry run (e.g. in CI), assuming a rough default instead.
+
+	Runs []json.RawMessage `json:"runs,omitempty"` // The runs to execute back to back (coordinator only), each given as a JSON object of overrides of this configuration. Set to nil by default (a single run).
 }
 
 // CoordinatorConfig is the configuration options specific to a coordinator node.
 type CoordinatorConfig struct {
-	BindAddr             string `json:"bind_addr"`       // The "host:port" to which to bind the coordinator node to listen for incoming workers.
-	ExpectWorkers        int    `json:"expect_workers"`  // The number of workers to expect before starting the load test.
-	WorkerConnectTimeout int    `json:"connect_timeout"` // The number of seconds to wait for all workers to connect.
-	ShutdownWait         int    `json:"shutdown_wait"`   // The number of seconds to wait at shutdown (while keeping the HTTP server running - primarily to allow Prometheus to keep polling).
-	LoadTestID           int    `json:"load_test_id"`    // An integer greater than 0 that will be exposed via a Prometheus gauge while the load test is underway.
+	BindAddr             string   `json:"bind_addr"`            // The "host:port" to which to bind the coordinator node to listen for incoming workers.
+	ExpectWorkers        int      `json:"expect_workers"`       // The number of workers to expect before starting the load test.
+	MinWorkers           int      `json:"min_workers"`          // The minimum number of workers with which to start the load test if ExpectWorkers haven't connected within StartGracePeriod of the minimum connecting. 0 means ExpectWorkers are required.
+	StartGracePeriod     Duration `json:"start_grace_period"`   // How long to keep waiting for ExpectWorkers once MinWorkers have connected, before starting the load test with the workers that have.
+	MaxWorkers           int      `json:"max_workers"`          // The maximum number of workers that may take part in the load test, including those that join once it's underway. 0 means unlimited.
+	WorkerConnectTimeout Duration `json:"connect_timeout"`      // How long to wait for all workers to connect.
+	ShutdownWait         Duration `json:"shutdown_wait"`        // How long to wait at shutdown (while keeping the HTTP server running - primarily to allow Prometheus to keep polling).
+	LoadTestID           int      `json:"load_test_id"`         // An integer greater than 0 that will be exposed via a Prometheus gauge while the load test is underway.
+	ManualStart          bool     `json:"manual_start"`         // Only start the load test (once at least the minimum number of workers have connected) when requested via the control API.
+	AuthToken            string   `json:"-"`                    // A shared token that workers must present in order to register. If empty, any worker may register.
+	TLSCertFile          string   `json:"tls_cert_file"`        // The PEM-encoded certificate (chain) with which to serve TLS (wss/https), if TLS is enabled.
+	TLSKeyFile           string   `json:"tls_key_file"`         // The PEM-encoded private key of the TLS certificate. TLS is enabled if both this and TLSCertFile are set.
+	StartStagger         Duration `json:"start_stagger"`        // The delay between successive workers' starts, so that they don't all send their first transactions at the same instant. 0 means all workers start at once.
+	StartStaggerRandom   bool     `json:"start_stagger_random"` // Start each worker at a random offset within the stagger window (StartStagger times one less than the number of workers), instead of at successive multiples of StartStagger.
+
+	HeartbeatInterval       Duration `json:"heartbeat_interval"`         // How often workers send heartbeats to the coordinator. 0 means the default of 1 second.
+	HeartbeatTimeout        Duration `json:"heartbeat_timeout"`          // How long the coordinator waits to hear from a worker before considering it failed. 0 means the default of 10 seconds.
+	ContinueOnWorkerFailure bool     `json:"continue_on_worker_failure"` // Carry on with the load test without workers that fail once it's underway, instead of failing the load test.
+
+	ShutdownWorkersOnCompletion bool `json:"shutdown_workers_on_completion"` // Tell the workers still connected to shut down (and exit cleanly) once the load test completes or is cancelled, rather than failing or cancelling them.
+
+	StateFile          string   `json:"state_file"`          // A file to which to periodically checkpoint the load test underway, so that it can be resumed if the coordinator crashes. Removed once the load test is over.
+	CheckpointInterval Duration `json:"checkpoint_interval"` // How often to checkpoint the load test to StateFile. 0 means the default of 5 seconds.
+	Resume             bool     `json:"resume"`              // Resume the load test checkpointed to StateFile (if any) on startup, waiting for its workers to reconnect, rather than starting afresh.
+
+	ShardEndpoints     bool `json:"shard_endpoints"`     // Partition the endpoints amongst the workers (round-robin, in order of worker ID), instead of having every worker connect to all of them. Workers with endpoint overrides keep their own endpoints.
+	RedistributeShards bool `json:"redistribute_shards"` // Give the endpoints of workers that fail during the load test to workers that join it later. Requires ShardEndpoints.
+
+	EnableCompression bool `json:"enable_compression"` // Compress the messages exchanged with workers that ask for it (with permessage-deflate), which mostly helps with large configurations (e.g. thousands of endpoints).
+
+	TotalRate float64 `json:"total_rate"` // The overall transaction rate (tx/sec) to split evenly amongst the workers taking part in the load test (and across their connections), instead of configuring each connection's rate. Mutually exclusive with the load testing configuration's rate. 0 means each connection's rate is configured.
+
+	WorkerOverrides map[string]WorkerOverride `json:"worker_overrides,omitempty"` // Overrides of the load testing configuration given to particular workers, keyed by worker ID or by a selector of the labels workers register with (e.g. "region=eu,instance=c5"). A worker's ID takes precedence over selectors, and of the selectors it matches, the one with the most labels (then the first in order) applies.
+}
+
+// WorkerOverride is a partial load testing configuration that overrides the
+// configuration given to particular workers. Fields that aren't set are not
+// overridden.
+type WorkerOverride struct {
+	Endpoints   []string `json:"endpoints,omitempty"`   // The endpoints to which the worker connects (each optionally suffixed with |maxrate=N), instead of the configured ones.
+	Rate        float64  `json:"rate,omitempty"`        // The number of transactions the worker generates each send period on each connection.
+	Connections int      `json:"connections,omitempty"` // The number of connections the worker opens to each endpoint.
+}
+
+// apply returns the given configuration with the override merged over it.
+func (o WorkerOverride) apply(cfg Config) Config {
+	if len(o.Endpoints) > 0 {
+		cfg.Endpoints = append([]string(nil), o.Endpoints...)
+		// the configured endpoints' rate limits, weights and aliases don't
+		// apply to the overriding endpoints
+		cfg.EndpointRateLimits = nil
+		cfg.EndpointWeights = nil
+		cfg.EndpointNames = nil
+	}
+	if o.Rate > 0 {
+		cfg.Rate = o.Rate
+	}
+	if o.Connections > 0 {
+		cfg.Connections = o.Connections
+	}
+	return cfg
 }
 
 // WorkerConfig is the configuration options specific to a worker node.
 type WorkerConfig struct {
-	ID                  string `json:"id"`              // A unique ID for this worker instance. Will show up in the metrics reported by the coordinator for this worker.
-	CoordAddr           string `json:"coord_addr"`      // The address at which to find the coordinator node.
-	CoordConnectTimeout int    `json:"connect_timeout"` // The maximum amount of time, in seconds, to allow for the coordinator to become available.
+	ID                  string            `json:"id"`                 // A unique ID for this worker instance. Will show up in the metrics reported by the coordinator for this worker. Defaults to the host name with a short random suffix.
+	Labels              map[string]string `json:"labels"`             // Labels (e.g. region or instance type) with which to identify this worker in the coordinator's logs, metrics and statistics.
+	CoordAddr           string            `json:"coord_addr"`         // The address at which to find the coordinator node.
+	CoordConnectTimeout Duration          `json:"connect_timeout"`    // The maximum amount of time to allow for the coordinator to become available, across all connection attempts.
+	CoordRetryInterval  Duration          `json:"retry_interval"`     // The initial interval between attempts to connect to the coordinator, which backs off (with jitter) with each failed attempt. 0 means 1 second.
+	MaxReconnectTime    Duration          `json:"max_reconnect_time"` // The maximum amount of time for which to keep trying to reconnect to the coordinator if the connection is lost during the load test. 0 means the worker aborts immediately.
+	MetricsAddr         string            `json:"metrics_addr"`       // The "host:port" at which to serve this worker's Prometheus metrics, overriding the coordinator's WorkerMetricsAddr.
+	AuthToken           string            `json:"-"`                  // The shared token to present to the coordinator when registering, if it requires one.
+	TLSCAFile           string            `json:"tls_ca_file"`        // A PEM-encoded CA certificate bundle with which to verify the coordinator's TLS certificate, instead of the system's.
+	TLSInsecure         bool              `json:"tls_insecure"`       // Skip verification of the coordinator's TLS certificate (only for testing).
+	ProtocolVersion     string            `json:"-"`                  // Overrides the protocol version the worker claims to speak (only for testing). Defaults to WorkerProtocolVersion.
+	ClientFactories     []string          `json:"-"`                  // Overrides the client factories the worker claims to support (only for testing). Defaults to all of the registered client factories.
+	LogLevel            string            `json:"log_level"`          // The minimum level of the log entries to write ("trace", "debug", "info", "warn" or "error"), overriding the coordinator's LogLevel.
+	LogFormat           string            `json:"log_format"`         // The format in which to write log entries ("text" or "json"), overriding the coordinator's LogFormat.
+	LogFile             string            `json:"log_file"`           // A file to which to write log entries as well as stderr (unless LogQuiet is set).
+	LogMaxSizeMB        int               `json:"log_max_size_mb"`    // The size (in megabytes) beyond which to rotate the LogFile. 0 means it's never rotated.
+	LogMaxBackups       int               `json:"log_max_backups"`    // The number of rotated LogFiles to keep.
+	LogQuiet            bool              `json:"log_quiet"`          // Only write log entries to the LogFile, and not to stderr.
+	JSONMessages        bool              `json:"json_messages"`      // Exchange messages with the coordinator as JSON, even if it supports a more compact binary encoding (e.g. for debugging).
+	EnableCompression   bool              `json:"enable_compression"` // Ask the coordinator to compress the messages exchanged with it (with permessage-deflate), which it does if it has compression enabled too.
 }
 
-var validBroadcastTxMethods = map[string]interface{}{
-	"async":  nil,
-	"sync":   nil,
-	"commit": nil,
+func (c Config) Validate() error {
+	return c.validate(false)
 }
 
-func (c Config) Validate() error {
+// validate checks the configuration. If the coordinator splits a total rate
+// amongst its workers, the configuration mustn't have a rate of its own.
+func (c Config) validate(totalRate bool) error {
 	if len(c.ClientFactory) == 0 {
 		return fmt.Errorf("client factory name must be specified")
 	}
@@ -75,32 +267,94 @@ func (c Config) Validate() error {
 	if c.Connections < 1 {
 		return fmt.Errorf("expected connections to be >= 1, but was %d", c.Connections)
 	}
-	if c.Time < 1 {
-		return fmt.Errorf("expected load test time to be >= 1 second, but was %d", c.Time)
+	if c.countBounded() {
+		if c.Count < 1 {
+			return fmt.Errorf("expected a max transaction count when the load test time is unbounded, but the count is unbounded too")
+		}
+	} else if c.Time < Duration(time.Second) {
+		return fmt.Errorf("expected load test time to be >= 1s, or 0 for no time limit, but was %s", c.Time)
 	}
-	if c.SendPeriod < 1 {
-		return fmt.Errorf("expected transaction send period to be >= 1 second, but was %d", c.SendPeriod)
+	if c.MaxRunTime < 0 {
+		return fmt.Errorf("expected max run time to be 0 (the default) or greater, but was %s", c.MaxRunTime)
 	}
-	if c.Rate < 1 {
-		return fmt.Errorf("expected transaction rate to be >= 1, but was %d", c.Rate)
+	if c.SendPeriod < Duration(minSendPeriod) {
+		return fmt.Errorf("expected transaction send period to be >= %s, but was %s", minSendPeriod, c.SendPeriod)
+	}
+	if limit := c.timeLimit(); limit > 0 && c.SendPeriod > Duration(limit) {
+		return fmt.Errorf("expected transaction send period (%s) to be no longer than the load test time (%s)", c.SendPeriod, limit)
+	}
+	if totalRate {
+		if c.Rate != 0 {
+			return fmt.Errorf("rate and total-rate are mutually exclusive, but got a rate of %v", c.Rate)
+		}
+	} else if !(c.Rate > 0) {
+		return fmt.Errorf("expected transaction rate to be > 0, but was %v", c.Rate)
 	}
 	if c.Count < 1 && c.Count != -1 {
 		return fmt.Errorf("expected max transaction count to either be -1 or >= 1, but was %d", c.Count)
 	}
-	if _, ok := validBroadcastTxMethods[c.BroadcastTxMethod]; !ok {
-		return fmt.Errorf("expected broadcast_tx method to be one of \"sync\", \"async\" or \"commit\", but was %s", c.BroadcastTxMethod)
+	if c.MaxTotalTxs < 0 || c.MaxTotalBytes < 0 || c.ConfirmTotalTxs < 0 || c.ConfirmTotalBytes < 0 {
+		return fmt.Errorf("max-total-txs, max-total-bytes, confirm-total-txs and confirm-total-bytes must be 0 (the default) or greater")
+	}
+	if err := c.checkOfferedLoad(c.offeredLoad()); err != nil {
+		return err
+	}
+	// a dry run checks the rate against a calibrated cost instead
+	if c.StrictFeasibility && !(c.DryRun && !c.SkipCalibration) {
+		if f := c.rateFeasibility(defaultTxGenerateCost); !f.feasible() {
+			return fmt.Errorf("transaction rate is unachievable: %s", f)
+		}
+	}
+	if _, err := lookupBroadcastMethod(c.BroadcastTxMethod); err != nil {
+		return err
+	}
+	if err := logging.Validate(c.LogLevel, c.LogFormat); err != nil {
+		return err
+	}
+	if err := validateLogFile(c.LogFile, c.LogMaxSizeMB, c.LogMaxBackups, c.LogQuiet); err != nil {
+		return err
 	}
 	if len(c.Endpoints) == 0 {
 		return fmt.Errorf("expected at least one endpoint to conduct load test against, but found none")
 	}
+	if err := c.checkEndpointPaths(); err != nil {
+		return err
+	}
+	endpoints, limits, weights, err := c.parseEndpoints()
+	if err != nil {
+		return err
+	}
+	if _, err := c.parseEndpointNames(); err != nil {
+		return err
+	}
+	// discovered endpoints are never rate limited, so can absorb any shortfall,
+	// and we can't tell how many endpoints resolved (e.g. DNS) endpoints will
+	// expand into
+	if len(limits) > 0 && c.EndpointSelectMethod == SelectSuppliedEndpoints && !c.IgnoreRateLimitShortfall && !c.hasResolvedEndpoints() {
+		requested := c.expectedTxRate(c.Connections * len(endpoints))
+		// allow for floating point error
+		if _, total := c.endpointRates(endpoints, limits, weights); total < requested-1e-6 {
+			return fmt.Errorf(
+				"endpoint rate limits only allow for %.3f tx/sec, which is below the requested rate of %.3f tx/sec (use --ignore-rate-limit-shortfall to allow this)",
+				total,
+				requested,
+			)
+		}
+	}
 	if _, ok := validEndpointSelectMethods[c.EndpointSelectMethod]; !ok {
 		return fmt.Errorf("invalid endpoint-select-method: %s", c.EndpointSelectMethod)
 	}
+	if _, ok := validRPCVersions[c.RPCVersion]; !ok && c.RPCVersion != "" {
+		return fmt.Errorf("expected rpc-version to be one of \"auto\", \"legacy\" or \"v1\", but was %s", c.RPCVersion)
+	}
 	if c.ExpectPeers < 0 {
 		return fmt.Errorf("expect-peers must be at least 0, but got %d", c.ExpectPeers)
 	}
-	if c.ExpectPeers > 0 && c.PeerConnectTimeout < 1 {
-		return fmt.Errorf("peer-connect-timeout must be at least 1 if expect-peers is non-zero, but got %d", c.PeerConnectTimeout)
+	if c.ExpectPeers > 0 && c.PeerConnectTimeout <= 0 {
+		return fmt.Errorf("peer-connect-timeout must be positive if expect-peers is non-zero, but got %s", c.PeerConnectTimeout)
+	}
+	if c.PeerPollInterval < 0 {
+		return fmt.Errorf("peer-poll-interval must be at least 0, but got %s", c.PeerPollInterval)
 	}
 	if c.MaxEndpoints < 0 {
 		return fmt.Errorf("invalid value for max-endpoints: %d", c.MaxEndpoints)
@@ -108,16 +362,319 @@ func (c Config) Validate() error {
 	if c.MinConnectivity < 0 {
 		return fmt.Errorf("invalid value for min-peer-connectivity: %d", c.MinConnectivity)
 	}
+	if c.HealthCheckTimeout < 0 {
+		return fmt.Errorf("health-check-timeout must be at least 0, but got %s", c.HealthCheckTimeout)
+	}
+	if c.MinHealthyEndpoints < 0 {
+		return fmt.Errorf("min-healthy-endpoints must be at least 0, but got %d", c.MinHealthyEndpoints)
+	}
+	if c.EndpointFailureThreshold < 0 {
+		return fmt.Errorf("endpoint-failure-threshold must be at least 0, but got %d", c.EndpointFailureThreshold)
+	}
+	if c.EndpointRecoveryInterval < 0 {
+		return fmt.Errorf("endpoint-recovery-interval must be at least 0, but got %s", c.EndpointRecoveryInterval)
+	}
+	if c.RediscoveryInterval < 0 {
+		return fmt.Errorf("rediscovery-interval must be at least 0, but got %s", c.RediscoveryInterval)
+	}
+	if c.RediscoveryInterval > 0 && c.EndpointSelectMethod == SelectSuppliedEndpoints && !c.hasResolvedEndpoints() {
+		return fmt.Errorf("rediscovery-interval requires an endpoint-select-method of %q or %q, or resolved (e.g. DNS or Kubernetes) endpoints", SelectDiscoveredEndpoints, SelectAnyEndpoints)
+	}
+	if _, err := newDiscoveryFilter(&c); err != nil {
+		return err
+	}
+	if c.PreferredEndpointCount < 0 {
+		return fmt.Errorf("preferred-endpoint-count must be at least 0, but got %d", c.PreferredEndpointCount)
+	}
+	if c.MaxRediscoveredEndpoints < 0 {
+		return fmt.Errorf("max-rediscovered-endpoints must be at least 0, but got %d", c.MaxRediscoveredEndpoints)
+	}
+	if len(c.StatsOutputFormat) > 0 {
+		if _, ok := validStatsFormats[c.StatsOutputFormat]; !ok {
+			return fmt.Errorf("invalid statistics output format: %s", c.StatsOutputFormat)
+		}
+	}
+	if c.StatsAppend && c.StatsOutputFormat == StatsFormatJSON {
+		return fmt.Errorf("statistics can only be appended in CSV format")
+	}
+	for _, dest := range []string{c.StatsOutputFile, c.RawStatsOutputFile} {
+		u, err := parseStatsUploadURL(dest)
+		if err != nil {
+			return fmt.Errorf("invalid statistics upload URL: %w", err)
+		}
+		if u != nil && c.StatsAppend && dest == c.StatsOutputFile {
+			return fmt.Errorf("statistics cannot be appended to an uploaded file")
+		}
+	}
+	if c.StatsCSVDelimiter != 0 && !validStatsCSVDelimiter(c.StatsCSVDelimiter) {
+		return fmt.Errorf("invalid statistics CSV delimiter: %q", c.StatsCSVDelimiter)
+	}
+	if c.StatsFlushInterval < 0 {
+		return fmt.Errorf("expected stats-flush-interval to be >= 0, but was %s", c.StatsFlushInterval)
+	}
+	if len(c.RawStatsOutputFile) > 0 && time.Duration(c.RawStatsInterval) < time.Second {
+		return fmt.Errorf("expected raw-stats-interval to be >= 1s, but was %s", c.RawStatsInterval)
+	}
+	if len(c.InfluxDBURL) > 0 {
+		u, err := url.Parse(c.InfluxDBURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid InfluxDB URL: %s", c.InfluxDBURL)
+		}
+		if len(c.InfluxDBBucket) == 0 {
+			return fmt.Errorf("an InfluxDB bucket must be specified when exporting statistics to InfluxDB")
+		}
+	}
+	if len(c.ResultWebhookURL) > 0 {
+		u, err := url.Parse(c.ResultWebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid result webhook URL: %s", c.ResultWebhookURL)
+		}
+	}
+	if len(c.OTLPEndpoint) > 0 {
+		u, err := url.Parse(c.OTLPEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid OTLP endpoint: %s", c.OTLPEndpoint)
+		}
+		if time.Duration(c.OTLPExportInterval) < time.Second {
+			return fmt.Errorf("expected otlp-export-interval to be >= 1s, but was %s", c.OTLPExportInterval)
+		}
+	}
+	for i, bound := range c.BroadcastLatencyBuckets {
+		if !(bound > 0) || (i > 0 && bound <= c.BroadcastLatencyBuckets[i-1]) {
+			return fmt.Errorf("expected broadcast-latency-buckets to be positive and in increasing order, but got %v", c.BroadcastLatencyBuckets)
+		}
+	}
+	if c.ProgressInterval < 0 {
+		return fmt.Errorf("expected progress-interval to be >= 0, but was %s", c.ProgressInterval)
+	}
+	if c.StatsPushInterval < 0 {
+		return fmt.Errorf("expected stats-push-interval to be >= 0, but was %s", c.StatsPushInterval)
+	}
+	if len(c.ProgressMode) > 0 {
+		if _, ok := validProgressModes[c.ProgressMode]; !ok {
+			return fmt.Errorf("invalid progress mode: %s", c.ProgressMode)
+		}
+	}
+	if c.MinSuccessRatio < 0 || c.MinSuccessRatio > 1 {
+		return fmt.Errorf("expected min-success-ratio to be between 0 and 1, but was %f", c.MinSuccessRatio)
+	}
+	if len(c.LatencySampleFile) > 0 {
+		if c.LatencySampleRate <= 0 || c.LatencySampleRate > 1 {
+			return fmt.Errorf("expected latency-sample-rate to be > 0 and <= 1, but was %f", c.LatencySampleRate)
+		}
+		if c.LatencySampleCap < 1 {
+			return fmt.Errorf("expected latency-sample-cap to be >= 1, but was %d", c.LatencySampleCap)
+		}
+	}
+	if err := c.validatePregeneration(); err != nil {
+		return err
+	}
+	if c.TraceSampleRate < 0 || c.TraceSampleRate > 1 {
+		return fmt.Errorf("expected trace-sample-rate to be between 0 and 1, but was %f", c.TraceSampleRate)
+	}
+	if c.RateWindow < 0 {
+		return fmt.Errorf("expected rate-window to be >= 0, but was %s", c.RateWindow)
+	}
+	if c.MaxRateDeviation < 0 {
+		return fmt.Errorf("expected max-rate-deviation to be >= 0, but was %f", c.MaxRateDeviation)
+	}
+	if c.ChainStats && c.ChainStatsTimeout <= 0 {
+		return fmt.Errorf("expected chain-stats-timeout to be > 0, but was %s", c.ChainStatsTimeout)
+	}
+	if c.DrainTimeout < 0 {
+		return fmt.Errorf("expected drain-timeout to be >= 0, but was %s", c.DrainTimeout)
+	}
+	if c.MempoolPauseThreshold < 0 {
+		return fmt.Errorf("expected mempool-pause-threshold to be >= 0, but was %d", c.MempoolPauseThreshold)
+	}
+	if c.MempoolPauseThreshold > 0 {
+		if c.MempoolResumeThreshold < 0 || c.MempoolResumeThreshold > c.MempoolPauseThreshold {
+			return fmt.Errorf("expected mempool-resume-threshold to be between 0 and the pause threshold (%d), but was %d", c.MempoolPauseThreshold, c.MempoolResumeThreshold)
+		}
+		if c.MempoolPollInterval <= 0 {
+			return fmt.Errorf("expected mempool-poll-interval to be > 0, but was %s", c.MempoolPollInterval)
+		}
+	}
+	for i := range c.Runs {
+		cfg, err := c.runConfig(i)
+		if err != nil {
+			return err
+		}
+		if err := cfg.validate(totalRate); err != nil {
+			return fmt.Errorf("invalid configuration for run %d: %w", i, err)
+		}
+	}
 	return nil
 }
 
+// RunConfig returns the configuration for the run with the given index, i.e.
+// this configuration with the run's overrides applied. Without any runs, the
+// configuration is returned as is.
+func (c Config) RunConfig(i int) (Config, error) {
+	cfg, err := c.runConfig(i)
+	if err != nil {
+		return c, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return c, fmt.Errorf("invalid configuration for run %d: %w", i, err)
+	}
+	return cfg, nil
+}
+
+// runConfig returns the configuration for the run with the given index, without
+// validating it.
+func (c Config) runConfig(i int) (Config, error) {
+	if len(c.Runs) == 0 {
+		return c, nil
+	}
+	if i < 0 || i >= len(c.Runs) {
+		return c, fmt.Errorf("no run with index %d (there are %d runs)", i, len(c.Runs))
+	}
+	cfg := c
+	cfg.Runs = nil
+	dec := json.NewDecoder(bytes.NewReader(c.Runs[i]))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return c, fmt.Errorf("invalid overrides for run %d: %w", i, err)
+	}
+	if len(cfg.Runs) > 0 {
+		return c, fmt.Errorf("invalid overrides for run %d: runs can't be nested", i)
+	}
+	return cfg, nil
+}
+
+// runs returns the number of runs to execute.
+func (c Config) runs() int {
+	if len(c.Runs) > 0 {
+		return len(c.Runs)
+	}
+	return 1
+}
+
+// clientFactories returns the distinct client factories used by this
+// configuration's runs.
+func (c Config) clientFactories() []string {
+	var factories []string
+	seen := make(map[string]bool)
+	for i := 0; i < c.runs(); i++ {
+		cfg, err := c.runConfig(i)
+		if err != nil || seen[cfg.ClientFactory] {
+			continue
+		}
+		seen[cfg.ClientFactory] = true
+		factories = append(factories, cfg.ClientFactory)
+	}
+	return factories
+}
+
+// runOutputFile returns the name of the given output file for the run with the
+// given index, by suffixing the file's base name with the run's index (e.g.
+// "stats.csv" becomes "stats-run1.csv" for the run with index 1).
+func runOutputFile(filename string, i int) string {
+	if len(filename) == 0 {
+		return filename
+	}
+	ext := path.Ext(filename)
+	return fmt.Sprintf("%s-run%d%s", strings.TrimSuffix(filename, ext), i, ext)
+}
+
+// expectedTxRate estimates the number of transactions per second that this
+// configuration would generate across the given number of connections.
+func (c Config) expectedTxRate(connections int) float64 {
+	return c.Rate * float64(connections) / c.SendPeriod.Seconds()
+}
+
+// rateFor returns the rate (per connection) at which to send in order to
+// generate the given number of transactions per second across the given number
+// of connections, i.e. the inverse of expectedTxRate.
+func (c Config) rateFor(txRate float64, connections int) float64 {
+	if connections < 1 {
+		return 0
+	}
+	return txRate * c.SendPeriod.Seconds() / float64(connections)
+}
+
+func (c Config) broadcastLatencyBuckets() []float64 {
+	if len(c.BroadcastLatencyBuckets) > 0 {
+		return c.BroadcastLatencyBuckets
+	}
+	return defaultBroadcastLatencyBuckets
+}
+
+func (c Config) statsPushInterval() time.Duration {
+	if c.StatsPushInterval > 0 {
+		return time.Duration(c.StatsPushInterval)
+	}
+	return defaultStatsPushInterval
+}
+
+func (c Config) mempoolResumeThreshold() int {
+	if c.MempoolResumeThreshold > 0 {
+		return c.MempoolResumeThreshold
+	}
+	return c.MempoolPauseThreshold / 2
+}
+
+// countBounded returns whether the load test has no time limit, and so only
+// ends once every connection has sent Count transactions (or once MaxRunTime
+// has elapsed, if set).
+func (c Config) countBounded() bool {
+	return c.Time == 0 || c.Time == Duration(-time.Second)
+}
+
+// timeLimit returns how long the load test may run for: its time or, if it's
+// count-bounded, its MaxRunTime. 0 means there's no limit.
+func (c Config) timeLimit() time.Duration {
+	if c.countBounded() {
+		return time.Duration(c.MaxRunTime)
+	}
+	return time.Duration(c.Time)
+}
+
+// expectedRunTime returns how long the load test should take: its time limit
+// or, if it's count-bounded without a cap, the time it takes each connection
+// to send Count transactions at the configured rate.
+func (c Config) expectedRunTime() time.Duration {
+	if !c.countBounded() || c.Rate <= 0 {
+		return c.timeLimit()
+	}
+	expected := time.Duration(math.Ceil(float64(c.Count)/c.Rate)) * time.Duration(c.SendPeriod)
+	if limit := c.timeLimit(); limit > 0 && limit < expected {
+		return limit
+	}
+	return expected
+}
+
+// maxRunTime returns how long the load test could possibly last, i.e. its time
+// limit or, if it's count-bounded without a cap, a generous multiple of its
+// expected run time (allowing for it being slowed down by backpressure, pauses
+// and so on).
+func (c Config) maxRunTime() time.Duration {
+	if limit := c.timeLimit(); limit > 0 {
+		return limit
+	}
+	if c.Rate <= 0 {
+		return countBoundedMaxRunTime
+	}
+	return countBoundedRunTimeFactor * c.expectedRunTime()
+}
+
 // MaxTxsPerEndpoint estimates the maximum number of transactions that this
 // configuration would generate for a single endpoint.
 func (c Config) MaxTxsPerEndpoint() uint64 {
 	if c.Count > -1 {
 		return uint64(c.Count)
 	}
-	return uint64(c.Rate) * uint64(c.Time)
+	periods := c.Time.Seconds()
+	if c.SendPeriod > 0 {
+		periods = float64(c.Time) / float64(c.SendPeriod)
+	}
+	maxTxs := uint64(math.Ceil(c.Rate * periods))
+	// every pre-generated transaction must be unique, even if it's not sent
+	if pregenerated := uint64(c.pregenerateCount()); pregenerated > maxTxs {
+		maxTxs = pregenerated
+	}
+	return maxTxs
 }
 
 func (c CoordinatorConfig) ToJSON() string {
@@ -135,15 +692,213 @@ func (c CoordinatorConfig) Validate() error {
 	if c.ExpectWorkers < 1 {
 		return fmt.Errorf("coordinator expect-workers must be at least 1, but got %d", c.ExpectWorkers)
 	}
-	if c.WorkerConnectTimeout < 1 {
-		return fmt.Errorf("coordinator connect-timeout must be at least 1 second")
+	if c.MinWorkers < 0 || c.MinWorkers > c.ExpectWorkers {
+		return fmt.Errorf("coordinator min-workers must be between 0 and expect-workers (%d), but got %d", c.ExpectWorkers, c.MinWorkers)
+	}
+	if c.StartGracePeriod < 0 {
+		return fmt.Errorf("coordinator start-grace-period must be 0 or greater, but got %s", c.StartGracePeriod)
+	}
+	if c.MaxWorkers < 0 {
+		return fmt.Errorf("coordinator max-workers must be 0 (unlimited) or greater, but got %d", c.MaxWorkers)
+	}
+	if c.MaxWorkers > 0 && c.MaxWorkers < c.ExpectWorkers {
+		return fmt.Errorf("coordinator max-workers (%d) must be at least expect-workers (%d)", c.MaxWorkers, c.ExpectWorkers)
+	}
+	if c.WorkerConnectTimeout <= 0 {
+		return fmt.Errorf("coordinator connect-timeout must be positive, but got %s", c.WorkerConnectTimeout)
+	}
+	if c.ShutdownWait < 0 {
+		return fmt.Errorf("coordinator shutdown-wait must be 0 or greater, but got %s", c.ShutdownWait)
 	}
 	if c.LoadTestID < 0 {
 		return fmt.Errorf("coordinator load-test-id must be 0 or greater")
 	}
+	if c.StartStagger < 0 {
+		return fmt.Errorf("coordinator start-stagger must be 0 or greater, but got %s", c.StartStagger)
+	}
+	if c.HeartbeatInterval < 0 || c.HeartbeatTimeout < 0 {
+		return fmt.Errorf("coordinator heartbeat-interval and heartbeat-timeout must be 0 (the default) or greater")
+	}
+	if c.heartbeatTimeout() <= c.heartbeatInterval() {
+		return fmt.Errorf("coordinator heartbeat-timeout (%s) must be greater than heartbeat-interval (%s)", c.heartbeatTimeout(), c.heartbeatInterval())
+	}
+	if c.CheckpointInterval < 0 {
+		return fmt.Errorf("coordinator checkpoint-interval must be 0 (the default) or greater, but got %s", c.CheckpointInterval)
+	}
+	if c.Resume && len(c.StateFile) == 0 {
+		return fmt.Errorf("coordinator resume requires a state-file")
+	}
+	if c.RedistributeShards && !c.ShardEndpoints {
+		return fmt.Errorf("coordinator redistribute-shards requires shard-endpoints")
+	}
+	if (len(c.TLSCertFile) > 0) != (len(c.TLSKeyFile) > 0) {
+		return fmt.Errorf("both a TLS certificate and key must be specified to enable TLS")
+	}
+	if c.TotalRate < 0 {
+		return fmt.Errorf("coordinator total-rate must be 0 or greater, but got %v", c.TotalRate)
+	}
+	for key, o := range c.WorkerOverrides {
+		if isWorkerLabelSelector(key) {
+			if _, err := parseWorkerLabelSelector(key); err != nil {
+				return fmt.Errorf("worker overrides: %w", err)
+			}
+		} else if !isValidWorkerID(key) {
+			return fmt.Errorf("invalid worker ID \"%s\" in worker overrides: worker IDs can only contain lowercase alphanumeric characters", key)
+		}
+		if o.Rate < 0 || o.Connections < 0 {
+			return fmt.Errorf("worker overrides for %s: rate and connections must not be negative", key)
+		}
+		if o.Rate > 0 && c.TotalRate > 0 {
+			return fmt.Errorf("worker overrides for %s: rate can't be overridden when splitting a total-rate amongst the workers", key)
+		}
+	}
 	return nil
 }
 
+// ValidateConfig checks the given load testing configuration for use by the
+// coordinator. If the coordinator splits a total rate amongst its workers, the
+// configuration mustn't have a rate of its own, since the coordinator works out
+// each worker's rate. The load that the expected number of workers would send
+// between them mustn't exceed the configuration's hard caps.
+func (c CoordinatorConfig) ValidateConfig(cfg Config) error {
+	if err := cfg.validate(c.TotalRate > 0); err != nil {
+		return err
+	}
+	return cfg.checkOfferedLoad(c.offeredLoad(cfg))
+}
+
+// ValidateWorkerOverrides checks that the configuration that workers with
+// overrides would receive, given the base load testing configuration, is
+// valid.
+func (c CoordinatorConfig) ValidateWorkerOverrides(cfg Config) error {
+	for key, o := range c.WorkerOverrides {
+		workers := "worker " + key
+		if isWorkerLabelSelector(key) {
+			workers = "workers matching " + key
+		}
+		merged := o.apply(cfg)
+		// the total load, overrides included, is checked by ValidateConfig
+		if err := merged.validate(c.TotalRate > 0); err != nil {
+			return fmt.Errorf("invalid configuration for %s: %w", workers, err)
+		}
+		if err := merged.ParseEndpointRateLimits(); err != nil {
+			return fmt.Errorf("invalid configuration for %s: %w", workers, err)
+		}
+	}
+	return nil
+}
+
+// workerOverride returns the overrides for the worker with the given ID and
+// labels, if any: those keyed by its ID, or else those whose label selector
+// selects the most of its labels (of equally specific selectors, the first in
+// order).
+func (c CoordinatorConfig) workerOverride(id string, labels map[string]string) (WorkerOverride, bool) {
+	if o, ok := c.WorkerOverrides[id]; ok {
+		return o, true
+	}
+	var best string
+	bestLabels := -1
+	for key := range c.WorkerOverrides {
+		if !isWorkerLabelSelector(key) {
+			continue
+		}
+		// selectors were validated along with the coordinator configuration
+		selected, err := parseWorkerLabelSelector(key)
+		if err != nil || !matchesWorkerLabels(selected, labels) {
+			continue
+		}
+		if len(selected) > bestLabels || (len(selected) == bestLabels && key < best) {
+			best, bestLabels = key, len(selected)
+		}
+	}
+	if bestLabels < 0 {
+		return WorkerOverride{}, false
+	}
+	return c.WorkerOverrides[best], true
+}
+
+// configForWorker returns the load testing configuration for the worker with
+// the given ID and labels, with any overrides for it merged over the given
+// base configuration.
+func (c CoordinatorConfig) configForWorker(cfg Config, id string, labels map[string]string) Config {
+	if o, ok := c.workerOverride(id, labels); ok {
+		return o.apply(cfg)
+	}
+	return cfg
+}
+
+// LoadWorkerOverrides reads worker overrides from the given JSON file, which
+// must contain an object mapping worker IDs or label selectors to their
+// overrides.
+func LoadWorkerOverrides(filename string) (map[string]WorkerOverride, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read worker overrides: %w", err)
+	}
+	var overrides map[string]WorkerOverride
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse worker overrides file %s: %w", filename, err)
+	}
+	return overrides, nil
+}
+
+// LoadRuns reads the runs to execute back to back from the given JSON file,
+// which must contain an array of objects overriding parts of the load testing
+// configuration.
+func LoadRuns(filename string) ([]json.RawMessage, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runs: %w", err)
+	}
+	var runs []json.RawMessage
+	if err := json.Unmarshal(b, &runs); err != nil {
+		return nil, fmt.Errorf("failed to parse runs file %s: %w", filename, err)
+	}
+	return runs, nil
+}
+
+// minWorkers returns the minimum number of workers required to start the load
+// test.
+func (c CoordinatorConfig) minWorkers() int {
+	if c.MinWorkers > 0 {
+		return c.MinWorkers
+	}
+	return c.ExpectWorkers
+}
+
+// checkpointInterval returns how often the load test is checkpointed to the
+// state file.
+func (c CoordinatorConfig) checkpointInterval() time.Duration {
+	if c.CheckpointInterval > 0 {
+		return time.Duration(c.CheckpointInterval)
+	}
+	return defaultCheckpointInterval
+}
+
+// heartbeatInterval returns how often workers send heartbeats.
+func (c CoordinatorConfig) heartbeatInterval() time.Duration {
+	if c.HeartbeatInterval > 0 {
+		return time.Duration(c.HeartbeatInterval)
+	}
+	return defaultHeartbeatInterval
+}
+
+// heartbeatTimeout returns how long a worker can go without being heard from
+// before it's considered to have failed.
+func (c CoordinatorConfig) heartbeatTimeout() time.Duration {
+	if c.HeartbeatTimeout > 0 {
+		return time.Duration(c.HeartbeatTimeout)
+	}
+	return defaultHeartbeatTimeout
+}
+
+// tlsEnabled returns whether the coordinator serves TLS.
+func (c CoordinatorConfig) tlsEnabled() bool {
+	return len(c.TLSCertFile) > 0 && len(c.TLSKeyFile) > 0
+}
+
 func (c Config) ToJSON() string {
 	b, err := json.Marshal(c)
 	if err != nil {
@@ -156,15 +911,52 @@ func (c WorkerConfig) Validate() error {
 	if len(c.ID) > 0 && !isValidWorkerID(c.ID) {
 		return fmt.Errorf("Invalid worker ID \"%s\": worker IDs can only be lowercase alphanumeric characters", c.ID)
 	}
+	if err := validateWorkerLabels(c.Labels); err != nil {
+		return err
+	}
 	if len(c.CoordAddr) == 0 {
 		return fmt.Errorf("coordinator address must be specified")
 	}
-	if c.CoordConnectTimeout < 1 {
-		return fmt.Errorf("expected connect-timeout to be >= 1, but was %d", c.CoordConnectTimeout)
+	if c.CoordConnectTimeout <= 0 {
+		return fmt.Errorf("expected connect-timeout to be positive, but was %s", c.CoordConnectTimeout)
+	}
+	if c.CoordRetryInterval < 0 {
+		return fmt.Errorf("expected retry-interval to be >= 0, but was %s", c.CoordRetryInterval)
+	}
+	if c.MaxReconnectTime < 0 {
+		return fmt.Errorf("expected max-reconnect-time to be >= 0, but was %s", c.MaxReconnectTime)
+	}
+	if err := logging.Validate(c.LogLevel, c.LogFormat); err != nil {
+		return err
+	}
+	if err := validateLogFile(c.LogFile, c.LogMaxSizeMB, c.LogMaxBackups, c.LogQuiet); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateLogFile checks the configuration of the file to which to write log
+// entries, if any.
+func validateLogFile(file string, maxSizeMB, maxBackups int, quiet bool) error {
+	if maxSizeMB < 0 {
+		return fmt.Errorf("expected log-max-size-mb to be >= 0, but was %d", maxSizeMB)
+	}
+	if maxBackups < 0 {
+		return fmt.Errorf("expected log-max-backups to be >= 0, but was %d", maxBackups)
+	}
+	if quiet && len(file) == 0 {
+		return fmt.Errorf("log-quiet requires a log file, or nothing would be logged")
 	}
 	return nil
 }
 
+func (c WorkerConfig) coordRetryInterval() time.Duration {
+	if c.CoordRetryInterval > 0 {
+		return time.Duration(c.CoordRetryInterval)
+	}
+	return workerConnectRetryInterval
+}
+
 func (c WorkerConfig) ToJSON() string {
 	b, err := json.Marshal(c)
 	if err != nil {
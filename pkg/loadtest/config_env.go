@@ -0,0 +1,290 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of the environment variables from which
+// configuration fields are read, e.g. TMLOADTEST_RATE for the "rate" field.
+const EnvPrefix = "TMLOADTEST_"
+
+// envSecretFields maps the names of the secret fields (which are never
+// written to JSON, and so have no JSON names) to the names by which they're
+// read from the environment.
+var envSecretFields = map[string]string{
+	"AuthToken":           "auth_token",
+	"ResultWebhookSecret": "result_webhook_secret",
+}
+
+// envField is a configuration field that can be read from the environment.
+type envField struct {
+	Name   string        // The name of the environment variable.
+	Value  reflect.Value // The (settable) field.
+	Secret bool          // Is the field's value secret?
+}
+
+// envFields returns the fields of the given configuration (a pointer to a
+// struct) that can be read from the environment, in order. Their environment
+// variables are named after their JSON names, e.g. TMLOADTEST_SEND_PERIOD for
+// "send_period".
+func envFields(cfg interface{}) []envField {
+	v := reflect.ValueOf(cfg).Elem()
+	fields := make([]envField, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		secretName, secret := envSecretFields[f.Name]
+		if secret {
+			name = secretName
+		}
+		if len(name) == 0 || name == "-" {
+			continue
+		}
+		fields = append(fields, envField{
+			Name:   EnvPrefix + strings.ToUpper(name),
+			Value:  v.Field(i),
+			Secret: secret,
+		})
+	}
+	return fields
+}
+
+// configEnvFields returns the fields of the given configuration that can be
+// read from the environment: the load testing configuration's, then the
+// coordinator's and then the workers'. The coordinator and workers share the
+// variables of the fields they have in common (e.g.
+// TMLOADTEST_CONNECT_TIMEOUT).
+func configEnvFields(into *ConfigFile) [][]envField {
+	return [][]envField{envFields(&into.Config), envFields(&into.Coordinator), envFields(&into.Worker)}
+}
+
+// LoadConfigFromEnv reads the configuration fields set by TMLOADTEST_
+// environment variables into the given configuration, leaving the others as
+// they are. Lists are given as comma-separated values, maps as
+// comma-separated key=value pairs, durations as duration strings (e.g. "90s")
+// and more complex fields (e.g. the coordinator's worker_overrides) as JSON.
+// Empty variables are ignored.
+func LoadConfigFromEnv(into *ConfigFile) error {
+	_, err := loadConfigFromEnv(os.LookupEnv, into)
+	return err
+}
+
+// loadConfigFromEnv reads the configuration from the environment variables
+// given by lookup, returning the names of the variables holding durations
+// given as bare numbers of seconds.
+func loadConfigFromEnv(lookup func(string) (string, bool), into *ConfigFile) ([]string, error) {
+	bare := make([]string, 0)
+	// the coordinator and workers share some variables, which are only
+	// reported once
+	reported := make(map[string]bool)
+	for _, section := range configEnvFields(into) {
+		for _, f := range section {
+			s, ok := lookup(f.Name)
+			if !ok || len(strings.TrimSpace(s)) == 0 {
+				continue
+			}
+			bareSeconds, err := setEnvValue(f.Value, s)
+			if err != nil {
+				if f.Secret {
+					return nil, fmt.Errorf("invalid value for %s: %w", f.Name, err)
+				}
+				return nil, fmt.Errorf("invalid value %q for %s: %w", s, f.Name, err)
+			}
+			if bareSeconds && !reported[f.Name] {
+				bare = append(bare, f.Name)
+				reported[f.Name] = true
+			}
+		}
+	}
+	return bare, nil
+}
+
+var (
+	durationType = reflect.TypeOf(Duration(0))
+	runeType     = reflect.TypeOf(rune(0))
+)
+
+// setEnvValue parses the given environment variable value into the given
+// field, returning whether it's a duration given as a bare number of seconds.
+func setEnvValue(v reflect.Value, s string) (bool, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case v.Type() == durationType:
+		d, bareSeconds, err := parseDuration(s)
+		if err != nil {
+			return false, err
+		}
+		v.SetInt(int64(d))
+		return bareSeconds, nil
+	case v.Type() == runeType:
+		var r rune
+		if err := newRuneValue(0, &r).Set(s); err != nil {
+			return false, err
+		}
+		v.SetInt(int64(r))
+		return false, nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("expected true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("expected an integer")
+		}
+		v.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return false, fmt.Errorf("expected a number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String && v.Type().Elem().Kind() != reflect.Float64 {
+			return false, setEnvJSON(v, s)
+		}
+		items := splitEnvList(s)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if _, err := setEnvValue(slice.Index(i), item); err != nil {
+				return false, fmt.Errorf("item %q: %w", item, err)
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String && v.Type().Elem().Kind() != reflect.Float64 {
+			return false, setEnvJSON(v, s)
+		}
+		m := reflect.MakeMap(v.Type())
+		for _, item := range splitEnvList(s) {
+			// keys may be endpoint addresses, which can't hold an "=" in
+			// their hosts but may in their paths
+			i := strings.LastIndex(item, "=")
+			if v.Type().Elem().Kind() == reflect.String {
+				i = strings.Index(item, "=")
+			}
+			if i <= 0 {
+				return false, fmt.Errorf("expected key=value, but got %q", item)
+			}
+			val := reflect.New(v.Type().Elem()).Elem()
+			if _, err := setEnvValue(val, item[i+1:]); err != nil {
+				return false, fmt.Errorf("item %q: %w", item, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(item[:i])), val)
+		}
+		v.Set(m)
+	default:
+		return false, setEnvJSON(v, s)
+	}
+	return false, nil
+}
+
+// setEnvJSON parses the given JSON into the given field.
+func setEnvJSON(v reflect.Value, s string) error {
+	ptr := reflect.New(v.Type())
+	if err := json.Unmarshal([]byte(s), ptr.Interface()); err != nil {
+		return fmt.Errorf("expected JSON: %s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	v.Set(ptr.Elem())
+	return nil
+}
+
+// splitEnvList splits the given comma-separated list, leaving out empty
+// items.
+func splitEnvList(s string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// formatEnvValue formats the given field's value as its environment variable
+// would give it.
+func formatEnvValue(v reflect.Value) string {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Type() == runeType:
+		r := rune(v.Int())
+		return (&runeValue{r: &r}).String()
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		return fmt.Sprint(v.Interface())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String && v.Type().Elem().Kind() != reflect.Float64 {
+			break
+		}
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatEnvValue(v.Index(i))
+		}
+		return strings.Join(items, ",")
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String && v.Type().Elem().Kind() != reflect.Float64 {
+			break
+		}
+		items := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			items = append(items, key.String()+"="+formatEnvValue(v.MapIndex(key)))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	if v.IsZero() {
+		return ""
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// writeConfigEnv writes the environment variables from which the given
+// configuration can be read, with their values, to w. The values of secrets
+// are redacted.
+func writeConfigEnv(w io.Writer, cfg *ConfigFile) error {
+	headings := []string{
+		"Load testing configuration",
+		"Coordinator configuration (tm-load-test coordinator)",
+		"Worker configuration (tm-load-test worker)",
+	}
+	for i, section := range configEnvFields(cfg) {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "# %s\n", headings[i]); err != nil {
+			return err
+		}
+		for _, f := range section {
+			value := formatEnvValue(f.Value)
+			if f.Secret && len(value) > 0 {
+				value = "<redacted>"
+			}
+			if _, err := fmt.Fprintf(w, "%s=%s\n", f.Name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
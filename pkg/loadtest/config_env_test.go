@@ -0,0 +1,202 @@
+package loadtest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"TMLOADTEST_RATE":                      "0.5",
+		"TMLOADTEST_CONNECTIONS":               " 4 ",
+		"TMLOADTEST_SEED":                      "-42",
+		"TMLOADTEST_TIME":                      "10m",
+		"TMLOADTEST_SEND_PERIOD":               "2",
+		"TMLOADTEST_HEALTH_CHECK":              "true",
+		"TMLOADTEST_ENDPOINTS":                 "ws://node0:26657/websocket, ws://node1:26657/websocket|maxrate=100,",
+		"TMLOADTEST_ENDPOINT_WEIGHTS":          "ws://node0:26657/websocket=2,ws://node1:26657/websocket?a=b=0.5",
+		"TMLOADTEST_BROADCAST_LATENCY_BUCKETS": "0.1,1,10",
+		"TMLOADTEST_STATS_CSV_DELIMITER":       `\t`,
+		"TMLOADTEST_RESULT_WEBHOOK_SECRET":     "webhook-secret",
+		"TMLOADTEST_EXPECT_WORKERS":            "4",
+		"TMLOADTEST_WORKER_OVERRIDES":          `{"worker1": {"rate": 50}}`,
+		"TMLOADTEST_CONNECT_TIMEOUT":           "1m",
+		"TMLOADTEST_AUTH_TOKEN":                "token",
+		"TMLOADTEST_COORD_ADDR":                "ws://coordinator:26670",
+		"TMLOADTEST_LABELS":                    "region=eu-west-1,selector=app=validator",
+		// empty variables are ignored
+		"TMLOADTEST_BROADCAST_TX_METHOD": "",
+	}
+	file := ConfigFile{Config: Config{BroadcastTxMethod: "async", Size: 250}}
+	bare, err := loadConfigFromEnv(envLookup(env), &file)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TMLOADTEST_SEND_PERIOD"}, bare)
+
+	assert.Equal(t, 0.5, file.Rate)
+	assert.Equal(t, 4, file.Connections)
+	assert.Equal(t, int64(-42), file.Seed)
+	assert.Equal(t, Duration(10*time.Minute), file.Time)
+	assert.Equal(t, Duration(2*time.Second), file.SendPeriod)
+	assert.True(t, file.HealthCheck)
+	assert.Equal(t, []string{"ws://node0:26657/websocket", "ws://node1:26657/websocket|maxrate=100"}, file.Endpoints)
+	assert.Equal(t, map[string]float64{
+		"ws://node0:26657/websocket":     2,
+		"ws://node1:26657/websocket?a=b": 0.5,
+	}, file.EndpointWeights)
+	assert.Equal(t, []float64{0.1, 1, 10}, file.BroadcastLatencyBuckets)
+	assert.Equal(t, '\t', file.StatsCSVDelimiter)
+	assert.Equal(t, "webhook-secret", file.ResultWebhookSecret)
+	assert.Equal(t, "async", file.BroadcastTxMethod)
+	assert.Equal(t, 250, file.Size)
+
+	assert.Equal(t, 4, file.Coordinator.ExpectWorkers)
+	assert.Equal(t, 50.0, file.Coordinator.WorkerOverrides["worker1"].Rate)
+	assert.Equal(t, "token", file.Coordinator.AuthToken)
+	assert.Equal(t, "ws://coordinator:26670", file.Worker.CoordAddr)
+	assert.Equal(t, map[string]string{"region": "eu-west-1", "selector": "app=validator"}, file.Worker.Labels)
+	assert.Equal(t, "token", file.Worker.AuthToken)
+	// the coordinator and workers share the variables of their common fields
+	assert.Equal(t, Duration(time.Minute), file.Coordinator.WorkerConnectTimeout)
+	assert.Equal(t, Duration(time.Minute), file.Worker.CoordConnectTimeout)
+}
+
+func TestLoadConfigFromEnvMalformed(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       string
+		expectError string
+	}{
+		{"TMLOADTEST_RATE", "fast", `invalid value "fast" for TMLOADTEST_RATE: expected a number`},
+		{"TMLOADTEST_CONNECTIONS", "1.5", "expected an integer"},
+		{"TMLOADTEST_SEED", "0x10", "expected an integer"},
+		{"TMLOADTEST_HEALTH_CHECK", "yes", "expected true or false"},
+		{"TMLOADTEST_TIME", "forever", `invalid duration "forever"`},
+		{"TMLOADTEST_STATS_CSV_DELIMITER", ";;", "expected a single character"},
+		{"TMLOADTEST_BROADCAST_LATENCY_BUCKETS", "0.1,slow", `item "slow": expected a number`},
+		{"TMLOADTEST_ENDPOINT_WEIGHTS", "ws://node0:26657/websocket", "expected key=value"},
+		{"TMLOADTEST_ENDPOINT_WEIGHTS", "ws://node0:26657/websocket=heavy", "expected a number"},
+		{"TMLOADTEST_LABELS", "=eu-west-1", "expected key=value"},
+		{"TMLOADTEST_WORKER_OVERRIDES", "worker1=50", "expected JSON"},
+		{"TMLOADTEST_WORKER_OVERRIDES", `{"worker1": {"rate": "fast"}}`, "expected JSON"},
+		{"TMLOADTEST_RUNS", `{"rate": 10}`, "expected JSON"},
+	}
+	for _, tc := range testCases {
+		var file ConfigFile
+		_, err := loadConfigFromEnv(envLookup(map[string]string{tc.name: tc.value}), &file)
+		require.Error(t, err, tc.name)
+		assert.Contains(t, err.Error(), tc.name, tc.value)
+		assert.Contains(t, err.Error(), tc.expectError, tc.value)
+	}
+}
+
+func TestWriteConfigEnv(t *testing.T) {
+	cfg := ConfigFile{
+		Config: Config{
+			ClientFactory:     "kvstore",
+			Rate:              0.1,
+			Time:              Duration(90 * time.Second),
+			Endpoints:         []string{"ws://node0:26657/websocket", "ws://node1:26657/websocket"},
+			EndpointNames:     map[string]string{"ws://node1:26657/websocket": "b", "ws://node0:26657/websocket": "a"},
+			StatsCSVDelimiter: ';',
+		},
+		Coordinator: CoordinatorConfig{
+			BindAddr:        "0.0.0.0:26670",
+			AuthToken:       "token",
+			WorkerOverrides: map[string]WorkerOverride{"worker1": {Rate: 50}},
+		},
+		Worker: WorkerConfig{Labels: map[string]string{"region": "eu-west-1"}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, writeConfigEnv(&buf, &cfg))
+	out := buf.String()
+	for _, line := range []string{
+		"# Load testing configuration",
+		"TMLOADTEST_RATE=0.1",
+		"TMLOADTEST_TIME=1m30s",
+		"TMLOADTEST_ENDPOINTS=ws://node0:26657/websocket,ws://node1:26657/websocket",
+		"TMLOADTEST_ENDPOINT_NAMES=ws://node0:26657/websocket=a,ws://node1:26657/websocket=b",
+		"TMLOADTEST_STATS_CSV_DELIMITER=;",
+		"TMLOADTEST_RESULT_WEBHOOK_SECRET=",
+		"# Coordinator configuration (tm-load-test coordinator)",
+		"TMLOADTEST_BIND_ADDR=0.0.0.0:26670",
+		"TMLOADTEST_AUTH_TOKEN=<redacted>",
+		`TMLOADTEST_WORKER_OVERRIDES={"worker1":{"rate":50}}`,
+		"# Worker configuration (tm-load-test worker)",
+		"TMLOADTEST_LABELS=region=eu-west-1",
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+	assert.NotContains(t, out, "token")
+
+	// the written variables read back into the same configuration
+	cfg.Coordinator.AuthToken = ""
+	buf.Reset()
+	require.NoError(t, writeConfigEnv(&buf, &cfg))
+	env := make(map[string]string)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if name, value, ok := strings.Cut(line, "="); ok && !strings.HasPrefix(line, "#") {
+			// the coordinator and workers share some variables
+			if _, exists := env[name]; !exists {
+				env[name] = value
+			}
+		}
+	}
+	var readBack ConfigFile
+	_, err := loadConfigFromEnv(envLookup(env), &readBack)
+	require.NoError(t, err)
+	assert.Equal(t, cfg, readBack)
+}
+
+func TestEnvPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "run.yaml", testYAMLConfig)
+	cli := &CLIConfig{AppName: "tm-load-test", DefaultClientFactory: "kvstore"}
+	t.Setenv("TMLOADTEST_RATE", "300")
+	t.Setenv("TMLOADTEST_SIZE", "100")
+	t.Setenv("TMLOADTEST_EXPECT_WORKERS", "8")
+	testCases := []struct {
+		args     []string
+		expected map[string]string
+	}{
+		{
+			// the environment overrides the defaults and the file, but not
+			// the file's other values
+			[]string{"--config", path},
+			map[string]string{"rate": "300", "size": "100", "time": "10m0s"},
+		},
+		{
+			[]string{"coordinator", "--config", path, "-r", "200", "--expect-workers", "2"},
+			map[string]string{"rate": "200", "size": "100", "expect-workers": "2"},
+		},
+		{
+			[]string{"coordinator"},
+			map[string]string{"rate": "300", "expect-workers": "8"},
+		},
+	}
+	for _, tc := range testCases {
+		root, err := buildCLI(cli, tc.args, logging.NewNoopLogger())
+		require.NoError(t, err, tc.args)
+		cmd, flags, err := root.Find(tc.args)
+		require.NoError(t, err, tc.args)
+		require.NoError(t, cmd.ParseFlags(flags), tc.args)
+		for name, expected := range tc.expected {
+			assert.Equal(t, expected, cmd.Flags().Lookup(name).Value.String(), "%v: --%s", tc.args, name)
+		}
+	}
+
+	t.Setenv("TMLOADTEST_SIZE", "large")
+	_, err := buildCLI(cli, nil, logging.NewNoopLogger())
+	assert.ErrorContains(t, err, `invalid value "large" for TMLOADTEST_SIZE`)
+}
@@ -0,0 +1,137 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the configuration read from a configuration file: the load
+// testing configuration at the top level, and the coordinator's and workers'
+// configurations in their own "coordinator" and "worker" sections. Keys are
+// the configurations' JSON field names, e.g.:
+//
+//	rate: 500
+//	time: 10m
+//	endpoints:
+//	  - ws://node0:26657/websocket
+//	coordinator:
+//	  expect_workers: 4
+type ConfigFile struct {
+	Config
+	Coordinator CoordinatorConfig `json:"coordinator"`
+	Worker      WorkerConfig      `json:"worker"`
+}
+
+// LoadConfigFromFile reads the configuration file with the given name into
+// the given configuration, the format of which is detected by its extension:
+// YAML (.yaml or .yml), TOML (.toml) or JSON (.json). Fields that the file
+// doesn't set keep their values, so the configuration can hold defaults
+// beforehand. If strict, keys that don't match any configuration field (e.g.
+// because of a typo) are an error, and otherwise they're ignored.
+//
+// Secrets (the coordinator's and workers' authentication tokens) can't be
+// given in configuration files.
+func LoadConfigFromFile(filename string, strict bool, into *ConfigFile) error {
+	doc, err := readConfigFile(filename)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to parse configuration file %s: %w", filename, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(into); err != nil {
+		return fmt.Errorf("invalid configuration file %s: %s", filename, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// readConfigFile reads the configuration file with the given name into a
+// generic document.
+func readConfigFile(filename string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+	var doc map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		err = toml.Unmarshal(b, &doc)
+	case ".json":
+		err = json.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("unrecognized configuration file extension \"%s\" (expected .yaml, .yml, .toml or .json)", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", filename, err)
+	}
+	return doc, nil
+}
+
+// configFileBareSeconds returns the (dotted) names of the duration fields in
+// the configuration file with the given name that are given as bare numbers of
+// seconds.
+func configFileBareSeconds(filename string) []string {
+	doc, err := readConfigFile(filename)
+	if err != nil {
+		return nil
+	}
+	bare := make([]string, 0)
+	check := func(prefix string, section map[string]interface{}, fields []string) {
+		for _, name := range fields {
+			if v, ok := section[name]; ok {
+				if _, isString := v.(string); !isString {
+					bare = append(bare, prefix+name)
+				}
+			}
+		}
+	}
+	check("", doc, durationFields)
+	if coord, ok := doc["coordinator"].(map[string]interface{}); ok {
+		check("coordinator.", coord, coordDurationFields)
+	}
+	if worker, ok := doc["worker"].(map[string]interface{}); ok {
+		check("worker.", worker, workerDurationFields)
+	}
+	return bare
+}
+
+// configFileArgs returns the configuration file given by the --config flag
+// amongst the given command line arguments (if any), and whether the
+// --strict-config flag was given. The configuration file is loaded before
+// the command line is parsed, so that flags override its values.
+func configFileArgs(args []string) (string, bool) {
+	var filename string
+	strict := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		switch {
+		case arg == "--config" && i+1 < len(args):
+			filename = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--config="):
+			filename = strings.TrimPrefix(arg, "--config=")
+		case arg == "--strict-config" || arg == "--strict-config=true":
+			strict = true
+		case arg == "--strict-config=false":
+			strict = false
+		}
+	}
+	return filename, strict
+}
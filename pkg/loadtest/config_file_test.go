@@ -0,0 +1,184 @@
+package loadtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testYAMLConfig = `# a load test
+rate: 500
+time: 10m
+send_period: 250ms
+endpoints:
+  - ws://node0:26657/websocket
+  - ws://node1:26657/websocket|maxrate=100
+endpoint_weights:
+  ws://node0:26657/websocket: 2
+broadcast_tx_method: sync
+coordinator:
+  expect_workers: 4
+  connect_timeout: 1m
+  worker_overrides:
+    worker1:
+      rate: 50
+worker:
+  coord_addr: ws://coordinator:26670
+  labels:
+    region: eu-west-1
+`
+
+const testTOMLConfig = `# a load test
+rate = 500
+time = "10m"
+send_period = '250ms'
+endpoints = [
+  "ws://node0:26657/websocket",
+  "ws://node1:26657/websocket|maxrate=100", # capped
+]
+endpoint_weights = { "ws://node0:26657/websocket" = 2 }
+broadcast_tx_method = "sync"
+
+[coordinator]
+expect_workers = 4
+connect_timeout = "1m"
+worker_overrides.worker1.rate = 50
+
+[worker]
+coord_addr = "ws://coordinator:26670"
+labels = { region = "eu-west-1" }
+`
+
+func writeConfigFile(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestLoadConfigFromFile(t *testing.T) {
+	for name, contents := range map[string]string{
+		"run.yaml": testYAMLConfig,
+		"run.toml": testTOMLConfig,
+	} {
+		file := ConfigFile{Config: Config{Connections: 1, Rate: 1000}}
+		require.NoError(t, LoadConfigFromFile(writeConfigFile(t, name, contents), true, &file), name)
+		// fields the file doesn't set keep their values
+		assert.Equal(t, 1, file.Connections, name)
+		assert.Equal(t, 500.0, file.Rate, name)
+		assert.Equal(t, Duration(10*time.Minute), file.Time, name)
+		assert.Equal(t, Duration(250*time.Millisecond), file.SendPeriod, name)
+		assert.Equal(t, []string{"ws://node0:26657/websocket", "ws://node1:26657/websocket|maxrate=100"}, file.Endpoints, name)
+		assert.Equal(t, map[string]float64{"ws://node0:26657/websocket": 2}, file.EndpointWeights, name)
+		assert.Equal(t, "sync", file.BroadcastTxMethod, name)
+		assert.Equal(t, 4, file.Coordinator.ExpectWorkers, name)
+		assert.Equal(t, Duration(time.Minute), file.Coordinator.WorkerConnectTimeout, name)
+		assert.Equal(t, 50.0, file.Coordinator.WorkerOverrides["worker1"].Rate, name)
+		assert.Equal(t, "ws://coordinator:26670", file.Worker.CoordAddr, name)
+		assert.Equal(t, map[string]string{"region": "eu-west-1"}, file.Worker.Labels, name)
+	}
+}
+
+func TestLoadConfigFromFileUnknownKeys(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contents    string
+		expectError string
+	}{
+		{"run.yaml", "rate: 500\nconections: 4\n", `unknown field "conections"`},
+		{"run.yaml", "coordinator:\n  expect_worker: 4\n", `unknown field "expect_worker"`},
+		{"run.toml", "rate = 500\n[worker]\ncoordinator = \"ws://localhost:26670\"\n", `unknown field "coordinator"`},
+		// secrets can't be given in configuration files
+		{"run.json", `{"coordinator": {"auth_token": "secret"}}`, `unknown field "auth_token"`},
+	}
+	for _, tc := range testCases {
+		path := writeConfigFile(t, tc.name, tc.contents)
+		var file ConfigFile
+		err := LoadConfigFromFile(path, true, &file)
+		require.Error(t, err, tc.contents)
+		assert.Contains(t, err.Error(), tc.expectError, tc.contents)
+		// unknown keys are ignored unless strict
+		require.NoError(t, LoadConfigFromFile(path, false, &file), tc.contents)
+	}
+}
+
+func TestLoadConfigFromFileErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contents    string
+		expectError string
+	}{
+		{"run.ini", "rate=500", `unrecognized configuration file extension ".ini"`},
+		{"run.yaml", "rate: [", "failed to parse configuration file"},
+		{"run.yaml", "rate: fast", "invalid configuration file"},
+		{"run.yaml", "time: forever", `invalid duration "forever"`},
+		{"run.toml", "rate = fast", `line 1 (last key "rate"): expected value but found "fast" instead`},
+		{"run.toml", "rate = 500\nrate = 600", "line 2 (last key \"rate\"): Key 'rate' has already been defined"},
+		{"run.toml", "endpoints = [\"a\" \"b\"]", `line 1 (last key "endpoints"): expected a comma`},
+		{"run.toml", "time = 2024-01-01T00:00:00Z", `invalid duration "2024-01-01T00:00:00Z"`},
+	}
+	for _, tc := range testCases {
+		var file ConfigFile
+		err := LoadConfigFromFile(writeConfigFile(t, tc.name, tc.contents), false, &file)
+		require.Error(t, err, tc.contents)
+		assert.Contains(t, err.Error(), tc.expectError, tc.contents)
+	}
+}
+
+func TestConfigFileBareSeconds(t *testing.T) {
+	path := writeConfigFile(t, "run.toml", `time = 60
+rate_window = "2s"
+[coordinator]
+start_stagger = 0.5
+connect_timeout = "1m"
+[worker]
+retry_interval = 5
+`)
+	assert.Equal(t, []string{"time", "coordinator.start_stagger", "worker.retry_interval"}, configFileBareSeconds(path))
+}
+
+func TestConfigFileFlagPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "run.yaml", testYAMLConfig)
+	cli := &CLIConfig{AppName: "tm-load-test", DefaultClientFactory: "kvstore"}
+	testCases := []struct {
+		args     []string
+		expected map[string]string
+	}{
+		{
+			// without flags, the file overrides the defaults
+			[]string{"--config", path},
+			map[string]string{"rate": "500", "connections": "1", "time": "10m0s", "broadcast-tx-method": "sync"},
+		},
+		{
+			[]string{"--config=" + path, "-r", "200", "--endpoints", "ws://node2:26657/websocket"},
+			map[string]string{"rate": "200", "time": "10m0s", "endpoints": "[ws://node2:26657/websocket]"},
+		},
+		{
+			[]string{"coordinator", "--config", path, "--connect-timeout", "2m"},
+			map[string]string{"rate": "500", "expect-workers": "4", "connect-timeout": "2m0s"},
+		},
+		{
+			[]string{"worker", "--strict-config", "--config", path, "--labels", "region=us-east-1"},
+			map[string]string{"coordinator": "ws://coordinator:26670", "labels": "[region=us-east-1]"},
+		},
+	}
+	for _, tc := range testCases {
+		root, err := buildCLI(cli, tc.args, logging.NewNoopLogger())
+		require.NoError(t, err, tc.args)
+		cmd, flags, err := root.Find(tc.args)
+		require.NoError(t, err, tc.args)
+		require.NoError(t, cmd.ParseFlags(flags), tc.args)
+		for name, expected := range tc.expected {
+			f := cmd.Flags().Lookup(name)
+			require.NotNil(t, f, name)
+			assert.Equal(t, expected, f.Value.String(), "%v: --%s", tc.args, name)
+		}
+	}
+
+	_, err := buildCLI(cli, []string{"--strict-config", "--config", writeConfigFile(t, "typo.yaml", "rat: 500")}, logging.NewNoopLogger())
+	assert.ErrorContains(t, err, `unknown field "rat"`)
+}
@@ -0,0 +1,739 @@
+package loadtest_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidateRate(t *testing.T) {
+	testCases := []struct {
+		rate        float64
+		expectError bool
+	}{
+		{0, true},
+		{-1, true},
+		{0.1, false},
+		{1, false},
+		{1000, false},
+	}
+	for _, tc := range testCases {
+		cfg := mockTestConfig("ws://localhost:26657/websocket")
+		cfg.Rate = tc.rate
+		err := cfg.Validate()
+		if tc.expectError {
+			assert.Error(t, err, "rate %v", tc.rate)
+		} else {
+			assert.NoError(t, err, "rate %v", tc.rate)
+		}
+	}
+}
+
+func TestConfigValidateDurations(t *testing.T) {
+	testCases := []struct {
+		time, sendPeriod time.Duration
+		expectError      bool
+	}{
+		{5 * time.Second, time.Second, false},
+		{5 * time.Second, 250 * time.Millisecond, false},
+		{time.Second, time.Millisecond, false},
+		{5 * time.Second, 0, true},
+		{5 * time.Second, time.Microsecond, true},
+		{5 * time.Second, 10 * time.Second, true},
+		{500 * time.Millisecond, 100 * time.Millisecond, true},
+	}
+	for _, tc := range testCases {
+		cfg := mockTestConfig("ws://localhost:26657/websocket")
+		cfg.Time = loadtest.Duration(tc.time)
+		cfg.SendPeriod = loadtest.Duration(tc.sendPeriod)
+		err := cfg.Validate()
+		if tc.expectError {
+			assert.Error(t, err, "time %s, send period %s", tc.time, tc.sendPeriod)
+		} else {
+			assert.NoError(t, err, "time %s, send period %s", tc.time, tc.sendPeriod)
+		}
+	}
+
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.HealthCheckTimeout = -seconds(1)
+	assert.Error(t, cfg.Validate())
+	cfg.HealthCheckTimeout = 0
+	cfg.EndpointRecoveryInterval = -seconds(1)
+	assert.Error(t, cfg.Validate())
+	cfg.EndpointRecoveryInterval = 0
+	cfg.PeerPollInterval = -seconds(1)
+	assert.Error(t, cfg.Validate())
+	cfg.PeerPollInterval = 0
+	// re-discovery requires endpoints to be discovered
+	cfg.RediscoveryInterval = seconds(60)
+	assert.Error(t, cfg.Validate())
+	cfg.EndpointSelectMethod = loadtest.SelectAnyEndpoints
+	assert.NoError(t, cfg.Validate())
+	// ...or DNS endpoints to be resolved again
+	cfg.EndpointSelectMethod = loadtest.SelectSuppliedEndpoints
+	cfg.Endpoints = []string{"dns+ws://validators.ns.svc:26657/websocket"}
+	assert.NoError(t, cfg.Validate())
+	cfg.DiscoveryExcludePatterns = []string{"sentry-("}
+	assert.Error(t, cfg.Validate())
+	cfg.DiscoveryExcludePatterns = []string{"^sentry-"}
+	assert.NoError(t, cfg.Validate())
+	cfg.EndpointSelectMethod = loadtest.SelectLowestLatencyEndpoints
+	cfg.PreferredEndpointCount = -1
+	assert.Error(t, cfg.Validate())
+	cfg.PreferredEndpointCount = 2
+	assert.NoError(t, cfg.Validate())
+
+	coordCfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 1, WorkerConnectTimeout: loadtest.Duration(500 * time.Millisecond)}
+	assert.NoError(t, coordCfg.Validate())
+	coordCfg.WorkerConnectTimeout = 0
+	assert.Error(t, coordCfg.Validate())
+	coordCfg.WorkerConnectTimeout = seconds(1)
+	coordCfg.ShutdownWait = -seconds(1)
+	assert.Error(t, coordCfg.Validate())
+
+	workerCfg := loadtest.WorkerConfig{CoordAddr: "ws://localhost:26670", CoordConnectTimeout: loadtest.Duration(500 * time.Millisecond)}
+	assert.NoError(t, workerCfg.Validate())
+	workerCfg.CoordConnectTimeout = 0
+	assert.Error(t, workerCfg.Validate())
+}
+
+func TestConfigValidateCountBounded(t *testing.T) {
+	testCases := []struct {
+		time        loadtest.Duration
+		count       int
+		maxRunTime  loadtest.Duration
+		expectError bool
+	}{
+		{0, 100, 0, false},
+		{-seconds(1), 100, 0, false},
+		{0, 100, seconds(60), false},
+		// both time and count unbounded
+		{0, -1, 0, true},
+		{-seconds(1), -1, seconds(60), true},
+		{-seconds(2), 100, 0, true},
+		{0, 100, -seconds(1), true},
+		// the cap must allow for at least one send period
+		{0, 100, loadtest.Duration(500 * time.Millisecond), true},
+	}
+	for _, tc := range testCases {
+		cfg := mockTestConfig("ws://localhost:26657/websocket")
+		cfg.Time = tc.time
+		cfg.Count = tc.count
+		cfg.MaxRunTime = tc.maxRunTime
+		err := cfg.Validate()
+		if tc.expectError {
+			assert.Error(t, err, "time %s, count %d, max run time %s", tc.time, tc.count, tc.maxRunTime)
+		} else {
+			assert.NoError(t, err, "time %s, count %d, max run time %s", tc.time, tc.count, tc.maxRunTime)
+		}
+	}
+}
+
+func TestConfigValidateEndpointRateLimits(t *testing.T) {
+	testCases := []struct {
+		endpoints   []string
+		limits      map[string]float64
+		ignore      bool
+		expectError bool
+	}{
+		{[]string{"ws://a:26657/websocket|maxrate=2000"}, nil, false, false},
+		{[]string{"ws://a:26657/websocket|maxrate=200"}, nil, false, true},
+		// the caps sum to less than the requested rate of 2000 tx/sec
+		{[]string{"ws://a:26657/websocket|maxrate=200", "ws://b:26657/websocket|maxrate=300"}, nil, false, true},
+		{[]string{"ws://a:26657/websocket|maxrate=200", "ws://b:26657/websocket|maxrate=300"}, nil, true, false},
+		// the uncapped endpoint takes up the shortfall
+		{[]string{"ws://a:26657/websocket|maxrate=200", "ws://b:26657/websocket"}, nil, false, false},
+		{[]string{"ws://a:26657/websocket", "ws://b:26657/websocket"}, map[string]float64{"ws://a:26657/websocket": 1, "ws://b:26657/websocket": 1}, false, true},
+		{[]string{"ws://a:26657/websocket"}, map[string]float64{"ws://a:26657/websocket": -1}, false, true},
+		{[]string{"ws://a:26657/websocket|maxrate=-5"}, nil, false, true},
+	}
+	for i, tc := range testCases {
+		cfg := mockTestConfig(tc.endpoints...)
+		cfg.Rate = 1000
+		cfg.EndpointRateLimits = tc.limits
+		cfg.IgnoreRateLimitShortfall = tc.ignore
+		err := cfg.Validate()
+		if tc.expectError {
+			assert.Error(t, err, "test case %d", i)
+		} else {
+			assert.NoError(t, err, "test case %d", i)
+		}
+	}
+}
+
+func TestConfigValidateRPCVersion(t *testing.T) {
+	for _, version := range []string{"", loadtest.RPCVersionAuto, loadtest.RPCVersionLegacy, loadtest.RPCVersionV1} {
+		cfg := mockTestConfig("ws://localhost:26657/websocket")
+		cfg.RPCVersion = version
+		assert.NoError(t, cfg.Validate(), "rpc version %q", version)
+	}
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.RPCVersion = "v2"
+	assert.Error(t, cfg.Validate())
+}
+
+func TestConfigValidateLogging(t *testing.T) {
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.LogLevel, cfg.LogFormat = "warn", "json"
+	assert.NoError(t, cfg.Validate())
+	cfg.LogLevel = "fatal"
+	assert.Error(t, cfg.Validate())
+	cfg.LogLevel, cfg.LogFormat = "", "logfmt"
+	assert.Error(t, cfg.Validate())
+
+	workerCfg := loadtest.WorkerConfig{CoordAddr: "ws://localhost:26670", CoordConnectTimeout: seconds(10), LogLevel: "verbose"}
+	assert.Error(t, workerCfg.Validate())
+}
+
+func TestConfigValidateLogFile(t *testing.T) {
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.LogFile, cfg.LogMaxSizeMB, cfg.LogMaxBackups, cfg.LogQuiet = "loadtest.log", 100, 5, true
+	assert.NoError(t, cfg.Validate())
+	cfg.LogMaxSizeMB = -1
+	assert.Error(t, cfg.Validate())
+	cfg.LogMaxSizeMB, cfg.LogMaxBackups = 100, -1
+	assert.Error(t, cfg.Validate())
+
+	// there'd be nowhere to log to
+	workerCfg := loadtest.WorkerConfig{CoordAddr: "ws://localhost:26670", CoordConnectTimeout: seconds(10), LogQuiet: true}
+	assert.Error(t, workerCfg.Validate())
+	workerCfg.LogFile = "worker.log"
+	assert.NoError(t, workerCfg.Validate())
+}
+
+func TestConfigValidateBroadcastLatencyBuckets(t *testing.T) {
+	testCases := []struct {
+		buckets     []float64
+		expectError bool
+	}{
+		{nil, false},
+		{[]float64{0.1}, false},
+		{[]float64{0.00001, 0.0001, 1}, false},
+		{[]float64{0, 1}, true},
+		{[]float64{1, 1}, true},
+		{[]float64{2, 1}, true},
+	}
+	for _, tc := range testCases {
+		cfg := mockTestConfig("ws://localhost:26657/websocket")
+		cfg.BroadcastLatencyBuckets = tc.buckets
+		err := cfg.Validate()
+		if tc.expectError {
+			assert.Error(t, err, "buckets %v", tc.buckets)
+		} else {
+			assert.NoError(t, err, "buckets %v", tc.buckets)
+		}
+	}
+}
+
+func TestConfigValidateInfluxDB(t *testing.T) {
+	testCases := []struct {
+		url, bucket string
+		expectError bool
+	}{
+		{"", "", false},
+		{"http://localhost:8086", "loadtests", false},
+		{"https://influx.example.com/prefix", "loadtests", false},
+		{"http://localhost:8086", "", true},
+		{"localhost:8086", "loadtests", true},
+	}
+	for _, tc := range testCases {
+		cfg := mockTestConfig("ws://localhost:26657/websocket")
+		cfg.InfluxDBURL = tc.url
+		cfg.InfluxDBBucket = tc.bucket
+		err := cfg.Validate()
+		if tc.expectError {
+			assert.Error(t, err, "url %q, bucket %q", tc.url, tc.bucket)
+		} else {
+			assert.NoError(t, err, "url %q, bucket %q", tc.url, tc.bucket)
+		}
+	}
+}
+
+func TestConfigValidateOTLP(t *testing.T) {
+	testCases := []struct {
+		endpoint    string
+		interval    int
+		expectError bool
+	}{
+		{"", 0, false},
+		{"http://localhost:4318", 10, false},
+		{"https://otel.example.com/v1/metrics", 1, false},
+		{"http://localhost:4318", 0, true},
+		{"localhost:4318", 10, true},
+	}
+	for _, tc := range testCases {
+		cfg := mockTestConfig("ws://localhost:26657/websocket")
+		cfg.OTLPEndpoint = tc.endpoint
+		cfg.OTLPExportInterval = seconds(tc.interval)
+		err := cfg.Validate()
+		if tc.expectError {
+			assert.Error(t, err, "endpoint %q, interval %d", tc.endpoint, tc.interval)
+		} else {
+			assert.NoError(t, err, "endpoint %q, interval %d", tc.endpoint, tc.interval)
+		}
+	}
+}
+
+func TestCoordinatorConfigValidateTLS(t *testing.T) {
+	cfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 1, WorkerConnectTimeout: seconds(1)}
+	assert.NoError(t, cfg.Validate())
+	cfg.TLSCertFile = "cert.pem"
+	assert.Error(t, cfg.Validate())
+	cfg.TLSKeyFile = "key.pem"
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestCoordinatorConfigValidateMaxWorkers(t *testing.T) {
+	cfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 2, WorkerConnectTimeout: seconds(1)}
+	assert.NoError(t, cfg.Validate())
+	cfg.MaxWorkers = 1
+	assert.Error(t, cfg.Validate())
+	cfg.MaxWorkers = -1
+	assert.Error(t, cfg.Validate())
+	cfg.MaxWorkers = 2
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestCoordinatorConfigValidateMinWorkers(t *testing.T) {
+	cfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 10, WorkerConnectTimeout: seconds(1), MinWorkers: 9, StartGracePeriod: seconds(30)}
+	assert.NoError(t, cfg.Validate())
+	cfg.MinWorkers = 11
+	assert.Error(t, cfg.Validate())
+	cfg.MinWorkers = -1
+	assert.Error(t, cfg.Validate())
+	cfg.MinWorkers = 0
+	cfg.StartGracePeriod = seconds(-1)
+	assert.Error(t, cfg.Validate())
+}
+
+func TestCoordinatorConfigValidateStartStagger(t *testing.T) {
+	cfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 2, WorkerConnectTimeout: seconds(1), StartStagger: loadtest.Duration(500 * time.Millisecond)}
+	assert.NoError(t, cfg.Validate())
+	cfg.StartStagger = loadtest.Duration(-500 * time.Millisecond)
+	assert.Error(t, cfg.Validate())
+}
+
+func TestCoordinatorConfigValidateResume(t *testing.T) {
+	cfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 1, WorkerConnectTimeout: seconds(1), Resume: true}
+	assert.Error(t, cfg.Validate())
+	cfg.StateFile = "state.json"
+	assert.NoError(t, cfg.Validate())
+	cfg.CheckpointInterval = seconds(-1)
+	assert.Error(t, cfg.Validate())
+}
+
+func TestCoordinatorConfigValidateHeartbeat(t *testing.T) {
+	cfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 2, WorkerConnectTimeout: seconds(1)}
+	assert.NoError(t, cfg.Validate())
+	cfg.HeartbeatInterval, cfg.HeartbeatTimeout = seconds(2), seconds(5)
+	assert.NoError(t, cfg.Validate())
+	cfg.HeartbeatTimeout = seconds(2)
+	assert.Error(t, cfg.Validate())
+	// the timeout must exceed the default interval too
+	cfg.HeartbeatInterval, cfg.HeartbeatTimeout = 0, seconds(1)
+	assert.Error(t, cfg.Validate())
+	cfg.HeartbeatInterval, cfg.HeartbeatTimeout = seconds(-1), 0
+	assert.Error(t, cfg.Validate())
+}
+
+func TestCoordinatorConfigValidateShards(t *testing.T) {
+	cfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 2, WorkerConnectTimeout: seconds(1), RedistributeShards: true}
+	assert.Error(t, cfg.Validate())
+	cfg.ShardEndpoints = true
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestWorkerConfigValidateLabels(t *testing.T) {
+	cfg := loadtest.WorkerConfig{ID: "worker0", CoordAddr: "ws://localhost:26670", CoordConnectTimeout: seconds(1)}
+	assert.NoError(t, cfg.Validate())
+	cfg.Labels = map[string]string{"region": "eu-west-1", "instance_type": "c5.large", "_zone": ""}
+	assert.NoError(t, cfg.Validate())
+	for _, name := range []string{"", "instance-type", "1zone", "__name", "worker"} {
+		cfg.Labels = map[string]string{name: "x"}
+		assert.Error(t, cfg.Validate(), name)
+	}
+}
+
+func TestCoordinatorConfigValidateWorkerOverrides(t *testing.T) {
+	cfg := loadtest.Config{
+		ClientFactory:        "kvstore",
+		Connections:          1,
+		Time:                 seconds(5),
+		SendPeriod:           seconds(1),
+		Rate:                 10,
+		Size:                 100,
+		Count:                -1,
+		BroadcastTxMethod:    "async",
+		Endpoints:            []string{"ws://localhost:26657/websocket"},
+		EndpointSelectMethod: loadtest.SelectSuppliedEndpoints,
+	}
+	require.NoError(t, cfg.Validate())
+	coordCfg := loadtest.CoordinatorConfig{
+		BindAddr:             "localhost:26670",
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(1),
+		WorkerOverrides: map[string]loadtest.WorkerOverride{
+			"worker0": {Endpoints: []string{"ws://eu:26657/websocket|maxrate=50"}, Rate: 20},
+		},
+	}
+	assert.NoError(t, coordCfg.Validate())
+	assert.NoError(t, coordCfg.ValidateWorkerOverrides(cfg))
+
+	// every merged configuration must be valid
+	coordCfg.WorkerOverrides["worker1"] = loadtest.WorkerOverride{Endpoints: []string{"ws://us:26657/websocket|maxrate=abc"}}
+	assert.NoError(t, coordCfg.Validate())
+	assert.Error(t, coordCfg.ValidateWorkerOverrides(cfg))
+	// the base rate exceeds the overriding endpoint's rate limit
+	coordCfg.WorkerOverrides["worker1"] = loadtest.WorkerOverride{Endpoints: []string{"ws://us:26657/websocket|maxrate=1"}}
+	assert.Error(t, coordCfg.ValidateWorkerOverrides(cfg))
+
+	delete(coordCfg.WorkerOverrides, "worker1")
+	coordCfg.WorkerOverrides["Worker-1"] = loadtest.WorkerOverride{Rate: 1}
+	assert.Error(t, coordCfg.Validate())
+	delete(coordCfg.WorkerOverrides, "Worker-1")
+	coordCfg.WorkerOverrides["worker1"] = loadtest.WorkerOverride{Connections: -1}
+	assert.Error(t, coordCfg.Validate())
+	delete(coordCfg.WorkerOverrides, "worker1")
+
+	// overrides can also be keyed by worker label selectors
+	coordCfg.WorkerOverrides["region=us,instance=c5"] = loadtest.WorkerOverride{Endpoints: []string{"ws://us:26657/websocket"}}
+	assert.NoError(t, coordCfg.Validate())
+	assert.NoError(t, coordCfg.ValidateWorkerOverrides(cfg))
+	coordCfg.WorkerOverrides["region=us,instance=c5"] = loadtest.WorkerOverride{Endpoints: []string{"ws://us:26657/websocket|maxrate=1"}}
+	assert.ErrorContains(t, coordCfg.ValidateWorkerOverrides(cfg), "invalid configuration for workers matching region=us,instance=c5")
+	delete(coordCfg.WorkerOverrides, "region=us,instance=c5")
+	coordCfg.WorkerOverrides["region="] = loadtest.WorkerOverride{Rate: 1}
+	assert.ErrorContains(t, coordCfg.Validate(), "invalid worker label selector")
+}
+
+func TestCoordinatorConfigValidateTotalRate(t *testing.T) {
+	coordCfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 2, WorkerConnectTimeout: seconds(1), TotalRate: 1000}
+	assert.NoError(t, coordCfg.Validate())
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	// the coordinator works out each worker's rate from the total
+	assert.Error(t, coordCfg.ValidateConfig(cfg))
+	cfg.Rate = 0
+	assert.NoError(t, coordCfg.ValidateConfig(cfg))
+	assert.Error(t, cfg.Validate())
+	// and neither runs nor workers can have rates of their own
+	cfg.Runs = []json.RawMessage{json.RawMessage(`{"time":10}`)}
+	assert.NoError(t, coordCfg.ValidateConfig(cfg))
+	cfg.Runs = []json.RawMessage{json.RawMessage(`{"rate":10}`)}
+	assert.Error(t, coordCfg.ValidateConfig(cfg))
+	cfg.Runs = nil
+	coordCfg.WorkerOverrides = map[string]loadtest.WorkerOverride{"worker0": {Connections: 2}}
+	assert.NoError(t, coordCfg.Validate())
+	assert.NoError(t, coordCfg.ValidateWorkerOverrides(cfg))
+	coordCfg.WorkerOverrides["worker0"] = loadtest.WorkerOverride{Rate: 20}
+	assert.Error(t, coordCfg.Validate())
+	assert.Error(t, coordCfg.ValidateWorkerOverrides(cfg))
+
+	coordCfg.WorkerOverrides = nil
+	coordCfg.TotalRate = -1
+	assert.Error(t, coordCfg.Validate())
+}
+
+func TestConfigValidateMaxTotalLoad(t *testing.T) {
+	// 2 endpoints x 2 connections x 10 txs/sec x 5s of 100-byte transactions
+	cfg := mockTestConfig("ws://node0:26657/websocket", "ws://node1:26657/websocket")
+	cfg.Connections = 2
+	cfg.Count = -1
+	cfg.MaxTotalTxs = 200
+	cfg.MaxTotalBytes = 20000
+	assert.NoError(t, cfg.Validate())
+
+	cfg.MaxTotalTxs = 199
+	assert.EqualError(t, cfg.Validate(), "the load test would send 200 transactions in total, which exceeds max-total-txs of 199")
+	cfg.MaxTotalTxs = 0
+	cfg.MaxTotalBytes = 19999
+	assert.EqualError(t, cfg.Validate(), "the load test would send 20000 transaction bytes in total, which exceeds max-total-bytes of 19999")
+
+	// each connection sends at most Count transactions
+	cfg.Count = 10
+	cfg.MaxTotalTxs = 40
+	cfg.MaxTotalBytes = 4000
+	assert.NoError(t, cfg.Validate())
+
+	// the confirmation thresholds don't fail validation
+	cfg.ConfirmTotalTxs = 1
+	assert.NoError(t, cfg.Validate())
+	cfg.ConfirmTotalTxs = -1
+	assert.Error(t, cfg.Validate())
+	cfg.ConfirmTotalTxs = 0
+	cfg.MaxTotalBytes = -1
+	assert.Error(t, cfg.Validate())
+}
+
+func TestCoordinatorConfigValidateMaxTotalLoad(t *testing.T) {
+	coordCfg := loadtest.CoordinatorConfig{BindAddr: "localhost:26670", ExpectWorkers: 3, WorkerConnectTimeout: seconds(1)}
+	// each worker sends 10 txs/sec x 5s, so 150 transactions between them
+	cfg := mockTestConfig("ws://node0:26657/websocket")
+	cfg.Count = -1
+	cfg.MaxTotalTxs = 150
+	assert.NoError(t, coordCfg.ValidateConfig(cfg))
+	cfg.MaxTotalTxs = 149
+	// which a single worker's load is well within
+	assert.NoError(t, cfg.Validate())
+	assert.EqualError(t, coordCfg.ValidateConfig(cfg), "the load test would send 150 transactions in total, which exceeds max-total-txs of 149")
+	cfg.MaxTotalTxs = 0
+	cfg.MaxTotalBytes = 14999
+	assert.EqualError(t, coordCfg.ValidateConfig(cfg), "the load test would send 15000 transaction bytes in total, which exceeds max-total-bytes of 14999")
+
+	// the heaviest workers with overrides are assumed to take part
+	cfg.MaxTotalBytes = 0
+	cfg.MaxTotalTxs = 249
+	coordCfg.WorkerOverrides = map[string]loadtest.WorkerOverride{
+		"worker0": {Rate: 20},
+		"worker1": {Connections: 2},
+		"worker2": {Rate: 5},
+		"worker3": {Endpoints: []string{"ws://eu0:26657/websocket", "ws://eu1:26657/websocket"}},
+	}
+	assert.NoError(t, coordCfg.ValidateWorkerOverrides(cfg))
+	assert.EqualError(t, coordCfg.ValidateConfig(cfg), "the load test would send 300 transactions in total, which exceeds max-total-txs of 249")
+	coordCfg.WorkerOverrides = nil
+
+	// with sharded endpoints, each worker only sends to its share
+	cfg.Endpoints = []string{"ws://node0:26657/websocket", "ws://node1:26657/websocket", "ws://node2:26657/websocket"}
+	cfg.MaxTotalTxs = 150
+	assert.Error(t, coordCfg.ValidateConfig(cfg))
+	coordCfg.ShardEndpoints = true
+	assert.NoError(t, coordCfg.ValidateConfig(cfg))
+	coordCfg.ShardEndpoints = false
+
+	// a total rate applies however many workers take part
+	coordCfg.TotalRate = 30
+	cfg.Rate = 0
+	cfg.Count = 1000
+	cfg.MaxTotalTxs = 149
+	assert.EqualError(t, coordCfg.ValidateConfig(cfg), "the load test would send 150 transactions in total, which exceeds max-total-txs of 149")
+	coordCfg.ExpectWorkers = 10
+	assert.EqualError(t, coordCfg.ValidateConfig(cfg), "the load test would send 150 transactions in total, which exceeds max-total-txs of 149")
+}
+
+func TestLoadWorkerOverrides(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "overrides.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{"worker0":{"endpoints":["ws://eu:26657/websocket"],"rate":20,"connections":2}}`), 0o644))
+	overrides, err := loadtest.LoadWorkerOverrides(filename)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]loadtest.WorkerOverride{
+		"worker0": {Endpoints: []string{"ws://eu:26657/websocket"}, Rate: 20, Connections: 2},
+	}, overrides)
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"worker0":{"time":20}}`), 0o644))
+	_, err = loadtest.LoadWorkerOverrides(filename)
+	assert.Error(t, err)
+}
+
+func TestConfigEndpointPaths(t *testing.T) {
+	cfg := mockTestConfig(
+		"ws://node0:26657",
+		"wss://node1:443/|maxrate=100",
+		"ws://node2:26657/websocket",
+		"ws://node3:8080/custom/ws|weight=2",
+		"ws://node4:26657/v1/websocket",
+		"dns+ws://validators.ns.svc:26657",
+	)
+	require.NoError(t, cfg.Validate())
+	require.NoError(t, cfg.ParseEndpointRateLimits())
+	assert.Equal(t, []string{
+		"ws://node0:26657/websocket",
+		"wss://node1:443/websocket",
+		"ws://node2:26657/websocket",
+		"ws://node3:8080/custom/ws",
+		"ws://node4:26657/v1/websocket",
+		"dns+ws://validators.ns.svc:26657/websocket",
+	}, cfg.Endpoints)
+	assert.Equal(t, map[string]float64{"wss://node1:443/websocket": 100}, cfg.EndpointRateLimits)
+	assert.Equal(t, map[string]float64{"ws://node3:8080/custom/ws": 2}, cfg.EndpointWeights)
+
+	testCases := []struct {
+		endpoint    string
+		expectError string
+	}{
+		{"ws://node0:26657", "endpoint ws://node0:26657 has no path: expected the node's WebSockets RPC endpoint, e.g. ws://node0:26657/websocket"},
+		{"wss://node1:443/|maxrate=100", "e.g. wss://node1:443/websocket"},
+		{"ws://node2:26657/websocket", ""},
+		{"ws://node3:8080/custom/ws", ""},
+	}
+	for _, tc := range testCases {
+		cfg := mockTestConfig(tc.endpoint)
+		cfg.StrictEndpoints = true
+		err := cfg.Validate()
+		if len(tc.expectError) == 0 {
+			assert.NoError(t, err, tc.endpoint)
+			continue
+		}
+		require.Error(t, err, tc.endpoint)
+		assert.Contains(t, err.Error(), tc.expectError, tc.endpoint)
+	}
+}
+
+func TestConfigEndpointNames(t *testing.T) {
+	cfg := mockTestConfig(
+		"ws://10.0.0.5:26657/websocket|name=validator-eu-1",
+		"ws://10.0.0.6:26657/websocket|weight=2|name=validator-eu-2",
+		"ws://10.0.0.7:26657/websocket",
+	)
+	require.NoError(t, cfg.Validate())
+	require.NoError(t, cfg.ParseEndpointRateLimits())
+	assert.Equal(t, []string{"ws://10.0.0.5:26657/websocket", "ws://10.0.0.6:26657/websocket", "ws://10.0.0.7:26657/websocket"}, cfg.Endpoints)
+	assert.Equal(t, map[string]string{
+		"ws://10.0.0.5:26657/websocket": "validator-eu-1",
+		"ws://10.0.0.6:26657/websocket": "validator-eu-2",
+	}, cfg.EndpointNames)
+	// parsing again must keep the names
+	require.NoError(t, cfg.ParseEndpointRateLimits())
+	assert.Len(t, cfg.EndpointNames, 2)
+	require.NoError(t, cfg.Validate())
+
+	// the names must survive being sent to workers
+	var received loadtest.Config
+	require.NoError(t, json.Unmarshal([]byte(cfg.ToJSON()), &received))
+	assert.Equal(t, cfg.EndpointNames, received.EndpointNames)
+
+	testCases := []struct {
+		endpoints   []string
+		names       map[string]string
+		expectError string
+	}{
+		{[]string{"ws://a:26657/websocket|name=validator", "ws://b:26657/websocket|name=validator"}, nil, `endpoints ws://a:26657/websocket and ws://b:26657/websocket have the same name "validator"`},
+		{[]string{"ws://a:26657/websocket|name=validator", "ws://b:26657/websocket"}, map[string]string{"ws://b:26657/websocket": "validator"}, "have the same name"},
+		{[]string{"ws://a:26657/websocket"}, map[string]string{"ws://a:26657/websocket": "eu validator"}, "invalid name for endpoint ws://a:26657/websocket"},
+		{[]string{"ws://a:26657/websocket|name=validator[0]"}, nil, "invalid name for endpoint ws://a:26657/websocket"},
+		// the same name may be given again to the same endpoint
+		{[]string{"ws://a:26657/websocket|name=validator"}, map[string]string{"ws://a:26657/websocket": "validator"}, ""},
+	}
+	for i, tc := range testCases {
+		cfg := mockTestConfig(tc.endpoints...)
+		cfg.EndpointNames = tc.names
+		err := cfg.Validate()
+		if len(tc.expectError) == 0 {
+			assert.NoError(t, err, "test case %d", i)
+			continue
+		}
+		require.Error(t, err, "test case %d", i)
+		assert.Contains(t, err.Error(), tc.expectError, "test case %d", i)
+	}
+}
+
+func TestConfigLoadEndpointsFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "endpoints.txt")
+	require.NoError(t, os.WriteFile(filename, []byte(`# validators
+ws://node0:26657/websocket|weight=3
+
+  ws://node1:26657/websocket|maxrate=200
+ws://node2:26657/websocket
+`), 0o644))
+	cfg := mockTestConfig("ws://node2:26657/websocket")
+	cfg.EndpointsFile = filename
+	require.NoError(t, cfg.LoadEndpointsFile(nil))
+	assert.Equal(t, []string{
+		"ws://node2:26657/websocket",
+		"ws://node0:26657/websocket|weight=3",
+		"ws://node1:26657/websocket|maxrate=200",
+	}, cfg.Endpoints)
+	assert.Empty(t, cfg.EndpointsFile)
+	require.NoError(t, cfg.Validate())
+
+	// loading again is a no-op
+	require.NoError(t, cfg.LoadEndpointsFile(nil))
+	assert.Len(t, cfg.Endpoints, 3)
+
+	cfg = mockTestConfig()
+	cfg.EndpointsFile = "-"
+	require.NoError(t, cfg.LoadEndpointsFile(strings.NewReader("ws://node0:26657/websocket\n\nws://node1:26657/websocket\n")))
+	assert.Equal(t, []string{"ws://node0:26657/websocket", "ws://node1:26657/websocket"}, cfg.Endpoints)
+}
+
+func TestConfigLoadEndpointsFileErrors(t *testing.T) {
+	testCases := []struct {
+		contents    string
+		endpoints   []string
+		expectError string
+	}{
+		{"ws://node0:26657/websocket\nws://node1:26657/websocket|maxrate=x\n", nil, "<stdin>:2: invalid maxrate"},
+		{"# comment\n\nhttp://node0:26657\n", nil, "<stdin>:3: invalid endpoint http://node0:26657"},
+		{"ws://node0:26657/websocket|weight=2\n", []string{"ws://node0:26657/websocket"}, "<stdin>:1: endpoint ws://node0:26657/websocket is already listed"},
+	}
+	for i, tc := range testCases {
+		cfg := mockTestConfig(tc.endpoints...)
+		cfg.EndpointsFile = "-"
+		err := cfg.LoadEndpointsFile(strings.NewReader(tc.contents))
+		require.Error(t, err, "test case %d", i)
+		assert.Contains(t, err.Error(), tc.expectError, "test case %d", i)
+	}
+
+	cfg := mockTestConfig()
+	cfg.EndpointsFile = filepath.Join(t.TempDir(), "missing.txt")
+	assert.Error(t, cfg.LoadEndpointsFile(nil))
+}
+
+func TestConfigRunConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "runs.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`[{"rate":20},{"time":10,"connections":2}]`), 0o644))
+	runs, err := loadtest.LoadRuns(filename)
+	require.NoError(t, err)
+
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.Runs = runs
+	require.NoError(t, cfg.Validate())
+	run0, err := cfg.RunConfig(0)
+	require.NoError(t, err)
+	assert.Equal(t, float64(20), run0.Rate)
+	assert.Equal(t, seconds(5), run0.Time)
+	assert.Nil(t, run0.Runs)
+	run1, err := cfg.RunConfig(1)
+	require.NoError(t, err)
+	assert.Equal(t, float64(10), run1.Rate)
+	assert.Equal(t, seconds(10), run1.Time)
+	assert.Equal(t, 2, run1.Connections)
+
+	// durations can be overridden either as duration strings or as (deprecated)
+	// bare numbers of seconds
+	cfg.Runs = []json.RawMessage{json.RawMessage(`{"time":"1m30s","send_period":"250ms"}`), json.RawMessage(`{"time":2.5}`)}
+	run0, err = cfg.RunConfig(0)
+	require.NoError(t, err)
+	assert.Equal(t, loadtest.Duration(90*time.Second), run0.Time)
+	assert.Equal(t, loadtest.Duration(250*time.Millisecond), run0.SendPeriod)
+	run1, err = cfg.RunConfig(1)
+	require.NoError(t, err)
+	assert.Equal(t, loadtest.Duration(2500*time.Millisecond), run1.Time)
+	cfg.Runs = []json.RawMessage{json.RawMessage(`{"time":"soon"}`)}
+	_, err = cfg.RunConfig(0)
+	assert.Error(t, err)
+	_, err = cfg.RunConfig(2)
+	assert.Error(t, err)
+
+	// each run's overrides must be valid configuration
+	for _, run := range []string{`{"rate":-1}`, `{"duration":1}`, `{"runs":[{}]}`, `[]`} {
+		cfg.Runs = []json.RawMessage{json.RawMessage(run)}
+		assert.Error(t, cfg.Validate(), run)
+	}
+}
+
+func TestConfigValidateStrictFeasibility(t *testing.T) {
+	// 10000 transactions per second over a single connection don't fit into
+	// a send period
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.Rate = 10000
+	assert.NoError(t, cfg.Validate())
+	cfg.StrictFeasibility = true
+	assert.ErrorContains(t, cfg.Validate(), "transaction rate is unachievable")
+	// a dry run calibrates the cost of transactions instead, unless skipped
+	cfg.DryRun = true
+	assert.NoError(t, cfg.Validate())
+	cfg.SkipCalibration = true
+	assert.Error(t, cfg.Validate())
+
+	cfg.DryRun, cfg.SkipCalibration = false, false
+	cfg.Connections = 2
+	cfg.Rate = 5000
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,33 @@
+package loadtest
+
+import (
+	"context"
+	"sync"
+)
+
+// afterFunc calls f in its own goroutine once the given context is done,
+// unless the returned function is called first, after which f is guaranteed
+// not to be called. The returned function must be called to release the
+// goroutine, and is safe to call more than once. (A stand-in for Go 1.21's
+// context.AfterFunc.)
+func afterFunc(ctx context.Context, f func()) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	var once sync.Once
+	stopc := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			once.Do(f)
+		case <-stopc:
+		}
+	}()
+	var stopOnce sync.Once
+	return func() {
+		stopOnce.Do(func() {
+			once.Do(func() {})
+			close(stopc)
+		})
+	}
+}
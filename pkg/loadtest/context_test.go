@@ -0,0 +1,176 @@
+package loadtest_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestCoordinatorRunWithContext(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	ignore := goleak.IgnoreCurrent()
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(10),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	coordErrs := make(chan error, 1)
+	go func() { coordErrs <- coord.RunWithContext(ctx) }()
+	workerErrs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  fmt.Sprintf("worker%d", i),
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		go func() { workerErrs <- worker.RunWithContext(context.Background()) }()
+	}
+	waitForRequests(t, svr)
+	cancel()
+
+	// the coordinator cancels the load test, as if interrupted
+	select {
+	case err := <-coordErrs:
+		require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for coordinator to stop")
+	}
+	for i := 0; i < 2; i++ {
+		require.ErrorIs(t, <-workerErrs, loadtest.ErrLoadTestCancelled)
+	}
+	report := readJSONReport(t, cfg.StatsOutputFile)
+	require.Equal(t, loadtest.StatsStatusCancelled, report.Aggregate.Status)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+	goleak.VerifyNone(t, ignore)
+}
+
+// A worker told to stop mid-test reports on what it sent until then, as if
+// the coordinator had cancelled its load test.
+func TestWorkerStop(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	ignore := goleak.IgnoreCurrent()
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErrs := make(chan error, 1)
+	go func() { coordErrs <- coord.Run() }()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	workerErrs := make(chan error, 1)
+	go func() { workerErrs <- worker.Run() }()
+	waitForRequests(t, svr)
+	worker.Stop()
+	worker.Stop()
+
+	select {
+	case err := <-workerErrs:
+		require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for worker to stop")
+	}
+	select {
+	case err := <-coordErrs:
+		require.NoError(t, err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for load test to complete")
+	}
+	report := readJSONReport(t, cfg.StatsOutputFile)
+	require.Len(t, report.Workers, 1)
+	require.Equal(t, svr.Requests(), report.Workers[0].TotalTxs)
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestWorkerRunWithContextWhileConnecting(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + freeLocalAddr(t),
+		CoordConnectTimeout: seconds(60),
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	err = worker.RunWithContext(ctx)
+	require.Error(t, err)
+	require.Equal(t, loadtest.FailureCancelled, loadtest.ClassifyError(err))
+	require.Less(t, time.Since(start), 10*time.Second)
+	goleak.VerifyNone(t, ignore)
+}
+
+func TestStandaloneWithContext(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	ignore := goleak.IgnoreCurrent()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- loadtest.ExecuteStandaloneWithContext(ctx, cfg) }()
+	waitForRequests(t, svr)
+	cancel()
+	select {
+	case err := <-errs:
+		require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for load test to be cancelled")
+	}
+	report := readJSONReport(t, cfg.StatsOutputFile)
+	require.Equal(t, loadtest.StatsStatusCancelled, report.Aggregate.Status)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+	goleak.VerifyNone(t, ignore)
+}
+
+// waitForRequests waits for the load test to be underway, with transactions
+// arriving at the given endpoint.
+func waitForRequests(t *testing.T, svr *mockRPCServer) {
+	deadline := time.Now().Add(20 * time.Second)
+	for svr.Requests() == 0 {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for load test to start")
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(time.Second)
+}
+
+func readJSONReport(t *testing.T, filename string) loadtest.Report {
+	b, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	return report
+}
@@ -0,0 +1,333 @@
+package loadtest
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The maximum size of a request body accepted by the control API.
+const controlMaxBodySize = 1 << 20
+
+// The names of the coordinator's states, as reported by the control API.
+var coordStateNames = map[int]string{
+	coordStarting:          "starting",
+	coordWaitingForPeers:   "waiting_for_peers",
+	coordWaitingForWorkers: "waiting_for_workers",
+	coordTesting:           "testing",
+	coordFailed:            "failed",
+	coordCompleted:         "completed",
+	coordPaused:            "paused",
+	coordCancelled:         "cancelled",
+}
+
+// TestStatus is the coordinator's current status, as served as JSON by its
+// control API at /v1/test/status.
+type TestStatus struct {
+	Phase            string     `json:"phase"`              // The coordinator's current phase: "starting", "waiting_for_peers", "waiting_for_workers", "testing", "paused", "failed", "cancelled" or "completed".
+	ManualStart      bool       `json:"manual_start"`       // Whether the load test only starts once requested via the control API.
+	ExpectWorkers    int        `json:"expect_workers"`     // The number of workers expected to take part in the load test.
+	MinWorkers       int        `json:"min_workers"`        // The number of workers required to start the load test.
+	ConnectedWorkers int        `json:"connected_workers"`  // The number of workers currently connected to the coordinator.
+	Progress         *LiveStats `json:"progress,omitempty"` // The load test's progress, once it has started.
+}
+
+// controlStartRequest is a request, via the control API, to start the load
+// test.
+type controlStartRequest struct {
+	cfg  *Config // The configuration with which to run the load test, if overridden.
+	resp chan error
+}
+
+// controlPauseRequest is a request, via the control API, to pause or resume the
+// load test.
+type controlPauseRequest struct {
+	pause bool // Whether to pause (rather than resume) the load test.
+	resp  chan error
+}
+
+// controlShutdownRequest is a request, via the control API, to shut down the
+// connected workers.
+type controlShutdownRequest struct {
+	force bool // Whether to shut down workers even in the middle of the load test.
+	resp  chan error
+}
+
+// handleTestStart starts the load test, optionally overriding parts of its
+// configuration with those given as JSON in the request body. The load test
+// can only be started once the minimum number of workers have connected.
+func (c *Coordinator) handleTestStart(w http.ResponseWriter, r *http.Request) {
+	if !c.authorizeControl(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if phase := c.getState(); phase != coordWaitingForWorkers {
+		writeControlError(w, http.StatusConflict, fmt.Errorf("cannot start load test while %s", coordStateNames[phase]))
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, controlMaxBodySize))
+	if err != nil {
+		writeControlError(w, http.StatusBadRequest, err)
+		return
+	}
+	req := controlStartRequest{resp: make(chan error, 1)}
+	if len(bytes.TrimSpace(body)) > 0 {
+		cfg := c.config()
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			writeControlError(w, http.StatusBadRequest, fmt.Errorf("invalid configuration overrides: %w", err))
+			return
+		}
+		if len(cfg.Runs) > 0 {
+			writeControlError(w, http.StatusBadRequest, fmt.Errorf("invalid configuration overrides: runs can't be overridden once the coordinator is running"))
+			return
+		}
+		if err := c.coordCfg.ValidateConfig(cfg); err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := cfg.ParseEndpointRateLimits(); err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := c.coordCfg.ValidateWorkerOverrides(cfg); err != nil {
+			writeControlError(w, http.StatusBadRequest, err)
+			return
+		}
+		req.cfg = &cfg
+	}
+
+	select {
+	case c.startRequest <- req:
+	case <-time.After(10 * time.Second):
+		writeControlError(w, http.StatusServiceUnavailable, fmt.Errorf("timed out waiting for coordinator"))
+		return
+	}
+	if err := <-req.resp; err != nil {
+		writeControlError(w, http.StatusConflict, err)
+		return
+	}
+	c.writeTestStatus(w, http.StatusAccepted)
+}
+
+// handleTestCancel cancels the load test (or the wait for it to start). A load
+// test that's underway still has its statistics written, marked as cancelled.
+func (c *Coordinator) handleTestCancel(w http.ResponseWriter, r *http.Request) {
+	if !c.authorizeControl(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	if phase := c.getState(); phase == coordFailed || phase == coordCompleted || phase == coordCancelled {
+		writeControlError(w, http.StatusConflict, fmt.Errorf("load test has already %s", coordStateNames[phase]))
+		return
+	}
+	c.logger.Info("Load test cancelled via control API")
+	c.cancel()
+	c.writeTestStatus(w, http.StatusAccepted)
+}
+
+// handleTestPause pauses the load test: workers stop sending transactions, but
+// keep their connections open, until the load test is resumed.
+func (c *Coordinator) handleTestPause(w http.ResponseWriter, r *http.Request) {
+	c.handlePauseControl(w, r, true)
+}
+
+// handleTestResume resumes a paused load test.
+func (c *Coordinator) handleTestResume(w http.ResponseWriter, r *http.Request) {
+	c.handlePauseControl(w, r, false)
+}
+
+func (c *Coordinator) handlePauseControl(w http.ResponseWriter, r *http.Request, pause bool) {
+	if !c.authorizeControl(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	action := "resume"
+	if pause {
+		action = "pause"
+	}
+	if phase := c.getState(); phase != coordTesting && phase != coordPaused {
+		writeControlError(w, http.StatusConflict, fmt.Errorf("cannot %s load test while %s", action, coordStateNames[phase]))
+		return
+	}
+	req := controlPauseRequest{pause: pause, resp: make(chan error, 1)}
+	select {
+	case c.pauseRequest <- req:
+	case <-time.After(10 * time.Second):
+		writeControlError(w, http.StatusServiceUnavailable, fmt.Errorf("timed out waiting for coordinator"))
+		return
+	}
+	if err := <-req.resp; err != nil {
+		writeControlError(w, http.StatusConflict, err)
+		return
+	}
+	c.writeTestStatus(w, http.StatusAccepted)
+}
+
+// handleWorkersShutdown tells the connected workers to shut down, so that they
+// exit cleanly. Workers in the middle of the load test only shut down if forced
+// to (via ?force=true), in which case the load test is cancelled.
+func (c *Coordinator) handleWorkersShutdown(w http.ResponseWriter, r *http.Request) {
+	if !c.authorizeControl(w, r) {
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	force, err := parseBoolParam(r, "force")
+	if err != nil {
+		writeControlError(w, http.StatusBadRequest, err)
+		return
+	}
+	if phase := c.getState(); phase != coordWaitingForWorkers && phase != coordTesting && phase != coordPaused {
+		writeControlError(w, http.StatusConflict, fmt.Errorf("cannot shut down workers while %s", coordStateNames[phase]))
+		return
+	}
+	req := controlShutdownRequest{force: force, resp: make(chan error, 1)}
+	select {
+	case c.shutdownRequest <- req:
+	case <-time.After(10 * time.Second):
+		writeControlError(w, http.StatusServiceUnavailable, fmt.Errorf("timed out waiting for coordinator"))
+		return
+	}
+	if err := <-req.resp; err != nil {
+		writeControlError(w, http.StatusConflict, err)
+		return
+	}
+	c.writeTestStatus(w, http.StatusAccepted)
+}
+
+// handleTestStatus serves the coordinator's current status.
+func (c *Coordinator) handleTestStatus(w http.ResponseWriter, r *http.Request) {
+	if !c.authorizeControl(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	c.writeTestStatus(w, http.StatusOK)
+}
+
+// handleStartRequest handles a request to start the load test, applying any
+// configuration overrides. Must only be called from the coordinator's event
+// loop while waiting for workers.
+func (c *Coordinator) handleStartRequest(req controlStartRequest) error {
+	if connected, required := len(c.workers), c.coordCfg.minWorkers(); connected < required {
+		return fmt.Errorf("only %d of %d workers have connected", connected, required)
+	}
+	if req.cfg != nil {
+		c.setConfig(*req.cfg)
+		c.logger.Info("Applied configuration overrides", "cfg", c.cfg.ToJSON())
+	}
+	// the response must reflect that the load test is underway
+	c.setState(coordTesting)
+	return nil
+}
+
+// handlePauseRequest pauses or resumes the load test, relaying the request to
+// all connected workers. Must only be called from the coordinator's event loop
+// during the load test.
+func (c *Coordinator) handlePauseRequest(req controlPauseRequest) error {
+	now := time.Now()
+	if req.pause {
+		if !c.pauseClk.pause(now) {
+			return fmt.Errorf("load test is already paused")
+		}
+		c.setState(coordPaused)
+		c.logger.Info("Load test paused via control API")
+	} else {
+		if !c.pauseClk.resume(now) {
+			return fmt.Errorf("load test is not paused")
+		}
+		c.setState(coordTesting)
+		c.logger.Info("Load test resumed via control API", "totalPaused", c.pauseClk.pausedDuration(now).Round(time.Millisecond).String())
+	}
+	for _, rw := range c.workers {
+		rw.SetPaused(req.pause)
+	}
+	c.publishLiveStats(false)
+	return nil
+}
+
+// handleShutdownRequest shuts down the connected workers in the middle of the
+// load test, which is only possible if forced, by cancelling the load test.
+// The workers still report the statistics they gathered until then before
+// shutting down. Must only be called from the coordinator's event loop during
+// the load test.
+func (c *Coordinator) handleShutdownRequest(req controlShutdownRequest) error {
+	if !req.force {
+		return fmt.Errorf("workers are in the middle of the load test - shutting them down must be forced")
+	}
+	c.logger.Info("Shutting down workers via control API - cancelling load test", "connected", len(c.workers))
+	c.shuttingDownWorkers = true
+	c.cancel()
+	return nil
+}
+
+// authorizeControl checks that a control API request presents the shared auth
+// token as a bearer token, if the coordinator requires one, responding with
+// 401 if it doesn't.
+func (c *Coordinator) authorizeControl(w http.ResponseWriter, r *http.Request) bool {
+	if len(c.coordCfg.AuthToken) == 0 {
+		return true
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(c.coordCfg.AuthToken)) == 1 {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	writeControlError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing auth token"))
+	return false
+}
+
+func (c *Coordinator) writeTestStatus(w http.ResponseWriter, status int) {
+	progress, connected := c.currentLiveStats()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(TestStatus{
+		Phase:            coordStateNames[c.getState()],
+		ManualStart:      c.coordCfg.ManualStart,
+		ExpectWorkers:    c.coordCfg.ExpectWorkers,
+		MinWorkers:       c.coordCfg.minWorkers(),
+		ConnectedWorkers: connected,
+		Progress:         progress,
+	})
+}
+
+// parseBoolParam parses the given boolean query parameter, which is false if
+// absent.
+func parseBoolParam(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if len(value) == 0 {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s parameter: %q", name, value)
+	}
+	return b, nil
+}
+
+func writeControlError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
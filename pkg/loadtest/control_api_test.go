@@ -0,0 +1,181 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newControlTestCoordinator(authToken string) *Coordinator {
+	return NewCoordinator(
+		&Config{ClientFactory: "kvstore", Connections: 1, Time: Duration(5 * time.Second), SendPeriod: Duration(time.Second), Rate: 10, Size: 100, Count: -1, BroadcastTxMethod: "async", Endpoints: []string{"ws://localhost:26657/websocket"}, EndpointSelectMethod: SelectSuppliedEndpoints},
+		&CoordinatorConfig{BindAddr: "localhost:0", ExpectWorkers: 1, WorkerConnectTimeout: Duration(time.Second), AuthToken: authToken, ManualStart: true},
+	)
+}
+
+func controlRequest(t *testing.T, handler http.HandlerFunc, method, body, token string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res), rec.Body.String())
+	return rec, res
+}
+
+func TestControlAPIStatus(t *testing.T) {
+	c := newControlTestCoordinator("")
+	rec, res := controlRequest(t, c.handleTestStatus, http.MethodGet, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "starting", res["phase"])
+	assert.Equal(t, true, res["manual_start"])
+	assert.Equal(t, 1.0, res["expect_workers"])
+	assert.Equal(t, 1.0, res["min_workers"])
+	assert.Nil(t, res["progress"])
+
+	c.setState(coordWaitingForWorkers)
+	c.setConnectedWorkers(1)
+	_, res = controlRequest(t, c.handleTestStatus, http.MethodGet, "", "")
+	assert.Equal(t, "waiting_for_workers", res["phase"])
+	assert.Equal(t, 1.0, res["connected_workers"])
+
+	rec, _ = controlRequest(t, c.handleTestStatus, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestControlAPIAuth(t *testing.T) {
+	c := newControlTestCoordinator("s3cret")
+	for _, handler := range []http.HandlerFunc{c.handleTestStatus, c.handleTestStart, c.handleTestCancel, c.handleTestPause, c.handleTestResume, c.handleWorkersShutdown} {
+		rec, _ := controlRequest(t, handler, http.MethodPost, "", "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		rec, _ = controlRequest(t, handler, http.MethodPost, "", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	rec, _ := controlRequest(t, c.handleTestStatus, http.MethodGet, "", "s3cret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestControlAPIStart(t *testing.T) {
+	c := newControlTestCoordinator("")
+	// the load test can only be started while waiting for workers
+	rec, res := controlRequest(t, c.handleTestStart, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, res["error"], "cannot start load test while starting")
+
+	c.setState(coordWaitingForWorkers)
+	rec, _ = controlRequest(t, c.handleTestStart, http.MethodGet, "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	// overrides must be valid configuration
+	rec, res = controlRequest(t, c.handleTestStart, http.MethodPost, `{"duration":1}`, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, res["error"], "unknown field")
+	rec, _ = controlRequest(t, c.handleTestStart, http.MethodPost, `{"rate":-1}`, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// the coordinator's event loop decides whether the load test can start
+	go func() {
+		req := <-c.startRequest
+		req.resp <- c.handleStartRequest(req)
+	}()
+	rec, res = controlRequest(t, c.handleTestStart, http.MethodPost, `{"time":1}`, "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "only 0 of 1 workers have connected", res["error"])
+	assert.Equal(t, Duration(5*time.Second), c.config().Time)
+}
+
+func TestControlAPICancel(t *testing.T) {
+	c := newControlTestCoordinator("")
+	c.setState(coordWaitingForWorkers)
+	rec, _ := controlRequest(t, c.handleTestCancel, http.MethodGet, "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec, res := controlRequest(t, c.handleTestCancel, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "cancelled", res["phase"])
+	assert.True(t, c.wasCancelled())
+	select {
+	case <-c.stop:
+	default:
+		t.Fatal("Expected the coordinator to have been stopped")
+	}
+
+	rec, _ = controlRequest(t, c.handleTestCancel, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestControlAPIPause(t *testing.T) {
+	c := newControlTestCoordinator("")
+	// the load test can only be paused once it's underway
+	c.setState(coordWaitingForWorkers)
+	rec, res := controlRequest(t, c.handleTestPause, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "cannot pause load test while waiting_for_workers", res["error"])
+
+	c.setState(coordTesting)
+	rec, _ = controlRequest(t, c.handleTestPause, http.MethodGet, "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// the coordinator's event loop handles the requests
+	go func() {
+		for req := range c.pauseRequest {
+			req.resp <- c.handlePauseRequest(req)
+		}
+	}()
+	defer close(c.pauseRequest)
+	rec, res = controlRequest(t, c.handleTestResume, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "load test is not paused", res["error"])
+
+	rec, res = controlRequest(t, c.handleTestPause, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "paused", res["phase"])
+	assert.Equal(t, "paused", res["progress"].(map[string]interface{})["state"])
+	rec, res = controlRequest(t, c.handleTestPause, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "load test is already paused", res["error"])
+
+	rec, res = controlRequest(t, c.handleTestResume, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "testing", res["phase"])
+	assert.Equal(t, "testing", res["progress"].(map[string]interface{})["state"])
+}
+
+func TestControlAPIWorkersShutdown(t *testing.T) {
+	c := newControlTestCoordinator("")
+	// workers can only be shut down while the coordinator is running
+	c.setState(coordCompleted)
+	rec, res := controlRequest(t, c.handleWorkersShutdown, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "cannot shut down workers while completed", res["error"])
+
+	c.setState(coordTesting)
+	rec, _ = controlRequest(t, c.handleWorkersShutdown, http.MethodGet, "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// the coordinator's event loop only shuts down workers mid-test if forced
+	go func() {
+		for req := range c.shutdownRequest {
+			req.resp <- c.handleShutdownRequest(req)
+		}
+	}()
+	defer close(c.shutdownRequest)
+	rec, res = controlRequest(t, c.handleWorkersShutdown, http.MethodPost, "", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, res["error"], "must be forced")
+	assert.False(t, c.wasCancelled())
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/workers/shutdown?force=true", nil)
+	rec = httptest.NewRecorder()
+	c.handleWorkersShutdown(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.True(t, c.wasCancelled())
+	assert.True(t, c.shuttingDownWorkers)
+}
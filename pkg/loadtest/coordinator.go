@@ -2,16 +2,24 @@ package loadtest
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/informalsystems/tm-load-test/internal/logging"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -26,19 +34,40 @@ const (
 	coordTesting           = 3
 	coordFailed            = 4
 	coordCompleted         = 5
+	coordPaused            = 6
+	coordCancelled         = 7
 )
 
 // The rate at which the coordinator logs progress and updates the Prometheus metrics
 // it's writing out.
 const coordProgressUpdateInterval = 5 * time.Second
 
+// The default interval at which workers send heartbeats, and how long the
+// coordinator waits to hear from a worker before considering it failed.
+const (
+	defaultHeartbeatInterval = 1 * time.Second
+	defaultHeartbeatTimeout  = 10 * time.Second
+)
+
+// How long the coordinator waits for workers to report their final statistics
+// once the load test is cancelled, on top of the time they may spend draining.
+const coordCancelTimeout = 15 * time.Second
+
+// The default Prometheus histogram buckets (in seconds) for broadcast latencies.
+var defaultBroadcastLatencyBuckets = prometheus.ExponentialBuckets(0.001, 2, 15)
+
+// The Prometheus histogram buckets (in seconds) for send-to-commit latencies.
+var commitLatencyBuckets = prometheus.ExponentialBuckets(0.1, 2, 12)
+
 // Coordinator is a WebSockets server that allows workers to connect to it to
 // obtain configuration information. It does nothing but coordinate load
 // testing amongst the workers.
 type Coordinator struct {
-	cfg      *Config
-	coordCfg *CoordinatorConfig
-	logger   logging.Logger
+	cfg       *Config
+	cfgMtx    sync.RWMutex // Guards the replacement of *cfg once other goroutines can read it (via config).
+	coordCfg  *CoordinatorConfig
+	logger    logging.Logger
+	newLogger LoggerFactory // Creates the loggers of our remote workers (nil for the one given to SetLogger).
 
 	svr        *http.Server  // The HTTP/WebSockets server.
 	svrStopped chan struct{} // Closed when the WebSockets server has shut down.
@@ -48,28 +77,100 @@ type Coordinator struct {
 	workerRegister   chan remoteWorkerRegisterRequest   // Send a request here to register a remote worker.
 	workerUnregister chan remoteWorkerUnregisterRequest // Send a request here to unregister a remote worker.
 	workerUpdate     chan workerMsg
+	startRequest     chan controlStartRequest    // Send a request here to start the load test via the control API.
+	pauseRequest     chan controlPauseRequest    // Send a request here to pause or resume the load test via the control API.
+	shutdownRequest  chan controlShutdownRequest // Send a request here to shut down the connected workers via the control API.
 	stop             chan struct{}
+	stopOnce         sync.Once
+
+	baseCfg Config // The configuration to which each run's overrides are applied.
+	run     int    // The index of the current run.
+	runs    int    // The number of runs to execute back to back.
 
 	// Rudimentary statistics
-	startTime           time.Time
-	lastProgressUpdate  time.Time
-	totalTxs            int              // The last calculated total number of transactions across all workers.
-	totalBytes          int64            // The last calculated total number of bytes in transactions sent across all workers.
-	totalTxsPerWorker   map[string]int   // The number of transactions sent by each worker.
-	totalBytesPerWorker map[string]int64 // The total cumulative number of transaction bytes sent by each worker.
+	startTime             time.Time
+	startedWorkers        int       // The number of workers with which the load test was started (excluding those that joined once it was underway).
+	sendEndTime           time.Time // The latest time at which any worker stopped sending (excluding draining).
+	lastProgressUpdate    time.Time
+	totalTxs              int                                 // The last calculated total number of transactions across all workers.
+	totalBytes            int64                               // The last calculated total number of bytes in transactions sent across all workers.
+	totalTxsPerWorker     map[string]int                      // The number of transactions sent by each worker.
+	totalBytesPerWorker   map[string]int64                    // The total cumulative number of transaction bytes sent by each worker.
+	mempoolPerWorker      map[string]MempoolStats             // Mempool throttling statistics reported by each worker.
+	healthPerWorker       map[string]EndpointHealthStats      // Endpoint blacklisting statistics reported by each worker.
+	broadcastLatPerWorker map[string]*latencySketch           // The broadcast latencies reported by each worker.
+	broadcastLatencies    *latencySketch                      // The broadcast latencies merged across all workers (guarded by mtx).
+	commitLatPerWorker    map[string]*latencySketch           // The send-to-commit latencies reported by each worker.
+	priorityLatPerWorker  map[string]map[int64]*latencySketch // The send-to-commit latencies per transaction priority reported by each worker.
+	commitLatencies       *latencySketch                      // The send-to-commit latencies merged across all workers (guarded by mtx).
+	endpointsPerWorker    map[string][]EndpointStats          // Per-endpoint statistics reported by each worker.
+	statsPerWorker        map[string]WorkerStats              // The final statistics reported by each worker that has completed its load testing.
+	resourcesPerWorker    map[string]ResourceUsage            // The peak resource usage reported by each worker.
+	buildPerWorker        map[string]BuildInfo                // The build of tm-load-test each worker runs, if it said when registering.
+	intervalTxsPerWorker  map[string][]int                    // The number of transactions sent during each rate window, reported by each worker that has completed its load testing.
+	statePerWorker        map[string]workerState              // The latest state reported by each worker.
+	reportedPerWorker     map[string]workerTotals             // The totals last reported by each worker, from which the Prometheus counters are incremented.
+	intervalsPerWorker    map[string]*intervalTotals          // The statistics folded from the intervals pushed by each worker thus far.
+	progressPerWorker     map[string]progressStatus           // The latest progress reported by each worker.
+	joinedAtPerWorker     map[string]float64                  // How far into the load test (in seconds) each worker that joined it once underway was accepted.
+	startOffsetPerWorker  map[string]float64                  // How long after the start of the load test (in seconds) each worker was told to start sending, if workers' starts are staggered.
+	reconnectDeadlines    map[string]time.Time                // When each worker that lost its connection during the load test must have reconnected by.
+	failedWorkers         map[string]bool                     // The workers that failed during the load test, if it carries on without them.
+	endpointShards        map[string][]string                 // The endpoints assigned to each worker, if the endpoints are sharded amongst the workers (guarded by mtx).
+	excludedEndpoints     []ExcludedEndpoint                  // The endpoints left out of the load test for failing their health checks.
+	txRateShare           float64                             // Each worker's share of the total transaction rate (tx/sec), once the load test has started, if a total rate is split amongst the workers (guarded by mtx).
+	progress              progressStatus                      // The last calculated progress across all workers.
+	interimStats          *AggregateStats                     // The last calculated aggregate statistics across all workers, while the load test is underway.
+	pauseClk              pauseClock                          // Tracks whether (and for how long) the load test has been paused.
+	cancelling            bool                                // Whether the load test was cancelled while underway, and the workers asked for their final statistics.
+	shuttingDownWorkers   bool                                // Whether the workers are to be shut down (rather than just cancelled) once the load test has been cancelled.
+	resumed               bool                                // Whether the current run was restored from the state file (rather than started by this coordinator).
 
 	// Prometheus metrics
-	stateMetric            prometheus.Gauge // A code-based status metric for representing the coordinator's current state.
-	totalTxsMetric         prometheus.Gauge // The total number of transactions sent by all workers.
-	totalBytesMetric       prometheus.Gauge // The total cumulative bytes in transactions sent by all workers.
-	txRateMetric           prometheus.Gauge // The transaction throughput rate (tx/sec) as measured by the coordinator since the last metrics update.
-	txDataRateMetric       prometheus.Gauge // The total transaction throughput rate in bytes/sec as measured by the coordinator.
-	overallTxRateMetric    prometheus.Gauge // The overall transaction throughput rate (tx/sec) as measured by the coordinator since the beginning of the load test.
-	workersCompletedMetric prometheus.Gauge // The total number of workers that have completed their testing.
-	testUnderwayMetric     prometheus.Gauge // The ID of the load test currently underway (-1 if none).
-
-	mtx       sync.Mutex
-	cancelled bool
+	registry               *prometheus.Registry
+	stateMetric            prometheus.Gauge   // A code-based status metric for representing the coordinator's current state.
+	totalTxsMetric         prometheus.Counter // The total number of transactions sent by all workers.
+	totalBytesMetric       prometheus.Counter // The total cumulative bytes in transactions sent by all workers.
+	txRateMetric           prometheus.Gauge   // The transaction throughput rate (tx/sec) as measured by the coordinator since the last metrics update.
+	txDataRateMetric       prometheus.Gauge   // The total transaction throughput rate in bytes/sec as measured by the coordinator.
+	overallTxRateMetric    prometheus.Gauge   // The overall transaction throughput rate (tx/sec) as measured by the coordinator since the beginning of the load test.
+	workersCompletedMetric prometheus.Gauge   // The total number of workers that have completed their testing.
+	workersStartedMetric   prometheus.Gauge   // The number of workers taking part in the load test.
+	testUnderwayMetric     prometheus.Gauge   // The ID of the load test currently underway (-1 if none).
+	mempoolPausesMetric    prometheus.Gauge   // The total number of times workers paused sending to an endpoint because of its mempool size.
+	mempoolPausedMetric    prometheus.Gauge   // The total time for which endpoints were paused, summed across all workers' endpoints.
+	mempoolPausedEpsMetric prometheus.Gauge   // The number of endpoints currently paused, summed across all workers.
+	healthyEndpointsMetric prometheus.Gauge   // The number of endpoints currently not blacklisted, summed across all workers.
+	progressRatioMetric    prometheus.Gauge   // The fraction of the load test completed so far, averaged across workers.
+	rejectedRegsMetric     prometheus.Counter // The number of worker registrations rejected because of a missing or incorrect auth token.
+	duplicateIDsMetric     prometheus.Counter // The number of worker registrations rejected because a worker with the same ID is already connected.
+
+	// Per-worker Prometheus metrics, labeled by worker ID (bounded by the
+	// number of workers taking part)
+	workerTxsMetric              *prometheus.CounterVec // The number of transactions sent by each worker.
+	workerBytesMetric            *prometheus.CounterVec // The number of transaction bytes sent by each worker.
+	workerFailuresMetric         *prometheus.CounterVec // The number of error responses received by each worker.
+	workerConnectedMetric        *prometheus.GaugeVec   // Whether each worker is currently connected (1) or not (0).
+	workerMissedHeartbeatsMetric *prometheus.CounterVec // The number of heartbeat intervals in which each worker wasn't heard from.
+	workerCPUMetric              *prometheus.GaugeVec   // Each worker's latest CPU utilization (as a percentage of a single core).
+	workerRSSMetric              *prometheus.GaugeVec   // Each worker's latest resident set size.
+	workerGoroutinesMetric       *prometheus.GaugeVec   // The latest number of goroutines running in each worker.
+	workerGCPauseMetric          *prometheus.GaugeVec   // The cumulative time for which garbage collection has paused each worker.
+	workerInfo                   *workerInfoCollector   // The labels each worker registered with.
+
+	events *coordEventQueue // Delivers workers' life cycle events to the listener set via SetEvents, if any.
+
+	mtx         sync.Mutex
+	state       int // The coordinator's current state (one of the coord* constants).
+	cancelled   bool
+	terminated  bool    // Whether the coordinator was cancelled by a termination signal.
+	finalReport *Report // The report on the aggregate and per-worker statistics, once all workers have completed.
+
+	// The view of the load test served at /stats
+	liveMtx       sync.Mutex
+	liveStats     *LiveStats // Only set once the load test has started.
+	liveStartTime time.Time
+	liveConnected int // The number of connected workers, while waiting for workers.
 }
 
 type remoteWorkerRegisterRequest struct {
@@ -78,71 +179,213 @@ type remoteWorkerRegisterRequest struct {
 }
 
 type remoteWorkerUnregisterRequest struct {
-	id  string // The ID of the worker to unregister.
-	err error  // If any error occurred during the worker's life cycle.
+	rw   *remoteWorker // The worker to unregister.
+	lost bool          // Whether the connection to the worker was lost, in which case it may reconnect.
+	err  error         // If any error occurred during the worker's life cycle.
 }
 
-var upgrader = websocket.Upgrader{
+var wsUpgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 }
 
-func NewCoordinator(cfg *Config, coordCfg *CoordinatorConfig) *Coordinator {
-	logger := logging.NewLogrusLogger("coordinator")
+// newCoordinatorRegistry creates the registry of a coordinator's metrics,
+// which also holds the Go runtime and process metrics that the default
+// registry would. Each coordinator has its own registry, rather than
+// registering its metrics globally, so that a process can create more than one
+// coordinator (e.g. to run successive load tests) without them clashing, and
+// so that each coordinator's metrics endpoint only reports its own workers.
+func newCoordinatorRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
+}
+
+func NewCoordinator(cfg *Config, coordCfg *CoordinatorConfig, opts ...Option) *Coordinator {
+	o := newOptions(opts)
+	logger := newLogger(o.loggerFactory, "coordinator")
+	if len(cfg.RunID) == 0 {
+		cfg.RunID = makeRunID()
+	}
+	registry := newCoordinatorRegistry()
+	metrics := promauto.With(registry)
 	coord := &Coordinator{
-		cfg:                 cfg,
-		coordCfg:            coordCfg,
-		logger:              logger,
-		svrStopped:          make(chan struct{}, 1),
-		workers:             make(map[string]*remoteWorker),
-		workerRegister:      make(chan remoteWorkerRegisterRequest, coordCfg.ExpectWorkers),
-		workerUnregister:    make(chan remoteWorkerUnregisterRequest, coordCfg.ExpectWorkers),
-		workerUpdate:        make(chan workerMsg, coordCfg.ExpectWorkers),
-		stop:                make(chan struct{}, 1),
-		totalTxsPerWorker:   make(map[string]int),
-		totalBytesPerWorker: make(map[string]int64),
-		stateMetric: promauto.NewGauge(prometheus.GaugeOpts{
+		cfg:                   cfg,
+		coordCfg:              coordCfg,
+		logger:                logger,
+		newLogger:             o.loggerFactory,
+		svrStopped:            make(chan struct{}, 1),
+		workers:               make(map[string]*remoteWorker),
+		workerRegister:        make(chan remoteWorkerRegisterRequest, coordCfg.ExpectWorkers),
+		workerUnregister:      make(chan remoteWorkerUnregisterRequest, coordCfg.ExpectWorkers),
+		workerUpdate:          make(chan workerMsg, coordCfg.ExpectWorkers),
+		startRequest:          make(chan controlStartRequest),
+		pauseRequest:          make(chan controlPauseRequest),
+		shutdownRequest:       make(chan controlShutdownRequest),
+		stop:                  make(chan struct{}, 1),
+		runs:                  cfg.runs(),
+		totalTxsPerWorker:     make(map[string]int),
+		totalBytesPerWorker:   make(map[string]int64),
+		mempoolPerWorker:      make(map[string]MempoolStats),
+		healthPerWorker:       make(map[string]EndpointHealthStats),
+		broadcastLatPerWorker: make(map[string]*latencySketch),
+		commitLatPerWorker:    make(map[string]*latencySketch),
+		priorityLatPerWorker:  make(map[string]map[int64]*latencySketch),
+		endpointsPerWorker:    make(map[string][]EndpointStats),
+		statsPerWorker:        make(map[string]WorkerStats),
+		resourcesPerWorker:    make(map[string]ResourceUsage),
+		buildPerWorker:        make(map[string]BuildInfo),
+		intervalTxsPerWorker:  make(map[string][]int),
+		statePerWorker:        make(map[string]workerState),
+		reportedPerWorker:     make(map[string]workerTotals),
+		intervalsPerWorker:    make(map[string]*intervalTotals),
+		progressPerWorker:     make(map[string]progressStatus),
+		joinedAtPerWorker:     make(map[string]float64),
+		startOffsetPerWorker:  make(map[string]float64),
+		reconnectDeadlines:    make(map[string]time.Time),
+		failedWorkers:         make(map[string]bool),
+		endpointShards:        make(map[string][]string),
+		registry:              registry,
+		stateMetric: metrics.NewGauge(prometheus.GaugeOpts{
 			Name: "tmloadtest_coordinator_state",
 			Help: "The current state of the tm-load-test coordinator",
 		}),
-		totalTxsMetric: promauto.NewGauge(prometheus.GaugeOpts{
+		totalTxsMetric: metrics.NewCounter(prometheus.CounterOpts{
 			Name: "tmloadtest_coordinator_total_txs",
 			Help: "The total cumulative number of transactions sent by all workers",
 		}),
-		totalBytesMetric: promauto.NewGauge(prometheus.GaugeOpts{
+		totalBytesMetric: metrics.NewCounter(prometheus.CounterOpts{
 			Name: "tmloadtest_coordinator_total_bytes",
 			Help: "The total cumulative number of bytes of transactions sent by all workers",
 		}),
-		txRateMetric: promauto.NewGauge(prometheus.GaugeOpts{
+		txRateMetric: metrics.NewGauge(prometheus.GaugeOpts{
 			Name: "tmloadtest_coordinator_tx_rate",
 			Help: "The current transaction throughput rate (in txs/sec) as seen by the tm-load-test coordinator, summed across all workers",
 		}),
-		txDataRateMetric: promauto.NewGauge(prometheus.GaugeOpts{
+		txDataRateMetric: metrics.NewGauge(prometheus.GaugeOpts{
 			Name: "tmloadtest_coordinator_tx_data_rate",
 			Help: "The current transaction throughput rate (in bytes/sec) as seen by the tm-load-test coordinator, summed across all workers",
 		}),
-		overallTxRateMetric: promauto.NewGauge(prometheus.GaugeOpts{
+		overallTxRateMetric: metrics.NewGauge(prometheus.GaugeOpts{
 			Name: "tmloadtest_coordinator_overall_tx_rate",
 			Help: "The overall transaction throughput rate as seen by the tm-load-test coordinator since the beginning of the load test",
 		}),
-		workersCompletedMetric: promauto.NewGauge(prometheus.GaugeOpts{
+		workersCompletedMetric: metrics.NewGauge(prometheus.GaugeOpts{
 			Name: "tmloadtest_coordinator_workers_completed",
 			Help: "The total number of workers that have completed their testing so far",
 		}),
-		testUnderwayMetric: promauto.NewGauge(prometheus.GaugeOpts{
+		workersStartedMetric: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_workers_started",
+			Help: "The number of workers taking part in the load test, including those that joined it once underway",
+		}),
+		testUnderwayMetric: metrics.NewGauge(prometheus.GaugeOpts{
 			Name: "tmloadtest_coordinator_test_underway",
 			Help: "The ID of the load test currently underway (-1 if none)",
 		}),
+		mempoolPausesMetric: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_mempool_pauses",
+			Help: "The total number of times workers paused sending to an endpoint because its mempool size exceeded the threshold",
+		}),
+		mempoolPausedMetric: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_mempool_paused_seconds",
+			Help: "The total time (in seconds) for which endpoints were paused because of their mempool size, summed across all workers",
+		}),
+		mempoolPausedEpsMetric: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_mempool_paused_endpoints",
+			Help: "The number of endpoints currently paused because of their mempool size, summed across all workers",
+		}),
+		healthyEndpointsMetric: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_healthy_endpoints",
+			Help: "The number of endpoints currently not blacklisted after repeated failures, summed across all workers",
+		}),
+		progressRatioMetric: metrics.NewGauge(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_progress_ratio",
+			Help: "The fraction of the load test completed so far (between 0 and 1), averaged across all workers",
+		}),
+		workerTxsMetric: metrics.NewCounterVec(prometheus.CounterOpts{
+			Name: "tmloadtest_coordinator_worker_txs",
+			Help: "The total number of transactions sent by each worker",
+		}, []string{"worker"}),
+		workerBytesMetric: metrics.NewCounterVec(prometheus.CounterOpts{
+			Name: "tmloadtest_coordinator_worker_bytes",
+			Help: "The total number of bytes of transactions sent by each worker",
+		}, []string{"worker"}),
+		workerFailuresMetric: metrics.NewCounterVec(prometheus.CounterOpts{
+			Name: "tmloadtest_coordinator_worker_failures",
+			Help: "The total number of error responses received by each worker",
+		}, []string{"worker"}),
+		workerConnectedMetric: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_worker_connected",
+			Help: "Whether each worker is currently connected to the coordinator (1) or not (0)",
+		}, []string{"worker"}),
+		workerMissedHeartbeatsMetric: metrics.NewCounterVec(prometheus.CounterOpts{
+			Name: "tmloadtest_coordinator_worker_missed_heartbeats",
+			Help: "The total number of heartbeat intervals in which each worker wasn't heard from",
+		}, []string{"worker"}),
+		workerCPUMetric: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_worker_cpu_percent",
+			Help: "The latest CPU utilization reported by each worker, as a percentage of a single core",
+		}, []string{"worker"}),
+		workerRSSMetric: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_worker_rss_bytes",
+			Help: "The latest resident set size reported by each worker",
+		}, []string{"worker"}),
+		workerGoroutinesMetric: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_worker_goroutines",
+			Help: "The latest number of goroutines reported by each worker",
+		}, []string{"worker"}),
+		workerGCPauseMetric: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tmloadtest_coordinator_worker_gc_pause_seconds",
+			Help: "The cumulative time for which garbage collection has paused each worker, as reported by it",
+		}, []string{"worker"}),
+		rejectedRegsMetric: metrics.NewCounter(prometheus.CounterOpts{
+			Name: "tmloadtest_coordinator_rejected_registrations",
+			Help: "The total number of worker registrations rejected because of a missing or incorrect auth token",
+		}),
+		duplicateIDsMetric: metrics.NewCounter(prometheus.CounterOpts{
+			Name: "tmloadtest_coordinator_duplicate_worker_ids",
+			Help: "The total number of worker registrations rejected because a worker with the same ID is already connected",
+		}),
+		workerInfo: newWorkerInfoCollector(
+			"tmloadtest_coordinator_worker_info",
+			"The labels of each worker that has registered with the coordinator (always 1)",
+		),
+	}
+	registry.MustRegister(coord.workerInfo)
+	registerBuildInfo(registry)
+	registry.MustRegister(newLatencyHistogramCollector(
+		"tmloadtest_coordinator_broadcast_latency_seconds",
+		"The broadcast latency of transactions (write-completion latency for broadcast_tx_async), across all workers",
+		cfg.broadcastLatencyBuckets(),
+		coord.getBroadcastLatencies,
+	))
+	if cfg.TrackCommitLatency {
+		registry.MustRegister(newLatencyHistogramCollector(
+			"tmloadtest_coordinator_commit_latency_seconds",
+			"The send-to-commit latency of sampled transactions, across all workers",
+			commitLatencyBuckets,
+			coord.getCommitLatencies,
+		))
 	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", coord.newWebSocketHandler())
-	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/stats", coord.handleLiveStats)
+	mux.HandleFunc("/v1/test/start", coord.handleTestStart)
+	mux.HandleFunc("/v1/test/cancel", coord.handleTestCancel)
+	mux.HandleFunc("/v1/test/pause", coord.handleTestPause)
+	mux.HandleFunc("/v1/test/resume", coord.handleTestResume)
+	mux.HandleFunc("/v1/test/status", coord.handleTestStatus)
+	mux.HandleFunc("/v1/workers/shutdown", coord.handleWorkersShutdown)
 	svr := &http.Server{
 		Addr:    coordCfg.BindAddr,
 		Handler: mux,
 	}
 	coord.svr = svr
-	coord.stateMetric.Set(coordStarting)
+	coord.setState(coordStarting)
 	coord.testUnderwayMetric.Set(-1)
 	return coord
 }
@@ -151,72 +394,396 @@ func NewCoordinator(cfg *Config, coordCfg *CoordinatorConfig) *Coordinator {
 // returning any error that causes one of the workers or the coordinator to
 // fail.
 func (c *Coordinator) Run() error {
+	return c.RunWithContext(context.Background())
+}
+
+// RunWithContext executes the coordinator's operations like Run, but stops
+// them (as Stop does) if the given context is cancelled, in which case the
+// statistics gathered until then are still written and ErrLoadTestCancelled
+// is returned.
+func (c *Coordinator) RunWithContext(ctx context.Context) (err error) {
+	// runs last, once everything else has been shut down
+	defer func() {
+		// a dry run has no results to report
+		if c.cfg.DryRun {
+			return
+		}
+		notifyResultWebhook(*c.cfg, c.getFinalStats(), err, c.logger)
+		if c.cfg.PrintSummaryJSON {
+			printSummary(NewSummary(*c.cfg, c.getFinalStats(), err), c.logger)
+		}
+	}()
+
+	c.logger.Info("Starting coordinator", "version", Version(), "protocolVersion", WorkerProtocolVersion)
+
+	if c.events != nil {
+		go c.events.run()
+		defer c.events.close(coordShutdownTimeout, c.logger)
+	}
+
+	// workers get their endpoints' rate limits separately from the endpoints
+	logEndpointPathDefaults(c.cfg.Endpoints, c.logger)
+	if err := c.cfg.ParseEndpointRateLimits(); err != nil {
+		c.setState(coordFailed)
+		return configError(err)
+	}
+	// workers are given the individual endpoints behind any resolved (e.g.
+	// DNS) endpoints
+	if err := c.cfg.ResolveEndpoints(); err != nil {
+		c.logger.Error("Failed to resolve endpoints", "err", err)
+		c.setState(coordFailed)
+		return connectivityError(err)
+	}
+	if err := c.coordCfg.ValidateWorkerOverrides(*c.cfg); err != nil {
+		c.setState(coordFailed)
+		return configError(err)
+	}
+	// a resumed load test carries on writing the files of the load test that
+	// was checkpointed
+	if !c.coordCfg.Resume {
+		if err := c.cfg.ValidateOutputFiles(); err != nil {
+			c.logger.Error("Invalid output files", "err", err)
+			c.setState(coordFailed)
+			return configError(err)
+		}
+	}
+
+	// a coordinator restarted after crashing picks up where it left off,
+	// having already waited for the network's peers (and workers)
+	if c.coordCfg.Resume {
+		cp, err := c.loadCheckpoint()
+		if err != nil {
+			c.setState(coordFailed)
+			return connectivityError(err)
+		}
+		if cp != nil {
+			c.restoreCheckpoint(cp)
+		} else {
+			c.logger.Info("No load test to resume - starting afresh", "stateFile", c.coordCfg.StateFile)
+		}
+	}
+
 	// if we care about how many peers are connected in the network, wait
 	// for a minimum number of them to connect before even listening for
 	// incoming worker connections
-	if c.cfg.ExpectPeers > 0 {
-		if err := c.waitForPeers(); err != nil {
-			c.stateMetric.Set(coordFailed)
+	if c.cfg.ExpectPeers > 0 && !c.resumed {
+		if err := c.waitForPeers(ctx); err != nil {
+			c.setState(coordFailed)
+			return connectivityError(err)
+		}
+	}
+
+	// only the (supplied or discovered) endpoints that are healthy are given
+	// to the workers
+	if !c.resumed {
+		if c.excludedEndpoints, err = checkEndpointsHealth(c.cfg, c.logger); err != nil {
+			c.logger.Error("Not enough healthy endpoints", "err", err)
+			c.setState(coordFailed)
+			return connectivityError(err)
+		}
+	}
+
+	// each run's overrides apply to the configuration as it stands once the
+	// network's peers are known
+	if len(c.cfg.Runs) > 0 && !c.resumed {
+		c.baseCfg = *c.cfg
+		if err := c.applyRun(0); err != nil {
+			c.setState(coordFailed)
 			return err
 		}
 	}
 
-	defer c.gracefulShutdown()
+	// a dry run checks that we can generate transactions (and how long it
+	// takes) before waiting for the workers
+	var dryRunTxSize int
+	var dryRunTxCost time.Duration
+	if c.cfg.DryRun {
+		if dryRunTxSize, err = sampleTxSize(*c.cfg); err == nil {
+			dryRunTxCost, err = calibrateTxCost(*c.cfg)
+		}
+		if err != nil {
+			c.logger.Error("Dry run failed", "err", err)
+			c.setState(coordFailed)
+			return connectivityError(err)
+		}
+	}
 
-	// we want to know if the user hits Ctrl+Break
-	cancelTrap := trapInterrupts(func() {
-		c.setCancelled(true)
-		close(c.stop)
-		c.stateMetric.Set(coordFailed)
-	}, c.logger)
+	if c.coordCfg.tlsEnabled() {
+		cert, err := tls.LoadX509KeyPair(c.coordCfg.TLSCertFile, c.coordCfg.TLSKeyFile)
+		if err != nil {
+			c.setState(coordFailed)
+			return configError(fmt.Errorf("failed to load TLS certificate: %w", err))
+		}
+		c.svr.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	defer c.gracefulShutdown(ctx)
+	defer c.removeCheckpoint()
+
+	// we want to know if the user hits Ctrl+Break (or the context is
+	// cancelled)
+	cancelTrap := trapSignals(c.cancel, c.terminate, c.logger)
 	defer func() {
 		close(cancelTrap)
 	}()
+	defer afterFunc(ctx, c.cancel)()
 
 	// we run the WebSockets server in the background
 	go c.runServer()
 
-	if err := c.waitForWorkers(); err != nil {
-		c.failAllRemoteWorkers(err.Error())
-		c.stateMetric.Set(coordFailed)
-		return err
+	if !c.resumed {
+		if err := c.waitForWorkers(); err != nil {
+			return c.fail(err)
+		}
+	}
+	if c.cfg.DryRun {
+		return c.finishDryRun(dryRunTxSize, dryRunTxCost)
+	}
+
+	for {
+		if err := c.receiveTestingUpdates(); err != nil {
+			return c.fail(err)
+		}
+		if c.run+1 >= c.runs {
+			break
+		}
+		if err := c.startNextRun(); err != nil {
+			return c.fail(err)
+		}
+	}
+
+	if c.coordCfg.ShutdownWorkersOnCompletion {
+		c.shutdownAllRemoteWorkers(false)
+	}
+	c.setState(coordCompleted)
+	return nil
+}
+
+// SetEvents sets the listener to notify of the life cycle events of the
+// workers taking part in the load test. Must be called before Run.
+func (c *Coordinator) SetEvents(events CoordinatorEvents) {
+	c.events = newCoordEventQueue(events, coordEventsBufSize)
+}
+
+// DroppedEvents returns the number of events that weren't delivered to the
+// listener set via SetEvents because it couldn't keep up.
+func (c *Coordinator) DroppedEvents() int64 {
+	if c.events == nil {
+		return 0
 	}
+	return c.events.dropped.Load()
+}
 
-	if err := c.receiveTestingUpdates(); err != nil {
-		c.failAllRemoteWorkers(err.Error())
-		c.stateMetric.Set(coordFailed)
+// fail ends the coordinator's operations with the given error, failing all
+// remote workers. A load test that was cancelled while underway has already
+// had its workers report their final statistics (or failed those that didn't).
+// Workers that are to be shut down on completion are shut down instead of
+// failing them if the load test is cancelled before it starts.
+func (c *Coordinator) fail(err error) error {
+	if errors.Is(err, ErrLoadTestCancelled) {
+		switch {
+		case c.cancelling:
+		case c.coordCfg.ShutdownWorkersOnCompletion:
+			c.shutdownAllRemoteWorkers(false)
+		default:
+			c.failAllRemoteWorkers(err.Error())
+		}
+		c.setState(coordCancelled)
 		return err
 	}
+	c.failAllRemoteWorkers(err.Error())
+	c.setState(coordFailed)
+	return err
+}
 
-	c.stateMetric.Set(coordCompleted)
+// applyRun switches to the configuration of the run with the given index. If
+// there are several runs, each one's statistics are written to files of their
+// own, suffixed with the run's index.
+func (c *Coordinator) applyRun(i int) error {
+	cfg, err := c.baseCfg.runConfig(i)
+	if err != nil {
+		return configError(err)
+	}
+	if err := c.coordCfg.ValidateConfig(cfg); err != nil {
+		return configError(fmt.Errorf("invalid configuration for run %d: %w", i, err))
+	}
+	if err := cfg.ParseEndpointRateLimits(); err != nil {
+		return configError(fmt.Errorf("invalid configuration for run %d: %w", i, err))
+	}
+	if err := c.coordCfg.ValidateWorkerOverrides(cfg); err != nil {
+		return configError(fmt.Errorf("invalid configuration for run %d: %w", i, err))
+	}
+	if len(c.baseCfg.Runs) > 0 {
+		cfg.StatsOutputFile = runOutputFile(cfg.StatsOutputFile, i)
+		cfg.RawStatsOutputFile = runOutputFile(cfg.RawStatsOutputFile, i)
+		cfg.LatencySampleFile = runOutputFile(cfg.LatencySampleFile, i)
+	}
+	c.run = i
+	c.setConfig(cfg)
 	return nil
 }
 
+// startNextRun starts the next run with the workers that are still connected,
+// once the previous run has completed and its statistics have been written.
+// Workers may still connect in the meantime, as when waiting for the first
+// run to start.
+func (c *Coordinator) startNextRun() error {
+	if err := c.applyRun(c.run + 1); err != nil {
+		return err
+	}
+	c.resetRunState()
+	c.logger.Info("Preparing next run", "run", c.run, "runs", c.runs, "cfg", c.cfg.ToJSON())
+	return c.waitForWorkers()
+}
+
+// resetRunState forgets about the statistics gathered during the previous run.
+func (c *Coordinator) resetRunState() {
+	c.startedWorkers = 0
+	c.sendEndTime = time.Time{}
+	c.totalTxs = 0
+	c.totalBytes = 0
+	c.totalTxsPerWorker = make(map[string]int)
+	c.totalBytesPerWorker = make(map[string]int64)
+	c.mempoolPerWorker = make(map[string]MempoolStats)
+	c.healthPerWorker = make(map[string]EndpointHealthStats)
+	c.broadcastLatPerWorker = make(map[string]*latencySketch)
+	c.commitLatPerWorker = make(map[string]*latencySketch)
+	c.priorityLatPerWorker = make(map[string]map[int64]*latencySketch)
+	c.endpointsPerWorker = make(map[string][]EndpointStats)
+	c.statsPerWorker = make(map[string]WorkerStats)
+	c.resourcesPerWorker = make(map[string]ResourceUsage)
+	c.intervalTxsPerWorker = make(map[string][]int)
+	c.statePerWorker = make(map[string]workerState)
+	c.reportedPerWorker = make(map[string]workerTotals)
+	c.intervalsPerWorker = make(map[string]*intervalTotals)
+	c.progressPerWorker = make(map[string]progressStatus)
+	c.joinedAtPerWorker = make(map[string]float64)
+	c.startOffsetPerWorker = make(map[string]float64)
+	c.reconnectDeadlines = make(map[string]time.Time)
+	c.failedWorkers = make(map[string]bool)
+	c.progress = progressStatus{}
+	c.interimStats = nil
+	c.pauseClk.reset()
+	// the workers still connected take part in the next run from its start
+	for id := range c.workers {
+		c.totalTxsPerWorker[id] = 0
+		c.totalBytesPerWorker[id] = 0
+		c.statePerWorker[id] = workerAccepted
+	}
+	c.mtx.Lock()
+	c.finalReport = nil
+	c.endpointShards = make(map[string][]string)
+	c.mtx.Unlock()
+}
+
 func (c *Coordinator) waitForWorkers() error {
 	c.logger.Info("Waiting for all workers to connect and register")
-	c.stateMetric.Set(coordWaitingForWorkers)
+	c.setState(coordWaitingForWorkers)
 
-	timeoutTicker := time.NewTicker(time.Duration(c.coordCfg.WorkerConnectTimeout) * time.Second)
+	timeoutTicker := time.NewTicker(time.Duration(c.coordCfg.WorkerConnectTimeout))
 	defer timeoutTicker.Stop()
+	// if the load test is started manually, we wait indefinitely for that
+	// once all the workers have connected
+	timeoutC := timeoutTicker.C
+
+	// once the minimum number of workers have connected, we only wait so long
+	// for the rest
+	minWorkers := c.coordCfg.minWorkers()
+	var graceTimer *time.Timer
+	var graceC <-chan time.Time
+	stopGracePeriod := func() {
+		if graceTimer != nil {
+			graceTimer.Stop()
+			graceTimer, graceC = nil, nil
+		}
+	}
+	defer stopGracePeriod()
+
+	// checkWorkers returns whether the load test can start right away, given
+	// the workers connected so far
+	checkWorkers := func() bool {
+		if len(c.workers) < c.coordCfg.ExpectWorkers {
+			if len(c.workers) >= minWorkers && c.coordCfg.ManualStart && timeoutC != nil {
+				c.logger.Info("Minimum number of workers connected - waiting for the load test to be started via the control API", "connected", len(c.workers), "expected", c.coordCfg.ExpectWorkers)
+				timeoutC = nil
+			}
+			if len(c.workers) >= minWorkers && graceTimer == nil && !c.coordCfg.ManualStart {
+				c.logger.Info(
+					"Minimum number of workers connected - waiting for the rest",
+					"connected", len(c.workers),
+					"expected", c.coordCfg.ExpectWorkers,
+					"gracePeriod", c.coordCfg.StartGracePeriod.String(),
+				)
+				graceTimer = time.NewTimer(time.Duration(c.coordCfg.StartGracePeriod))
+				graceC = graceTimer.C
+			}
+			return false
+		}
+		if !c.coordCfg.ManualStart {
+			return true
+		}
+		c.logger.Info("All workers connected - waiting for the load test to be started via the control API")
+		timeoutC = nil
+		return false
+	}
+	// workers stay connected from one run to the next
+	if checkWorkers() {
+		return c.startLoadTest()
+	}
 
 	for {
 		select {
 		case req := <-c.workerRegister:
-			req.resp <- c.registerRemoteWorker(req.rw)
+			if req.rw.resuming {
+				req.resp <- fmt.Errorf("no load test is underway")
+				continue
+			}
 			if len(c.workers) >= c.coordCfg.ExpectWorkers {
+				req.resp <- fmt.Errorf("too many workers")
+				continue
+			}
+			req.resp <- c.registerRemoteWorker(req.rw)
+			if checkWorkers() {
+				return c.startLoadTest()
+			}
+
+		case req := <-c.startRequest:
+			err := c.handleStartRequest(req)
+			req.resp <- err
+			if err == nil {
 				return c.startLoadTest()
 			}
 
+		case req := <-c.shutdownRequest:
+			c.logger.Info("Shutting down workers via control API", "connected", len(c.workers))
+			c.shutdownAllRemoteWorkers(req.force)
+			req.resp <- nil
+
 		case req := <-c.workerUnregister:
 			// we can do this safely during this waiting period without
 			// jeopardizing the load testing
-			c.unregisterRemoteWorker(req.id)
+			c.unregisterRemoteWorker(req.rw.ID())
+			if len(c.workers) < minWorkers {
+				stopGracePeriod()
+				timeoutC = timeoutTicker.C
+			}
 
-		case <-timeoutTicker.C:
-			return fmt.Errorf("timed out waiting for all workers to connect")
+		case <-graceC:
+			c.logMissingWorkers()
+			return c.startLoadTest()
+
+		case <-timeoutC:
+			if len(c.workers) >= minWorkers && minWorkers < c.coordCfg.ExpectWorkers && !c.coordCfg.ManualStart {
+				c.logMissingWorkers()
+				return c.startLoadTest()
+			}
+			if minWorkers < c.coordCfg.ExpectWorkers {
+				return connectivityError(fmt.Errorf("timed out waiting for workers to connect: only %d of the minimum of %d workers connected", len(c.workers), minWorkers))
+			}
+			return connectivityError(fmt.Errorf("timed out waiting for all workers to connect"))
 
 		case <-c.stop:
-			return fmt.Errorf("wait routine cancelled")
+			return fmt.Errorf("%w while waiting for workers", ErrLoadTestCancelled)
 
 		case <-c.svrStopped:
 			return fmt.Errorf("web server stopped unexpectedly")
@@ -224,32 +791,79 @@ func (c *Coordinator) waitForWorkers() error {
 	}
 }
 
+// logMissingWorkers warns that the load test is starting without all of the
+// expected workers, identifying those that are missing where possible (i.e.
+// those for which there are worker overrides keyed by worker ID).
+func (c *Coordinator) logMissingWorkers() {
+	connected := make([]string, 0, len(c.workers))
+	for id := range c.workers {
+		connected = append(connected, id)
+	}
+	sort.Strings(connected)
+	missing := make([]string, 0)
+	for id := range c.coordCfg.WorkerOverrides {
+		if _, ok := c.workers[id]; !ok && !isWorkerLabelSelector(id) {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(missing)
+	kvpairs := []interface{}{
+		"connected", len(c.workers),
+		"expected", c.coordCfg.ExpectWorkers,
+		"workers", strings.Join(connected, ","),
+	}
+	if len(missing) > 0 {
+		kvpairs = append(kvpairs, "missing", strings.Join(missing, ","))
+	}
+	c.logger.Warn("Starting load test without all of the expected workers", kvpairs...)
+}
+
 // Starts with the configuration file's endpoints list, polling those endpoints
 // for unique peers that have connected. Waits until we have the minimum number
 // of endpoints, and on success returns a list of peer addresses. On failure,
 // returns a relevant error.
-func (c *Coordinator) waitForPeers() error {
-	c.stateMetric.Set(coordWaitingForPeers)
+func (c *Coordinator) waitForPeers(ctx context.Context) error {
+	c.setState(coordWaitingForPeers)
+	// stopping the coordinator stops the wait too
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	filter, err := newDiscoveryFilter(c.cfg)
+	if err != nil {
+		c.logger.Error("Invalid discovery filter", "err", err)
+		return err
+	}
 	peers, err := waitForNetworkPeers(
+		ctx,
 		c.cfg.Endpoints,
 		c.cfg.EndpointSelectMethod,
 		c.cfg.ExpectPeers,
 		c.cfg.MinConnectivity,
 		c.cfg.MaxEndpoints,
-		time.Duration(c.cfg.PeerConnectTimeout)*time.Second,
+		c.cfg.Seed,
+		filter,
+		time.Duration(c.cfg.PeerConnectTimeout),
+		time.Duration(c.cfg.PeerPollInterval),
 		c.logger,
 	)
 	if err != nil {
 		c.logger.Error("Failed while waiting for peers to connect", "err", err)
 		return err
 	}
+	c.cfg.DiscoverySeeds = c.cfg.Endpoints
 	c.cfg.Endpoints = peers
 	return nil
 }
 
-func (c *Coordinator) receiveTestingUpdates() error {
+func (c *Coordinator) receiveTestingUpdates() (err error) {
 	c.logger.Info("Watching for worker updates")
-	c.stateMetric.Set(coordTesting)
+	c.setState(coordTesting)
 
 	// we set the current test underway ID to our configured load test ID for
 	// the duration of the test 将当前进行中的测试ID设置为配置的负载测试ID
@@ -257,22 +871,105 @@ func (c *Coordinator) receiveTestingUpdates() error {
 	// and we set it to -1 the moment all workers are done
 	defer c.testUnderwayMetric.Set(-1)
 
+	// worker samples are timestamped relative to the start of each worker's
+	// load test, so they're aligned on the test start time
+	var tw *timeseriesWriter
+	if len(c.cfg.RawStatsOutputFile) > 0 {
+		rawOut, rerr := newStatsOutput(c.cfg.RawStatsOutputFile)
+		if rerr != nil {
+			c.logger.Error("Failed to create raw statistics output file", "err", rerr)
+			return rerr
+		}
+		if tw, rerr = newTimeseriesWriter(rawOut.filename); rerr != nil {
+			c.logger.Error("Failed to create raw statistics output file", "err", rerr)
+			return rerr
+		}
+		// the raw statistics are uploaded even if the load test fails
+		defer func() {
+			_ = tw.Close()
+			if uerr := rawOut.finish(*c.cfg, c.logger); uerr != nil && err == nil {
+				err = uerr
+			}
+		}()
+	}
+
+	// the statistics gathered so far are flushed periodically, so that a
+	// coordinator that's killed leaves them behind
+	flushFile, ferr := c.cfg.statsFlushFile()
+	if ferr != nil {
+		c.logger.Error("Failed to create aggregate statistics output file", "err", ferr)
+		return ferr
+	}
+	var flushC <-chan time.Time
+	if len(flushFile) > 0 {
+		flushTicker := time.NewTicker(time.Duration(c.cfg.StatsFlushInterval))
+		defer flushTicker.Stop()
+		flushC = flushTicker.C
+	}
+
 	completed := 0
 
 	progressTicker := time.NewTicker(coordProgressUpdateInterval)
 	defer progressTicker.Stop()
 
-	c.startTime = time.Now()
-	c.lastProgressUpdate = c.startTime
+	// progress logging is optional
+	var progressLogC <-chan time.Time
+	if c.cfg.ProgressInterval > 0 {
+		progressLogTicker := time.NewTicker(time.Duration(c.cfg.ProgressInterval))
+		defer progressLogTicker.Stop()
+		progressLogC = progressLogTicker.C
+	}
+
+	// a resumed load test started before the coordinator was restarted, and
+	// some of its workers may have already reported their final statistics
+	if c.resumed {
+		c.resumed = false
+		for _, state := range c.statePerWorker {
+			if state == workerCompleted || state == workerCancelled {
+				completed++
+			}
+		}
+		if completed+len(c.failedWorkers) >= c.participants() {
+			return c.finishLoadTest(completed)
+		}
+		if c.cancelling {
+			c.cancel()
+		}
+	} else {
+		c.startTime = time.Now()
+	}
+	c.lastProgressUpdate = time.Now()
+	c.publishLiveStats(false)
+
+	// the load test is checkpointed, so that it can be resumed if the
+	// coordinator crashes
+	var checkpointC <-chan time.Time
+	if len(c.coordCfg.StateFile) > 0 {
+		checkpointTicker := time.NewTicker(c.coordCfg.checkpointInterval())
+		defer checkpointTicker.Stop()
+		checkpointC = checkpointTicker.C
+		c.checkpoint()
+	}
+
+	// workers that disconnect cleanly may still have their final updates
+	// queued when we process their unregistration
+	disconnected := make(map[string]bool)
+
+	// once cancelled, we give the workers some time to report the statistics
+	// they gathered until then
+	stopC := c.stop
+	var cancelTimeoutC <-chan time.Time
 
 	for {
 		select {
 		case msg := <-c.workerUpdate:
 			c.logger.Debug("Got update from worker", "msg", msg)
-			if _, exists := c.workers[msg.ID]; !exists {
-				c.logger.Error("Got message from unregistered worker - ignoring", "id", msg.ID)
+			if _, exists := c.workers[msg.ID]; !exists && !disconnected[msg.ID] {
+				c.logger.Warn("Got message from unregistered worker - ignoring", "id", msg.ID)
 				continue
 			}
+			c.foldStatsInterval(&msg)
+			c.trackWorkerMetrics(msg)
 			// keep track of how many transactions this worker has reported
 			if msg.TxCount > 0 {
 				c.totalTxsPerWorker[msg.ID] = msg.TxCount
@@ -281,39 +978,191 @@ func (c *Coordinator) receiveTestingUpdates() error {
 			if msg.TotalTxBytes > 0 {
 				c.totalBytesPerWorker[msg.ID] = msg.TotalTxBytes
 			}
+			if msg.Mempool != nil {
+				c.mempoolPerWorker[msg.ID] = *msg.Mempool
+			}
+			if msg.EndpointHealth != nil {
+				c.healthPerWorker[msg.ID] = *msg.EndpointHealth
+			}
+			if msg.BroadcastLatency != nil {
+				c.broadcastLatPerWorker[msg.ID] = msg.BroadcastLatency
+			}
+			if msg.CommitLatency != nil {
+				c.commitLatPerWorker[msg.ID] = msg.CommitLatency
+			}
+			if msg.CommitLatencyByPriority != nil {
+				c.priorityLatPerWorker[msg.ID] = msg.CommitLatencyByPriority
+			}
+			if msg.Endpoints != nil {
+				c.endpointsPerWorker[msg.ID] = msg.Endpoints
+			}
+			if msg.Stats != nil {
+				c.statsPerWorker[msg.ID] = *msg.Stats
+			}
+			if msg.Resources != nil {
+				c.resourcesPerWorker[msg.ID] = c.resourcesPerWorker[msg.ID].peak(*msg.Resources)
+			}
+			if len(msg.IntervalTxs) > 0 {
+				c.intervalTxsPerWorker[msg.ID] = c.alignIntervalTxs(msg.ID, msg.IntervalTxs)
+			}
+			if msg.State == workerTesting || msg.State == workerCompleted || msg.State == workerCancelled {
+				c.progressPerWorker[msg.ID] = progressStatus{
+					Ratio:    msg.Progress,
+					Failures: msg.Failures,
+					ETA:      time.Duration(msg.ETASeconds * float64(time.Second)),
+				}
+			}
+			if len(msg.State) > 0 {
+				c.statePerWorker[msg.ID] = msg.State
+			}
+			if tw != nil && len(msg.Timeseries) > 0 {
+				if err := tw.Write(msg.ID, c.alignTimeseries(msg.ID, msg.Timeseries)...); err != nil {
+					c.logger.Error("Failed to write raw statistics", "err", err)
+				}
+			}
+			c.publishLiveStats(false)
 
 			switch msg.State {
 			case workerTesting:
-				c.logger.Debug("Update from remote worker", "id", msg.ID, "txCount", msg.TxCount)
-
-			case workerCompleted:
-				c.logger.Debug("Worker completed its testing", "id", msg.ID)
+				c.logger.Debug("Update from remote worker", c.workerFields(msg.ID, "txCount", msg.TxCount)...)
+				e := WorkerProgressEvent{
+					WorkerEvent:  c.workerEvent(msg.ID),
+					TxCount:      msg.TxCount,
+					TotalTxBytes: msg.TotalTxBytes,
+					Progress:     msg.Progress,
+					Failures:     msg.Failures,
+					ETA:          time.Duration(msg.ETASeconds * float64(time.Second)),
+				}
+				c.publishEvent(func(l CoordinatorEvents) { l.WorkerProgress(e) })
+
+			case workerCompleted, workerCancelled:
+				c.logger.Debug("Worker completed its testing", c.workerFields(msg.ID, "state", msg.State)...)
+				e := WorkerFinishedEvent{
+					WorkerEvent:  c.workerEvent(msg.ID),
+					Cancelled:    msg.State == workerCancelled,
+					TxCount:      msg.TxCount,
+					TotalTxBytes: msg.TotalTxBytes,
+					Stats:        msg.Stats,
+				}
+				c.publishEvent(func(l CoordinatorEvents) { l.WorkerFinished(e) })
+				c.trackWorkerSendEnd(msg.DrainSeconds)
 				completed++
-				if completed >= c.coordCfg.ExpectWorkers {
-					c.logger.Info("All workers completed their load testing")
-					c.logTestingProgress(completed)
-					return nil
+				if completed+len(c.failedWorkers) >= c.participants() {
+					return c.finishLoadTest(completed)
 				}
 
 			case workerFailed:
-				return fmt.Errorf(msg.Error)
+				if err := c.handleWorkerFailure(msg.ID, fmt.Errorf(msg.Error)); err != nil {
+					return err
+				}
+				if completed+len(c.failedWorkers) >= c.participants() {
+					return c.finishLoadTest(completed)
+				}
 
 			default:
 				return fmt.Errorf("unexpected state from remote worker: %s", msg.State)
 			}
 
+		case req := <-c.workerRegister:
+			if req.rw.resuming {
+				req.resp <- c.resumeRemoteWorker(req.rw)
+				c.publishLiveStats(false)
+				continue
+			}
+			if err := c.registerLateWorker(req.rw); err != nil {
+				req.resp <- err
+				continue
+			}
+			req.resp <- nil
+			if err := req.rw.StartLoadTest(); err != nil {
+				c.logger.Error("Failed to start load test for worker", c.workerFields(req.rw.ID(), "err", err)...)
+				return err
+			}
+			c.publishWorkerStarted(req.rw)
+			// a worker joining a paused load test starts off paused
+			if c.pauseClk.isPaused() {
+				req.rw.SetPaused(true)
+			}
+			c.publishLiveStats(false)
+
+		case req := <-c.pauseRequest:
+			req.resp <- c.handlePauseRequest(req)
+
+		case req := <-c.shutdownRequest:
+			req.resp <- c.handleShutdownRequest(req)
+
 		case req := <-c.workerUnregister:
-			c.unregisterRemoteWorker(req.id)
+			id := req.rw.ID()
+			// the worker may have already reconnected
+			if c.workers[id] != req.rw {
+				continue
+			}
+			c.unregisterRemoteWorker(id)
+			if req.lost && req.rw.reconnectTime > 0 {
+				c.reconnectDeadlines[id] = time.Now().Add(time.Duration(req.rw.reconnectTime))
+				c.logger.Warn("Lost connection to worker - waiting for it to reconnect", c.workerFields(id, "err", req.err, "timeout", req.rw.reconnectTime.String())...)
+				c.publishLiveStats(false)
+				continue
+			}
+			disconnected[id] = true
 			if req.err != nil {
-				return fmt.Errorf("remote worker failed: %s", req.err.Error())
+				if err := c.handleWorkerFailure(id, fmt.Errorf("remote worker failed: %s", req.err.Error())); err != nil {
+					return err
+				}
+				if completed+len(c.failedWorkers) >= c.participants() {
+					return c.finishLoadTest(completed)
+				}
 			}
+			c.publishLiveStats(false)
 
 		case <-progressTicker.C:
-			c.logTestingProgress(completed)
+			for id, deadline := range c.reconnectDeadlines {
+				if time.Now().After(deadline) {
+					delete(c.reconnectDeadlines, id)
+					if err := c.handleWorkerFailure(id, fmt.Errorf("worker %s failed to reconnect", id)); err != nil {
+						return err
+					}
+				}
+			}
+			if len(c.failedWorkers) > 0 && completed+len(c.failedWorkers) >= c.participants() {
+				return c.finishLoadTest(completed)
+			}
+			c.logTestingProgress(completed, false)
 
-		case <-c.stop:
-			c.logger.Debug("Load testing cancel signal received")
-			return fmt.Errorf("load testing cancelled")
+		case <-checkpointC:
+			c.checkpoint()
+
+		case <-flushC:
+			c.flushInterimStats(flushFile)
+			if tw != nil {
+				if err := tw.Sync(); err != nil {
+					c.logger.Error("Failed to flush raw statistics", "err", err)
+				}
+			}
+
+		case <-progressLogC:
+			c.logger.Info("Progress", append(c.progress.logKVs(), "totalTxs", c.totalTxs, "totalBytes", c.totalBytes)...)
+			c.progressRecord().log(c.logger)
+
+		case <-stopC:
+			c.logger.Info("Load test cancelled - waiting for workers to report their final statistics")
+			c.flushInterimStats(flushFile)
+			c.cancelling = true
+			stopC = nil
+			for _, rw := range c.workers {
+				c.cancelRemoteWorker(rw)
+			}
+			drain := time.Duration(c.cfg.DrainTimeout)
+			if c.wasTerminated() {
+				// we have to exit before we're killed
+				drain = terminationDrain(*c.cfg)
+			}
+			cancelTimeoutC = time.After(drain + coordCancelTimeout)
+			c.publishLiveStats(false)
+
+		case <-cancelTimeoutC:
+			c.logger.Warn("Timed out waiting for workers to report their final statistics - using their latest progress updates instead", "reported", completed, "participants", c.participants())
+			return c.finishCancelled(completed)
 
 		case <-c.svrStopped:
 			return fmt.Errorf("web server stopped unexpectedly")
@@ -321,6 +1170,204 @@ func (c *Coordinator) receiveTestingUpdates() error {
 	}
 }
 
+// finishLoadTest reports on the load test once all of the workers taking part
+// have either completed (or, if the load test was cancelled, reported their
+// final statistics) or failed. Must only be called from the coordinator's
+// event loop.
+func (c *Coordinator) finishLoadTest(completed int) error {
+	if completed == 0 {
+		return fmt.Errorf("all workers failed")
+	}
+	if c.cancelling {
+		c.logger.Info("All workers reported their final statistics", "failed", len(c.failedWorkers))
+		return c.finishCancelled(completed)
+	}
+	if len(c.failedWorkers) > 0 {
+		c.logger.Info("All remaining workers completed their load testing", "failed", len(c.failedWorkers))
+	} else {
+		c.logger.Info("All workers completed their load testing")
+	}
+	c.logTestingProgress(completed, true)
+	c.publishLiveStats(true)
+	return c.writeFinalStats()
+}
+
+// handleWorkerFailure handles the failure of a worker during the load test,
+// returning the given error if the load test can't carry on without the
+// worker. Otherwise the worker no longer counts towards the workers the load
+// test waits for (or the maximum number of workers). Must only be called from
+// the coordinator's event loop.
+func (c *Coordinator) handleWorkerFailure(id string, err error) error {
+	if !c.failedWorkers[id] {
+		e := WorkerFailedEvent{WorkerEvent: c.workerEvent(id), Err: err}
+		c.publishEvent(func(l CoordinatorEvents) { l.WorkerFailed(e) })
+	}
+	if !c.coordCfg.ContinueOnWorkerFailure {
+		return err
+	}
+	if c.failedWorkers[id] {
+		return nil
+	}
+	c.failedWorkers[id] = true
+	c.statePerWorker[id] = workerFailed
+	c.logger.Warn("Worker failed - continuing the load test without it", c.workerFields(id, "err", err)...)
+	if shard := c.endpointShard(id); len(shard) > 0 && c.coordCfg.RedistributeShards {
+		c.logger.Info("Failed worker's endpoints will be given to the next worker to join", c.workerFields(id, "endpoints", strings.Join(shard, ","))...)
+	}
+	// the remaining workers make up the failed worker's share of the total
+	// rate
+	c.splitTotalRate()
+	c.publishLiveStats(false)
+	return nil
+}
+
+// finishCancelled reports on a cancelled load test, with the statistics the
+// workers gathered until then, failing any workers that haven't reported
+// their final statistics. Must only be called from the coordinator's event
+// loop.
+func (c *Coordinator) finishCancelled(completed int) error {
+	for id, rw := range c.workers {
+		if state := c.statePerWorker[id]; state != workerCompleted && state != workerCancelled {
+			_ = rw.Fail(ErrLoadTestCancelled.Error())
+		}
+	}
+	c.logTestingProgress(completed, true)
+	c.publishLiveStats(true)
+	if err := c.writeFinalStats(); err != nil {
+		return err
+	}
+	if c.wasTerminated() {
+		return ErrLoadTestTerminated
+	}
+	return ErrLoadTestCancelled
+}
+
+// trackWorkerSendEnd estimates when a worker that has just completed its
+// testing stopped sending transactions, given how long it reported draining.
+func (c *Coordinator) trackWorkerSendEnd(drainSeconds float64) {
+	sendEnd := time.Now().Add(-time.Duration(drainSeconds * float64(time.Second)))
+	if sendEnd.After(c.sendEndTime) {
+		c.sendEndTime = sendEnd
+	}
+}
+
+// registerLateWorker registers a worker that connected once the load test was
+// already underway, as long as there's room for it and enough of the load test
+// left for it to take part.
+func (c *Coordinator) registerLateWorker(rw *remoteWorker) error {
+	if c.coordCfg.MaxWorkers > 0 && c.participants()-len(c.failedWorkers) >= c.coordCfg.MaxWorkers {
+		return fmt.Errorf("too many workers")
+	}
+	id := rw.ID()
+	if err := c.checkUniqueWorkerID(rw); err != nil {
+		return err
+	}
+	// workers can only reconnect before the load test starts
+	if _, exists := c.totalTxsPerWorker[id]; exists {
+		return fmt.Errorf("worker with ID %s has already taken part in this load test", id)
+	}
+	elapsed := time.Since(c.startTime).Seconds()
+	if limit := c.cfg.timeLimit(); limit > 0 && limit.Seconds()-elapsed < 1 {
+		return fmt.Errorf("load test is about to end")
+	}
+	rw.setJoinedAt(elapsed)
+	if err := c.registerRemoteWorker(rw); err != nil {
+		return err
+	}
+	c.joinedAtPerWorker[id] = elapsed
+	c.assignLateWorkerShard(id)
+	c.splitTotalRate()
+	c.workersStartedMetric.Set(float64(c.participants()))
+	c.logger.Info("Worker joined load test already underway", c.workerFields(id, "elapsed", fmt.Sprintf("%.1fs", elapsed))...)
+	return nil
+}
+
+// resumeRemoteWorker re-registers a worker that lost its connection during the
+// load test, superseding its previous connection if we haven't noticed that
+// it was lost yet. The worker's totals are carried over, so any progress it
+// reports again isn't double counted.
+func (c *Coordinator) resumeRemoteWorker(rw *remoteWorker) error {
+	id := rw.ID()
+	_, awaited := c.reconnectDeadlines[id]
+	prev, connected := c.workers[id]
+	if !awaited && !connected {
+		return fmt.Errorf("worker with ID %s is not taking part in this load test", id)
+	}
+	if connected {
+		c.unregisterRemoteWorker(id)
+		prev.Stop()
+	}
+	if err := c.registerRemoteWorker(rw); err != nil {
+		return err
+	}
+	delete(c.reconnectDeadlines, id)
+	c.statePerWorker[id] = workerTesting
+	// the worker may have missed a pause or resume request (or the load test
+	// being cancelled) while it was disconnected
+	rw.SetPaused(c.pauseClk.isPaused())
+	if share := c.workerTxRate(); share > 0 {
+		rw.SetTxRate(share)
+	}
+	if c.cancelling {
+		c.cancelRemoteWorker(rw)
+	}
+	c.logger.Info("Worker reconnected", c.workerFields(id)...)
+	return nil
+}
+
+// participants returns the number of workers taking part in the load test,
+// including those that joined once it was underway.
+func (c *Coordinator) participants() int {
+	return c.startedWorkers + len(c.joinedAtPerWorker)
+}
+
+// alignTimeseries shifts the timeseries samples reported by a worker that
+// joined the load test once it was underway, which are relative to the start
+// of its own load test, to be relative to the start of the overall load test.
+func (c *Coordinator) alignTimeseries(id string, samples []timeseriesSample) []timeseriesSample {
+	offset := c.workerStartOffset(id)
+	if offset == 0 {
+		return samples
+	}
+	aligned := make([]timeseriesSample, len(samples))
+	for i, sample := range samples {
+		sample.TSeconds += offset
+		aligned[i] = sample
+	}
+	return aligned
+}
+
+// alignIntervalTxs pads the per-window transaction counts reported by a worker
+// that joined the load test once it was underway with the windows it missed.
+func (c *Coordinator) alignIntervalTxs(id string, counts []int) []int {
+	offset := c.workerStartOffset(id)
+	if offset == 0 || c.cfg.RateWindow <= 0 {
+		return counts
+	}
+	missed := int(math.Round(offset / c.cfg.RateWindow.Seconds()))
+	return append(make([]int, missed), counts...)
+}
+
+// targetTxRateShare returns the given worker's contribution to the target
+// transaction rate of a load test lasting totalTime seconds. Workers that
+// joined the load test once it was underway only contributed for part of it.
+func (c *Coordinator) targetTxRateShare(ws WorkerStats, totalTime float64) float64 {
+	// when workers' starts are staggered, each worker is only active for the
+	// duration of the load test, from its own start onwards
+	if offset, staggered := c.startOffsetPerWorker[ws.ID]; staggered && totalTime > 0 {
+		active := totalTime - offset
+		if limit := c.cfg.timeLimit(); limit > 0 {
+			active = math.Min(limit.Seconds(), active)
+		}
+		return ws.TargetTxRate * math.Max(0, active) / totalTime
+	}
+	joinedAt, late := c.joinedAtPerWorker[ws.ID]
+	if !late || totalTime <= 0 {
+		return ws.TargetTxRate
+	}
+	return ws.TargetTxRate * math.Max(0, totalTime-joinedAt) / totalTime
+}
+
 func (c *Coordinator) RegisterRemoteWorker(rw *remoteWorker) error {
 	c.logger.Debug("Attempting to register remote worker")
 	resp := make(chan error, 1)
@@ -338,35 +1385,209 @@ func (c *Coordinator) RegisterRemoteWorker(rw *remoteWorker) error {
 }
 
 func (c *Coordinator) registerRemoteWorker(rw *remoteWorker) error {
-	c.logger.Debug("Attempting to register remote worker", "id", rw.ID())
-	if len(c.workers) >= c.coordCfg.ExpectWorkers {
-		return fmt.Errorf("too many workers")
+	id, labels := rw.ID(), rw.Labels()
+	c.logger.Debug("Attempting to register remote worker", "id", id, "labels", formatWorkerLabels(labels))
+	if err := c.checkUniqueWorkerID(rw); err != nil {
+		return err
 	}
+	rw.setRun(c.run)
+	c.workers[id] = rw
+	c.workerInfo.set(id, labels)
+	if rw.build != nil {
+		c.buildPerWorker[id] = *rw.build
+	}
+	// a reconnecting worker retains the totals it reported before
+	if _, exists := c.totalTxsPerWorker[id]; !exists {
+		c.totalTxsPerWorker[id] = 0
+		c.totalBytesPerWorker[id] = 0
+	}
+	c.statePerWorker[id] = workerAccepted
+	c.setConnectedWorkers(len(c.workers))
+	// make sure the worker's series exist from the start
+	c.workerTxsMetric.WithLabelValues(id)
+	c.workerBytesMetric.WithLabelValues(id)
+	c.workerFailuresMetric.WithLabelValues(id)
+	c.workerConnectedMetric.WithLabelValues(id).Set(1)
+	c.workerMissedHeartbeatsMetric.WithLabelValues(id)
+	c.logger.Info("Added remote worker", c.workerFields(id)...)
+	e := WorkerRegisteredEvent{WorkerEvent: c.workerEvent(id), Resumed: rw.resuming}
+	c.publishEvent(func(l CoordinatorEvents) { l.WorkerRegistered(e) })
+	return nil
+}
+
+// checkUniqueWorkerID rejects a worker registering with the ID of a worker
+// that's already connected (e.g. two workers accidentally configured with the
+// same ID), whose statistics would otherwise be mixed up. A worker resuming its
+// load test supersedes its previous connection instead (see
+// resumeRemoteWorker).
+func (c *Coordinator) checkUniqueWorkerID(rw *remoteWorker) error {
 	id := rw.ID()
-	if _, exists := c.workers[id]; exists {
-		return fmt.Errorf("worker with ID %s already exists", id)
+	prev, exists := c.workers[id]
+	if !exists {
+		return nil
+	}
+	c.duplicateIDsMetric.Inc()
+	err := fmt.Errorf("a worker with ID %s is already registered - each worker must have a unique ID", id)
+	c.logger.Error("Rejected worker registration", "id", id, "labels", formatWorkerLabels(rw.Labels()), "registeredLabels", formatWorkerLabels(prev.Labels()), "err", err)
+	return err
+}
+
+// publishEvent queues an event for delivery to the listener set via
+// SetEvents, if any.
+func (c *Coordinator) publishEvent(deliver func(CoordinatorEvents)) {
+	if c.events != nil {
+		c.events.publish(deliver)
+	}
+}
+
+// workerEvent identifies the given worker (and the current run) in an event
+// happening now.
+func (c *Coordinator) workerEvent(id string) WorkerEvent {
+	return WorkerEvent{WorkerID: id, Labels: c.workerInfo.get(id), Run: c.run, Time: time.Now()}
+}
+
+func (c *Coordinator) publishWorkerStarted(rw *remoteWorker) {
+	e := WorkerStartedEvent{
+		WorkerEvent:   c.workerEvent(rw.ID()),
+		StartDelay:    time.Duration(rw.getStartDelay() * float64(time.Second)),
+		JoinedSeconds: rw.getJoinedAt(),
+	}
+	c.publishEvent(func(l CoordinatorEvents) { l.WorkerStarted(e) })
+}
+
+// checkClientFactories checks that a registering worker supports the client
+// factories used by the load test. Workers that don't say which client
+// factories they support (those predating protocol version 1.7) can't be
+// checked. Safe to call from any goroutine.
+func (c *Coordinator) checkClientFactories(rw *remoteWorker) error {
+	if len(rw.clientFactories) == 0 {
+		return nil
+	}
+	supported := make(map[string]bool)
+	for _, factory := range rw.clientFactories {
+		supported[factory] = true
+	}
+	for _, factory := range c.config().clientFactories() {
+		if !supported[factory] {
+			err := fmt.Errorf("worker doesn't support client factory \"%s\" (it only supports %s)", factory, strings.Join(rw.clientFactories, ", "))
+			c.logger.Error("Rejected worker registration", "id", rw.ID(), "labels", formatWorkerLabels(rw.Labels()), "err", err)
+			return err
+		}
 	}
-	c.workers[id] = rw
-	c.totalTxsPerWorker[id] = 0
-	c.totalBytesPerWorker[id] = 0
-	c.logger.Info("Added remote worker", "id", id)
 	return nil
 }
 
-func (c *Coordinator) UnregisterRemoteWorker(id string, err error) {
-	c.workerUnregister <- remoteWorkerUnregisterRequest{id: id, err: err}
+// authenticateWorker checks the auth token presented by a registering worker,
+// if the coordinator requires one. Safe to call from any goroutine.
+func (c *Coordinator) authenticateWorker(rw *remoteWorker, token string) error {
+	if len(c.coordCfg.AuthToken) == 0 {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(c.coordCfg.AuthToken)) == 1 {
+		return nil
+	}
+	c.rejectedRegsMetric.Inc()
+	err := fmt.Errorf("invalid auth token")
+	if len(token) == 0 {
+		err = fmt.Errorf("an auth token is required to register with this coordinator")
+	}
+	c.logger.Error("Rejected worker registration", "id", rw.ID(), "labels", formatWorkerLabels(rw.Labels()), "err", err)
+	return err
+}
+
+// workerFields returns the key/value pairs with which to log a message about
+// the given worker: its ID and labels (if any), followed by the given pairs.
+func (c *Coordinator) workerFields(id string, kvpairs ...interface{}) []interface{} {
+	fields := []interface{}{"id", id}
+	if labels := c.workerInfo.get(id); len(labels) > 0 {
+		fields = append(fields, "labels", formatWorkerLabels(labels))
+	}
+	return append(fields, kvpairs...)
+}
+
+func (c *Coordinator) UnregisterRemoteWorker(rw *remoteWorker, lost bool, err error) {
+	c.workerUnregister <- remoteWorkerUnregisterRequest{rw: rw, lost: lost, err: err}
 }
 
 func (c *Coordinator) unregisterRemoteWorker(id string) {
 	delete(c.workers, id)
-	c.logger.Info("Unregistered worker", "id", id)
+	c.setConnectedWorkers(len(c.workers))
+	c.workerConnectedMetric.WithLabelValues(id).Set(0)
+	c.logger.Info("Unregistered worker", c.workerFields(id)...)
 }
 
 func (c *Coordinator) ReceiveWorkerUpdate(msg workerMsg) {
 	c.workerUpdate <- msg
 }
 
-func (c *Coordinator) logTestingProgress(completed int) {
+// workerTotals are the cumulative totals reported by a worker.
+type workerTotals struct {
+	txs      int
+	bytes    int64
+	failures int
+}
+
+// counterDelta returns by how much a counter must be incremented when a
+// worker reports the given cumulative total, having last reported the given
+// baseline. A total below the baseline means that the worker restarted its
+// count (e.g. because it restarted), in which case the whole total is new.
+func counterDelta(total, baseline int64) int64 {
+	if total < baseline {
+		return total
+	}
+	return total - baseline
+}
+
+// trackWorkerMetrics increments the coordinator's Prometheus counters by the
+// difference between the totals in the given worker update and those the
+// worker last reported. Workers report absolute totals, so this ensures the
+// counters never go backwards or double-count if a worker reconnects (or
+// restarts). The gauges of the worker's resource usage are simply updated.
+func (c *Coordinator) trackWorkerMetrics(msg workerMsg) {
+	last := c.reportedPerWorker[msg.ID]
+	reported := last
+	if msg.TxCount > 0 {
+		reported.txs = msg.TxCount
+	}
+	if msg.TotalTxBytes > 0 {
+		reported.bytes = msg.TotalTxBytes
+	}
+	if msg.State == workerTesting || msg.State == workerCompleted {
+		reported.failures = msg.Failures
+	}
+	if delta := counterDelta(int64(reported.txs), int64(last.txs)); delta > 0 {
+		c.totalTxsMetric.Add(float64(delta))
+		c.workerTxsMetric.WithLabelValues(msg.ID).Add(float64(delta))
+	}
+	if delta := counterDelta(reported.bytes, last.bytes); delta > 0 {
+		c.totalBytesMetric.Add(float64(delta))
+		c.workerBytesMetric.WithLabelValues(msg.ID).Add(float64(delta))
+	}
+	if delta := counterDelta(int64(reported.failures), int64(last.failures)); delta > 0 {
+		c.workerFailuresMetric.WithLabelValues(msg.ID).Add(float64(delta))
+	}
+	c.reportedPerWorker[msg.ID] = reported
+	if r := msg.Resources; r != nil {
+		c.workerCPUMetric.WithLabelValues(msg.ID).Set(r.CPUPercent)
+		c.workerRSSMetric.WithLabelValues(msg.ID).Set(float64(r.RSSBytes))
+		c.workerGoroutinesMetric.WithLabelValues(msg.ID).Set(float64(r.Goroutines))
+		c.workerGCPauseMetric.WithLabelValues(msg.ID).Set(r.GCPauseSeconds)
+	}
+}
+
+// progressRecord returns the structured record of the progress of all of the
+// workers, as of the last time it was computed.
+func (c *Coordinator) progressRecord() progressRecord {
+	p := c.progress
+	p.Elapsed = time.Since(c.startTime)
+	p.TotalTxs = c.totalTxs
+	p.TxBytes = c.totalBytes
+	return newProgressRecord(progressRecordAggregate, p, c.getBroadcastLatencies())
+}
+
+// logTestingProgress logs the load test's progress and updates the metrics.
+// Once final, it also computes the aggregate statistics.
+func (c *Coordinator) logTestingProgress(completed int, final bool) {
 	totalTxs := 0
 	for _, txCount := range c.totalTxsPerWorker {
 		totalTxs += txCount
@@ -375,7 +1596,8 @@ func (c *Coordinator) logTestingProgress(completed int) {
 	for _, txBytes := range c.totalBytesPerWorker {
 		totalBytes += txBytes
 	}
-	overallElapsed := time.Since(c.startTime).Seconds()
+	// time spent paused must not dilute the overall rate
+	overallElapsed := c.pauseClk.activeSeconds(c.startTime, time.Now())
 	elapsed := time.Since(c.lastProgressUpdate).Seconds()
 
 	overallAvgRate := float64(0)
@@ -390,7 +1612,7 @@ func (c *Coordinator) logTestingProgress(completed int) {
 		avgDataRate = float64(totalBytes-c.totalBytes) / elapsed
 	}
 
-	c.logger.Info(
+	c.logger.Debug(
 		"Progress",
 		"totalTxs", totalTxs,
 		"overallAvgRate", fmt.Sprintf("%.2f txs/sec", overallAvgRate),
@@ -398,40 +1620,366 @@ func (c *Coordinator) logTestingProgress(completed int) {
 		"totalBytes", totalBytes,
 	)
 
+	// workers that haven't reported their progress yet count as not having
+	// made any
+	c.progress = progressStatus{TxRate: avgRate}
+	for _, wp := range c.progressPerWorker {
+		c.progress.Ratio += wp.Ratio
+		c.progress.Failures += wp.Failures
+		if wp.ETA > c.progress.ETA {
+			c.progress.ETA = wp.ETA
+		}
+	}
+	if participants := c.participants(); participants > 0 {
+		c.progress.Ratio /= float64(participants)
+	}
+	c.progressRatioMetric.Set(c.progress.Ratio)
+
 	c.lastProgressUpdate = time.Now()
 	c.totalTxs = totalTxs
 	c.totalBytes = totalBytes
-	c.totalTxsMetric.Set(float64(totalTxs))
-	c.totalBytesMetric.Set(float64(totalBytes))
 	c.txRateMetric.Set(avgRate)
 	c.txDataRateMetric.Set(avgDataRate)
 	c.overallTxRateMetric.Set(overallAvgRate)
 	c.workersCompletedMetric.Set(float64(completed))
 
-	// if we're done and we need to write aggregate statistics
-	if completed >= c.coordCfg.ExpectWorkers && len(c.cfg.StatsOutputFile) > 0 {
-		stats := AggregateStats{
-			TotalTxs:         totalTxs,
-			TotalTimeSeconds: overallElapsed,
-			TotalBytes:       totalBytes,
+	var mempool *MempoolStats
+	if c.cfg.MempoolPauseThreshold > 0 {
+		mempool = &MempoolStats{}
+		for _, workerMempool := range c.mempoolPerWorker {
+			mempool.Pauses += workerMempool.Pauses
+			mempool.PausedSeconds += workerMempool.PausedSeconds
+			mempool.PausedEndpoints += workerMempool.PausedEndpoints
+		}
+		c.mempoolPausesMetric.Set(float64(mempool.Pauses))
+		c.mempoolPausedMetric.Set(mempool.PausedSeconds)
+		c.mempoolPausedEpsMetric.Set(float64(mempool.PausedEndpoints))
+	}
+
+	var endpointHealth *EndpointHealthStats
+	if c.cfg.EndpointFailureThreshold > 0 {
+		endpointHealth = &EndpointHealthStats{}
+		for _, workerHealth := range c.healthPerWorker {
+			endpointHealth.Blacklistings += workerHealth.Blacklistings
+			endpointHealth.Recoveries += workerHealth.Recoveries
+			endpointHealth.HealthyEndpoints += workerHealth.HealthyEndpoints
+			endpointHealth.BlacklistedEndpoints += workerHealth.BlacklistedEndpoints
+		}
+		c.healthyEndpointsMetric.Set(float64(endpointHealth.HealthyEndpoints))
+	}
+
+	broadcastLatencies := newLatencySketch()
+	for _, workerLatencies := range c.broadcastLatPerWorker {
+		broadcastLatencies.Merge(workerLatencies)
+	}
+	c.setBroadcastLatencies(broadcastLatencies)
+
+	var commitLatency *LatencyStats
+	var commitLatencyByPriority []PriorityLatencyStats
+	if c.cfg.TrackCommitLatency {
+		merged := newLatencySketch()
+		for _, workerLatencies := range c.commitLatPerWorker {
+			merged.Merge(workerLatencies)
+		}
+		c.setCommitLatencies(merged)
+		commitLatency = merged.Stats()
+
+		mergedByPriority := make(map[int64]*latencySketch)
+		for _, workerLatencies := range c.priorityLatPerWorker {
+			for priority, sketch := range workerLatencies {
+				if _, exists := mergedByPriority[priority]; !exists {
+					mergedByPriority[priority] = newLatencySketch()
+				}
+				mergedByPriority[priority].Merge(sketch)
+			}
+		}
+		commitLatencyByPriority = priorityLatencyStats(mergedByPriority)
+	}
+
+	// the time spent by workers draining in-flight responses must not
+	// dilute the average rates
+	totalTime := overallElapsed
+	if !c.sendEndTime.IsZero() {
+		totalTime = c.pauseClk.activeSeconds(c.startTime, c.sendEndTime)
+	}
+	workerStats := c.workerStats()
+	stats := AggregateStats{
+		TotalTxs:                totalTxs,
+		TotalTimeSeconds:        totalTime,
+		TotalBytes:              totalBytes,
+		Mempool:                 mempool,
+		EndpointHealth:          endpointHealth,
+		BroadcastLatency:        broadcastLatencies.Stats(),
+		CommitLatency:           commitLatency,
+		CommitLatencyByPriority: commitLatencyByPriority,
+		Endpoints:               c.endpointStats(),
+	}
+	if c.cfg.RateWindow > 0 {
+		sets := make([][]int, 0, len(c.intervalTxsPerWorker))
+		for _, counts := range c.intervalTxsPerWorker {
+			sets = append(sets, counts)
+		}
+		stats.setIntervalRates(intervalRateStats(mergeIntervalTxCounts(sets...), time.Duration(c.cfg.RateWindow)))
+	}
+	for _, ws := range workerStats {
+		stats.TargetTxRate += c.targetTxRateShare(ws, totalTime)
+		stats.FailedTxs += ws.Failures
+		stats.ErroredConnections += ws.ErroredConnections
+		stats.PregeneratedTxs += ws.PregeneratedTxs
+	}
+	// the workers' shares are rebalanced as they join or fail, so as to
+	// add up to the total rate throughout
+	if c.coordCfg.TotalRate > 0 {
+		stats.TargetTxRate = c.coordCfg.TotalRate
+	}
+	stats.ExcludedEndpoints = c.excludedEndpoints
+	// until we're done, the statistics so far are kept to flush to the
+	// statistics output file
+	if !final {
+		c.interimStats = &stats
+		return
+	}
+
+	// once we're done, report on the aggregate statistics
+	if c.cfg.ChainStats && len(c.cfg.Endpoints) > 0 {
+		endpoints, err := resolveRPCEndpoints(c.cfg.Endpoints[:1], c.cfg.RPCVersion, c.logger)
+		if err != nil {
+			c.logger.Error("Failed to resolve endpoint for chain statistics", "err", err)
+		} else {
+			sendEndTime := c.sendEndTime
+			if sendEndTime.IsZero() {
+				sendEndTime = time.Now()
+			}
+			stats.Chain = collectChainStats(*c.cfg, endpoints[0], c.startTime, sendEndTime, c.logger)
+		}
+	}
+	stats.Compute()
+	switch {
+	case c.cancelling && c.wasTerminated():
+		stats.Status = StatsStatusTerminated
+	case c.cancelling:
+		stats.Status = StatsStatusCancelled
+	}
+	c.setFinalReport(NewReport(*c.cfg, stats, c.workerStats()))
+	warnOnRateShortfall(c.logger, stats, c.cfg.MaxRateDeviation)
+}
+
+// flushInterimStats writes the aggregate statistics last calculated while the
+// load test is underway to the given statistics output file, if any. Must only
+// be called from the coordinator's event loop.
+func (c *Coordinator) flushInterimStats(filename string) {
+	if len(filename) == 0 || c.interimStats == nil {
+		return
+	}
+	if err := flushStats(filename, *c.cfg, *c.interimStats, c.workerStats()); err != nil {
+		c.logger.Error("Failed to flush aggregate statistics", "err", err)
+	}
+}
+
+// writeFinalStats writes the final statistics to the statistics output file,
+// if any. Failing to write them doesn't fail the load test, but failing to
+// upload them does if the upload is required.
+func (c *Coordinator) writeFinalStats() error {
+	report := c.Report()
+	if report == nil || len(c.cfg.StatsOutputFile) == 0 {
+		return nil
+	}
+	out, err := newStatsOutput(c.cfg.StatsOutputFile)
+	if err != nil {
+		c.logger.Error("Failed to create aggregate statistics output file", "err", err)
+		return nil
+	}
+	if err := writeReport(out.filename, c.cfg.StatsOutputFormat, *report); err != nil {
+		c.logger.Error("Failed to write aggregate statistics", "err", err)
+		return nil
+	}
+	return out.finish(*c.cfg, c.logger)
+}
+
+// workerStats returns the statistics reported by each worker. Workers that
+// have not reported their final statistics are summarized from their latest
+// progress updates.
+func (c *Coordinator) workerStats() []WorkerStats {
+	stats := make([]WorkerStats, 0, len(c.totalTxsPerWorker))
+	for id, totalTxs := range c.totalTxsPerWorker {
+		ws, exists := c.statsPerWorker[id]
+		if !exists {
+			ws = WorkerStats{
+				ID:         id,
+				TotalTxs:   totalTxs,
+				TotalBytes: c.totalBytesPerWorker[id],
+				Failures:   c.progressPerWorker[id].Failures,
+			}
 		}
-		if err := writeAggregateStats(c.cfg.StatsOutputFile, stats); err != nil {
-			c.logger.Error("Failed to write aggregate statistics", "err", err)
+		ws.Labels = c.workerInfo.get(id)
+		if build, ok := c.buildPerWorker[id]; ok {
+			ws.Build = &build
+		}
+		if peak, ok := c.resourcesPerWorker[id]; ok {
+			ws.PeakResources = &peak
+		}
+		stats = append(stats, ws)
+	}
+	return stats
+}
+
+// endpointStats sums the per-endpoint statistics across all workers.
+func (c *Coordinator) endpointStats() []EndpointStats {
+	if len(c.endpointsPerWorker) == 0 {
+		return nil
+	}
+	stats := make([]EndpointStats, 0)
+	byEndpoint := make(map[string]int)
+	for _, workerEndpoints := range c.endpointsPerWorker {
+		for _, ep := range workerEndpoints {
+			idx, exists := byEndpoint[ep.Endpoint]
+			if !exists {
+				idx = len(stats)
+				byEndpoint[ep.Endpoint] = idx
+				stats = append(stats, EndpointStats{Endpoint: ep.Endpoint, Name: ep.Name, Weight: ep.Weight, JoinedSeconds: ep.JoinedSeconds})
+			}
+			// the endpoint joined when the first worker added it
+			if ep.JoinedSeconds < stats[idx].JoinedSeconds {
+				stats[idx].JoinedSeconds = ep.JoinedSeconds
+			}
+			stats[idx].TargetRate += ep.TargetRate
+			stats[idx].TotalTxs += ep.TotalTxs
 		}
 	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Endpoint < stats[j].Endpoint })
+	return stats
 }
 
 func (c *Coordinator) startLoadTest() error {
-	c.logger.Info("All workers connected - starting load test", "count", len(c.workers))
+	// a dry run ends once all the workers have registered
+	if c.cfg.DryRun {
+		return nil
+	}
+	if c.runs > 1 {
+		c.logger.Info("Starting load test", "workers", len(c.workers), "run", c.run, "runs", c.runs)
+	} else {
+		c.logger.Info("Starting load test", "workers", len(c.workers))
+	}
+	c.startedWorkers = len(c.workers)
+	c.workersStartedMetric.Set(float64(c.startedWorkers))
+	c.assignStartOffsets()
+	c.assignEndpointShards()
+	c.splitTotalRate()
 	for id, rw := range c.workers {
 		if err := rw.StartLoadTest(); err != nil {
-			c.logger.Info("Failed to start load test for worker", "id", id, "err", err)
+			c.logger.Info("Failed to start load test for worker", c.workerFields(id, "err", err)...)
 			return err
 		}
+		c.publishWorkerStarted(rw)
 	}
 	return nil
 }
 
+// assignStartOffsets staggers the starts of the workers taking part in the load
+// test, if configured to, by giving each of them an offset from the start of
+// the load test: successive multiples of the stagger (in order of worker ID),
+// or random offsets within the stagger window.
+func (c *Coordinator) assignStartOffsets() {
+	if c.coordCfg.StartStagger <= 0 {
+		return
+	}
+	ids := make([]string, 0, len(c.workers))
+	for id := range c.workers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	stagger := c.coordCfg.StartStagger.Seconds()
+	window := stagger * float64(len(ids)-1)
+	for i, id := range ids {
+		offset := stagger * float64(i)
+		if c.coordCfg.StartStaggerRandom {
+			offset = rand.Float64() * window
+		}
+		c.startOffsetPerWorker[id] = offset
+		c.workers[id].setStartDelay(offset)
+		c.logger.Debug("Staggering worker's start", c.workerFields(id, "offset", fmt.Sprintf("%.3fs", offset))...)
+	}
+	c.logger.Info("Staggering workers' starts", "stagger", c.coordCfg.StartStagger, "window", fmt.Sprintf("%.3fs", window))
+}
+
+// splitTotalRate splits the total transaction rate (if configured) evenly
+// amongst the workers taking part in the load test that haven't failed, and
+// tells each of them its share. Must only be called from the coordinator's
+// event loop.
+func (c *Coordinator) splitTotalRate() {
+	if c.coordCfg.TotalRate <= 0 {
+		return
+	}
+	workers := c.participants() - len(c.failedWorkers)
+	if workers < 1 {
+		return
+	}
+	share := c.coordCfg.TotalRate / float64(workers)
+	c.mtx.Lock()
+	changed := share != c.txRateShare
+	c.txRateShare = share
+	c.mtx.Unlock()
+	for id, rw := range c.workers {
+		if !c.failedWorkers[id] {
+			rw.SetTxRate(share)
+		}
+	}
+	if changed {
+		c.logger.Info(
+			"Splitting total transaction rate amongst workers",
+			"totalRate", fmt.Sprintf("%.2f txs/sec", c.coordCfg.TotalRate),
+			"workers", workers,
+			"rate", fmt.Sprintf("%.2f txs/sec", share),
+		)
+	}
+}
+
+// workerTxRate returns each worker's share of the total transaction rate
+// (tx/sec), or 0 if no total rate is configured. Until the load test starts,
+// the expected number of workers is assumed to take part.
+func (c *Coordinator) workerTxRate() float64 {
+	if c.coordCfg.TotalRate <= 0 {
+		return 0
+	}
+	c.mtx.Lock()
+	share := c.txRateShare
+	c.mtx.Unlock()
+	if share > 0 {
+		return share
+	}
+	return c.coordCfg.TotalRate / float64(c.expectedWorkers())
+}
+
+// workerStartOffset returns how long after the start of the load test (in
+// seconds) the given worker started, if it joined the load test once it was
+// underway or its start was staggered.
+func (c *Coordinator) workerStartOffset(id string) float64 {
+	if joinedAt, late := c.joinedAtPerWorker[id]; late {
+		return joinedAt
+	}
+	return c.startOffsetPerWorker[id]
+}
+
+// cancelRemoteWorker asks the worker to cancel its load test and report its
+// final statistics, shutting it down afterwards if the workers are to be shut
+// down.
+func (c *Coordinator) cancelRemoteWorker(rw *remoteWorker) {
+	if c.shuttingDownWorkers || c.coordCfg.ShutdownWorkersOnCompletion {
+		rw.Shutdown(true)
+	} else {
+		rw.Cancel()
+	}
+}
+
+// shutdownAllRemoteWorkers tells all of the connected workers to shut down and
+// exit cleanly.
+func (c *Coordinator) shutdownAllRemoteWorkers(force bool) {
+	c.logger.Debug("Shutting down all remote workers", "force", force)
+	for _, rw := range c.workers {
+		rw.Shutdown(force)
+	}
+}
+
 func (c *Coordinator) failAllRemoteWorkers(reason string) {
 	c.logger.Debug("Failing all remote workers", "reason", reason)
 	for _, rw := range c.workers {
@@ -441,7 +1989,15 @@ func (c *Coordinator) failAllRemoteWorkers(reason string) {
 }
 
 func (c *Coordinator) newWebSocketHandler() func(w http.ResponseWriter, r *http.Request) {
+	// compression is only used with workers that ask for it too
+	upgrader := wsUpgrader
+	upgrader.EnableCompression = c.coordCfg.EnableCompression
 	return func(w http.ResponseWriter, r *http.Request) {
+		// browsers get the dashboard instead
+		if !websocket.IsWebSocketUpgrade(r) {
+			c.handleDashboard(w, r)
+			return
+		}
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			c.logger.Error("Error while attempting to upgrade incoming WebSockets connection", "err", err)
@@ -459,25 +2015,35 @@ func (c *Coordinator) runServer() {
 
 	c.logger.Info("Starting WebSockets server")
 
-	if err := c.svr.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	var err error
+	if c.coordCfg.tlsEnabled() {
+		// the certificate's already been loaded into the server's TLS config
+		err = c.svr.ListenAndServeTLS("", "")
+	} else {
+		err = c.svr.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		c.logger.Error("Server shut down", "err", err)
 		return
 	}
 	c.logger.Info("Server shut down")
 }
 
-// Graceful shutdown for the web server.
-func (c *Coordinator) shutdownServer() {
+// Graceful shutdown for the web server. The shutdown wait is cut short if the
+// given context is cancelled.
+func (c *Coordinator) shutdownServer(ctx context.Context) {
 	// curl htttp:13213213
 	// we only care about the shutdown wait period if we haven't been killed
 	if !c.wasCancelled() && c.coordCfg.ShutdownWait > 0 {
-		c.logger.Info("Entering post-shutdown wait period", "wait", fmt.Sprintf("%ds", c.coordCfg.ShutdownWait))
+		c.logger.Info("Entering post-shutdown wait period", "wait", c.coordCfg.ShutdownWait.String())
 		cancelSleep := make(chan struct{})
 		cancelTrap := trapInterrupts(func() { close(cancelSleep) }, c.logger)
 		select {
 		case <-cancelSleep:
 			c.logger.Info("Cancelling shutdown wait")
-		case <-time.After(time.Duration(c.coordCfg.ShutdownWait) * time.Second):
+		case <-ctx.Done():
+			c.logger.Info("Cancelling shutdown wait")
+		case <-time.After(time.Duration(c.coordCfg.ShutdownWait)):
 		}
 		close(cancelTrap)
 	}
@@ -496,10 +2062,46 @@ func (c *Coordinator) expectedWorkers() int {
 	return c.coordCfg.ExpectWorkers
 }
 
+// config returns a copy of the load testing configuration. Safe to call from
+// any goroutine.
 func (c *Coordinator) config() Config {
+	c.cfgMtx.RLock()
+	defer c.cfgMtx.RUnlock()
 	return *c.cfg
 }
 
+// setConfig replaces the load testing configuration. Must only be called from
+// the coordinator's event loop, which can read the configuration directly.
+func (c *Coordinator) setConfig(cfg Config) {
+	c.cfgMtx.Lock()
+	defer c.cfgMtx.Unlock()
+	*c.cfg = cfg
+}
+
+// workerOverride returns the overrides for the worker with the given ID (which
+// has registered), if any. Safe to call from any goroutine.
+func (c *Coordinator) workerOverride(id string) WorkerOverride {
+	o, _ := c.coordCfg.workerOverride(id, c.workerInfo.get(id))
+	return o
+}
+
+// workerConfig returns the load testing configuration for the worker with the
+// given ID, including any overrides for it. Safe to call from any goroutine.
+func (c *Coordinator) workerConfig(id string) Config {
+	cfg := c.coordCfg.configForWorker(c.config(), id, c.workerInfo.get(id))
+	c.mtx.Lock()
+	if shard, ok := c.endpointShards[id]; ok {
+		cfg.Endpoints = append([]string(nil), shard...)
+	}
+	c.mtx.Unlock()
+	// workers get their exact share of the total rate when told to start,
+	// which they spread across the connections they actually open
+	if share := c.workerTxRate(); share > 0 {
+		cfg.Rate = cfg.rateFor(share, cfg.Connections*len(cfg.Endpoints))
+	}
+	return cfg
+}
+
 func (c *Coordinator) stopRemoteWorkers() {
 	c.logger.Debug("Stopping all remote workers")
 	for _, rw := range c.workers {
@@ -507,40 +2109,138 @@ func (c *Coordinator) stopRemoteWorkers() {
 	}
 }
 
-func (c *Coordinator) gracefulShutdown() {
+func (c *Coordinator) gracefulShutdown(ctx context.Context) {
+	// a failure to save the metrics mustn't keep us from shutting down
+	c.saveMetrics()
+
+	// stop all remote worker event loops
+	c.stopRemoteWorkers()
+	// gracefully shut down the WebSockets server
+	c.shutdownServer(ctx)
+	select {
+	case <-c.svrStopped:
+	case <-time.After(coordShutdownTimeout):
+		c.logger.Error("Failed to shut down within the required time period")
+	}
+}
 
+func (c *Coordinator) saveMetrics() {
 	url := "http://localhost:26670/metrics"
 
 	response, err := http.Get(url) //发起HTTP GET请求
 	if err != nil {
-		fmt.Println("Error:", err)
+		c.logger.Warn("Failed to fetch metrics", "url", url, "err", err)
 		return
 	}
 	defer response.Body.Close()
 
 	body, err := io.ReadAll(response.Body) //读取内容
 	if err != nil {
-		fmt.Println("Error reading response body:", err)
+		c.logger.Warn("Failed to read metrics", "url", url, "err", err)
 		return
 	}
 
 	err = os.WriteFile("output.html", body, 0644) //将内容保存到文件
 	if err != nil {
-		fmt.Println("Error writing to file:", err)
+		c.logger.Warn("Failed to save metrics", "file", "output.html", "err", err)
 		return
 	}
 
-	fmt.Println("Content saved to output.html")
+	c.logger.Info("Saved metrics", "file", "output.html")
+}
 
-	// stop all remote worker event loops
-	c.stopRemoteWorkers()
-	// gracefully shut down the WebSockets server
-	c.shutdownServer()
-	select {
-	case <-c.svrStopped:
-	case <-time.After(coordShutdownTimeout):
-		c.logger.Error("Failed to shut down within the required time period")
+func (c *Coordinator) setBroadcastLatencies(sketch *latencySketch) {
+	c.mtx.Lock()
+	c.broadcastLatencies = sketch
+	c.mtx.Unlock()
+}
+
+func (c *Coordinator) getBroadcastLatencies() *latencySketch {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.broadcastLatencies
+}
+
+func (c *Coordinator) setCommitLatencies(sketch *latencySketch) {
+	c.mtx.Lock()
+	c.commitLatencies = sketch
+	c.mtx.Unlock()
+}
+
+func (c *Coordinator) getCommitLatencies() *latencySketch {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.commitLatencies
+}
+
+func (c *Coordinator) setFinalReport(report Report) {
+	c.mtx.Lock()
+	c.finalReport = &report
+	c.mtx.Unlock()
+}
+
+func (c *Coordinator) getFinalStats() *AggregateStats {
+	if report := c.Report(); report != nil {
+		return &report.Aggregate
 	}
+	return nil
+}
+
+// Report returns the report on the final aggregate and per-worker statistics
+// of the load test (of the last run, if there were several), as written to the
+// statistics output file, once Run has returned. It's also available if the
+// load test was cancelled, or fell short of a threshold, but not if it failed
+// before any statistics were gathered, or for a dry run, in which case it's
+// nil.
+func (c *Coordinator) Report() *Report {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.finalReport
+}
+
+// Stop stops the coordinator's operations, as if interrupted. If the load test
+// is underway, the workers are asked to report the statistics they gathered
+// until then, which are written before Run returns ErrLoadTestCancelled. Safe
+// to call more than once, from any goroutine.
+func (c *Coordinator) Stop() {
+	c.cancel()
+}
+
+// cancel stops the coordinator's operations. If the load test is underway, the
+// workers are asked to report the statistics they gathered until then, which
+// are written as usual (marked as cancelled). Safe to call more than once,
+// from any goroutine.
+func (c *Coordinator) cancel() {
+	c.stopOnce.Do(func() {
+		c.setCancelled(true)
+		close(c.stop)
+		c.setState(coordCancelled)
+	})
+}
+
+// terminate cancels the coordinator's operations as cancel does, but marks the
+// statistics as terminated, and gives the workers less time to report them so
+// that we can exit before being killed.
+func (c *Coordinator) terminate() {
+	c.mtx.Lock()
+	c.terminated = true
+	c.mtx.Unlock()
+	c.cancel()
+}
+
+// setState updates the coordinator's state, as reported by its Prometheus
+// metrics and control API.
+func (c *Coordinator) setState(state int) {
+	c.mtx.Lock()
+	c.state = state
+	c.mtx.Unlock()
+	c.stateMetric.Set(float64(state))
+}
+
+func (c *Coordinator) getState() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.state
 }
 
 func (c *Coordinator) setCancelled(cancelled bool) {
@@ -554,3 +2254,9 @@ func (c *Coordinator) wasCancelled() bool {
 	defer c.mtx.Unlock()
 	return c.cancelled
 }
+
+func (c *Coordinator) wasTerminated() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.terminated
+}
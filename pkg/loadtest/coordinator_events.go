@@ -0,0 +1,138 @@
+package loadtest
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// The maximum number of events buffered for a slow listener before further
+// events are dropped.
+const coordEventsBufSize = 1024
+
+// CoordinatorEvents receives events about the life cycle of the workers taking
+// part in a load test, for programs that embed the coordinator. Events are
+// delivered in order from a single goroutine, outside of the coordinator's
+// event loop and locks, so listeners may take their time. If a listener falls
+// too far behind, further events are dropped (and counted) until it catches
+// up.
+type CoordinatorEvents interface {
+	// WorkerRegistered is called when a worker has been accepted by the
+	// coordinator, including when it joins (or reconnects to) a load test
+	// that's already underway.
+	WorkerRegistered(e WorkerRegisteredEvent)
+
+	// WorkerStarted is called when a worker has been told to start its load
+	// test (or run).
+	WorkerStarted(e WorkerStartedEvent)
+
+	// WorkerProgress is called with each progress update from a worker.
+	WorkerProgress(e WorkerProgressEvent)
+
+	// WorkerFailed is called when a worker fails, or the coordinator gives up
+	// on it, during the load test.
+	WorkerFailed(e WorkerFailedEvent)
+
+	// WorkerFinished is called when a worker has reported its final
+	// statistics, once its load test has completed or been cancelled.
+	WorkerFinished(e WorkerFinishedEvent)
+}
+
+// WorkerEvent identifies the worker (and run) to which an event relates, and
+// when it happened.
+type WorkerEvent struct {
+	WorkerID string
+	Labels   map[string]string // The labels the worker registered with.
+	Run      int               // The index of the run, if the coordinator executes several runs back to back.
+	Time     time.Time
+}
+
+// WorkerRegisteredEvent is the event of a worker being accepted by the
+// coordinator.
+type WorkerRegisteredEvent struct {
+	WorkerEvent
+	Resumed bool // Whether the worker reconnected to resume its part in the load test.
+}
+
+// WorkerStartedEvent is the event of a worker being told to start its load
+// test.
+type WorkerStartedEvent struct {
+	WorkerEvent
+	StartDelay    time.Duration // How long the worker waits before sending, if workers' starts are staggered.
+	JoinedSeconds float64       // How far into the load test (in seconds) the worker joined, if it joined once underway.
+}
+
+// WorkerProgressEvent is a progress update from a worker.
+type WorkerProgressEvent struct {
+	WorkerEvent
+	TxCount      int           // The total number of transactions sent by the worker so far.
+	TotalTxBytes int64         // The total number of transaction bytes sent by the worker so far.
+	Progress     float64       // The fraction of its load test the worker has completed (between 0 and 1).
+	Failures     int           // The number of error responses the worker has received so far.
+	ETA          time.Duration // The estimated time until the worker completes its load test.
+}
+
+// WorkerFailedEvent is the event of a worker failing during the load test.
+type WorkerFailedEvent struct {
+	WorkerEvent
+	Err error
+}
+
+// WorkerFinishedEvent is the event of a worker reporting its final statistics.
+type WorkerFinishedEvent struct {
+	WorkerEvent
+	Cancelled    bool         // Whether the load test was cancelled, rather than completed.
+	TxCount      int          // The total number of transactions sent by the worker.
+	TotalTxBytes int64        // The total number of transaction bytes sent by the worker.
+	Stats        *WorkerStats // The worker's final statistics, if it reported them.
+}
+
+// coordEventQueue delivers events to a CoordinatorEvents listener from its own
+// goroutine (once running), buffering up to a limit and dropping events beyond
+// it, so that a slow listener never holds up the coordinator.
+type coordEventQueue struct {
+	events  CoordinatorEvents
+	queue   chan func(CoordinatorEvents)
+	dropped atomic.Int64
+	stopped chan struct{}
+}
+
+func newCoordEventQueue(events CoordinatorEvents, bufSize int) *coordEventQueue {
+	return &coordEventQueue{
+		events:  events,
+		queue:   make(chan func(CoordinatorEvents), bufSize),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (q *coordEventQueue) run() {
+	defer close(q.stopped)
+	for deliver := range q.queue {
+		deliver(q.events)
+	}
+}
+
+// publish queues the given delivery of an event without blocking, dropping it
+// if the queue is full.
+func (q *coordEventQueue) publish(deliver func(CoordinatorEvents)) {
+	select {
+	case q.queue <- deliver:
+	default:
+		q.dropped.Add(1)
+	}
+}
+
+// close stops accepting events, and waits up to the given timeout for those
+// already queued to be delivered.
+func (q *coordEventQueue) close(timeout time.Duration, logger logging.Logger) {
+	close(q.queue)
+	select {
+	case <-q.stopped:
+	case <-time.After(timeout):
+		logger.Warn("Timed out waiting for event listener to receive all events", "pending", len(q.queue))
+	}
+	if dropped := q.dropped.Load(); dropped > 0 {
+		logger.Warn("Some events were dropped because the event listener couldn't keep up", "dropped", dropped)
+	}
+}
@@ -0,0 +1,122 @@
+package loadtest_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingListener records the events it receives, by worker.
+type recordingListener struct {
+	mtx    sync.Mutex
+	events map[string][]interface{}
+}
+
+var _ loadtest.CoordinatorEvents = (*recordingListener)(nil)
+
+func (l *recordingListener) record(id string, e interface{}) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.events == nil {
+		l.events = make(map[string][]interface{})
+	}
+	l.events[id] = append(l.events[id], e)
+}
+
+func (l *recordingListener) WorkerRegistered(e loadtest.WorkerRegisteredEvent) {
+	l.record(e.WorkerID, e)
+}
+
+func (l *recordingListener) WorkerStarted(e loadtest.WorkerStartedEvent) {
+	l.record(e.WorkerID, e)
+}
+
+func (l *recordingListener) WorkerProgress(e loadtest.WorkerProgressEvent) {
+	// a slow listener mustn't hold up the load test
+	time.Sleep(100 * time.Millisecond)
+	l.record(e.WorkerID, e)
+}
+
+func (l *recordingListener) WorkerFailed(e loadtest.WorkerFailedEvent) {
+	l.record(e.WorkerID, e)
+}
+
+func (l *recordingListener) WorkerFinished(e loadtest.WorkerFinishedEvent) {
+	l.record(e.WorkerID, e)
+}
+
+func TestCoordinatorEvents(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(4)
+	cfg.Count = -1
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(10),
+	})
+	listener := &recordingListener{}
+	coord.SetEvents(listener)
+	errs := make(chan error, 3)
+	go func() { errs <- coord.Run() }()
+	for i := 0; i < 2; i++ {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  fmt.Sprintf("worker%d", i),
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+			Labels:              map[string]string{"zone": fmt.Sprintf("z%d", i)},
+		})
+		require.NoError(t, err)
+		go func() { errs <- worker.Run() }()
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(time.Duration(cfg.Time) + 30*time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+	require.Zero(t, coord.DroppedEvents())
+
+	// all events have been delivered by the time the coordinator returns
+	listener.mtx.Lock()
+	defer listener.mtx.Unlock()
+	require.Len(t, listener.events, 2)
+	for i := 0; i < 2; i++ {
+		id := fmt.Sprintf("worker%d", i)
+		events := listener.events[id]
+		require.GreaterOrEqual(t, len(events), 4, id)
+
+		registered, ok := events[0].(loadtest.WorkerRegisteredEvent)
+		require.True(t, ok, "expected the first event to be registration, but got %T", events[0])
+		require.Equal(t, map[string]string{"zone": fmt.Sprintf("z%d", i)}, registered.Labels)
+		require.False(t, registered.Resumed)
+		started, ok := events[1].(loadtest.WorkerStartedEvent)
+		require.True(t, ok, "expected the second event to be the start, but got %T", events[1])
+		require.False(t, started.Time.Before(registered.Time))
+
+		// progress updates follow, with the worker's totals only growing
+		lastTxCount := 0
+		for _, e := range events[2 : len(events)-1] {
+			progress, ok := e.(loadtest.WorkerProgressEvent)
+			require.True(t, ok, "expected a progress update, but got %T", e)
+			require.GreaterOrEqual(t, progress.TxCount, lastTxCount)
+			lastTxCount = progress.TxCount
+		}
+		require.Greater(t, lastTxCount, 0)
+
+		finished, ok := events[len(events)-1].(loadtest.WorkerFinishedEvent)
+		require.True(t, ok, "expected the last event to be completion, but got %T", events[len(events)-1])
+		require.False(t, finished.Cancelled)
+		require.NotNil(t, finished.Stats)
+		require.Equal(t, finished.TxCount, finished.Stats.TotalTxs)
+		require.GreaterOrEqual(t, finished.TxCount, lastTxCount)
+	}
+}
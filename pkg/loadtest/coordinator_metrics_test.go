@@ -0,0 +1,202 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatherCounter returns the value of the given unlabeled counter from the
+// coordinator's registry.
+func gatherCounter(t *testing.T, c *Coordinator, name string) float64 {
+	families, err := c.registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == name {
+			require.Len(t, family.GetMetric(), 1)
+			return family.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return 0
+}
+
+func TestCoordinatorCountersSurviveReconnects(t *testing.T) {
+	cfg := &Config{}
+	coord := NewCoordinator(cfg, &CoordinatorConfig{ExpectWorkers: 1})
+
+	require.NoError(t, coord.registerRemoteWorker(&remoteWorker{id: "w1"}))
+	coord.trackWorkerMetrics(workerMsg{ID: "w1", State: workerTesting, TxCount: 100, TotalTxBytes: 1000})
+	assert.Equal(t, float64(100), gatherCounter(t, coord, "tmloadtest_coordinator_total_txs"))
+
+	// the worker reconnects and carries on from where it left off
+	coord.unregisterRemoteWorker("w1")
+	require.NoError(t, coord.registerRemoteWorker(&remoteWorker{id: "w1"}))
+	coord.trackWorkerMetrics(workerMsg{ID: "w1", State: workerTesting, TxCount: 150, TotalTxBytes: 1500})
+	assert.Equal(t, float64(150), gatherCounter(t, coord, "tmloadtest_coordinator_total_txs"))
+	assert.Equal(t, float64(1500), gatherCounter(t, coord, "tmloadtest_coordinator_total_bytes"))
+
+	// the worker restarts, and so reports totals from scratch
+	coord.trackWorkerMetrics(workerMsg{ID: "w1", State: workerTesting, TxCount: 20, TotalTxBytes: 200})
+	assert.Equal(t, float64(170), gatherCounter(t, coord, "tmloadtest_coordinator_total_txs"))
+	assert.Equal(t, float64(1700), gatherCounter(t, coord, "tmloadtest_coordinator_total_bytes"))
+}
+
+func TestCoordinatorDuplicateWorkerIDs(t *testing.T) {
+	coord := NewCoordinator(&Config{Time: Duration(time.Minute)}, &CoordinatorConfig{ExpectWorkers: 2})
+	first := &remoteWorker{id: "w1", stop: make(chan struct{}), logger: logging.NewNoopLogger()}
+	require.NoError(t, coord.registerRemoteWorker(first))
+
+	// a fresh worker with the same ID is rejected, whether it registers before
+	// or during the load test
+	err := coord.registerRemoteWorker(&remoteWorker{id: "w1"})
+	require.ErrorContains(t, err, "a worker with ID w1 is already registered")
+	coord.startTime = time.Now()
+	err = coord.registerLateWorker(&remoteWorker{id: "w1"})
+	require.ErrorContains(t, err, "a worker with ID w1 is already registered")
+	assert.Equal(t, float64(2), gatherCounter(t, coord, "tmloadtest_coordinator_duplicate_worker_ids"))
+	assert.Same(t, first, coord.workers["w1"])
+
+	// whereas the worker itself reconnecting to resume its load test
+	// supersedes its previous connection, even if we haven't noticed that it
+	// was lost yet
+	resumed := &remoteWorker{id: "w1", resuming: true}
+	require.NoError(t, coord.resumeRemoteWorker(resumed))
+	assert.Same(t, resumed, coord.workers["w1"])
+	assert.Equal(t, float64(2), gatherCounter(t, coord, "tmloadtest_coordinator_duplicate_worker_ids"))
+	select {
+	case <-first.stop:
+	default:
+		t.Fatal("Expected the superseded connection to be stopped")
+	}
+}
+
+func TestCoordinatorWorkerLabels(t *testing.T) {
+	coord := NewCoordinator(&Config{}, &CoordinatorConfig{ExpectWorkers: 3})
+	require.NoError(t, coord.registerRemoteWorker(&remoteWorker{id: "w1", labels: map[string]string{"region": "eu", "instance": "c5"}}))
+	require.NoError(t, coord.registerRemoteWorker(&remoteWorker{id: "w2", labels: map[string]string{"region": "us"}}))
+	require.NoError(t, coord.registerRemoteWorker(&remoteWorker{id: "w3"}))
+
+	// worker IDs must be unique
+	err := coord.registerRemoteWorker(&remoteWorker{id: "w1", labels: map[string]string{"region": "ap"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a worker with ID w1 is already registered")
+	assert.Equal(t, "eu", coord.workerInfo.get("w1")["region"])
+
+	// each worker's series has the union of all workers' label names
+	families, err := coord.registry.Gather()
+	require.NoError(t, err)
+	info := make(map[string]map[string]string)
+	for _, family := range families {
+		if family.GetName() != "tmloadtest_coordinator_worker_info" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			assert.Equal(t, float64(1), m.GetGauge().GetValue())
+			labels := make(map[string]string)
+			for _, pair := range m.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			info[labels["worker"]] = labels
+		}
+	}
+	assert.Equal(t, map[string]map[string]string{
+		"w1": {"worker": "w1", "instance": "c5", "region": "eu"},
+		"w2": {"worker": "w2", "instance": "", "region": "us"},
+		"w3": {"worker": "w3", "instance": "", "region": ""},
+	}, info)
+
+	assert.Equal(t, []interface{}{"id", "w1", "labels", "instance=c5,region=eu", "err", "x"}, coord.workerFields("w1", "err", "x"))
+	assert.Equal(t, []interface{}{"id", "w3"}, coord.workerFields("w3"))
+}
+
+// gatherWorkerGauge returns the value of the given gauge, labeled by worker, for
+// the given worker from the coordinator's registry.
+func gatherWorkerGauge(t *testing.T, c *Coordinator, name, worker string) float64 {
+	families, err := c.registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, pair := range m.GetLabel() {
+				if pair.GetName() == "worker" && pair.GetValue() == worker {
+					return m.GetGauge().GetValue()
+				}
+			}
+		}
+	}
+	t.Fatalf("metric %s not found for worker %s", name, worker)
+	return 0
+}
+
+func TestCoordinatorWorkerResourceMetrics(t *testing.T) {
+	coord := NewCoordinator(&Config{}, &CoordinatorConfig{ExpectWorkers: 1})
+	require.NoError(t, coord.registerRemoteWorker(&remoteWorker{id: "w1"}))
+
+	coord.trackWorkerMetrics(workerMsg{ID: "w1", State: workerTesting, Resources: &ResourceUsage{CPUPercent: 150, RSSBytes: 1 << 20, Goroutines: 40, GCPauseSeconds: 0.5}})
+	coord.trackWorkerMetrics(workerMsg{ID: "w1", State: workerTesting, Resources: &ResourceUsage{CPUPercent: 80, RSSBytes: 2 << 20, Goroutines: 30, GCPauseSeconds: 0.75}})
+	// the gauges show the latest values
+	assert.Equal(t, float64(80), gatherWorkerGauge(t, coord, "tmloadtest_coordinator_worker_cpu_percent", "w1"))
+	assert.Equal(t, float64(2<<20), gatherWorkerGauge(t, coord, "tmloadtest_coordinator_worker_rss_bytes", "w1"))
+	assert.Equal(t, float64(30), gatherWorkerGauge(t, coord, "tmloadtest_coordinator_worker_goroutines", "w1"))
+	assert.Equal(t, 0.75, gatherWorkerGauge(t, coord, "tmloadtest_coordinator_worker_gc_pause_seconds", "w1"))
+}
+
+func TestCoordinatorsHaveSeparateRegistries(t *testing.T) {
+	// creating a second coordinator in the same process doesn't clash with
+	// the first one's metrics
+	first := NewCoordinator(&Config{}, &CoordinatorConfig{ExpectWorkers: 1})
+	second := NewCoordinator(&Config{}, &CoordinatorConfig{ExpectWorkers: 1})
+
+	require.NoError(t, first.registerRemoteWorker(&remoteWorker{id: "w1"}))
+	first.trackWorkerMetrics(workerMsg{ID: "w1", State: workerTesting, Resources: &ResourceUsage{Goroutines: 40}})
+	first.totalTxsMetric.Add(100)
+	assert.Equal(t, float64(40), gatherWorkerGauge(t, first, "tmloadtest_coordinator_worker_goroutines", "w1"))
+
+	// and each coordinator only reports its own metrics, along with the
+	// runtime's
+	for _, c := range []*Coordinator{first, second} {
+		families, err := c.registry.Gather()
+		require.NoError(t, err)
+		names := make(map[string]*dto.MetricFamily)
+		for _, family := range families {
+			names[family.GetName()] = family
+		}
+		assert.Contains(t, names, "go_goroutines")
+		require.Contains(t, names, "tmloadtest_coordinator_total_txs")
+		if c == second {
+			assert.Equal(t, float64(0), names["tmloadtest_coordinator_total_txs"].GetMetric()[0].GetCounter().GetValue())
+			assert.NotContains(t, names, "tmloadtest_coordinator_worker_goroutines")
+		}
+	}
+}
+
+func TestCoordinatorFinalUpdateAfterDisconnect(t *testing.T) {
+	// the coordinator may only get to a worker's final update, which is queued
+	// separately, once it has processed the worker's disconnection, so we try
+	// a few times
+	for i := 0; i < 20; i++ {
+		coord := NewCoordinator(&Config{Time: Duration(time.Minute)}, &CoordinatorConfig{ExpectWorkers: 1})
+		rw := &remoteWorker{id: "w1", stop: make(chan struct{}), logger: logging.NewNoopLogger()}
+		require.NoError(t, coord.registerRemoteWorker(rw))
+		coord.startedWorkers = 1
+
+		coord.workerUpdate <- workerMsg{ID: "w1", State: workerCompleted, TxCount: 100, TotalTxBytes: 1000}
+		coord.workerUnregister <- remoteWorkerUnregisterRequest{rw: rw}
+		done := make(chan error, 1)
+		go func() { done <- coord.receiveTestingUpdates() }()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the worker's final update to complete the load test")
+		}
+		assert.Equal(t, 100, coord.totalTxsPerWorker["w1"])
+	}
+}
@@ -0,0 +1,2121 @@
+package loadtest_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoordinatorWorkerStats(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	coord := runCoordinatorWorkers(t, cfg, 2)
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 2)
+	// the coordinator reports the same statistics as it wrote
+	returned := coord.Report()
+	require.NotNil(t, returned)
+	require.Equal(t, report.Aggregate.TotalTxs, returned.Aggregate.TotalTxs)
+	require.Equal(t, report.Aggregate.TotalBytes, returned.Aggregate.TotalBytes)
+	require.Len(t, returned.Workers, 2)
+	require.Equal(t, report.Workers[0].ID, returned.Workers[0].ID)
+
+	totalTxs, totalBytes := 0, int64(0)
+	for i, ws := range report.Workers {
+		require.Equal(t, fmt.Sprintf("worker%d", i), ws.ID)
+		require.Greater(t, ws.TotalTimeSeconds, 0.0)
+		require.InDelta(t, float64(ws.TotalTxs)/ws.TotalTimeSeconds, ws.AvgTxRate, 1e-9)
+		require.Zero(t, ws.Failures)
+		// each worker says which build it runs when registering
+		require.NotNil(t, ws.Build)
+		require.Equal(t, loadtest.CLIVersion, ws.Build.Version)
+		totalTxs += ws.TotalTxs
+		totalBytes += ws.TotalBytes
+	}
+	require.Equal(t, report.Aggregate.TotalTxs, totalTxs)
+	require.Equal(t, report.Aggregate.TotalBytes, totalBytes)
+}
+
+// Workers report their resource usage during a busy load test, and the
+// coordinator includes each worker's peak usage in its statistics.
+func TestCoordinatorWorkerResources(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(3)
+	cfg.Count = -1
+	cfg.Rate = 500
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	// GC pause time is cumulative across the process
+	runtime.GC()
+	runCoordinatorWorkers(t, cfg, 1)
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 1)
+	peak := report.Workers[0].PeakResources
+	require.NotNil(t, peak)
+	require.Greater(t, peak.CPUPercent, 0.0)
+	require.Greater(t, peak.RSSBytes, int64(0))
+	require.Greater(t, peak.Goroutines, 0)
+	require.Greater(t, peak.GCPauseSeconds, 0.0)
+}
+
+func TestCoordinatorWorkerOverrides(t *testing.T) {
+	base := newMockRPCServer(t, 0)
+	svr0 := newMockRPCServer(t, 0)
+	svr1 := newMockRPCServer(t, 0)
+
+	cfg := mockTestConfig(base.URL())
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	runCoordinatorWorkersWith(t, cfg, loadtest.CoordinatorConfig{
+		WorkerOverrides: map[string]loadtest.WorkerOverride{
+			"worker0": {Endpoints: []string{svr0.URL()}},
+			"worker1": {Endpoints: []string{svr1.URL()}, Rate: 20},
+		},
+	}, 2)
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 2)
+	// each worker only sent to its own endpoint
+	require.Zero(t, base.Requests())
+	require.Equal(t, "worker0", report.Workers[0].ID)
+	require.Equal(t, svr0.Requests(), report.Workers[0].TotalTxs)
+	require.InDelta(t, 10, report.Workers[0].TargetTxRate, 1e-9)
+	require.Equal(t, "worker1", report.Workers[1].ID)
+	require.Equal(t, svr1.Requests(), report.Workers[1].TotalTxs)
+	require.InDelta(t, 20, report.Workers[1].TargetTxRate, 1e-9)
+	require.Greater(t, report.Workers[1].TotalTxs, report.Workers[0].TotalTxs)
+}
+
+func TestCoordinatorWorkerOverridesByLabels(t *testing.T) {
+	base := newMockRPCServer(t, 0)
+	eu := newMockRPCServer(t, 0)
+	us := newMockRPCServer(t, 0)
+
+	cfg := mockTestConfig(base.URL())
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        3,
+		WorkerConnectTimeout: seconds(10),
+		WorkerOverrides: map[string]loadtest.WorkerOverride{
+			"region=eu": {Endpoints: []string{eu.URL()}},
+			"region=us": {Endpoints: []string{us.URL()}, Rate: 20},
+			// the worker's ID takes precedence over its labels
+			"worker2": {Rate: 5},
+		},
+	})
+	errs := make(chan error, 4)
+	go func() { errs <- coord.Run() }()
+	for i, region := range []string{"eu", "us", "us"} {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  fmt.Sprintf("worker%d", i),
+			Labels:              map[string]string{"region": region},
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		go func() { errs <- worker.Run() }()
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(30 * time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 3)
+	require.Equal(t, eu.Requests(), report.Workers[0].TotalTxs)
+	require.InDelta(t, 10, report.Workers[0].TargetTxRate, 1e-9)
+	require.Equal(t, us.Requests(), report.Workers[1].TotalTxs)
+	require.InDelta(t, 20, report.Workers[1].TargetTxRate, 1e-9)
+	require.Equal(t, base.Requests(), report.Workers[2].TotalTxs)
+	require.InDelta(t, 5, report.Workers[2].TargetTxRate, 1e-9)
+}
+
+func TestCoordinatorTotalRate(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.Rate = 0
+	cfg.Connections = 2
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	runCoordinatorWorkersWith(t, cfg, loadtest.CoordinatorConfig{TotalRate: 40}, 2)
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 2)
+	// each worker sent its share of the total rate
+	for _, ws := range report.Workers {
+		require.InDelta(t, 20, ws.TargetTxRate, 1e-9, ws.ID)
+		require.Greater(t, ws.TotalTxs, 0, ws.ID)
+	}
+	require.InDelta(t, 40, report.Aggregate.TargetTxRate, 1e-9)
+	require.Greater(t, report.Aggregate.AchievedTxRate, 0.0)
+}
+
+// The total rate is split anew when a worker joins the load test once it's
+// underway.
+func TestCoordinatorTotalRateLateWorker(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(5)
+	cfg.Count = -1
+	cfg.Rate = 0
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		MaxWorkers:           3,
+		WorkerConnectTimeout: seconds(10),
+		TotalRate:            60,
+	})
+	errs := make(chan error, 4)
+	go func() { errs <- coord.Run() }()
+
+	runWorker := func(id string) error {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  id,
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		return worker.Run()
+	}
+	for i := 0; i < 2; i++ {
+		id := fmt.Sprintf("worker%d", i)
+		go func() { errs <- runWorker(id) }()
+	}
+	time.Sleep(2 * time.Second)
+	go func() { errs <- runWorker("worker2") }()
+
+	for i := 0; i < 4; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(30 * time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 3)
+	// the workers that started the load test ended up sending a third of the
+	// total rate each, rather than half of it
+	for _, ws := range report.Workers {
+		require.InDelta(t, 20, ws.TargetTxRate, 1e-9, ws.ID)
+	}
+	require.InDelta(t, 60, report.Aggregate.TargetTxRate, 1e-9)
+}
+
+func TestCoordinatorShardEndpoints(t *testing.T) {
+	svrs := make([]*mockRPCServer, 4)
+	urls := make([]string, len(svrs))
+	for i := range svrs {
+		svrs[i] = newMockRPCServer(t, 0)
+		urls[i] = svrs[i].URL()
+	}
+	cfg := mockTestConfig(urls...)
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	// per-endpoint statistics are only gathered with endpoint rate limits
+	cfg.EndpointRateLimits = map[string]float64{urls[0]: 1000}
+	runCoordinatorWorkersWith(t, cfg, loadtest.CoordinatorConfig{ShardEndpoints: true}, 2)
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 2)
+	// each worker only sent to its own (disjoint) share of the endpoints
+	require.Equal(t, "worker0", report.Workers[0].ID)
+	require.Equal(t, svrs[0].Requests()+svrs[2].Requests(), report.Workers[0].TotalTxs)
+	require.Equal(t, "worker1", report.Workers[1].ID)
+	require.Equal(t, svrs[1].Requests()+svrs[3].Requests(), report.Workers[1].TotalTxs)
+	// and the per-endpoint statistics still cover all of the endpoints
+	require.Len(t, report.Aggregate.Endpoints, 4)
+	for _, ep := range report.Aggregate.Endpoints {
+		for i, url := range urls {
+			if ep.Endpoint == url {
+				require.Greater(t, ep.TotalTxs, 0)
+				require.Equal(t, svrs[i].Requests(), ep.TotalTxs)
+			}
+		}
+	}
+}
+
+func TestCoordinatorStartStagger(t *testing.T) {
+	svr0 := newMockRPCServer(t, 0)
+	svr1 := newMockRPCServer(t, 0)
+
+	cfg := mockTestConfig(svr0.URL())
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	runCoordinatorWorkersWith(t, cfg, loadtest.CoordinatorConfig{
+		StartStagger: loadtest.Duration(500 * time.Millisecond),
+		WorkerOverrides: map[string]loadtest.WorkerOverride{
+			"worker1": {Endpoints: []string{svr1.URL()}},
+		},
+	}, 2)
+
+	// workers start in order of their IDs
+	require.False(t, svr0.FirstTxAt().IsZero())
+	require.False(t, svr1.FirstTxAt().IsZero())
+	stagger := svr1.FirstTxAt().Sub(svr0.FirstTxAt())
+	require.InDelta(t, 500*time.Millisecond, stagger, float64(250*time.Millisecond))
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 2)
+	// each worker's statistics are measured from its own start, and the
+	// load test lasts at least as long as the last worker's part in it
+	for _, ws := range report.Workers {
+		require.InDelta(t, 2, ws.TotalTimeSeconds, 0.5, ws.ID)
+	}
+	require.GreaterOrEqual(t, report.Aggregate.TotalTimeSeconds, 2.5)
+	require.Less(t, report.Aggregate.TargetTxRate, float64(20))
+}
+
+func TestCoordinatorRuns(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	cfg.Runs = []json.RawMessage{
+		json.RawMessage(`{}`),
+		json.RawMessage(`{"rate":30}`),
+	}
+	// the worker registers once and takes part in both runs
+	runCoordinatorWorkers(t, cfg, 1)
+
+	reports := make([]loadtest.Report, 2)
+	for i := range reports {
+		b, err := os.ReadFile(filepath.Join(filepath.Dir(cfg.StatsOutputFile), fmt.Sprintf("stats-run%d.json", i)))
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(b, &reports[i]))
+		require.Len(t, reports[i].Workers, 1)
+		require.Equal(t, "worker0", reports[i].Workers[0].ID)
+		require.Zero(t, reports[i].Workers[0].Failures)
+	}
+	_, err := os.Stat(cfg.StatsOutputFile)
+	require.True(t, os.IsNotExist(err))
+
+	require.Equal(t, float64(10), reports[0].Config.Rate)
+	require.Equal(t, float64(30), reports[1].Config.Rate)
+	// each run's statistics only cover that run
+	require.InDelta(t, 2, reports[0].Workers[0].TotalTimeSeconds, 0.5)
+	require.InDelta(t, 2, reports[1].Workers[0].TotalTimeSeconds, 0.5)
+	require.Greater(t, reports[1].Aggregate.TotalTxs, 2*reports[0].Aggregate.TotalTxs)
+	require.Equal(t, svr.Requests(), reports[0].Aggregate.TotalTxs+reports[1].Aggregate.TotalTxs)
+}
+
+func TestCoordinatorLiveStats(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	// workers report their progress every few seconds
+	cfg.Time = seconds(6)
+	cfg.Count = -1
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(10),
+	})
+	errs := make(chan error, 3)
+	go func() { errs <- coord.Run() }()
+
+	getStats := func() (int, []byte) {
+		res, err := http.Get("http://" + addr + "/stats")
+		if err != nil {
+			return 0, nil
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, body
+	}
+
+	// not started until all workers have connected
+	var (
+		status int
+		body   []byte
+	)
+	require.Eventually(t, func() bool {
+		status, body = getStats()
+		return status != 0
+	}, 5*time.Second, 50*time.Millisecond)
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Contains(t, string(body), "0 of 2 workers connected")
+
+	for i := 0; i < 2; i++ {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  fmt.Sprintf("worker%d", i),
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		go func() { errs <- worker.Run() }()
+	}
+
+	var stats loadtest.LiveStats
+	require.Eventually(t, func() bool {
+		status, body = getStats()
+		if status != http.StatusOK {
+			return false
+		}
+		require.NoError(t, json.Unmarshal(body, &stats))
+		return stats.Workers[0].TotalTxs > 0 && stats.Workers[1].TotalTxs > 0
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Equal(t, "testing", stats.State)
+	require.Greater(t, stats.ElapsedSeconds, 0.0)
+	require.Equal(t, 2, stats.ConnectedWorkers)
+	require.Equal(t, stats.Workers[0].TotalTxs+stats.Workers[1].TotalTxs, stats.TotalTxs)
+	require.Equal(t, stats.Workers[0].TotalBytes+stats.Workers[1].TotalBytes, stats.TotalBytes)
+	require.Greater(t, stats.Progress, 0.0)
+	require.Less(t, stats.Progress, 1.0)
+	for i, ws := range stats.Workers {
+		require.Equal(t, fmt.Sprintf("worker%d", i), ws.ID)
+		require.Equal(t, "testing", ws.State)
+		require.True(t, ws.Connected)
+	}
+
+	// check the shape of the JSON document itself
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &doc))
+	for _, key := range []string{"state", "elapsed_seconds", "total_txs", "total_bytes", "failures", "progress", "connected_workers", "workers"} {
+		require.Contains(t, doc, key)
+	}
+
+	// the Prometheus metrics are labeled by worker
+	res, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	metrics, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.Contains(t, string(metrics), fmt.Sprintf(`tmloadtest_coordinator_worker_txs{worker="worker%d"} %d`, i, stats.Workers[i].TotalTxs))
+		require.Contains(t, string(metrics), fmt.Sprintf(`tmloadtest_coordinator_worker_connected{worker="worker%d"} 1`, i))
+		require.Contains(t, string(metrics), fmt.Sprintf(`tmloadtest_coordinator_worker_failures{worker="worker%d"} 0`, i))
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(30 * time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+}
+
+func TestCoordinatorStreamsIntervalStats(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(5)
+	cfg.Count = -1
+	cfg.StatsPushInterval = seconds(1)
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(10),
+		ShutdownWait:         seconds(2),
+	})
+	errs := make(chan error, 3)
+	go func() { errs <- coord.Run() }()
+	for i := 0; i < 2; i++ {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  fmt.Sprintf("worker%d", i),
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		go func() { errs <- worker.Run() }()
+	}
+
+	// the coordinator's running totals only grow, until the final ones
+	var running []int
+	var final loadtest.LiveStats
+	require.Eventually(t, func() bool {
+		res, err := http.Get("http://" + addr + "/stats")
+		if err != nil {
+			return false
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return false
+		}
+		var stats loadtest.LiveStats
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&stats))
+		if stats.State == "completed" {
+			final = stats
+			return true
+		}
+		running = append(running, stats.TotalTxs)
+		return false
+	}, time.Duration(cfg.Time+20)*time.Second, 100*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(30 * time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+	require.Equal(t, svr.Requests(), final.TotalTxs)
+	distinct := 0
+	for i, total := range running {
+		require.LessOrEqual(t, total, final.TotalTxs)
+		if i > 0 {
+			require.GreaterOrEqual(t, total, running[i-1])
+			if total > running[i-1] {
+				distinct++
+			}
+		}
+	}
+	// with the workers pushing every second, the totals are updated several
+	// times along the way
+	require.GreaterOrEqual(t, distinct, 3, "running totals: %v", running)
+}
+
+func TestCoordinatorAuthToken(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(1)
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+		AuthToken:            "s3cret",
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+
+	runWorker := func(id, token string) error {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  id,
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+			AuthToken:           token,
+		})
+		require.NoError(t, err)
+		return worker.Run()
+	}
+
+	// rejected workers don't affect the load test
+	err := runWorker("intruder", "wrong")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid auth token")
+	err = runWorker("intruder", "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "auth token is required")
+
+	res, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	require.Contains(t, string(body), "tmloadtest_coordinator_rejected_registrations 2\n")
+
+	require.NoError(t, runWorker("worker0", "s3cret"))
+	select {
+	case err := <-coordErr:
+		require.NoError(t, err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for load test to complete")
+	}
+}
+
+// Workers lacking the load test's client factory are rejected when they
+// register, rather than failing once the load test starts.
+func TestCoordinatorClientFactories(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(1)
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+
+	runWorker := func(id string, factories []string) error {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  id,
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+			ClientFactories:     factories,
+		})
+		require.NoError(t, err)
+		return worker.Run()
+	}
+
+	// the rejection doesn't affect the load test, which a capable worker can
+	// still take part in
+	err := runWorker("incapable", []string{"myapp", "otherapp"})
+	require.ErrorContains(t, err, `coordinator rejected worker: worker doesn't support client factory "kvstore" (it only supports myapp, otherapp)`)
+	require.NoError(t, runWorker("worker0", nil))
+	select {
+	case err := <-coordErr:
+		require.NoError(t, err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for load test to complete")
+	}
+}
+
+// Two workers accidentally configured with the same ID mustn't have their
+// statistics mixed up: whichever registers second is rejected.
+func TestCoordinatorDuplicateWorkerID(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+
+	workerErrs := make(chan error, 3)
+	runWorker := func(id string) {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  id,
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		go func() { workerErrs <- worker.Run() }()
+	}
+	runWorker("worker0")
+	runWorker("worker0")
+	select {
+	case err := <-workerErrs:
+		require.ErrorContains(t, err, "coordinator rejected worker: a worker with ID worker0 is already registered")
+	case <-time.After(20 * time.Second):
+		t.Fatal("Timed out waiting for duplicate worker to be rejected")
+	}
+	res, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	require.Contains(t, string(body), "tmloadtest_coordinator_duplicate_worker_ids 1\n")
+
+	// the rejection doesn't affect the load test
+	runWorker("worker1")
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-workerErrs)
+	}
+	select {
+	case err := <-coordErr:
+		require.NoError(t, err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for load test to complete")
+	}
+	report := readJSONReport(t, cfg.StatsOutputFile)
+	require.Len(t, report.Workers, 2)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+}
+
+func TestCoordinatorEndpointWeights(t *testing.T) {
+	heavy := newMockRPCServer(t, 0)
+	light := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(heavy.URL()+"|weight=3", light.URL())
+	cfg.Connections = 4
+	cfg.Time = seconds(2)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	require.NoError(t, worker.Run())
+	require.NoError(t, <-coordErr)
+
+	// the weights must reach the worker with the endpoints
+	assert.Equal(t, 6, heavy.Connections())
+	assert.Equal(t, 2, light.Connections())
+	report := readJSONReport(t, cfg.StatsOutputFile)
+	require.Len(t, report.Aggregate.Endpoints, 2)
+	for _, ep := range report.Aggregate.Endpoints {
+		if ep.Endpoint == heavy.URL() {
+			assert.Equal(t, 3.0, ep.Weight)
+			assert.InDelta(t, 0.75, ep.Share, 0.05)
+		} else {
+			assert.Equal(t, 1.0, ep.Weight)
+			assert.InDelta(t, 0.25, ep.Share, 0.05)
+		}
+	}
+}
+
+func TestCoordinatorCompression(t *testing.T) {
+	cfg := mockTestConfig()
+	for i := 0; i < 5000; i++ {
+		cfg.Endpoints = append(cfg.Endpoints, fmt.Sprintf("ws://node%d.example.com:26657/websocket", i))
+	}
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(10),
+		EnableCompression:    true,
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+	defer func() {
+		coord.Stop()
+		<-coordErr
+	}()
+
+	var wireBytes int64
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &countingConn{Conn: conn, read: &wireBytes}, nil
+		},
+	}
+	// the coordinator may not be listening yet
+	deadline := time.Now().Add(10 * time.Second)
+	conn, resp, err := dialer.Dial("ws://"+addr, nil)
+	for err != nil && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		conn, resp, err = dialer.Dial("ws://"+addr, nil)
+	}
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Contains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+	require.NoError(t, conn.WriteJSON(map[string]string{"id": "worker0", "protocol_version": loadtest.WorkerProtocolVersion}))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var accepted struct {
+		State  string           `json:"state"`
+		Config *loadtest.Config `json:"config"`
+	}
+	require.NoError(t, json.Unmarshal(data, &accepted))
+	require.Equal(t, "accepted", accepted.State)
+	require.NotNil(t, accepted.Config)
+	require.Equal(t, cfg.Endpoints, accepted.Config.Endpoints)
+	// the handshake response is included, but the endpoints compress well
+	require.Less(t, wireBytes, int64(len(data)/2))
+}
+
+// countingConn counts the bytes read from the underlying connection.
+type countingConn struct {
+	net.Conn
+	read *int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	*c.read += int64(n)
+	return n, err
+}
+
+func TestCoordinatorLateWorker(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(6)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		MaxWorkers:           3,
+		WorkerConnectTimeout: seconds(10),
+	})
+	errs := make(chan error, 4)
+	go func() { errs <- coord.Run() }()
+
+	runWorker := func(id string) error {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  id,
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		return worker.Run()
+	}
+	for i := 0; i < 2; i++ {
+		id := fmt.Sprintf("worker%d", i)
+		go func() { errs <- runWorker(id) }()
+	}
+
+	// a third worker joins halfway through the load test
+	time.Sleep(3 * time.Second)
+	go func() { errs <- runWorker("worker2") }()
+	// but there's no room for a fourth
+	time.Sleep(500 * time.Millisecond)
+	err := runWorker("worker3")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "too many workers")
+
+	for i := 0; i < 4; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(30 * time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 3)
+
+	totalTxs, targetTxRate := 0, 0.0
+	for _, ws := range report.Workers {
+		require.Greater(t, ws.TotalTxs, 0, ws.ID)
+		totalTxs += ws.TotalTxs
+		targetTxRate += ws.TargetTxRate
+	}
+	require.Equal(t, report.Aggregate.TotalTxs, totalTxs)
+	// the late worker only ran for the remainder of the load test
+	late := report.Workers[2]
+	require.Equal(t, "worker2", late.ID)
+	require.Less(t, late.TotalTimeSeconds, report.Workers[0].TotalTimeSeconds-1)
+	// and so only contributed to the target rate for that long
+	require.Less(t, report.Aggregate.TargetTxRate, targetTxRate)
+	require.Greater(t, report.Aggregate.TargetTxRate, targetTxRate*2/3)
+}
+
+func TestCoordinatorMinWorkers(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(4)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        3,
+		MinWorkers:           2,
+		StartGracePeriod:     seconds(1),
+		WorkerConnectTimeout: seconds(10),
+	})
+	errs := make(chan error, 3)
+	go func() { errs <- coord.Run() }()
+
+	// the third worker never shows up
+	for i := 0; i < 2; i++ {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  fmt.Sprintf("worker%d", i),
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		go func() { errs <- worker.Run() }()
+	}
+
+	// the load test starts once the grace period has elapsed
+	require.Eventually(t, func() bool {
+		res, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			return false
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return err == nil && strings.Contains(string(body), "tmloadtest_coordinator_workers_started 2\n")
+	}, 5*time.Second, 100*time.Millisecond)
+
+	// and completes once the workers that started it have
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(30 * time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 2)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+}
+
+func TestCoordinatorMinWorkersNotMet(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        3,
+		MinWorkers:           2,
+		StartGracePeriod:     seconds(1),
+		WorkerConnectTimeout: seconds(2),
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	workerErr := make(chan error, 1)
+	go func() { workerErr <- worker.Run() }()
+
+	select {
+	case err := <-coordErr:
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "only 1 of the minimum of 2 workers connected")
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for coordinator to give up")
+	}
+	select {
+	case err := <-workerErr:
+		require.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for worker to fail")
+	}
+	require.Zero(t, svr.Requests())
+}
+
+func TestCoordinatorManualStart(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(1),
+		ManualStart:          true,
+	})
+	errs := make(chan error, 2)
+	go func() { errs <- coord.Run() }()
+
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	go func() { errs <- worker.Run() }()
+
+	getStatus := func() (loadtest.TestStatus, error) {
+		var status loadtest.TestStatus
+		res, err := http.Get("http://" + addr + "/v1/test/status")
+		if err != nil {
+			return status, err
+		}
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+		return status, nil
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if status, err := getStatus(); err == nil && status.ConnectedWorkers == 1 {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for worker to connect")
+		time.Sleep(100 * time.Millisecond)
+	}
+	// the load test waits to be started, beyond the connect timeout
+	time.Sleep(1500 * time.Millisecond)
+	status, err := getStatus()
+	require.NoError(t, err)
+	require.Equal(t, "waiting_for_workers", status.Phase)
+	require.Nil(t, status.Progress)
+
+	// overriding the configured duration
+	res, err := http.Post("http://"+addr+"/v1/test/start", "application/json", strings.NewReader(`{"time":1}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusAccepted, res.StatusCode)
+	status, err = getStatus()
+	require.NoError(t, err)
+	require.Equal(t, "testing", status.Phase)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(30 * time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 1)
+	require.InDelta(t, 1, report.Workers[0].TotalTimeSeconds, 0.5)
+}
+
+func TestCoordinatorControlCancel(t *testing.T) {
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(30),
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		res, err := http.Post("http://"+addr+"/v1/test/cancel", "application/json", nil)
+		if err == nil {
+			res.Body.Close()
+			require.Equal(t, http.StatusAccepted, res.StatusCode)
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for coordinator to start")
+		time.Sleep(100 * time.Millisecond)
+	}
+	select {
+	case err := <-coordErr:
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "cancelled")
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for coordinator to stop")
+	}
+}
+
+func TestCoordinatorCancelWritesStats(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(10),
+	})
+	errs := make(chan error, 3)
+	go func() { errs <- coord.Run() }()
+	for i := 0; i < 2; i++ {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  fmt.Sprintf("worker%d", i),
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		go func() { errs <- worker.Run() }()
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for svr.Requests() == 0 {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for load test to start")
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(2 * time.Second)
+	res, err := http.Post("http://"+addr+"/v1/test/cancel", "application/json", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusAccepted, res.StatusCode)
+
+	// the coordinator and both workers end with the load test cancelled
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-errs:
+			require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+		case <-time.After(20 * time.Second):
+			t.Fatal("Timed out waiting for load test to be cancelled")
+		}
+	}
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Equal(t, loadtest.StatsStatusCancelled, report.Aggregate.Status)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+	require.Less(t, report.Aggregate.TotalTimeSeconds, 10.0)
+	require.Len(t, report.Workers, 2)
+	require.Equal(t, report.Aggregate.TotalTxs, report.Workers[0].TotalTxs+report.Workers[1].TotalTxs)
+}
+
+func TestCoordinatorPauseResume(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(6)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	errs := make(chan error, 2)
+	go func() { errs <- coord.Run() }()
+
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	go func() { errs <- worker.Run() }()
+
+	control := func(action string) (int, loadtest.TestStatus) {
+		var status loadtest.TestStatus
+		res, err := http.Post("http://"+addr+"/v1/test/"+action, "application/json", nil)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		if res.StatusCode == http.StatusAccepted {
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+		}
+		return res.StatusCode, status
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		res, err := http.Get("http://" + addr + "/v1/test/status")
+		if err == nil {
+			var status loadtest.TestStatus
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+			res.Body.Close()
+			if status.Phase == "testing" {
+				break
+			}
+		}
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for load test to start")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	time.Sleep(time.Second)
+	code, status := control("pause")
+	require.Equal(t, http.StatusAccepted, code)
+	require.Equal(t, "paused", status.Phase)
+	require.NotNil(t, status.Progress)
+	require.Equal(t, "paused", status.Progress.State)
+	code, _ = control("pause")
+	require.Equal(t, http.StatusConflict, code)
+
+	// give the pause time to reach the worker
+	time.Sleep(500 * time.Millisecond)
+	paused := svr.Requests()
+	time.Sleep(2 * time.Second)
+	require.Equal(t, paused, svr.Requests())
+	code, status = control("resume")
+	require.Equal(t, http.StatusAccepted, code)
+	require.Equal(t, "testing", status.Phase)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(30 * time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+	require.Greater(t, svr.Requests(), paused)
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	// the pause lasted about 2.5s of the 6s load test
+	require.Len(t, report.Workers, 1)
+	require.InDelta(t, 3.5, report.Workers[0].TotalTimeSeconds, 0.75)
+	// the coordinator only notices the worker completing on its next update,
+	// up to 3s later
+	require.InDelta(t, 5, report.Aggregate.TotalTimeSeconds, 1.75)
+}
+
+func TestCoordinatorShutdownWorkers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		auto     bool   // Whether the coordinator shuts down its workers once cancelled.
+		endpoint string // The control API endpoint to POST to once the worker has connected.
+	}{
+		{"ViaControlAPI", false, "/v1/workers/shutdown"},
+		{"OnCancel", true, "/v1/test/cancel"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := mockTestConfig("ws://localhost:26657/websocket")
+			addr := freeLocalAddr(t)
+			coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+				BindAddr:                    addr,
+				ExpectWorkers:               1,
+				WorkerConnectTimeout:        seconds(10),
+				ManualStart:                 true,
+				ShutdownWorkersOnCompletion: tc.auto,
+			})
+			coordErr := make(chan error, 1)
+			go func() { coordErr <- coord.Run() }()
+
+			worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+				ID:                  "worker0",
+				CoordAddr:           "ws://" + addr,
+				CoordConnectTimeout: seconds(10),
+			})
+			require.NoError(t, err)
+			workerErr := make(chan error, 1)
+			go func() { workerErr <- worker.Run() }()
+
+			deadline := time.Now().Add(10 * time.Second)
+			for {
+				res, err := http.Get("http://" + addr + "/v1/test/status")
+				if err == nil {
+					var status loadtest.TestStatus
+					require.NoError(t, json.NewDecoder(res.Body).Decode(&status))
+					res.Body.Close()
+					if status.ConnectedWorkers == 1 {
+						break
+					}
+				}
+				require.True(t, time.Now().Before(deadline), "Timed out waiting for worker to connect")
+				time.Sleep(100 * time.Millisecond)
+			}
+			res, err := http.Post("http://"+addr+tc.endpoint, "application/json", nil)
+			require.NoError(t, err)
+			res.Body.Close()
+			require.Equal(t, http.StatusAccepted, res.StatusCode)
+
+			// the worker exits cleanly
+			select {
+			case err := <-workerErr:
+				require.NoError(t, err)
+			case <-time.After(10 * time.Second):
+				t.Fatal("Timed out waiting for worker to shut down")
+			}
+			if !tc.auto {
+				res, err = http.Post("http://"+addr+"/v1/test/cancel", "application/json", nil)
+				require.NoError(t, err)
+				res.Body.Close()
+			}
+			select {
+			case err := <-coordErr:
+				require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+			case <-time.After(10 * time.Second):
+				t.Fatal("Timed out waiting for coordinator to stop")
+			}
+		})
+	}
+}
+
+func TestCoordinatorShutdownWorkersMidTest(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	workerErr := make(chan error, 1)
+	go func() { workerErr <- worker.Run() }()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for svr.Requests() == 0 {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for load test to start")
+		time.Sleep(100 * time.Millisecond)
+	}
+	shutdown := func(query string) int {
+		res, err := http.Post("http://"+addr+"/v1/workers/shutdown"+query, "application/json", nil)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	// workers in the middle of the load test only shut down if forced to
+	require.Equal(t, http.StatusConflict, shutdown(""))
+	require.Equal(t, http.StatusBadRequest, shutdown("?force=maybe"))
+	time.Sleep(time.Second)
+	select {
+	case err := <-workerErr:
+		t.Fatalf("Worker stopped without being forced to: %v", err)
+	default:
+	}
+	require.Equal(t, http.StatusAccepted, shutdown("?force=true"))
+
+	select {
+	case err := <-workerErr:
+		require.NoError(t, err)
+	case <-time.After(20 * time.Second):
+		t.Fatal("Timed out waiting for worker to shut down")
+	}
+	select {
+	case err := <-coordErr:
+		require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+	case <-time.After(20 * time.Second):
+		t.Fatal("Timed out waiting for coordinator to stop")
+	}
+	// the worker still reported what it sent until then
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Equal(t, loadtest.StatsStatusCancelled, report.Aggregate.Status)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+}
+
+func TestWorkerReconnect(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	// the worker only notices its connection is gone when it next reports its
+	// progress
+	cfg.Time = seconds(12)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	errs := make(chan error, 2)
+	go func() { errs <- coord.Run() }()
+
+	proxy := newFlakyProxy(t, addr)
+	defer proxy.Close()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + proxy.Addr(),
+		CoordConnectTimeout: seconds(10),
+		MaxReconnectTime:    seconds(10),
+	})
+	require.NoError(t, err)
+	go func() { errs <- worker.Run() }()
+
+	time.Sleep(3 * time.Second)
+	proxy.Restart(time.Second)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(time.Duration(cfg.Time) + 30*time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 1)
+	// the worker kept sending while disconnected, and its progress was only
+	// counted once
+	require.Equal(t, report.Workers[0].TotalTxs, report.Aggregate.TotalTxs)
+	require.InDelta(t, cfg.Rate*cfg.Time.Seconds(), float64(report.Aggregate.TotalTxs), cfg.Rate*2)
+}
+
+func TestCoordinatorResume(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(12)
+	cfg.Count = -1
+	cfg.StatsPushInterval = seconds(1)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	// the first coordinator "crashes" once it has checkpointed some progress:
+	// its workers lose their connections to it, and its state file is frozen
+	// as it was at the time
+	crashedCfg := cfg
+	crashedAddr := freeLocalAddr(t)
+	crashedStateFile := filepath.Join(t.TempDir(), "crashed-state.json")
+	crashed := loadtest.NewCoordinator(&crashedCfg, &loadtest.CoordinatorConfig{
+		BindAddr:             crashedAddr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+		StateFile:            crashedStateFile,
+		CheckpointInterval:   seconds(1),
+	})
+	go func() { _ = crashed.Run() }()
+
+	proxy := newFlakyProxy(t, crashedAddr)
+	defer proxy.Close()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + proxy.Addr(),
+		CoordConnectTimeout: seconds(10),
+		MaxReconnectTime:    seconds(10),
+	})
+	require.NoError(t, err)
+	errs := make(chan error, 2)
+	go func() { errs <- worker.Run() }()
+
+	checkpointedTxs := func() int {
+		var cp struct {
+			Workers map[string]struct {
+				TxCount int `json:"tx_count"`
+			} `json:"workers"`
+		}
+		b, err := os.ReadFile(crashedStateFile)
+		if err != nil || json.Unmarshal(b, &cp) != nil {
+			return 0
+		}
+		return cp.Workers["worker0"].TxCount
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for checkpointedTxs() == 0 {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for load test to be checkpointed")
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(2 * time.Second)
+	proxy.Close()
+	b, err := os.ReadFile(crashedStateFile)
+	require.NoError(t, err)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(stateFile, b, 0o600))
+
+	// the restarted coordinator resumes the load test once the worker
+	// reconnects
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+		StateFile:            stateFile,
+		CheckpointInterval:   seconds(1),
+		Resume:               true,
+	})
+	go func() { errs <- coord.Run() }()
+	time.Sleep(500 * time.Millisecond)
+	proxy.Retarget(addr)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(time.Duration(cfg.Time) + 30*time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+
+	b, err = os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 1)
+	// the progress made before the crash was only counted once, and the load
+	// test's timing carried on from its original start
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+	require.Equal(t, report.Workers[0].TotalTxs, report.Aggregate.TotalTxs)
+	require.InDelta(t, cfg.Time.Seconds(), report.Aggregate.TotalTimeSeconds, 2)
+	// the load test can't be resumed once it's over
+	_, err = os.Stat(stateFile)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestWorkerWaitsForCoordinator(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+
+	addr := freeLocalAddr(t)
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+		CoordRetryInterval:  seconds(1),
+	})
+	require.NoError(t, err)
+	errs := make(chan error, 2)
+	go func() { errs <- worker.Run() }()
+
+	// the coordinator only starts listening once the worker has been trying
+	// to connect for a while
+	time.Sleep(2 * time.Second)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	go func() { errs <- coord.Run() }()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(time.Duration(cfg.Time) + 30*time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+	require.Equal(t, cfg.Count, svr.Requests())
+}
+
+func TestWorkerConnectFailures(t *testing.T) {
+	// authentication failures abort immediately
+	forbidden := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer forbidden.Close()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws" + strings.TrimPrefix(forbidden.URL, "http"),
+		CoordConnectTimeout: seconds(30),
+	})
+	require.NoError(t, err)
+	start := time.Now()
+	require.ErrorContains(t, worker.Run(), "coordinator rejected connection: 403 Forbidden")
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// whereas other failures are retried until the connect timeout, across
+	// all attempts
+	worker, err = loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + freeLocalAddr(t),
+		CoordConnectTimeout: seconds(3),
+	})
+	require.NoError(t, err)
+	start = time.Now()
+	err = worker.Run()
+	require.ErrorContains(t, err, "failed to reach coordinator within connect time limit")
+	require.Equal(t, loadtest.ExitCodeConnectivity, loadtest.ExitCodeFor(err))
+	require.InDelta(t, 3, time.Since(start).Seconds(), 1)
+}
+
+func TestWorkerReconnectDisabled(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(12)
+	cfg.Count = -1
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	errs := make(chan error, 2)
+	go func() { errs <- coord.Run() }()
+
+	proxy := newFlakyProxy(t, addr)
+	defer proxy.Close()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + proxy.Addr(),
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	go func() { errs <- worker.Run() }()
+
+	time.Sleep(3 * time.Second)
+	proxy.Restart(time.Second)
+
+	// without reconnection, both the worker and the load test fail
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			require.Error(t, err)
+		case <-time.After(time.Duration(cfg.Time) + 30*time.Second):
+			t.Fatal("Timed out waiting for load test to fail")
+		}
+	}
+}
+
+// flakyProxy forwards TCP connections to a target address, and can be
+// restarted to simulate a transient network failure.
+type flakyProxy struct {
+	t    *testing.T
+	addr string
+
+	mtx      sync.Mutex
+	target   string
+	listener net.Listener
+	conns    []net.Conn
+}
+
+func newFlakyProxy(t *testing.T, target string) *flakyProxy {
+	p := &flakyProxy{t: t, addr: freeLocalAddr(t), target: target}
+	p.listen()
+	return p
+}
+
+func (p *flakyProxy) Addr() string {
+	return p.addr
+}
+
+func (p *flakyProxy) listen() {
+	l, err := net.Listen("tcp", p.addr)
+	require.NoError(p.t, err)
+	p.mtx.Lock()
+	p.listener = l
+	p.mtx.Unlock()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go p.forward(conn)
+		}
+	}()
+}
+
+func (p *flakyProxy) forward(conn net.Conn) {
+	p.mtx.Lock()
+	target := p.target
+	p.mtx.Unlock()
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	p.mtx.Lock()
+	p.conns = append(p.conns, conn, upstream)
+	p.mtx.Unlock()
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	_, _ = io.Copy(conn, upstream)
+	conn.Close()
+}
+
+// Restart drops all connections and stops accepting new ones for the given
+// downtime.
+func (p *flakyProxy) Restart(downtime time.Duration) {
+	p.Close()
+	time.Sleep(downtime)
+	p.listen()
+}
+
+// Retarget drops all connections, and forwards new ones to the given target
+// from then on.
+func (p *flakyProxy) Retarget(target string) {
+	p.Close()
+	p.mtx.Lock()
+	p.target = target
+	p.mtx.Unlock()
+	p.listen()
+}
+
+func (p *flakyProxy) Close() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.listener.Close()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func TestCoordinatorTLS(t *testing.T) {
+	certFile, keyFile := writeSelfSignedCert(t)
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(1)
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+		TLSCertFile:          certFile,
+		TLSKeyFile:           keyFile,
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+
+	// plaintext workers can't connect
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "plaintext",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(2),
+	})
+	require.NoError(t, err)
+	require.Error(t, worker.Run())
+
+	// and neither can workers that don't trust the coordinator's certificate
+	worker, err = loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "untrusting",
+		CoordAddr:           "wss://" + addr,
+		CoordConnectTimeout: seconds(2),
+	})
+	require.NoError(t, err)
+	require.Error(t, worker.Run())
+
+	worker, err = loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "wss://" + addr,
+		CoordConnectTimeout: seconds(10),
+		TLSCAFile:           certFile,
+	})
+	require.NoError(t, err)
+	require.NoError(t, worker.Run())
+	select {
+	case err := <-coordErr:
+		require.NoError(t, err)
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for load test to complete")
+	}
+}
+
+// writeSelfSignedCert writes a self-signed certificate for localhost, and its
+// private key, to temporary files.
+func writeSelfSignedCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+// runCoordinatorWorkers executes a load test with the given configuration
+// through a coordinator and the given number of workers, named "worker0",
+// "worker1", etc., returning the coordinator once the load test is complete.
+func runCoordinatorWorkers(t *testing.T, cfg loadtest.Config, workers int) *loadtest.Coordinator {
+	return runCoordinatorWorkersWith(t, cfg, loadtest.CoordinatorConfig{}, workers)
+}
+
+// runCoordinatorWorkersWith runs a load test with the given number of workers
+// (with IDs worker0, worker1, etc.), filling in the coordinator configuration's
+// bind address, expected workers and connect timeout.
+func runCoordinatorWorkersWith(t *testing.T, cfg loadtest.Config, coordCfg loadtest.CoordinatorConfig, workers int) *loadtest.Coordinator {
+	addr := freeLocalAddr(t)
+	coordCfg.BindAddr = addr
+	coordCfg.ExpectWorkers = workers
+	coordCfg.WorkerConnectTimeout = seconds(10)
+	timeout := time.Duration(cfg.Time)*time.Duration(len(cfg.Runs)+1) + 30*time.Second
+	coord := loadtest.NewCoordinator(&cfg, &coordCfg)
+	errs := make(chan error, workers+1)
+	go func() { errs <- coord.Run() }()
+
+	for i := 0; i < workers; i++ {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  fmt.Sprintf("worker%d", i),
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+		})
+		require.NoError(t, err)
+		go func() { errs <- worker.Run() }()
+	}
+	for i := 0; i < workers+1; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(timeout):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+	return coord
+}
+
+func TestCoordinatorWorkerHeartbeatTimeout(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(6)
+	cfg.Count = -1
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:                addr,
+		ExpectWorkers:           2,
+		WorkerConnectTimeout:    seconds(10),
+		HeartbeatInterval:       seconds(1),
+		HeartbeatTimeout:        seconds(3),
+		ContinueOnWorkerFailure: true,
+	})
+	errs := make(chan error, 2)
+	go func() { errs <- coord.Run() }()
+
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	go func() { errs <- worker.Run() }()
+	runSilentWorker(t, addr, "worker1")
+
+	// the silent worker misses heartbeats before it's considered dead
+	deadline := time.Now().Add(10 * time.Second)
+	for !strings.Contains(getMetrics(t, addr), `tmloadtest_coordinator_worker_missed_heartbeats{worker="worker1"} 2`) {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for missed heartbeats")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// the load test carries on without the silent worker
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(time.Duration(cfg.Time) + 30*time.Second):
+			t.Fatal("Timed out waiting for load test to complete")
+		}
+	}
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	// the silent worker is still reported on, with what it sent until it
+	// failed
+	require.Len(t, report.Workers, 2)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+	for _, ws := range report.Workers {
+		if ws.ID == "worker1" {
+			require.Equal(t, 0, ws.TotalTxs)
+		}
+	}
+}
+
+func TestCoordinatorWorkerHeartbeatTimeoutFails(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(10),
+		HeartbeatInterval:    seconds(1),
+		HeartbeatTimeout:     seconds(3),
+	})
+	errs := make(chan error, 1)
+	go func() { errs <- coord.Run() }()
+
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	go func() { _ = worker.Run() }()
+	runSilentWorker(t, addr, "worker1")
+
+	// the load test fails long before it would otherwise have completed
+	select {
+	case err := <-errs:
+		require.ErrorContains(t, err, "no heartbeat from worker within 3s")
+	case <-time.After(15 * time.Second):
+		t.Fatal("Timed out waiting for load test to fail")
+	}
+}
+
+func TestCoordinatorProtocolVersion(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErrs := make(chan error, 1)
+	go func() { coordErrs <- coord.Run() }()
+
+	// workers with incompatible protocol versions are rejected immediately,
+	// without affecting the load test
+	for _, version := range []string{"2.5", "1.10", "one"} {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  "incompatible",
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+			ProtocolVersion:     version,
+		})
+		require.NoError(t, err)
+		err = worker.Run()
+		require.ErrorContains(t, err, fmt.Sprintf("coordinator rejected worker: incompatible protocol versions: coordinator speaks %s, but worker speaks %s", loadtest.WorkerProtocolVersion, version))
+	}
+
+	// whereas workers one minor version apart can work together
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+		ProtocolVersion:     "1.7",
+	})
+	require.NoError(t, err)
+	require.NoError(t, worker.Run())
+	select {
+	case err := <-coordErrs:
+		require.NoError(t, err)
+	case <-time.After(time.Duration(cfg.Time) + 30*time.Second):
+		t.Fatal("Timed out waiting for load test to complete")
+	}
+}
+
+// Workers exchange messages with the coordinator as MessagePack by default,
+// and as JSON if they ask to, within the same load test.
+func TestCoordinatorMessageEncodings(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        2,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErrs := make(chan error, 1)
+	go func() { coordErrs <- coord.Run() }()
+
+	workerErrs := make(chan error, 2)
+	for i, jsonMessages := range []bool{false, true} {
+		worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+			ID:                  fmt.Sprintf("worker%d", i),
+			CoordAddr:           "ws://" + addr,
+			CoordConnectTimeout: seconds(10),
+			JSONMessages:        jsonMessages,
+		})
+		require.NoError(t, err)
+		go func() { workerErrs <- worker.Run() }()
+	}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, <-workerErrs)
+	}
+	select {
+	case err := <-coordErrs:
+		require.NoError(t, err)
+	case <-time.After(time.Duration(cfg.Time) + 30*time.Second):
+		t.Fatal("Timed out waiting for load test to complete")
+	}
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Len(t, report.Workers, 2)
+	for _, ws := range report.Workers {
+		require.Greater(t, ws.TotalTxs, 0)
+	}
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+}
+
+// runSilentWorker registers a fake worker with the given ID with the
+// coordinator, which sends heartbeats until the load test starts, and then
+// goes silent (without disconnecting).
+func runSilentWorker(t *testing.T, addr, id string) {
+	// the coordinator may not be listening yet
+	deadline := time.Now().Add(10 * time.Second)
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+addr, nil)
+	for err != nil && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		conn, _, err = websocket.DefaultDialer.Dial("ws://"+addr, nil)
+	}
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, conn.WriteJSON(map[string]string{"id": id, "protocol_version": loadtest.WorkerProtocolVersion}))
+	var accepted map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&accepted))
+	require.Equal(t, "accepted", accepted["state"])
+	require.Equal(t, 1.0, accepted["heartbeat_interval"])
+
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		var msg map[string]interface{}
+		_ = conn.ReadJSON(&msg)
+	}()
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = conn.WriteJSON(map[string]string{"id": id, "state": "heartbeat"})
+			case <-started:
+				return
+			}
+		}
+	}()
+}
+
+func getMetrics(t *testing.T, addr string) string {
+	res, err := http.Get("http://" + addr + "/metrics")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func freeLocalAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestCoordinatorWorkerLogging(t *testing.T) {
+	defer func() {
+		logrus.SetLevel(logrus.DebugLevel)
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	}()
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.LogLevel = "info"
+
+	// the workers adopt the coordinator's logging configuration
+	runCoordinatorWorkers(t, cfg, 1)
+	assert.Equal(t, logrus.InfoLevel, logrus.GetLevel())
+
+	// unless they're given their own
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErrs := make(chan error, 1)
+	go func() { coordErrs <- coord.Run() }()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+		LogFormat:           "json",
+	})
+	require.NoError(t, err)
+	require.NoError(t, worker.Run())
+	require.NoError(t, <-coordErrs)
+	assert.Equal(t, logrus.InfoLevel, logrus.GetLevel())
+	assert.IsType(t, &logrus.JSONFormatter{}, logrus.StandardLogger().Formatter)
+}
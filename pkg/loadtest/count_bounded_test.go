@@ -0,0 +1,56 @@
+package loadtest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandaloneCountBounded(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = 0
+	cfg.Connections = 2
+	cfg.Rate = 10
+	cfg.SendPeriod = loadtest.Duration(250 * time.Millisecond)
+	// sent over 3 send periods
+	cfg.Count = 25
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.csv")
+	require.NoError(t, cfg.Validate())
+
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+	assert.Equal(t, cfg.Connections*cfg.Count, svr.Requests())
+
+	f, err := os.Open(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	defer f.Close()
+	report, err := loadtest.ParseReport(f)
+	require.NoError(t, err)
+	assert.Empty(t, report.Aggregate.Status)
+	assert.Equal(t, cfg.Connections*cfg.Count, report.Aggregate.TotalTxs)
+}
+
+func TestTransactorCountBoundedMaxRunTime(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = -seconds(1)
+	cfg.Count = 1000
+	cfg.MaxRunTime = seconds(2)
+	require.NoError(t, cfg.Validate())
+
+	tr, err := loadtest.NewTransactor(svr.URL(), &cfg)
+	require.NoError(t, err)
+	start := time.Now()
+	tr.Start()
+	require.NoError(t, tr.Wait())
+
+	// the cap cuts the load test short of its count
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Less(t, tr.GetTxCount(), cfg.Count)
+	assert.Greater(t, tr.GetTxCount(), 0)
+}
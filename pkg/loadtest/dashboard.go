@@ -0,0 +1,29 @@
+package loadtest
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// dashboardPage is a self-contained HTML page that renders the coordinator's
+// live statistics (as served at /stats) in a browser.
+//
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// handleDashboard serves the dashboard page at the root of the coordinator's
+// HTTP server, for requests that aren't workers' WebSockets connections.
+func (c *Coordinator) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(dashboardPage)
+}
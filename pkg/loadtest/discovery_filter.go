@@ -0,0 +1,139 @@
+package loadtest
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// discoveryFilter decides which of the peers discovered by crawling the
+// network (e.g. sentries and seeds) to leave out of the load test, by their
+// monikers and listen addresses and by the chain ID their nodes report.
+type discoveryFilter struct {
+	include []*regexp.Regexp // If any, peers must match at least one of these.
+	exclude []*regexp.Regexp // Peers matching any of these are left out, even if included.
+	chainID string           // If set, the chain ID that peers' nodes must report.
+	timeout time.Duration    // How long each peer has to report its chain ID.
+}
+
+// newDiscoveryFilter builds the filter configured by the given configuration,
+// returning nil if discovered peers aren't to be filtered.
+func newDiscoveryFilter(cfg *Config) (*discoveryFilter, error) {
+	if len(cfg.DiscoveryIncludePatterns) == 0 && len(cfg.DiscoveryExcludePatterns) == 0 && len(cfg.DiscoveryChainID) == 0 {
+		return nil, nil
+	}
+	include, err := compileDiscoveryPatterns("discovery-include", cfg.DiscoveryIncludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileDiscoveryPatterns("discovery-exclude", cfg.DiscoveryExcludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	return &discoveryFilter{
+		include: include,
+		exclude: exclude,
+		chainID: cfg.DiscoveryChainID,
+		timeout: cfg.healthCheckTimeout(),
+	}, nil
+}
+
+func compileDiscoveryPatterns(flag string, patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %v", flag, pattern, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// matchPatterns returns the reason for which the peer with the given node
+// information is left out by the include and exclude patterns, or an empty
+// string if it isn't. Exclusions take precedence over inclusions.
+func (f *discoveryFilter) matchPatterns(info *DefaultNodeInfo) string {
+	if len(f.include) == 0 && len(f.exclude) == 0 {
+		return ""
+	}
+	if info == nil {
+		return "no moniker or listen address known to match against"
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(info.Moniker) || re.MatchString(info.ListenAddr) {
+			return fmt.Sprintf("matches exclude pattern %q", re)
+		}
+	}
+	if len(f.include) == 0 {
+		return ""
+	}
+	for _, re := range f.include {
+		if re.MatchString(info.Moniker) || re.MatchString(info.ListenAddr) {
+			return ""
+		}
+	}
+	return "matches no include pattern"
+}
+
+// checkChainID returns the reason for which the peer whose RPC API is served
+// at the given HTTP address is left out because of its chain ID, or an empty
+// string if it isn't.
+func (f *discoveryFilter) checkChainID(httpAddr string) string {
+	if len(f.chainID) == 0 {
+		return ""
+	}
+	client := newHttpRpcClient(httpAddr)
+	defer client.close()
+	client.client.Timeout = f.timeout
+	status, err := client.status()
+	if err != nil {
+		return fmt.Sprintf("failed to query its chain ID: %v", err)
+	}
+	if status.NodeInfo.Network != f.chainID {
+		return fmt.Sprintf("chain ID %q is not %q", status.NodeInfo.Network, f.chainID)
+	}
+	return ""
+}
+
+// discoveredPeer is a peer found by crawling the network, which is a
+// candidate for inclusion in the load test.
+type discoveredPeer struct {
+	httpAddr string           // The address at which the peer's RPC API is served over HTTP.
+	info     *DefaultNodeInfo // The peer's node information, as reported by the nodes it's connected to (nil if unknown).
+}
+
+// allowed returns which of the given peers to keep, checking their chain IDs
+// concurrently. Each peer left out is logged (at debug level) along with the
+// reason.
+func (f *discoveryFilter) allowed(peers []discoveredPeer, logger logging.Logger) []bool {
+	result := make([]bool, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer discoveredPeer) {
+			defer wg.Done()
+			reason := f.matchPatterns(peer.info)
+			if len(reason) == 0 {
+				reason = f.checkChainID(peer.httpAddr)
+			}
+			if len(reason) > 0 {
+				logger.Debug("Leaving out discovered peer", "addr", peer.httpAddr, "moniker", peerMoniker(peer.info), "reason", reason)
+				return
+			}
+			result[i] = true
+		}(i, peer)
+	}
+	wg.Wait()
+	return result
+}
+
+func peerMoniker(info *DefaultNodeInfo) string {
+	if info == nil {
+		return ""
+	}
+	return info.Moniker
+}
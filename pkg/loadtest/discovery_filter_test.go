@@ -0,0 +1,114 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoveryFilterPatterns(t *testing.T) {
+	peers := []DefaultNodeInfo{
+		{Moniker: "validator-0", ListenAddr: "tcp://10.0.0.1:26656"},
+		{Moniker: "validator-1", ListenAddr: "tcp://10.0.1.1:26656"},
+		{Moniker: "sentry-0", ListenAddr: "tcp://10.0.0.2:26656"},
+		{Moniker: "seed", ListenAddr: "tcp://10.0.0.3:26656"},
+	}
+	testCases := []struct {
+		include  []string
+		exclude  []string
+		expected []string
+	}{
+		{nil, nil, []string{"validator-0", "validator-1", "sentry-0", "seed"}},
+		{[]string{"^validator-"}, nil, []string{"validator-0", "validator-1"}},
+		{nil, []string{"^sentry-", "^seed$"}, []string{"validator-0", "validator-1"}},
+		// listen addresses are matched too
+		{[]string{`10\.0\.0\.`}, nil, []string{"validator-0", "sentry-0", "seed"}},
+		// exclusions take precedence over inclusions
+		{[]string{`10\.0\.0\.`}, []string{"^seed$"}, []string{"validator-0", "sentry-0"}},
+		{[]string{"^validator-"}, []string{`10\.0\.1\.`}, []string{"validator-0"}},
+	}
+	for i, tc := range testCases {
+		filter, err := newDiscoveryFilter(&Config{DiscoveryIncludePatterns: tc.include, DiscoveryExcludePatterns: tc.exclude})
+		require.NoError(t, err)
+		monikers := make([]string, 0)
+		for j := range peers {
+			if filter == nil || filter.matchPatterns(&peers[j]) == "" {
+				monikers = append(monikers, peers[j].Moniker)
+			}
+		}
+		assert.Equal(t, tc.expected, monikers, "test case %d", i)
+	}
+
+	filter, err := newDiscoveryFilter(&Config{DiscoveryExcludePatterns: []string{"^seed$"}})
+	require.NoError(t, err)
+	assert.Contains(t, filter.matchPatterns(&peers[3]), `matches exclude pattern "^seed$"`)
+	// peers we know nothing about can't be matched
+	assert.NotEmpty(t, filter.matchPatterns(nil))
+
+	_, err = newDiscoveryFilter(&Config{DiscoveryIncludePatterns: []string{"validator-("}})
+	assert.ErrorContains(t, err, "invalid discovery-include pattern")
+	_, err = newDiscoveryFilter(&Config{DiscoveryExcludePatterns: []string{"*"}})
+	assert.ErrorContains(t, err, "invalid discovery-exclude pattern")
+}
+
+// newCannedStatusServer serves the status RPC API of a node on the given
+// chain.
+func newCannedStatusServer(t *testing.T, chainID string) *httptest.Server {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, _ := json.Marshal(RPCResponse{JSONRPC: "2.0", ID: -1, Result: json.RawMessage(fmt.Sprintf(`{"node_info":{"network":"%s"}}`, chainID))})
+		_, _ = w.Write(res)
+	}))
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func TestDiscoveryFilterChainID(t *testing.T) {
+	same := newCannedStatusServer(t, "test-chain")
+	other := newCannedStatusServer(t, "other-chain")
+	sentry := newCannedStatusServer(t, "test-chain")
+	peers := []discoveredPeer{
+		{httpAddr: same.URL, info: &DefaultNodeInfo{Moniker: "validator-0"}},
+		{httpAddr: other.URL, info: &DefaultNodeInfo{Moniker: "validator-1"}},
+		{httpAddr: sentry.URL, info: &DefaultNodeInfo{Moniker: "sentry-0"}},
+		// unreachable, so its chain ID is unknown
+		{httpAddr: "http://127.0.0.1:1", info: &DefaultNodeInfo{Moniker: "validator-2"}},
+	}
+	filter, err := newDiscoveryFilter(&Config{DiscoveryExcludePatterns: []string{"^sentry-"}, DiscoveryChainID: "test-chain"})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false, false}, filter.allowed(peers, logging.NewNoopLogger()))
+	assert.Contains(t, filter.checkChainID(other.URL), `chain ID "other-chain" is not "test-chain"`)
+}
+
+func TestFilterPeerMap(t *testing.T) {
+	peers := map[string]*peerInfo{
+		"http://10.0.0.1:26657": {Addr: "http://10.0.0.1:26657"},
+		"http://10.0.0.2:26657": {Addr: "http://10.0.0.2:26657", NodeInfo: &DefaultNodeInfo{Moniker: "validator-1"}},
+		"http://10.0.0.3:26657": {Addr: "http://10.0.0.3:26657", NodeInfo: &DefaultNodeInfo{Moniker: "sentry-0"}},
+		"http://10.0.0.4:26657": {Addr: "http://10.0.0.4:26657", NodeInfo: &DefaultNodeInfo{Moniker: "seed", ListenAddr: "tcp://10.0.0.4:26656"}},
+	}
+	suppliedPeers := map[string]*peerInfo{"http://10.0.0.1:26657": peers["http://10.0.0.1:26657"]}
+	filter, err := newDiscoveryFilter(&Config{DiscoveryIncludePatterns: []string{"^validator-", ":26656$"}, DiscoveryExcludePatterns: []string{"^seed$"}})
+	require.NoError(t, err)
+	logger := logging.NewNoopLogger()
+
+	// the supplied peer is never filtered
+	endpoints, err := filterPeerMap(suppliedPeers, peers, SelectAnyEndpoints, 0, 0, filter, logger)
+	require.NoError(t, err)
+	sort.Strings(endpoints)
+	assert.Equal(t, []string{"ws://10.0.0.1:26657/websocket", "ws://10.0.0.2:26657/websocket"}, endpoints)
+
+	endpoints, err = filterPeerMap(suppliedPeers, peers, SelectDiscoveredEndpoints, 0, 0, filter, logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ws://10.0.0.2:26657/websocket"}, endpoints)
+
+	endpoints, err = filterPeerMap(suppliedPeers, peers, SelectDiscoveredEndpoints, 0, 0, nil, logger)
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 3)
+}
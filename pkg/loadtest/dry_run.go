@@ -0,0 +1,214 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// dryRunSampleTxs is the number of transactions a dry run generates to check
+// that the client factory works and to measure the transactions' size.
+const dryRunSampleTxs = 10
+
+// dryRunPlan describes the load that a load test would generate, as worked out
+// by a dry run.
+type dryRunPlan struct {
+	ClientFactory string
+	Workers       int           // The number of workers that registered (0 for a standalone load test).
+	Time          time.Duration // The duration of the load test, or its expected duration if it's count-bounded (0 if unknown).
+	Count         int           // The number of transactions each connection sends, if the load test is count-bounded.
+	TxSize        int           // The average size of the sample transactions, in bytes.
+	Endpoints     []dryRunEndpoint
+	Excluded      []ExcludedEndpoint
+}
+
+// dryRunEndpoint describes the load that a single endpoint would receive.
+type dryRunEndpoint struct {
+	Endpoint    string
+	Connections int
+	TxRate      float64
+	Txs         uint64
+}
+
+// sampleTxSize checks the configuration with the configured client factory and
+// generates a handful of transactions with it, without sending them, returning
+// their average size.
+func sampleTxSize(cfg Config) (int, error) {
+	factory, ok := clientFactories[cfg.ClientFactory]
+	if !ok {
+		return 0, fmt.Errorf("client factory \"%s\" does not exist", cfg.ClientFactory)
+	}
+	if err := factory.ValidateConfig(cfg); err != nil {
+		return 0, fmt.Errorf("invalid configuration for client factory \"%s\": %w", cfg.ClientFactory, err)
+	}
+	client, err := factory.NewClient(cfg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create client with client factory \"%s\": %w", cfg.ClientFactory, err)
+	}
+	total := 0
+	for i := 0; i < dryRunSampleTxs; i++ {
+		tx, err := client.GenerateTx()
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate sample transaction: %w", err)
+		}
+		if len(tx) == 0 {
+			return 0, fmt.Errorf("client factory \"%s\" generated an empty transaction", cfg.ClientFactory)
+		}
+		total += len(tx)
+	}
+	return total / dryRunSampleTxs, nil
+}
+
+// newDryRunPlan works out the load that the given configuration would
+// generate, given the average size of its transactions.
+func newDryRunPlan(cfg Config, txSize int) dryRunPlan {
+	plan := dryRunPlan{
+		ClientFactory: cfg.ClientFactory,
+		Time:          cfg.expectedRunTime(),
+		TxSize:        txSize,
+	}
+	if cfg.countBounded() {
+		plan.Count = cfg.Count
+	}
+	plan.addEndpoints(cfg)
+	return plan
+}
+
+// addEndpoints adds the load that the given configuration (e.g. a worker's)
+// would generate to the plan, merging it with that of any endpoints already
+// planned.
+func (p *dryRunPlan) addEndpoints(cfg Config) {
+	conns := cfg.endpointConnections(cfg.Endpoints)
+	// without rate limits, every connection sends at the configured rate
+	var rates map[string]float64
+	if len(cfg.EndpointRateLimits) > 0 {
+		rates, _ = cfg.endpointRates(cfg.Endpoints, cfg.EndpointRateLimits, cfg.EndpointWeights)
+	}
+	for i, endpoint := range cfg.Endpoints {
+		rate := cfg.expectedTxRate(conns[i])
+		if rates != nil {
+			rate = rates[endpoint]
+		}
+		txs := cfg.offeredTxs(rate, conns[i])
+		p.addEndpoint(dryRunEndpoint{Endpoint: endpoint, Connections: conns[i], TxRate: rate, Txs: txs})
+	}
+}
+
+func (p *dryRunPlan) addEndpoint(e dryRunEndpoint) {
+	for i := range p.Endpoints {
+		if p.Endpoints[i].Endpoint == e.Endpoint {
+			p.Endpoints[i].Connections += e.Connections
+			p.Endpoints[i].TxRate += e.TxRate
+			p.Endpoints[i].Txs += e.Txs
+			return
+		}
+	}
+	p.Endpoints = append(p.Endpoints, e)
+}
+
+// totals returns the total expected transaction rate, number of transactions
+// and number of bytes across all endpoints.
+func (p dryRunPlan) totals() (float64, uint64, uint64) {
+	rate, txs := float64(0), uint64(0)
+	for _, e := range p.Endpoints {
+		rate += e.TxRate
+		txs += e.Txs
+	}
+	return rate, txs, txs * uint64(p.TxSize)
+}
+
+// write writes a human-readable summary of the plan to the given writer.
+func (p dryRunPlan) write(out io.Writer) error {
+	rate, txs, bytes := p.totals()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Dry run summary (no transactions were sent)")
+	fmt.Fprintf(w, "  Client factory\t%s\n", p.ClientFactory)
+	if p.Workers > 0 {
+		fmt.Fprintf(w, "  Workers\t%d\n", p.Workers)
+	}
+	switch {
+	case p.Count > 0 && p.Time > 0:
+		fmt.Fprintf(w, "  Duration\t~%s\t(until %d txs per connection)\n", p.Time, p.Count)
+	case p.Count > 0:
+		fmt.Fprintf(w, "  Duration\tunbounded\t(until %d txs per connection)\n", p.Count)
+	default:
+		fmt.Fprintf(w, "  Duration\t%s\n", p.Time)
+	}
+	fmt.Fprintf(w, "  Transaction size\t%d bytes\t(average of %d samples)\n", p.TxSize, dryRunSampleTxs)
+	fmt.Fprintf(w, "  Expected transactions\t%d\t(%.2f txs/sec)\n", txs, rate)
+	fmt.Fprintf(w, "  Expected bytes\t%d\t(%.2f bytes/sec)\n", bytes, rate*float64(p.TxSize))
+	fmt.Fprintln(w, "  Endpoint\tConnections\tRate (txs/sec)\tTransactions")
+	for _, e := range p.Endpoints {
+		fmt.Fprintf(w, "  %s\t%d\t%.2f\t%d\n", e.Endpoint, e.Connections, e.TxRate, e.Txs)
+	}
+	for _, e := range p.Excluded {
+		fmt.Fprintf(w, "  %s\texcluded\t%s\n", e.Endpoint, e.Reason)
+	}
+	return w.Flush()
+}
+
+// dryRunStandalone completes a standalone dry run once the endpoints have been
+// checked, printing the load that the load test would generate to stdout.
+func dryRunStandalone(cfg Config, excluded []ExcludedEndpoint, logger logging.Logger) error {
+	txSize, err := sampleTxSize(cfg)
+	if err != nil {
+		logger.Error("Dry run failed", "err", err)
+		return connectivityError(err)
+	}
+	if err := calibrateRateFeasibility(cfg, logger); err != nil {
+		logger.Error("Dry run failed", "err", err)
+		return configError(err)
+	}
+	plan := newDryRunPlan(cfg, txSize)
+	plan.Excluded = excluded
+	logger.Info("Dry run complete")
+	return plan.write(os.Stdout)
+}
+
+// finishDryRun completes a coordinator's dry run once all the workers have
+// registered, checking that each worker's rate is achievable given the
+// calibrated cost of generating a transaction and printing the load that they
+// would generate between them to stdout before telling them to shut down.
+func (c *Coordinator) finishDryRun(txSize int, txCost time.Duration) error {
+	c.assignEndpointShards()
+	if c.coordCfg.TotalRate > 0 {
+		c.mtx.Lock()
+		c.txRateShare = c.coordCfg.TotalRate / float64(len(c.workers))
+		c.mtx.Unlock()
+	}
+	plan := dryRunPlan{
+		ClientFactory: c.cfg.ClientFactory,
+		Workers:       len(c.workers),
+		Time:          c.cfg.expectedRunTime(),
+		TxSize:        txSize,
+		Excluded:      c.excludedEndpoints,
+	}
+	if c.cfg.countBounded() {
+		plan.Count = c.cfg.Count
+	}
+	ids := make([]string, 0, len(c.workers))
+	for id := range c.workers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		workerCfg := c.workerConfig(id)
+		if err := workerCfg.checkRateFeasibility(txCost, c.logger, c.workerFields(id)...); err != nil {
+			err = configError(fmt.Errorf("worker %s: %w", id, err))
+			c.logger.Error("Dry run failed", "err", err)
+			c.shutdownAllRemoteWorkers(false)
+			c.setState(coordFailed)
+			return err
+		}
+		plan.addEndpoints(workerCfg)
+	}
+	c.logger.Info("Dry run complete - shutting down workers", "workers", len(c.workers))
+	c.shutdownAllRemoteWorkers(false)
+	c.setState(coordCompleted)
+	return plan.write(os.Stdout)
+}
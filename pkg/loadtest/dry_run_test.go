@@ -0,0 +1,116 @@
+package loadtest_test
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureStdout returns what the given function writes to stdout, along with
+// the error it returns.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdout = w
+	err = fn()
+	os.Stdout = stdout
+	require.NoError(t, w.Close())
+	out, rerr := io.ReadAll(r)
+	require.NoError(t, rerr)
+	return strings.Join(strings.Fields(string(out)), " "), err
+}
+
+func TestStandaloneDryRun(t *testing.T) {
+	svr1 := newMockRPCServer(t, 0)
+	svr2 := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr1.URL(), svr2.URL())
+	cfg.Connections = 2
+	cfg.Count = -1
+	cfg.Time = seconds(60)
+	cfg.Rate = 5
+	cfg.Size = 250
+	cfg.DryRun = true
+	require.NoError(t, cfg.Validate())
+
+	out, err := captureStdout(t, func() error { return loadtest.ExecuteStandalone(cfg) })
+	require.NoError(t, err)
+	assert.Contains(t, out, "Dry run summary (no transactions were sent)")
+	assert.Contains(t, out, "Transaction size 250 bytes")
+	// 2 endpoints x 2 connections x 5 txs/sec for 60s
+	assert.Contains(t, out, "Expected transactions 1200 (20.00 txs/sec)")
+	assert.Contains(t, out, "Expected bytes 300000 (5000.00 bytes/sec)")
+	assert.Contains(t, out, svr1.URL()+" 2 10.00 600")
+	assert.Contains(t, out, svr2.URL()+" 2 10.00 600")
+	// nothing was broadcast, or even connected to
+	assert.Zero(t, svr1.Requests())
+	assert.Zero(t, svr2.Requests())
+	assert.Zero(t, svr1.Connections())
+	assert.Zero(t, svr2.Connections())
+}
+
+func TestStandaloneDryRunCountBounded(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Connections = 2
+	cfg.Count = 500
+	cfg.Time = 0
+	cfg.Rate = 5
+	cfg.DryRun = true
+	require.NoError(t, cfg.Validate())
+
+	out, err := captureStdout(t, func() error { return loadtest.ExecuteStandalone(cfg) })
+	require.NoError(t, err)
+	// 500 txs per connection at 5 txs/sec
+	assert.Contains(t, out, "Duration ~1m40s (until 500 txs per connection)")
+	assert.Contains(t, out, "Expected transactions 1000 (10.00 txs/sec)")
+	assert.Zero(t, svr.Requests())
+}
+
+func TestStandaloneDryRunFailures(t *testing.T) {
+	live := newMockRPCServer(t, 0)
+	dead := "ws://" + freeLocalAddr(t) + "/websocket"
+
+	// an unreachable endpoint would fail the load test
+	cfg := mockTestConfig(live.URL(), dead)
+	cfg.DryRun = true
+	_, err := captureStdout(t, func() error { return loadtest.ExecuteStandalone(cfg) })
+	require.ErrorContains(t, err, "endpoint "+dead+" failed its health check")
+
+	// unless unhealthy endpoints are left out
+	cfg.HealthCheck = true
+	out, err := captureStdout(t, func() error { return loadtest.ExecuteStandalone(cfg) })
+	require.NoError(t, err)
+	assert.Contains(t, out, dead+" excluded")
+	assert.Contains(t, out, "Expected transactions 10 (10.00 txs/sec)")
+
+	// transactions too small for the client factory
+	cfg.Size = 5
+	_, err = captureStdout(t, func() error { return loadtest.ExecuteStandalone(cfg) })
+	require.ErrorContains(t, err, "transaction size 5 is too small")
+	assert.Zero(t, live.Requests())
+}
+
+func TestCoordinatorDryRun(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Count = -1
+	cfg.Time = seconds(10)
+	cfg.DryRun = true
+
+	out, err := captureStdout(t, func() error {
+		runCoordinatorWorkersWith(t, cfg, loadtest.CoordinatorConfig{TotalRate: 30}, 2)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out, "Workers 2")
+	assert.Contains(t, out, "Expected transactions 300 (30.00 txs/sec)")
+	assert.Contains(t, out, svr.URL()+" 2 30.00 300")
+	assert.Zero(t, svr.Requests())
+	assert.Zero(t, svr.Connections())
+}
@@ -0,0 +1,108 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration that can be given either as a duration string
+// (e.g. "250ms" or "2m") or, for backward compatibility, as a bare number of
+// seconds (e.g. 60), both on the command line and in JSON.
+//
+// Durations are written to JSON as (possibly fractional) numbers of seconds, so
+// that reports, checkpoints and the configurations sent to workers keep the
+// form they had when these were integers.
+type Duration time.Duration
+
+// The load testing, coordinator and worker configuration fields holding
+// Durations, by their JSON names.
+var (
+	durationFields       = jsonDurationFields(Config{})
+	coordDurationFields  = jsonDurationFields(CoordinatorConfig{})
+	workerDurationFields = jsonDurationFields(WorkerConfig{})
+)
+
+// jsonDurationFields returns the JSON names of the Duration fields of the given
+// configuration struct, in order.
+func jsonDurationFields(cfg interface{}) []string {
+	t := reflect.TypeOf(cfg)
+	names := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Type == durationType && len(name) > 0 && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// parseDuration parses the given duration string or bare number of seconds,
+// returning whether it was the latter.
+func parseDuration(s string) (d Duration, bareSeconds bool, err error) {
+	s = strings.TrimSpace(s)
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(secs) || math.IsInf(secs, 0) {
+			return 0, true, fmt.Errorf("invalid duration %q", s)
+		}
+		return Duration(secs * float64(time.Second)), true, nil
+	}
+	td, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid duration %q: expected a duration such as \"250ms\" or \"2m\", or a number of seconds", s)
+	}
+	return Duration(td), false, nil
+}
+
+// Seconds returns the duration as a floating point number of seconds.
+func (d Duration) Seconds() float64 {
+	return time.Duration(d).Seconds()
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatFloat(d.Seconds(), 'f', -1, 64)), nil
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if bytes.HasPrefix(b, []byte(`"`)) {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	parsed, _, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// bareSecondsFields returns the names of the duration fields given as bare
+// numbers of seconds in the given JSON object of configuration overrides.
+func bareSecondsFields(raw json.RawMessage) []string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil
+	}
+	var bare []string
+	for _, name := range durationFields {
+		if v, ok := fields[name]; ok && !bytes.HasPrefix(bytes.TrimSpace(v), []byte(`"`)) {
+			bare = append(bare, name)
+		}
+	}
+	return bare
+}
@@ -0,0 +1,107 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationFlag(t *testing.T) {
+	testCases := []struct {
+		arg         string
+		expected    time.Duration
+		bareSeconds bool
+		expectError bool
+	}{
+		{"60", time.Minute, true, false},
+		{"0.25", 250 * time.Millisecond, true, false},
+		{"250ms", 250 * time.Millisecond, false, false},
+		{"2m", 2 * time.Minute, false, false},
+		{"1h30m", 90 * time.Minute, false, false},
+		{"0", 0, true, false},
+		{"soon", 0, false, true},
+		{"10 seconds", 0, false, true},
+		{"NaN", 0, true, true},
+	}
+	for _, tc := range testCases {
+		var d Duration
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		v := newDurationValue(time.Second, &d)
+		flags.Var(v, "send-period", "")
+		err := flags.Parse([]string{"--send-period", tc.arg})
+		if tc.expectError {
+			assert.Error(t, err, tc.arg)
+			continue
+		}
+		require.NoError(t, err, tc.arg)
+		assert.Equal(t, Duration(tc.expected), d, tc.arg)
+		assert.Equal(t, tc.bareSeconds, v.bareSeconds, tc.arg)
+
+		// the flag's string form parses back to the same duration
+		var roundTripped Duration
+		require.NoError(t, newDurationValue(0, &roundTripped).Set(v.String()), tc.arg)
+		assert.Equal(t, d, roundTripped, tc.arg)
+	}
+
+	// the default applies if the flag isn't given
+	var d Duration
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.Var(newDurationValue(3*time.Minute, &d), "connect-timeout", "")
+	require.NoError(t, flags.Parse(nil))
+	assert.Equal(t, Duration(3*time.Minute), d)
+	assert.Equal(t, "3m0s", flags.Lookup("connect-timeout").DefValue)
+}
+
+func TestDurationJSON(t *testing.T) {
+	testCases := []struct {
+		json     string
+		expected time.Duration
+		written  string
+	}{
+		{`60`, time.Minute, `60`},
+		{`0.25`, 250 * time.Millisecond, `0.25`},
+		{`"1m30s"`, 90 * time.Second, `90`},
+		{`"250ms"`, 250 * time.Millisecond, `0.25`},
+		{`"15"`, 15 * time.Second, `15`},
+	}
+	for _, tc := range testCases {
+		var d Duration
+		require.NoError(t, json.Unmarshal([]byte(tc.json), &d), tc.json)
+		assert.Equal(t, Duration(tc.expected), d, tc.json)
+		b, err := json.Marshal(d)
+		require.NoError(t, err)
+		assert.Equal(t, tc.written, string(b), tc.json)
+	}
+	for _, invalid := range []string{`"soon"`, `true`, `[]`} {
+		var d Duration
+		assert.Error(t, json.Unmarshal([]byte(invalid), &d), invalid)
+	}
+
+	// configurations survive the round trip to workers intact
+	cfg := Config{Time: Duration(90 * time.Second), SendPeriod: Duration(100 * time.Millisecond), PeerConnectTimeout: Duration(10 * time.Minute)}
+	b, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	var decoded Config
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, cfg.Time, decoded.Time)
+	assert.Equal(t, cfg.SendPeriod, decoded.SendPeriod)
+	assert.Equal(t, cfg.PeerConnectTimeout, decoded.PeerConnectTimeout)
+}
+
+func TestBareSecondsFields(t *testing.T) {
+	assert.Equal(t, []string{"time", "peer_connect_timeout"}, bareSecondsFields(json.RawMessage(`{"time":60,"send_period":"1s","peer_connect_timeout":30,"rate":5}`)))
+	assert.Empty(t, bareSecondsFields(json.RawMessage(`{"time":"1m","rate":5}`)))
+}
+
+func TestDurationFields(t *testing.T) {
+	assert.Contains(t, durationFields, "time")
+	assert.Contains(t, durationFields, "rate_window")
+	assert.NotContains(t, durationFields, "rate")
+	assert.Contains(t, coordDurationFields, "start_stagger")
+	assert.Contains(t, coordDurationFields, "connect_timeout")
+	assert.Equal(t, []string{"connect_timeout", "retry_interval", "max_reconnect_time"}, workerDurationFields)
+}
@@ -0,0 +1,209 @@
+package loadtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// EndpointHealthStats summarizes how often endpoints were blacklisted during
+// the load test after repeated failures, and how often they recovered.
+type EndpointHealthStats struct {
+	Blacklistings        int `json:"blacklistings"`         // The total number of times an endpoint was blacklisted.
+	Recoveries           int `json:"recoveries"`            // The total number of times a blacklisted endpoint recovered.
+	HealthyEndpoints     int `json:"healthy_endpoints"`     // The number of endpoints currently healthy.
+	BlacklistedEndpoints int `json:"blacklisted_endpoints"` // The number of endpoints currently blacklisted.
+}
+
+// endpointBlacklist tracks the consecutive failures of each endpoint in a load
+// test, and stops sending to an endpoint once it has failed too many times in
+// a row, optionally probing it periodically until it recovers.
+type endpointBlacklist struct {
+	threshold        int
+	recoveryInterval time.Duration // Zero if blacklisted endpoints are never probed.
+	probeTimeout     time.Duration
+	onChange         func() // Called whenever an endpoint is blacklisted or recovers.
+	logger           logging.Logger
+
+	mtx           sync.Mutex
+	endpoints     map[string]*blacklistEndpoint // Keyed by WebSockets address.
+	blacklistings int
+	recoveries    int
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type blacklistEndpoint struct {
+	addr        string
+	transactors []*Transactor
+
+	failures    int // The number of consecutive failures.
+	blacklisted bool
+}
+
+func newEndpointBlacklist(cfg *Config, transactors []*Transactor, onChange func(), logger logging.Logger) *endpointBlacklist {
+	b := &endpointBlacklist{
+		threshold:        cfg.EndpointFailureThreshold,
+		recoveryInterval: time.Duration(cfg.EndpointRecoveryInterval),
+		probeTimeout:     cfg.healthCheckTimeout(),
+		onChange:         onChange,
+		logger:           logger,
+		endpoints:        make(map[string]*blacklistEndpoint),
+		stop:             make(chan struct{}),
+		stopped:          make(chan struct{}),
+	}
+	b.add(transactors)
+	return b
+}
+
+// add tracks the failures of the endpoints of the given transactors (e.g.
+// those added by re-discovery during the load test).
+func (b *endpointBlacklist) add(transactors []*Transactor) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for _, t := range transactors {
+		ep, exists := b.endpoints[t.remoteAddr]
+		if !exists {
+			ep = &blacklistEndpoint{addr: t.remoteAddr}
+			b.endpoints[t.remoteAddr] = ep
+		}
+		ep.transactors = append(ep.transactors, t)
+		t.setBlacklisted(ep.blacklisted)
+	}
+}
+
+// run probes the blacklisted endpoints at the recovery interval until stopped.
+func (b *endpointBlacklist) run() {
+	defer close(b.stopped)
+	if b.recoveryInterval <= 0 {
+		<-b.stop
+		return
+	}
+
+	ticker := time.NewTicker(b.recoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.probe()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Stop terminates the probing loop and blocks until it has stopped.
+func (b *endpointBlacklist) Stop() {
+	close(b.stop)
+	<-b.stopped
+}
+
+// failed records a failure of the endpoint with the given address, which is
+// blacklisted once it has failed the configured number of times in a row.
+func (b *endpointBlacklist) failed(addr string, err error) {
+	b.mtx.Lock()
+	ep, ok := b.endpoints[addr]
+	if !ok || ep.blacklisted {
+		b.mtx.Unlock()
+		return
+	}
+	ep.failures++
+	if ep.failures < b.threshold {
+		b.mtx.Unlock()
+		return
+	}
+	b.logger.Warn("Blacklisting endpoint after repeated failures", "endpoint", addr, "failures", ep.failures, "err", err)
+	b.setBlacklisted(ep, true)
+	b.mtx.Unlock()
+	b.onChange()
+}
+
+// succeeded resets the consecutive failures of the endpoint with the given
+// address.
+func (b *endpointBlacklist) succeeded(addr string) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if ep, ok := b.endpoints[addr]; ok && !ep.blacklisted {
+		ep.failures = 0
+	}
+}
+
+// probe checks the health of each blacklisted endpoint concurrently, taking
+// those that are healthy again off the blacklist.
+func (b *endpointBlacklist) probe() {
+	b.mtx.Lock()
+	var blacklisted []*blacklistEndpoint
+	for _, ep := range b.endpoints {
+		if ep.blacklisted {
+			blacklisted = append(blacklisted, ep)
+		}
+	}
+	b.mtx.Unlock()
+
+	var wg sync.WaitGroup
+	recovered := make([]bool, len(blacklisted))
+	for i, ep := range blacklisted {
+		wg.Add(1)
+		go func(i int, ep *blacklistEndpoint) {
+			defer wg.Done()
+			httpAddr, err := rpcHTTPAddr(ep.addr)
+			if err == nil {
+				err = checkNodeHealth(newHttpRpcClient(httpAddr), time.Now().Add(b.probeTimeout))
+			}
+			if err != nil {
+				b.logger.Debug("Blacklisted endpoint is still unhealthy", "endpoint", ep.addr, "err", err)
+				return
+			}
+			recovered[i] = true
+		}(i, ep)
+	}
+	wg.Wait()
+
+	changed := false
+	b.mtx.Lock()
+	for i, ep := range blacklisted {
+		if recovered[i] && ep.blacklisted {
+			b.logger.Info("Blacklisted endpoint has recovered - resuming sending", "endpoint", ep.addr)
+			b.setBlacklisted(ep, false)
+			changed = true
+		}
+	}
+	b.mtx.Unlock()
+	if changed {
+		b.onChange()
+	}
+}
+
+// Must be called with the mutex held.
+func (b *endpointBlacklist) setBlacklisted(ep *blacklistEndpoint, blacklisted bool) {
+	ep.blacklisted = blacklisted
+	ep.failures = 0
+	if blacklisted {
+		b.blacklistings++
+	} else {
+		b.recoveries++
+	}
+	for _, t := range ep.transactors {
+		t.setBlacklisted(blacklisted)
+	}
+}
+
+func (b *endpointBlacklist) stats() EndpointHealthStats {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	stats := EndpointHealthStats{
+		Blacklistings: b.blacklistings,
+		Recoveries:    b.recoveries,
+	}
+	for _, ep := range b.endpoints {
+		if ep.blacklisted {
+			stats.BlacklistedEndpoints++
+		} else {
+			stats.HealthyEndpoints++
+		}
+	}
+	return stats
+}
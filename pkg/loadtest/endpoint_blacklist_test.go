@@ -0,0 +1,108 @@
+package loadtest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointBlacklistRedistributesAndRecovers(t *testing.T) {
+	healthy := newMockRPCServer(t, 0)
+	dying := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(healthy.URL(), dying.URL())
+	cfg.Time = seconds(6)
+	cfg.SendPeriod = loadtest.Duration(250 * time.Millisecond)
+	// 20 tx/sec per endpoint
+	cfg.Rate = 5
+	cfg.Count = -1
+	cfg.EndpointFailureThreshold = 2
+	cfg.EndpointRecoveryInterval = loadtest.Duration(250 * time.Millisecond)
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+
+	time.Sleep(time.Second)
+	dying.SetDown(true)
+	require.Eventually(t, func() bool {
+		return tg.EndpointHealthStats().BlacklistedEndpoints == 1
+	}, 2*time.Second, 50*time.Millisecond)
+
+	// the healthy endpoint must take on the dead endpoint's share of the rate
+	assert.InDelta(t, 40, tg.Report().Aggregate.TargetTxRate, 1e-6)
+	before := healthy.Requests()
+	time.Sleep(time.Second)
+	assert.GreaterOrEqual(t, healthy.Requests()-before, 30)
+
+	dying.SetDown(false)
+	require.Eventually(t, func() bool {
+		return tg.EndpointHealthStats().Recoveries == 1
+	}, 2*time.Second, 50*time.Millisecond)
+	revived := dying.Requests()
+	require.NoError(t, tg.Wait())
+
+	assert.Greater(t, dying.Requests(), revived)
+	assert.Equal(t, 2, dying.Connections())
+	stats := tg.Report().Aggregate.EndpointHealth
+	require.NotNil(t, stats)
+	assert.Equal(t, loadtest.EndpointHealthStats{
+		Blacklistings:    1,
+		Recoveries:       1,
+		HealthyEndpoints: 2,
+	}, *stats)
+	assert.InDelta(t, 40, tg.Report().Aggregate.TargetTxRate, 1e-6)
+}
+
+func TestEndpointBlacklistWithoutRecovery(t *testing.T) {
+	healthy := newMockRPCServer(t, 0)
+	dying := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(healthy.URL(), dying.URL())
+	cfg.Time = seconds(3)
+	cfg.SendPeriod = loadtest.Duration(250 * time.Millisecond)
+	cfg.Count = -1
+	cfg.EndpointFailureThreshold = 2
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	time.Sleep(500 * time.Millisecond)
+	dying.SetDown(true)
+	time.Sleep(time.Second)
+	dying.SetDown(false)
+	// the load test must not fail because of the dead endpoint
+	require.NoError(t, tg.Wait())
+
+	// never probed, so it stays blacklisted
+	assert.Equal(t, 1, dying.Connections())
+	assert.Equal(t, loadtest.EndpointHealthStats{
+		Blacklistings:        1,
+		HealthyEndpoints:     1,
+		BlacklistedEndpoints: 1,
+	}, *tg.EndpointHealthStats())
+}
+
+func TestEndpointBlacklistConsecutiveFailures(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	// every other transaction fails, so there are never 2 failures in a row
+	svr.SetFailEvery(2)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(4)
+	// one transaction per send period, so that responses arrive in order
+	cfg.Rate = 1
+	cfg.Count = -1
+	cfg.EndpointFailureThreshold = 2
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	require.NoError(t, tg.Wait())
+
+	assert.Greater(t, tg.Report().Aggregate.FailedTxs, 0)
+	assert.Equal(t, loadtest.EndpointHealthStats{HealthyEndpoints: 1}, *tg.EndpointHealthStats())
+}
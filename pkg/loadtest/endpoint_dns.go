@@ -0,0 +1,108 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Scheme prefixes of endpoints that are expanded through DNS into the
+// individual endpoints behind them (e.g. the pods behind a Kubernetes headless
+// service).
+const (
+	dnsEndpointPrefix    = "dns+"    // e.g. dns+ws://validators.ns.svc:26657/websocket, expanded via A/AAAA records.
+	dnsSRVEndpointPrefix = "dnssrv+" // e.g. dnssrv+ws://_rpc._tcp.validators.ns.svc/websocket, expanded via SRV records.
+)
+
+// dnsLookup looks up the DNS records behind DNS endpoints. It is satisfied by
+// *net.Resolver.
+type dnsLookup interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// The lookup with which DNS endpoints are expanded.
+var dnsResolver dnsLookup = net.DefaultResolver
+
+// isDNSEndpoint returns whether the given endpoint is to be expanded through
+// DNS.
+func isDNSEndpoint(endpoint string) bool {
+	endpoint = strings.TrimSpace(endpoint)
+	return strings.HasPrefix(endpoint, dnsEndpointPrefix) || strings.HasPrefix(endpoint, dnsSRVEndpointPrefix)
+}
+
+// dnsEndpointResolver expands DNS endpoints via their A/AAAA or SRV records.
+type dnsEndpointResolver struct {
+	lookup dnsLookup
+}
+
+var _ EndpointResolver = (*dnsEndpointResolver)(nil)
+
+func (r *dnsEndpointResolver) Handles(endpoint string) bool {
+	return isDNSEndpoint(endpoint)
+}
+
+func (r *dnsEndpointResolver) Resolve(ctx context.Context, endpoint string) ([]string, error) {
+	return resolveDNSEndpoint(ctx, r.lookup, endpoint)
+}
+
+// resolveDNSEndpoint expands the given DNS endpoint into the WebSockets
+// endpoints at each of the addresses (for A/AAAA records) or targets and
+// ports (for SRV records) that its host name resolves to, in a stable order.
+func resolveDNSEndpoint(ctx context.Context, resolver dnsLookup, endpoint string) ([]string, error) {
+	srv := strings.HasPrefix(endpoint, dnsSRVEndpointPrefix)
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS endpoint %s: %w", endpoint, err)
+	}
+	prefix := dnsEndpointPrefix
+	if srv {
+		prefix = dnsSRVEndpointPrefix
+	}
+	u.Scheme = strings.TrimPrefix(u.Scheme, prefix)
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return nil, fmt.Errorf("invalid DNS endpoint %s: expected a scheme of %sws or %swss", endpoint, prefix, prefix)
+	}
+	host := u.Hostname()
+	if len(host) == 0 {
+		return nil, fmt.Errorf("invalid DNS endpoint %s: missing host name", endpoint)
+	}
+
+	hostPorts := make([]string, 0)
+	if srv {
+		_, records, err := resolver.LookupSRV(ctx, "", "", host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up SRV records for %s (endpoint %s): %w", host, endpoint, err)
+		}
+		for _, record := range records {
+			target := strings.TrimSuffix(record.Target, ".")
+			hostPorts = append(hostPorts, net.JoinHostPort(target, strconv.Itoa(int(record.Port))))
+		}
+	} else {
+		if len(u.Port()) == 0 {
+			return nil, fmt.Errorf("invalid DNS endpoint %s: missing port", endpoint)
+		}
+		addrs, err := resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up A/AAAA records for %s (endpoint %s): %w", host, endpoint, err)
+		}
+		for _, addr := range addrs {
+			hostPorts = append(hostPorts, net.JoinHostPort(addr.String(), u.Port()))
+		}
+	}
+	if len(hostPorts) == 0 {
+		return nil, fmt.Errorf("no DNS records found for %s (endpoint %s)", host, endpoint)
+	}
+	sort.Strings(hostPorts)
+
+	endpoints := make([]string, 0, len(hostPorts))
+	for _, hostPort := range hostPorts {
+		u.Host = hostPort
+		endpoints = append(endpoints, u.String())
+	}
+	return endpoints, nil
+}
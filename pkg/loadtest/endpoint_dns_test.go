@@ -0,0 +1,105 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockResolver struct {
+	ips map[string][]string
+	srv map[string][]*net.SRV
+}
+
+func (r *mockResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r.ips[host]
+	if !ok {
+		return nil, fmt.Errorf("no such host")
+	}
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs, nil
+}
+
+func (r *mockResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	records, ok := r.srv[name]
+	if !ok {
+		return "", nil, fmt.Errorf("no such host")
+	}
+	return name, records, nil
+}
+
+func TestConfigExpandDNSEndpoints(t *testing.T) {
+	resolver := &mockResolver{
+		ips: map[string][]string{
+			"validators.ns.svc": {"10.0.0.2", "10.0.0.1", "fd00::1"},
+		},
+		srv: map[string][]*net.SRV{
+			"_rpc._tcp.sentries.ns.svc": {
+				{Target: "sentry-1.sentries.ns.svc.", Port: 26667},
+				{Target: "sentry-0.sentries.ns.svc.", Port: 26657},
+			},
+		},
+	}
+	cfg := Config{
+		Endpoints: []string{
+			"ws://10.0.0.1:26657/websocket",
+			"dns+ws://validators.ns.svc:26657/websocket|weight=2",
+			"dnssrv+wss://_rpc._tcp.sentries.ns.svc/websocket|maxrate=100",
+		},
+	}
+	require.NoError(t, cfg.ParseEndpointRateLimits())
+	require.NoError(t, cfg.resolveEndpoints([]EndpointResolver{&dnsEndpointResolver{lookup: resolver}}))
+	assert.Equal(t, []string{
+		"ws://10.0.0.1:26657/websocket",
+		"ws://10.0.0.2:26657/websocket",
+		"ws://[fd00::1]:26657/websocket",
+		"wss://sentry-0.sentries.ns.svc:26657/websocket",
+		"wss://sentry-1.sentries.ns.svc:26667/websocket",
+	}, cfg.Endpoints)
+	assert.Equal(t, []string{
+		"dns+ws://validators.ns.svc:26657/websocket",
+		"dnssrv+wss://_rpc._tcp.sentries.ns.svc/websocket",
+	}, cfg.ResolvedEndpoints)
+	// the endpoint listed explicitly keeps its own (default) weight
+	assert.Equal(t, map[string]float64{
+		"ws://10.0.0.2:26657/websocket":  2,
+		"ws://[fd00::1]:26657/websocket": 2,
+	}, cfg.EndpointWeights)
+	assert.Equal(t, map[string]float64{
+		"wss://sentry-0.sentries.ns.svc:26657/websocket": 100,
+		"wss://sentry-1.sentries.ns.svc:26667/websocket": 100,
+	}, cfg.EndpointRateLimits)
+
+	// expanding again is a no-op
+	require.NoError(t, cfg.resolveEndpoints([]EndpointResolver{&dnsEndpointResolver{lookup: &mockResolver{}}}))
+	assert.Len(t, cfg.Endpoints, 5)
+}
+
+func TestConfigExpandDNSEndpointsErrors(t *testing.T) {
+	resolver := &mockResolver{
+		ips: map[string][]string{"empty.ns.svc": {}},
+	}
+	testCases := []struct {
+		endpoint    string
+		expectError string
+	}{
+		{"dns+ws://missing.ns.svc:26657/websocket", "A/AAAA records for missing.ns.svc"},
+		{"dnssrv+ws://_rpc._tcp.missing.ns.svc/websocket", "SRV records for _rpc._tcp.missing.ns.svc"},
+		{"dns+ws://empty.ns.svc:26657/websocket", "no DNS records found for empty.ns.svc"},
+		{"dns+ws://validators.ns.svc/websocket", "missing port"},
+		{"dns+http://validators.ns.svc:26657", "expected a scheme of dns+ws or dns+wss"},
+	}
+	for _, tc := range testCases {
+		cfg := Config{Endpoints: []string{tc.endpoint}}
+		err := cfg.resolveEndpoints([]EndpointResolver{&dnsEndpointResolver{lookup: resolver}})
+		require.Error(t, err, tc.endpoint)
+		assert.Contains(t, err.Error(), tc.expectError, tc.endpoint)
+	}
+}
@@ -0,0 +1,130 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const defaultHealthCheckTimeout = 5 * time.Second
+
+// ExcludedEndpoint is an endpoint left out of the load test because it failed
+// its health check.
+type ExcludedEndpoint struct {
+	Endpoint string `json:"endpoint"` // The endpoint's WebSockets address.
+	Reason   string `json:"reason"`   // Why the endpoint's health check failed.
+}
+
+func (c Config) healthCheckTimeout() time.Duration {
+	if c.HealthCheckTimeout > 0 {
+		return time.Duration(c.HealthCheckTimeout)
+	}
+	return defaultHealthCheckTimeout
+}
+
+func (c Config) minHealthyEndpoints() int {
+	if c.MinHealthyEndpoints > 0 {
+		return c.MinHealthyEndpoints
+	}
+	return 1
+}
+
+// checkEndpointsHealth checks the health of all of the configured endpoints
+// concurrently (if configured to), and leaves those that fail out of the
+// configuration, returning them. Fails if fewer than the minimum number of
+// endpoints are healthy. A dry run always checks the endpoints' health, but
+// only leaves out those that fail if configured to, since the load test
+// itself would otherwise fail on them.
+func checkEndpointsHealth(cfg *Config, logger logging.Logger) ([]ExcludedEndpoint, error) {
+	if !cfg.HealthCheck && !cfg.DryRun {
+		return nil, nil
+	}
+	timeout := cfg.healthCheckTimeout()
+	logger.Info("Checking endpoints' health", "endpoints", len(cfg.Endpoints), "timeout", timeout)
+	errs := make([]error, len(cfg.Endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range cfg.Endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			errs[i] = checkEndpointHealth(endpoint, cfg.RPCVersion, timeout)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	healthy := make([]string, 0, len(cfg.Endpoints))
+	var excluded []ExcludedEndpoint
+	for i, endpoint := range cfg.Endpoints {
+		if errs[i] != nil && !cfg.HealthCheck {
+			return nil, fmt.Errorf("endpoint %s failed its health check: %w", endpoint, errs[i])
+		}
+		if errs[i] != nil {
+			logger.Warn("Excluding unhealthy endpoint from load test", "endpoint", endpoint, "err", errs[i])
+			excluded = append(excluded, ExcludedEndpoint{Endpoint: endpoint, Reason: errs[i].Error()})
+			continue
+		}
+		healthy = append(healthy, endpoint)
+	}
+	if minHealthy := cfg.minHealthyEndpoints(); len(healthy) < minHealthy {
+		return excluded, fmt.Errorf("only %d of %d endpoints are healthy, but at least %d are required", len(healthy), len(cfg.Endpoints), minHealthy)
+	}
+	if len(excluded) > 0 {
+		logger.Info("Excluded unhealthy endpoints", "excluded", len(excluded), "remaining", len(healthy))
+	} else {
+		logger.Info("All endpoints are healthy")
+	}
+	cfg.Endpoints = healthy
+	return excluded, nil
+}
+
+// checkEndpointHealth calls the health and status RPC APIs of the given
+// WebSockets endpoint's node, which must both succeed within the given
+// timeout. Unless the RPC version is forced, the routes of each RPC version
+// we support are tried, as when detecting the endpoint's RPC version.
+func checkEndpointHealth(endpoint, rpcVersion string, timeout time.Duration) error {
+	versions := detectableRPCVersions
+	if rpcVersion == RPCVersionLegacy || rpcVersion == RPCVersionV1 {
+		versions = []string{rpcVersion}
+	}
+	deadline := time.Now().Add(timeout)
+	errs := make([]string, 0, len(versions))
+	for _, version := range versions {
+		versionedAddr, err := rpcWebSocketAddr(endpoint, version)
+		if err != nil {
+			return err
+		}
+		httpAddr, err := rpcHTTPAddr(versionedAddr)
+		if err != nil {
+			return err
+		}
+		if err = checkNodeHealth(newHttpRpcClient(httpAddr), deadline); err == nil {
+			return nil
+		}
+		if len(versions) == 1 {
+			return err
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", version, err))
+	}
+	return fmt.Errorf("health check failed (%s)", strings.Join(errs, "; "))
+}
+
+// checkNodeHealth calls the health and status RPC APIs via the given client,
+// giving up at the given deadline.
+func checkNodeHealth(client *httpClient, deadline time.Time) error {
+	defer client.close()
+	var health json.RawMessage
+	for _, method := range []string{"health", "status"} {
+		client.client.Timeout = time.Until(deadline)
+		if client.client.Timeout <= 0 {
+			return fmt.Errorf("timed out checking health of %s", client.addr)
+		}
+		if err := client.get(method, &health); err != nil {
+			return err
+		}
+	}
+	return nil
+}
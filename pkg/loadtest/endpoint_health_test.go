@@ -0,0 +1,110 @@
+package loadtest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHangingServer returns the WebSockets address of an endpoint that
+// accepts connections but never responds.
+func newHangingServer(t *testing.T) string {
+	done := make(chan struct{})
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	t.Cleanup(func() {
+		close(done)
+		svr.Close()
+	})
+	return "ws" + strings.TrimPrefix(svr.URL, "http") + "/websocket"
+}
+
+func TestStandaloneHealthCheck(t *testing.T) {
+	live1 := newMockRPCServer(t, 0)
+	live2 := newMockRPCServer(t, 0)
+	dead := "ws://" + freeLocalAddr(t) + "/websocket"
+	hanging := newHangingServer(t)
+
+	cfg := mockTestConfig(live1.URL(), dead, live2.URL(), hanging)
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.HealthCheck = true
+	cfg.HealthCheckTimeout = loadtest.Duration(500 * time.Millisecond)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	require.NoError(t, cfg.Validate())
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	report := readJSONReport(t, cfg.StatsOutputFile)
+	assert.Equal(t, []string{live1.URL(), live2.URL()}, report.Config.Endpoints)
+	require.Len(t, report.Aggregate.ExcludedEndpoints, 2)
+	assert.Equal(t, dead, report.Aggregate.ExcludedEndpoints[0].Endpoint)
+	assert.Contains(t, report.Aggregate.ExcludedEndpoints[0].Reason, "connection refused")
+	assert.Equal(t, hanging, report.Aggregate.ExcludedEndpoints[1].Endpoint)
+	assert.NotEmpty(t, report.Aggregate.ExcludedEndpoints[1].Reason)
+	assert.Greater(t, live1.Requests(), 0)
+	assert.Greater(t, live2.Requests(), 0)
+}
+
+func TestStandaloneHealthCheckMinHealthyEndpoints(t *testing.T) {
+	live := newMockRPCServer(t, 0)
+	dead := "ws://" + freeLocalAddr(t) + "/websocket"
+
+	cfg := mockTestConfig(live.URL(), dead)
+	cfg.HealthCheck = true
+	cfg.MinHealthyEndpoints = 2
+	err := loadtest.ExecuteStandalone(cfg)
+	require.ErrorContains(t, err, "only 1 of 2 endpoints are healthy, but at least 2 are required")
+	assert.Equal(t, loadtest.FailureConnectivity, loadtest.ClassifyError(err))
+	assert.Equal(t, 0, live.Connections())
+
+	// without health checks, the load test fails on the dead endpoint
+	cfg.HealthCheck = false
+	err = loadtest.ExecuteStandalone(cfg)
+	require.Error(t, err)
+	assert.Equal(t, loadtest.ExitCodeConnectivity, loadtest.ExitCodeFor(err))
+}
+
+func TestCoordinatorHealthCheck(t *testing.T) {
+	live := newMockRPCServer(t, 0)
+	dead := "ws://" + freeLocalAddr(t) + "/websocket"
+
+	cfg := mockTestConfig(dead, live.URL())
+	cfg.Time = seconds(2)
+	cfg.HealthCheck = true
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	// the worker only gets the healthy endpoint, so doesn't fail
+	require.NoError(t, worker.Run())
+	require.NoError(t, <-coordErr)
+
+	report := readJSONReport(t, cfg.StatsOutputFile)
+	assert.Equal(t, []string{live.URL()}, report.Config.Endpoints)
+	require.Len(t, report.Aggregate.ExcludedEndpoints, 1)
+	assert.Equal(t, dead, report.Aggregate.ExcludedEndpoints[0].Endpoint)
+	assert.Contains(t, report.Aggregate.ExcludedEndpoints[0].Reason, "connection refused")
+	assert.Equal(t, report.Aggregate.TotalTxs, live.Requests())
+}
@@ -0,0 +1,72 @@
+package loadtest_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPv6SuppliedEndpoint(t *testing.T) {
+	svr := newMockRPCServerIPv6(t, 0)
+	require.True(t, strings.HasPrefix(svr.URL(), "ws://[::1]:"), svr.URL())
+	cfg := mockTestConfig(svr.URL() + "|weight=2")
+	cfg.Time = seconds(3)
+	cfg.Count = -1
+	cfg.WorkerMetricsAddr = freeLocalAddr(t)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.csv")
+
+	done := make(chan error, 1)
+	go func() { done <- loadtest.ExecuteStandalone(cfg) }()
+
+	time.Sleep(2 * time.Second)
+	resp, err := http.Get("http://" + cfg.WorkerMetricsAddr + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `tmloadtest_worker_open_connections{endpoint="`+svr.URL()+`"} 1`)
+	require.NoError(t, <-done)
+
+	assert.Greater(t, svr.Requests(), 0)
+	stats, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(stats), "endpoint_total_txs["+svr.URL()+"]")
+	assert.Contains(t, string(stats), "endpoint_weight["+svr.URL()+"],2,")
+}
+
+func TestIPv6RediscoveredEndpoint(t *testing.T) {
+	initial := newMockRPCServer(t, 0)
+	joining := newMockRPCServerIPv6(t, 0)
+	cfg := mockTestConfig(initial.URL())
+	cfg.EndpointSelectMethod = loadtest.SelectAnyEndpoints
+	cfg.Time = seconds(3)
+	cfg.SendPeriod = loadtest.Duration(250 * time.Millisecond)
+	cfg.Count = -1
+	cfg.RediscoveryInterval = loadtest.Duration(250 * time.Millisecond)
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	// the IPv6 peer is reported by its unbracketed remote IP
+	initial.SetPeers(joining)
+	require.Eventually(t, func() bool {
+		return joining.Connections() > 0
+	}, 2*time.Second, 50*time.Millisecond)
+	require.NoError(t, tg.Wait())
+
+	assert.Greater(t, joining.Requests(), 0)
+	endpoints := make([]string, 0)
+	for _, ep := range tg.Report().Aggregate.Endpoints {
+		endpoints = append(endpoints, ep.Endpoint)
+	}
+	assert.ElementsMatch(t, []string{initial.URL(), joining.URL()}, endpoints)
+}
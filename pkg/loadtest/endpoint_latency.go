@@ -0,0 +1,93 @@
+package loadtest
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// The number of status RPC round trips with which the latency of each
+// endpoint is measured, if endpoints are selected by latency.
+const latencyProbeCount = 3
+
+// EndpointLatency is the round-trip time to an endpoint measured before the
+// load test, if endpoints are selected by latency.
+type EndpointLatency struct {
+	Endpoint  string  `json:"endpoint"`             // The endpoint's WebSockets address.
+	MedianRTT float64 `json:"median_rtt,omitempty"` // The median round-trip time (in seconds) of the endpoint's status RPC API.
+	Preferred bool    `json:"preferred"`            // Whether the endpoint was amongst the fastest, and so sent transactions from the start.
+	Error     string  `json:"error,omitempty"`      // Why the endpoint's latency couldn't be measured, if it couldn't.
+}
+
+// preferredEndpoints returns the number of endpoints with the lowest latency
+// to which to send, if endpoints are selected by latency.
+func (c Config) preferredEndpoints() int {
+	if c.PreferredEndpointCount > 0 {
+		return c.PreferredEndpointCount
+	}
+	return 1
+}
+
+// probeEndpointLatencies measures the latency of each of the given endpoints
+// concurrently, returning them ordered from the fastest to the slowest, with
+// those whose latency couldn't be measured last (in their original order).
+// The first preferred endpoints are marked as such.
+func probeEndpointLatencies(endpoints []string, preferred int, timeout time.Duration, logger logging.Logger) []EndpointLatency {
+	latencies := make([]EndpointLatency, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			latencies[i].Endpoint = endpoint
+			rtt, err := probeEndpointLatency(endpoint, timeout)
+			if err != nil {
+				latencies[i].Error = err.Error()
+				return
+			}
+			latencies[i].MedianRTT = rtt.Seconds()
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	sort.SliceStable(latencies, func(i, j int) bool {
+		if (len(latencies[i].Error) == 0) != (len(latencies[j].Error) == 0) {
+			return len(latencies[i].Error) == 0
+		}
+		return latencies[i].MedianRTT < latencies[j].MedianRTT
+	})
+	for i := range latencies {
+		latencies[i].Preferred = i < preferred
+		if len(latencies[i].Error) > 0 {
+			logger.Warn("Failed to measure endpoint latency", "endpoint", latencies[i].Endpoint, "preferred", latencies[i].Preferred, "err", latencies[i].Error)
+			continue
+		}
+		logger.Info("Measured endpoint latency", "endpoint", latencies[i].Endpoint, "medianRTT", fmt.Sprintf("%.3fms", latencies[i].MedianRTT*1000), "preferred", latencies[i].Preferred)
+	}
+	return latencies
+}
+
+// probeEndpointLatency returns the median round-trip time of a few calls to
+// the status RPC API of the given WebSockets endpoint's node.
+func probeEndpointLatency(endpoint string, timeout time.Duration) (time.Duration, error) {
+	httpAddr, err := rpcHTTPAddr(endpoint)
+	if err != nil {
+		return 0, err
+	}
+	client := newHttpRpcClient(httpAddr)
+	defer client.close()
+	client.client.Timeout = timeout
+	rtts := make([]time.Duration, 0, latencyProbeCount)
+	for i := 0; i < latencyProbeCount; i++ {
+		start := time.Now()
+		if _, err := client.status(); err != nil {
+			return 0, err
+		}
+		rtts = append(rtts, time.Since(start))
+	}
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	return rtts[len(rtts)/2], nil
+}
@@ -0,0 +1,84 @@
+package loadtest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLowestLatencyEndpointSelection(t *testing.T) {
+	slow := newMockRPCServer(t, 0)
+	slow.SetStatusDelay(100 * time.Millisecond)
+	fast := newMockRPCServer(t, 0)
+	medium := newMockRPCServer(t, 0)
+	medium.SetStatusDelay(50 * time.Millisecond)
+	cfg := mockTestConfig(slow.URL(), fast.URL(), medium.URL())
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.EndpointSelectMethod = loadtest.SelectLowestLatencyEndpoints
+	cfg.PreferredEndpointCount = 1
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	require.NoError(t, tg.Wait())
+
+	// without blacklisting, the slower endpoints aren't connected to at all
+	assert.Equal(t, []string{fast.URL()}, cfg.Endpoints)
+	assert.Greater(t, fast.Requests(), 0)
+	assert.Equal(t, 0, medium.Connections())
+	assert.Equal(t, 0, slow.Connections())
+
+	latencies := tg.Report().Aggregate.EndpointLatencies
+	require.Len(t, latencies, 3)
+	assert.Equal(t, fast.URL(), latencies[0].Endpoint)
+	assert.True(t, latencies[0].Preferred)
+	assert.Equal(t, medium.URL(), latencies[1].Endpoint)
+	assert.False(t, latencies[1].Preferred)
+	assert.Equal(t, slow.URL(), latencies[2].Endpoint)
+	assert.GreaterOrEqual(t, latencies[2].MedianRTT, 0.1)
+	assert.InDelta(t, 10, tg.Report().Aggregate.TargetTxRate, 1e-6)
+}
+
+func TestLowestLatencyEndpointStandby(t *testing.T) {
+	fast := newMockRPCServer(t, 0)
+	medium := newMockRPCServer(t, 0)
+	medium.SetStatusDelay(50 * time.Millisecond)
+	slow := newMockRPCServer(t, 0)
+	slow.SetStatusDelay(100 * time.Millisecond)
+	cfg := mockTestConfig(slow.URL(), medium.URL(), fast.URL())
+	cfg.Time = seconds(3)
+	cfg.SendPeriod = loadtest.Duration(250 * time.Millisecond)
+	cfg.Rate = 5
+	cfg.Count = -1
+	cfg.EndpointSelectMethod = loadtest.SelectLowestLatencyEndpoints
+	cfg.EndpointFailureThreshold = 2
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	// the standby endpoints don't add to the overall rate
+	assert.InDelta(t, 20, tg.Report().Aggregate.TargetTxRate, 1e-6)
+	tg.Start()
+
+	time.Sleep(750 * time.Millisecond)
+	assert.Greater(t, fast.Requests(), 0)
+	assert.Equal(t, 0, medium.Requests())
+	assert.Equal(t, 0, slow.Requests())
+
+	fast.SetDown(true)
+	require.Eventually(t, func() bool {
+		return tg.EndpointHealthStats().BlacklistedEndpoints == 1
+	}, 2*time.Second, 50*time.Millisecond)
+	require.NoError(t, tg.Wait())
+
+	// only the next fastest endpoint stands in for the blacklisted one, at
+	// the same overall rate
+	assert.Greater(t, medium.Requests(), 0)
+	assert.Equal(t, 0, slow.Requests())
+	assert.InDelta(t, 20, tg.Report().Aggregate.TargetTxRate, 1e-6)
+}
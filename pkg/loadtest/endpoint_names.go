@@ -0,0 +1,50 @@
+package loadtest
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Endpoint aliases must be usable as they are in stats row names and metric
+// labels.
+var endpointNameRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+func validateEndpointName(name string) error {
+	if !endpointNameRe.MatchString(name) {
+		return fmt.Errorf("\"%s\" must start with an alphanumeric character and only contain alphanumeric characters, dots, dashes and underscores", name)
+	}
+	return nil
+}
+
+// parseEndpointNames returns the aliases of the configured endpoints, keyed by
+// address, from both EndpointNames and the endpoints' options, checking that
+// no two endpoints share an alias.
+func (c Config) parseEndpointNames() (map[string]string, error) {
+	names := make(map[string]string, len(c.EndpointNames))
+	for addr, name := range c.EndpointNames {
+		names[addr] = name
+	}
+	for _, endpoint := range c.Endpoints {
+		addr, opts, err := parseEndpoint(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if len(opts.name) > 0 {
+			names[addr] = opts.name
+		}
+	}
+	byName := make(map[string]string, len(names))
+	for addr, name := range names {
+		if err := validateEndpointName(name); err != nil {
+			return nil, fmt.Errorf("invalid name for endpoint %s: %w", addr, err)
+		}
+		if other, exists := byName[name]; exists {
+			if other > addr {
+				other, addr = addr, other
+			}
+			return nil, fmt.Errorf("endpoints %s and %s have the same name \"%s\", but names must be unique", other, addr, name)
+		}
+		byName[name] = addr
+	}
+	return names, nil
+}
@@ -0,0 +1,83 @@
+package loadtest_test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointNamesLabelStatsAndMetrics(t *testing.T) {
+	named := newMockRPCServer(t, 0)
+	unnamed := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(named.URL()+"|name=validator-eu-1", unnamed.URL())
+	cfg.Time = seconds(3)
+	cfg.Count = -1
+	cfg.WorkerMetricsAddr = freeLocalAddr(t)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.csv")
+
+	done := make(chan error, 1)
+	go func() { done <- loadtest.ExecuteStandalone(cfg) }()
+
+	time.Sleep(2 * time.Second)
+	resp, err := http.Get("http://" + cfg.WorkerMetricsAddr + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	metrics := string(body)
+	assert.Contains(t, metrics, `tmloadtest_worker_open_connections{endpoint="validator-eu-1"} 1`)
+	assert.Contains(t, metrics, `tmloadtest_worker_open_connections{endpoint="`+unnamed.URL()+`"} 1`)
+	assert.NotContains(t, metrics, named.URL())
+	require.NoError(t, <-done)
+
+	data, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	stats := string(data)
+	assert.Contains(t, stats, "endpoint_total_txs[validator-eu-1],")
+	assert.Contains(t, stats, "endpoint_total_txs["+unnamed.URL()+"],")
+	// the named endpoint's address is still recorded
+	assert.Contains(t, stats, "endpoint_url[validator-eu-1],"+named.URL()+",")
+	assert.NotContains(t, stats, "endpoint_total_txs["+named.URL()+"]")
+}
+
+func TestCoordinatorEndpointNames(t *testing.T) {
+	eu := newMockRPCServer(t, 0)
+	us := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(eu.URL()+"|name=validator-eu-1", us.URL()+"|name=validator-us-1")
+	cfg.Time = seconds(2)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	coordErr := make(chan error, 1)
+	go func() { coordErr <- coord.Run() }()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	require.NoError(t, worker.Run())
+	require.NoError(t, <-coordErr)
+
+	// the names must reach the worker with the endpoints, and come back with
+	// its statistics
+	report := readJSONReport(t, cfg.StatsOutputFile)
+	names := make(map[string]string)
+	for _, ep := range report.Aggregate.Endpoints {
+		names[ep.Endpoint] = ep.Name
+	}
+	assert.Equal(t, map[string]string{eu.URL(): "validator-eu-1", us.URL(): "validator-us-1"}, names)
+}
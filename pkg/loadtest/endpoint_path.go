@@ -0,0 +1,70 @@
+package loadtest
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// The path of nodes' WebSockets RPC endpoint, which is appended to endpoints
+// given without a path (unless endpoints are strict).
+const defaultWebSocketPath = "/websocket"
+
+// endpointMissingPath returns whether the given endpoint address (without
+// options) is a ws:// or wss:// URL, or a DNS endpoint of one, without a
+// path, e.g. "ws://host:26657" or "ws://host:26657/".
+func endpointMissingPath(addr string) bool {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return false
+	}
+	scheme := strings.TrimPrefix(strings.TrimPrefix(u.Scheme, dnsSRVEndpointPrefix), dnsEndpointPrefix)
+	if scheme != "ws" && scheme != "wss" {
+		return false
+	}
+	return u.Path == "" || u.Path == "/"
+}
+
+// normalizeEndpointPath appends the default WebSockets path to the given
+// endpoint address (without options) if it has no path. Custom paths are left
+// as they are.
+func normalizeEndpointPath(addr string) string {
+	if !endpointMissingPath(addr) {
+		return addr
+	}
+	u, _ := url.Parse(addr)
+	u.Path = defaultWebSocketPath
+	return u.String()
+}
+
+// endpointAddr returns the address of the given endpoint, without any
+// options, as given.
+func endpointAddr(endpoint string) string {
+	return strings.TrimSpace(strings.Split(endpoint, endpointOptionsSeparator)[0])
+}
+
+// checkEndpointPaths fails if endpoints are strict and any of the configured
+// endpoints has no path.
+func (c Config) checkEndpointPaths() error {
+	if !c.StrictEndpoints {
+		return nil
+	}
+	for _, endpoint := range c.Endpoints {
+		if addr := endpointAddr(endpoint); endpointMissingPath(addr) {
+			return fmt.Errorf("endpoint %s has no path: expected the node's WebSockets RPC endpoint, e.g. %s", addr, normalizeEndpointPath(addr))
+		}
+	}
+	return nil
+}
+
+// logEndpointPathDefaults logs each of the given endpoints to which the
+// default WebSockets path is appended when parsing them.
+func logEndpointPathDefaults(endpoints []string, logger logging.Logger) {
+	for _, endpoint := range endpoints {
+		if addr := endpointAddr(endpoint); endpointMissingPath(addr) {
+			logger.Info("Appending default WebSockets path to endpoint without one", "endpoint", addr, "normalized", normalizeEndpointPath(addr))
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package loadtest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Separates an endpoint's address from its options, e.g.
+	// "ws://host:26657/websocket|maxrate=200".
+	endpointOptionsSeparator = "|"
+
+	endpointMaxRateOption = "maxrate"
+	endpointWeightOption  = "weight"
+	endpointNameOption    = "name"
+)
+
+// EndpointStats summarizes the transactions sent to a single endpoint.
+type EndpointStats struct {
+	Endpoint      string  `json:"endpoint"`                 // The endpoint's WebSockets address.
+	Name          string  `json:"name,omitempty"`           // The endpoint's alias, if it has one, with which it's labelled instead of its address.
+	TargetRate    float64 `json:"target_rate"`              // The rate (tx/sec) at which we aimed to send transactions to this endpoint.
+	TotalTxs      int     `json:"total_txs"`                // The total number of transactions sent to this endpoint.
+	AvgTxRate     float64 `json:"avg_tx_rate"`              // The rate (tx/sec) at which transactions were actually sent to this endpoint.
+	Weight        float64 `json:"weight,omitempty"`         // The endpoint's relative weight, if endpoint weights are configured.
+	Share         float64 `json:"share,omitempty"`          // The fraction of all transactions that were actually sent to this endpoint, if endpoint weights are configured.
+	JoinedSeconds float64 `json:"joined_seconds,omitempty"` // How far into the load test (in seconds) the endpoint was added, if it was found by re-discovery.
+}
+
+// ParseEndpointRateLimits strips any per-endpoint options (e.g.
+// "|maxrate=200", "|weight=3" or "|name=validator-1") from the configured
+// endpoints, moving the rate limits into EndpointRateLimits, the weights into
+// EndpointWeights and the aliases into EndpointNames. It is safe to call more
+// than once.
+func (c *Config) ParseEndpointRateLimits() error {
+	endpoints, limits, weights, err := c.parseEndpoints()
+	if err != nil {
+		return err
+	}
+	names, err := c.parseEndpointNames()
+	if err != nil {
+		return err
+	}
+	if len(names) > 0 {
+		c.EndpointNames = names
+	}
+	c.Endpoints = endpoints
+	if len(limits) > 0 {
+		c.EndpointRateLimits = limits
+	}
+	if len(weights) > 0 {
+		c.EndpointWeights = weights
+	}
+	return nil
+}
+
+// parseEndpoints returns the configured endpoints' addresses (without
+// options), and the rate limits and weights from both EndpointRateLimits and
+// EndpointWeights and the endpoints' options.
+func (c Config) parseEndpoints() ([]string, map[string]float64, map[string]float64, error) {
+	endpoints := make([]string, 0, len(c.Endpoints))
+	limits := make(map[string]float64)
+	for addr, maxRate := range c.EndpointRateLimits {
+		limits[addr] = maxRate
+	}
+	weights := make(map[string]float64)
+	for addr, weight := range c.EndpointWeights {
+		weights[addr] = weight
+	}
+	for _, endpoint := range c.Endpoints {
+		addr, opts, err := parseEndpoint(endpoint)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		endpoints = append(endpoints, addr)
+		if opts.maxRate > 0 {
+			limits[addr] = opts.maxRate
+		}
+		if opts.weight > 0 {
+			weights[addr] = opts.weight
+		}
+	}
+	for addr, maxRate := range limits {
+		if !(maxRate > 0) {
+			return nil, nil, nil, fmt.Errorf("expected rate limit for endpoint %s to be > 0, but was %v", addr, maxRate)
+		}
+	}
+	for addr, weight := range weights {
+		if !(weight > 0) || math.IsInf(weight, 0) {
+			return nil, nil, nil, fmt.Errorf("expected weight for endpoint %s to be > 0, but was %v", addr, weight)
+		}
+	}
+	return endpoints, limits, weights, nil
+}
+
+// endpointOptions are the options given after an endpoint's address.
+type endpointOptions struct {
+	maxRate float64 // The endpoint's rate limit, or 0 if not specified.
+	weight  float64 // The endpoint's weight, or 0 if not specified.
+	name    string  // The endpoint's alias, or empty if not specified.
+}
+
+// parseEndpoint splits an endpoint of the form
+// "ws://host:26657/websocket|maxrate=200|weight=3|name=validator-1" into its
+// address and options. The default WebSockets path is appended to addresses
+// without a path.
+func parseEndpoint(endpoint string) (string, endpointOptions, error) {
+	parts := strings.Split(endpoint, endpointOptionsSeparator)
+	addr := strings.TrimSpace(parts[0])
+	var opts endpointOptions
+	for _, opt := range parts[1:] {
+		kv := strings.SplitN(opt, "=", 2)
+		if len(kv) != 2 {
+			return "", opts, fmt.Errorf("invalid option \"%s\" for endpoint %s: expected key=value", opt, addr)
+		}
+		switch strings.TrimSpace(kv[0]) {
+		case endpointMaxRateOption:
+			v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil || !(v > 0) {
+				return "", opts, fmt.Errorf("invalid maxrate \"%s\" for endpoint %s: expected a number > 0", kv[1], addr)
+			}
+			opts.maxRate = v
+
+		case endpointWeightOption:
+			v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil || !(v > 0) || math.IsInf(v, 0) {
+				return "", opts, fmt.Errorf("invalid weight \"%s\" for endpoint %s: expected a number > 0", kv[1], addr)
+			}
+			opts.weight = v
+
+		case endpointNameOption:
+			name := strings.TrimSpace(kv[1])
+			if err := validateEndpointName(name); err != nil {
+				return "", opts, fmt.Errorf("invalid name for endpoint %s: %w", addr, err)
+			}
+			opts.name = name
+
+		default:
+			return "", opts, fmt.Errorf("unrecognized option \"%s\" for endpoint %s", kv[0], addr)
+		}
+	}
+	return normalizeEndpointPath(addr), opts, nil
+}
+
+// endpointRates allocates the overall transaction rate (tx/sec) requested by
+// this configuration across the given endpoints in proportion to their
+// weights, respecting any endpoint rate limits. Whatever capped endpoints
+// cannot take is redistributed amongst the other endpoints, again in
+// proportion to their weights. Also returns the total allocated rate, which is
+// below the requested rate only if all endpoints are capped.
+func (c Config) endpointRates(endpoints []string, limits, weights map[string]float64) (map[string]float64, float64) {
+	rates := make(map[string]float64, len(endpoints))
+	budget := c.expectedTxRate(c.Connections * len(endpoints))
+	// allocate to the most constrained endpoints (for their weight) first
+	sorted := make([]string, len(endpoints))
+	copy(sorted, endpoints)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return endpointRateLimit(limits, sorted[i])/endpointWeight(weights, sorted[i]) <
+			endpointRateLimit(limits, sorted[j])/endpointWeight(weights, sorted[j])
+	})
+	totalWeight := float64(0)
+	for _, endpoint := range sorted {
+		totalWeight += endpointWeight(weights, endpoint)
+	}
+	total := float64(0)
+	for _, endpoint := range sorted {
+		weight := endpointWeight(weights, endpoint)
+		share := budget * weight / totalWeight
+		totalWeight -= weight
+		if maxRate := endpointRateLimit(limits, endpoint); maxRate < share {
+			share = maxRate
+		}
+		rates[endpoint] = share
+		budget -= share
+		total += share
+	}
+	return rates, total
+}
+
+// endpointRateLimit returns the rate limit for the given endpoint, or +Inf if
+// it is not limited.
+func endpointRateLimit(limits map[string]float64, endpoint string) float64 {
+	if maxRate, ok := limits[endpoint]; ok {
+		return maxRate
+	}
+	return math.Inf(1)
+}
@@ -0,0 +1,125 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	testCases := []struct {
+		endpoint        string
+		expectedAddr    string
+		expectedMaxRate float64
+		expectedWeight  float64
+		expectedName    string
+		expectError     bool
+	}{
+		{"ws://host:26657/websocket", "ws://host:26657/websocket", 0, 0, "", false},
+		{"ws://host:26657/websocket|maxrate=200", "ws://host:26657/websocket", 200, 0, "", false},
+		{"ws://host:26657/websocket|maxrate=0.5", "ws://host:26657/websocket", 0.5, 0, "", false},
+		{"ws://host:26657/websocket|weight=3", "ws://host:26657/websocket", 0, 3, "", false},
+		{"ws://host:26657/websocket|maxrate=200|weight=0.5", "ws://host:26657/websocket", 200, 0.5, "", false},
+		{"ws://host:26657/websocket|maxrate=0", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|maxrate=abc", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|maxrate", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|weight=0", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|weight=-1", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|weight=Inf", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|minrate=10", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|name=validator-eu-1", "ws://host:26657/websocket", 0, 0, "validator-eu-1", false},
+		{"ws://host:26657/websocket|weight=2|name=v1.eu_west", "ws://host:26657/websocket", 0, 2, "v1.eu_west", false},
+		{"ws://host:26657/websocket|name=", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|name=-validator", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|name=validator[1]", "", 0, 0, "", true},
+		{"ws://host:26657/websocket|name=eu validator", "", 0, 0, "", true},
+	}
+	for _, tc := range testCases {
+		addr, opts, err := parseEndpoint(tc.endpoint)
+		if tc.expectError {
+			assert.Error(t, err, tc.endpoint)
+			continue
+		}
+		require.NoError(t, err, tc.endpoint)
+		assert.Equal(t, tc.expectedAddr, addr)
+		assert.Equal(t, tc.expectedMaxRate, opts.maxRate)
+		assert.Equal(t, tc.expectedWeight, opts.weight)
+		assert.Equal(t, tc.expectedName, opts.name)
+	}
+}
+
+func TestEndpointRates(t *testing.T) {
+	cfg := Config{Connections: 2, SendPeriod: Duration(time.Second), Rate: 50} // 100 tx/sec per endpoint
+	endpoints := []string{"a", "b", "c"}
+	testCases := []struct {
+		limits        map[string]float64
+		weights       map[string]float64
+		expectedRates map[string]float64
+		expectedTotal float64
+	}{
+		{
+			map[string]float64{},
+			nil,
+			map[string]float64{"a": 100, "b": 100, "c": 100},
+			300,
+		},
+		// a's shortfall is redistributed evenly to b and c
+		{
+			map[string]float64{"a": 20},
+			nil,
+			map[string]float64{"a": 20, "b": 140, "c": 140},
+			300,
+		},
+		// b can take some, but not all, of a's shortfall
+		{
+			map[string]float64{"a": 20, "b": 120},
+			nil,
+			map[string]float64{"a": 20, "b": 120, "c": 160},
+			300,
+		},
+		// limits above the fair share have no effect
+		{
+			map[string]float64{"a": 500},
+			nil,
+			map[string]float64{"a": 100, "b": 100, "c": 100},
+			300,
+		},
+		// all endpoints capped below the requested rate
+		{
+			map[string]float64{"a": 10, "b": 20, "c": 30},
+			nil,
+			map[string]float64{"a": 10, "b": 20, "c": 30},
+			60,
+		},
+		// the rate is divided in proportion to the weights
+		{
+			map[string]float64{},
+			map[string]float64{"a": 3},
+			map[string]float64{"a": 180, "b": 60, "c": 60},
+			300,
+		},
+		// a's shortfall is redistributed in proportion to b's and c's weights
+		{
+			map[string]float64{"a": 20},
+			map[string]float64{"b": 3},
+			map[string]float64{"a": 20, "b": 210, "c": 70},
+			300,
+		},
+		// a heavily weighted endpoint is the most constrained for its weight
+		{
+			map[string]float64{"a": 150},
+			map[string]float64{"a": 4},
+			map[string]float64{"a": 150, "b": 75, "c": 75},
+			300,
+		},
+	}
+	for _, tc := range testCases {
+		rates, total := cfg.endpointRates(endpoints, tc.limits, tc.weights)
+		for endpoint, expected := range tc.expectedRates {
+			assert.InDelta(t, expected, rates[endpoint], 1e-9, "endpoint %s with limits %v", endpoint, tc.limits)
+		}
+		assert.InDelta(t, tc.expectedTotal, total, 1e-9)
+	}
+}
@@ -0,0 +1,253 @@
+package loadtest
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// The port on which Tendermint nodes serve their RPC API by default, which we
+// assume for peers that don't advertise their RPC address.
+const defaultRPCPort = "26657"
+
+// endpointRediscoverer periodically crawls the network, via the net_info RPC
+// API of the endpoints in a load test, and resolves any DNS (or otherwise
+// resolved) endpoints again, for endpoints that have joined since the load
+// test started, adding those that are healthy to the load test.
+type endpointRediscoverer struct {
+	group     *TransactorGroup
+	cfg       *Config
+	interval  time.Duration
+	resolvers []EndpointResolver
+	logger    logging.Logger
+
+	filter *discoveryFilter // Nil if discovered peers aren't filtered.
+	seeds  map[string]bool  // The keys of the endpoints originally supplied for discovery.
+	added  int              // The number of endpoints added thus far (only accessed from the run loop).
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func newEndpointRediscoverer(g *TransactorGroup, cfg *Config, logger logging.Logger) *endpointRediscoverer {
+	r := &endpointRediscoverer{
+		group:     g,
+		cfg:       cfg,
+		interval:  time.Duration(cfg.RediscoveryInterval),
+		resolvers: registeredEndpointResolvers(),
+		logger:    logger,
+		seeds:     make(map[string]bool),
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	for _, seed := range cfg.DiscoverySeeds {
+		r.seeds[endpointKey(seed)] = true
+	}
+	// the configuration has been validated by now
+	r.filter, _ = newDiscoveryFilter(cfg)
+	return r
+}
+
+func (r *endpointRediscoverer) run() {
+	defer close(r.stopped)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.rediscover()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Stop terminates the re-discovery loop and blocks until it has stopped, after
+// which no more endpoints are added.
+func (r *endpointRediscoverer) Stop() {
+	close(r.stop)
+	<-r.stopped
+}
+
+// rediscover crawls the network once, adding any new endpoints that are
+// healthy (up to the configured maximum).
+func (r *endpointRediscoverer) rediscover() {
+	if r.cfg.MaxRediscoveredEndpoints > 0 && r.added >= r.cfg.MaxRediscoveredEndpoints {
+		return
+	}
+	endpoints := make([]string, 0)
+	known := make(map[string]bool)
+	for endpoint := range r.group.transactorsByEndpoint() {
+		endpoints = append(endpoints, endpoint)
+		known[endpointKey(endpoint)] = true
+	}
+	sort.Strings(endpoints)
+	candidates := make([]string, 0)
+	if r.cfg.EndpointSelectMethod != SelectSuppliedEndpoints {
+		peerEndpoints, nodeInfos := discoverPeerEndpoints(endpoints, r.cfg.healthCheckTimeout(), r.logger)
+		discovered := make([]string, 0)
+		for _, endpoint := range peerEndpoints {
+			key := endpointKey(endpoint)
+			if known[key] || (r.cfg.EndpointSelectMethod == SelectDiscoveredEndpoints && r.seeds[key]) {
+				continue
+			}
+			known[key] = true
+			discovered = append(discovered, endpoint)
+		}
+		candidates = append(candidates, r.filterPeers(discovered, nodeInfos)...)
+	}
+	for _, resolved := range r.cfg.ResolvedEndpoints {
+		addrs, err := resolveEndpoint(findEndpointResolver(r.resolvers, resolved), resolved)
+		if err != nil {
+			r.logger.Info("Failed to resolve endpoint again - skipping", "endpoint", resolved, "err", err)
+			continue
+		}
+		for _, endpoint := range addrs {
+			if key := endpointKey(endpoint); !known[key] {
+				known[key] = true
+				candidates = append(candidates, endpoint)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		r.logger.Debug("No new endpoints rediscovered", "endpoints", len(endpoints))
+		return
+	}
+	r.logger.Info("Rediscovered new endpoints", "endpoints", candidates)
+
+	// endpoints that aren't healthy yet get another chance next time
+	errs := make([]error, len(candidates))
+	var wg sync.WaitGroup
+	for i, endpoint := range candidates {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			errs[i] = checkEndpointHealth(endpoint, r.cfg.RPCVersion, r.cfg.healthCheckTimeout())
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	for i, endpoint := range candidates {
+		if r.cfg.MaxRediscoveredEndpoints > 0 && r.added >= r.cfg.MaxRediscoveredEndpoints {
+			r.logger.Info("Reached the maximum number of rediscovered endpoints", "max", r.cfg.MaxRediscoveredEndpoints)
+			return
+		}
+		if errs[i] != nil {
+			r.logger.Info("Not adding unhealthy rediscovered endpoint", "endpoint", endpoint, "err", errs[i])
+			continue
+		}
+		addrs, err := resolveRPCEndpoints([]string{endpoint}, r.cfg.RPCVersion, r.logger)
+		if err == nil {
+			err = r.group.addEndpoint(addrs[0])
+		}
+		if err != nil {
+			r.logger.Error("Failed to add rediscovered endpoint", "endpoint", endpoint, "err", err)
+			continue
+		}
+		r.added++
+	}
+}
+
+// filterPeers returns those of the given discovered endpoints that pass the
+// discovery filter (if any).
+func (r *endpointRediscoverer) filterPeers(endpoints []string, nodeInfos map[string]*DefaultNodeInfo) []string {
+	if r.filter == nil || len(endpoints) == 0 {
+		return endpoints
+	}
+	peers := make([]discoveredPeer, len(endpoints))
+	for i, endpoint := range endpoints {
+		httpAddr, _ := rpcHTTPAddr(endpoint)
+		peers[i] = discoveredPeer{httpAddr: httpAddr, info: nodeInfos[endpoint]}
+	}
+	result := make([]string, 0, len(endpoints))
+	for i, allowed := range r.filter.allowed(peers, r.logger) {
+		if allowed {
+			result = append(result, endpoints[i])
+		}
+	}
+	return result
+}
+
+// discoverPeerEndpoints queries the net_info RPC API of each of the given
+// WebSockets endpoints' nodes concurrently, returning the WebSockets addresses
+// of the RPC endpoints of all of their peers, along with the peers' node
+// information keyed by those addresses. Endpoints that can't be queried within
+// the given timeout are skipped.
+func discoverPeerEndpoints(endpoints []string, timeout time.Duration, logger logging.Logger) ([]string, map[string]*DefaultNodeInfo) {
+	peers := make([][]Peer, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			httpAddr, err := rpcHTTPAddr(endpoint)
+			if err != nil {
+				logger.Debug("Failed to query endpoint for peers - skipping", "endpoint", endpoint, "err", err)
+				return
+			}
+			client := newHttpRpcClient(httpAddr)
+			defer client.close()
+			client.client.Timeout = timeout
+			netInfo, err := client.netInfo()
+			if err != nil {
+				logger.Debug("Failed to query endpoint for peers - skipping", "endpoint", endpoint, "err", err)
+				return
+			}
+			peers[i] = netInfo.Peers
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	result := make([]string, 0)
+	nodeInfos := make(map[string]*DefaultNodeInfo)
+	for _, endpointPeers := range peers {
+		for i, peer := range endpointPeers {
+			endpoint := peerRPCEndpoint(peer)
+			if _, seen := nodeInfos[endpoint]; !seen {
+				nodeInfos[endpoint] = &endpointPeers[i].NodeInfo
+				result = append(result, endpoint)
+			}
+		}
+	}
+	return result, nodeInfos
+}
+
+// peerRPCEndpoint returns the WebSockets address of the given peer's RPC
+// endpoint, at its remote IP address and on the port of its advertised RPC
+// address (or on the default RPC port, if it doesn't advertise one).
+func peerRPCEndpoint(peer Peer) string {
+	port := defaultRPCPort
+	if u, err := url.Parse(peer.NodeInfo.Other.RPCAddress); err == nil && len(u.Port()) > 0 {
+		port = u.Port()
+	}
+	return fmt.Sprintf("ws://%s%s", net.JoinHostPort(peer.RemoteIP, port), defaultWebSocketPath)
+}
+
+// endpointKey identifies the node behind the given WebSockets endpoint by its
+// IP address (if its host name can be resolved) and port, so that endpoints
+// given by host name can be matched with the peers' IP addresses.
+func endpointKey(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	host := u.Hostname()
+	if ip, err := lookupPeerIP(host); err == nil {
+		host = ip
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		port = "80"
+		if u.Scheme == "wss" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,81 @@
+package loadtest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointRediscoveryAddsHealthyEndpoints(t *testing.T) {
+	initial := newMockRPCServer(t, 0)
+	joining := newMockRPCServer(t, 0)
+	recovering := newMockRPCServer(t, 0)
+	recovering.SetDown(true)
+	cfg := mockTestConfig(initial.URL())
+	cfg.EndpointSelectMethod = loadtest.SelectAnyEndpoints
+	cfg.Time = seconds(4)
+	cfg.SendPeriod = loadtest.Duration(250 * time.Millisecond)
+	cfg.Count = -1
+	cfg.RediscoveryInterval = loadtest.Duration(250 * time.Millisecond)
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+
+	time.Sleep(time.Second)
+	initial.SetPeers(joining, recovering)
+	require.Eventually(t, func() bool {
+		return joining.Connections() > 0
+	}, 2*time.Second, 50*time.Millisecond)
+	// unhealthy endpoints must not be added until they're healthy
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, 0, recovering.Connections())
+	recovering.SetDown(false)
+	require.NoError(t, tg.Wait())
+
+	assert.Equal(t, 1, joining.Connections())
+	assert.Equal(t, 1, recovering.Connections())
+	assert.Greater(t, joining.Requests(), 0)
+	assert.Greater(t, recovering.Requests(), 0)
+
+	stats := tg.Report().Aggregate
+	require.Len(t, stats.Endpoints, 3)
+	byEndpoint := make(map[string]loadtest.EndpointStats)
+	for _, ep := range stats.Endpoints {
+		byEndpoint[ep.Endpoint] = ep
+	}
+	assert.Equal(t, 0.0, byEndpoint[initial.URL()].JoinedSeconds)
+	assert.InDelta(t, 1.25, byEndpoint[joining.URL()].JoinedSeconds, 0.5)
+	assert.Greater(t, byEndpoint[recovering.URL()].JoinedSeconds, byEndpoint[joining.URL()].JoinedSeconds)
+	assert.Equal(t, joining.Requests(), byEndpoint[joining.URL()].TotalTxs)
+	assert.Equal(t, initial.Requests()+joining.Requests()+recovering.Requests(), stats.TotalTxs)
+}
+
+func TestEndpointRediscoveryExcludesSeedsAndCaps(t *testing.T) {
+	seed := newMockRPCServer(t, 0)
+	initial := newMockRPCServer(t, 0)
+	first := newMockRPCServer(t, 0)
+	second := newMockRPCServer(t, 0)
+	initial.SetPeers(seed, first, second)
+	cfg := mockTestConfig(initial.URL())
+	cfg.EndpointSelectMethod = loadtest.SelectDiscoveredEndpoints
+	cfg.DiscoverySeeds = []string{seed.URL()}
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.RediscoveryInterval = loadtest.Duration(250 * time.Millisecond)
+	cfg.MaxRediscoveredEndpoints = 1
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	require.NoError(t, tg.Wait())
+
+	assert.Equal(t, 0, seed.Connections())
+	assert.Equal(t, 1, first.Connections()+second.Connections())
+	assert.Len(t, tg.EndpointStats(), 2)
+}
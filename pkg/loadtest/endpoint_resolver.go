@@ -0,0 +1,181 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long to wait for an endpoint to be resolved (e.g. for each DNS lookup).
+const endpointResolveTimeout = 10 * time.Second
+
+// EndpointResolver expands endpoints of the kinds it handles (e.g. DNS
+// endpoints such as "dns+ws://validators.ns.svc:26657/websocket") into the
+// individual WebSockets endpoints behind them. Endpoints are resolved before
+// the load test starts, and again on every re-discovery, so that endpoints
+// that join later are added to the load test.
+//
+// Further resolvers can be registered with RegisterEndpointResolver.
+type EndpointResolver interface {
+	// Handles returns whether the given endpoint (without options) is to be
+	// expanded by this resolver.
+	Handles(endpoint string) bool
+	// Resolve returns the WebSockets endpoints currently behind the given
+	// endpoint, in a stable order. An error is returned if there are none.
+	Resolve(ctx context.Context, endpoint string) ([]string, error)
+}
+
+// staticEndpointResolver resolves ordinary endpoints (e.g.
+// "ws://10.0.0.1:26657/websocket") to themselves. It handles any endpoint that
+// no registered resolver handles.
+type staticEndpointResolver struct{}
+
+var _ EndpointResolver = staticEndpointResolver{}
+
+func (staticEndpointResolver) Handles(string) bool {
+	return true
+}
+
+func (staticEndpointResolver) Resolve(_ context.Context, endpoint string) ([]string, error) {
+	return []string{endpoint}, nil
+}
+
+var (
+	endpointResolversMtx sync.RWMutex
+	endpointResolvers    = []EndpointResolver{
+		&dnsEndpointResolver{lookup: dnsResolver},
+	}
+)
+
+// RegisterEndpointResolver adds the given resolver to those with which
+// endpoints are expanded, after the built-in DNS resolver. An endpoint is
+// expanded by the first registered resolver that handles it. The Kubernetes
+// resolver registers itself when the k8s subpackage is imported.
+func RegisterEndpointResolver(resolver EndpointResolver) {
+	endpointResolversMtx.Lock()
+	defer endpointResolversMtx.Unlock()
+	endpointResolvers = append(endpointResolvers, resolver)
+}
+
+// registeredEndpointResolvers returns the currently registered resolvers.
+func registeredEndpointResolvers() []EndpointResolver {
+	endpointResolversMtx.RLock()
+	defer endpointResolversMtx.RUnlock()
+	return append([]EndpointResolver(nil), endpointResolvers...)
+}
+
+// findEndpointResolver returns the first of the given resolvers that handles
+// the given endpoint, or the static resolver if none does.
+func findEndpointResolver(resolvers []EndpointResolver, endpoint string) EndpointResolver {
+	endpoint = strings.TrimSpace(endpoint)
+	for _, resolver := range resolvers {
+		if resolver.Handles(endpoint) {
+			return resolver
+		}
+	}
+	return staticEndpointResolver{}
+}
+
+// isStaticEndpoint returns whether the given endpoint is used as it is, rather
+// than being expanded by one of the given resolvers.
+func isStaticEndpoint(resolvers []EndpointResolver, endpoint string) bool {
+	_, static := findEndpointResolver(resolvers, endpoint).(staticEndpointResolver)
+	return static
+}
+
+// hasResolvedEndpoints returns whether any of the configured endpoints are (or
+// were) expanded by a resolver, e.g. DNS endpoints.
+func (c Config) hasResolvedEndpoints() bool {
+	if len(c.ResolvedEndpoints) > 0 {
+		return true
+	}
+	resolvers := registeredEndpointResolvers()
+	for _, endpoint := range c.Endpoints {
+		if !isStaticEndpoint(resolvers, endpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveEndpoints replaces any of the configured endpoints handled by a
+// registered EndpointResolver (e.g. DNS endpoints) with the individual
+// WebSockets endpoints they resolve to, each of which inherits the resolved
+// endpoint's rate limit and weight, and its alias suffixed with the endpoint's
+// index (e.g. "validators-0"). The resolved endpoints are kept in
+// ResolvedEndpoints, so that they can be resolved again by re-discovery.
+// Endpoint options must already have been parsed (see
+// ParseEndpointRateLimits). It is safe to call more than once.
+func (c *Config) ResolveEndpoints() error {
+	return c.resolveEndpoints(registeredEndpointResolvers())
+}
+
+// ExpandDNSEndpoints resolves the configured endpoints.
+//
+// Deprecated: use ResolveEndpoints, which also expands the endpoints of any
+// other registered EndpointResolver.
+func (c *Config) ExpandDNSEndpoints() error {
+	return c.ResolveEndpoints()
+}
+
+func (c *Config) resolveEndpoints(resolvers []EndpointResolver) error {
+	// already resolved
+	if len(c.ResolvedEndpoints) > 0 {
+		return nil
+	}
+	endpoints := make([]string, 0, len(c.Endpoints))
+	seen := make(map[string]bool)
+	for _, endpoint := range c.Endpoints {
+		resolver := findEndpointResolver(resolvers, endpoint)
+		if _, static := resolver.(staticEndpointResolver); static {
+			if !seen[endpoint] {
+				seen[endpoint] = true
+				endpoints = append(endpoints, endpoint)
+			}
+			continue
+		}
+		addrs, err := resolveEndpoint(resolver, endpoint)
+		if err != nil {
+			return err
+		}
+		for i, addr := range addrs {
+			if seen[addr] {
+				continue
+			}
+			seen[addr] = true
+			endpoints = append(endpoints, addr)
+			if maxRate, ok := c.EndpointRateLimits[endpoint]; ok {
+				c.EndpointRateLimits[addr] = maxRate
+			}
+			if weight, ok := c.EndpointWeights[endpoint]; ok {
+				c.EndpointWeights[addr] = weight
+			}
+			if name, ok := c.EndpointNames[endpoint]; ok {
+				c.EndpointNames[addr] = fmt.Sprintf("%s-%d", name, i)
+			}
+		}
+		delete(c.EndpointRateLimits, endpoint)
+		delete(c.EndpointWeights, endpoint)
+		delete(c.EndpointNames, endpoint)
+		c.ResolvedEndpoints = append(c.ResolvedEndpoints, endpoint)
+	}
+	c.Endpoints = endpoints
+	return nil
+}
+
+// resolveEndpoint resolves the given endpoint with the given resolver, within
+// the endpoint resolution timeout.
+func resolveEndpoint(resolver EndpointResolver, endpoint string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), endpointResolveTimeout)
+	defer cancel()
+	addrs, err := resolver.Resolve(ctx, strings.TrimSpace(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no endpoints found behind %s", endpoint)
+	}
+	return addrs, nil
+}
@@ -0,0 +1,75 @@
+package loadtest_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// poolResolver resolves "pool://" endpoints to the URLs of the mock servers
+// in its pool, which may change over time.
+type poolResolver struct {
+	mtx  sync.Mutex
+	pool []*mockRPCServer
+}
+
+func (r *poolResolver) Handles(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "pool://")
+}
+
+func (r *poolResolver) Resolve(_ context.Context, _ string) ([]string, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	endpoints := make([]string, 0, len(r.pool))
+	for _, svr := range r.pool {
+		endpoints = append(endpoints, svr.URL())
+	}
+	return endpoints, nil
+}
+
+func (r *poolResolver) set(pool ...*mockRPCServer) {
+	r.mtx.Lock()
+	r.pool = pool
+	r.mtx.Unlock()
+}
+
+var (
+	testPoolResolver     = &poolResolver{}
+	registerPoolResolver sync.Once
+)
+
+func TestCustomEndpointResolver(t *testing.T) {
+	registerPoolResolver.Do(func() { loadtest.RegisterEndpointResolver(testPoolResolver) })
+	initial := newMockRPCServer(t, 0)
+	joining := newMockRPCServer(t, 0)
+	testPoolResolver.set(initial)
+	cfg := mockTestConfig("pool://validators|weight=2|name=validator")
+	cfg.Time = seconds(3)
+	cfg.SendPeriod = loadtest.Duration(250 * time.Millisecond)
+	cfg.Count = -1
+	// resolved endpoints are resolved again, even if they're supplied
+	cfg.RediscoveryInterval = loadtest.Duration(250 * time.Millisecond)
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	assert.Equal(t, []string{initial.URL()}, cfg.Endpoints)
+	assert.Equal(t, map[string]string{initial.URL(): "validator-0"}, cfg.EndpointNames)
+	tg.Start()
+
+	time.Sleep(500 * time.Millisecond)
+	testPoolResolver.set(initial, joining)
+	require.Eventually(t, func() bool {
+		return joining.Connections() > 0
+	}, 2*time.Second, 50*time.Millisecond)
+	require.NoError(t, tg.Wait())
+
+	assert.Greater(t, initial.Requests(), 0)
+	assert.Greater(t, joining.Requests(), 0)
+}
@@ -0,0 +1,117 @@
+package loadtest
+
+import (
+	"sort"
+	"strings"
+)
+
+// shardEndpoints partitions the given endpoints amongst the workers with the
+// given IDs, such that each worker gets much the same total endpoint weight:
+// each endpoint, heaviest first, goes to the worker with the least weight thus
+// far. Without weights, that's round-robin. If there are more workers than
+// endpoints, each endpoint is shared by several workers instead.
+func shardEndpoints(endpoints, ids []string, weights map[string]float64) map[string][]string {
+	shards := make(map[string][]string, len(ids))
+	if len(endpoints) == 0 || len(ids) == 0 {
+		return shards
+	}
+	if len(ids) > len(endpoints) {
+		for i, id := range ids {
+			shards[id] = []string{endpoints[i%len(endpoints)]}
+		}
+		return shards
+	}
+	sorted := make([]string, len(endpoints))
+	copy(sorted, endpoints)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return endpointWeight(weights, sorted[i]) > endpointWeight(weights, sorted[j])
+	})
+	assigned := make(map[string]string, len(endpoints))
+	loads := make([]float64, len(ids))
+	for _, endpoint := range sorted {
+		lightest := 0
+		for i := range loads {
+			if loads[i] < loads[lightest] {
+				lightest = i
+			}
+		}
+		assigned[endpoint] = ids[lightest]
+		loads[lightest] += endpointWeight(weights, endpoint)
+	}
+	// keep the endpoints in their configured order
+	for _, endpoint := range endpoints {
+		shards[assigned[endpoint]] = append(shards[assigned[endpoint]], endpoint)
+	}
+	return shards
+}
+
+// assignEndpointShards partitions the endpoints amongst the workers taking part
+// in the load test (in order of worker ID), if configured to. Workers with
+// endpoint overrides keep their own endpoints. Must only be called from the
+// coordinator's event loop.
+func (c *Coordinator) assignEndpointShards() {
+	if !c.coordCfg.ShardEndpoints {
+		return
+	}
+	ids := make([]string, 0, len(c.workers))
+	for id := range c.workers {
+		if len(c.workerOverride(id).Endpoints) == 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	shards := shardEndpoints(c.cfg.Endpoints, ids, c.cfg.EndpointWeights)
+	c.mtx.Lock()
+	c.endpointShards = shards
+	c.mtx.Unlock()
+	for _, id := range ids {
+		c.logger.Info("Assigned endpoints to worker", c.workerFields(id, "endpoints", strings.Join(shards[id], ","))...)
+	}
+}
+
+// assignLateWorkerShard assigns endpoints to a worker joining the load test
+// once it's underway, if the endpoints are sharded: those that no other
+// worker covers (e.g. because the worker they were assigned to failed, if
+// they're to be redistributed), or otherwise the endpoint covered by the
+// fewest workers. Must only be called from the coordinator's event loop.
+func (c *Coordinator) assignLateWorkerShard(id string) {
+	if !c.coordCfg.ShardEndpoints || len(c.workerOverride(id).Endpoints) > 0 || len(c.cfg.Endpoints) == 0 {
+		return
+	}
+	coverage := make(map[string]int)
+	c.mtx.Lock()
+	for workerID, shard := range c.endpointShards {
+		if c.coordCfg.RedistributeShards && c.failedWorkers[workerID] {
+			continue
+		}
+		for _, endpoint := range shard {
+			coverage[endpoint]++
+		}
+	}
+	shard := make([]string, 0)
+	for _, endpoint := range c.cfg.Endpoints {
+		if coverage[endpoint] == 0 {
+			shard = append(shard, endpoint)
+		}
+	}
+	if len(shard) == 0 {
+		least := c.cfg.Endpoints[0]
+		for _, endpoint := range c.cfg.Endpoints[1:] {
+			if coverage[endpoint] < coverage[least] {
+				least = endpoint
+			}
+		}
+		shard = append(shard, least)
+	}
+	c.endpointShards[id] = shard
+	c.mtx.Unlock()
+	c.logger.Info("Assigned endpoints to worker", c.workerFields(id, "endpoints", strings.Join(shard, ","))...)
+}
+
+// endpointShard returns the endpoints assigned to the given worker, if the
+// endpoints are sharded.
+func (c *Coordinator) endpointShard(id string) []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.endpointShards[id]
+}
@@ -0,0 +1,83 @@
+package loadtest
+
+import (
+	"math"
+	"sort"
+)
+
+// endpointWeight returns the weight of the given endpoint, which is 1 if it
+// has no configured weight.
+func endpointWeight(weights map[string]float64, endpoint string) float64 {
+	if weight, ok := weights[endpoint]; ok && weight > 0 {
+		return weight
+	}
+	return 1
+}
+
+// endpointConnections returns the number of connections to open to each of
+// the given endpoints. Without endpoint weights, that's the configured number
+// of connections for every endpoint. Otherwise the same total number of
+// connections is divided amongst the endpoints in proportion to their
+// weights (by largest remainder), although every endpoint gets at least one
+// connection.
+func (c Config) endpointConnections(endpoints []string) []int {
+	conns := make([]int, len(endpoints))
+	if len(c.EndpointWeights) == 0 {
+		for i := range conns {
+			conns[i] = c.Connections
+		}
+		return conns
+	}
+	total := c.Connections * len(endpoints)
+	totalWeight := float64(0)
+	for _, endpoint := range endpoints {
+		totalWeight += endpointWeight(c.EndpointWeights, endpoint)
+	}
+	ideal := make([]float64, len(endpoints))
+	remaining := total
+	for i, endpoint := range endpoints {
+		ideal[i] = float64(total) * endpointWeight(c.EndpointWeights, endpoint) / totalWeight
+		conns[i] = int(math.Max(1, math.Floor(ideal[i])))
+		remaining -= conns[i]
+	}
+	// hand out what's left to the endpoints furthest below their ideal
+	// number of connections (or take back from those furthest above it, if
+	// guaranteeing every endpoint a connection gave out too many)
+	order := make([]int, len(endpoints))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return ideal[order[a]]-float64(conns[order[a]]) > ideal[order[b]]-float64(conns[order[b]])
+	})
+	for i := 0; remaining > 0; i = (i + 1) % len(order) {
+		conns[order[i]]++
+		remaining--
+	}
+	for i := len(order) - 1; remaining < 0; i-- {
+		if i < 0 {
+			i = len(order) - 1
+		}
+		if conns[order[i]] > 1 {
+			conns[order[i]]--
+			remaining++
+		}
+	}
+	return conns
+}
+
+// computeEndpointShares computes the fraction of all transactions that was
+// sent to each of the given endpoints, if endpoint weights are configured, so
+// that it can be compared to the endpoints' weights.
+func computeEndpointShares(stats []EndpointStats) {
+	total := 0
+	for _, ep := range stats {
+		total += ep.TotalTxs
+	}
+	for i := range stats {
+		stats[i].Share = 0
+		if stats[i].Weight > 0 && total > 0 {
+			stats[i].Share = float64(stats[i].TotalTxs) / float64(total)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package loadtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointConnections(t *testing.T) {
+	testCases := []struct {
+		connections   int
+		endpoints     []string
+		weights       map[string]float64
+		expectedConns []int
+	}{
+		// without weights, every endpoint gets the configured connections
+		{4, []string{"a", "b"}, nil, []int{4, 4}},
+		// 3:1 weighting of 8 connections
+		{4, []string{"a", "b"}, map[string]float64{"a": 3}, []int{6, 2}},
+		{4, []string{"a", "b"}, map[string]float64{"a": 3, "b": 1}, []int{6, 2}},
+		// the largest remainder (the earliest endpoint, if tied) gets the
+		// odd connection
+		{2, []string{"a", "b", "c"}, map[string]float64{"a": 2, "b": 2}, []int{3, 2, 1}},
+		{3, []string{"a", "b"}, map[string]float64{"a": 0.4, "b": 0.6}, []int{2, 4}},
+		// every endpoint gets at least one connection
+		{1, []string{"a", "b", "c"}, map[string]float64{"a": 100}, []int{1, 1, 1}},
+		{2, []string{"a", "b", "c"}, map[string]float64{"a": 100}, []int{4, 1, 1}},
+	}
+	for _, tc := range testCases {
+		cfg := Config{Connections: tc.connections, EndpointWeights: tc.weights}
+		conns := cfg.endpointConnections(tc.endpoints)
+		total := 0
+		for _, n := range conns {
+			total += n
+		}
+		assert.Equal(t, tc.connections*len(tc.endpoints), total, "weights %v", tc.weights)
+		assert.Equal(t, tc.expectedConns, conns, "weights %v", tc.weights)
+	}
+}
+
+func TestShardEndpointsWeights(t *testing.T) {
+	endpoints := []string{"a", "b", "c", "d", "e"}
+	ids := []string{"w0", "w1"}
+
+	// without weights, endpoints are assigned round-robin
+	assert.Equal(t, map[string][]string{
+		"w0": {"a", "c", "e"},
+		"w1": {"b", "d"},
+	}, shardEndpoints(endpoints, ids, nil))
+
+	// the heavy endpoint gets a worker to itself, and the total weights are
+	// balanced
+	shards := shardEndpoints(endpoints, ids, map[string]float64{"c": 4})
+	assert.Equal(t, map[string][]string{
+		"w0": {"c"},
+		"w1": {"a", "b", "d", "e"},
+	}, shards)
+
+	weights := map[string]float64{"a": 2, "b": 2}
+	shards = shardEndpoints(endpoints, ids, weights)
+	loads := make(map[string]float64)
+	for id, shard := range shards {
+		for _, endpoint := range shard {
+			loads[id] += endpointWeight(weights, endpoint)
+		}
+	}
+	require.Len(t, loads, 2)
+	assert.InDelta(t, loads["w0"], loads["w1"], 1)
+}
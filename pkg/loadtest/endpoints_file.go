@@ -0,0 +1,86 @@
+package loadtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// The name of the endpoints file that stands for standard input.
+const endpointsFileStdin = "-"
+
+// LoadEndpointsFile reads the endpoints listed in the configuration's
+// EndpointsFile (if any), one per line, and merges them into its Endpoints,
+// after which EndpointsFile is cleared so that the endpoints are only read
+// once (e.g. by the coordinator, before it hands the configuration to its
+// workers). Blank lines and lines starting with "#" are ignored, and each line
+// may carry the same options as the endpoints given directly (e.g.
+// "ws://host:26657/websocket|weight=2"). An EndpointsFile of "-" reads the
+// endpoints from the given standard input.
+func (c *Config) LoadEndpointsFile(stdin io.Reader) error {
+	if len(c.EndpointsFile) == 0 {
+		return nil
+	}
+	name := c.EndpointsFile
+	r := stdin
+	if name == endpointsFileStdin {
+		name = "<stdin>"
+	} else {
+		f, err := os.Open(c.EndpointsFile)
+		if err != nil {
+			return fmt.Errorf("failed to read endpoints file: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	endpoints, err := parseEndpointsFile(name, r, c.Endpoints)
+	if err != nil {
+		return err
+	}
+	c.Endpoints = append(c.Endpoints, endpoints...)
+	c.EndpointsFile = ""
+	return nil
+}
+
+// parseEndpointsFile parses the endpoints listed in the named file, returning
+// those not already amongst the given endpoints. Errors name the file and the
+// offending line.
+func parseEndpointsFile(name string, r io.Reader, existing []string) ([]string, error) {
+	known := make(map[string]string, len(existing))
+	for _, endpoint := range existing {
+		if addr, _, err := parseEndpoint(endpoint); err == nil {
+			known[addr] = strings.TrimSpace(endpoint)
+		}
+	}
+	endpoints := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		endpoint := strings.TrimSpace(scanner.Text())
+		if len(endpoint) == 0 || strings.HasPrefix(endpoint, "#") {
+			continue
+		}
+		addr, _, err := parseEndpoint(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		u, err := url.Parse(addr)
+		if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || len(u.Host) == 0 {
+			return nil, fmt.Errorf("%s:%d: invalid endpoint %s: expected a ws:// or wss:// URL", name, line, addr)
+		}
+		if prev, ok := known[addr]; ok {
+			if prev == endpoint {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: endpoint %s is already listed with different options (%s)", name, line, addr, prev)
+		}
+		known[addr] = endpoint
+		endpoints = append(endpoints, endpoint)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read endpoints file %s: %w", name, err)
+	}
+	return endpoints, nil
+}
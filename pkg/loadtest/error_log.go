@@ -0,0 +1,150 @@
+package loadtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	// defaultErrorLogInterval is how often errors of the same category are
+	// logged, at most, unless configured otherwise.
+	defaultErrorLogInterval = 10 * time.Second
+	// errorLogSummaryInterval is how often the errors of all categories since
+	// the previous summary are summarized.
+	errorLogSummaryInterval = time.Minute
+)
+
+func (c Config) errorLogInterval() time.Duration {
+	if c.ErrorLogInterval > 0 {
+		return time.Duration(c.ErrorLogInterval)
+	}
+	if c.ErrorLogInterval < 0 {
+		return 0
+	}
+	return defaultErrorLogInterval
+}
+
+// errorLog rate limits the logging of errors that can recur at the rate at
+// which transactions are sent (e.g. while an endpoint is down), so that the
+// logs don't explode with identical lines. Errors are grouped into categories:
+// each category is logged at most once per interval, with how many times it
+// was repeated in between, and the errors of all categories are periodically
+// summarized. Every error is still logged at debug level. It's safe for
+// concurrent use.
+type errorLog struct {
+	logger          logging.Logger
+	interval        time.Duration // 0 means every error is logged.
+	summaryInterval time.Duration
+	now             func() time.Time
+
+	mtx         sync.Mutex
+	categories  map[string]*errorCategory
+	lastSummary time.Time
+}
+
+type errorCategory struct {
+	lastLogged time.Time
+	repeated   int // The number of errors since the category was last logged.
+	count      int // The number of errors since the last summary.
+}
+
+func newErrorLog(logger logging.Logger, interval, summaryInterval time.Duration) *errorLog {
+	return newErrorLogWithClock(logger, interval, summaryInterval, time.Now)
+}
+
+func newErrorLogWithClock(logger logging.Logger, interval, summaryInterval time.Duration, now func() time.Time) *errorLog {
+	return &errorLog{
+		logger:          logger,
+		interval:        interval,
+		summaryInterval: summaryInterval,
+		now:             now,
+		categories:      make(map[string]*errorCategory),
+		lastSummary:     now(),
+	}
+}
+
+// Error logs the given error message at error level, unless an error of the
+// same category was logged less than an interval ago, in which case it's
+// logged at debug level instead. Once the category is next logged at error
+// level, its message is suffixed with how many times it was repeated.
+func (l *errorLog) Error(category, msg string, kvpairs ...interface{}) {
+	now := l.now()
+	l.mtx.Lock()
+	c, ok := l.categories[category]
+	if !ok {
+		c = &errorCategory{}
+		l.categories[category] = c
+	}
+	c.count++
+	suppress := ok && l.interval > 0 && now.Sub(c.lastLogged) < l.interval
+	repeated := c.repeated
+	if suppress {
+		c.repeated++
+	} else {
+		c.lastLogged, c.repeated = now, 0
+	}
+	l.mtx.Unlock()
+
+	if suppress {
+		l.logger.Debug(msg, kvpairs...)
+		return
+	}
+	if repeated > 0 {
+		msg = fmt.Sprintf("%s (repeated %d times)", msg, repeated)
+	}
+	l.logger.Error(msg, kvpairs...)
+}
+
+// MaybeSummarize summarizes the errors since the previous summary if it was
+// at least a summary interval ago.
+func (l *errorLog) MaybeSummarize() {
+	l.mtx.Lock()
+	due := l.now().Sub(l.lastSummary) >= l.summaryInterval
+	l.mtx.Unlock()
+	if due {
+		l.Summarize()
+	}
+}
+
+// Summarize logs the number of errors of each category since the previous
+// summary, if there were any, in a single line.
+func (l *errorLog) Summarize() {
+	now := l.now()
+	l.mtx.Lock()
+	since := l.lastSummary
+	l.lastSummary = now
+	counts := make(map[string]int)
+	for name, c := range l.categories {
+		if c.count > 0 {
+			counts[name] = c.count
+			c.count = 0
+		}
+	}
+	l.mtx.Unlock()
+
+	if len(counts) == 0 {
+		return
+	}
+	names := make([]string, 0, len(counts))
+	total := 0
+	for name, count := range counts {
+		names = append(names, name)
+		total += count
+	}
+	sort.Strings(names)
+	summary := make([]string, len(names))
+	for i, name := range names {
+		summary[i] = fmt.Sprintf("%s=%d", name, counts[name])
+	}
+	l.logger.Warn(
+		"Errors since last summary",
+		"total", total,
+		"categories", strings.Join(summary, ","),
+		"period", now.Sub(since).Round(time.Second).String(),
+	)
+}
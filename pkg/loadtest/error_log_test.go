@@ -0,0 +1,103 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestErrorLogSuppression(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := newErrorLogWithClock(logger, 10*time.Second, time.Minute, clock.now)
+
+	for i := 0; i < 5000; i++ {
+		l.Error("send", "Failed to send transactions")
+	}
+	// the first is logged, and the rest are only available at debug level
+	assert.Equal(t, []string{"Failed to send transactions"}, logger.errors)
+	assert.Len(t, logger.debugs, 4999)
+
+	clock.advance(5 * time.Second)
+	l.Error("send", "Failed to send transactions")
+	assert.Len(t, logger.errors, 1)
+
+	clock.advance(5 * time.Second)
+	l.Error("send", "Failed to send transactions")
+	require.Len(t, logger.errors, 2)
+	assert.Equal(t, "Failed to send transactions (repeated 5000 times)", logger.errors[1])
+
+	// nothing was suppressed since
+	clock.advance(10 * time.Second)
+	l.Error("send", "Failed to send transactions")
+	require.Len(t, logger.errors, 3)
+	assert.Equal(t, "Failed to send transactions", logger.errors[2])
+}
+
+func TestErrorLogCategories(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := newErrorLogWithClock(logger, 10*time.Second, time.Minute, clock.now)
+
+	for i := 0; i < 10; i++ {
+		l.Error("rejected[-32603]", "Transaction rejected by endpoint")
+		l.Error("reconnect", "Failed to reconnect to endpoint")
+	}
+	// distinct categories don't suppress each other
+	assert.Equal(t, []string{"Transaction rejected by endpoint", "Failed to reconnect to endpoint"}, logger.errors)
+	assert.Len(t, logger.debugs, 18)
+}
+
+func TestErrorLogDisabled(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := newErrorLogWithClock(logger, 0, time.Minute, clock.now)
+	for i := 0; i < 10; i++ {
+		l.Error("send", "Failed to send transactions")
+	}
+	assert.Len(t, logger.errors, 10)
+	assert.Empty(t, logger.debugs)
+}
+
+func TestErrorLogSummary(t *testing.T) {
+	logger := &recordingLogger{}
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := newErrorLogWithClock(logger, 10*time.Second, time.Minute, clock.now)
+
+	// nothing to summarize
+	clock.advance(time.Minute)
+	l.MaybeSummarize()
+	assert.Empty(t, logger.warns)
+
+	for i := 0; i < 3; i++ {
+		l.Error("send", "Failed to send transactions")
+	}
+	l.Error("rejected", "Transaction rejected by endpoint")
+	clock.advance(30 * time.Second)
+	l.MaybeSummarize()
+	assert.Empty(t, logger.warns)
+
+	clock.advance(30 * time.Second)
+	l.MaybeSummarize()
+	assert.Equal(t, []string{"Errors since last summary"}, logger.warns)
+	// each summary only covers the errors since the previous one
+	clock.advance(time.Minute)
+	l.MaybeSummarize()
+	assert.Len(t, logger.warns, 1)
+}
+
+func TestConfigErrorLogInterval(t *testing.T) {
+	assert.Equal(t, defaultErrorLogInterval, Config{}.errorLogInterval())
+	assert.Equal(t, 30*time.Second, Config{ErrorLogInterval: Duration(30 * time.Second)}.errorLogInterval())
+	assert.Equal(t, time.Duration(0), Config{ErrorLogInterval: -1}.errorLogInterval())
+}
@@ -0,0 +1,120 @@
+package loadtest
+
+import "errors"
+
+// FailureClass classifies the errors with which load tests end, so that the
+// CLI (and anything else running load tests) can tell a misconfiguration from
+// unreachable endpoints, a failure while sending transactions, a cancellation
+// or results that fell short of a threshold.
+type FailureClass int
+
+// The classes of failure with which a load test can end.
+const (
+	FailureNone         FailureClass = iota // The load test completed successfully.
+	FailureConfig                           // The configuration is invalid, so no load test was attempted.
+	FailureConnectivity                     // The endpoints (or coordinator) couldn't be reached, or another pre-flight check failed.
+	FailureRuntime                          // The load test failed while sending transactions, or while reporting on them.
+	FailureCancelled                        // The load test was cancelled (e.g. by an interrupt) or terminated before it completed.
+	FailureThreshold                        // The load test completed, but its results fell short of a threshold (e.g. --min-success-ratio).
+)
+
+// String returns the name of the failure class.
+func (c FailureClass) String() string {
+	switch c {
+	case FailureNone:
+		return "none"
+	case FailureConfig:
+		return "config"
+	case FailureConnectivity:
+		return "connectivity"
+	case FailureCancelled:
+		return "cancelled"
+	case FailureThreshold:
+		return "threshold"
+	default:
+		return "runtime"
+	}
+}
+
+// ExitCode returns the code with which the CLI exits when a load test ends
+// with a failure of this class.
+func (c FailureClass) ExitCode() int {
+	switch c {
+	case FailureNone:
+		return ExitCodeSuccess
+	case FailureConfig:
+		return ExitCodeInvalidConfig
+	case FailureConnectivity:
+		return ExitCodeConnectivity
+	case FailureCancelled:
+		return ExitCodeCancelled
+	case FailureThreshold:
+		return ExitCodeThreshold
+	default:
+		return ExitCodeRuntime
+	}
+}
+
+// ClassifyError returns the class of failure with which the given error (as
+// returned by ExecuteStandalone, Coordinator.Run or Worker.Run) ended a load
+// test. Cancellation takes precedence over any other class, and errors that
+// weren't otherwise classified are runtime failures.
+func ClassifyError(err error) FailureClass {
+	if err == nil {
+		return FailureNone
+	}
+	if errors.Is(err, ErrLoadTestCancelled) {
+		return FailureCancelled
+	}
+	var ce *classifiedError
+	if errors.As(err, &ce) {
+		return ce.class
+	}
+	return FailureRuntime
+}
+
+// ExitCodeFor returns the code with which the CLI exits when a load test ends
+// with the given error (which may be nil).
+func ExitCodeFor(err error) int {
+	return ClassifyError(err).ExitCode()
+}
+
+// classifiedError is an error that has been given a class of failure, without
+// changing its message.
+type classifiedError struct {
+	class FailureClass
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// withFailureClass gives the given error (if any) the given class of failure,
+// unless it's already been classified, in which case it keeps its class.
+func withFailureClass(class FailureClass, err error) error {
+	if err == nil {
+		return nil
+	}
+	var ce *classifiedError
+	if errors.As(err, &ce) {
+		return err
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+func configError(err error) error {
+	return withFailureClass(FailureConfig, err)
+}
+
+func connectivityError(err error) error {
+	return withFailureClass(FailureConnectivity, err)
+}
+
+func thresholdError(err error) error {
+	return withFailureClass(FailureThreshold, err)
+}
@@ -0,0 +1,60 @@
+package loadtest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		class    FailureClass
+		exitCode int
+	}{
+		{"success", nil, FailureNone, ExitCodeSuccess},
+		{"config", configError(errors.New("invalid endpoint rate limit")), FailureConfig, ExitCodeInvalidConfig},
+		{"connectivity", connectivityError(errors.New("dial tcp: connection refused")), FailureConnectivity, ExitCodeConnectivity},
+		{"runtime", errors.New("connection reset by peer"), FailureRuntime, ExitCodeRuntime},
+		{"cancelled", ErrLoadTestCancelled, FailureCancelled, ExitCodeCancelled},
+		{"terminated", ErrLoadTestTerminated, FailureCancelled, ExitCodeCancelled},
+		{"threshold", thresholdError(errors.New("success ratio of 0.5 is below the minimum of 0.9")), FailureThreshold, ExitCodeThreshold},
+		// classes survive wrapping
+		{"wrapped", fmt.Errorf("worker w1: %w", configError(errors.New("rate is unachievable"))), FailureConfig, ExitCodeInvalidConfig},
+		// and the first class given to an error sticks
+		{"reclassified", connectivityError(configError(errors.New("invalid worker configuration"))), FailureConfig, ExitCodeInvalidConfig},
+		{"worker cancelled", connectivityError(errWorkerCancelled), FailureCancelled, ExitCodeCancelled},
+		// cancellation takes precedence
+		{"cancelled while connecting", connectivityError(fmt.Errorf("%w while waiting for workers", ErrLoadTestCancelled)), FailureCancelled, ExitCodeCancelled},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.class, ClassifyError(tc.err))
+			assert.Equal(t, tc.exitCode, ExitCodeFor(tc.err))
+		})
+	}
+}
+
+func TestClassifiedErrorKeepsMessage(t *testing.T) {
+	cause := errors.New("timed out waiting for all workers to connect")
+	err := connectivityError(cause)
+	assert.EqualError(t, err, cause.Error())
+	assert.ErrorIs(t, err, cause)
+	assert.NoError(t, configError(nil))
+}
+
+func TestFailureClassString(t *testing.T) {
+	for class, name := range map[FailureClass]string{
+		FailureNone:         "none",
+		FailureConfig:       "config",
+		FailureConnectivity: "connectivity",
+		FailureRuntime:      "runtime",
+		FailureCancelled:    "cancelled",
+		FailureThreshold:    "threshold",
+	} {
+		assert.Equal(t, name, class.String())
+	}
+}
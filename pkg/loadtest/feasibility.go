@@ -0,0 +1,149 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	// defaultTxGenerateCost is the rough time it takes to generate and
+	// serialize a transaction, assumed unless measured by a calibration.
+	defaultTxGenerateCost = 50 * time.Microsecond
+
+	// txWriteCost is the rough time it takes to write a serialized transaction
+	// to a WebSockets connection, which a calibration doesn't measure.
+	txWriteCost = 50 * time.Microsecond
+
+	// feasibilityHeadroom is the fraction of each send period that sending a
+	// batch of transactions may take up before the rate is deemed
+	// unachievable, leaving time for reading responses, pings and garbage
+	// collection.
+	feasibilityHeadroom = 0.8
+
+	// calibrationTxs is the number of transactions generated to measure the
+	// cost of generating and serializing a transaction.
+	calibrationTxs = 100
+)
+
+// rateFeasibility is a rough estimate of whether each connection can send its
+// batch of transactions within a single send period.
+type rateFeasibility struct {
+	BatchSize   float64       // The number of transactions each connection sends per send period.
+	TxBudget    time.Duration // The time each transaction may take for a batch to fit into a send period.
+	TxCost      time.Duration // The estimated time it takes to generate, serialize and write a transaction.
+	Connections int           // The number of connections per endpoint needed to achieve the same overall rate.
+	SendPeriod  time.Duration // The shortest send period in which a connection can send a batch of the configured size.
+}
+
+// rateFeasibility works out whether this configuration's rate is achievable,
+// given the time it takes to generate and serialize a transaction.
+func (c Config) rateFeasibility(generateCost time.Duration) rateFeasibility {
+	f := rateFeasibility{
+		BatchSize:   c.Rate,
+		TxBudget:    time.Duration(math.MaxInt64),
+		TxCost:      generateCost + txWriteCost,
+		Connections: c.Connections,
+		SendPeriod:  time.Duration(c.SendPeriod),
+	}
+	if c.Rate <= 0 {
+		return f
+	}
+	budget := float64(c.SendPeriod) * feasibilityHeadroom
+	f.TxBudget = time.Duration(budget / c.Rate)
+	// the time it takes a connection to send its batch
+	needed := c.Rate * float64(f.TxCost)
+	if needed <= budget {
+		return f
+	}
+	f.Connections = int(math.Ceil(float64(c.Connections) * needed / budget))
+	f.SendPeriod = time.Duration(math.Ceil(needed/feasibilityHeadroom/float64(time.Millisecond))) * time.Millisecond
+	return f
+}
+
+// feasible returns whether the estimate suggests that the rate is achievable.
+func (f rateFeasibility) feasible() bool {
+	return f.TxCost <= f.TxBudget
+}
+
+// String describes an unachievable rate, along with how to achieve it.
+func (f rateFeasibility) String() string {
+	return fmt.Sprintf(
+		"batches of %g transactions per connection leave %s per transaction, but each takes about %s to generate, serialize and send - "+
+			"use at least %d connections per endpoint (scaling the rate down to keep the same total), or a send period of at least %s",
+		f.BatchSize,
+		f.TxBudget,
+		f.TxCost,
+		f.Connections,
+		f.SendPeriod,
+	)
+}
+
+// checkRateFeasibility logs a warning if the configured rate looks
+// unachievable given the time it takes to generate and serialize a
+// transaction, or returns an error instead if feasibility is strictly
+// enforced. Any given key/value pairs are added to the warning.
+func (c Config) checkRateFeasibility(generateCost time.Duration, logger logging.Logger, kvpairs ...interface{}) error {
+	f := c.rateFeasibility(generateCost)
+	if f.feasible() {
+		return nil
+	}
+	if c.StrictFeasibility {
+		return fmt.Errorf("transaction rate is unachievable: %s", f)
+	}
+	kvpairs = append(kvpairs, "rate", c.Rate, "sendPeriod", c.SendPeriod, "connections", c.Connections, "reason", f.String())
+	logger.Warn("Transaction rate is likely unachievable", kvpairs...)
+	return nil
+}
+
+// calibrateTxCost measures the average time it takes to generate and serialize
+// a transaction with the configured client factory, unless calibration is
+// skipped, in which case a rough default is assumed.
+func calibrateTxCost(cfg Config) (time.Duration, error) {
+	if cfg.SkipCalibration {
+		return defaultTxGenerateCost, nil
+	}
+	factory, ok := clientFactories[cfg.ClientFactory]
+	if !ok {
+		return 0, fmt.Errorf("client factory \"%s\" does not exist", cfg.ClientFactory)
+	}
+	method, err := lookupBroadcastMethod(cfg.BroadcastTxMethod)
+	if err != nil {
+		return 0, err
+	}
+	client, err := factory.NewClient(cfg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create client with client factory \"%s\": %w", cfg.ClientFactory, err)
+	}
+	start := time.Now()
+	for i := 0; i < calibrationTxs; i++ {
+		tx, err := client.GenerateTx()
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate calibration transaction: %w", err)
+		}
+		req, err := method.buildRequest(i, tx)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := json.Marshal(req); err != nil {
+			return 0, err
+		}
+	}
+	return time.Since(start) / calibrationTxs, nil
+}
+
+// calibrateRateFeasibility calibrates the cost of generating transactions for
+// a dry run and checks that the configured rate is achievable with it.
+func calibrateRateFeasibility(cfg Config, logger logging.Logger) error {
+	cost, err := calibrateTxCost(cfg)
+	if err != nil {
+		return err
+	}
+	if !cfg.SkipCalibration {
+		logger.Info("Calibrated transaction generation", "txs", calibrationTxs, "costPerTx", cost)
+	}
+	return cfg.checkRateFeasibility(cost, logger)
+}
@@ -0,0 +1,81 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateFeasibility(t *testing.T) {
+	testCases := []struct {
+		rate        float64
+		sendPeriod  time.Duration
+		connections int
+		feasible    bool
+		budget      time.Duration
+		recConns    int
+		recPeriod   time.Duration
+	}{
+		// each transaction takes 100µs by default, and may take up to 80% of
+		// the send period's share
+		{rate: 1000, sendPeriod: time.Second, connections: 1, feasible: true, budget: 800 * time.Microsecond},
+		{rate: 8000, sendPeriod: time.Second, connections: 1, feasible: true, budget: 100 * time.Microsecond},
+		{rate: 10000, sendPeriod: time.Second, connections: 1, budget: 80 * time.Microsecond, recConns: 2, recPeriod: 1250 * time.Millisecond},
+		{rate: 1000, sendPeriod: 100 * time.Millisecond, connections: 4, budget: 80 * time.Microsecond, recConns: 5, recPeriod: 125 * time.Millisecond},
+		{rate: 0.5, sendPeriod: time.Millisecond, connections: 1, feasible: true, budget: 1600 * time.Microsecond},
+	}
+	for _, tc := range testCases {
+		cfg := Config{Rate: tc.rate, SendPeriod: Duration(tc.sendPeriod), Connections: tc.connections}
+		f := cfg.rateFeasibility(defaultTxGenerateCost)
+		assert.Equal(t, tc.feasible, f.feasible(), "rate %v", tc.rate)
+		assert.Equal(t, 100*time.Microsecond, f.TxCost)
+		assert.Equal(t, tc.budget, f.TxBudget, "rate %v", tc.rate)
+		if !tc.feasible {
+			assert.Equal(t, tc.recConns, f.Connections, "rate %v", tc.rate)
+			assert.Equal(t, tc.recPeriod, f.SendPeriod, "rate %v", tc.rate)
+			// the recommendations are feasible
+			cfg.Rate = tc.rate * float64(tc.connections) / float64(f.Connections)
+			cfg.Connections = f.Connections
+			assert.True(t, cfg.rateFeasibility(defaultTxGenerateCost).feasible(), "rate %v", tc.rate)
+			cfg = Config{Rate: tc.rate, SendPeriod: Duration(f.SendPeriod), Connections: tc.connections}
+			assert.True(t, cfg.rateFeasibility(defaultTxGenerateCost).feasible(), "rate %v", tc.rate)
+		}
+	}
+
+	// a total rate is split amongst the workers later on
+	assert.True(t, Config{SendPeriod: Duration(time.Second), Connections: 1}.rateFeasibility(defaultTxGenerateCost).feasible())
+	// a calibrated cost takes precedence over the default
+	cfg := Config{Rate: 1000, SendPeriod: Duration(time.Second), Connections: 1}
+	assert.False(t, cfg.rateFeasibility(time.Millisecond).feasible())
+}
+
+func TestCheckRateFeasibility(t *testing.T) {
+	cfg := Config{Rate: 10000, SendPeriod: Duration(time.Second), Connections: 1}
+	assert.NoError(t, cfg.checkRateFeasibility(defaultTxGenerateCost, logging.NewNoopLogger()))
+	cfg.StrictFeasibility = true
+	err := cfg.checkRateFeasibility(defaultTxGenerateCost, logging.NewNoopLogger())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "use at least 2 connections per endpoint")
+	assert.Contains(t, err.Error(), "or a send period of at least 1.25s")
+	cfg.Rate = 1000
+	assert.NoError(t, cfg.checkRateFeasibility(defaultTxGenerateCost, logging.NewNoopLogger()))
+}
+
+func TestCalibrateTxCost(t *testing.T) {
+	cfg := Config{ClientFactory: "kvstore", Size: 250, BroadcastTxMethod: "async", SkipCalibration: true}
+	cost, err := calibrateTxCost(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, defaultTxGenerateCost, cost)
+
+	cfg.SkipCalibration = false
+	cost, err = calibrateTxCost(cfg)
+	require.NoError(t, err)
+	assert.Greater(t, cost, time.Duration(0))
+
+	cfg.ClientFactory = "nonexistent"
+	_, err = calibrateTxCost(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package loadtest
+
+import "os"
+
+// Advisory file locking is not supported on this platform, so concurrent
+// appends to the same file are not protected.
+func lockFile(*os.File) error { return nil }
+
+func unlockFile(*os.File) error { return nil }
@@ -0,0 +1,68 @@
+// Package gcs adds Google Cloud Storage statistics output URLs of the form
+// gs://bucket/key to tm-load-test. Importing the package registers its
+// statistics uploader:
+//
+//	import _ "github.com/informalsystems/tm-load-test/pkg/loadtest/gcs"
+//
+// It lives in its own package so that programs that embed the loadtest
+// package without it don't depend on the Google Cloud client libraries.
+package gcs
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"cloud.google.com/go/storage"
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+func init() {
+	if err := loadtest.RegisterStatsUploader("gs", &gcsUploader{}); err != nil {
+		panic(err)
+	}
+}
+
+// gcsUploader uploads objects to Google Cloud Storage with the Google Cloud
+// client library, which obtains credentials from the application default
+// credentials file or the GCE metadata server, as usual (or uses an emulator
+// given by STORAGE_EMULATOR_HOST without authentication). An access token
+// given by GOOGLE_OAUTH_ACCESS_TOKEN takes precedence.
+type gcsUploader struct{}
+
+var _ loadtest.StatsUploader = (*gcsUploader)(nil)
+
+func (u *gcsUploader) Upload(ctx context.Context, bucket, key, contentType string, body []byte) (bool, error) {
+	var opts []option.ClientOption
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); len(token) > 0 && len(os.Getenv("STORAGE_EMULATOR_HOST")) == 0 {
+		opts = append(opts, option.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})))
+	}
+	// fails if no credentials can be found, which there's no point in retrying
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	// we retry uploads ourselves
+	obj := client.Bucket(bucket).Object(key).Retryer(storage.WithPolicy(storage.RetryNever))
+	w := obj.NewWriter(ctx)
+	w.ContentType = contentType
+	// upload the object in a single request
+	w.ChunkSize = 0
+	_, err = w.Write(body)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) {
+			return loadtest.RetryableUploadStatus(apiErr.Code), err
+		}
+		return true, err
+	}
+	return false, nil
+}
@@ -0,0 +1,81 @@
+package gcs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gcsStub is a Cloud Storage emulator stub that records uploaded objects.
+type gcsStub struct {
+	mtx      sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int // The statuses with which to respond to successive requests (200 once exhausted).
+}
+
+func (s *gcsStub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.requests = append(s.requests, req)
+	s.bodies = append(s.bodies, body)
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	// responds with the uploaded object's metadata
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte("{}"))
+}
+
+// newGCSStub starts a stub at which the Cloud Storage client is pointed as an
+// emulator, isolating it from any credentials on the machine running the
+// tests.
+func newGCSStub(t *testing.T, statuses ...int) *gcsStub {
+	stub := &gcsStub{statuses: statuses}
+	svr := httptest.NewServer(stub)
+	t.Cleanup(svr.Close)
+	t.Setenv("STORAGE_EMULATOR_HOST", svr.URL)
+	return stub
+}
+
+func TestGCSUpload(t *testing.T) {
+	stub := newGCSStub(t)
+	retry, err := (&gcsUploader{}).Upload(context.Background(), "bucket", "runs/stats.json", "application/json", []byte("{}\n"))
+	require.NoError(t, err)
+	assert.False(t, retry)
+
+	require.Len(t, stub.requests, 1)
+	req := stub.requests[0]
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, "/upload/storage/v1/b/bucket/o", req.URL.Path)
+	assert.Equal(t, "multipart", req.URL.Query().Get("uploadType"))
+	// the object's metadata and content are uploaded together
+	body := string(stub.bodies[0])
+	assert.Contains(t, body, `"name":"runs/stats.json"`)
+	assert.Contains(t, body, `"contentType":"application/json"`)
+	assert.Contains(t, body, "\r\n\r\n{}\n\r\n")
+}
+
+func TestGCSUploadFailure(t *testing.T) {
+	stub := newGCSStub(t, http.StatusServiceUnavailable, http.StatusForbidden)
+	uploader := &gcsUploader{}
+
+	// server errors are worth retrying
+	retry, err := uploader.Upload(context.Background(), "bucket", "stats.json", "application/json", []byte("{}\n"))
+	assert.Error(t, err)
+	assert.True(t, retry)
+	// but client errors aren't, and the client library doesn't retry either
+	retry, err = uploader.Upload(context.Background(), "bucket", "stats.json", "application/json", []byte("{}\n"))
+	assert.Error(t, err)
+	assert.False(t, retry)
+	assert.Len(t, stub.requests, 2)
+}
@@ -0,0 +1,256 @@
+package loadtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	influxDBMeasurement = "tmloadtest"
+
+	influxDBSampleInterval = 1 * time.Second  // The interval over which statistics are aggregated into each point.
+	influxDBBatchInterval  = 10 * time.Second // The interval at which batches of points are written.
+	influxDBMaxBatchSize   = 5000             // Write a batch early if this many points are pending.
+	influxDBWriteTimeout   = 10 * time.Second
+)
+
+// influxDBSink writes per-interval statistics for each endpoint to an
+// InfluxDB v2 bucket using the line protocol. Write failures are logged and
+// otherwise ignored, so that they don't affect the load test.
+type influxDBSink struct {
+	writeURL string
+	token    string
+	tags     string // Pre-formatted tags to add to all points (after the endpoint tag).
+	client   *http.Client
+	logger   logging.Logger
+
+	mtx       sync.Mutex
+	intervals map[string]*influxDBInterval // Statistics for the current interval, keyed by endpoint.
+
+	// Only accessed from the run goroutine.
+	pending   []string // Points awaiting writing.
+	written   int
+	failed    int
+	lastError error
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type influxDBInterval struct {
+	txs        int
+	bytes      int64
+	failures   int
+	latencySum time.Duration
+	latencies  int
+}
+
+var _ MetricsSink = (*influxDBSink)(nil)
+
+// newInfluxDBSink creates a sink that writes to the given bucket of the
+// InfluxDB v2 server at the given URL, adding the given tags to all points.
+func newInfluxDBSink(serverURL, token, org, bucket string, tags map[string]string, logger logging.Logger) (*influxDBSink, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse InfluxDB URL %s: %w", serverURL, err)
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/api/v2/write"
+	q := url.Values{}
+	q.Set("bucket", bucket)
+	if len(org) > 0 {
+		q.Set("org", org)
+	}
+	q.Set("precision", "s")
+	u.RawQuery = q.Encode()
+
+	s := &influxDBSink{
+		writeURL:  u.String(),
+		token:     token,
+		tags:      formatInfluxDBTags(tags),
+		client:    &http.Client{Timeout: influxDBWriteTimeout},
+		logger:    logger,
+		intervals: make(map[string]*influxDBInterval),
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *influxDBSink) TxsSent(endpoint string, count int, bytes int64) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	iv := s.interval(endpoint)
+	iv.txs += count
+	iv.bytes += bytes
+}
+
+func (s *influxDBSink) TxFailed(endpoint string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.interval(endpoint).failures++
+}
+
+func (s *influxDBSink) BroadcastLatency(endpoint string, latency time.Duration) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	iv := s.interval(endpoint)
+	iv.latencySum += latency
+	iv.latencies++
+}
+
+// Must be called while holding the mutex.
+func (s *influxDBSink) interval(endpoint string) *influxDBInterval {
+	iv, ok := s.intervals[endpoint]
+	if !ok {
+		iv = &influxDBInterval{}
+		s.intervals[endpoint] = iv
+	}
+	return iv
+}
+
+// Close writes any outstanding statistics and logs a summary of any write
+// failures.
+func (s *influxDBSink) Close() {
+	close(s.stop)
+	<-s.stopped
+	if s.failed > 0 {
+		s.logger.Error(
+			"Failed to export some statistics to InfluxDB",
+			"failedPoints", s.failed,
+			"totalPoints", s.failed+s.written,
+			"lastErr", s.lastError,
+		)
+	} else {
+		s.logger.Debug("Exported statistics to InfluxDB", "points", s.written)
+	}
+}
+
+func (s *influxDBSink) run() {
+	defer close(s.stopped)
+
+	sampleTicker := time.NewTicker(influxDBSampleInterval)
+	defer sampleTicker.Stop()
+	batchTicker := time.NewTicker(influxDBBatchInterval)
+	defer batchTicker.Stop()
+
+	for {
+		select {
+		case now := <-sampleTicker.C:
+			s.sample(now)
+			if len(s.pending) >= influxDBMaxBatchSize {
+				s.write()
+			}
+
+		case <-batchTicker.C:
+			s.write()
+
+		case <-s.stop:
+			s.sample(time.Now())
+			s.write()
+			return
+		}
+	}
+}
+
+// sample converts the statistics for the current interval into points.
+func (s *influxDBSink) sample(now time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	endpoints := make([]string, 0, len(s.intervals))
+	for endpoint := range s.intervals {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	for _, endpoint := range endpoints {
+		s.pending = append(s.pending, s.formatPoint(endpoint, s.intervals[endpoint], now))
+		// we keep reporting on endpoints once seen, even if idle
+		s.intervals[endpoint] = &influxDBInterval{}
+	}
+}
+
+func (s *influxDBSink) formatPoint(endpoint string, iv *influxDBInterval, t time.Time) string {
+	avgLatencyMs := 0.0
+	if iv.latencies > 0 {
+		avgLatencyMs = float64((iv.latencySum / time.Duration(iv.latencies)).Microseconds()) / 1000
+	}
+	return fmt.Sprintf(
+		"%s,endpoint=%s%s txs=%di,bytes=%di,failures=%di,avg_latency_ms=%.3f %d",
+		influxDBMeasurement,
+		escapeInfluxDBTag(endpoint),
+		s.tags,
+		iv.txs,
+		iv.bytes,
+		iv.failures,
+		avgLatencyMs,
+		t.Unix(),
+	)
+}
+
+func (s *influxDBSink) write() {
+	if len(s.pending) == 0 {
+		return
+	}
+	points := s.pending
+	s.pending = nil
+	if err := s.post(strings.Join(points, "\n")); err != nil {
+		if s.failed == 0 {
+			s.logger.Error("Failed to export statistics to InfluxDB", "err", err)
+		}
+		s.failed += len(points)
+		s.lastError = err
+		return
+	}
+	s.written += len(points)
+}
+
+func (s *influxDBSink) post(body string) error {
+	req, err := http.NewRequest(http.MethodPost, s.writeURL, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if len(s.token) > 0 {
+		req.Header.Set("Authorization", "Token "+s.token)
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("got status %d from InfluxDB: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// formatInfluxDBTags formats the given tags (sorted by key) for inclusion in
+// a line protocol point, including the leading comma.
+func formatInfluxDBTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, ",%s=%s", escapeInfluxDBTag(k), escapeInfluxDBTag(tags[k]))
+	}
+	return sb.String()
+}
+
+var influxDBTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+func escapeInfluxDBTag(s string) string {
+	return influxDBTagEscaper.Replace(s)
+}
@@ -0,0 +1,78 @@
+package loadtest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfluxDBSink(t *testing.T) {
+	var mtx sync.Mutex
+	var requests []*http.Request
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mtx.Lock()
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		mtx.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink, err := newInfluxDBSink(srv.URL, "secret", "myorg", "loadtests", map[string]string{"worker": "w1"}, logging.NewNoopLogger())
+	require.NoError(t, err)
+	sink.TxsSent("ws://host a:26657/websocket", 2, 500)
+	sink.TxsSent("ws://host a:26657/websocket", 1, 250)
+	sink.TxFailed("ws://host a:26657/websocket")
+	sink.BroadcastLatency("ws://host a:26657/websocket", time.Millisecond)
+	sink.BroadcastLatency("ws://host a:26657/websocket", 2*time.Millisecond)
+	sink.Close()
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/api/v2/write", requests[0].URL.Path)
+	assert.Equal(t, "loadtests", requests[0].URL.Query().Get("bucket"))
+	assert.Equal(t, "myorg", requests[0].URL.Query().Get("org"))
+	assert.Equal(t, "s", requests[0].URL.Query().Get("precision"))
+	assert.Equal(t, "Token secret", requests[0].Header.Get("Authorization"))
+
+	lines := strings.Split(bodies[0], "\n")
+	require.Len(t, lines, 1)
+	i := strings.LastIndex(lines[0], " ")
+	assert.Equal(t, `tmloadtest,endpoint=ws://host\ a:26657/websocket,worker=w1 txs=3i,bytes=750i,failures=1i,avg_latency_ms=1.500`, lines[0][:i])
+	ts, err := strconv.ParseInt(lines[0][i+1:], 10, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().Unix(), ts, 5)
+}
+
+func TestInfluxDBSinkWriteFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	sink, err := newInfluxDBSink(srv.URL, "", "", "loadtests", nil, logging.NewNoopLogger())
+	require.NoError(t, err)
+	sink.TxsSent("ws://localhost:26657/websocket", 1, 250)
+	sink.Close()
+
+	assert.Equal(t, 1, sink.failed)
+	assert.Equal(t, 0, sink.written)
+	assert.ErrorContains(t, sink.lastError, "got status 401")
+}
+
+func TestFormatInfluxDBTags(t *testing.T) {
+	assert.Equal(t, "", formatInfluxDBTags(nil))
+	assert.Equal(t, `,a=1,b=x\,y\=z`, formatInfluxDBTags(map[string]string{"b": "x,y=z", "a": "1"}))
+}
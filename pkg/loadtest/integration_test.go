@@ -4,7 +4,7 @@
 package loadtest_test
 
 import (
-	"encoding/csv"
+	"context"
 	"fmt"
 	"io"
 	"math"
@@ -51,8 +51,9 @@ func testCoordinatorWorkerHappyPath(t *testing.T) {
 	coordCfg := loadtest.CoordinatorConfig{ //负载测试系统的主要控制器。它负责协调和管理整个测试过程，包括启动、停止和监控工作器的活动。
 		BindAddr:             fmt.Sprintf("localhost:%d", freePort),
 		ExpectWorkers:        2,
-		WorkerConnectTimeout: 10,
-		ShutdownWait:         1,
+		WorkerConnectTimeout: seconds(10),
+		ShutdownWait:         seconds(1),
+		AuthToken:            "integration-test-token",
 	}
 	coord := loadtest.NewCoordinator(&cfg, &coordCfg) //创建协调器
 	coordErr := make(chan error, 1)
@@ -62,9 +63,20 @@ func testCoordinatorWorkerHappyPath(t *testing.T) {
 
 	workerCfg := loadtest.WorkerConfig{ //工作器是模拟真实节点的实例，负责实际执行交易和与 Tendermint 共识引擎进行交互
 		CoordAddr:           fmt.Sprintf("ws://localhost:%d", freePort),
-		CoordConnectTimeout: 10,
+		CoordConnectTimeout: seconds(10),
+		AuthToken:           coordCfg.AuthToken,
 	}
-	worker1, err := loadtest.NewWorker(&workerCfg)
+	// only the first worker serves its own metrics, since both workers share
+	// the same host
+	workerMetricsPort, err := getFreePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker1Cfg := workerCfg
+	worker1Cfg.ID = "integration1"
+	worker1Cfg.Labels = map[string]string{"role": "first"}
+	worker1Cfg.MetricsAddr = fmt.Sprintf("localhost:%d", workerMetricsPort)
+	worker1, err := loadtest.NewWorker(&worker1Cfg)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -72,8 +84,18 @@ func testCoordinatorWorkerHappyPath(t *testing.T) {
 	go func() {
 		worker1Err <- worker1.Run()
 	}()
+	// scrape the worker's metrics mid-run
+	worker1Metrics := make(chan workerMetricsResult, 1)
+	go func() {
+		time.Sleep(time.Duration(cfg.Time) / 2)
+		txs, err := getWorkerTotalTxs(workerMetricsPort)
+		worker1Metrics <- workerMetricsResult{txs, err}
+	}()
 
-	worker2, err := loadtest.NewWorker(&workerCfg) //创建两个工作器
+	worker2Cfg := workerCfg
+	worker2Cfg.ID = "integration2"
+	worker2Cfg.Labels = map[string]string{"role": "second"}
+	worker2, err := loadtest.NewWorker(&worker2Cfg) //创建两个工作器
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -106,7 +128,7 @@ func testCoordinatorWorkerHappyPath(t *testing.T) {
 				t.Fatal(err)
 			}
 
-		case <-time.After(time.Duration(cfg.Time*10) * time.Second):
+		case <-time.After(10 * time.Duration(cfg.Time)):
 			t.Fatal("Timed out waiting for test to complete")
 		}
 
@@ -120,6 +142,13 @@ func testCoordinatorWorkerHappyPath(t *testing.T) {
 	if !metricsTested { //确保已经完成测试
 		t.Fatal("Expected to have tested Prometheus metrics, but did not")
 	}
+	wm := <-worker1Metrics
+	if wm.err != nil {
+		t.Fatal("Failed to scrape worker metrics mid-run:", wm.err)
+	}
+	if wm.txs <= 0 || wm.txs > totalTxsPerWorker {
+		t.Fatalf("Expected worker to have sent between 1 and %d transactions mid-run, but got %d", totalTxsPerWorker, wm.txs)
+	}
 	// check the Prometheus stats检查统计数据与期待值是否一致
 	if expectedTotalTxs != pstats.txCount {
 		t.Fatalf("Expected %d total transactions from Prometheus statistics, but got %d", expectedTotalTxs, pstats.txCount)
@@ -127,34 +156,63 @@ func testCoordinatorWorkerHappyPath(t *testing.T) {
 	if expectedTotalBytes != pstats.txBytes {
 		t.Fatalf("Expected %d total transactions from Prometheus statistics, but got %d", expectedTotalBytes, pstats.txBytes)
 	}
+	if len(pstats.workerTxs) != 2 {
+		t.Fatalf("Expected per-worker transaction counts for 2 workers from Prometheus statistics, but got %v", pstats.workerTxs)
+	}
+	for id, txs := range pstats.workerTxs {
+		if txs != totalTxsPerWorker {
+			t.Fatalf("Expected worker %s to have sent %d transactions according to Prometheus statistics, but got %d", id, totalTxsPerWorker, txs)
+		}
+	}
+	expectedRoles := map[string]string{worker1Cfg.ID: "first", worker2Cfg.ID: "second"}
+	for id, role := range expectedRoles {
+		if _, exists := pstats.workerTxs[id]; !exists {
+			t.Fatalf("Expected Prometheus statistics for worker %s, but got %v", id, pstats.workerTxs)
+		}
+		if pstats.workerRoles[id] != role {
+			t.Fatalf("Expected worker %s to have role label %q in Prometheus statistics, but got %q", id, role, pstats.workerRoles[id])
+		}
+	}
 
 	// ensure the aggregate stats were generated and computed correctly
-	stats, err := parseStats(cfg.StatsOutputFile)
-	if err != nil {
-		t.Fatal("Failed to parse output stats", err)
+	report := coord.Report()
+	if report == nil {
+		t.Fatal("Expected the coordinator to report its final statistics, but got none")
 	}
-	t.Logf("Got aggregate statistics from CSV: %v", stats)
-	if stats.TotalTxs != expectedTotalTxs {
-		t.Fatalf("Expected %d transactions to have been recorded in aggregate stats, but got %d", expectedTotalTxs, stats.TotalTxs)
+	if _, err := os.Stat(cfg.StatsOutputFile); err != nil {
+		t.Fatal("Expected the aggregate statistics to have been written", err)
 	}
-	if stats.TotalBytes != expectedTotalBytes {
-		t.Fatalf("Expected %d bytes to have been sent, but got %d", expectedTotalBytes, stats.TotalBytes)
+	stats := &report.Aggregate
+	t.Logf("Got aggregate statistics: %v", stats)
+	checkAggregateStats(t, stats, expectedTotalTxs, expectedTotalBytes)
+
+	// ensure each worker's own statistics were recorded
+	workerStats := make(map[string]loadtest.WorkerStats, len(report.Workers))
+	for _, ws := range report.Workers {
+		workerStats[ws.ID] = ws
 	}
-	if !floatsEqualWithTolerance(stats.AvgTxRate, float64(stats.TotalTxs)/stats.TotalTimeSeconds, float64(stats.TotalTxs)/1000.0) {
-		t.Fatalf(
-			"Average transaction rate (%.3f) does not compute from total time (%.3f) and total transactions (%d)",
-			stats.AvgTxRate,
-			stats.TotalTimeSeconds,
-			stats.TotalTxs,
-		)
+	if len(workerStats) != 2 {
+		t.Fatalf("Expected statistics for 2 workers, but got %d", len(workerStats))
 	}
-	if !floatsEqualWithTolerance(stats.AvgDataRate, float64(stats.TotalBytes)/stats.TotalTimeSeconds, float64(stats.TotalBytes)/1000.0) {
-		t.Fatalf(
-			"Average transaction data rate (%.3f) does not compute from total time (%.3f) and total bytes sent (%d)",
-			stats.AvgDataRate,
-			stats.TotalTimeSeconds,
-			stats.TotalBytes,
-		)
+	for id, role := range expectedRoles {
+		ws, exists := workerStats[id]
+		if !exists {
+			t.Fatalf("Expected statistics for worker %s, but got none", id)
+		}
+		if ws.Labels["role"] != role {
+			t.Fatalf("Expected worker %s to have role label %q in its statistics, but got %q", id, role, ws.Labels["role"])
+		}
+	}
+	workerTxs, workerBytes := 0, int64(0)
+	for _, ws := range workerStats {
+		workerTxs += ws.TotalTxs
+		workerBytes += ws.TotalBytes
+	}
+	if workerTxs != stats.TotalTxs {
+		t.Fatalf("Expected per-worker transactions to sum to %d, but got %d", stats.TotalTxs, workerTxs)
+	}
+	if workerBytes != stats.TotalBytes {
+		t.Fatalf("Expected per-worker bytes to sum to %d, but got %d", stats.TotalBytes, workerBytes)
 	}
 }
 
@@ -168,17 +226,24 @@ func testStandaloneHappyPath(t *testing.T) {
 
 	expectedTotalTxs := totalTxsPerWorker
 	cfg := testConfig(tempDir)
+	// the statistics are returned, so needn't be written
+	cfg.StatsOutputFile = ""
 	expectedTotalBytes := int64(cfg.Size) * int64(expectedTotalTxs)
-	if err := loadtest.ExecuteStandalone(cfg); err != nil {
+	report, err := loadtest.RunStandalone(context.Background(), cfg)
+	if err != nil {
 		t.Fatal(err)
 	}
 
 	// ensure the aggregate stats were generated and computed correctly
-	stats, err := parseStats(cfg.StatsOutputFile)
-	if err != nil {
-		t.Fatal("Failed to parse output stats", err)
-	}
-	t.Logf("Got aggregate statistics from CSV: %v", stats)
+	stats := &report.Aggregate
+	t.Logf("Got aggregate statistics: %v", stats)
+	checkAggregateStats(t, stats, expectedTotalTxs, expectedTotalBytes)
+}
+
+// checkAggregateStats ensures that the expected number of transactions (and
+// bytes) were recorded in the given aggregate statistics, and that the rates
+// computed from them are consistent.
+func checkAggregateStats(t *testing.T, stats *loadtest.AggregateStats, expectedTotalTxs int, expectedTotalBytes int64) {
 	if stats.TotalTxs != expectedTotalTxs {
 		t.Fatalf("Expected %d transactions to have been recorded in aggregate stats, but got %d", expectedTotalTxs, stats.TotalTxs)
 	}
@@ -201,14 +266,30 @@ func testStandaloneHappyPath(t *testing.T) {
 			stats.TotalBytes,
 		)
 	}
+	checkBroadcastLatency(t, stats)
+	checkFailures(t, stats)
+}
+
+// checkFailures ensures that none of the transactions or connections in a
+// happy path test failed.
+func checkFailures(t *testing.T, stats *loadtest.AggregateStats) {
+	if stats.FailedTxs != 0 {
+		t.Fatalf("Expected no failed transactions, but got %d", stats.FailedTxs)
+	}
+	if stats.SuccessRatio != 1 {
+		t.Fatalf("Expected a success ratio of 1, but got %.6f", stats.SuccessRatio)
+	}
+	if stats.ErroredConnections != 0 {
+		t.Fatalf("Expected no errored connections, but got %d", stats.ErroredConnections)
+	}
 }
 
 func testConfig(tempDir string) loadtest.Config {
 	return loadtest.Config{
 		ClientFactory:        "kvstore",
 		Connections:          1,
-		Time:                 5,
-		SendPeriod:           1,
+		Time:                 seconds(5),
+		SendPeriod:           seconds(1),
 		Rate:                 100,
 		Size:                 100,
 		Count:                totalTxsPerWorker,
@@ -217,7 +298,7 @@ func testConfig(tempDir string) loadtest.Config {
 		EndpointSelectMethod: loadtest.SelectSuppliedEndpoints,
 		StatsOutputFile:      path.Join(tempDir, "stats.csv"),
 		NoTrapInterrupts:     true,
-		PeerConnectTimeout:   30,
+		PeerConnectTimeout:   seconds(30),
 		MinConnectivity:      4,
 		ExpectPeers:          4,
 	}
@@ -237,73 +318,59 @@ func getFreePort() (int, error) {
 	return l.Addr().(*net.TCPAddr).Port, nil
 }
 
-func parseStats(filename string) (*loadtest.AggregateStats, error) {
-	f, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+// checkBroadcastLatency ensures that broadcast latency percentiles were
+// recorded for all transactions and are consistent with each other.
+func checkBroadcastLatency(t *testing.T, stats *loadtest.AggregateStats) {
+	if stats.BroadcastLatency == nil {
+		t.Fatal("Expected broadcast latency statistics in aggregate stats, but found none")
 	}
-	defer f.Close()
-
-	reader := csv.NewReader(f)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
+	l := stats.BroadcastLatency
+	if l.Count != stats.TotalTxs {
+		t.Fatalf("Expected %d broadcast latency samples, but got %d", stats.TotalTxs, l.Count)
 	}
-
-	if len(records) < 3 {
-		return nil, fmt.Errorf("expected at least 3 records in aggregate stats CSV, but got %d", len(records))
+	if !(l.P50 <= l.P90 && l.P90 <= l.P95 && l.P95 <= l.P99 && l.P99 <= l.Max) {
+		t.Fatalf("Broadcast latency percentiles are not monotonic: %+v", *l)
 	}
-	stats := &loadtest.AggregateStats{}
-	for _, record := range records {
-		if len(record) > 0 {
-			if len(record) < 3 {
-				return nil, fmt.Errorf("expected at least 3 columns for each non-empty row in aggregate stats CSV")
-			}
-			switch record[0] {
-			case "total_txs":
-				totalTxs, err := strconv.ParseInt(record[1], 10, 32)
-				if err != nil {
-					return nil, err
-				}
-				stats.TotalTxs = int(totalTxs)
-
-			case "total_time":
-				stats.TotalTimeSeconds, err = strconv.ParseFloat(record[1], 64)
-				if err != nil {
-					return nil, err
-				}
-
-			case "total_bytes":
-				stats.TotalBytes, err = strconv.ParseInt(record[1], 10, 64)
-				if err != nil {
-					return nil, err
-				}
-
-			case "avg_tx_rate":
-				stats.AvgTxRate, err = strconv.ParseFloat(record[1], 64)
-				if err != nil {
-					return nil, err
-				}
-
-			case "avg_data_rate":
-				stats.AvgDataRate, err = strconv.ParseFloat(record[1], 64)
-				if err != nil {
-					return nil, err
-				}
-			}
-		}
-	}
-
-	return stats, nil
 }
 
 func floatsEqualWithTolerance(a, b, tolerance float64) bool {
 	return math.Abs(a-b) < tolerance
 }
 
+type workerMetricsResult struct {
+	txs int
+	err error
+}
+
+// getWorkerTotalTxs scrapes the total number of transactions sent from a
+// worker's metrics endpoint.
+func getWorkerTotalTxs(port int) (int, error) {
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", port))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("expected status code 200 from worker metrics endpoint, but got %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, "tmloadtest_worker_total_txs ") {
+			txs, err := strconv.ParseFloat(strings.TrimPrefix(line, "tmloadtest_worker_total_txs "), 64)
+			return int(txs), err
+		}
+	}
+	return 0, fmt.Errorf("worker metrics did not include tmloadtest_worker_total_txs")
+}
+
 type prometheusStats struct { //存储指标
-	txCount int
-	txBytes int64
+	txCount     int
+	txBytes     int64
+	workerTxs   map[string]int    // The per-worker transaction counts, keyed by worker ID.
+	workerRoles map[string]string // The per-worker values of the "role" label, keyed by worker ID.
 }
 
 func getPrometheusStats(t *testing.T, port int) prometheusStats {
@@ -323,7 +390,7 @@ func getPrometheusStats(t *testing.T, port int) prometheusStats {
 	if err != nil {
 		t.Fatal("Failed to read response body from Prometheus endpoint:", err)
 	}
-	stats := prometheusStats{}
+	stats := prometheusStats{workerTxs: make(map[string]int), workerRoles: make(map[string]string)}
 	for _, line := range strings.Split(string(body), "\n") { //遍历获取到的Prometheus metrics数据的每一行，根据行的前缀判断是否是需要的指标
 		if strings.HasPrefix(line, "tmloadtest_coordinator_total_txs") {
 			parts := strings.Split(line, " ")
@@ -335,6 +402,31 @@ func getPrometheusStats(t *testing.T, port int) prometheusStats {
 				t.Fatal(err)
 			}
 
+		} else if strings.HasPrefix(line, `tmloadtest_coordinator_worker_txs{worker="`) {
+			// e.g. tmloadtest_coordinator_worker_txs{worker="abc"} 50
+			labels, value, ok := strings.Cut(strings.TrimPrefix(line, `tmloadtest_coordinator_worker_txs{worker="`), `"} `)
+			if !ok {
+				t.Fatal("Invalid Prometheus metrics format")
+			}
+			txs, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stats.workerTxs[labels] = int(txs)
+
+		} else if strings.HasPrefix(line, "tmloadtest_coordinator_worker_info{") {
+			// e.g. tmloadtest_coordinator_worker_info{role="first",worker="abc"} 1
+			labels, _, ok := strings.Cut(strings.TrimPrefix(line, "tmloadtest_coordinator_worker_info{"), "} ")
+			if !ok {
+				t.Fatal("Invalid Prometheus metrics format")
+			}
+			values := make(map[string]string)
+			for _, pair := range strings.Split(labels, ",") {
+				name, value, _ := strings.Cut(pair, "=")
+				values[name] = strings.Trim(value, `"`)
+			}
+			stats.workerRoles[values["worker"]] = values["role"]
+
 		} else if strings.HasPrefix(line, "tmloadtest_coordinator_total_bytes") {
 			parts := strings.Split(line, " ")
 			if len(parts) < 2 {
@@ -0,0 +1,99 @@
+package loadtest
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Samples ending less than this fraction of a window into a new window are
+// attributed to the previous window, since they are most likely the result of
+// timer jitter (or a final sample taken just after the previous window ended).
+const intervalWindowTolerance = 0.1
+
+// intervalTxCounts tracks the number of transactions sent during each
+// consecutive window of a load test, from which the variability of the
+// transaction rate over the course of the load test can be determined.
+type intervalTxCounts struct {
+	window time.Duration
+
+	mtx    sync.Mutex
+	counts []int // The number of transactions sent during each window, in order.
+}
+
+func newIntervalTxCounts(window time.Duration) *intervalTxCounts {
+	return &intervalTxCounts{window: window}
+}
+
+// add attributes the given timeseries sample to the window in which it ended.
+func (c *intervalTxCounts) add(s timeseriesSample) {
+	i := int(math.Ceil(s.TSeconds/c.window.Seconds()-intervalWindowTolerance)) - 1
+	if i < 0 {
+		i = 0
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for len(c.counts) <= i {
+		c.counts = append(c.counts, 0)
+	}
+	c.counts[i] += s.Txs
+}
+
+// Counts returns a copy of the transaction counts per window.
+func (c *intervalTxCounts) Counts() []int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return append([]int(nil), c.counts...)
+}
+
+// mergeIntervalTxCounts sums the given sets of transaction counts per window
+// (e.g. from different workers), window by window.
+func mergeIntervalTxCounts(sets ...[]int) []int {
+	var merged []int
+	for _, counts := range sets {
+		for i, count := range counts {
+			if i >= len(merged) {
+				merged = append(merged, 0)
+			}
+			merged[i] += count
+		}
+	}
+	return merged
+}
+
+// IntervalRateStats summarizes the variability of the transaction rate over
+// the windows of a load test.
+type IntervalRateStats struct {
+	Peak   float64 // The highest rate (tx/sec) achieved during any window.
+	Min    float64 // The lowest rate (tx/sec) achieved during any window.
+	StdDev float64 // The standard deviation of the rates achieved during each window.
+}
+
+// intervalRateStats computes the peak, minimum and standard deviation of the
+// transaction rates over the given windows. The first and last windows, during
+// which the load test ramps up and down (and the last of which is usually only
+// partially covered), are excluded, unless there are too few windows to do so.
+func intervalRateStats(counts []int, window time.Duration) IntervalRateStats {
+	if len(counts) > 2 {
+		counts = counts[1 : len(counts)-1]
+	}
+	if len(counts) == 0 || window <= 0 {
+		return IntervalRateStats{}
+	}
+	stats := IntervalRateStats{Peak: math.Inf(-1), Min: math.Inf(1)}
+	var sum float64
+	for _, count := range counts {
+		rate := float64(count) / window.Seconds()
+		stats.Peak = math.Max(stats.Peak, rate)
+		stats.Min = math.Min(stats.Min, rate)
+		sum += rate
+	}
+	mean := sum / float64(len(counts))
+	var sqDiffs float64
+	for _, count := range counts {
+		d := float64(count)/window.Seconds() - mean
+		sqDiffs += d * d
+	}
+	stats.StdDev = math.Sqrt(sqDiffs / float64(len(counts)))
+	return stats
+}
@@ -0,0 +1,73 @@
+package loadtest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntervalTxCounts(t *testing.T) {
+	c := newIntervalTxCounts(time.Second)
+	for _, s := range []timeseriesSample{
+		{TSeconds: 1.002, Txs: 10},
+		{TSeconds: 2.001, Txs: 20},
+		{TSeconds: 2.999, Txs: 30},
+		// a final sample taken just after the previous window ended
+		{TSeconds: 3.01, Txs: 1},
+	} {
+		c.add(s)
+	}
+	assert.Equal(t, []int{10, 20, 31}, c.Counts())
+
+	// a partial final window
+	c.add(timeseriesSample{TSeconds: 3.5, Txs: 5})
+	assert.Equal(t, []int{10, 20, 31, 5}, c.Counts())
+}
+
+func TestMergeIntervalTxCounts(t *testing.T) {
+	assert.Nil(t, mergeIntervalTxCounts())
+	assert.Equal(
+		t,
+		[]int{15, 30, 45, 5},
+		mergeIntervalTxCounts([]int{10, 20, 30, 5}, []int{5, 10, 15}),
+	)
+}
+
+func TestIntervalRateStats(t *testing.T) {
+	testCases := []struct {
+		name   string
+		counts []int
+		window time.Duration
+		expect IntervalRateStats
+	}{
+		{"no windows", nil, time.Second, IntervalRateStats{}},
+		{"single window", []int{100}, time.Second, IntervalRateStats{Peak: 100, Min: 100}},
+		{"ramp windows excluded", []int{5000, 2000, 2000, 50, 50, 1}, time.Second, IntervalRateStats{Peak: 2000, Min: 50, StdDev: 975}},
+		{"longer window", []int{0, 200, 400, 0}, 2 * time.Second, IntervalRateStats{Peak: 200, Min: 100, StdDev: 50}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := intervalRateStats(tc.counts, tc.window)
+			assert.InDelta(t, tc.expect.Peak, actual.Peak, 1e-9)
+			assert.InDelta(t, tc.expect.Min, actual.Min, 1e-9)
+			assert.InDelta(t, tc.expect.StdDev, actual.StdDev, 1e-9)
+		})
+	}
+}
+
+func TestIntervalRateStatsAcrossWorkers(t *testing.T) {
+	// two workers whose rates peak at different times, such that the merged
+	// rates are 110, 200, 110 and 20 tx/sec after excluding the ramp windows
+	merged := mergeIntervalTxCounts(
+		[]int{10, 100, 100, 10, 10, 3},
+		[]int{10, 10, 100, 100, 10},
+	)
+	stats := intervalRateStats(merged, time.Second)
+	assert.InDelta(t, 200, stats.Peak, 1e-9)
+	assert.InDelta(t, 20, stats.Min, 1e-9)
+	require.False(t, math.IsNaN(stats.StdDev))
+	assert.InDelta(t, math.Sqrt((0+90*90+0+90*90)/4.0), stats.StdDev, 1e-9)
+}
@@ -0,0 +1,194 @@
+// Package k8s adds k8s:// endpoints to tm-load-test, which are expanded into
+// the ready pods matching a label selector, as listed through the Kubernetes
+// API. Importing the package registers its endpoint resolver:
+//
+//	import _ "github.com/informalsystems/tm-load-test/pkg/loadtest/k8s"
+//
+// It lives in its own package so that programs that embed the loadtest
+// package without it don't depend on the Kubernetes client libraries.
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Scheme prefix of endpoints that are expanded into the pods matching a label
+// selector, as listed through the Kubernetes API, e.g.
+// k8s://validators/app=validator:26657 for the pods labelled app=validator in
+// the validators namespace, with their RPC endpoints on port 26657.
+const k8sEndpointPrefix = "k8s://"
+
+const (
+	defaultRPCPort       = "26657"
+	defaultWebSocketPath = "/websocket"
+)
+
+func init() {
+	loadtest.RegisterEndpointResolver(&k8sEndpointResolver{newClient: newK8sClientFromEnv})
+}
+
+// k8sPod is a pod as listed through the Kubernetes API.
+type k8sPod struct {
+	Name  string
+	IP    string
+	Ready bool // Whether the pod is running, ready and not being deleted.
+}
+
+// k8sPodLister lists the pods in a namespace that match a label selector.
+type k8sPodLister interface {
+	ListPods(ctx context.Context, namespace, labelSelector string) ([]k8sPod, error)
+}
+
+// k8sEndpointResolver expands Kubernetes endpoints into the WebSockets
+// endpoints of the ready pods they select. The Kubernetes API client is only
+// created once a Kubernetes endpoint is resolved.
+type k8sEndpointResolver struct {
+	newClient func() (k8sPodLister, error)
+
+	mtx    sync.Mutex
+	client k8sPodLister
+}
+
+var _ loadtest.EndpointResolver = (*k8sEndpointResolver)(nil)
+
+func (r *k8sEndpointResolver) Handles(endpoint string) bool {
+	return strings.HasPrefix(endpoint, k8sEndpointPrefix)
+}
+
+func (r *k8sEndpointResolver) Resolve(ctx context.Context, endpoint string) ([]string, error) {
+	namespace, selector, port, err := parseK8sEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	client, err := r.getClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Kubernetes API client for endpoint %s: %w", endpoint, err)
+	}
+	pods, err := client.ListPods(ctx, namespace, selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods for endpoint %s: %w", endpoint, err)
+	}
+	// pods that aren't ready yet are picked up by re-discovery once they are
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	endpoints := make([]string, 0, len(pods))
+	for _, pod := range pods {
+		if pod.Ready && len(pod.IP) > 0 {
+			endpoints = append(endpoints, fmt.Sprintf("ws://%s%s", net.JoinHostPort(pod.IP, port), defaultWebSocketPath))
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no ready pods found matching %s in namespace %s (endpoint %s)", selector, namespace, endpoint)
+	}
+	return endpoints, nil
+}
+
+func (r *k8sEndpointResolver) getClient() (k8sPodLister, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.client == nil {
+		client, err := r.newClient()
+		if err != nil {
+			return nil, err
+		}
+		r.client = client
+	}
+	return r.client, nil
+}
+
+// parseK8sEndpoint returns the namespace, label selector and RPC port of the
+// given Kubernetes endpoint, of the form k8s://namespace/selector[:port]. The
+// port defaults to 26657.
+func parseK8sEndpoint(endpoint string) (string, string, string, error) {
+	rest := strings.TrimPrefix(endpoint, k8sEndpointPrefix)
+	namespace, selector, ok := strings.Cut(rest, "/")
+	if !ok || len(namespace) == 0 || len(selector) == 0 {
+		return "", "", "", fmt.Errorf("invalid Kubernetes endpoint %s: expected %snamespace/label=value[:port]", endpoint, k8sEndpointPrefix)
+	}
+	port := defaultRPCPort
+	if i := strings.LastIndex(selector, ":"); i >= 0 {
+		if _, err := strconv.ParseUint(selector[i+1:], 10, 16); err != nil {
+			return "", "", "", fmt.Errorf("invalid Kubernetes endpoint %s: invalid port \"%s\"", endpoint, selector[i+1:])
+		}
+		selector, port = selector[:i], selector[i+1:]
+	}
+	if len(selector) == 0 {
+		return "", "", "", fmt.Errorf("invalid Kubernetes endpoint %s: missing label selector", endpoint)
+	}
+	return namespace, selector, port, nil
+}
+
+// k8sClient lists pods through the Kubernetes API with client-go.
+type k8sClient struct {
+	host      string // e.g. https://10.96.0.1:443
+	clientset kubernetes.Interface
+}
+
+var _ k8sPodLister = (*k8sClient)(nil)
+
+func (c *k8sClient) ListPods(ctx context.Context, namespace, labelSelector string) ([]k8sPod, error) {
+	list, err := c.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	switch {
+	case err == nil:
+	case apierrors.IsUnauthorized(err):
+		return nil, fmt.Errorf("not authenticated by the Kubernetes API at %s: %w (check the credentials of the current kubeconfig context)", c.host, err)
+	case apierrors.IsForbidden(err):
+		return nil, fmt.Errorf("not permitted to list pods in namespace %s: %w (the service account or user needs the \"list\" permission on \"pods\", e.g. through a Role and RoleBinding)", namespace, err)
+	default:
+		return nil, fmt.Errorf("failed to list pods in namespace %s through the Kubernetes API at %s: %w", namespace, c.host, err)
+	}
+	pods := make([]k8sPod, 0, len(list.Items))
+	for _, item := range list.Items {
+		ready := false
+		for _, cond := range item.Status.Conditions {
+			if cond.Type == corev1.PodReady {
+				ready = cond.Status == corev1.ConditionTrue
+			}
+		}
+		pods = append(pods, k8sPod{
+			Name:  item.Name,
+			IP:    item.Status.PodIP,
+			Ready: ready && item.Status.Phase == corev1.PodRunning && item.DeletionTimestamp == nil,
+		})
+	}
+	return pods, nil
+}
+
+// newK8sClientFromEnv creates a Kubernetes API client for the current context
+// of the kubeconfig files given by KUBECONFIG, or of ~/.kube/config, or else
+// with the service account of the pod in which we're running, as kubectl
+// does.
+func newK8sClientFromEnv() (k8sPodLister, error) {
+	return newK8sClient(clientcmd.NewDefaultClientConfigLoadingRules())
+}
+
+// newK8sClientFromKubeconfig creates a Kubernetes API client for the current
+// context of the given kubeconfig file.
+func newK8sClientFromKubeconfig(path string) (*k8sClient, error) {
+	return newK8sClient(&clientcmd.ClientConfigLoadingRules{ExplicitPath: path})
+}
+
+func newK8sClient(loadingRules *clientcmd.ClientConfigLoadingRules) (*k8sClient, error) {
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.UserAgent = "tm-load-test/" + loadtest.Version()
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &k8sClient{host: config.Host, clientset: clientset}, nil
+}
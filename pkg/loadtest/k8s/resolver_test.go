@@ -0,0 +1,221 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePodLister lists a fixed set of pods, regardless of the namespace and
+// selector.
+type fakePodLister struct {
+	pods []k8sPod
+	err  error
+}
+
+func (l *fakePodLister) ListPods(context.Context, string, string) ([]k8sPod, error) {
+	return append([]k8sPod(nil), l.pods...), l.err
+}
+
+func newFakeK8sResolver(lister *fakePodLister) *k8sEndpointResolver {
+	return &k8sEndpointResolver{newClient: func() (k8sPodLister, error) { return lister, nil }}
+}
+
+func TestK8sEndpointResolver(t *testing.T) {
+	lister := &fakePodLister{pods: []k8sPod{
+		{Name: "validator-1", IP: "10.0.0.2", Ready: true},
+		{Name: "validator-0", IP: "10.0.0.1", Ready: true},
+		{Name: "validator-2", IP: "10.0.0.3"},
+		{Name: "sentry-0", IP: "fd00::1", Ready: true},
+	}}
+	resolver := newFakeK8sResolver(lister)
+	const endpoint = "k8s://validators/app=validator:26667"
+	assert.True(t, resolver.Handles(endpoint))
+	assert.False(t, resolver.Handles("ws://10.0.0.1:26657/websocket"))
+
+	// pods that aren't ready are left out, and the others are ordered by name
+	endpoints, err := resolver.Resolve(context.Background(), endpoint)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ws://[fd00::1]:26667/websocket",
+		"ws://10.0.0.1:26667/websocket",
+		"ws://10.0.0.2:26667/websocket",
+	}, endpoints)
+
+	// as pods churn, resolving again reflects the current pods
+	lister.pods[2].Ready = true
+	lister.pods = lister.pods[1:]
+	endpoints, err = resolver.Resolve(context.Background(), endpoint)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"ws://[fd00::1]:26667/websocket",
+		"ws://10.0.0.1:26667/websocket",
+		"ws://10.0.0.3:26667/websocket",
+	}, endpoints)
+
+	lister.pods = []k8sPod{{Name: "validator-0", IP: "10.0.0.1"}}
+	_, err = resolver.Resolve(context.Background(), endpoint)
+	assert.ErrorContains(t, err, "no ready pods found matching app=validator in namespace validators")
+}
+
+func TestParseK8sEndpoint(t *testing.T) {
+	testCases := []struct {
+		endpoint          string
+		expectedNamespace string
+		expectedSelector  string
+		expectedPort      string
+		expectError       bool
+	}{
+		{"k8s://validators/app=validator:26657", "validators", "app=validator", "26657", false},
+		{"k8s://validators/app=validator", "validators", "app=validator", "26657", false},
+		{"k8s://default/app.kubernetes.io/name=cometbft:443", "default", "app.kubernetes.io/name=cometbft", "443", false},
+		{"k8s://validators", "", "", "", true},
+		{"k8s:///app=validator", "", "", "", true},
+		{"k8s://validators/:26657", "", "", "", true},
+		{"k8s://validators/app=validator:rpc", "", "", "", true},
+	}
+	for _, tc := range testCases {
+		namespace, selector, port, err := parseK8sEndpoint(tc.endpoint)
+		if tc.expectError {
+			assert.Error(t, err, tc.endpoint)
+			continue
+		}
+		require.NoError(t, err, tc.endpoint)
+		assert.Equal(t, tc.expectedNamespace, namespace, tc.endpoint)
+		assert.Equal(t, tc.expectedSelector, selector, tc.endpoint)
+		assert.Equal(t, tc.expectedPort, port, tc.endpoint)
+	}
+}
+
+const k8sTestPodList = `{"kind":"PodList","apiVersion":"v1","items":[
+	{"metadata":{"name":"validator-0"},"status":{"phase":"Running","podIP":"10.0.0.1","conditions":[{"type":"Ready","status":"True"}]}},
+	{"metadata":{"name":"validator-1"},"status":{"phase":"Pending","podIP":"10.0.0.2"}},
+	{"metadata":{"name":"validator-2"},"status":{"phase":"Running","podIP":"10.0.0.3","conditions":[{"type":"Ready","status":"False"}]}},
+	{"metadata":{"name":"validator-3","deletionTimestamp":"2024-01-01T00:00:00Z"},"status":{"phase":"Running","podIP":"10.0.0.4","conditions":[{"type":"Ready","status":"True"}]}}
+]}`
+
+// newK8sAPIServer returns a fake Kubernetes API server that lists the test
+// pods to requests with the given bearer token, and otherwise forbids it.
+func newK8sAPIServer(t *testing.T, token string) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/validators/pods" || r.URL.Query().Get("labelSelector") != "app=validator" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","message":"pods is forbidden: User \"system:serviceaccount:loadtest:default\" cannot list resource \"pods\" in API group \"\" in the namespace \"validators\"","reason":"Forbidden","code":403}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, k8sTestPodList)
+	})
+	svr := httptest.NewTLSServer(handler)
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func writeKubeconfig(t *testing.T, svr *httptest.Server, token string) string {
+	return writeKubeconfigWithUser(t, svr, "token: "+token)
+}
+
+// writeKubeconfigWithUser writes a kubeconfig for the given fake Kubernetes API
+// server, whose current context's user is given in YAML.
+func writeKubeconfigWithUser(t *testing.T, svr *httptest.Server, user string) string {
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: svr.Certificate().Raw})
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: loadtest
+contexts:
+- name: other
+  context: {cluster: other, user: other}
+- name: loadtest
+  context: {cluster: test, user: test}
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+users:
+- name: test
+  user:
+    %s
+`, svr.URL, base64.StdEncoding.EncodeToString(ca), user)), 0o600))
+	return path
+}
+
+func TestK8sClientFromKubeconfig(t *testing.T) {
+	svr := newK8sAPIServer(t, "secret")
+	client, err := newK8sClientFromKubeconfig(writeKubeconfig(t, svr, "secret"))
+	require.NoError(t, err)
+	pods, err := client.ListPods(context.Background(), "validators", "app=validator")
+	require.NoError(t, err)
+	assert.Equal(t, []k8sPod{
+		{Name: "validator-0", IP: "10.0.0.1", Ready: true},
+		{Name: "validator-1", IP: "10.0.0.2"},
+		{Name: "validator-2", IP: "10.0.0.3"},
+		{Name: "validator-3", IP: "10.0.0.4"},
+	}, pods)
+
+	// missing permissions are reported clearly
+	client, err = newK8sClientFromKubeconfig(writeKubeconfig(t, svr, "other"))
+	require.NoError(t, err)
+	t.Setenv("KUBECONFIG", writeKubeconfig(t, svr, "other"))
+	// the resolver registered with the loadtest package is used
+	cfg := loadtest.Config{Endpoints: []string{"k8s://validators/app=validator"}}
+	err = cfg.ResolveEndpoints()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "k8s://validators/app=validator")
+	assert.Contains(t, err.Error(), "not permitted to list pods in namespace validators")
+	assert.Contains(t, err.Error(), `cannot list resource "pods"`)
+	_, err = client.ListPods(context.Background(), "validators", "app=validator")
+	assert.ErrorContains(t, err, "Role and RoleBinding")
+}
+
+func TestK8sClientExecPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the credential plugin is a shell script")
+	}
+	svr := newK8sAPIServer(t, "plugin-token")
+	plugin := filepath.Join(t.TempDir(), "credential-plugin")
+	require.NoError(t, os.WriteFile(plugin, []byte(`#!/bin/sh
+echo '{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"plugin-token"}}'
+`), 0o700))
+	client, err := newK8sClientFromKubeconfig(writeKubeconfigWithUser(t, svr, fmt.Sprintf(
+		"exec: {apiVersion: client.authentication.k8s.io/v1, command: %s, interactiveMode: Never}", plugin,
+	)))
+	require.NoError(t, err)
+	pods, err := client.ListPods(context.Background(), "validators", "app=validator")
+	require.NoError(t, err)
+	assert.Len(t, pods, 4)
+}
+
+func TestK8sClientFromKubeconfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	testCases := []struct {
+		kubeconfig  string
+		expectError string
+	}{
+		{"clusters: []", "no configuration has been provided"},
+		{"current-context: missing", "context was not found for specified context: missing"},
+		{"current-context: a\ncontexts: [{name: a, context: {cluster: c, user: u}}]\nclusters: [{name: c, cluster: {}}]", `no server found for cluster "c"`},
+	}
+	for i, tc := range testCases {
+		path := filepath.Join(dir, fmt.Sprintf("config%d", i))
+		require.NoError(t, os.WriteFile(path, []byte(tc.kubeconfig), 0o600))
+		_, err := newK8sClientFromKubeconfig(path)
+		assert.ErrorContains(t, err, tc.expectError, tc.kubeconfig)
+	}
+}
@@ -0,0 +1,101 @@
+package loadtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// The default maximum number of raw latency samples to retain.
+const defaultLatencySampleCap = 100000
+
+var latencySamplesHeader = []string{"send_unix_nanos", "latency_micros"}
+
+// latencySample is a single raw broadcast latency measurement.
+type latencySample struct {
+	sentAt  time.Time
+	latency time.Duration
+}
+
+// latencyReservoir retains a uniform random sample of bounded size of all of
+// the broadcast latencies offered to it (using reservoir sampling), such that
+// its memory usage doesn't grow with the length of the load test.
+type latencyReservoir struct {
+	capacity int
+	rate     float64 // The fraction of latencies to offer to the reservoir.
+
+	mtx     sync.Mutex
+	rnd     *rand.Rand
+	seen    int // The number of latencies offered to the reservoir thus far.
+	samples []latencySample
+}
+
+func newLatencyReservoir(capacity int, rate float64) *latencyReservoir {
+	return &latencyReservoir{
+		capacity: capacity,
+		rate:     rate,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		samples:  make([]latencySample, 0, capacity),
+	}
+}
+
+// Add offers the latency of a transaction sent at the given time to the
+// reservoir.
+func (r *latencyReservoir) Add(sentAt time.Time, latency time.Duration) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.rate < 1 && r.rnd.Float64() >= r.rate {
+		return
+	}
+	r.seen++
+	if len(r.samples) < r.capacity {
+		r.samples = append(r.samples, latencySample{sentAt: sentAt, latency: latency})
+		return
+	}
+	// the n-th latency replaces a random sample with probability cap/n
+	if i := r.rnd.Intn(r.seen); i < r.capacity {
+		r.samples[i] = latencySample{sentAt: sentAt, latency: latency}
+	}
+}
+
+// Samples returns a copy of the retained samples, in order of send time.
+func (r *latencyReservoir) Samples() []latencySample {
+	r.mtx.Lock()
+	samples := append([]latencySample(nil), r.samples...)
+	r.mtx.Unlock()
+	sort.Slice(samples, func(i, j int) bool { return samples[i].sentAt.Before(samples[j].sentAt) })
+	return samples
+}
+
+// writeLatencySamples writes the given raw latency samples to the specified
+// CSV file.
+func writeLatencySamples(filename string, samples []latencySample) error {
+	filename, err := prepareOutputFile(filename)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(latencySamplesHeader); err != nil {
+		return err
+	}
+	for _, s := range samples {
+		if err := w.Write([]string{
+			fmt.Sprintf("%d", s.sentAt.UnixNano()),
+			fmt.Sprintf("%d", s.latency.Microseconds()),
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
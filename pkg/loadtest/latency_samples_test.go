@@ -0,0 +1,76 @@
+package loadtest
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyReservoirCap(t *testing.T) {
+	r := newLatencyReservoir(1000, 1)
+	base := time.Now()
+	for i := 0; i < 100000; i++ {
+		r.Add(base.Add(time.Duration(i)*time.Millisecond), time.Duration(i)*time.Microsecond)
+	}
+	samples := r.Samples()
+	require.Len(t, samples, 1000)
+	assert.Equal(t, 1000, cap(r.samples))
+	assert.True(t, sort.SliceIsSorted(samples, func(i, j int) bool { return samples[i].sentAt.Before(samples[j].sentAt) }))
+}
+
+func TestLatencyReservoirUniformity(t *testing.T) {
+	const (
+		offered  = 200000
+		capacity = 20000
+		buckets  = 10
+	)
+	r := newLatencyReservoir(capacity, 1)
+	base := time.Now()
+	for i := 0; i < offered; i++ {
+		r.Add(base.Add(time.Duration(i)*time.Millisecond), time.Millisecond)
+	}
+	// each tenth of the run should be equally represented
+	counts := make([]int, buckets)
+	for _, s := range r.Samples() {
+		i := int(s.sentAt.Sub(base)/time.Millisecond) * buckets / offered
+		counts[i]++
+	}
+	for i, count := range counts {
+		assert.InDelta(t, capacity/buckets, count, capacity/buckets*0.1, "bucket %d", i)
+	}
+}
+
+func TestLatencyReservoirRate(t *testing.T) {
+	r := newLatencyReservoir(100000, 0.25)
+	base := time.Now()
+	for i := 0; i < 40000; i++ {
+		r.Add(base, time.Millisecond)
+	}
+	assert.InDelta(t, 10000, len(r.Samples()), 500)
+}
+
+func TestWriteLatencySamples(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "latencies.csv")
+	sentAt := time.Unix(1700000000, 123456789)
+	require.NoError(t, writeLatencySamples(filename, []latencySample{
+		{sentAt: sentAt, latency: 1500 * time.Microsecond},
+		{sentAt: sentAt.Add(time.Second), latency: 42 * time.Microsecond},
+	}))
+
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"send_unix_nanos", "latency_micros"},
+		{"1700000000123456789", "1500"},
+		{"1700000001123456789", "42"},
+	}, records)
+}
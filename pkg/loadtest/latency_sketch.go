@@ -0,0 +1,192 @@
+package loadtest
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// The relative accuracy of the quantiles produced by a latencySketch.
+	latencySketchAccuracy = 0.01
+
+	// Latencies below this value are all counted in the lowest bucket.
+	latencySketchMinValue = 1e-6 // 1 microsecond
+)
+
+var latencySketchGamma = (1 + latencySketchAccuracy) / (1 - latencySketchAccuracy)
+
+// LatencyStats summarizes a distribution of latencies, in seconds.
+type LatencyStats struct {
+	Count int     `json:"count"` // The number of latency samples taken.
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// latencySketch is a mergeable, fixed-accuracy streaming quantile sketch for
+// latencies. Samples are counted in logarithmically sized buckets, so memory
+// usage only depends on the range of latencies observed and not on the
+// number of samples.
+type latencySketch struct {
+	Counts map[int]uint64 `json:"counts"` // Sample counts, keyed by bucket index.
+	Count  uint64         `json:"count"`  // The total number of samples.
+	Max    float64        `json:"max"`    // The largest sample (in seconds).
+}
+
+func newLatencySketch() *latencySketch {
+	return &latencySketch{
+		Counts: make(map[int]uint64),
+	}
+}
+
+// Add records the given latency sample.
+func (s *latencySketch) Add(d time.Duration) {
+	v := d.Seconds()
+	s.Counts[latencySketchIndex(v)]++
+	s.Count++
+	if v > s.Max {
+		s.Max = v
+	}
+}
+
+// Merge adds all of the samples from the given sketch to this one.
+func (s *latencySketch) Merge(other *latencySketch) {
+	if other == nil {
+		return
+	}
+	for idx, count := range other.Counts {
+		s.Counts[idx] += count
+	}
+	s.Count += other.Count
+	if other.Max > s.Max {
+		s.Max = other.Max
+	}
+}
+
+// Copy returns a deep copy of this sketch.
+func (s *latencySketch) Copy() *latencySketch {
+	c := newLatencySketch()
+	c.Merge(s)
+	return c
+}
+
+// Diff returns a sketch of the samples in this sketch that aren't in the given
+// earlier version of it (which may be nil), or nil if there are none. The
+// difference keeps this sketch's maximum, since merging it into the earlier
+// version must reproduce this sketch.
+func (s *latencySketch) Diff(base *latencySketch) *latencySketch {
+	if s == nil {
+		return nil
+	}
+	if base == nil {
+		base = newLatencySketch()
+	}
+	if s.Count <= base.Count {
+		return nil
+	}
+	d := newLatencySketch()
+	for idx, count := range s.Counts {
+		if count > base.Counts[idx] {
+			d.Counts[idx] = count - base.Counts[idx]
+		}
+	}
+	d.Count = s.Count - base.Count
+	d.Max = s.Max
+	return d
+}
+
+// Quantile returns an estimate of the latency (in seconds) at the given
+// quantile (between 0 and 1).
+func (s *latencySketch) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	indices := make([]int, 0, len(s.Counts))
+	for idx := range s.Counts {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+	rank := uint64(math.Ceil(q * float64(s.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for _, idx := range indices {
+		seen += s.Counts[idx]
+		if seen >= rank {
+			return math.Min(latencySketchValue(idx), s.Max)
+		}
+	}
+	return s.Max
+}
+
+// Stats summarizes the sketch's distribution.
+func (s *latencySketch) Stats() *LatencyStats {
+	return &LatencyStats{
+		Count: int(s.Count),
+		P50:   s.Quantile(0.5),
+		P90:   s.Quantile(0.9),
+		P95:   s.Quantile(0.95),
+		P99:   s.Quantile(0.99),
+		Max:   s.Max,
+	}
+}
+
+func latencySketchIndex(v float64) int {
+	if v <= latencySketchMinValue {
+		return latencySketchIndex(latencySketchMinValue * latencySketchGamma)
+	}
+	return int(math.Ceil(math.Log(v) / math.Log(latencySketchGamma)))
+}
+
+// Returns the representative value of the bucket with the given index, which
+// is within the sketch's relative accuracy of every value in the bucket.
+func latencySketchValue(idx int) float64 {
+	return 2 * math.Pow(latencySketchGamma, float64(idx)) / (latencySketchGamma + 1)
+}
+
+// latencyHistogramCollector exposes a latencySketch as a Prometheus histogram
+// with the given buckets, computed at collection time.
+type latencyHistogramCollector struct {
+	desc    *prometheus.Desc
+	buckets []float64
+	sketch  func() *latencySketch // Must return a sketch that is safe to read.
+}
+
+var _ prometheus.Collector = (*latencyHistogramCollector)(nil)
+
+func newLatencyHistogramCollector(name, help string, buckets []float64, sketch func() *latencySketch) *latencyHistogramCollector {
+	return &latencyHistogramCollector{
+		desc:    prometheus.NewDesc(name, help, nil, nil),
+		buckets: buckets,
+		sketch:  sketch,
+	}
+}
+
+func (c *latencyHistogramCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *latencyHistogramCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.sketch()
+	if s == nil {
+		s = newLatencySketch()
+	}
+	sum := float64(0)
+	bucketCounts := make(map[float64]uint64, len(c.buckets))
+	for idx, count := range s.Counts {
+		v := latencySketchValue(idx)
+		sum += v * float64(count)
+		for _, upperBound := range c.buckets {
+			if v <= upperBound {
+				bucketCounts[upperBound] += count
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(c.desc, s.Count, sum, bucketCounts)
+}
@@ -0,0 +1,38 @@
+package loadtest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencySketchQuantiles(t *testing.T) {
+	a, b := newLatencySketch(), newLatencySketch()
+	// 1ms through 1000ms, split across two sketches
+	for i := 1; i <= 1000; i++ {
+		if i%2 == 0 {
+			a.Add(time.Duration(i) * time.Millisecond)
+		} else {
+			b.Add(time.Duration(i) * time.Millisecond)
+		}
+	}
+	a.Merge(b)
+	stats := a.Stats()
+	assert.Equal(t, 1000, stats.Count)
+	for _, tc := range []struct{ actual, expected float64 }{
+		{stats.P50, 0.5},
+		{stats.P90, 0.9},
+		{stats.P95, 0.95},
+		{stats.P99, 0.99},
+		{stats.Max, 1.0},
+	} {
+		assert.LessOrEqual(t, math.Abs(tc.actual-tc.expected)/tc.expected, latencySketchAccuracy*1.01, "expected ~%f, got %f", tc.expected, tc.actual)
+	}
+	// memory is bounded by the range of values, not the number of samples
+	for i := 0; i < 100000; i++ {
+		a.Add(time.Duration(1+i%1000) * time.Millisecond)
+	}
+	assert.Less(t, len(a.Counts), 400)
+}
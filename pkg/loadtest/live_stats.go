@@ -0,0 +1,119 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// LiveStats is the coordinator's current aggregated view of a load test, as
+// served as JSON at its /stats endpoint.
+type LiveStats struct {
+	State            string            `json:"state"`             // Either "testing", "paused", "completed" or "cancelled".
+	ElapsedSeconds   float64           `json:"elapsed_seconds"`   // The time elapsed since the load test started (or until it completed).
+	TotalTxs         int               `json:"total_txs"`         // The total number of transactions sent thus far across all workers.
+	TotalBytes       int64             `json:"total_bytes"`       // The total number of transaction bytes sent thus far across all workers.
+	Failures         int               `json:"failures"`          // The total number of error responses received thus far across all workers.
+	Progress         float64           `json:"progress"`          // The fraction of the load test completed thus far, averaged across workers.
+	ConnectedWorkers int               `json:"connected_workers"` // The number of workers currently connected to the coordinator.
+	Workers          []LiveWorkerStats `json:"workers"`           // The latest view of each worker, ordered by ID.
+}
+
+// LiveWorkerStats is the coordinator's latest view of a single worker.
+type LiveWorkerStats struct {
+	ID         string  `json:"id"`          // The worker's unique ID.
+	State      string  `json:"state"`       // The worker's latest reported state.
+	Connected  bool    `json:"connected"`   // Whether the worker is still connected to the coordinator.
+	TotalTxs   int     `json:"total_txs"`   // The total number of transactions sent thus far by the worker.
+	TotalBytes int64   `json:"total_bytes"` // The total number of transaction bytes sent thus far by the worker.
+	Failures   int     `json:"failures"`    // The total number of error responses received thus far by the worker.
+	Progress   float64 `json:"progress"`    // The fraction of its load test that the worker has completed thus far.
+}
+
+// publishLiveStats updates the view of the load test served at /stats. Must
+// only be called from the coordinator's event loop, once the load test has
+// started.
+func (c *Coordinator) publishLiveStats(completed bool) {
+	stats := &LiveStats{
+		State:            string(workerTesting),
+		ConnectedWorkers: len(c.workers),
+		Workers:          make([]LiveWorkerStats, 0, len(c.totalTxsPerWorker)),
+	}
+	if c.pauseClk.isPaused() {
+		stats.State = string(workerPaused)
+	}
+	if completed {
+		stats.State = string(workerCompleted)
+		if c.cancelling {
+			stats.State = string(workerCancelled)
+		}
+		stats.ElapsedSeconds = time.Since(c.startTime).Seconds()
+	}
+	for id, totalTxs := range c.totalTxsPerWorker {
+		_, connected := c.workers[id]
+		progress := c.progressPerWorker[id]
+		ws := LiveWorkerStats{
+			ID:         id,
+			State:      string(c.statePerWorker[id]),
+			Connected:  connected,
+			TotalTxs:   totalTxs,
+			TotalBytes: c.totalBytesPerWorker[id],
+			Failures:   progress.Failures,
+			Progress:   progress.Ratio,
+		}
+		stats.TotalTxs += ws.TotalTxs
+		stats.TotalBytes += ws.TotalBytes
+		stats.Failures += ws.Failures
+		stats.Progress += ws.Progress
+		stats.Workers = append(stats.Workers, ws)
+	}
+	if participants := c.participants(); participants > 0 {
+		stats.Progress /= float64(participants)
+	}
+	sort.Slice(stats.Workers, func(i, j int) bool { return stats.Workers[i].ID < stats.Workers[j].ID })
+
+	c.liveMtx.Lock()
+	c.liveStats = stats
+	c.liveStartTime = c.startTime
+	c.liveMtx.Unlock()
+}
+
+// setConnectedWorkers records the number of workers connected to the
+// coordinator, to report while waiting for workers.
+func (c *Coordinator) setConnectedWorkers(count int) {
+	c.liveMtx.Lock()
+	c.liveConnected = count
+	c.liveMtx.Unlock()
+}
+
+// handleLiveStats serves the coordinator's current view of the load test as
+// JSON, or responds with 503 if the load test hasn't started yet.
+func (c *Coordinator) handleLiveStats(w http.ResponseWriter, r *http.Request) {
+	stats, connected := c.currentLiveStats()
+	w.Header().Set("Content-Type", "application/json")
+	if stats == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("load test has not started yet (%d of %d workers connected)", connected, c.coordCfg.ExpectWorkers),
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(stats)
+}
+
+// currentLiveStats returns the current view of the load test (nil if it hasn't
+// started yet), along with the number of connected workers.
+func (c *Coordinator) currentLiveStats() (*LiveStats, int) {
+	c.liveMtx.Lock()
+	defer c.liveMtx.Unlock()
+	if c.liveStats == nil {
+		return nil, c.liveConnected
+	}
+	stats := *c.liveStats
+	if stats.State != string(workerCompleted) && stats.State != string(workerCancelled) {
+		stats.ElapsedSeconds = time.Since(c.liveStartTime).Seconds()
+	}
+	return &stats, c.liveConnected
+}
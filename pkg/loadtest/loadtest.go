@@ -1,68 +1,348 @@
 package loadtest
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
-	"github.com/informalsystems/tm-load-test/internal/logging"
+	uuid "github.com/satori/go.uuid"
 )
 
+// ErrLoadTestCancelled is returned when a load test is cancelled (e.g. by an
+// interrupt) before it completes. The statistics gathered up until then are
+// still written, marked as cancelled.
+var ErrLoadTestCancelled = errors.New("load test cancelled")
+
 // ExecuteStandalone will run a standalone (non-coordinator/worker) load test.
-func ExecuteStandalone(cfg Config) error {
-	logger := logging.NewLogrusLogger("loadtest")
+// Nothing is logged unless a logger is given via SetLogger or WithLogger.
+func ExecuteStandalone(cfg Config, opts ...Option) error {
+	return ExecuteStandaloneWithContext(context.Background(), cfg, opts...)
+}
+
+// ExecuteStandaloneWithContext runs a standalone load test like
+// ExecuteStandalone, but cancels it if the given context is cancelled, in
+// which case the statistics gathered until then are still written and
+// ErrLoadTestCancelled is returned.
+func ExecuteStandaloneWithContext(ctx context.Context, cfg Config, opts ...Option) error {
+	_, err := RunStandalone(ctx, cfg, opts...)
+	return err
+}
+
+// RunStandalone runs a standalone load test like ExecuteStandaloneWithContext,
+// and returns the report on its final statistics, so that they needn't be
+// written to (and parsed back from) a statistics output file, which is only
+// written if StatsOutputFile is set. The report is also returned along with
+// the error if the load test was cancelled (ErrLoadTestCancelled), or fell
+// short of MinSuccessRatio, but is nil if the load test failed before any
+// statistics were gathered, or for a dry run.
+func RunStandalone(ctx context.Context, cfg Config, opts ...Option) (report *Report, err error) {
+	o := newOptions(opts)
+	logger := newLogger(o.loggerFactory, "loadtest")
 
 	logger.Debug("Attempting standalone load test against endpoints", "endpoints", cfg.Endpoints)
 
+	if len(cfg.RunID) == 0 {
+		cfg.RunID = makeRunID()
+	}
+
+	// runs last, once everything else has been cleaned up
+	defer func() {
+		// a dry run has no results to report
+		if cfg.DryRun {
+			return
+		}
+		// the statistics, if available
+		var stats *AggregateStats
+		if report != nil {
+			stats = &report.Aggregate
+		}
+		notifyResultWebhook(cfg, stats, err, logger)
+		if cfg.PrintSummaryJSON {
+			printSummary(NewSummary(cfg, stats, err), logger)
+		}
+	}()
+
+	logEndpointPathDefaults(cfg.Endpoints, logger)
+	if err := cfg.ParseEndpointRateLimits(); err != nil {
+		logger.Error("Invalid endpoints", "err", err)
+		return nil, configError(err)
+	}
+	if err := cfg.ValidateOutputFiles(); err != nil {
+		logger.Error("Invalid output files", "err", err)
+		return nil, configError(err)
+	}
+	if err := cfg.ResolveEndpoints(); err != nil {
+		logger.Error("Failed to resolve endpoints", "err", err)
+		return nil, connectivityError(err)
+	}
+
 	// if we need to wait for the network to stabilize first
 	if cfg.ExpectPeers > 0 {
+		filter, err := newDiscoveryFilter(&cfg)
+		if err != nil {
+			logger.Error("Invalid discovery filter", "err", err)
+			return nil, configError(err)
+		}
 		peers, err := waitForNetworkPeers(
+			ctx,
 			cfg.Endpoints,
 			cfg.EndpointSelectMethod,
 			cfg.ExpectPeers,
 			cfg.MinConnectivity,
 			cfg.MaxEndpoints,
-			time.Duration(cfg.PeerConnectTimeout)*time.Second,
+			cfg.Seed,
+			filter,
+			time.Duration(cfg.PeerConnectTimeout),
+			time.Duration(cfg.PeerPollInterval),
 			logger,
 		)
 		if err != nil {
 			logger.Error("Failed while waiting for peers to connect", "err", err)
-			return err
+			return nil, connectivityError(err)
 		}
+		cfg.DiscoverySeeds = cfg.Endpoints
 		cfg.Endpoints = peers
 		logger.Debug("Updated list of endpoints for test", "endpoints", cfg.Endpoints)
 	}
 
+	excludedEndpoints, err := checkEndpointsHealth(&cfg, logger)
+	if err != nil {
+		logger.Error("Not enough healthy endpoints", "err", err)
+		return nil, connectivityError(err)
+	}
+	if cfg.DryRun {
+		return nil, dryRunStandalone(cfg, excludedEndpoints, logger)
+	}
+	if err := cfg.checkRateFeasibility(defaultTxGenerateCost, logger); err != nil {
+		logger.Error("Infeasible transaction rate", "err", err)
+		return nil, configError(err)
+	}
+
 	logger.Info("Connecting to remote endpoints")
 	tg := NewTransactorGroup() //
 	tg.SetLogger(logger)
+	tg.newLogger = o.loggerFactory
+	if len(cfg.WorkerMetricsAddr) > 0 {
+		reg := newMetricsRegistry()
+		ms, err := startMetricsServer(cfg.WorkerMetricsAddr, reg, logger)
+		if err != nil {
+			logger.Error("Failed to start metrics endpoint", "err", err)
+			return nil, connectivityError(err)
+		}
+		defer ms.Stop()
+		tg.SetMetricsRegistry(reg)
+	}
 	if err := tg.AddAll(&cfg); err != nil {
-		return err
+		return nil, connectivityError(err)
 	}
+	if err := tg.pregenerate(); err != nil {
+		logger.Error("Failed to pre-generate transactions", "err", err)
+		tg.close()
+		return nil, err
+	}
+	if len(cfg.StatsdAddr) > 0 {
+		sink, err := newStatsdSink(cfg.StatsdAddr, cfg.StatsdPrefix, nil, cfg.expectedTxRate(len(tg.transactors)), logger)
+		if err != nil {
+			logger.Error("Failed to set up StatsD metrics", "err", err)
+			return nil, connectivityError(err)
+		}
+		defer sink.Close()
+		tg.AddMetricsSink(sink)
+	}
+	if len(cfg.InfluxDBURL) > 0 {
+		sink, err := newInfluxDBSink(cfg.InfluxDBURL, cfg.InfluxDBToken, cfg.InfluxDBOrg, cfg.InfluxDBBucket, nil, logger)
+		if err != nil {
+			logger.Error("Failed to set up InfluxDB export", "err", err)
+			return nil, connectivityError(err)
+		}
+		defer sink.Close()
+		tg.AddMetricsSink(sink)
+	}
+	if len(cfg.OTLPEndpoint) > 0 {
+		sink, err := newOTLPSink(
+			cfg.OTLPEndpoint,
+			time.Duration(cfg.OTLPExportInterval),
+			map[string]string{"tmloadtest.run.id": cfg.RunID},
+			cfg.broadcastLatencyBuckets(),
+			logger,
+		)
+		if err != nil {
+			logger.Error("Failed to set up OpenTelemetry export", "err", err)
+			return nil, configError(err)
+		}
+		defer sink.Close()
+		tg.AddMetricsSink(sink)
+	}
+	var tw *timeseriesWriter
+	if len(cfg.RawStatsOutputFile) > 0 {
+		rawOut, rerr := newStatsOutput(cfg.RawStatsOutputFile)
+		if rerr != nil {
+			logger.Error("Failed to create raw statistics output file", "err", rerr)
+			return nil, connectivityError(rerr)
+		}
+		if tw, rerr = newTimeseriesWriter(rawOut.filename); rerr != nil {
+			logger.Error("Failed to create raw statistics output file", "err", rerr)
+			return nil, connectivityError(rerr)
+		}
+		// the raw statistics are uploaded even if the load test fails
+		defer func() {
+			_ = tw.Close()
+			if uerr := rawOut.finish(cfg, logger); uerr != nil && err == nil {
+				err = uerr
+			}
+		}()
+		tg.setTimeseriesCallback(time.Duration(cfg.RawStatsInterval), func(s timeseriesSample) {
+			if err := tw.Write(standaloneTimeseriesWorker, s); err != nil {
+				logger.Error("Failed to write raw statistics", "err", err)
+			}
+		})
+	}
+
+	flushFile, err := cfg.statsFlushFile()
+	if err != nil {
+		logger.Error("Failed to create aggregate statistics output file", "err", err)
+		return nil, connectivityError(err)
+	}
+
+	// progress is only displayed (rather than just logged) when run by the CLI
+	progressMode := cfg.ProgressMode
+	if progressMode == ProgressModeBar && (o.progressBarOut == nil || !isTerminal(o.progressBarOut)) {
+		progressMode = ProgressModeLog
+	}
+	// progress records are logged unless the progress bar is shown, which
+	// suppresses informational logging anyway
+	var bar *progressBar
+	if cfg.ProgressInterval > 0 {
+		progressInterval := time.Duration(cfg.ProgressInterval)
+		switch progressMode {
+		case ProgressModeNone:
+			tg.setProgressStatusCallback(progressInterval, func(p progressStatus) {
+				tg.progressRecord(progressRecordStandalone, p).log(logger)
+			})
+		case ProgressModeBar:
+			bar = newProgressBar(o.progressBarOut)
+			tg.setProgressStatusCallback(progressBarRefreshInterval, bar.Render)
+		default:
+			tg.setProgressStatusCallback(progressInterval, func(p progressStatus) {
+				if o.progressOut != nil {
+					fmt.Fprintln(o.progressOut, p.String())
+				}
+				tg.progressRecord(progressRecordStandalone, p).log(logger)
+			})
+		}
+	}
+
 	logger.Info("Initiating load test ")
+	if bar != nil && o.attachProgressBar != nil {
+		detach := o.attachProgressBar(bar)
+		defer detach()
+	}
 	tg.Start() //
+	defer afterFunc(ctx, tg.Cancel)()
+
+	// the statistics gathered so far are flushed periodically, so that a load
+	// test that's killed (e.g. for running out of memory) leaves them behind
+	flusher := startStandaloneStatsFlusher(flushFile, cfg, tg, excludedEndpoints, tw, logger)
+	defer flusher.Stop()
 
 	var cancelTrap chan struct{}
 	if !cfg.NoTrapInterrupts {
-		// we want to know if the user hits Ctrl+Break
-		cancelTrap = trapInterrupts(func() { tg.Cancel() }, logger)
+		// we want to know if the user hits Ctrl+Break, or the load test is
+		// terminated (e.g. because its container is being stopped)
+		cancelTrap = trapSignals(
+			func() {
+				flusher.Flush()
+				tg.Cancel()
+			},
+			func() {
+				flusher.Flush()
+				tg.Terminate(terminationDrain(cfg))
+			},
+			logger,
+		)
 		defer close(cancelTrap)
+		// and SIGUSR1/SIGUSR2 pause and resume the load test
+		pauseTrap := trapPauseSignals(func() { tg.Pause() }, func() { tg.Resume() }, logger)
+		defer close(pauseTrap)
 	} else {
 		logger.Debug("Skipping trapping of interrupts (e.g. Ctrl+Break)")
 	}
 
+	// an interrupted load test still reports on what was sent until then
+	var cancelled error
 	if err := tg.Wait(); err != nil {
-		logger.Error("Failed to execute load test", "err", err)
-		return err
+		if !errors.Is(err, ErrLoadTestCancelled) {
+			logger.Error("Failed to execute load test", "err", err)
+			return nil, err
+		}
+		logger.Info("Load test cancelled - reporting the statistics gathered so far")
+		cancelled = err
+	}
+	// the final statistics take the place of those flushed so far
+	flusher.Stop()
+	aggStats := tg.aggregateStats()
+	aggStats.ExcludedEndpoints = excludedEndpoints
+	if len(tg.transactors) > 0 {
+		aggStats.Chain = collectChainStats(cfg, tg.transactors[0].remoteAddr, tg.getStartTime(), tg.sendEndTime(), logger)
+	}
+	aggStats.Compute()
+	switch {
+	case errors.Is(cancelled, ErrLoadTestTerminated):
+		aggStats.Status = StatsStatusTerminated
+	case cancelled != nil:
+		aggStats.Status = StatsStatusCancelled
+	}
+	r := NewReport(cfg, aggStats, nil)
+	report = &r
+	if bar != nil {
+		bar.Finish(aggStats)
+	}
+	warnOnRateShortfall(logger, aggStats, cfg.MaxRateDeviation)
+
+	if len(cfg.LatencySampleFile) > 0 {
+		logger.Info("Writing raw latency samples", "outputFile", cfg.LatencySampleFile)
+		if err := tg.writeLatencySamples(cfg.LatencySampleFile); err != nil {
+			logger.Error("Failed to write raw latency samples", "err", err)
+			return report, err
+		}
 	}
 
 	// if we need to write the final statistics
 	if len(cfg.StatsOutputFile) > 0 {
 		logger.Info("Writing aggregate statistics", "outputFile", cfg.StatsOutputFile)
-		if err := tg.WriteAggregateStats(cfg.StatsOutputFile); err != nil {
+		out, err := newStatsOutput(cfg.StatsOutputFile)
+		if err != nil {
+			logger.Error("Failed to create aggregate statistics output file", "err", err)
+			return report, err
+		}
+		if err := writeReport(out.filename, cfg.StatsOutputFormat, r); err != nil {
 			logger.Error("Failed to write aggregate statistics", "err", err)
-			return err
+			return report, err
+		}
+		if err := out.finish(cfg, logger); err != nil {
+			return report, err
+		}
+	}
+
+	if cancelled != nil {
+		return report, cancelled
+	}
+
+	if cfg.MinSuccessRatio > 0 {
+		if aggStats.SuccessRatio < cfg.MinSuccessRatio {
+			err := fmt.Errorf("success ratio of %.4f is below the minimum of %.4f", aggStats.SuccessRatio, cfg.MinSuccessRatio)
+			logger.Error("Load test failed", "err", err, "failedTxs", aggStats.FailedTxs, "totalTxs", aggStats.TotalTxs)
+			return report, thresholdError(err)
 		}
 	}
 
 	logger.Info("Load test complete!")
-	return nil
+	return report, nil
+}
+
+// makeRunID generates a unique identifier for a load test run.
+func makeRunID() string {
+	return strings.ReplaceAll(uuid.NewV4().String(), "-", "")
 }
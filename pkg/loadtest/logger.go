@@ -0,0 +1,121 @@
+package loadtest
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// Logger is the interface through which all of our components log. Embedders
+// can implement it to route our logs into their own logging (see SetLogger).
+// By default nothing is logged, so that embedding this package doesn't write
+// to the host application's stderr - the CLI (see Run) opts into logging via
+// logrus.
+type Logger = logging.Logger
+
+// LoggerFactory creates the logger for a component with the given context,
+// e.g. "coordinator", "worker[w1]" or "transactor[ws://host:26657/websocket]".
+type LoggerFactory func(ctx string) Logger
+
+// The factory from which our components obtain their loggers, unless they're
+// given one of their own (see WithLogger).
+var (
+	loggerFactoryMtx sync.RWMutex
+	loggerFactory    LoggerFactory = NewNoopLogger
+	loggerFactorySet bool          // Was a factory given to SetLogger?
+)
+
+// SetLogger sets the factory from which all of our components obtain their
+// loggers, unless they're given one of their own (see WithLogger), from then
+// on. Passing nil restores the default, which logs nothing.
+func SetLogger(factory LoggerFactory) {
+	loggerFactoryMtx.Lock()
+	defer loggerFactoryMtx.Unlock()
+	loggerFactorySet = factory != nil
+	if factory == nil {
+		factory = NewNoopLogger
+	}
+	loggerFactory = factory
+}
+
+// defaultToLogrus makes our components log via logrus, unless a factory has
+// been given to SetLogger.
+func defaultToLogrus() {
+	loggerFactoryMtx.Lock()
+	defer loggerFactoryMtx.Unlock()
+	if !loggerFactorySet {
+		loggerFactory = NewLogrusLogger
+	}
+}
+
+// NewLogrusLogger is a LoggerFactory whose loggers log via logrus' standard
+// logger, configured by our --log-level and --log-format flags.
+func NewLogrusLogger(ctx string) Logger {
+	return logging.NewLogrusLogger(ctx)
+}
+
+// NewNoopLogger is a LoggerFactory whose loggers do nothing. It's the default.
+func NewNoopLogger(ctx string) Logger {
+	return logging.NewNoopLogger()
+}
+
+// newLogger creates the logger for a component with the given context via the
+// given factory, or the one given to SetLogger if that's nil, with the given
+// key/value pairs as fields.
+func newLogger(factory LoggerFactory, ctx string, kvpairs ...interface{}) Logger {
+	if factory == nil {
+		loggerFactoryMtx.RLock()
+		factory = loggerFactory
+		loggerFactoryMtx.RUnlock()
+	}
+	logger := factory(ctx)
+	for i := 0; i+1 < len(kvpairs); i += 2 {
+		logger.SetField(kvpairs[i].(string), kvpairs[i+1])
+	}
+	return logger
+}
+
+// Option configures a Coordinator, Worker or standalone load test.
+type Option func(*options)
+
+type options struct {
+	loggerFactory LoggerFactory
+
+	// Where the CLI displays the progress of standalone load tests (see
+	// withProgressDisplay).
+	progressBarOut    *os.File
+	progressOut       io.Writer
+	attachProgressBar func(*progressBar) func()
+}
+
+// WithLogger gives a Coordinator, Worker or standalone load test (and the
+// components it creates, such as its transactors) its own logger factory,
+// instead of the one given to SetLogger.
+func WithLogger(factory LoggerFactory) Option {
+	return func(o *options) {
+		o.loggerFactory = factory
+	}
+}
+
+// withProgressDisplay makes a standalone load test display its progress as the
+// CLI does, in the configured progress mode: the status line is drawn on
+// barOut (if it's a terminal, after calling attach, whose returned function is
+// called once the bar is done with), and progress lines are printed to out.
+// Otherwise progress is only logged, as progress records.
+func withProgressDisplay(barOut *os.File, out io.Writer, attach func(*progressBar) func()) Option {
+	return func(o *options) {
+		o.progressBarOut = barOut
+		o.progressOut = out
+		o.attachProgressBar = attach
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
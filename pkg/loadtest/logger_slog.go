@@ -0,0 +1,17 @@
+//go:build go1.21
+
+package loadtest
+
+import (
+	"log/slog"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// NewSlogLoggerFactory returns a LoggerFactory whose loggers write to the given
+// slog logger, with each component's context as the "ctx" attribute.
+func NewSlogLoggerFactory(logger *slog.Logger) LoggerFactory {
+	return func(ctx string) Logger {
+		return logging.NewSlogLogger(logger, ctx)
+	}
+}
@@ -0,0 +1,148 @@
+package loadtest_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logRecorder records the messages logged by the loggers it creates, along
+// with their contexts.
+type logRecorder struct {
+	mtx   sync.Mutex
+	lines map[string][]string // Messages by context.
+}
+
+func newLogRecorder() *logRecorder {
+	return &logRecorder{lines: make(map[string][]string)}
+}
+
+func (r *logRecorder) newLogger(ctx string) loadtest.Logger {
+	return &recordedLogger{Logger: loadtest.NewNoopLogger(ctx), rec: r, ctx: ctx}
+}
+
+func (r *logRecorder) record(ctx, msg string) {
+	r.mtx.Lock()
+	r.lines[ctx] = append(r.lines[ctx], msg)
+	r.mtx.Unlock()
+}
+
+// messages returns the messages logged by the loggers with the given context,
+// or whose contexts start with it if it ends with "[".
+func (r *logRecorder) messages(ctx string) []string {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	var msgs []string
+	for c, lines := range r.lines {
+		if c == ctx || (strings.HasSuffix(ctx, "[") && strings.HasPrefix(c, ctx)) {
+			msgs = append(msgs, lines...)
+		}
+	}
+	return msgs
+}
+
+type recordedLogger struct {
+	loadtest.Logger
+
+	rec *logRecorder
+	ctx string
+}
+
+func (l *recordedLogger) Debug(msg string, kvpairs ...interface{}) { l.rec.record(l.ctx, msg) }
+func (l *recordedLogger) Info(msg string, kvpairs ...interface{})  { l.rec.record(l.ctx, msg) }
+func (l *recordedLogger) Warn(msg string, kvpairs ...interface{})  { l.rec.record(l.ctx, msg) }
+func (l *recordedLogger) Error(msg string, kvpairs ...interface{}) { l.rec.record(l.ctx, msg) }
+
+func TestSetLogger(t *testing.T) {
+	rec := newLogRecorder()
+	loadtest.SetLogger(rec.newLogger)
+	defer loadtest.SetLogger(nil)
+
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	_, err := loadtest.RunStandalone(context.Background(), cfg)
+	require.NoError(t, err)
+
+	assert.Contains(t, rec.messages("loadtest"), "Attempting standalone load test against endpoints")
+	assert.Contains(t, rec.messages("transactor["), "Connected to remote Tendermint WebSockets RPC")
+}
+
+func TestWithLogger(t *testing.T) {
+	// nothing should be logged via the global factory
+	globalRec := newLogRecorder()
+	loadtest.SetLogger(globalRec.newLogger)
+	defer loadtest.SetLogger(nil)
+
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	addr := freeLocalAddr(t)
+	coordRec, workerRec := newLogRecorder(), newLogRecorder()
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	}, loadtest.WithLogger(coordRec.newLogger))
+	coordErrs := make(chan error, 1)
+	go func() { coordErrs <- coord.Run() }()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	}, loadtest.WithLogger(workerRec.newLogger))
+	require.NoError(t, err)
+	require.NoError(t, worker.Run())
+	require.NoError(t, <-coordErrs)
+
+	assert.Contains(t, coordRec.messages("coordinator"), "All workers completed their load testing")
+	assert.NotEmpty(t, coordRec.messages("remoteWorker["))
+	assert.Contains(t, workerRec.messages("worker[worker0]"), "Successfully registered with coordinator")
+	assert.Contains(t, workerRec.messages("transactor["), "Connected to remote Tendermint WebSockets RPC")
+	assert.Empty(t, coordRec.messages("worker[worker0]"))
+	assert.Empty(t, workerRec.messages("coordinator"))
+	globalRec.mtx.Lock()
+	assert.Empty(t, globalRec.lines)
+	globalRec.mtx.Unlock()
+}
+
+func TestSilentByDefault(t *testing.T) {
+	// catches writes to stderr, whether direct or via logrus
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	prevStderr, prevOut := os.Stderr, logrus.StandardLogger().Out
+	os.Stderr = w
+	logrus.SetOutput(w)
+	defer func() {
+		os.Stderr = prevStderr
+		logrus.SetOutput(prevOut)
+	}()
+	output := make(chan []byte, 1)
+	go func() {
+		b, _ := io.ReadAll(r)
+		output <- b
+	}()
+
+	svr := newMockRPCServer(t, 0)
+	for _, mode := range []string{"", loadtest.ProgressModeLog, loadtest.ProgressModeBar} {
+		cfg := mockTestConfig(svr.URL())
+		cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.csv")
+		// progress is reported, but only displayed by the CLI
+		cfg.ProgressInterval = loadtest.Duration(100 * time.Millisecond)
+		cfg.ProgressMode = mode
+		require.NoError(t, loadtest.ExecuteStandalone(cfg), mode)
+	}
+
+	os.Stderr = prevStderr
+	logrus.SetOutput(prevOut)
+	require.NoError(t, w.Close())
+	assert.Empty(t, string(<-output))
+}
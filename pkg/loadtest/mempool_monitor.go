@@ -0,0 +1,216 @@
+package loadtest
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// MempoolStats summarizes how often, and for how long, sending was paused
+// because of endpoints' mempools exceeding the configured threshold.
+type MempoolStats struct {
+	Pauses          int     `json:"pauses"`           // The total number of times sending to an endpoint was paused.
+	PausedSeconds   float64 `json:"paused_seconds"`   // The total time for which endpoints were paused, summed across endpoints.
+	PausedEndpoints int     `json:"paused_endpoints"` // The number of endpoints currently paused.
+}
+
+// mempoolMonitor periodically polls the mempool size of each endpoint in a
+// load test, pausing sending to an endpoint while its mempool is too full.
+type mempoolMonitor struct {
+	pauseThreshold  int
+	resumeThreshold int
+	pollInterval    time.Duration
+	logger          logging.Logger
+
+	mtx       sync.Mutex
+	endpoints map[string]*mempoolEndpoint // Keyed by WebSockets address.
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type mempoolEndpoint struct {
+	addr        string
+	client      *httpClient
+	transactors []*Transactor
+
+	paused      bool
+	pausedSince time.Time
+	pauses      int
+	pausedTime  time.Duration
+	failing     bool // Did the last query fail?
+}
+
+func newMempoolMonitor(cfg *Config, transactors []*Transactor, logger logging.Logger) (*mempoolMonitor, error) {
+	m := &mempoolMonitor{
+		pauseThreshold:  cfg.MempoolPauseThreshold,
+		resumeThreshold: cfg.mempoolResumeThreshold(),
+		pollInterval:    time.Duration(cfg.MempoolPollInterval),
+		logger:          logger,
+		endpoints:       make(map[string]*mempoolEndpoint),
+		stop:            make(chan struct{}),
+		stopped:         make(chan struct{}),
+	}
+	if err := m.add(transactors); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// add monitors the mempools of the endpoints of the given transactors (e.g.
+// those added by re-discovery during the load test).
+func (m *mempoolMonitor) add(transactors []*Transactor) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, t := range transactors {
+		ep, exists := m.endpoints[t.remoteAddr]
+		if !exists {
+			rpcAddr, err := rpcHTTPAddr(t.remoteAddr)
+			if err != nil {
+				return err
+			}
+			ep = &mempoolEndpoint{
+				addr:   t.remoteAddr,
+				client: newHttpRpcClient(rpcAddr),
+			}
+			m.endpoints[t.remoteAddr] = ep
+		}
+		ep.transactors = append(ep.transactors, t)
+		t.setMempoolPaused(ep.paused)
+	}
+	return nil
+}
+
+func (m *mempoolMonitor) run() {
+	defer close(m.stopped)
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		m.poll()
+		select {
+		case <-ticker.C:
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Stop terminates the polling loop and blocks until it has stopped.
+func (m *mempoolMonitor) Stop() {
+	close(m.stop)
+	<-m.stopped
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for _, ep := range m.endpoints {
+		ep.client.close()
+	}
+}
+
+func (m *mempoolMonitor) poll() {
+	m.mtx.Lock()
+	endpoints := make([]*mempoolEndpoint, 0, len(m.endpoints))
+	for _, ep := range m.endpoints {
+		endpoints = append(endpoints, ep)
+	}
+	m.mtx.Unlock()
+
+	var wg sync.WaitGroup
+	for _, ep := range endpoints {
+		wg.Add(1)
+		go func(ep *mempoolEndpoint) {
+			defer wg.Done()
+			res, err := ep.client.numUnconfirmedTxs()
+			m.update(ep, res, err)
+		}(ep)
+	}
+	wg.Wait()
+}
+
+func (m *mempoolMonitor) update(ep *mempoolEndpoint, res *NumUnconfirmedTxs, err error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if err != nil {
+		// we'd rather keep sending than fail the whole load test
+		if !ep.failing {
+			m.logger.Warn("Failed to query mempool size - not throttling endpoint", "endpoint", ep.addr, "err", err)
+		}
+		ep.failing = true
+		m.setPaused(ep, false)
+		return
+	}
+	if ep.failing {
+		m.logger.Info("Mempool size queries succeeding again", "endpoint", ep.addr)
+		ep.failing = false
+	}
+
+	size := int(res.Total)
+	switch {
+	case !ep.paused && size > m.pauseThreshold:
+		m.logger.Info("Mempool size exceeds threshold - pausing endpoint", "endpoint", ep.addr, "size", size, "threshold", m.pauseThreshold)
+		m.setPaused(ep, true)
+
+	case ep.paused && size < m.resumeThreshold:
+		m.logger.Info("Mempool size below resume threshold - resuming endpoint", "endpoint", ep.addr, "size", size, "threshold", m.resumeThreshold)
+		m.setPaused(ep, false)
+	}
+}
+
+// Must be called with the mutex held.
+func (m *mempoolMonitor) setPaused(ep *mempoolEndpoint, paused bool) {
+	if ep.paused == paused {
+		return
+	}
+	ep.paused = paused
+	if paused {
+		ep.pauses++
+		ep.pausedSince = time.Now()
+	} else {
+		ep.pausedTime += time.Since(ep.pausedSince)
+	}
+	for _, t := range ep.transactors {
+		t.setMempoolPaused(paused)
+	}
+}
+
+func (m *mempoolMonitor) stats() MempoolStats {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	stats := MempoolStats{}
+	for _, ep := range m.endpoints {
+		stats.Pauses += ep.pauses
+		pausedTime := ep.pausedTime
+		if ep.paused {
+			stats.PausedEndpoints++
+			pausedTime += time.Since(ep.pausedSince)
+		}
+		stats.PausedSeconds += pausedTime.Seconds()
+	}
+	return stats
+}
+
+// rpcHTTPAddr converts the given WebSockets endpoint address into the base
+// address of the same node's HTTP RPC endpoint, retaining any RPC version path
+// prefix (e.g. "/v1").
+func rpcHTTPAddr(wsAddr string) (string, error) {
+	u, err := url.Parse(wsAddr)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return "", fmt.Errorf("unsupported protocol: %s (only ws:// and wss:// are supported)", u.Scheme)
+	}
+	u.Path = rpcPathPrefix(u.Path)
+	u.RawQuery = ""
+	return u.String(), nil
+}
@@ -0,0 +1,47 @@
+package loadtest_test
+
+import (
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMempoolMonitorPausesSending(t *testing.T) {
+	testCases := []struct {
+		mempoolSize    int
+		expectPaused   bool
+		expectRequests bool
+	}{
+		{500, true, false},
+		{10, false, true},
+		// failing mempool queries must not throttle sending
+		{-1, false, true},
+	}
+	for _, tc := range testCases {
+		svr := newMockRPCServer(t, 0)
+		svr.SetMempoolSize(tc.mempoolSize)
+		cfg := mockTestConfig(svr.URL())
+		cfg.Time = seconds(2)
+		cfg.Count = -1
+		cfg.MempoolPauseThreshold = 100
+		cfg.MempoolPollInterval = seconds(1)
+		require.NoError(t, cfg.Validate())
+
+		tg := loadtest.NewTransactorGroup()
+		require.NoError(t, tg.AddAll(&cfg))
+		tg.Start()
+		require.NoError(t, tg.Wait())
+
+		stats := tg.MempoolStats()
+		require.NotNil(t, stats)
+		if tc.expectPaused {
+			assert.Equal(t, 1, stats.Pauses, "mempool size %d", tc.mempoolSize)
+			assert.Greater(t, stats.PausedSeconds, 1.0, "mempool size %d", tc.mempoolSize)
+		} else {
+			assert.Equal(t, 0, stats.Pauses, "mempool size %d", tc.mempoolSize)
+		}
+		assert.Equal(t, tc.expectRequests, svr.Requests() > 0, "mempool size %d", tc.mempoolSize)
+	}
+}
@@ -2,10 +2,41 @@ package loadtest
 
 // A generic message to/from a worker.
 type workerMsg struct {
-	ID           string      `json:"id,omitempty"`             // A UUID for this worker.
-	State        workerState `json:"state,omitempty"`          // The worker's desired or actual state.
-	TxCount      int         `json:"tx_count,omitempty"`       // The total number of transactions sent thus far by this worker.
-	TotalTxBytes int64       `json:"total_tx_bytes,omitempty"` // The total number of transaction bytes sent thus far by this worker.
-	Error        string      `json:"error,omitempty"`          // If the worker has failed somehow, a descriptive error message as to why.
-	Config       *Config     `json:"config,omitempty"`         // The load testing configuration, if relevant.
+	ID                      string                   `json:"id,omitempty"`                         // The worker's unique ID.
+	Labels                  map[string]string        `json:"labels,omitempty"`                     // The labels with which the worker identifies itself when registering.
+	State                   workerState              `json:"state,omitempty"`                      // The worker's desired or actual state.
+	TxCount                 int                      `json:"tx_count,omitempty"`                   // The total number of transactions sent thus far by this worker.
+	TotalTxBytes            int64                    `json:"total_tx_bytes,omitempty"`             // The total number of transaction bytes sent thus far by this worker.
+	Progress                float64                  `json:"progress,omitempty"`                   // The fraction of its load test that the worker has completed thus far.
+	ETASeconds              float64                  `json:"eta_seconds,omitempty"`                // The estimated time remaining for the worker's load test.
+	Failures                int                      `json:"failures,omitempty"`                   // The total number of error responses received thus far by this worker.
+	DrainSeconds            float64                  `json:"drain_seconds,omitempty"`              // How long the worker spent draining in-flight responses after it stopped sending.
+	Interval                *statsInterval           `json:"interval,omitempty"`                   // The statistics gathered since the worker's previous update, during the load test.
+	BroadcastLatency        *latencySketch           `json:"broadcast_latency,omitempty"`          // The broadcast latencies measured thus far, once the worker has completed its load testing.
+	Mempool                 *MempoolStats            `json:"mempool,omitempty"`                    // Mempool throttling statistics, if mempool monitoring is enabled.
+	EndpointHealth          *EndpointHealthStats     `json:"endpoint_health,omitempty"`            // Endpoint blacklisting statistics, if endpoints are blacklisted after repeated failures.
+	CommitLatency           *latencySketch           `json:"commit_latency,omitempty"`             // The send-to-commit latencies measured thus far, once the worker has completed its load testing (if commit latency tracking is enabled).
+	CommitLatencyByPriority map[int64]*latencySketch `json:"commit_latency_by_priority,omitempty"` // The send-to-commit latencies per transaction priority, if the client produces prioritized transactions.
+	Endpoints               []EndpointStats          `json:"endpoints,omitempty"`                  // Per-endpoint statistics.
+	Timeseries              []timeseriesSample       `json:"timeseries,omitempty"`                 // Timeseries samples recorded since the last message, if raw statistics are to be recorded.
+	IntervalTxs             []int                    `json:"interval_txs,omitempty"`               // The number of transactions sent during each rate window, once the worker has completed its load testing.
+	Stats                   *WorkerStats             `json:"stats,omitempty"`                      // The worker's own final statistics, once it has completed its load testing.
+	Error                   string                   `json:"error,omitempty"`                      // If the worker has failed somehow, a descriptive error message as to why.
+	Config                  *Config                  `json:"config,omitempty"`                     // The load testing configuration, if relevant.
+	AuthToken               string                   `json:"auth_token,omitempty"`                 // The shared token with which the worker authenticates itself when registering, if the coordinator requires one.
+	Resume                  bool                     `json:"resume,omitempty"`                     // Whether the worker is registering again to resume its load test after losing its connection to the coordinator.
+	MaxReconnectTime        Duration                 `json:"max_reconnect_time,omitempty"`         // How long the worker keeps trying to reconnect if it loses its connection to the coordinator during the load test.
+	ElapsedSeconds          float64                  `json:"elapsed_seconds,omitempty"`            // How far into the load test the worker was accepted, if it joined a load test that was already underway.
+	StartDelaySeconds       float64                  `json:"start_delay_seconds,omitempty"`        // How long the worker must wait before it starts sending transactions, if the coordinator staggers workers' starts.
+	Run                     int                      `json:"run,omitempty"`                        // The index of the run being started, if the coordinator executes several runs back to back.
+	Runs                    int                      `json:"runs,omitempty"`                       // The number of runs the coordinator executes back to back, if more than one.
+	HeartbeatInterval       Duration                 `json:"heartbeat_interval,omitempty"`         // How often the worker must send heartbeats to the coordinator, once accepted.
+	ProtocolVersion         string                   `json:"protocol_version,omitempty"`           // The version of the protocol spoken by the sender, when registering (or accepting a worker).
+	Force                   bool                     `json:"force,omitempty"`                      // Whether the worker must shut down even in the middle of its load test, when told to shut down.
+	Encodings               []string                 `json:"encodings,omitempty"`                  // The message encodings (other than JSON) the worker supports, when registering.
+	Encoding                string                   `json:"encoding,omitempty"`                   // The encoding in which messages are exchanged from now on, when accepting a worker. Defaults to JSON.
+	Resources               *ResourceUsage           `json:"resources,omitempty"`                  // The worker's own resource usage, sampled with each progress update during the load test.
+	TxRate                  float64                  `json:"tx_rate,omitempty"`                    // The transaction rate (tx/sec) at which the worker must send across all of its connections, if the coordinator splits a total rate amongst its workers.
+	ClientFactories         []string                 `json:"client_factories,omitempty"`           // The client factories the worker supports, when registering.
+	Build                   *BuildInfo               `json:"build,omitempty"`                      // The build of tm-load-test the worker runs, when registering.
 }
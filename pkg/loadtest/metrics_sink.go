@@ -0,0 +1,45 @@
+package loadtest
+
+import "time"
+
+// MetricsSink receives metrics from transactors as they send transactions, so
+// that they can be forwarded to a monitoring system. Implementations must be
+// safe for concurrent use and must not block, since they're called from the
+// transactors' send and receive loops.
+type MetricsSink interface {
+	// TxsSent is called after a batch of transactions has been sent to the
+	// given endpoint.
+	TxsSent(endpoint string, count int, bytes int64)
+
+	// TxFailed is called when an endpoint responds to a transaction with an
+	// error.
+	TxFailed(endpoint string)
+
+	// BroadcastLatency is called with the broadcast latency of each
+	// transaction sent to the given endpoint (the write-completion latency for
+	// broadcast_tx_async).
+	BroadcastLatency(endpoint string, latency time.Duration)
+}
+
+// metricsSinks forwards metrics to multiple sinks.
+type metricsSinks []MetricsSink
+
+var _ MetricsSink = metricsSinks(nil)
+
+func (s metricsSinks) TxsSent(endpoint string, count int, bytes int64) {
+	for _, sink := range s {
+		sink.TxsSent(endpoint, count, bytes)
+	}
+}
+
+func (s metricsSinks) TxFailed(endpoint string) {
+	for _, sink := range s {
+		sink.TxFailed(endpoint)
+	}
+}
+
+func (s metricsSinks) BroadcastLatency(endpoint string, latency time.Duration) {
+	for _, sink := range s {
+		sink.BroadcastLatency(endpoint, latency)
+	}
+}
@@ -0,0 +1,485 @@
+package loadtest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+)
+
+// mockRPCServer emulates a Tendermint WebSockets RPC endpoint, responding to
+// each request after a configurable delay.
+type mockRPCServer struct {
+	svr        *httptest.Server
+	respDelay  time.Duration
+	pathPrefix string // The RPC version path prefix (e.g. "/v1"), if any.
+
+	mtx         sync.Mutex
+	requests    int
+	methods     map[string]int     // The number of transactions received via each RPC method.
+	connections int                // The number of WebSockets connections accepted thus far.
+	firstTxAt   time.Time          // When the first transaction was received.
+	failEvery   int                // If > 0, respond to every n-th transaction with an error.
+	readDelay   time.Duration      // How long to wait before reading each request, to throttle clients.
+	statusDelay time.Duration      // How long to wait before responding to status requests, to emulate network latency.
+	mempoolSize int                // Set to a negative value to make mempool queries fail.
+	mempoolTxs  []string           // Base64-encoded transactions awaiting inclusion in a block.
+	subscribers map[*mockConn]int  // Subscribed connections and their subscription request IDs.
+	open        map[*mockConn]bool // All open connections.
+	down        bool               // Whether the mock endpoint is emulating a dead node.
+	peers       []*mockRPCServer   // The peers reported by the net_info RPC API.
+	blocks      []mockBlock        // The blocks produced thus far, in ascending order of height.
+	stopBlocks  chan struct{}
+}
+
+// mockBlock holds the metadata of a block produced by the mock endpoint.
+type mockBlock struct {
+	time   time.Time
+	numTxs int
+}
+
+// mockConn serializes writes to a mock server-side WebSockets connection.
+type mockConn struct {
+	conn *websocket.Conn
+	mtx  sync.Mutex
+}
+
+func (c *mockConn) writeResponse(id int, result string) {
+	res, _ := json.Marshal(loadtest.RPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  json.RawMessage(result),
+	})
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	_ = c.conn.WriteMessage(websocket.TextMessage, res)
+}
+
+func (c *mockConn) writeError(id int, message string) {
+	res, _ := json.Marshal(loadtest.RPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &loadtest.RPCError{Code: -32603, Message: message},
+	})
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	_ = c.conn.WriteMessage(websocket.TextMessage, res)
+}
+
+func newMockRPCServer(t testing.TB, respDelay time.Duration) *mockRPCServer {
+	return newMockRPCServerWithPrefix(t, respDelay, "")
+}
+
+// newMockRPCServerV1 creates a mock endpoint that only exposes the CometBFT v1
+// RPC routes (e.g. "/v1/websocket").
+func newMockRPCServerV1(t testing.TB, respDelay time.Duration) *mockRPCServer {
+	return newMockRPCServerWithPrefix(t, respDelay, "/v1")
+}
+
+// newMockRPCServerIPv6 creates a mock endpoint listening on the IPv6 loopback
+// address, skipping the test if IPv6 isn't available.
+func newMockRPCServerIPv6(t testing.TB, respDelay time.Duration) *mockRPCServer {
+	l, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback address not available: %v", err)
+	}
+	return newMockRPCServerOn(t, respDelay, "", l)
+}
+
+func newMockRPCServerWithPrefix(t testing.TB, respDelay time.Duration, pathPrefix string) *mockRPCServer {
+	return newMockRPCServerOn(t, respDelay, pathPrefix, nil)
+}
+
+// newMockRPCServerOn creates a mock endpoint serving on the given listener, or
+// on a local IPv4 address if nil.
+func newMockRPCServerOn(t testing.TB, respDelay time.Duration, pathPrefix string, l net.Listener) *mockRPCServer {
+	m := &mockRPCServer{
+		respDelay:   respDelay,
+		pathPrefix:  pathPrefix,
+		methods:     make(map[string]int),
+		subscribers: make(map[*mockConn]int),
+		open:        make(map[*mockConn]bool),
+		stopBlocks:  make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(pathPrefix+"/websocket", m.handleWebSocket)
+	mux.HandleFunc(pathPrefix+"/health", m.handleHealth)
+	mux.HandleFunc(pathPrefix+"/status", m.handleStatus)
+	mux.HandleFunc(pathPrefix+"/net_info", m.handleNetInfo)
+	mux.HandleFunc(pathPrefix+"/num_unconfirmed_txs", m.handleNumUnconfirmedTxs)
+	mux.HandleFunc(pathPrefix+"/blockchain", m.handleBlockchain)
+	if l == nil {
+		m.svr = httptest.NewServer(mux)
+	} else {
+		m.svr = httptest.NewUnstartedServer(mux)
+		_ = m.svr.Listener.Close()
+		m.svr.Listener = l
+		m.svr.Start()
+	}
+	t.Cleanup(func() {
+		close(m.stopBlocks)
+		m.svr.Close()
+	})
+	return m
+}
+
+// StartBlocks makes the mock endpoint produce a block at the given interval,
+// containing all of the transactions broadcast since the previous block, and
+// publish it to all subscribers.
+func (m *mockRPCServer) StartBlocks(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.produceBlock()
+			case <-m.stopBlocks:
+				return
+			}
+		}
+	}()
+}
+
+// DropSubscriptions closes all subscribed connections.
+func (m *mockRPCServer) DropSubscriptions() {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for c := range m.subscribers {
+		_ = c.conn.Close()
+		delete(m.subscribers, c)
+	}
+}
+
+func (m *mockRPCServer) produceBlock() {
+	m.mtx.Lock()
+	txs := m.mempoolTxs
+	m.mempoolTxs = nil
+	m.blocks = append(m.blocks, mockBlock{time: time.Now(), numTxs: len(txs)})
+	subscribers := make(map[*mockConn]int, len(m.subscribers))
+	for c, id := range m.subscribers {
+		subscribers[c] = id
+	}
+	m.mtx.Unlock()
+
+	if txs == nil {
+		txs = []string{}
+	}
+	txsJSON, _ := json.Marshal(txs)
+	event := fmt.Sprintf(
+		`{"query":"tm.event='NewBlock'","data":{"type":"tendermint/event/NewBlock","value":{"block":{"data":{"txs":%s}}}}}`,
+		string(txsJSON),
+	)
+	for c, id := range subscribers {
+		c.writeResponse(id, event)
+	}
+}
+
+// URL returns the WebSockets URL for the mock endpoint.
+func (m *mockRPCServer) URL() string {
+	return m.wsBaseURL() + m.pathPrefix + "/websocket"
+}
+
+// LegacyURL returns the mock endpoint's WebSockets URL without any RPC version
+// path prefix, which is only valid if the mock endpoint exposes legacy routes.
+func (m *mockRPCServer) LegacyURL() string {
+	return m.wsBaseURL() + "/websocket"
+}
+
+func (m *mockRPCServer) wsBaseURL() string {
+	return "ws" + strings.TrimPrefix(m.svr.URL, "http")
+}
+
+// Requests returns the total number of requests received by the mock
+// endpoint.
+func (m *mockRPCServer) Requests() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.requests
+}
+
+// MempoolTxs returns the base64-encoded transactions the mock endpoint has
+// accepted since it last produced a block.
+func (m *mockRPCServer) MempoolTxs() []string {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return append([]string(nil), m.mempoolTxs...)
+}
+
+// MethodRequests returns the number of transactions the mock endpoint has
+// received via the given RPC method.
+func (m *mockRPCServer) MethodRequests(method string) int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.methods[method]
+}
+
+// Connections returns the number of WebSockets connections the mock endpoint
+// has accepted.
+func (m *mockRPCServer) Connections() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.connections
+}
+
+// FirstTxAt returns when the mock endpoint received its first transaction.
+func (m *mockRPCServer) FirstTxAt() time.Time {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.firstTxAt
+}
+
+// SetReadDelay makes the mock endpoint wait for the given duration before
+// reading each request, such that clients sending faster than that are
+// throttled by backpressure.
+func (m *mockRPCServer) SetReadDelay(d time.Duration) {
+	m.mtx.Lock()
+	m.readDelay = d
+	m.mtx.Unlock()
+}
+
+// SetStatusDelay makes the mock endpoint wait for the given duration before
+// responding to each status request, emulating a distant node.
+func (m *mockRPCServer) SetStatusDelay(d time.Duration) {
+	m.mtx.Lock()
+	m.statusDelay = d
+	m.mtx.Unlock()
+}
+
+// SetFailEvery makes the mock endpoint respond to every n-th transaction with
+// an error.
+func (m *mockRPCServer) SetFailEvery(n int) {
+	m.mtx.Lock()
+	m.failEvery = n
+	m.mtx.Unlock()
+}
+
+// SetDown makes the mock endpoint emulate a dead node (closing all open
+// connections and refusing new ones, and failing its health checks) until
+// it's brought back up.
+func (m *mockRPCServer) SetDown(down bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.down = down
+	if down {
+		for c := range m.open {
+			_ = c.conn.Close()
+		}
+	}
+}
+
+// SetPeers sets the peers reported by the mock endpoint's net_info RPC API.
+func (m *mockRPCServer) SetPeers(peers ...*mockRPCServer) {
+	m.mtx.Lock()
+	m.peers = peers
+	m.mtx.Unlock()
+}
+
+// SetMempoolSize sets the mempool size reported by the mock endpoint.
+func (m *mockRPCServer) SetMempoolSize(size int) {
+	m.mtx.Lock()
+	m.mempoolSize = size
+	m.mtx.Unlock()
+}
+
+func (m *mockRPCServer) handleNumUnconfirmedTxs(w http.ResponseWriter, r *http.Request) {
+	m.mtx.Lock()
+	size := m.mempoolSize
+	m.mtx.Unlock()
+	if size < 0 {
+		http.Error(w, "mempool unavailable", http.StatusInternalServerError)
+		return
+	}
+	writeRPCResult(w, fmt.Sprintf(`{"n_txs":"%d","total":"%d","total_bytes":"%d"}`, size, size, size*100))
+}
+
+func (m *mockRPCServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	m.mtx.Lock()
+	down := m.down
+	m.mtx.Unlock()
+	if down {
+		http.Error(w, "node is down", http.StatusServiceUnavailable)
+		return
+	}
+	writeRPCResult(w, `{}`)
+}
+
+func (m *mockRPCServer) handleNetInfo(w http.ResponseWriter, r *http.Request) {
+	m.mtx.Lock()
+	peers := make([]string, 0, len(m.peers))
+	for _, peer := range m.peers {
+		u, _ := url.Parse(peer.svr.URL)
+		peers = append(peers, fmt.Sprintf(
+			`{"node_info":{"other":{"rpc_address":"tcp://0.0.0.0:%s"}},"remote_ip":"%s"}`,
+			u.Port(),
+			u.Hostname(),
+		))
+	}
+	m.mtx.Unlock()
+	writeRPCResult(w, fmt.Sprintf(`{"n_peers":"%d","peers":[%s]}`, len(peers), strings.Join(peers, ",")))
+}
+
+func (m *mockRPCServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	version := "0.34.24"
+	if m.pathPrefix == "/v1" {
+		version = "1.0.0"
+	}
+	m.mtx.Lock()
+	height := len(m.blocks)
+	blockTime := time.Time{}
+	if height > 0 {
+		blockTime = m.blocks[height-1].time
+	}
+	statusDelay := m.statusDelay
+	m.mtx.Unlock()
+	time.Sleep(statusDelay)
+	writeRPCResult(w, fmt.Sprintf(
+		`{"node_info":{"version":"%s"},"sync_info":{"latest_block_height":"%d","latest_block_time":"%s"}}`,
+		version,
+		height,
+		blockTime.Format(time.RFC3339Nano),
+	))
+}
+
+// handleBlockchain serves the metadata of up to 20 of the blocks produced thus
+// far in the requested height range, in descending order of height.
+func (m *mockRPCServer) handleBlockchain(w http.ResponseWriter, r *http.Request) {
+	minHeight, _ := strconv.Atoi(r.URL.Query().Get("minHeight"))
+	maxHeight, _ := strconv.Atoi(r.URL.Query().Get("maxHeight"))
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if minHeight < 1 {
+		minHeight = 1
+	}
+	if maxHeight < 1 || maxHeight > len(m.blocks) {
+		maxHeight = len(m.blocks)
+	}
+	if maxHeight-minHeight >= 20 {
+		minHeight = maxHeight - 19
+	}
+	metas := make([]string, 0, 20)
+	for height := maxHeight; height >= minHeight; height-- {
+		b := m.blocks[height-1]
+		metas = append(metas, fmt.Sprintf(
+			`{"header":{"height":"%d","time":"%s"},"num_txs":"%d"}`,
+			height,
+			b.time.Format(time.RFC3339Nano),
+			b.numTxs,
+		))
+	}
+	writeRPCResult(w, fmt.Sprintf(`{"last_height":"%d","block_metas":[%s]}`, len(m.blocks), strings.Join(metas, ",")))
+}
+
+func writeRPCResult(w http.ResponseWriter, result string) {
+	res, _ := json.Marshal(loadtest.RPCResponse{
+		JSONRPC: "2.0",
+		ID:      -1,
+		Result:  json.RawMessage(result),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(res)
+}
+
+func (m *mockRPCServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	m.mtx.Lock()
+	down := m.down
+	m.mtx.Unlock()
+	if down {
+		http.Error(w, "node is down", http.StatusServiceUnavailable)
+		return
+	}
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	c := &mockConn{conn: conn}
+	m.mtx.Lock()
+	m.connections++
+	m.open[c] = true
+	m.mtx.Unlock()
+
+	defer func() {
+		m.mtx.Lock()
+		delete(m.subscribers, c)
+		delete(m.open, c)
+		m.mtx.Unlock()
+	}()
+	for {
+		m.mtx.Lock()
+		readDelay := m.readDelay
+		m.mtx.Unlock()
+		time.Sleep(readDelay)
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req loadtest.RPCRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			continue
+		}
+		if req.Method == "subscribe" {
+			m.mtx.Lock()
+			m.subscribers[c] = req.ID
+			m.mtx.Unlock()
+			c.writeResponse(req.ID, `{}`)
+			continue
+		}
+		var params struct {
+			Tx string `json:"tx"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		m.mtx.Lock()
+		if m.requests == 0 {
+			m.firstTxAt = time.Now()
+		}
+		m.requests++
+		m.methods[req.Method]++
+		fail := m.failEvery > 0 && m.requests%m.failEvery == 0
+		if !fail {
+			m.mempoolTxs = append(m.mempoolTxs, params.Tx)
+		}
+		m.mtx.Unlock()
+
+		go func(id int) {
+			time.Sleep(m.respDelay)
+			if fail {
+				c.writeError(id, "tx already exists in cache")
+				return
+			}
+			c.writeResponse(id, `{}`)
+		}(req.ID)
+	}
+}
+
+// seconds returns the given number of seconds as a configuration duration.
+func seconds(n int) loadtest.Duration {
+	return loadtest.Duration(time.Duration(n) * time.Second)
+}
+
+func mockTestConfig(endpoints ...string) loadtest.Config {
+	return loadtest.Config{
+		ClientFactory:        "kvstore",
+		Connections:          1,
+		Time:                 seconds(5),
+		SendPeriod:           seconds(1),
+		Rate:                 10,
+		Size:                 100,
+		Count:                10,
+		BroadcastTxMethod:    "async",
+		Endpoints:            endpoints,
+		EndpointSelectMethod: loadtest.SelectSuppliedEndpoints,
+		NoTrapInterrupts:     true,
+	}
+}
@@ -0,0 +1,58 @@
+package loadtest
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// The encodings in which the coordinator and its workers can exchange
+// messages. Messages are exchanged as JSON unless both sides support the
+// binary encoding (MessagePack), which is much cheaper to encode and decode.
+const (
+	msgEncodingJSON    = "json"
+	msgEncodingMsgpack = "msgpack"
+)
+
+// negotiateEncoding picks the encoding in which to exchange messages with a
+// worker that offers the given encodings. Workers that predate encoding
+// negotiation don't offer any, and so speak JSON.
+func negotiateEncoding(offered []string) string {
+	for _, enc := range offered {
+		if enc == msgEncodingMsgpack {
+			return msgEncodingMsgpack
+		}
+	}
+	return msgEncodingJSON
+}
+
+// marshalMsgpack encodes the given value as MessagePack. Structs are encoded as
+// maps keyed by their fields' JSON names, honoring the same "omitempty" and
+// "-" options as encoding/json, so that a value survives a round trip through
+// MessagePack as it would through JSON.
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalMsgpack decodes the given MessagePack data into the value to which
+// v points. As with encoding/json, unknown struct fields are ignored.
+func unmarshalMsgpack(data []byte, v interface{}) error {
+	r := bytes.NewReader(data)
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("msgpack: %d bytes of trailing data", r.Len())
+	}
+	return nil
+}
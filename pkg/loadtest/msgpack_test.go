@@ -0,0 +1,258 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Any message survives a round trip through MessagePack exactly as it survives
+// one through JSON.
+func TestMsgpackRoundTripMatchesJSON(t *testing.T) {
+	cfg := &quick.Config{
+		MaxCount: 200,
+		// a fixed seed, so that any failure is reproducible
+		Rand: rand.New(rand.NewSource(1)),
+		Values: func(args []reflect.Value, r *rand.Rand) {
+			v, ok := quick.Value(reflect.TypeOf(workerMsg{}), r)
+			require.True(t, ok)
+			msg := v.Interface().(workerMsg)
+			// raw JSON must at least be valid JSON
+			if msg.Config != nil {
+				for i := range msg.Config.Runs {
+					msg.Config.Runs[i] = json.RawMessage(`{"rate":1}`)
+				}
+			}
+			args[0] = reflect.ValueOf(msg)
+		},
+	}
+	err := quick.Check(func(msg workerMsg) bool {
+		// normalize the message as JSON would (e.g. replacing invalid UTF-8)
+		data, err := json.Marshal(&msg)
+		if err != nil {
+			t.Logf("failed to marshal message as JSON: %v", err)
+			return false
+		}
+		var expected workerMsg
+		if err := json.Unmarshal(data, &expected); err != nil {
+			t.Logf("failed to unmarshal message from JSON: %v", err)
+			return false
+		}
+		packed, err := marshalMsgpack(&expected)
+		if err != nil {
+			t.Logf("failed to marshal message as MessagePack: %v", err)
+			return false
+		}
+		var actual workerMsg
+		if err := unmarshalMsgpack(packed, &actual); err != nil {
+			t.Logf("failed to unmarshal message from MessagePack: %v", err)
+			return false
+		}
+		return assert.Equal(t, expected, actual)
+	}, cfg)
+	require.NoError(t, err)
+}
+
+func TestMsgpackRoundTripTypicalMessages(t *testing.T) {
+	cfg := &Config{
+		Connections:             2,
+		Time:                    Duration(60 * time.Second),
+		Rate:                    1000.5,
+		Endpoints:               []string{"ws://a:26657/websocket", "ws://b:26657/websocket"},
+		StatsCSVDelimiter:       ';',
+		BroadcastLatencyBuckets: []float64{0.001, 0.01, 0.1},
+		EndpointRateLimits:      map[string]float64{"ws://a:26657/websocket": 100},
+		EndpointWeights:         map[string]float64{"ws://b:26657/websocket": 2.5},
+		Runs:                    []json.RawMessage{json.RawMessage(`{"rate":10}`)},
+		ResultWebhookSecret:     "secret",
+	}
+	for _, msg := range []workerMsg{
+		{ID: "w1", Labels: map[string]string{"region": "eu"}, ProtocolVersion: WorkerProtocolVersion, Encodings: []string{msgEncodingMsgpack}},
+		{ID: "w1", State: workerAccepted, Config: cfg, ElapsedSeconds: 1.5, Encoding: msgEncodingMsgpack},
+		typicalStatsUpdate(),
+		{ID: "w1", State: workerCompleted, Stats: &WorkerStats{ID: "w1", TotalTxs: math.MaxInt32 + 1, TotalBytes: math.MaxInt64, AvgTxRate: -1.25}},
+		{ID: "w1", State: workerShutdown, Force: true},
+	} {
+		packed, err := marshalMsgpack(&msg)
+		require.NoError(t, err)
+		var actual workerMsg
+		require.NoError(t, unmarshalMsgpack(packed, &actual))
+		expected := msg
+		if expected.Config != nil {
+			// fields excluded from JSON are excluded from MessagePack too
+			c := *expected.Config
+			c.ResultWebhookSecret = ""
+			expected.Config = &c
+		}
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestMsgpackIntegers(t *testing.T) {
+	type ints struct {
+		I   int    `json:"i"`
+		I64 int64  `json:"i64"`
+		U64 uint64 `json:"u64"`
+		R   rune   `json:"r"`
+	}
+	for _, v := range []int64{0, 1, -1, -32, -33, 127, 128, -128, -129, 255, 256, 65535, 65536, -32768, -32769, math.MaxInt32, math.MinInt32, math.MaxInt64, math.MinInt64} {
+		expected := ints{I: int(v), I64: v, U64: uint64(v), R: rune(v)}
+		packed, err := marshalMsgpack(expected)
+		require.NoError(t, err)
+		var actual ints
+		require.NoError(t, unmarshalMsgpack(packed, &actual))
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestMsgpackErrors(t *testing.T) {
+	packed, err := marshalMsgpack(typicalStatsUpdate())
+	require.NoError(t, err)
+	var msg workerMsg
+	// truncated data
+	for i := 0; i < len(packed); i++ {
+		assert.Error(t, unmarshalMsgpack(packed[:i], &msg), "truncated to %d bytes", i)
+	}
+	// trailing data
+	assert.Error(t, unmarshalMsgpack(append(packed, 0x01), &msg))
+	// mismatched types
+	bad, err := marshalMsgpack(map[string]string{"tx_count": "many"})
+	require.NoError(t, err)
+	assert.Error(t, unmarshalMsgpack(bad, &msg))
+	// values that can't be decoded into
+	assert.Error(t, unmarshalMsgpack(packed, msg))
+}
+
+// Fields a receiver doesn't know about (e.g. from a newer protocol version) are
+// skipped, as with JSON.
+func TestMsgpackSkipsUnknownFields(t *testing.T) {
+	type newerMsg struct {
+		ID      string                 `json:"id"`
+		Extra   map[string][]float64   `json:"extra"`
+		Nested  *workerMsg             `json:"nested"`
+		Blob    []byte                 `json:"blob"`
+		Numbers map[string]interface{} `json:"-"`
+		TxCount int                    `json:"tx_count"`
+	}
+	packed, err := marshalMsgpack(newerMsg{
+		ID:      "w1",
+		Extra:   map[string][]float64{"a": {1.5, -2}},
+		Nested:  &workerMsg{ID: "w2", TxCount: 1 << 40, Labels: map[string]string{"a": "b"}},
+		Blob:    make([]byte, 300),
+		TxCount: 42,
+	})
+	require.NoError(t, err)
+	var msg workerMsg
+	require.NoError(t, unmarshalMsgpack(packed, &msg))
+	assert.Equal(t, workerMsg{ID: "w1", TxCount: 42}, msg)
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, msgEncodingJSON, negotiateEncoding(nil))
+	assert.Equal(t, msgEncodingJSON, negotiateEncoding([]string{"protobuf"}))
+	assert.Equal(t, msgEncodingMsgpack, negotiateEncoding([]string{"protobuf", msgEncodingMsgpack}))
+}
+
+// typicalStatsUpdate returns a stats update like those a worker sends the
+// coordinator every second during a load test.
+func typicalStatsUpdate() workerMsg {
+	sketch := func(offset int) *latencySketch {
+		s := &latencySketch{Counts: make(map[int]uint64)}
+		for i := 0; i < 40; i++ {
+			s.Counts[offset+i] = uint64(1 + i*37%500)
+			s.Count += s.Counts[offset+i]
+		}
+		s.Max = 0.734
+		return s
+	}
+	return workerMsg{
+		ID:           "worker-3f9a2c",
+		State:        workerTesting,
+		TxCount:      123456,
+		TotalTxBytes: 123456 * 250,
+		Progress:     0.4321,
+		ETASeconds:   34.1,
+		Failures:     12,
+		Interval: &statsInterval{
+			Seq:              42,
+			Txs:              1000,
+			Bytes:            250000,
+			Failures:         1,
+			BroadcastLatency: sketch(1200),
+			CommitLatency:    sketch(1700),
+		},
+		Mempool: &MempoolStats{Pauses: 3, PausedSeconds: 1.25, PausedEndpoints: 1},
+		Endpoints: []EndpointStats{
+			{Endpoint: "ws://node0:26657/websocket", TargetRate: 500, TotalTxs: 61728, AvgTxRate: 498.2},
+			{Endpoint: "ws://node1:26657/websocket", TargetRate: 500, TotalTxs: 61728, AvgTxRate: 499.7},
+		},
+		Timeseries: []timeseriesSample{
+			{TSeconds: 41, Txs: 1000, Bytes: 250000, Failures: 1, AvgLatencyMs: 12.5},
+		},
+	}
+}
+
+func BenchmarkStatsUpdate_EncodeJSON(b *testing.B) {
+	msg := typicalStatsUpdate()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := json.Marshal(&msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(data)))
+	}
+}
+
+func BenchmarkStatsUpdate_EncodeMsgpack(b *testing.B) {
+	msg := typicalStatsUpdate()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data, err := marshalMsgpack(&msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(data)))
+	}
+}
+
+func BenchmarkStatsUpdate_DecodeJSON(b *testing.B) {
+	msg := typicalStatsUpdate()
+	data, err := json.Marshal(&msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var decoded workerMsg
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStatsUpdate_DecodeMsgpack(b *testing.B) {
+	msg := typicalStatsUpdate()
+	data, err := marshalMsgpack(&msg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var decoded workerMsg
+		if err := unmarshalMsgpack(data, &decoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
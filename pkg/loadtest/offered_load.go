@@ -0,0 +1,134 @@
+package loadtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// offeredTxs returns the number of transactions sent at the given overall rate
+// (tx/sec) over the course of the load test, across the given number of
+// connections (each of which sends at most Count transactions, if limited).
+// Count-bounded load tests send exactly Count transactions per connection,
+// unless they're capped by a MaxRunTime first.
+func (c Config) offeredTxs(txRate float64, connections int) uint64 {
+	maxTxs := uint64(c.Count) * uint64(connections)
+	if c.countBounded() && c.MaxRunTime == 0 {
+		return maxTxs
+	}
+	txs := uint64(math.Ceil(txRate * c.timeLimit().Seconds()))
+	if c.Count > 0 && txs > maxTxs {
+		txs = maxTxs
+	}
+	return txs
+}
+
+// offeredLoad returns the total number of transactions, and of transaction
+// bytes, that a standalone load test (or a single worker) with this
+// configuration would send, i.e. Rate x Time x Connections for each endpoint.
+// Endpoint rate limits, which can only lower the load, are not taken into
+// account.
+func (c Config) offeredLoad() (uint64, uint64) {
+	return c.offeredLoadTo(len(c.Endpoints))
+}
+
+// offeredLoadTo returns the load this configuration would send to the given
+// number of endpoints (see offeredLoad).
+func (c Config) offeredLoadTo(endpoints int) (uint64, uint64) {
+	conns := c.Connections * endpoints
+	txs := c.offeredTxs(c.expectedTxRate(conns), conns)
+	return txs, txs * uint64(c.Size)
+}
+
+// offeredLoad returns the total number of transactions, and of transaction
+// bytes, that the expected number of workers would send between them, given
+// the coordinator's load testing configuration. Workers with overrides may
+// send more (or less) than the rest, so the heaviest of them are assumed to
+// take part, and the heaviest of the overrides keyed by label selectors (if
+// heavier than the base configuration) to apply to all other workers.
+func (c CoordinatorConfig) offeredLoad(cfg Config) (uint64, uint64) {
+	workers := c.ExpectWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	endpoints := len(cfg.Endpoints)
+	if c.ShardEndpoints {
+		// every worker connects to its share of the endpoints, and to at
+		// least one
+		endpoints = (endpoints + workers - 1) / workers
+	}
+	var totalTxs uint64
+	if c.TotalRate > 0 {
+		totalTxs = cfg.offeredTxs(c.TotalRate, cfg.Connections*endpoints*workers)
+		return totalTxs, totalTxs * uint64(cfg.Size)
+	}
+	// overrides keyed by worker ID apply to one worker each, whereas those
+	// keyed by label selectors may apply to any number of workers
+	overridden := make([]uint64, 0, len(c.WorkerOverrides))
+	txs, _ := cfg.offeredLoadTo(endpoints)
+	for key, o := range c.WorkerOverrides {
+		workerCfg, workerEndpoints := o.apply(cfg), endpoints
+		if len(o.Endpoints) > 0 {
+			workerEndpoints = len(workerCfg.Endpoints)
+		}
+		workerTxs, _ := workerCfg.offeredLoadTo(workerEndpoints)
+		if !isWorkerLabelSelector(key) {
+			overridden = append(overridden, workerTxs)
+		} else if workerTxs > txs {
+			txs = workerTxs
+		}
+	}
+	sort.Slice(overridden, func(i, j int) bool { return overridden[i] > overridden[j] })
+	for i := 0; i < workers; i++ {
+		if i < len(overridden) {
+			totalTxs += overridden[i]
+		} else {
+			totalTxs += txs
+		}
+	}
+	// overrides don't change the size of the transactions
+	return totalTxs, totalTxs * uint64(cfg.Size)
+}
+
+// checkOfferedLoad returns an error if the given total number of transactions
+// or of transaction bytes exceeds the configured hard caps.
+func (c Config) checkOfferedLoad(txs, bytes uint64) error {
+	if c.MaxTotalTxs > 0 && txs > uint64(c.MaxTotalTxs) {
+		return fmt.Errorf("the load test would send %d transactions in total, which exceeds max-total-txs of %d", txs, c.MaxTotalTxs)
+	}
+	if c.MaxTotalBytes > 0 && bytes > uint64(c.MaxTotalBytes) {
+		return fmt.Errorf("the load test would send %d transaction bytes in total, which exceeds max-total-bytes of %d", bytes, c.MaxTotalBytes)
+	}
+	return nil
+}
+
+// needsConfirmation returns whether a load test that sends the given total
+// number of transactions and of transaction bytes needs to be confirmed
+// before it goes ahead.
+func (c Config) needsConfirmation(txs, bytes uint64) bool {
+	if c.AssumeYes || c.DryRun {
+		return false
+	}
+	return (c.ConfirmTotalTxs > 0 && txs > uint64(c.ConfirmTotalTxs)) ||
+		(c.ConfirmTotalBytes > 0 && bytes > uint64(c.ConfirmTotalBytes))
+}
+
+// confirmOfferedLoad asks (on out) whether to go ahead with a load test that
+// sends the given total number of transactions and of transaction bytes,
+// returning an error unless the answer read from in is yes.
+func confirmOfferedLoad(txs, bytes uint64, in io.Reader, out io.Writer) error {
+	fmt.Fprintf(out, "The load test will send %d transactions (%d bytes) in total. Continue? [y/N] ", txs, bytes)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && len(answer) == 0 {
+		fmt.Fprintln(out)
+		return fmt.Errorf("failed to read confirmation (use --yes to skip it): %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("load test not confirmed")
+}
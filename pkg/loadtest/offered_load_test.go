@@ -0,0 +1,37 @@
+package loadtest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeedsConfirmation(t *testing.T) {
+	cfg := Config{ConfirmTotalTxs: 1000, ConfirmTotalBytes: 50000}
+	assert.False(t, cfg.needsConfirmation(1000, 50000))
+	assert.True(t, cfg.needsConfirmation(1001, 100))
+	assert.True(t, cfg.needsConfirmation(10, 50001))
+
+	// unless confirmation is skipped, or nothing will be sent
+	cfg.AssumeYes = true
+	assert.False(t, cfg.needsConfirmation(1001, 50001))
+	cfg.AssumeYes, cfg.DryRun = false, true
+	assert.False(t, cfg.needsConfirmation(1001, 50001))
+
+	assert.False(t, Config{}.needsConfirmation(1e9, 1e12))
+}
+
+func TestConfirmOfferedLoad(t *testing.T) {
+	for _, answer := range []string{"y\n", "yes\n", " Y \n", "yes"} {
+		var out bytes.Buffer
+		assert.NoError(t, confirmOfferedLoad(1200, 300000, strings.NewReader(answer), &out), "answer %q", answer)
+		assert.Equal(t, "The load test will send 1200 transactions (300000 bytes) in total. Continue? [y/N] ", out.String())
+	}
+	for _, answer := range []string{"\n", "n\n", "no\n", "sure\n"} {
+		assert.EqualError(t, confirmOfferedLoad(1200, 300000, strings.NewReader(answer), &bytes.Buffer{}), "load test not confirmed", "answer %q", answer)
+	}
+	// e.g. if stdin isn't interactive
+	assert.ErrorContains(t, confirmOfferedLoad(1200, 300000, strings.NewReader(""), &bytes.Buffer{}), "use --yes to skip it")
+}
@@ -0,0 +1,194 @@
+package loadtest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const (
+	otlpMetricsPath   = "/v1/metrics"
+	otlpExportTimeout = 10 * time.Second
+	otlpScopeName     = "github.com/informalsystems/tm-load-test"
+)
+
+// otlpSink periodically exports the transactors' counters and broadcast
+// latency histogram to an OpenTelemetry collector through the OpenTelemetry
+// metrics SDK, using the OTLP/HTTP exporter. Export failures are logged and
+// otherwise ignored, so that they don't affect the load test.
+type otlpSink struct {
+	provider *sdkmetric.MeterProvider
+	exporter *otlpExporter
+	logger   logging.Logger
+
+	txs      metric.Int64Counter
+	bytes    metric.Int64Counter
+	failures metric.Int64Counter
+	latency  metric.Float64Histogram
+
+	endpointAttrs sync.Map // The attributes of each endpoint's measurements, keyed by endpoint.
+}
+
+var _ MetricsSink = (*otlpSink)(nil)
+
+// newOTLPSink creates a sink that exports metrics at the given interval to the
+// OpenTelemetry collector at the given URL. The given attributes are added to
+// the resource describing this load testing process.
+func newOTLPSink(endpoint string, interval time.Duration, attrs map[string]string, bounds []float64, logger logging.Logger) (*otlpSink, error) {
+	exportURL := strings.TrimRight(endpoint, "/")
+	if !strings.HasSuffix(exportURL, otlpMetricsPath) {
+		exportURL += otlpMetricsPath
+	}
+	client, err := otlpmetrichttp.New(
+		context.Background(),
+		otlpmetrichttp.WithEndpointURL(exportURL),
+		otlpmetrichttp.WithTimeout(otlpExportTimeout),
+		// a collector that's down mustn't hold up the end of the load test
+		otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	resourceAttrs := []attribute.KeyValue{
+		attribute.String("service.name", "tm-load-test"),
+		attribute.String("service.version", Version()),
+	}
+	for k, v := range attrs {
+		resourceAttrs = append(resourceAttrs, attribute.String(k, v))
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(resourceAttrs...))
+	if err != nil {
+		return nil, err
+	}
+	exporter := &otlpExporter{Exporter: client, logger: logger}
+	s := &otlpSink{
+		provider: sdkmetric.NewMeterProvider(
+			sdkmetric.WithResource(res),
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
+				exporter,
+				sdkmetric.WithInterval(interval),
+				sdkmetric.WithTimeout(otlpExportTimeout),
+			)),
+		),
+		exporter: exporter,
+		logger:   logger,
+	}
+	meter := s.provider.Meter(otlpScopeName, metric.WithInstrumentationVersion(Version()))
+	if s.txs, err = meter.Int64Counter("tmloadtest.txs", metric.WithDescription("The total number of transactions sent"), metric.WithUnit("{tx}")); err != nil {
+		return nil, err
+	}
+	if s.bytes, err = meter.Int64Counter("tmloadtest.bytes", metric.WithDescription("The total number of bytes of transactions sent"), metric.WithUnit("By")); err != nil {
+		return nil, err
+	}
+	if s.failures, err = meter.Int64Counter("tmloadtest.failures", metric.WithDescription("The total number of error responses to transactions"), metric.WithUnit("{tx}")); err != nil {
+		return nil, err
+	}
+	if s.latency, err = meter.Float64Histogram(
+		"tmloadtest.broadcast_latency",
+		metric.WithDescription("The broadcast round-trip latency of each transaction (write-completion latency for broadcast_tx_async)"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(bounds...),
+	); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *otlpSink) TxsSent(endpoint string, count int, bytes int64) {
+	attrs := s.attributes(endpoint)
+	s.txs.Add(context.Background(), int64(count), attrs)
+	s.bytes.Add(context.Background(), bytes, attrs)
+}
+
+func (s *otlpSink) TxFailed(endpoint string) {
+	s.failures.Add(context.Background(), 1, s.attributes(endpoint))
+}
+
+func (s *otlpSink) BroadcastLatency(endpoint string, latency time.Duration) {
+	s.latency.Record(context.Background(), latency.Seconds(), s.attributes(endpoint))
+}
+
+// attributes returns the attributes with which to record measurements for the
+// given endpoint, which are only built once per endpoint since latencies are
+// recorded for every transaction.
+func (s *otlpSink) attributes(endpoint string) metric.MeasurementOption {
+	if attrs, ok := s.endpointAttrs.Load(endpoint); ok {
+		return attrs.(metric.MeasurementOption)
+	}
+	attrs, _ := s.endpointAttrs.LoadOrStore(endpoint, metric.WithAttributeSet(attribute.NewSet(attribute.String("endpoint", endpoint))))
+	return attrs.(metric.MeasurementOption)
+}
+
+// Close exports the final values of all metrics and logs a summary of any
+// export failures.
+func (s *otlpSink) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+	defer cancel()
+	if err := s.provider.Shutdown(ctx); err != nil {
+		s.logger.Debug("Failed to shut down OpenTelemetry metrics export", "err", err)
+	}
+	exports, failed, lastErr := s.exporter.results()
+	if failed > 0 {
+		s.logger.Error(
+			"Failed to export some metrics to OpenTelemetry collector",
+			"failedExports", failed,
+			"totalExports", exports,
+			"lastErr", lastErr,
+		)
+	}
+}
+
+// otlpExporter wraps the OTLP exporter to keep track of failed exports, which
+// are logged by the sink rather than by OpenTelemetry's global error handler.
+type otlpExporter struct {
+	sdkmetric.Exporter
+	logger logging.Logger
+
+	mtx       sync.Mutex
+	exports   int
+	failed    int
+	lastError error
+}
+
+func (e *otlpExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	// nothing to export until some transactions have been sent
+	if !hasMetrics(rm) {
+		return nil
+	}
+	err := e.Exporter.Export(ctx, rm)
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.exports++
+	if err != nil {
+		if e.failed == 0 {
+			e.logger.Error("Failed to export metrics to OpenTelemetry collector", "err", err)
+		}
+		e.failed++
+		e.lastError = err
+	}
+	return nil
+}
+
+func (e *otlpExporter) results() (exports, failed int, lastError error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.exports, e.failed, e.lastError
+}
+
+func hasMetrics(rm *metricdata.ResourceMetrics) bool {
+	for _, sm := range rm.ScopeMetrics {
+		if len(sm.Metrics) > 0 {
+			return true
+		}
+	}
+	return false
+}
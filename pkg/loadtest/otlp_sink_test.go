@@ -0,0 +1,111 @@
+package loadtest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func otlpAttributeMap(kvs []*commonpb.KeyValue) map[string]string {
+	attrs := make(map[string]string)
+	for _, kv := range kvs {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	return attrs
+}
+
+func TestOTLPSink(t *testing.T) {
+	var mtx sync.Mutex
+	var reqs []*colmetricpb.ExportMetricsServiceRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, otlpMetricsPath, r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		req := &colmetricpb.ExportMetricsServiceRequest{}
+		assert.NoError(t, proto.Unmarshal(body, req))
+		mtx.Lock()
+		reqs = append(reqs, req)
+		mtx.Unlock()
+	}))
+	defer srv.Close()
+
+	sink, err := newOTLPSink(
+		srv.URL,
+		time.Hour,
+		map[string]string{"tmloadtest.run.id": "run1", "tmloadtest.worker.id": "w1"},
+		[]float64{0.001, 0.01},
+		logging.NewNoopLogger(),
+	)
+	require.NoError(t, err)
+	sink.TxsSent("ws://localhost:26657/websocket", 3, 750)
+	sink.TxFailed("ws://localhost:26657/websocket")
+	sink.BroadcastLatency("ws://localhost:26657/websocket", 500*time.Microsecond)
+	sink.BroadcastLatency("ws://localhost:26657/websocket", 10*time.Millisecond)
+	sink.BroadcastLatency("ws://localhost:26657/websocket", time.Second)
+	sink.Close()
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	// the final export must happen on close, regardless of the interval
+	require.Len(t, reqs, 1)
+	require.Len(t, reqs[0].ResourceMetrics, 1)
+	rm := reqs[0].ResourceMetrics[0]
+	attrs := otlpAttributeMap(rm.Resource.Attributes)
+	assert.Equal(t, "tm-load-test", attrs["service.name"])
+	assert.Equal(t, "run1", attrs["tmloadtest.run.id"])
+	assert.Equal(t, "w1", attrs["tmloadtest.worker.id"])
+
+	require.Len(t, rm.ScopeMetrics, 1)
+	assert.Equal(t, otlpScopeName, rm.ScopeMetrics[0].Scope.Name)
+	metrics := make(map[string]*metricpb.Metric)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	endpointAttrs := map[string]string{"endpoint": "ws://localhost:26657/websocket"}
+	for name, expected := range map[string]int64{"tmloadtest.txs": 3, "tmloadtest.bytes": 750, "tmloadtest.failures": 1} {
+		sum := metrics[name].GetSum()
+		require.NotNil(t, sum, name)
+		assert.True(t, sum.IsMonotonic, name)
+		assert.Equal(t, metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, sum.AggregationTemporality, name)
+		require.Len(t, sum.DataPoints, 1, name)
+		assert.Equal(t, endpointAttrs, otlpAttributeMap(sum.DataPoints[0].Attributes), name)
+		assert.Equal(t, expected, sum.DataPoints[0].GetAsInt(), name)
+	}
+	latency := metrics["tmloadtest.broadcast_latency"].GetHistogram()
+	require.NotNil(t, latency)
+	require.Len(t, latency.DataPoints, 1)
+	dp := latency.DataPoints[0]
+	assert.Equal(t, uint64(3), dp.Count)
+	assert.InDelta(t, 1.0105, dp.GetSum(), 1e-9)
+	assert.Equal(t, []float64{0.001, 0.01}, dp.ExplicitBounds)
+	assert.Equal(t, []uint64{1, 1, 1}, dp.BucketCounts)
+}
+
+func TestOTLPSinkExportFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	sink, err := newOTLPSink(srv.URL+"/v1/metrics", time.Hour, nil, defaultBroadcastLatencyBuckets, logging.NewNoopLogger())
+	require.NoError(t, err)
+	sink.TxsSent("ws://localhost:26657/websocket", 1, 250)
+	sink.Close()
+
+	exports, failed, lastErr := sink.exporter.results()
+	assert.Equal(t, 1, exports)
+	assert.Equal(t, 1, failed)
+	assert.ErrorContains(t, lastErr, "500 Internal Server Error")
+}
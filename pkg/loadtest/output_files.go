@@ -0,0 +1,87 @@
+package loadtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandHome expands a leading ~ in the given path to the current user's home
+// directory.
+func expandHome(filename string) (string, error) {
+	if filename != "~" && !strings.HasPrefix(filename, "~/") {
+		return filename, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to expand %s: %w", filename, err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(filename, "~")), nil
+}
+
+// prepareOutputFile expands a leading ~ in the given output file's path and
+// creates any of its parent directories that are missing, returning the
+// expanded path.
+func prepareOutputFile(filename string) (string, error) {
+	filename, err := expandHome(filename)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory for %s: %w", filename, err)
+	}
+	return filename, nil
+}
+
+// ValidateOutputFiles checks that the load test won't overwrite any existing
+// statistics, timeseries or latency sample files, unless overwriting them is
+// allowed. Statistics that are appended or uploaded are never overwritten. With
+// several runs, each run's files are checked. Standalone load tests and
+// coordinators check their output files before starting anyway, so this is
+// only needed to check them up front.
+func (c Config) ValidateOutputFiles() error {
+	if c.StatsOverwrite {
+		return nil
+	}
+	for i := 0; i < c.runs(); i++ {
+		cfg, err := c.runConfig(i)
+		if err != nil {
+			return err
+		}
+		files := []string{cfg.RawStatsOutputFile, cfg.LatencySampleFile}
+		if !cfg.StatsAppend {
+			files = append(files, cfg.StatsOutputFile)
+		}
+		for _, filename := range files {
+			if len(c.Runs) > 0 {
+				filename = runOutputFile(filename, i)
+			}
+			if err := validateOutputFile(filename); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateOutputFile checks that the given output file (if any) doesn't
+// already exist.
+func validateOutputFile(filename string) error {
+	if len(filename) == 0 {
+		return nil
+	}
+	if u, _ := parseStatsUploadURL(filename); u != nil {
+		return nil
+	}
+	expanded, err := expandHome(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(expanded); err == nil {
+		return fmt.Errorf("output file %s already exists (use --overwrite-stats to overwrite it)", filename)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check output file %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateOutputFilesExisting(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "stats.csv")
+	require.NoError(t, os.WriteFile(existing, []byte("last night's results\n"), 0o644))
+
+	for _, cfg := range []Config{
+		{StatsOutputFile: existing},
+		{RawStatsOutputFile: existing},
+		{LatencySampleFile: existing},
+	} {
+		assert.EqualError(t, cfg.ValidateOutputFiles(), "output file "+existing+" already exists (use --overwrite-stats to overwrite it)")
+		cfg.StatsOverwrite = true
+		assert.NoError(t, cfg.ValidateOutputFiles())
+	}
+
+	// appended statistics are never overwritten
+	assert.NoError(t, Config{StatsOutputFile: existing, StatsAppend: true}.ValidateOutputFiles())
+	assert.Error(t, Config{RawStatsOutputFile: existing, StatsAppend: true}.ValidateOutputFiles())
+	// and neither are uploaded ones
+	assert.NoError(t, Config{StatsOutputFile: "stub://bucket/stats.csv", RawStatsOutputFile: "stub://bucket/raw.csv"}.ValidateOutputFiles())
+	assert.NoError(t, Config{StatsOutputFile: filepath.Join(dir, "new.csv")}.ValidateOutputFiles())
+	assert.NoError(t, Config{}.ValidateOutputFiles())
+}
+
+func TestValidateOutputFilesRuns(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "stats-run1.csv"), nil, 0o644))
+	cfg := Config{
+		StatsOutputFile: filepath.Join(dir, "stats.csv"),
+		Runs:            []json.RawMessage{json.RawMessage(`{}`), json.RawMessage(`{}`)},
+	}
+	assert.ErrorContains(t, cfg.ValidateOutputFiles(), "stats-run1.csv already exists")
+	// each run's file is checked, even if overridden
+	cfg.Runs[1] = json.RawMessage(`{"stats_output_file":"` + filepath.Join(dir, "other.csv") + `"}`)
+	assert.NoError(t, cfg.ValidateOutputFiles())
+}
+
+// Load tests run via the library refuse to overwrite existing files too.
+func TestRunRefusesToOverwriteOutputFiles(t *testing.T) {
+	existing := filepath.Join(t.TempDir(), "stats.csv")
+	require.NoError(t, os.WriteFile(existing, []byte("last night's results\n"), 0o644))
+	cfg := Config{Endpoints: []string{"ws://localhost:26657/websocket"}, StatsOutputFile: existing}
+
+	_, err := RunStandalone(context.Background(), cfg)
+	assert.ErrorContains(t, err, "already exists")
+	assert.Equal(t, FailureConfig, ClassifyError(err))
+
+	err = NewCoordinator(&cfg, &CoordinatorConfig{BindAddr: "localhost:0", ExpectWorkers: 1}).Run()
+	assert.ErrorContains(t, err, "already exists")
+	assert.Equal(t, FailureConfig, ClassifyError(err))
+
+	b, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "last night's results\n", string(b))
+}
+
+func TestPrepareOutputFileMissingDirectories(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "nightly", "2026-10-15", "stats.csv")
+	prepared, err := prepareOutputFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, filename, prepared)
+	assert.DirExists(t, filepath.Dir(filename))
+
+	out, err := newStatsOutput(filepath.Join(t.TempDir(), "raw", "timeseries.csv"))
+	require.NoError(t, err)
+	assert.DirExists(t, filepath.Dir(out.filename))
+	require.NoError(t, writeLatencySamples(filepath.Join(t.TempDir(), "samples", "latencies.csv"), nil))
+}
+
+func TestOutputFileTildeExpansion(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	expanded, err := expandHome("~/results/stats.csv")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "results", "stats.csv"), expanded)
+	expanded, err = expandHome("~")
+	require.NoError(t, err)
+	assert.Equal(t, home, expanded)
+	// only a leading ~ refers to the home directory
+	for _, filename := range []string{"results/~/stats.csv", "~user/stats.csv", "/tmp/stats.csv"} {
+		expanded, err = expandHome(filename)
+		require.NoError(t, err)
+		assert.Equal(t, filename, expanded)
+	}
+
+	prepared, err := prepareOutputFile("~/results/stats.csv")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "results", "stats.csv"), prepared)
+	assert.DirExists(t, filepath.Join(home, "results"))
+
+	require.NoError(t, os.WriteFile(prepared, nil, 0o644))
+	assert.EqualError(t, Config{StatsOutputFile: "~/results/stats.csv"}.ValidateOutputFiles(), "output file ~/results/stats.csv already exists (use --overwrite-stats to overwrite it)")
+}
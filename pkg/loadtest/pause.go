@@ -0,0 +1,90 @@
+package loadtest
+
+import (
+	"sync"
+	"time"
+)
+
+// pauseClock keeps track of whether a load test has been paused on request,
+// and for how long in total, so that the paused time can be excluded from
+// rate calculations.
+type pauseClock struct {
+	mtx       sync.RWMutex
+	paused    bool
+	pausedAt  time.Time     // When the current pause started.
+	pausedFor time.Duration // The total duration of all previous pauses.
+}
+
+// pause starts a pause at the given time, returning false if already paused.
+func (p *pauseClock) pause(now time.Time) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.paused {
+		return false
+	}
+	p.paused = true
+	p.pausedAt = now
+	return true
+}
+
+// resume ends the current pause at the given time, returning false if not
+// paused.
+func (p *pauseClock) resume(now time.Time) bool {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if !p.paused {
+		return false
+	}
+	p.paused = false
+	if now.After(p.pausedAt) {
+		p.pausedFor += now.Sub(p.pausedAt)
+	}
+	return true
+}
+
+// reset forgets about all previous pauses, ending the current one (if any).
+func (p *pauseClock) reset() {
+	p.mtx.Lock()
+	p.paused = false
+	p.pausedAt = time.Time{}
+	p.pausedFor = 0
+	p.mtx.Unlock()
+}
+
+// restore forgets about all previous pauses, other than for their given total
+// duration.
+func (p *pauseClock) restore(pausedFor time.Duration) {
+	p.mtx.Lock()
+	p.paused = false
+	p.pausedAt = time.Time{}
+	p.pausedFor = pausedFor
+	p.mtx.Unlock()
+}
+
+func (p *pauseClock) isPaused() bool {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+	return p.paused
+}
+
+// pausedDuration returns the total time spent paused up until the given time,
+// including the current pause (if any).
+func (p *pauseClock) pausedDuration(until time.Time) time.Duration {
+	p.mtx.RLock()
+	defer p.mtx.RUnlock()
+	d := p.pausedFor
+	if p.paused && until.After(p.pausedAt) {
+		d += until.Sub(p.pausedAt)
+	}
+	return d
+}
+
+// activeSeconds returns the time elapsed between the given start and end
+// times, excluding any time spent paused.
+func (p *pauseClock) activeSeconds(start, end time.Time) float64 {
+	active := end.Sub(start) - p.pausedDuration(end)
+	if active < 0 {
+		return 0
+	}
+	return active.Seconds()
+}
@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package loadtest
+
+import "github.com/informalsystems/tm-load-test/internal/logging"
+
+// Pausing and resuming via signals is not supported on this platform.
+func trapPauseSignals(_, _ func(), _ logging.Logger) chan struct{} {
+	return make(chan struct{})
+}
@@ -0,0 +1,39 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package loadtest
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// trapPauseSignals calls onPause whenever SIGUSR1 is received, and onResume
+// whenever SIGUSR2 is received, until the returned channel is closed.
+func trapPauseSignals(onPause, onResume func(), logger logging.Logger) chan struct{} {
+	sigc := make(chan os.Signal, 1)
+	cancelTrap := make(chan struct{})
+	signal.Notify(sigc, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		defer signal.Stop(sigc)
+		for {
+			select {
+			case sig := <-sigc:
+				if sig == syscall.SIGUSR1 {
+					logger.Info("Caught pause signal")
+					onPause()
+				} else {
+					logger.Info("Caught resume signal")
+					onResume()
+				}
+
+			case <-cancelTrap:
+				logger.Debug("Pause signal trap cancelled")
+				return
+			}
+		}
+	}()
+	return cancelTrap
+}
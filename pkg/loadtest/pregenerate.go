@@ -0,0 +1,179 @@
+package loadtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const defaultPregenerateMemoryLimitMB = 1024
+
+// The approximate memory (in bytes) taken up by the bookkeeping of each
+// pre-generated transaction, over and above the transaction itself and its
+// request's parameters (e.g. slice headers and the JSON wrapping the params).
+const pregeneratedTxOverhead = 64
+
+// pregeneratedTx is a transaction generated before the load test started,
+// along with its priority (if the client supports priorities) and the
+// parameters of the request submitting it.
+type pregeneratedTx struct {
+	tx       []byte
+	priority int64
+	params   json.RawMessage
+}
+
+// pregenerateCount returns the number of transactions to pre-generate per
+// connection, which is 0 unless transactions are to be pre-generated.
+func (c Config) pregenerateCount() int {
+	if !c.PregenerateTxs {
+		return 0
+	}
+	if c.PregenerateCount > 0 {
+		return c.PregenerateCount
+	}
+	if c.Count > 0 {
+		return c.Count
+	}
+	return 0
+}
+
+func (c Config) pregenerateMemoryLimitMB() int {
+	if c.PregenerateMemoryLimitMB > 0 {
+		return c.PregenerateMemoryLimitMB
+	}
+	return defaultPregenerateMemoryLimitMB
+}
+
+// pregeneratedMemory estimates the memory (in bytes) taken up by the given
+// number of pre-generated transactions: each transaction, the base64-encoded
+// copy of it in its request's parameters, and their bookkeeping.
+func (c Config) pregeneratedMemory(txs int) uint64 {
+	perTx := c.Size + base64.StdEncoding.EncodedLen(c.Size) + pregeneratedTxOverhead
+	return uint64(txs) * uint64(perTx)
+}
+
+func (c Config) validatePregeneration() error {
+	if c.PregenerateCount < 0 {
+		return fmt.Errorf("expected pregenerate-count to be >= 0, but was %d", c.PregenerateCount)
+	}
+	if c.PregenerateMemoryLimitMB < 0 {
+		return fmt.Errorf("expected pregenerate-memory-limit-mb to be >= 0, but was %d", c.PregenerateMemoryLimitMB)
+	}
+	if !c.PregenerateTxs {
+		return nil
+	}
+	if c.Count < 1 && c.PregenerateCount == 0 {
+		return fmt.Errorf("pregenerate-txs requires either count or pregenerate-count to be set, to know how many transactions to pre-generate")
+	}
+	if c.Count > 0 && c.PregenerateCount > c.Count {
+		return fmt.Errorf("expected pregenerate-count to be <= count (%d), but was %d", c.Count, c.PregenerateCount)
+	}
+	return nil
+}
+
+// pregenerate generates the given number of transactions (and the parameters
+// of the requests submitting them) up front, which are then sent before any
+// generated on the fly. Must be called before the transactor is started.
+func (t *Transactor) pregenerate(count int) error {
+	pool := make([]pregeneratedTx, 0, count)
+	for i := 0; i < count; i++ {
+		tx, priority, err := t.generateTx()
+		if err != nil {
+			return fmt.Errorf("failed to pre-generate transaction: %w", err)
+		}
+		params, err := t.broadcastMethod.BuildParams(tx)
+		if err != nil {
+			return fmt.Errorf("failed to pre-generate request: %w", err)
+		}
+		pool = append(pool, pregeneratedTx{tx: tx, priority: priority, params: params})
+	}
+	t.pregenerated = pool
+	t.statsMtx.Lock()
+	t.pregeneratedCount = count
+	t.statsMtx.Unlock()
+	return nil
+}
+
+// nextTx returns the next transaction to send, along with its priority and,
+// if it was pre-generated, the parameters of the request submitting it. Once
+// the pre-generated transactions run out, they're generated on the fly. Must
+// only be called from the send loop.
+func (t *Transactor) nextTx() ([]byte, int64, json.RawMessage, error) {
+	if len(t.pregenerated) > 0 {
+		next := t.pregenerated[0]
+		// the transaction's memory can be reclaimed once it's been sent
+		t.pregenerated[0] = pregeneratedTx{}
+		t.pregenerated = t.pregenerated[1:]
+		if len(t.pregenerated) == 0 {
+			t.pregenerated = nil
+			t.logger.Info("Sent all pre-generated transactions - generating the rest as they're sent")
+		}
+		return next.tx, next.priority, next.params, nil
+	}
+	tx, priority, err := t.generateTx()
+	return tx, priority, nil, err
+}
+
+// getPregeneratedCount returns the number of transactions pre-generated by
+// the transactor.
+func (t *Transactor) getPregeneratedCount() int {
+	t.statsMtx.RLock()
+	defer t.statsMtx.RUnlock()
+	return t.pregeneratedCount
+}
+
+// pregenerate pre-generates the configured number of transactions for each
+// of the group's transactors concurrently, if transactions are to be
+// pre-generated, unless they're estimated to take up more memory than the
+// configured limit. Must be called before the group is started.
+func (g *TransactorGroup) pregenerate() error {
+	if g.config == nil {
+		return nil
+	}
+	count := g.config.pregenerateCount()
+	transactors := g.getTransactors()
+	if count == 0 || len(transactors) == 0 {
+		return nil
+	}
+	total := count * len(transactors)
+	estimated := g.config.pregeneratedMemory(total)
+	limitMB := g.config.pregenerateMemoryLimitMB()
+	if estimated > uint64(limitMB)<<20 {
+		return configError(fmt.Errorf(
+			"pre-generating %d transactions (%d for each of %d connections) would take up an estimated %d MB of memory, which exceeds the limit of %d MB - reduce pregenerate-count or increase pregenerate-memory-limit-mb",
+			total,
+			count,
+			len(transactors),
+			(estimated+(1<<20)-1)>>20,
+			limitMB,
+		))
+	}
+	g.logger.Info("Pre-generating transactions", "total", total, "perConnection", count, "estimatedMB", (estimated+(1<<20)-1)>>20)
+	start := time.Now()
+	errs := make(chan error, len(transactors))
+	for _, t := range transactors {
+		go func(t *Transactor) { errs <- t.pregenerate(count) }(t)
+	}
+	var err error
+	for range transactors {
+		if terr := <-errs; terr != nil && err == nil {
+			err = terr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	g.logger.Info("Pre-generated transactions", "total", total, "took", time.Since(start).Round(time.Millisecond).String())
+	return nil
+}
+
+// pregeneratedTxs returns the total number of transactions pre-generated by
+// the group's transactors.
+func (g *TransactorGroup) pregeneratedTxs() int {
+	total := 0
+	for _, t := range g.getTransactors() {
+		total += t.getPregeneratedCount()
+	}
+	return total
+}
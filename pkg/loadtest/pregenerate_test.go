@@ -0,0 +1,158 @@
+package loadtest_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidatePregeneration(t *testing.T) {
+	testCases := []struct {
+		name        string
+		count       int
+		pregenCount int
+		limitMB     int
+		valid       bool
+	}{
+		{"count", 10, 0, 0, true},
+		{"partial", 10, 5, 0, true},
+		{"unlimited count", -1, 100, 0, true},
+		{"no count", -1, 0, 0, false},
+		{"more than count", 10, 11, 0, false},
+		{"negative count", 10, -1, 0, false},
+		{"negative limit", 10, 0, -1, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := mockTestConfig("ws://localhost:26657/websocket")
+			cfg.PregenerateTxs = true
+			cfg.Count = tc.count
+			cfg.PregenerateCount = tc.pregenCount
+			cfg.PregenerateMemoryLimitMB = tc.limitMB
+			if tc.valid {
+				assert.NoError(t, cfg.Validate())
+			} else {
+				assert.Error(t, cfg.Validate())
+			}
+		})
+	}
+}
+
+// requireUniqueTxs checks that the mock endpoint received the given number of
+// transactions, none of them more than once.
+func requireUniqueTxs(t *testing.T, svr *mockRPCServer, count int) {
+	txs := svr.MempoolTxs()
+	require.Len(t, txs, count)
+	seen := make(map[string]bool, len(txs))
+	for _, tx := range txs {
+		require.False(t, seen[tx], "duplicate transaction: %s", tx)
+		seen[tx] = true
+	}
+}
+
+func TestStandalonePregeneratedTxs(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Connections = 2
+	cfg.Count = 20
+	cfg.Rate = 20
+	cfg.PregenerateTxs = true
+	require.NoError(t, cfg.Validate())
+
+	report, err := loadtest.RunStandalone(context.Background(), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 2*cfg.Count, report.Aggregate.TotalTxs)
+	assert.Equal(t, 2*cfg.Count, report.Aggregate.PregeneratedTxs)
+	requireUniqueTxs(t, svr, 2*cfg.Count)
+}
+
+func TestStandalonePartiallyPregeneratedTxs(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Count = 20
+	cfg.Rate = 20
+	cfg.PregenerateTxs = true
+	cfg.PregenerateCount = 5
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.csv")
+
+	// the rest are generated as they're sent
+	report, err := loadtest.RunStandalone(context.Background(), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.Count, report.Aggregate.TotalTxs)
+	assert.Equal(t, 5, report.Aggregate.PregeneratedTxs)
+	requireUniqueTxs(t, svr, cfg.Count)
+
+	// and pre-generation is noted in the statistics
+	f, err := os.Open(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	defer f.Close()
+	parsed, err := loadtest.ParseReport(f)
+	require.NoError(t, err)
+	assert.Equal(t, 5, parsed.Aggregate.PregeneratedTxs)
+}
+
+func TestPregenerateMemoryLimit(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Size = 10000
+	cfg.Count = 1000
+	cfg.PregenerateTxs = true
+	cfg.PregenerateMemoryLimitMB = 10
+
+	// an estimated 23 MB
+	_, err := loadtest.RunStandalone(context.Background(), cfg)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds the limit of 10 MB")
+	assert.Equal(t, loadtest.FailureConfig, loadtest.ClassifyError(err))
+	assert.Zero(t, svr.Requests())
+}
+
+func TestCoordinatorPregeneratedTxs(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.PregenerateTxs = true
+
+	coord := runCoordinatorWorkers(t, cfg, 2)
+	report := coord.Report()
+	require.NotNil(t, report)
+	assert.Equal(t, 2*cfg.Count, report.Aggregate.PregeneratedTxs)
+	for _, ws := range report.Workers {
+		assert.Equal(t, cfg.Count, ws.PregeneratedTxs, ws.ID)
+	}
+	requireUniqueTxs(t, svr, 2*cfg.Count)
+}
+
+// benchmarkSendRate runs a standalone load test that sends b.N transactions of
+// the given size through a single connection as fast as it can, reporting the
+// rate achieved. Pre-generation counts towards the time per operation, but not
+// towards the rate, just as it doesn't in the load test's statistics.
+func benchmarkSendRate(b *testing.B, size int, pregenerate bool) {
+	svr := newMockRPCServer(b, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(600)
+	cfg.Size = size
+	cfg.Count = b.N
+	cfg.Rate = float64(b.N)
+	cfg.PregenerateTxs = pregenerate
+	cfg.PregenerateMemoryLimitMB = 1 << 20
+
+	b.ResetTimer()
+	report, err := loadtest.RunStandalone(context.Background(), cfg)
+	b.StopTimer()
+	require.NoError(b, err)
+	require.Equal(b, b.N, report.Aggregate.TotalTxs)
+	b.ReportMetric(report.Aggregate.AvgTxRate, "txs/s")
+}
+
+func BenchmarkSendRate(b *testing.B) {
+	for _, size := range []int{250, 10000} {
+		b.Run(fmt.Sprintf("live_%db", size), func(b *testing.B) { benchmarkSendRate(b, size, false) })
+		b.Run(fmt.Sprintf("pregenerated_%db", size), func(b *testing.B) { benchmarkSendRate(b, size, true) })
+	}
+}
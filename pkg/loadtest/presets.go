@@ -0,0 +1,187 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// The names of the built-in configuration presets.
+const (
+	PresetSmoke  = "smoke"  // A brief, gentle load test, to check that everything works.
+	PresetSteady = "steady" // A sustained, moderate load.
+	PresetSpike  = "spike"  // A short burst of heavy load over many connections.
+	PresetSoak   = "soak"   // A light load over several hours, to expose slow leaks and degradation.
+)
+
+// preset is a built-in configuration preset: a set of values for the settings
+// that most determine the load generated, which other settings (and explicitly
+// given flags) are combined with.
+type preset struct {
+	name        string
+	description string
+	apply       func(cfg *Config)
+}
+
+// The built-in presets, in the order in which they're listed.
+var presets = []preset{
+	{
+		name:        PresetSmoke,
+		description: "A brief, gentle load test, to check that everything works",
+		apply: func(cfg *Config) {
+			cfg.Connections = 1
+			cfg.Rate = 10
+			cfg.SendPeriod = Duration(time.Second)
+			cfg.Time = Duration(30 * time.Second)
+		},
+	},
+	{
+		name:        PresetSteady,
+		description: "A sustained, moderate load",
+		apply: func(cfg *Config) {
+			cfg.Connections = 2
+			cfg.Rate = 250
+			cfg.SendPeriod = Duration(time.Second)
+			cfg.Time = Duration(10 * time.Minute)
+		},
+	},
+	{
+		name:        PresetSpike,
+		description: "A short burst of heavy load over many connections",
+		apply: func(cfg *Config) {
+			cfg.Connections = 8
+			cfg.Rate = 1000
+			cfg.SendPeriod = Duration(time.Second)
+			cfg.Time = Duration(2 * time.Minute)
+		},
+	},
+	{
+		name:        PresetSoak,
+		description: "A light load over several hours, sent in small sub-second batches, to expose slow leaks and degradation",
+		apply: func(cfg *Config) {
+			cfg.Connections = 1
+			cfg.Rate = 1
+			cfg.SendPeriod = Duration(200 * time.Millisecond)
+			cfg.Time = Duration(6 * time.Hour)
+		},
+	},
+}
+
+// PresetNames returns the names of the built-in configuration presets.
+func PresetNames() []string {
+	names := make([]string, len(presets))
+	for i, p := range presets {
+		names[i] = p.name
+	}
+	return names
+}
+
+func lookupPreset(name string) (preset, error) {
+	for _, p := range presets {
+		if p.name == name {
+			return p, nil
+		}
+	}
+	return preset{}, fmt.Errorf("unknown preset %s (expected one of %s)", name, strings.Join(PresetNames(), ", "))
+}
+
+// PresetConfig returns the configuration of the built-in preset with the given
+// name: the default configuration (as given by the command line's defaults),
+// with the preset's connections, rate, send period and duration. Only the
+// endpoints remain to be set.
+func PresetConfig(name string) (Config, error) {
+	p, err := lookupPreset(name)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := defaultConfig()
+	p.apply(&cfg)
+	return cfg, nil
+}
+
+// SmokeConfig returns the configuration of the smoke preset: a single
+// connection sending 10 transactions per second for 30 seconds.
+func SmokeConfig() Config {
+	cfg, _ := PresetConfig(PresetSmoke)
+	return cfg
+}
+
+// SteadyConfig returns the configuration of the steady preset: 2 connections
+// each sending 250 transactions per second for 10 minutes.
+func SteadyConfig() Config {
+	cfg, _ := PresetConfig(PresetSteady)
+	return cfg
+}
+
+// SpikeConfig returns the configuration of the spike preset: 8 connections
+// each sending 1000 transactions per second for 2 minutes.
+func SpikeConfig() Config {
+	cfg, _ := PresetConfig(PresetSpike)
+	return cfg
+}
+
+// SoakConfig returns the configuration of the soak preset: a single connection
+// sending 5 transactions per second, one every 200ms, for 6 hours.
+func SoakConfig() Config {
+	cfg, _ := PresetConfig(PresetSoak)
+	return cfg
+}
+
+// defaultConfig returns the load testing configuration with the command
+// line's defaults.
+func defaultConfig() Config {
+	var cfg Config
+	fs := pflag.NewFlagSet("defaults", pflag.ContinueOnError)
+	addLoadTestFlags(fs, &cfg, &CLIConfig{DefaultClientFactory: "kvstore"})
+	addStandaloneFlags(fs, &cfg)
+	addCoordinatorLoadTestFlags(fs, &cfg)
+	return cfg
+}
+
+// presetArg returns the preset named by the --preset flag among the given
+// command line arguments, if any. Presets are applied over the defaults before
+// the configuration file, the environment and the command line are read, so
+// that any of them can override the preset's values.
+func presetArg(args []string) string {
+	var name string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		switch {
+		case arg == "--preset" && i+1 < len(args):
+			name = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--preset="):
+			name = strings.TrimPrefix(arg, "--preset=")
+		}
+	}
+	return name
+}
+
+// writePresets writes a table of the built-in presets, with their effective
+// values, to the given writer.
+func writePresets(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Preset\tConnections\tRate\tSend period\tTime\tTxs/sec per endpoint\tDescription")
+	for _, p := range presets {
+		cfg, _ := PresetConfig(p.name)
+		fmt.Fprintf(
+			w,
+			"%s\t%d\t%g\t%s\t%s\t%g\t%s\n",
+			p.name,
+			cfg.Connections,
+			cfg.Rate,
+			cfg.SendPeriod,
+			cfg.Time,
+			cfg.expectedTxRate(cfg.Connections),
+			p.description,
+		)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,93 @@
+package loadtest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresetsValidate(t *testing.T) {
+	constructors := map[string]func() Config{
+		PresetSmoke:  SmokeConfig,
+		PresetSteady: SteadyConfig,
+		PresetSpike:  SpikeConfig,
+		PresetSoak:   SoakConfig,
+	}
+	require.Len(t, constructors, len(PresetNames()))
+	for _, name := range PresetNames() {
+		cfg, err := PresetConfig(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, cfg, constructors[name](), name)
+		cfg.Endpoints = []string{"ws://localhost:26657/websocket"}
+		assert.NoError(t, cfg.Validate(), name)
+		// presets don't trip the feasibility check either
+		cfg.StrictFeasibility = true
+		assert.NoError(t, cfg.Validate(), name)
+	}
+
+	_, err := PresetConfig("stress")
+	assert.EqualError(t, err, "unknown preset stress (expected one of smoke, steady, spike, soak)")
+}
+
+func TestPresetsStartFromDefaults(t *testing.T) {
+	cfg := SoakConfig()
+	assert.Equal(t, "kvstore", cfg.ClientFactory)
+	assert.Equal(t, 250, cfg.Size)
+	assert.Equal(t, -1, cfg.Count)
+	assert.Equal(t, "async", cfg.BroadcastTxMethod)
+	assert.Equal(t, 5.0, cfg.expectedTxRate(cfg.Connections))
+}
+
+func TestPresetFlagPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "rate.yaml", "rate: 500\n")
+	testCases := []struct {
+		args     []string
+		env      map[string]string
+		expected map[string]string
+	}{
+		{
+			// the preset overrides the defaults
+			args:     []string{"standalone", "--preset", "smoke"},
+			expected: map[string]string{"rate": "10", "connections": "1", "time": "30s", "send-period": "1s", "size": "250"},
+		},
+		{
+			// and flags override the preset
+			args:     []string{"standalone", "--preset=soak", "-T", "1h", "-c", "2"},
+			expected: map[string]string{"rate": "1", "connections": "2", "time": "1h0m0s", "send-period": "200ms"},
+		},
+		{
+			args:     []string{"standalone", "-r", "20"},
+			expected: map[string]string{"rate": "20", "connections": "1", "time": "1m0s"},
+		},
+		{
+			// as do the configuration file and the environment (set last, as
+			// it remains set for the rest of the test)
+			args:     []string{"coordinator", "--config", path, "--preset", "spike"},
+			env:      map[string]string{EnvPrefix + "CONNECTIONS": "3"},
+			expected: map[string]string{"rate": "500", "connections": "3", "time": "2m0s"},
+		},
+	}
+	for _, tc := range testCases {
+		for k, v := range tc.env {
+			t.Setenv(k, v)
+		}
+		cmd, err := parseTestCLI(t, tc.args...)
+		require.NoError(t, err, tc.args)
+		assertFlags(t, cmd, tc.expected)
+	}
+
+	_, err := buildCLI(testCLI, []string{"standalone", "--preset", "stress"}, logging.NewNoopLogger())
+	assert.ErrorContains(t, err, "unknown preset stress")
+}
+
+func TestWritePresets(t *testing.T) {
+	var buf bytes.Buffer
+	writePresets(&buf)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, len(presets)+1)
+	assert.Equal(t, []string{"soak", "1", "1", "200ms", "6h0m0s", "5"}, strings.Fields(lines[4])[:6])
+}
@@ -0,0 +1,191 @@
+package loadtest
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// progressRecordMsg is the message of the structured progress records logged
+// at info level once per progress interval, so that log pipelines can build
+// timelines of load tests. The message and the records' fields are documented,
+// so they must be kept stable.
+const progressRecordMsg = "Progress record"
+
+// The workers of the progress records logged by the coordinator (which cover
+// all of its workers) and by standalone load tests.
+const (
+	progressRecordAggregate  = "aggregate"
+	progressRecordStandalone = "standalone"
+)
+
+// progressStatus summarizes how far along a load test is.
+type progressStatus struct {
+	Ratio    float64       // The fraction of the load test completed, between 0 and 1.
+	TotalTxs int           // The total number of transactions sent so far.
+	TxBytes  int64         // The total number of transaction bytes sent so far.
+	MaxTxs   int           // The maximum number of transactions to send, or 0 if unlimited.
+	TxRate   float64       // The transaction rate (tx/sec) achieved recently.
+	Failures int           // The total number of error responses received so far.
+	Elapsed  time.Duration // The time elapsed since the start of the load test.
+	ETA      time.Duration // The estimated time remaining.
+}
+
+// computeProgress estimates the progress of a load test with the given
+// configuration, executed through the given number of transactors. The load
+// test ends either when its time limit is reached or, if configured, once all
+// transactors have sent their maximum number of transactions, whichever comes
+// first. Count-bounded load tests only have a time limit if capped, so their
+// progress is measured in transactions.
+func computeProgress(cfg *Config, transactors int, elapsed time.Duration, totalTxs int, txRate float64, failures int) progressStatus {
+	p := progressStatus{TotalTxs: totalTxs, TxRate: txRate, Failures: failures, Elapsed: elapsed}
+	timeLimit := cfg.timeLimit()
+	if timeLimit > 0 {
+		p.Ratio = elapsed.Seconds() / timeLimit.Seconds()
+		p.ETA = timeLimit - elapsed
+	}
+	if cfg.Count > 0 && transactors > 0 {
+		maxTxs := cfg.Count * transactors
+		p.MaxTxs = maxTxs
+		if countRatio := float64(totalTxs) / float64(maxTxs); countRatio > p.Ratio {
+			p.Ratio = countRatio
+		}
+		// until there's a rate to go by, assume the configured one
+		if txRate <= 0 && cfg.countBounded() && cfg.SendPeriod > 0 {
+			txRate = cfg.expectedTxRate(transactors)
+		}
+		if txRate > 0 {
+			if eta := time.Duration(float64(maxTxs-totalTxs) / txRate * float64(time.Second)); eta < p.ETA || timeLimit == 0 {
+				p.ETA = eta
+			}
+		}
+	}
+	if p.Ratio > 1 {
+		p.Ratio = 1
+	}
+	if p.ETA < 0 {
+		p.ETA = 0
+	}
+	return p
+}
+
+// logKVs returns the progress as key/value pairs for logging.
+func (p progressStatus) logKVs() []interface{} {
+	return []interface{}{
+		"complete", fmt.Sprintf("%.1f%%", p.Ratio*100),
+		"rate", fmt.Sprintf("%.2f txs/sec", p.TxRate),
+		"failures", p.Failures,
+		"eta", p.ETA.Round(time.Second).String(),
+	}
+}
+
+// progressRecord is the structured record of a load test's progress logged
+// once per progress interval.
+type progressRecord struct {
+	Worker     string        // The ID of the worker, progressRecordAggregate or progressRecordStandalone.
+	Elapsed    time.Duration // The time elapsed since the start of the load test.
+	TotalTxs   int           // The total number of transactions sent so far.
+	TxRate     float64       // The transaction rate (tx/sec) achieved recently.
+	TxBytes    int64         // The total number of transaction bytes sent so far.
+	Failures   int           // The total number of error responses received so far.
+	P95Latency float64       // The 95th percentile of the broadcast latencies so far, in seconds.
+}
+
+// newProgressRecord creates the progress record of the given worker from its
+// progress and the given broadcast latencies (if any).
+func newProgressRecord(worker string, p progressStatus, latencies *latencySketch) progressRecord {
+	r := progressRecord{
+		Worker:   worker,
+		Elapsed:  p.Elapsed,
+		TotalTxs: p.TotalTxs,
+		TxRate:   p.TxRate,
+		TxBytes:  p.TxBytes,
+		Failures: p.Failures,
+	}
+	if latencies != nil {
+		r.P95Latency = latencies.Quantile(0.95)
+	}
+	return r
+}
+
+// log logs the record at info level via the given logger.
+func (r progressRecord) log(logger Logger) {
+	logger.Info(
+		progressRecordMsg,
+		"worker", r.Worker,
+		"elapsed_s", roundTo(r.Elapsed.Seconds(), 3),
+		"txs_total", r.TotalTxs,
+		"tx_rate", roundTo(r.TxRate, 2),
+		"bytes_total", r.TxBytes,
+		"failures", r.Failures,
+		"p95_latency_ms", roundTo(r.P95Latency*1000, 3),
+	)
+}
+
+// roundTo rounds the given value to the given number of decimal places.
+func roundTo(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
+
+func (p progressStatus) String() string {
+	return fmt.Sprintf(
+		"Progress: %.1f%% complete, %.2f txs/sec, %d failures, ETA %s",
+		p.Ratio*100,
+		p.TxRate,
+		p.Failures,
+		p.ETA.Round(time.Second).String(),
+	)
+}
+
+// progressMonitor periodically computes a TransactorGroup's progress, passing
+// it to a callback.
+type progressMonitor struct {
+	g        *TransactorGroup
+	interval time.Duration
+	callback func(progressStatus)
+
+	lastTxs  int
+	lastTime time.Time
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func newProgressMonitor(g *TransactorGroup, interval time.Duration, callback func(progressStatus)) *progressMonitor {
+	return &progressMonitor{
+		g:        g,
+		interval: interval,
+		callback: callback,
+		lastTime: g.getStartTime(),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (m *progressMonitor) run() {
+	defer close(m.stopped)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			totals := m.g.timeseriesTotals()
+			txRate := float64(totals.txs-m.lastTxs) / now.Sub(m.lastTime).Seconds()
+			m.lastTxs, m.lastTime = totals.txs, now
+			m.callback(m.g.progress(txRate))
+
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// Stop blocks until the monitor has stopped.
+func (m *progressMonitor) Stop() {
+	close(m.stop)
+	<-m.stopped
+}
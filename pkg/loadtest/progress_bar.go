@@ -0,0 +1,145 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Progress display modes for standalone load tests.
+const (
+	ProgressModeBar  = "bar"  // A single status line, updated in place (if stdout is a terminal).
+	ProgressModeLog  = "log"  // Periodic progress log lines.
+	ProgressModeNone = "none" // No progress reporting.
+)
+
+var validProgressModes = map[string]interface{}{
+	ProgressModeBar:  nil,
+	ProgressModeLog:  nil,
+	ProgressModeNone: nil,
+}
+
+const (
+	progressBarRefreshInterval = 1 * time.Second
+	progressBarWidth           = 30
+)
+
+// progressBar renders a load test's progress as a single line, which is
+// rewritten in place using carriage returns.
+type progressBar struct {
+	mtx   sync.Mutex
+	out   io.Writer
+	drawn bool // Is there a status line on screen that needs clearing?
+}
+
+func newProgressBar(out io.Writer) *progressBar {
+	return &progressBar{out: out}
+}
+
+// Render replaces the current status line with the given progress.
+func (b *progressBar) Render(p progressStatus) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	fmt.Fprintf(b.out, "\r%s\033[K", p.barLine())
+	b.drawn = true
+}
+
+// Clear removes the current status line, if any.
+func (b *progressBar) Clear() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.clear()
+}
+
+func (b *progressBar) clear() {
+	if b.drawn {
+		fmt.Fprint(b.out, "\r\033[K")
+		b.drawn = false
+	}
+}
+
+// Finish removes the status line and renders a summary of the given
+// statistics.
+func (b *progressBar) Finish(stats AggregateStats) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.clear()
+	writeSummaryTable(b.out, stats)
+}
+
+// barLine formats the progress as a status line, e.g.
+//
+//	[=========>          ]  45.0% | 4500/10000 txs | 1000.00 txs/sec | 0 failures | 4s elapsed, 5s remaining
+func (p progressStatus) barLine() string {
+	filled := int(p.Ratio * progressBarWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < progressBarWidth {
+		bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+	}
+	txs := fmt.Sprintf("%d txs", p.TotalTxs)
+	if p.MaxTxs > 0 {
+		txs = fmt.Sprintf("%d/%d txs", p.TotalTxs, p.MaxTxs)
+	}
+	return fmt.Sprintf(
+		"[%s] %5.1f%% | %s | %.2f txs/sec | %d failures | %s elapsed, %s remaining",
+		bar,
+		p.Ratio*100,
+		txs,
+		p.TxRate,
+		p.Failures,
+		p.Elapsed.Round(time.Second),
+		p.ETA.Round(time.Second),
+	)
+}
+
+func writeSummaryTable(out io.Writer, stats AggregateStats) {
+	stats.Compute()
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Load test summary")
+	fmt.Fprintf(w, "  Duration\t%.3fs\n", stats.TotalTimeSeconds)
+	fmt.Fprintf(w, "  Transactions\t%d\t(%.2f txs/sec)\n", stats.TotalTxs, stats.AvgTxRate)
+	fmt.Fprintf(w, "  Bytes\t%d\t(%.2f bytes/sec)\n", stats.TotalBytes, stats.AvgDataRate)
+	fmt.Fprintf(w, "  Failures\t%d\t(%.2f%% success)\n", stats.FailedTxs, stats.SuccessRatio*100)
+	if stats.ErroredConnections > 0 {
+		fmt.Fprintf(w, "  Errored connections\t%d\n", stats.ErroredConnections)
+	}
+	if stats.PregeneratedTxs > 0 {
+		fmt.Fprintf(w, "  Pre-generated txs\t%d\n", stats.PregeneratedTxs)
+	}
+	if stats.BroadcastLatency != nil {
+		fmt.Fprintf(
+			w,
+			"  Broadcast latency\tp50 %s\tp99 %s\tmax %s\n",
+			secondsToDuration(stats.BroadcastLatency.P50),
+			secondsToDuration(stats.BroadcastLatency.P99),
+			secondsToDuration(stats.BroadcastLatency.Max),
+		)
+	}
+	if stats.CommitLatency != nil {
+		fmt.Fprintf(
+			w,
+			"  Commit latency\tp50 %s\tp99 %s\tmax %s\n",
+			secondsToDuration(stats.CommitLatency.P50),
+			secondsToDuration(stats.CommitLatency.P99),
+			secondsToDuration(stats.CommitLatency.Max),
+		)
+	}
+	_ = w.Flush()
+}
+
+func secondsToDuration(secs float64) time.Duration {
+	return time.Duration(secs * float64(time.Second)).Round(time.Microsecond)
+}
+
+// isTerminal returns whether the given file is a terminal (character device).
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
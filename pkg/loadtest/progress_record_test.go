@@ -0,0 +1,140 @@
+package loadtest_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedBuffer is a buffer that's safe to write to from many goroutines.
+type lockedBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// captureJSONLogs logs via logrus until the end of the test, capturing the
+// entries logged (at info level and above), and returns a function that parses
+// the progress records captured so far.
+func captureJSONLogs(t *testing.T) func() []map[string]interface{} {
+	loadtest.SetLogger(loadtest.NewLogrusLogger)
+	std := logrus.StandardLogger()
+	prevLevel, prevFormatter, prevOut := std.GetLevel(), std.Formatter, std.Out
+	t.Cleanup(func() {
+		loadtest.SetLogger(nil)
+		logrus.SetLevel(prevLevel)
+		logrus.SetFormatter(prevFormatter)
+		logrus.SetOutput(prevOut)
+	})
+	buf := &lockedBuffer{}
+	logrus.SetOutput(buf)
+	require.NoError(t, logging.Configure("info", "json"))
+
+	return func() []map[string]interface{} {
+		var records []map[string]interface{}
+		scanner := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+		for scanner.Scan() {
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "line: %s", scanner.Text())
+			if entry["msg"] == "Progress record" {
+				records = append(records, entry)
+			}
+		}
+		return records
+	}
+}
+
+// requireProgressRecord checks that the given record has all of the
+// documented fields, with plausible values.
+func requireProgressRecord(t *testing.T, record map[string]interface{}, worker string) {
+	assert.Equal(t, "info", record["level"])
+	assert.Equal(t, worker, record["worker"])
+	for _, field := range []string{"elapsed_s", "txs_total", "tx_rate", "bytes_total", "failures", "p95_latency_ms"} {
+		require.Contains(t, record, field)
+		v, ok := record[field].(float64)
+		require.True(t, ok, "%s is not a number: %v", field, record[field])
+		assert.GreaterOrEqual(t, v, 0.0, field)
+	}
+	assert.Greater(t, record["elapsed_s"], 0.0)
+}
+
+func TestStandaloneProgressRecords(t *testing.T) {
+	records := captureJSONLogs(t)
+
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(4)
+	cfg.Count = -1
+	cfg.ProgressInterval = seconds(1)
+	cfg.ProgressMode = loadtest.ProgressModeLog
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	recs := records()
+	require.GreaterOrEqual(t, len(recs), 2)
+	for _, rec := range recs {
+		requireProgressRecord(t, rec, "standalone")
+	}
+	// the totals are cumulative
+	last := recs[len(recs)-1]
+	assert.Greater(t, last["txs_total"], 0.0)
+	assert.Equal(t, last["txs_total"].(float64)*float64(cfg.Size), last["bytes_total"])
+	assert.GreaterOrEqual(t, last["txs_total"], recs[0]["txs_total"])
+	assert.Greater(t, last["elapsed_s"], recs[0]["elapsed_s"])
+}
+
+func TestProgressRecordsDisabled(t *testing.T) {
+	records := captureJSONLogs(t)
+
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+	cfg.Count = -1
+	cfg.ProgressInterval = 0
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	assert.Empty(t, records())
+}
+
+func TestCoordinatorProgressRecords(t *testing.T) {
+	records := captureJSONLogs(t)
+
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(4)
+	cfg.Count = -1
+	cfg.ProgressInterval = seconds(1)
+	runCoordinatorWorkers(t, cfg, 2)
+
+	byWorker := make(map[string][]map[string]interface{})
+	for _, rec := range records() {
+		worker, _ := rec["worker"].(string)
+		byWorker[worker] = append(byWorker[worker], rec)
+	}
+	for _, worker := range []string{"worker0", "worker1", "aggregate"} {
+		require.NotEmpty(t, byWorker[worker], worker)
+		for _, rec := range byWorker[worker] {
+			requireProgressRecord(t, rec, worker)
+		}
+	}
+	assert.Equal(t, "coordinator", byWorker["aggregate"][0]["ctx"])
+	assert.Equal(t, "worker[worker0]", byWorker["worker0"][0]["ctx"])
+}
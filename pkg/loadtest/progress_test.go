@@ -0,0 +1,103 @@
+package loadtest
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeProgress(t *testing.T) {
+	testCases := []struct {
+		name          string
+		time, count   int
+		transactors   int
+		elapsed       time.Duration
+		totalTxs      int
+		txRate        float64
+		expectedRatio float64
+		expectedETA   time.Duration
+	}{
+		{"time limited", 100, -1, 2, 25 * time.Second, 500, 20, 0.25, 75 * time.Second},
+		{"count limited", 100, 100, 2, 10 * time.Second, 100, 10, 0.5, 10 * time.Second},
+		{"time limit before count", 20, 1000, 1, 10 * time.Second, 100, 10, 0.5, 10 * time.Second},
+		{"no rate yet", 100, 100, 1, 0, 0, 0, 0, 100 * time.Second},
+		{"overrun", 10, -1, 1, 12 * time.Second, 100, 10, 1, 0},
+		// without a time limit, progress is measured in transactions
+		{"count bounded", 0, 100, 2, 100 * time.Second, 50, 0.5, 0.25, 300 * time.Second},
+		// assuming the configured rate of 1 tx/sec per transactor
+		{"count bounded without rate", 0, 100, 2, 0, 0, 0, 0, 100 * time.Second},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{Time: Duration(time.Duration(tc.time) * time.Second), Count: tc.count, Rate: 1, SendPeriod: Duration(time.Second)}
+			p := computeProgress(cfg, tc.transactors, tc.elapsed, tc.totalTxs, tc.txRate, 3)
+			assert.InDelta(t, tc.expectedRatio, p.Ratio, 1e-9)
+			assert.Equal(t, tc.expectedETA, p.ETA)
+			assert.Equal(t, tc.txRate, p.TxRate)
+			assert.Equal(t, 3, p.Failures)
+			assert.Equal(t, tc.totalTxs, p.TotalTxs)
+			assert.Equal(t, tc.elapsed, p.Elapsed)
+		})
+	}
+}
+
+func TestProgressStatusString(t *testing.T) {
+	p := progressStatus{Ratio: 0.425, TxRate: 123.456, Failures: 2, ETA: 13400 * time.Millisecond}
+	assert.Equal(t, "Progress: 42.5% complete, 123.46 txs/sec, 2 failures, ETA 13s", p.String())
+}
+
+func TestProgressBarLine(t *testing.T) {
+	p := progressStatus{
+		Ratio:    0.45,
+		TotalTxs: 4500,
+		MaxTxs:   10000,
+		TxRate:   1000,
+		Failures: 1,
+		Elapsed:  4200 * time.Millisecond,
+		ETA:      5500 * time.Millisecond,
+	}
+	assert.Equal(t, "[=============>                ]  45.0% | 4500/10000 txs | 1000.00 txs/sec | 1 failures | 4s elapsed, 6s remaining", p.barLine())
+
+	p = progressStatus{Ratio: 1, TotalTxs: 20}
+	assert.Equal(t, "[==============================] 100.0% | 20 txs | 0.00 txs/sec | 0 failures | 0s elapsed, 0s remaining", p.barLine())
+}
+
+func TestProgressBar(t *testing.T) {
+	buf := new(bytes.Buffer)
+	bar := newProgressBar(buf)
+	bar.Clear()
+	assert.Empty(t, buf.String())
+
+	bar.Render(progressStatus{TotalTxs: 10})
+	bar.Clear()
+	bar.Finish(AggregateStats{TotalTxs: 10, TotalBytes: 2500, TotalTimeSeconds: 2, FailedTxs: 1})
+	out := buf.String()
+	assert.Regexp(t, `^\r\[.*\| 10 txs \|.*\x1b\[K\r\x1b\[K`, out)
+	assert.Contains(t, out, "Load test summary\n")
+	assert.Regexp(t, `Transactions +10 +\(5\.00 txs/sec\)`, out)
+	assert.Regexp(t, `Failures +1 +\(90\.00% success\)\n`, out)
+}
+
+func TestAttachProgressBar(t *testing.T) {
+	std := logrus.StandardLogger()
+	level := std.GetLevel()
+	defer std.SetLevel(level)
+	std.SetLevel(logrus.InfoLevel)
+
+	buf := new(bytes.Buffer)
+	bar := newProgressBar(buf)
+	detach := attachProgressBar(bar)
+	assert.Equal(t, logrus.WarnLevel, std.GetLevel())
+	bar.Render(progressStatus{})
+	// logging must clear the status line
+	require.NoError(t, std.Hooks.Fire(logrus.WarnLevel, logrus.NewEntry(std)))
+	assert.True(t, bytes.HasSuffix(buf.Bytes(), []byte("\r\x1b[K")))
+
+	detach()
+	assert.Equal(t, logrus.InfoLevel, std.GetLevel())
+	assert.Empty(t, std.Hooks[logrus.WarnLevel])
+}
@@ -0,0 +1,62 @@
+package loadtest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WorkerProtocolVersion is the version ("major.minor") of the protocol spoken
+// between the coordinator and its workers. The minor version must be
+// incremented when fields are added to the messages they exchange, and the
+// major version when messages change in any other way. A coordinator and
+// worker can work together as long as their major versions match and their
+// minor versions differ by at most one, since either side ignores fields it
+// doesn't know about.
+const WorkerProtocolVersion = "1.8"
+
+// The protocol version assumed for peers that don't state theirs, which
+// predate protocol versioning.
+const unversionedProtocol = "0.0"
+
+// checkProtocolVersions returns an error if the given coordinator and worker
+// protocol versions are incompatible.
+func checkProtocolVersions(coordVersion, workerVersion string) error {
+	coordMajor, coordMinor, cerr := parseProtocolVersion(coordVersion)
+	workerMajor, workerMinor, werr := parseProtocolVersion(workerVersion)
+	compatible := cerr == nil && werr == nil && coordMajor == workerMajor && coordMinor-workerMinor <= 1 && workerMinor-coordMinor <= 1
+	if !compatible {
+		return fmt.Errorf(
+			"incompatible protocol versions: coordinator speaks %s, but worker speaks %s (major versions must match, and minor versions can differ by at most 1)",
+			describeProtocolVersion(coordVersion),
+			describeProtocolVersion(workerVersion),
+		)
+	}
+	return nil
+}
+
+func parseProtocolVersion(version string) (int, int, error) {
+	if len(version) == 0 {
+		version = unversionedProtocol
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("expected a protocol version of the form major.minor, but got %s", version)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid major protocol version in %s: %w", version, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid minor protocol version in %s: %w", version, err)
+	}
+	return major, minor, nil
+}
+
+func describeProtocolVersion(version string) string {
+	if len(version) == 0 {
+		return "an unversioned protocol (from before " + WorkerProtocolVersion + ")"
+	}
+	return version
+}
@@ -1,14 +1,16 @@
 package loadtest
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/informalsystems/tm-load-test/internal/logging"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 // remoteWorker encapsulates the logic and transport-layer interaction between
@@ -20,19 +22,40 @@ type remoteWorker struct {
 	sock  *simpleSocket // The simpler interface to our websocket connection.
 
 	// Remote worker state
-	mtx           sync.RWMutex
-	id            string
-	txCount       int
-	state         workerState
-	logger        logging.Logger
-	stateMetric   prometheus.Gauge // A numeric representation of the state variable.
-	txCountMetric prometheus.Gauge // A way for us to expose the txCount variable via Prometheus.
-
-	stateCtrl chan remoteWorkerStateCtrlMsg
-	stop      chan struct{}
-	stopped   chan struct{}
+	mtx             sync.RWMutex
+	id              string
+	labels          map[string]string // The labels the worker registered with.
+	build           *BuildInfo        // The build of tm-load-test the worker runs, if it said when registering.
+	txCount         int
+	state           workerState
+	joinedAt        float64  // How far into the load test (in seconds) the worker was accepted, if it joined once the test was underway.
+	startDelay      float64  // How long (in seconds) the worker must wait before it starts sending, if the coordinator staggers workers' starts.
+	run             int      // The index of the run the worker is taking part in, if the coordinator executes several runs back to back.
+	resuming        bool     // Whether the worker is reconnecting to resume a load test it was already taking part in.
+	reconnectTime   Duration // How long the worker keeps trying to reconnect if its connection is lost during the load test.
+	encoding        string   // The encoding in which messages are exchanged with the worker, once it's been accepted.
+	txRate          float64  // The transaction rate (tx/sec) at which the worker must send, if the coordinator splits a total rate amongst its workers.
+	clientFactories []string // The client factories the worker supports, if it said when registering.
+	logger          logging.Logger
+	stateMetric     prometheus.Gauge // A numeric representation of the state variable.
+	txCountMetric   prometheus.Gauge // A way for us to expose the txCount variable via Prometheus.
+
+	stateCtrl      chan remoteWorkerStateCtrlMsg
+	pauseCtrl      chan struct{} // Signalled when the worker is to be paused or resumed.
+	pauseRequested atomic.Bool   // Whether the worker is to be paused (or resumed) when pauseCtrl is next signalled.
+	cancelCtrl     chan struct{} // Signalled when the worker is to cancel its load test.
+	shutdownCtrl   chan bool     // Signalled (with whether to force it) when the worker is to shut down.
+	rateCtrl       chan struct{} // Signalled when the worker's transaction rate changes.
+	updates        chan remoteWorkerUpdate
+	lastHeardAt    atomic.Int64 // When (in Unix nanoseconds) we last heard from the worker.
+	stop           chan struct{}
+	stopped        chan struct{}
 }
 
+// errRemoteWorkerShutdown is returned once a remote worker has been told to
+// shut down, ending its interaction with the coordinator cleanly.
+var errRemoteWorkerShutdown = errors.New("remote worker shut down")
+
 var workerStateMetricValues = map[workerState]float64{
 	workerConnected: 0,
 	workerAccepted:  1,
@@ -40,6 +63,15 @@ var workerStateMetricValues = map[workerState]float64{
 	workerTesting:   3,
 	workerFailed:    4,
 	workerCompleted: 5,
+	workerPaused:    6,
+	workerCancelled: 7,
+}
+
+// remoteWorkerUpdate is a message read from the worker (other than a
+// heartbeat), or the error that stopped us from reading any further.
+type remoteWorkerUpdate struct {
+	msg workerMsg
+	err error
 }
 
 type remoteWorkerStateCtrlMsg struct {
@@ -58,12 +90,18 @@ func newRemoteWorker(conn *websocket.Conn, coord *Coordinator) *remoteWorker {
 			ssFlushOnStop(true),
 			ssSendCloseMessage(false),
 			ssParentCtx("remoteWorker"),
+			ssLoggerFactory(coord.newLogger),
 		),
-		logger:    logging.NewNoopLogger(),
-		state:     workerConnected,
-		stateCtrl: make(chan remoteWorkerStateCtrlMsg, 3),
-		stop:      make(chan struct{}, 1),
-		stopped:   make(chan struct{}, 1),
+		logger:       logging.NewNoopLogger(),
+		state:        workerConnected,
+		stateCtrl:    make(chan remoteWorkerStateCtrlMsg, 3),
+		pauseCtrl:    make(chan struct{}, 1),
+		cancelCtrl:   make(chan struct{}, 1),
+		shutdownCtrl: make(chan bool, 1),
+		rateCtrl:     make(chan struct{}, 1),
+		updates:      make(chan remoteWorkerUpdate),
+		stop:         make(chan struct{}, 1),
+		stopped:      make(chan struct{}, 1),
 	}
 	return rs
 }
@@ -80,6 +118,103 @@ func (rw *remoteWorker) StartLoadTest() error {
 	return rw.sendCtrlMsg(workerTesting)
 }
 
+// SetPaused asks the worker to pause (or resume) its load test. It doesn't
+// wait for the request to be relayed to the worker, and if several requests
+// are made in quick succession, only the latest is relayed.
+func (rw *remoteWorker) SetPaused(paused bool) {
+	rw.pauseRequested.Store(paused)
+	select {
+	case rw.pauseCtrl <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel asks the worker to cancel its load test and report the statistics it
+// has gathered so far as its final update. It doesn't wait for the request to
+// be relayed to the worker.
+func (rw *remoteWorker) Cancel() {
+	select {
+	case rw.cancelCtrl <- struct{}{}:
+	default:
+	}
+}
+
+// Shutdown asks the worker to shut down and exit cleanly. A worker in the
+// middle of its load test only does so if forced, in which case it reports the
+// statistics it has gathered so far as its final update (as if cancelled). It
+// doesn't wait for the request to be relayed to the worker.
+func (rw *remoteWorker) Shutdown(force bool) {
+	select {
+	case rw.shutdownCtrl <- force:
+	default:
+	}
+}
+
+// SetTxRate changes the transaction rate (tx/sec) at which the worker must
+// send. A worker whose load test is underway is told its new rate without
+// waiting for the request to be relayed to it, while others get it when told to
+// start.
+func (rw *remoteWorker) SetTxRate(rate float64) {
+	rw.mtx.Lock()
+	rw.txRate = rate
+	rw.mtx.Unlock()
+	select {
+	case rw.rateCtrl <- struct{}{}:
+	default:
+	}
+}
+
+func (rw *remoteWorker) getTxRate() float64 {
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+	return rw.txRate
+}
+
+func (rw *remoteWorker) setJoinedAt(elapsed float64) {
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+	rw.joinedAt = elapsed
+}
+
+func (rw *remoteWorker) getJoinedAt() float64 {
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+	return rw.joinedAt
+}
+
+func (rw *remoteWorker) setStartDelay(delay float64) {
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+	rw.startDelay = delay
+}
+
+func (rw *remoteWorker) getStartDelay() float64 {
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+	return rw.startDelay
+}
+
+func (rw *remoteWorker) setRun(run int) {
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+	rw.run = run
+}
+
+func (rw *remoteWorker) getRun() int {
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+	return rw.run
+}
+
+// nextRun moves the worker on to the next run, which it takes part in from
+// its start.
+func (rw *remoteWorker) nextRun() {
+	rw.mtx.Lock()
+	defer rw.mtx.Unlock()
+	rw.run++
+	rw.joinedAt = 0
+}
+
 // Fail can be called outside of the goroutine that's running the Run method to
 // trigger a failure in the remote worker and shut down the local connection. It
 // returns any error that may have occurred in communicating the state change
@@ -100,57 +235,130 @@ func (rw *remoteWorker) Stop() {
 
 func (rw *remoteWorker) eventLoop() { //处理来自协调器的控制消息和与协调器的状态同步
 	var err error
+	registered, lost := false, false
 
 	defer func() {
+		// interrupt the read in progress (if any), rather than waiting for it
+		// to time out
+		_ = rw.sock.conn.SetReadDeadline(time.Now())
 		rw.sock.Stop()
 		close(rw.stopped)
 		rw.logger.Debug("Remote worker event loop shut down")
-		rw.coord.UnregisterRemoteWorker(rw.ID(), err)
+		// connections that never got as far as registering must not affect
+		// the load test (or a registered worker with the same ID)
+		if registered {
+			rw.coord.UnregisterRemoteWorker(rw, lost, err)
+		}
 	}()
 
 	// the first thing we need to do is get the worker's ID
-	if err = rw.readID(); err != nil {
+	token, version, err := rw.readRegistration()
+	if err != nil {
 		_ = rw.sock.WriteWorkerMsg(workerMsg{State: workerFailed, Error: err.Error()})
 		return
 	}
 
+	// there's no point going any further with a worker we can't understand
+	if err = checkProtocolVersions(WorkerProtocolVersion, version); err != nil {
+		rw.logger.Error("Rejecting worker", "err", err)
+		_ = rw.sock.WriteWorkerMsg(workerMsg{State: workerRejected, Error: err.Error(), ProtocolVersion: WorkerProtocolVersion})
+		return
+	}
+
+	if err = rw.coord.authenticateWorker(rw, token); err != nil {
+		_ = rw.sock.WriteWorkerMsg(workerMsg{State: workerRejected, Error: err.Error()})
+		return
+	}
+
+	// a worker that can't create the load test's clients would only fail
+	// once the load test starts
+	if err = rw.coord.checkClientFactories(rw); err != nil {
+		_ = rw.sock.WriteWorkerMsg(workerMsg{State: workerRejected, Error: err.Error()})
+		return
+	}
+
 	// ask the coordinator to register this worker
 	if err = rw.registerRemoteWorker(); err != nil {
 		_ = rw.sock.WriteWorkerMsg(workerMsg{State: workerRejected, Error: err.Error()})
 		return
 	}
+	registered = true
 
 	// We only now know what the remote worker's ID is, so now we can create its
 	// metrics.
 	rw.createMetrics()
 
-	// wait until the coordinator indicates that the load test can start, or fail
-	if err = rw.waitForStart(); err != nil {
+	// from now on the worker sends heartbeats, so we read from it continuously
+	rw.lastHeardAt.Store(time.Now().UnixNano())
+	go rw.readLoop()
+	go rw.monitorHeartbeats()
+
+	// wait until the coordinator indicates that the load test can start, or
+	// fail (a resuming worker's load test is already underway)
+	if rw.resuming {
+		rw.setState(workerTesting)
+	} else if err = rw.waitForStart(); err != nil {
+		if errors.Is(err, errRemoteWorkerShutdown) {
+			rw.logger.Info("Remote worker shut down")
+			err = nil
+			return
+		}
 		rw.logger.Error("Failed while waiting for load test to start", "err", err)
 		return
 	}
 
-	// receive updates from the worker
-	if err = rw.receiveTestingUpdates(); err != nil {
-		rw.logger.Error("Failed while receiving testing updates from worker", "err", err)
-		return
+	for {
+		// receive updates from the worker
+		if lost, err = rw.receiveTestingUpdates(); err != nil {
+			rw.logger.Error("Failed while receiving testing updates from worker", "err", err)
+			return
+		}
+		if rw.getState() == workerCancelled || !rw.moreRuns() {
+			break
+		}
+		// the worker stays connected, and takes part in the next run from
+		// its start
+		rw.nextRun()
+		if err = rw.waitForStart(); err != nil {
+			if errors.Is(err, errRemoteWorkerShutdown) {
+				rw.logger.Info("Remote worker shut down")
+				err = nil
+				return
+			}
+			rw.logger.Error("Failed while waiting for next run to start", "err", err)
+			return
+		}
 	}
 
 	rw.logger.Info("Remote worker completed testing")
 }
 
-// Attempts to obtain the remote worker's ID.
-func (rw *remoteWorker) readID() error {
+// Attempts to obtain the remote worker's ID (and whether it's resuming its
+// load test), returning the auth token it presented (if any) and the version
+// of the protocol it speaks.
+func (rw *remoteWorker) readRegistration() (string, string, error) {
 	msg, err := rw.sock.ReadWorkerMsg()
 	if err != nil {
-		return err
+		return "", "", err
 	}
 	if len(msg.ID) == 0 {
-		return fmt.Errorf("expected non-nil ID for new worker")
+		return "", "", fmt.Errorf("expected non-nil ID for new worker")
 	}
-	rw.setID(msg.ID)
-	rw.logger.Info("Worker connected")
-	return nil
+	if err := validateWorkerLabels(msg.Labels); err != nil {
+		return "", "", err
+	}
+	rw.setIdentity(msg.ID, msg.Labels)
+	rw.resuming = msg.Resume
+	rw.reconnectTime = msg.MaxReconnectTime
+	rw.encoding = negotiateEncoding(msg.Encodings)
+	rw.clientFactories = msg.ClientFactories
+	rw.build = msg.Build
+	version := "unknown"
+	if rw.build != nil {
+		version = rw.build.String()
+	}
+	rw.logger.Info("Worker connected", "resuming", msg.Resume, "version", version, "protocolVersion", describeProtocolVersion(msg.ProtocolVersion), "encoding", rw.encoding)
+	return msg.AuthToken, msg.ProtocolVersion, nil
 }
 
 func (rw *remoteWorker) registerRemoteWorker() error {
@@ -158,13 +366,77 @@ func (rw *remoteWorker) registerRemoteWorker() error {
 	if err := rw.coord.RegisterRemoteWorker(rw); err != nil {
 		return err
 	}
-	cfg := rw.coord.config()
-	// tell the worker it's been accepted and give it its configuration
-	return rw.sock.WriteWorkerMsg(workerMsg{
-		ID:     rw.id,
-		State:  workerAccepted,
-		Config: &cfg,
-	})
+	cfg := rw.coord.workerConfig(rw.ID())
+	// tell the worker it's been accepted and give it its configuration (still
+	// as JSON, after which we switch to the negotiated encoding)
+	if err := rw.sock.WriteWorkerMsg(workerMsg{
+		ID:                rw.id,
+		State:             workerAccepted,
+		Config:            &cfg,
+		ElapsedSeconds:    rw.getJoinedAt(),
+		HeartbeatInterval: Duration(rw.coord.coordCfg.heartbeatInterval()),
+		ProtocolVersion:   WorkerProtocolVersion,
+		Encoding:          rw.encoding,
+	}); err != nil {
+		return err
+	}
+	if rw.encoding == msgEncodingMsgpack {
+		rw.sock.useBinary()
+	}
+	return nil
+}
+
+// readLoop reads messages from the worker until reading fails, relaying them
+// (other than heartbeats) via the updates channel. If we don't hear from the
+// worker within the heartbeat timeout, we consider it dead.
+func (rw *remoteWorker) readLoop() {
+	timeout := rw.coord.coordCfg.heartbeatTimeout()
+	for {
+		msg, err := rw.sock.ReadWorkerMsg(timeout)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				err = fmt.Errorf("no heartbeat from worker within %s", timeout)
+			}
+		} else {
+			rw.lastHeardAt.Store(time.Now().UnixNano())
+			if msg.State == workerHeartbeat {
+				continue
+			}
+		}
+		select {
+		case rw.updates <- remoteWorkerUpdate{msg: msg, err: err}:
+		case <-rw.stopped:
+			return
+		}
+		// a read that fails (or times out) leaves the connection unreadable
+		if err != nil {
+			return
+		}
+	}
+}
+
+// monitorHeartbeats counts the heartbeats the worker misses (i.e. each
+// heartbeat interval in which we don't hear from it at all), until the remote
+// worker stops.
+func (rw *remoteWorker) monitorHeartbeats() {
+	interval := rw.coord.coordCfg.heartbeatInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	missed := rw.coord.workerMissedHeartbeatsMetric.WithLabelValues(rw.ID())
+	// allow for some jitter in when heartbeats arrive
+	grace := interval + interval/2
+	for {
+		select {
+		case <-ticker.C:
+			if time.Since(time.Unix(0, rw.lastHeardAt.Load())) > grace {
+				missed.Inc()
+			}
+
+		case <-rw.stopped:
+			return
+		}
+	}
 }
 
 func (rw *remoteWorker) waitForStart() error {
@@ -172,53 +444,146 @@ func (rw *remoteWorker) waitForStart() error {
 	for {
 		select {
 		case msg := <-rw.stateCtrl:
-			msg.resp <- rw.sock.WriteWorkerMsg(workerMsg{ID: rw.id, State: msg.newState, Error: msg.err})
+			// the worker gets its latest rate when told to start
+			select {
+			case <-rw.rateCtrl:
+			default:
+			}
+			out := workerMsg{ID: rw.id, State: msg.newState, Error: msg.err, StartDelaySeconds: rw.getStartDelay(), TxRate: rw.getTxRate()}
+			if runs := rw.coord.runs; runs > 1 {
+				out.Run, out.Runs = rw.getRun(), runs
+			}
+			// the configuration may have been overridden since the worker
+			// registered, when the load test was started via the control API
+			// (workers joining later get the latest configuration anyway)
+			if msg.newState == workerTesting && rw.getJoinedAt() == 0 {
+				cfg := rw.coord.workerConfig(rw.ID())
+				out.Config = &cfg
+			}
+			msg.resp <- rw.sock.WriteWorkerMsg(out)
 			if msg.newState == workerTesting {
 				return nil
 			}
 			return fmt.Errorf("expected next worker state to be \"%s\", but was \"%s\"", workerTesting, msg.newState)
 
+		case force := <-rw.shutdownCtrl:
+			return rw.sendShutdown(force)
+
+		case u := <-rw.updates:
+			if u.err != nil {
+				return fmt.Errorf("failed to read from remote worker: %s", u.err.Error())
+			}
+			if u.msg.State == workerFailed {
+				return fmt.Errorf("remote worker failed: %s", u.msg.Error)
+			}
+			rw.logger.Debug("Ignoring unexpected message from worker while waiting for load test to start", "state", u.msg.State)
+
 		case <-rw.stop:
+			// the coordinator may have asked the worker to shut down just
+			// before stopping
+			select {
+			case force := <-rw.shutdownCtrl:
+				return rw.sendShutdown(force)
+			default:
+			}
 			return fmt.Errorf("wait cancelled")
 		}
 	}
 }
 
-func (rw *remoteWorker) receiveTestingUpdates() error {
+// sendShutdown tells the worker to shut down, returning errRemoteWorkerShutdown
+// once it has been told.
+func (rw *remoteWorker) sendShutdown(force bool) error {
+	if err := rw.sock.WriteWorkerMsg(workerMsg{ID: rw.id, State: workerShutdown, Force: force}); err != nil {
+		return fmt.Errorf("failed to write to remote worker: %s", err.Error())
+	}
+	return errRemoteWorkerShutdown
+}
+
+// receiveTestingUpdates relays the worker's updates to the coordinator until it
+// completes its load testing. On failure, it returns whether the connection to
+// the worker was lost (as opposed to the worker itself failing).
+func (rw *remoteWorker) receiveTestingUpdates() (bool, error) {
 	rw.logger.Debug("Receiving load testing updates")
-	updateTicker := time.NewTicker(workerUpdateInterval) //定时，每三秒钟定时更新
-	defer updateTicker.Stop()
 	for {
 		select {
 		case msg := <-rw.stateCtrl: //接收stateCtrl通道上的消息
 			if msg.newState == workerFailed { //将失败消息写回到远程工作节点，然后返回相应的错误
 				msg.resp <- rw.sock.WriteWorkerMsg(workerMsg{ID: rw.id, State: msg.newState, Error: msg.err})
-				return fmt.Errorf("worker failed: %s", msg.err)
+				return false, fmt.Errorf("worker failed: %s", msg.err)
+			}
+
+		case <-rw.pauseCtrl:
+			newState := workerTesting
+			if rw.pauseRequested.Load() {
+				newState = workerPaused
+			}
+			if err := rw.sock.WriteWorkerMsg(workerMsg{ID: rw.id, State: newState}); err != nil {
+				return true, fmt.Errorf("failed to write to remote worker: %s", err.Error())
+			}
+			rw.setState(newState)
+
+		case <-rw.cancelCtrl:
+			if err := rw.sock.WriteWorkerMsg(workerMsg{ID: rw.id, State: workerCancelled}); err != nil {
+				return true, fmt.Errorf("failed to write to remote worker: %s", err.Error())
+			}
+
+		case <-rw.rateCtrl:
+			if err := rw.sock.WriteWorkerMsg(workerMsg{ID: rw.id, State: workerRate, TxRate: rw.getTxRate()}); err != nil {
+				return true, fmt.Errorf("failed to write to remote worker: %s", err.Error())
 			}
 
-		case <-updateTicker.C: //定时器触发
-			rw.logger.Debug("Attempting to receive update from remote worker")
-			msg, err := rw.sock.ReadWorkerMsg(workerUpdateInterval) //从工作节点读取更新消息
-			if err != nil {
-				return fmt.Errorf("failed to read from remote worker: %s", err.Error())
+		case force := <-rw.shutdownCtrl:
+			// workers refuse to shut down mid-test unless forced
+			if !force {
+				rw.logger.Debug("Not shutting down worker during load test without forcing it")
+				continue
 			}
+			if err := rw.sock.WriteWorkerMsg(workerMsg{ID: rw.id, State: workerShutdown, Force: true}); err != nil {
+				return true, fmt.Errorf("failed to write to remote worker: %s", err.Error())
+			}
+
+		case u := <-rw.updates: //从工作节点读取更新消息
+			if u.err != nil {
+				return true, fmt.Errorf("failed to read from remote worker: %s", u.err.Error())
+			}
+			msg := u.msg
 			if msg.State == workerFailed {
-				return fmt.Errorf("remote worker failed: %s", msg.Error)
+				return false, fmt.Errorf("remote worker failed: %s", msg.Error)
 			}
 			rw.setTxCount(msg.TxCount)        //成功读取消息，将消息中的交易数量和状态更新
 			rw.coord.ReceiveWorkerUpdate(msg) //ReceiveWorkerUpdate处理该消息
 			if msg.State == workerCompleted { //若该状态，设置相应的Prometheus指标并返回
 				rw.stateMetric.Set(workerStateMetricValues[workerCompleted])
-				return nil
+				// a worker that has another run to take part in waits for
+				// our acknowledgement before waiting for the run to start
+				if rw.moreRuns() {
+					if err := rw.sock.WriteWorkerMsg(workerMsg{ID: rw.id, State: workerCompleted}); err != nil {
+						return true, fmt.Errorf("failed to write to remote worker: %s", err.Error())
+					}
+				}
+				return false, nil
+			}
+			// a worker whose load test was cancelled reports the statistics
+			// it gathered until then as its final update
+			if msg.State == workerCancelled {
+				rw.setState(workerCancelled)
+				return false, nil
 			}
 
 		case <-rw.stop: //收到rw.stop通道上的信号，停止接收更新，取消循环并返回错误
 			rw.logger.Debug("Got update receiver cancellation notification")
-			return fmt.Errorf("update receiver cancelled")
+			return false, fmt.Errorf("update receiver cancelled")
 		}
 	}
 }
 
+// moreRuns returns whether the coordinator executes any more runs after the one
+// the worker has taken part in.
+func (rw *remoteWorker) moreRuns() bool {
+	return rw.getRun()+1 < rw.coord.runs
+}
+
 // Blocking send operation
 func (rw *remoteWorker) sendCtrlMsg(newState workerState, errors ...string) error {
 	rw.logger.Debug("Sending control message", "newState", newState)
@@ -247,10 +612,15 @@ func (rw *remoteWorker) sendCtrlMsg(newState workerState, errors ...string) erro
 	}
 }
 
-func (rw *remoteWorker) setID(id string) {
+func (rw *remoteWorker) setIdentity(id string, labels map[string]string) {
 	rw.mtx.Lock()
 	rw.id = id
-	rw.logger = logging.NewLogrusLogger(fmt.Sprintf("remoteWorker[%s]", id))
+	rw.labels = labels
+	if len(labels) > 0 {
+		rw.logger = newLogger(rw.coord.newLogger, fmt.Sprintf("remoteWorker[%s]", id), "labels", formatWorkerLabels(labels))
+	} else {
+		rw.logger = newLogger(rw.coord.newLogger, fmt.Sprintf("remoteWorker[%s]", id))
+	}
 	rw.mtx.Unlock()
 }
 
@@ -260,6 +630,12 @@ func (rw *remoteWorker) ID() string {
 	return rw.id
 }
 
+func (rw *remoteWorker) Labels() map[string]string {
+	rw.mtx.RLock()
+	defer rw.mtx.RUnlock()
+	return rw.labels
+}
+
 func (rw *remoteWorker) setTxCount(txCount int) {
 	rw.mtx.Lock()
 	rw.txCount = txCount
@@ -291,15 +667,29 @@ func (rw *remoteWorker) setState(newState workerState) {
 
 func (rw *remoteWorker) createMetrics() {
 	rw.mtx.Lock()
-	rw.stateMetric = promauto.NewGauge(prometheus.GaugeOpts{
+	rw.stateMetric = rw.registerGauge(prometheus.GaugeOpts{
 		Name: fmt.Sprintf("tmloadtest_worker_%s_state", rw.id),
 		Help: fmt.Sprintf("The current state of worker %s", rw.id),
 	})
 	rw.stateMetric.Set(workerStateMetricValues[workerAccepted])
 
-	rw.txCountMetric = promauto.NewGauge(prometheus.GaugeOpts{
+	rw.txCountMetric = rw.registerGauge(prometheus.GaugeOpts{
 		Name: fmt.Sprintf("tmloadtest_worker_%s_total_txs", rw.id),
 		Help: fmt.Sprintf("The total number of transactions sent by worker %s", rw.id),
 	})
 	rw.mtx.Unlock()
 }
+
+// registerGauge registers a gauge with the coordinator's registry, reusing the
+// existing one if a worker with the same ID has connected before.
+func (rw *remoteWorker) registerGauge(opts prometheus.GaugeOpts) prometheus.Gauge {
+	gauge := prometheus.NewGauge(opts)
+	if err := rw.coord.registry.Register(gauge); err != nil {
+		existing, ok := err.(prometheus.AlreadyRegisteredError)
+		if !ok {
+			panic(err)
+		}
+		return existing.ExistingCollector.(prometheus.Gauge)
+	}
+	return gauge
+}
@@ -0,0 +1,201 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	StatsFormatCSV  = "csv"  // Write aggregate statistics as a key/value CSV file (the default).
+	StatsFormatJSON = "json" // Write a full Report as a JSON document.
+)
+
+var validStatsFormats = map[string]interface{}{
+	StatsFormatCSV:  nil,
+	StatsFormatJSON: nil,
+}
+
+// Report is the complete set of results from a load test, as written to the
+// statistics output file in JSON format.
+type Report struct {
+	Version   string         `json:"version"`           // The version of tm-load-test that produced this report.
+	Build     BuildInfo      `json:"build"`             // The build of tm-load-test that produced this report.
+	Config    Config         `json:"config"`            // The effective configuration of the load test.
+	Aggregate AggregateStats `json:"aggregate"`         // Statistics aggregated across all workers (including per-endpoint statistics).
+	Workers   []WorkerStats  `json:"workers,omitempty"` // Per-worker statistics (only in coordinator/worker mode).
+}
+
+// WorkerStats summarizes the activity of a single worker.
+type WorkerStats struct {
+	ID               string            `json:"id"`                 // The worker's unique ID.
+	Labels           map[string]string `json:"labels,omitempty"`   // The labels the worker registered with, if any.
+	Build            *BuildInfo        `json:"build,omitempty"`    // The build of tm-load-test the worker ran, if it said when registering.
+	TotalTxs         int               `json:"total_txs"`          // The total number of transactions sent by the worker.
+	TotalBytes       int64             `json:"total_bytes"`        // The cumulative number of bytes sent as transactions by the worker.
+	TotalTimeSeconds float64           `json:"total_time_seconds"` // The time taken by the worker to send `TotalTxs` transactions.
+	Failures         int               `json:"failures"`           // The number of error responses received by the worker.
+
+	ErroredConnections int     `json:"errored_connections"` // The number of the worker's connections to endpoints that failed.
+	TargetTxRate       float64 `json:"target_tx_rate"`      // The configured transaction rate (tx/sec) across all of the worker's connections.
+
+	PregeneratedTxs int `json:"pregenerated_txs,omitempty"` // The number of transactions the worker generated before the load test started, if it pre-generated them.
+
+	PeakResources *ResourceUsage `json:"peak_resources,omitempty"` // The worker's peak resource usage (and total GC pause time) during the load test, if it reported it.
+
+	EndpointLatencies []EndpointLatency `json:"endpoint_latencies,omitempty"` // The latency of each endpoint measured by the worker before the load test, from the fastest to the slowest, if endpoints are selected by latency.
+
+	// Computed statistics
+	AvgTxRate float64 `json:"avg_tx_rate"` // The rate at which the worker submitted transactions (tx/sec).
+}
+
+func (s *WorkerStats) Compute() {
+	s.AvgTxRate = 0
+	if s.TotalTimeSeconds > 0.0 {
+		s.AvgTxRate = float64(s.TotalTxs) / s.TotalTimeSeconds
+	}
+}
+
+// NewReport builds a report from the given configuration and statistics,
+// computing any derived statistics.
+func NewReport(cfg Config, stats AggregateStats, workers []WorkerStats) Report {
+	stats.Compute()
+	for i := range workers {
+		workers[i].Compute()
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return Report{
+		Version:   Version(),
+		Build:     GetBuildInfo(),
+		Config:    cfg,
+		Aggregate: stats,
+		Workers:   workers,
+	}
+}
+
+// writeReport writes the given report to the specified file in the given
+// format.
+func writeReport(filename, format string, report Report) error {
+	switch format {
+	case StatsFormatJSON:
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(filename, append(b, '\n'), 0o644)
+
+	case StatsFormatCSV, "":
+		stats := csvAggregateStats(report.Config, report.Aggregate)
+		if report.Config.StatsAppend {
+			return appendAggregateStats(filename, NewStatsWriter(report.Config), report.Config.RunID, stats)
+		}
+		return writeAggregateStats(filename, NewStatsWriter(report.Config), stats, report.Workers)
+	}
+	return fmt.Errorf("unsupported statistics output format: %s", format)
+}
+
+// The formats in which the report subcommand renders a report.
+const (
+	ReportFormatTable   = "table"   // A human-readable summary table.
+	ReportFormatCSV     = "csv"     // The aggregate statistics, as written to a CSV statistics output file.
+	ReportFormatSummary = "summary" // The single-line JSON summary, as printed with --summary-json.
+)
+
+// LoadReport reads the report from the given statistics output file, which
+// must have been written in JSON format.
+func LoadReport(filename string) (Report, error) {
+	var report Report
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return report, fmt.Errorf("failed to read report: %w", err)
+	}
+	if err := json.Unmarshal(b, &report); err != nil {
+		return report, fmt.Errorf("failed to parse report %s (it must be written with --stats-output-format %s): %w", filename, StatsFormatJSON, err)
+	}
+	return report, nil
+}
+
+// renderReport writes the given report to w in the given format (one of the
+// ReportFormat constants), writing CSV with the given statistics writer.
+func renderReport(w io.Writer, report Report, format string, sw *StatsWriter) error {
+	report.Aggregate.Compute()
+	switch format {
+	case ReportFormatTable, "":
+		if len(report.Config.RunID) > 0 {
+			version := report.Version
+			// reports from before build metadata was recorded only have
+			// their version
+			if len(report.Build.Version) > 0 {
+				version = report.Build.String()
+			}
+			fmt.Fprintf(w, "Run %s (tm-load-test %s)\n", report.Config.RunID, version)
+		}
+		writeSummaryTable(w, report.Aggregate)
+		return nil
+
+	case ReportFormatCSV:
+		for i := range report.Workers {
+			report.Workers[i].Compute()
+		}
+		return sw.WriteAggregate(w, csvAggregateStats(report.Config, report.Aggregate), report.Workers)
+
+	case ReportFormatSummary:
+		b, err := json.Marshal(NewSummary(report.Config, &report.Aggregate, nil))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(b))
+		return err
+	}
+	return fmt.Errorf("unsupported report format: %s (expected %s, %s or %s)", format, ReportFormatTable, ReportFormatCSV, ReportFormatSummary)
+}
+
+// Summary is the single-line, machine-readable summary of a load test that is
+// printed to stdout on completion if requested.
+type Summary struct {
+	RunID            string  `json:"run_id"`                      // The identifier of the load test run.
+	Success          bool    `json:"success"`                     // Whether the load test completed successfully.
+	Error            string  `json:"error,omitempty"`             // Why the load test failed, if it did.
+	TotalTxs         int     `json:"total_txs"`                   // The total number of transactions sent.
+	TotalBytes       int64   `json:"total_bytes"`                 // The cumulative number of bytes sent as transactions.
+	TotalTimeSeconds float64 `json:"total_time_seconds"`          // The total time taken to send `TotalTxs` transactions.
+	AchievedTxRate   float64 `json:"achieved_tx_rate"`            // The transaction rate (tx/sec) actually achieved.
+	FailedTxs        int     `json:"failed_txs"`                  // The number of transactions to which an endpoint responded with an error.
+	StatsOutputFile  string  `json:"stats_output_file,omitempty"` // Where the aggregate statistics were written, if at all.
+}
+
+// NewSummary summarizes the outcome of a load test with the given
+// configuration. The statistics are nil if the load test failed before
+// producing any.
+func NewSummary(cfg Config, stats *AggregateStats, err error) Summary {
+	s := Summary{
+		RunID:           cfg.RunID,
+		Success:         err == nil,
+		StatsOutputFile: cfg.StatsOutputFile,
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	if stats != nil {
+		s.TotalTxs = stats.TotalTxs
+		s.TotalBytes = stats.TotalBytes
+		s.TotalTimeSeconds = stats.TotalTimeSeconds
+		s.AchievedTxRate = stats.AchievedTxRate
+		s.FailedTxs = stats.FailedTxs
+	}
+	return s
+}
+
+// printSummary writes the given summary to stdout as a single line of JSON.
+func printSummary(s Summary, logger logging.Logger) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		logger.Error("Failed to marshal summary", "err", err)
+		return
+	}
+	fmt.Fprintln(os.Stdout, string(b))
+}
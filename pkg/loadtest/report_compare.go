@@ -0,0 +1,191 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// reportMetric is a metric by which the results of two load tests are
+// compared.
+type reportMetric struct {
+	name          string
+	lowerIsBetter bool
+	// value returns the metric's value, and whether it's available
+	value  func(s AggregateStats) (float64, bool)
+	format func(v float64) string
+}
+
+// The metrics by which the results of two load tests are compared, in the
+// order in which they're displayed.
+var reportMetrics = []reportMetric{
+	{
+		name:   "tx_rate",
+		value:  func(s AggregateStats) (float64, bool) { return s.AvgTxRate, true },
+		format: func(v float64) string { return fmt.Sprintf("%.2f txs/sec", v) },
+	},
+	{
+		name:   "data_rate",
+		value:  func(s AggregateStats) (float64, bool) { return s.AvgDataRate, true },
+		format: func(v float64) string { return fmt.Sprintf("%.2f bytes/sec", v) },
+	},
+	{
+		name:          "failure_ratio",
+		lowerIsBetter: true,
+		value:         func(s AggregateStats) (float64, bool) { return 1 - s.SuccessRatio, s.TotalTxs > 0 },
+		format:        func(v float64) string { return fmt.Sprintf("%.4f", v) },
+	},
+	latencyMetric("broadcast_latency_p50", func(s AggregateStats) *LatencyStats { return s.BroadcastLatency }, func(l *LatencyStats) float64 { return l.P50 }),
+	latencyMetric("broadcast_latency_p90", func(s AggregateStats) *LatencyStats { return s.BroadcastLatency }, func(l *LatencyStats) float64 { return l.P90 }),
+	latencyMetric("broadcast_latency_p95", func(s AggregateStats) *LatencyStats { return s.BroadcastLatency }, func(l *LatencyStats) float64 { return l.P95 }),
+	latencyMetric("broadcast_latency_p99", func(s AggregateStats) *LatencyStats { return s.BroadcastLatency }, func(l *LatencyStats) float64 { return l.P99 }),
+	latencyMetric("broadcast_latency_max", func(s AggregateStats) *LatencyStats { return s.BroadcastLatency }, func(l *LatencyStats) float64 { return l.Max }),
+	latencyMetric("commit_latency_p50", func(s AggregateStats) *LatencyStats { return s.CommitLatency }, func(l *LatencyStats) float64 { return l.P50 }),
+	latencyMetric("commit_latency_p90", func(s AggregateStats) *LatencyStats { return s.CommitLatency }, func(l *LatencyStats) float64 { return l.P90 }),
+	latencyMetric("commit_latency_p95", func(s AggregateStats) *LatencyStats { return s.CommitLatency }, func(l *LatencyStats) float64 { return l.P95 }),
+	latencyMetric("commit_latency_p99", func(s AggregateStats) *LatencyStats { return s.CommitLatency }, func(l *LatencyStats) float64 { return l.P99 }),
+	latencyMetric("commit_latency_max", func(s AggregateStats) *LatencyStats { return s.CommitLatency }, func(l *LatencyStats) float64 { return l.Max }),
+}
+
+func latencyMetric(name string, stats func(AggregateStats) *LatencyStats, percentile func(*LatencyStats) float64) reportMetric {
+	return reportMetric{
+		name:          name,
+		lowerIsBetter: true,
+		value: func(s AggregateStats) (float64, bool) {
+			if l := stats(s); l != nil {
+				return percentile(l), true
+			}
+			return 0, false
+		},
+		format: func(v float64) string { return secondsToDuration(v).String() },
+	}
+}
+
+// reportMetricNames returns the names of the metrics by which reports can be
+// compared.
+func reportMetricNames() []string {
+	names := make([]string, len(reportMetrics))
+	for i, m := range reportMetrics {
+		names[i] = m.name
+	}
+	return names
+}
+
+// metricDelta is the change in a single metric between two load tests.
+type metricDelta struct {
+	metric    reportMetric
+	baseline  float64
+	candidate float64
+}
+
+// change returns the change in the metric as a percentage of its baseline
+// value, which is infinite if the baseline is zero (and the candidate isn't).
+func (d metricDelta) change() float64 {
+	if d.baseline == 0 {
+		if d.candidate == 0 {
+			return 0
+		}
+		return math.Copysign(math.Inf(1), d.candidate)
+	}
+	return (d.candidate - d.baseline) / math.Abs(d.baseline) * 100
+}
+
+// regression returns the percentage by which the metric got worse, which is
+// negative if it improved.
+func (d metricDelta) regression() float64 {
+	if d.metric.lowerIsBetter {
+		return d.change()
+	}
+	return -d.change()
+}
+
+// compareReports compares the metrics available in both of the given reports.
+func compareReports(baseline, candidate Report) []metricDelta {
+	baseline.Aggregate.Compute()
+	candidate.Aggregate.Compute()
+	deltas := make([]metricDelta, 0, len(reportMetrics))
+	for _, m := range reportMetrics {
+		b, bok := m.value(baseline.Aggregate)
+		c, cok := m.value(candidate.Aggregate)
+		if bok && cok {
+			deltas = append(deltas, metricDelta{metric: m, baseline: b, candidate: c})
+		}
+	}
+	return deltas
+}
+
+// writeComparison writes a human-readable table of the given changes in
+// metrics between a baseline and a candidate load test.
+func writeComparison(out io.Writer, deltas []metricDelta) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Metric\tBaseline\tCandidate\tDelta\tChange")
+	for _, d := range deltas {
+		delta := d.metric.format(d.candidate - d.baseline)
+		if d.candidate >= d.baseline {
+			delta = "+" + delta
+		}
+		change := "n/a"
+		if c := d.change(); !math.IsInf(c, 0) {
+			change = fmt.Sprintf("%+.2f%%", c)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", d.metric.name, d.metric.format(d.baseline), d.metric.format(d.candidate), delta, change)
+	}
+	_ = w.Flush()
+}
+
+// checkRegressions returns an error if any of the named metrics regressed by
+// more than the given percentage.
+func checkRegressions(deltas []metricDelta, metrics []string, threshold float64) error {
+	var regressed []string
+	for _, name := range metrics {
+		found := false
+		for _, d := range deltas {
+			if d.metric.name != name {
+				continue
+			}
+			found = true
+			if r := d.regression(); r > threshold {
+				regressed = append(regressed, fmt.Sprintf("%s by %.2f%%", name, r))
+			}
+		}
+		if !found {
+			return fmt.Errorf("metric %s is not available in both reports", name)
+		}
+	}
+	if len(regressed) > 0 {
+		return fmt.Errorf("regression beyond %g%% threshold: %s", threshold, strings.Join(regressed, ", "))
+	}
+	return nil
+}
+
+// validateReportMetrics checks that the given metrics can be compared.
+func validateReportMetrics(metrics []string) error {
+	for _, name := range metrics {
+		found := false
+		for _, m := range reportMetrics {
+			found = found || m.name == name
+		}
+		if !found {
+			return fmt.Errorf("unknown metric %s (expected one of %s)", name, strings.Join(reportMetricNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// loadReportFile reads a report from the given statistics output file, in any
+// format in which it can be written (see ParseReport).
+func loadReportFile(filename string) (Report, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to read report: %w", err)
+	}
+	defer f.Close()
+	report, err := ParseReport(f)
+	if err != nil {
+		return report, fmt.Errorf("%s: %w", filename, err)
+	}
+	return report, nil
+}
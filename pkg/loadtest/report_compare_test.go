@@ -0,0 +1,68 @@
+package loadtest
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareReports(t *testing.T) {
+	baseline, err := loadReportFile(filepath.Join("testdata", "reports", "baseline.csv"))
+	require.NoError(t, err)
+	candidate, err := loadReportFile(filepath.Join("testdata", "reports", "candidate.json"))
+	require.NoError(t, err)
+
+	deltas := compareReports(baseline, candidate)
+	// commit latency isn't available in either report
+	names := make([]string, len(deltas))
+	byName := make(map[string]metricDelta)
+	for i, d := range deltas {
+		names[i] = d.metric.name
+		byName[d.metric.name] = d
+	}
+	assert.Equal(t, []string{
+		"tx_rate", "data_rate", "failure_ratio",
+		"broadcast_latency_p50", "broadcast_latency_p90", "broadcast_latency_p95", "broadcast_latency_p99", "broadcast_latency_max",
+	}, names)
+	assert.InDelta(t, -10, byName["tx_rate"].change(), 1e-9)
+	assert.InDelta(t, 10, byName["tx_rate"].regression(), 1e-9)
+	assert.InDelta(t, 900, byName["failure_ratio"].change(), 1e-6)
+	assert.InDelta(t, 25, byName["broadcast_latency_p99"].regression(), 1e-9)
+	assert.Equal(t, 0.0, byName["broadcast_latency_p50"].regression())
+
+	var buf bytes.Buffer
+	writeComparison(&buf, deltas)
+	out := strings.Join(strings.Fields(buf.String()), " ")
+	assert.Contains(t, out, "Metric Baseline Candidate Delta Change")
+	assert.Contains(t, out, "tx_rate 1000.00 txs/sec 900.00 txs/sec -100.00 txs/sec -10.00%")
+	assert.Contains(t, out, "broadcast_latency_p99 40ms 50ms +10ms +25.00%")
+
+	assert.NoError(t, checkRegressions(deltas, []string{"tx_rate"}, 10))
+	assert.EqualError(t, checkRegressions(deltas, []string{"tx_rate"}, 9.5), "regression beyond 9.5% threshold: tx_rate by 10.00%")
+	assert.EqualError(t, checkRegressions(deltas, []string{"tx_rate", "broadcast_latency_p99", "broadcast_latency_p50"}, 5), "regression beyond 5% threshold: tx_rate by 10.00%, broadcast_latency_p99 by 25.00%")
+	// improvements are never regressions
+	assert.NoError(t, checkRegressions(compareReports(candidate, baseline), []string{"tx_rate", "failure_ratio", "broadcast_latency_p99"}, 0))
+	assert.ErrorContains(t, checkRegressions(deltas, []string{"commit_latency_p99"}, 5), "not available in both reports")
+}
+
+func TestMetricDeltaZeroBaseline(t *testing.T) {
+	failures := metricDelta{metric: reportMetrics[2], baseline: 0, candidate: 0.01}
+	assert.True(t, math.IsInf(failures.regression(), 1))
+	assert.Error(t, checkRegressions([]metricDelta{failures}, []string{"failure_ratio"}, 1000))
+	failures.candidate = 0
+	assert.Equal(t, 0.0, failures.regression())
+
+	var buf bytes.Buffer
+	writeComparison(&buf, []metricDelta{{metric: reportMetrics[2], candidate: 0.01}})
+	assert.Contains(t, buf.String(), "n/a")
+}
+
+func TestValidateReportMetrics(t *testing.T) {
+	assert.NoError(t, validateReportMetrics([]string{"tx_rate", "commit_latency_max"}))
+	assert.ErrorContains(t, validateReportMetrics([]string{"tx_rate", "latency"}), "unknown metric latency (expected one of tx_rate, data_rate, failure_ratio,")
+}
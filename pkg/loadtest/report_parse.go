@@ -0,0 +1,205 @@
+package loadtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseReport reads a report from a statistics output file in any of the
+// formats in which it can be written: a full report in JSON format, aggregate
+// statistics in CSV format (with either header, and any delimiter), or
+// statistics appended one run per row (in which case the last run is read).
+//
+// Only the aggregate statistics (and, for appended statistics, the run ID) can
+// be read back from CSV files; per-endpoint and per-worker statistics are
+// skipped.
+func ParseReport(r io.Reader) (Report, error) {
+	var report Report
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return report, fmt.Errorf("failed to read report: %w", err)
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return report, fmt.Errorf("empty report")
+	}
+	if b[0] == '{' {
+		if err := json.Unmarshal(b, &report); err != nil {
+			return report, fmt.Errorf("failed to parse report in JSON format: %w", err)
+		}
+		report.Aggregate.Compute()
+		return report, nil
+	}
+
+	header, _, _ := bufio.NewReader(bytes.NewReader(b)).ReadLine()
+	var values map[string]string
+	switch {
+	case hasHeaderPrefix(header, "parameter"):
+		values, err = parseStatsRecords(b, csvDelimiter(header, "parameter"))
+	case hasHeaderPrefix(header, "run_id"):
+		values, err = parseAppendedStats(b, csvDelimiter(header, "run_id"))
+	default:
+		return report, fmt.Errorf("unrecognized report format: expected JSON, or CSV with a parameter or run_id header, but got %q", header)
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to parse report in CSV format: %w", err)
+	}
+	report.Config.RunID = values["run_id"]
+	if report.Aggregate, err = aggregateStatsFromValues(values); err != nil {
+		return report, fmt.Errorf("failed to parse report in CSV format: %w", err)
+	}
+	return report, nil
+}
+
+func hasHeaderPrefix(header []byte, name string) bool {
+	return len(header) > len(name) && strings.EqualFold(string(header[:len(name)]), name)
+}
+
+// csvDelimiter returns the delimiter following the given first column name in
+// the header row.
+func csvDelimiter(header []byte, name string) rune {
+	r, _ := utf8.DecodeRune(header[len(name):])
+	return r
+}
+
+// parseStatsRecords reads aggregate statistics written one parameter per row,
+// returning their values by name.
+func parseStatsRecords(b []byte, delimiter rune) (map[string]string, error) {
+	rows, err := readStatsCSV(b, delimiter, 3)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(rows))
+	// the first row is the header
+	for _, row := range rows[1:] {
+		values[row[0]] = row[1]
+	}
+	return values, nil
+}
+
+// parseAppendedStats reads the last run's aggregate statistics from a file to
+// which they were appended one run per row, returning their values by name.
+func parseAppendedStats(b []byte, delimiter rune) (map[string]string, error) {
+	rows, err := readStatsCSV(b, delimiter, -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("no runs found")
+	}
+	header, last := rows[0], rows[len(rows)-1]
+	if len(last) != len(header) {
+		return nil, fmt.Errorf("expected %d columns in the last row, but got %d", len(header), len(last))
+	}
+	values := make(map[string]string, len(header))
+	for i, name := range header {
+		// statistics that weren't gathered are left empty
+		if len(last[i]) > 0 {
+			values[name] = last[i]
+		}
+	}
+	return values, nil
+}
+
+func readStatsCSV(b []byte, delimiter rune, fields int) ([][]string, error) {
+	if !validStatsCSVDelimiter(delimiter) {
+		return nil, fmt.Errorf("invalid delimiter: %q", delimiter)
+	}
+	cr := csv.NewReader(bytes.NewReader(b))
+	cr.Comma = delimiter
+	cr.FieldsPerRecord = fields
+	return cr.ReadAll()
+}
+
+// aggregateStatsFromValues builds aggregate statistics from the given values of
+// the parameters in the CSV output, computing any derived statistics.
+func aggregateStatsFromValues(values map[string]string) (AggregateStats, error) {
+	var stats AggregateStats
+	p := statsValueParser{values: values}
+	stats.TotalTimeSeconds = p.float("total_time", true)
+	stats.TotalTxs = p.int("total_txs", true)
+	stats.TotalBytes = int64(p.int("total_bytes", false))
+	stats.FailedTxs = p.int("failed_txs", false)
+	stats.ErroredConnections = p.int("errored_connections", false)
+	stats.TargetTxRate = p.float("target_tx_rate", false)
+	stats.PeakTxRate = p.float("peak_tx_rate", false)
+	stats.MinTxRate = p.float("min_tx_rate", false)
+	stats.TxRateStdDev = p.float("tx_rate_stddev", false)
+	stats.Status = values["status"]
+	stats.PregeneratedTxs = p.int("pregenerated_txs", false)
+	stats.BroadcastLatency = p.latency("broadcast_latency")
+	stats.CommitLatency = p.latency("commit_latency")
+	if _, ok := values["mempool_pauses"]; ok {
+		stats.Mempool = &MempoolStats{
+			Pauses:        p.int("mempool_pauses", true),
+			PausedSeconds: p.float("mempool_paused_time", false),
+		}
+	}
+	if p.err != nil {
+		return stats, p.err
+	}
+	stats.Compute()
+	return stats, nil
+}
+
+// statsValueParser parses statistics' values, keeping the first error it
+// encounters.
+type statsValueParser struct {
+	values map[string]string
+	err    error
+}
+
+func (p *statsValueParser) value(name string, required bool) (string, bool) {
+	v, ok := p.values[name]
+	if !ok && required && p.err == nil {
+		p.err = fmt.Errorf("missing %s", name)
+	}
+	return v, ok
+}
+
+func (p *statsValueParser) float(name string, required bool) float64 {
+	v, ok := p.value(name, required)
+	if !ok {
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid value for %s: %q", name, v)
+	}
+	return f
+}
+
+func (p *statsValueParser) int(name string, required bool) int {
+	v, ok := p.value(name, required)
+	if !ok {
+		return 0
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("invalid value for %s: %q", name, v)
+	}
+	return i
+}
+
+// latency returns the latency statistics with the given name prefix, if they
+// were gathered.
+func (p *statsValueParser) latency(prefix string) *LatencyStats {
+	if _, ok := p.values[prefix+"_samples"]; !ok {
+		return nil
+	}
+	return &LatencyStats{
+		Count: p.int(prefix+"_samples", true),
+		P50:   p.float(prefix+"_p50", true),
+		P90:   p.float(prefix+"_p90", true),
+		P95:   p.float(prefix+"_p95", true),
+		P99:   p.float(prefix+"_p99", true),
+		Max:   p.float(prefix+"_max", true),
+	}
+}
@@ -0,0 +1,88 @@
+package loadtest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseReportFixture(t *testing.T, name string) (loadtest.Report, error) {
+	f, err := os.Open(filepath.Join("testdata", "reports", name))
+	require.NoError(t, err)
+	defer f.Close()
+	return loadtest.ParseReport(f)
+}
+
+func TestParseReportCSV(t *testing.T) {
+	report, err := parseReportFixture(t, "baseline.csv")
+	require.NoError(t, err)
+	stats := report.Aggregate
+	assert.Equal(t, 10000, stats.TotalTxs)
+	assert.Equal(t, int64(2500000), stats.TotalBytes)
+	assert.Equal(t, 10.0, stats.TotalTimeSeconds)
+	assert.Equal(t, 10, stats.FailedTxs)
+	assert.Equal(t, 1010.0, stats.PeakTxRate)
+	// derived statistics are recomputed
+	assert.Equal(t, 1000.0, stats.AvgTxRate)
+	assert.Equal(t, 250000.0, stats.AvgDataRate)
+	assert.InDelta(t, 0.999, stats.SuccessRatio, 1e-9)
+	require.NotNil(t, stats.BroadcastLatency)
+	assert.Equal(t, loadtest.LatencyStats{Count: 10000, P50: 0.01, P90: 0.02, P95: 0.03, P99: 0.04, Max: 0.1}, *stats.BroadcastLatency)
+	assert.Nil(t, stats.CommitLatency)
+	assert.Nil(t, stats.Mempool)
+	// per-endpoint and per-worker statistics are skipped
+	assert.Empty(t, stats.Endpoints)
+	assert.Empty(t, report.Workers)
+
+	// with a machine-readable header and another delimiter
+	report, err = parseReportFixture(t, "header-tab.csv")
+	require.NoError(t, err)
+	assert.Equal(t, 10000, report.Aggregate.TotalTxs)
+	assert.Equal(t, loadtest.StatsStatusCancelled, report.Aggregate.Status)
+	assert.Nil(t, report.Aggregate.BroadcastLatency)
+}
+
+func TestParseReportAppendedCSV(t *testing.T) {
+	// the last run is read
+	report, err := parseReportFixture(t, "appended.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "nightly-2026-10-14", report.Config.RunID)
+	assert.Equal(t, 9500, report.Aggregate.TotalTxs)
+	assert.Equal(t, 950.0, report.Aggregate.AvgTxRate)
+	assert.Equal(t, 5.0, report.Aggregate.RateDeviationPercent)
+	require.NotNil(t, report.Aggregate.BroadcastLatency)
+	assert.Equal(t, 0.041, report.Aggregate.BroadcastLatency.P99)
+	require.NotNil(t, report.Aggregate.Mempool)
+	assert.Equal(t, loadtest.MempoolStats{Pauses: 2, PausedSeconds: 1.5}, *report.Aggregate.Mempool)
+	assert.Nil(t, report.Aggregate.CommitLatency)
+}
+
+func TestParseReportJSON(t *testing.T) {
+	report, err := parseReportFixture(t, "candidate.json")
+	require.NoError(t, err)
+	assert.Equal(t, "candidate", report.Config.RunID)
+	assert.Equal(t, 9000, report.Aggregate.TotalTxs)
+	assert.Equal(t, 900.0, report.Aggregate.AvgTxRate)
+	assert.InDelta(t, 0.99, report.Aggregate.SuccessRatio, 1e-9)
+	require.NotNil(t, report.Aggregate.BroadcastLatency)
+	assert.Equal(t, 0.05, report.Aggregate.BroadcastLatency.P99)
+}
+
+func TestParseReportMalformed(t *testing.T) {
+	testCases := map[string]string{
+		"bad-value.csv":     `invalid value for total_txs: "lots"`,
+		"missing-total.csv": "missing total_txs",
+		"ragged.csv":        "wrong number of fields",
+		"truncated.json":    "failed to parse report in JSON format",
+		"unrecognized.txt":  "unrecognized report format",
+		"empty.csv":         "empty report",
+	}
+	for name, expected := range testCases {
+		_, err := parseReportFixture(t, name)
+		assert.ErrorContains(t, err, expected, name)
+	}
+}
@@ -0,0 +1,535 @@
+package loadtest_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportJSONRoundTrip(t *testing.T) {
+	report := loadtest.NewReport(
+		mockTestConfig("ws://localhost:26657/websocket"),
+		loadtest.AggregateStats{
+			TotalTxs:         100,
+			TotalTimeSeconds: 10,
+			TotalBytes:       25000,
+			BroadcastLatency: &loadtest.LatencyStats{Count: 100, P50: 0.01, P90: 0.02, P95: 0.03, P99: 0.04, Max: 0.05},
+			Endpoints: []loadtest.EndpointStats{
+				{Endpoint: "ws://localhost:26657/websocket", TargetRate: 10, TotalTxs: 100},
+			},
+			CommitLatencyByPriority: []loadtest.PriorityLatencyStats{
+				{Priority: 1, LatencyStats: loadtest.LatencyStats{Count: 1, P50: 1, P90: 1, P95: 1, P99: 1, Max: 1}},
+			},
+		},
+		[]loadtest.WorkerStats{
+			{ID: "worker2", TotalTxs: 50, TotalBytes: 12500},
+			{ID: "worker1", TotalTxs: 50, TotalBytes: 12500},
+		},
+	)
+	require.Equal(t, loadtest.Version(), report.Version)
+	require.Equal(t, 10.0, report.Aggregate.AvgTxRate)
+	require.Equal(t, 10.0, report.Aggregate.Endpoints[0].AvgTxRate)
+	require.Equal(t, "worker1", report.Workers[0].ID)
+
+	b, err := json.Marshal(report)
+	require.NoError(t, err)
+	var decoded loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, report, decoded)
+}
+
+func TestStandaloneStatsOutputFormats(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	outDir := t.TempDir()
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(3)
+	cfg.StatsOutputFile = filepath.Join(outDir, "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Equal(t, cfg.Count, report.Aggregate.TotalTxs)
+	require.Equal(t, int64(cfg.Count*cfg.Size), report.Aggregate.TotalBytes)
+	require.NotNil(t, report.Aggregate.BroadcastLatency)
+	require.Equal(t, cfg.Endpoints, report.Config.Endpoints)
+	require.Equal(t, loadtest.Version(), report.Version)
+	// the per-endpoint statistics are always reported
+	require.Len(t, report.Aggregate.Endpoints, 1)
+	require.Equal(t, cfg.Endpoints[0], report.Aggregate.Endpoints[0].Endpoint)
+	require.Equal(t, cfg.Count, report.Aggregate.Endpoints[0].TotalTxs)
+
+	cfg.StatsOutputFile = filepath.Join(outDir, "stats.csv")
+	cfg.StatsOutputFormat = loadtest.StatsFormatCSV
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	f, err := os.Open(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"Parameter", "Value", "Units"}, records[0])
+	require.Equal(t, "total_time", records[1][0])
+	// but the CSV output only has them if endpoint options are configured
+	for _, record := range records {
+		require.NotContains(t, record[0], "endpoint_")
+	}
+}
+
+func TestRunStandalone(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(3)
+	// no statistics output file is needed to get at the statistics
+	require.Empty(t, cfg.StatsOutputFile)
+
+	report, err := loadtest.RunStandalone(context.Background(), cfg)
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	require.Equal(t, cfg.Count, report.Aggregate.TotalTxs)
+	require.Equal(t, int64(cfg.Count*cfg.Size), report.Aggregate.TotalBytes)
+	require.Greater(t, report.Aggregate.AvgTxRate, 0.0)
+	require.NotNil(t, report.Aggregate.BroadcastLatency)
+	require.Empty(t, report.Aggregate.Status)
+	require.Empty(t, report.Workers)
+	require.Equal(t, cfg.Endpoints, report.Config.Endpoints)
+	require.NotEmpty(t, report.Config.RunID)
+	require.Equal(t, loadtest.Version(), report.Version)
+}
+
+func TestRunStandaloneCancelled(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	type result struct {
+		report *loadtest.Report
+		err    error
+	}
+	results := make(chan result, 1)
+	go func() {
+		report, err := loadtest.RunStandalone(ctx, cfg)
+		results <- result{report, err}
+	}()
+	waitForRequests(t, svr)
+	cancel()
+	select {
+	case res := <-results:
+		require.ErrorIs(t, res.err, loadtest.ErrLoadTestCancelled)
+		// the statistics gathered until then are still reported
+		require.NotNil(t, res.report)
+		require.Equal(t, loadtest.StatsStatusCancelled, res.report.Aggregate.Status)
+		require.Equal(t, svr.Requests(), res.report.Aggregate.TotalTxs)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for load test to be cancelled")
+	}
+
+	// as long as there are statistics to report
+	cfg.Endpoints = []string{"ws://" + freeLocalAddr(t) + "/websocket"}
+	report, err := loadtest.RunStandalone(context.Background(), cfg)
+	require.Error(t, err)
+	require.Nil(t, report)
+}
+
+func TestStandaloneCancel(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+	cfg.NoTrapInterrupts = false
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.csv")
+
+	errs := make(chan error, 1)
+	go func() { errs <- loadtest.ExecuteStandalone(cfg) }()
+	deadline := time.Now().Add(10 * time.Second)
+	for svr.Requests() == 0 {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for load test to start")
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(time.Second)
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+	select {
+	case err := <-errs:
+		require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+		require.Equal(t, loadtest.ExitCodeCancelled, loadtest.ExitCodeFor(err))
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for load test to be cancelled")
+	}
+
+	f, err := os.Open(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	values := make(map[string]string)
+	for _, record := range records[1:] {
+		values[record[0]] = record[1]
+	}
+	require.Equal(t, "cancelled", values["status"])
+	require.Equal(t, strconv.Itoa(svr.Requests()), values["total_txs"])
+}
+
+func TestStandaloneTerminate(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+	cfg.NoTrapInterrupts = false
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+
+	errs := make(chan error, 1)
+	go func() { errs <- loadtest.ExecuteStandalone(cfg) }()
+	deadline := time.Now().Add(10 * time.Second)
+	for svr.Requests() == 0 {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for load test to start")
+		time.Sleep(100 * time.Millisecond)
+	}
+	time.Sleep(time.Second)
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case err := <-errs:
+		require.ErrorIs(t, err, loadtest.ErrLoadTestTerminated)
+		// which is still a cancellation, as far as exit codes go
+		require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for load test to be terminated")
+	}
+
+	report, err := loadtest.LoadReport(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	require.Equal(t, loadtest.StatsStatusTerminated, report.Aggregate.Status)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+}
+
+func TestStandaloneStatsAppend(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(1)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.csv")
+	cfg.StatsAppend = true
+	cfg.RunID = "nightly1"
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+	// the run ID must be generated if not supplied
+	cfg.RunID = ""
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	f, err := os.Open(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	require.Equal(t, []string{"run_id", "timestamp", "total_time", "total_txs"}, records[0][:4])
+	require.Equal(t, "nightly1", records[1][0])
+	require.NotEmpty(t, records[2][0])
+	require.NotEqual(t, "nightly1", records[2][0])
+}
+
+func TestStandaloneFailedTxs(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	svr.SetFailEvery(2)
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.BroadcastTxMethod = "sync"
+	cfg.DrainTimeout = seconds(5)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	cfg.MinSuccessRatio = 0.9
+	returned, err := loadtest.RunStandalone(context.Background(), cfg)
+	require.Error(t, err)
+	require.Equal(t, loadtest.FailureThreshold, loadtest.ClassifyError(err))
+	require.Equal(t, loadtest.ExitCodeThreshold, loadtest.ExitCodeFor(err))
+	require.NotNil(t, returned)
+	require.Equal(t, cfg.Count/2, returned.Aggregate.FailedTxs)
+
+	// the statistics must still be written
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	require.Equal(t, cfg.Count, report.Aggregate.TotalTxs)
+	require.Equal(t, cfg.Count/2, report.Aggregate.FailedTxs)
+	require.Equal(t, 0.5, report.Aggregate.SuccessRatio)
+	require.Equal(t, 0, report.Aggregate.ErroredConnections)
+
+	// the statistics written above are only overwritten if asked to
+	cfg.MinSuccessRatio = 0.5
+	cfg.StatsOverwrite = true
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+}
+
+func TestStandaloneChainStats(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	svr.StartBlocks(50 * time.Millisecond)
+	// blocks produced before the load test must be excluded
+	time.Sleep(time.Second)
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+	cfg.ChainStats = true
+	cfg.ChainStatsTimeout = seconds(5)
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	chain := report.Aggregate.Chain
+	require.NotNil(t, chain)
+	// the transactions sent last must be committed in the first block after
+	// the load test
+	require.Equal(t, int64(cfg.Count), chain.CommittedTxs)
+	require.Greater(t, chain.StartHeight, int64(10))
+	require.Equal(t, chain.EndHeight-chain.StartHeight+1, int64(chain.Blocks))
+	require.InDelta(t, 0.05, chain.AvgBlockIntervalSeconds, 0.02)
+	require.InDelta(t, float64(cfg.Count)/float64(chain.Blocks), chain.AvgTxsPerBlock, 1e-9)
+}
+
+func TestStandaloneSummaryJSON(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.RunID = "ci-run"
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.csv")
+	cfg.PrintSummaryJSON = true
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	os.Stdout = w
+	err = loadtest.ExecuteStandalone(cfg)
+	os.Stdout = stdout
+	require.NoError(t, w.Close())
+	require.NoError(t, err)
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	// nothing but the summary must be written to stdout
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	require.Len(t, lines, 1)
+	var summary loadtest.Summary
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &summary))
+	require.Equal(t, "ci-run", summary.RunID)
+	require.True(t, summary.Success)
+	require.Empty(t, summary.Error)
+	require.Equal(t, cfg.Count, summary.TotalTxs)
+	require.Equal(t, int64(cfg.Count*cfg.Size), summary.TotalBytes)
+	require.Greater(t, summary.TotalTimeSeconds, 0.0)
+	require.Greater(t, summary.AchievedTxRate, 0.0)
+	require.Equal(t, 0, summary.FailedTxs)
+	require.Equal(t, cfg.StatsOutputFile, summary.StatsOutputFile)
+}
+
+func TestStandaloneResultWebhook(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		payloads []loadtest.WebhookPayload
+	)
+	// the webhook always rejects the results, which must not affect the
+	// outcome of the load test
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload loadtest.WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mtx.Lock()
+		payloads = append(payloads, payload)
+		mtx.Unlock()
+		http.Error(w, "rejected", http.StatusBadRequest)
+	}))
+	defer webhook.Close()
+
+	svr := newMockRPCServer(t, 0)
+	svr.SetFailEvery(2)
+	cfg := mockTestConfig(svr.URL())
+	cfg.BroadcastTxMethod = "sync"
+	cfg.DrainTimeout = seconds(5)
+	cfg.RunID = "webhook-run"
+	cfg.ResultWebhookURL = webhook.URL
+	cfg.MinSuccessRatio = 0.9
+	require.Error(t, loadtest.ExecuteStandalone(cfg))
+
+	cfg.MinSuccessRatio = 0.5
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, payloads, 2)
+	require.Equal(t, "webhook-run", payloads[0].RunID)
+	require.Equal(t, loadtest.WebhookStatusFailed, payloads[0].Status)
+	require.Contains(t, payloads[0].Error, "success ratio")
+	require.NotNil(t, payloads[0].Stats)
+	require.Equal(t, cfg.Count, payloads[0].Stats.TotalTxs)
+	require.Equal(t, cfg.Count/2, payloads[0].Stats.FailedTxs)
+	require.Equal(t, loadtest.WebhookStatusCompleted, payloads[1].Status)
+	require.Empty(t, payloads[1].Error)
+}
+
+func TestStandaloneLatencySamples(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.LatencySampleFile = filepath.Join(t.TempDir(), "latencies.csv")
+	cfg.LatencySampleRate = 1
+	cfg.LatencySampleCap = 5
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	f, err := os.Open(cfg.LatencySampleFile)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	// the header and no more samples than the cap
+	require.Len(t, records, 6)
+	require.Equal(t, []string{"send_unix_nanos", "latency_micros"}, records[0])
+}
+
+// A rate below 1 tx/s sends transactions at long intervals, rather than
+// rounding down to sending nothing.
+func TestStandaloneSubOneRate(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(21)
+	cfg.Count = -1
+	cfg.Rate = 0.1 // one tx every 10 seconds
+
+	start := time.Now()
+	report, err := loadtest.RunStandalone(context.Background(), cfg)
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	require.Equal(t, 2, report.Aggregate.TotalTxs)
+	require.Equal(t, 2, svr.MethodRequests("broadcast_tx_async"))
+	require.InDelta(t, 0.1, report.Aggregate.TargetTxRate, 1e-9)
+	// the first tx is sent once a whole tx's worth of send periods has passed
+	firstTxAfter := svr.FirstTxAt().Sub(start)
+	require.True(t, firstTxAfter > 8*time.Second && firstTxAfter < 12*time.Second, firstTxAfter)
+}
+
+func TestStandaloneRateShortfall(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	// the endpoint can only accept ~200 txs/sec
+	svr.SetReadDelay(5 * time.Millisecond)
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(3)
+	cfg.Rate = 1000
+	cfg.Count = -1
+	cfg.Size = 10000
+	cfg.MaxRateDeviation = 5
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.json")
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	b, err := os.ReadFile(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	var report loadtest.Report
+	require.NoError(t, json.Unmarshal(b, &report))
+	t.Logf("Got aggregate statistics: %v", &report.Aggregate)
+	require.Equal(t, 1000.0, report.Aggregate.TargetTxRate)
+	require.Equal(t, report.Aggregate.AvgTxRate, report.Aggregate.AchievedTxRate)
+	require.Greater(t, report.Aggregate.RateDeviationPercent, 5.0)
+	require.InDelta(t, (1000-report.Aggregate.AchievedTxRate)/10, report.Aggregate.RateDeviationPercent, 1e-9)
+}
+
+func TestConfigValidateStatsOutputFormat(t *testing.T) {
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	for _, format := range []string{"", loadtest.StatsFormatCSV, loadtest.StatsFormatJSON} {
+		cfg.StatsOutputFormat = format
+		require.NoError(t, cfg.Validate(), format)
+	}
+	cfg.StatsOutputFormat = "xml"
+	require.Error(t, cfg.Validate())
+}
+
+func TestConfigValidateStatsAppend(t *testing.T) {
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.StatsAppend = true
+	require.NoError(t, cfg.Validate())
+	cfg.StatsOutputFormat = loadtest.StatsFormatJSON
+	require.Error(t, cfg.Validate())
+}
+
+func TestConfigValidateStatsCSVDelimiter(t *testing.T) {
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.StatsCSVDelimiter = ';'
+	require.NoError(t, cfg.Validate())
+	cfg.StatsCSVDelimiter = '"'
+	require.Error(t, cfg.Validate())
+}
+
+// memUploader keeps uploaded statistics in memory, by bucket and key.
+type memUploader struct {
+	mtx     sync.Mutex
+	objects map[string]string
+}
+
+func (u *memUploader) Upload(_ context.Context, bucket, key, _ string, body []byte) (bool, error) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	u.objects[bucket+"/"+key] = string(body)
+	return false, nil
+}
+
+// The uploader registered for mem:// URLs.
+var memStatsUploader = &memUploader{objects: make(map[string]string)}
+
+func init() {
+	if err := loadtest.RegisterStatsUploader("mem", memStatsUploader); err != nil {
+		panic(err)
+	}
+}
+
+func TestStandaloneStatsUpload(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+	cfg.StatsOutputFile = "mem://results/run1/stats.csv"
+	cfg.RawStatsOutputFile = "mem://results/run1/raw.csv"
+	cfg.RawStatsInterval = seconds(1)
+	cfg.RequireStatsUpload = true
+	require.NoError(t, cfg.Validate())
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	uploads := memStatsUploader
+	uploads.mtx.Lock()
+	defer uploads.mtx.Unlock()
+	require.Contains(t, uploads.objects, "results/run1/stats.csv")
+	require.Contains(t, uploads.objects["results/run1/stats.csv"], "total_txs,10,count")
+	require.Contains(t, uploads.objects, "results/run1/raw.csv")
+	require.True(t, strings.HasPrefix(uploads.objects["results/run1/raw.csv"], "t_seconds,"), uploads.objects["results/run1/raw.csv"])
+}
+
+func TestConfigValidateStatsUpload(t *testing.T) {
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	cfg.StatsOutputFile = "mem://results/stats.csv"
+	require.NoError(t, cfg.Validate())
+	cfg.StatsAppend = true
+	require.Error(t, cfg.Validate())
+	cfg.StatsAppend = false
+	cfg.RawStatsOutputFile = "mem://results"
+	cfg.RawStatsInterval = seconds(1)
+	require.Error(t, cfg.Validate())
+	// URLs can only be given for registered uploaders
+	cfg.RawStatsOutputFile = "gs://results/raw.csv"
+	require.ErrorContains(t, cfg.Validate(), "no statistics uploader is registered for gs:// URLs")
+}
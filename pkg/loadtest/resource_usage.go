@@ -0,0 +1,110 @@
+package loadtest
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reading the runtime's memory statistics briefly stops the world, so we do so
+// at most this often, however often the worker reports its resource usage.
+const memStatsSampleInterval = 5 * time.Second
+
+// ResourceUsage summarizes a worker's use of its machine's resources, so that a
+// shortfall in the achieved transaction rate can be attributed to a worker
+// being CPU- or memory-bound.
+type ResourceUsage struct {
+	CPUPercent     float64 `json:"cpu_percent"`      // The worker's CPU utilization, as a percentage of a single core (so it may exceed 100 on multi-core machines).
+	RSSBytes       int64   `json:"rss_bytes"`        // The worker's resident set size.
+	Goroutines     int     `json:"goroutines"`       // The number of goroutines running in the worker.
+	GCPauseSeconds float64 `json:"gc_pause_seconds"` // The cumulative time for which garbage collection has paused the worker.
+}
+
+// peak returns the larger of each of the given usage's values and our own.
+func (u ResourceUsage) peak(other ResourceUsage) ResourceUsage {
+	if other.CPUPercent > u.CPUPercent {
+		u.CPUPercent = other.CPUPercent
+	}
+	if other.RSSBytes > u.RSSBytes {
+		u.RSSBytes = other.RSSBytes
+	}
+	if other.Goroutines > u.Goroutines {
+		u.Goroutines = other.Goroutines
+	}
+	if other.GCPauseSeconds > u.GCPauseSeconds {
+		u.GCPauseSeconds = other.GCPauseSeconds
+	}
+	return u
+}
+
+// resourceSampler samples the resource usage of the current process. CPU
+// utilization is measured between successive samples.
+type resourceSampler struct {
+	mtx            sync.Mutex
+	lastCPUTime    time.Duration
+	lastSampleTime time.Time
+	lastMemStats   time.Time
+	sysBytes       int64   // The memory obtained from the OS, according to the runtime's memory statistics.
+	gcPauseSeconds float64 // According to the runtime's memory statistics.
+}
+
+func newResourceSampler() *resourceSampler {
+	s := &resourceSampler{lastSampleTime: time.Now()}
+	s.lastCPUTime, _ = processCPUTime()
+	s.readMemStats(s.lastSampleTime)
+	return s
+}
+
+// sample returns the process's current resource usage.
+func (s *resourceSampler) sample() ResourceUsage {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := time.Now()
+	usage := ResourceUsage{Goroutines: runtime.NumGoroutine()}
+	if cpuTime, ok := processCPUTime(); ok {
+		if wall := now.Sub(s.lastSampleTime); wall > 0 {
+			usage.CPUPercent = 100 * float64(cpuTime-s.lastCPUTime) / float64(wall)
+		}
+		s.lastCPUTime = cpuTime
+	}
+	s.lastSampleTime = now
+	if now.Sub(s.lastMemStats) >= memStatsSampleInterval {
+		s.readMemStats(now)
+	}
+	usage.GCPauseSeconds = s.gcPauseSeconds
+	if rss, ok := processRSS(); ok {
+		usage.RSSBytes = rss
+	} else {
+		usage.RSSBytes = s.sysBytes
+	}
+	return usage
+}
+
+func (s *resourceSampler) readMemStats(now time.Time) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	s.sysBytes = int64(ms.Sys)
+	s.gcPauseSeconds = time.Duration(ms.PauseTotalNs).Seconds()
+	s.lastMemStats = now
+}
+
+// processRSS returns the resident set size of the current process, if the
+// platform exposes it via procfs (e.g. Linux).
+func processRSS() (int64, bool) {
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return 0, false
+	}
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return pages * int64(os.Getpagesize()), true
+}
@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package loadtest
+
+import "time"
+
+// The CPU time consumed by the process is not available on this platform, so
+// workers report no CPU utilization.
+func processCPUTime() (time.Duration, bool) { return 0, false }
@@ -0,0 +1,37 @@
+package loadtest
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceSampler(t *testing.T) {
+	s := newResourceSampler()
+	// keep a core busy for a while, and make sure there's been a GC pause
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for x := 0; time.Now().Before(deadline); x++ {
+		_ = make([]byte, 1024+x%1024)
+	}
+	runtime.GC()
+	s.lastMemStats = time.Time{}
+
+	usage := s.sample()
+	assert.Greater(t, usage.CPUPercent, 0.0)
+	assert.Greater(t, usage.RSSBytes, int64(0))
+	assert.Greater(t, usage.Goroutines, 0)
+	assert.Greater(t, usage.GCPauseSeconds, 0.0)
+
+	// the memory statistics are only read again once they're stale
+	runtime.GC()
+	assert.Equal(t, usage.GCPauseSeconds, s.sample().GCPauseSeconds)
+}
+
+func TestResourceUsagePeak(t *testing.T) {
+	peak := ResourceUsage{}.
+		peak(ResourceUsage{CPUPercent: 150, RSSBytes: 100, Goroutines: 40, GCPauseSeconds: 0.5}).
+		peak(ResourceUsage{CPUPercent: 80, RSSBytes: 200, Goroutines: 30, GCPauseSeconds: 0.75})
+	assert.Equal(t, ResourceUsage{CPUPercent: 150, RSSBytes: 200, Goroutines: 40, GCPauseSeconds: 0.75}, peak)
+}
@@ -206,6 +206,14 @@ type ChannelStatus struct {
 // Percent represents a percentage in increments of 1/1000th of a percent.
 type Percent uint32
 
+// NumUnconfirmedTxs corresponds to the JSON-RPC response format produced by
+// the Tendermint Core v0.34.x num_unconfirmed_txs RPC API.
+type NumUnconfirmedTxs struct {
+	Count      JSONStrInt   `json:"n_txs"`       // The number of transactions returned (limited).
+	Total      JSONStrInt   `json:"total"`       // The total number of transactions in the mempool.
+	TotalBytes JSONStrInt64 `json:"total_bytes"` // The total size of all transactions in the mempool.
+}
+
 type httpClient struct {
 	addr   string
 	client *http.Client
@@ -225,6 +233,12 @@ func newHttpRpcClient(addr string) *httpClient {
 	}
 }
 
+// close releases the client's idle connections, which would otherwise keep
+// their goroutines (and sockets) alive once we're done with the client.
+func (c *httpClient) close() {
+	c.client.CloseIdleConnections()
+}
+
 func (c *httpClient) netInfo() (*NetInfo, error) {
 	httpRes, err := c.client.Get(c.addr + "/net_info")
 	if err != nil {
@@ -250,3 +264,38 @@ func (c *httpClient) netInfo() (*NetInfo, error) {
 	}
 	return netInfo, nil
 }
+
+func (c *httpClient) numUnconfirmedTxs() (*NumUnconfirmedTxs, error) {
+	res := &NumUnconfirmedTxs{}
+	if err := c.get("num_unconfirmed_txs", res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// get calls the given RPC method via an HTTP GET request and unmarshals the
+// inner result into the given value.
+func (c *httpClient) get(method string, result interface{}) error {
+	httpRes, err := c.client.Get(c.addr + "/" + method)
+	if err != nil {
+		return fmt.Errorf("failed to get %s for peer %s: %w", method, c.addr, err)
+	}
+	defer httpRes.Body.Close()
+
+	resBytes, err := io.ReadAll(httpRes.Body)
+	if err != nil {
+		return err
+	}
+
+	res := &RPCResponse{}
+	if err := json.Unmarshal(resBytes, res); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response for peer %s: %w", method, c.addr, err)
+	}
+	if res.Error != nil && res.Error.Code != 0 {
+		return fmt.Errorf("got error code %d when attempting to get %s for %s: %s", res.Error.Code, method, c.addr, res.Error.Message)
+	}
+	if err := json.Unmarshal(res.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s inner response for peer %s: %w", method, c.addr, err)
+	}
+	return nil
+}
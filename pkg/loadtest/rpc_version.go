@@ -0,0 +1,140 @@
+package loadtest
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	RPCVersionAuto   = "auto"   // Detect each endpoint's RPC version automatically (the default).
+	RPCVersionLegacy = "legacy" // Use the legacy (unversioned) RPC routes, e.g. "/websocket".
+	RPCVersionV1     = "v1"     // Use the CometBFT v1 RPC routes, e.g. "/v1/websocket".
+)
+
+var validRPCVersions = map[string]interface{}{
+	RPCVersionAuto:   nil,
+	RPCVersionLegacy: nil,
+	RPCVersionV1:     nil,
+}
+
+// The RPC versions to try when detecting an endpoint's RPC version, in order
+// of preference.
+var detectableRPCVersions = []string{RPCVersionV1, RPCVersionLegacy}
+
+const rpcVersionDetectTimeout = 10 * time.Second
+
+// NodeStatus corresponds to the subset of the JSON-RPC response format
+// produced by the Tendermint/CometBFT status RPC API that we need.
+type NodeStatus struct {
+	NodeInfo DefaultNodeInfo `json:"node_info"`
+	SyncInfo SyncInfo        `json:"sync_info"`
+}
+
+// SyncInfo corresponds to the subset of the node's synchronization status we
+// need.
+type SyncInfo struct {
+	LatestBlockHeight JSONStrInt64 `json:"latest_block_height"`
+	LatestBlockTime   time.Time    `json:"latest_block_time"`
+}
+
+func (c *httpClient) status() (*NodeStatus, error) {
+	res := &NodeStatus{}
+	if err := c.get("status", res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// resolveRPCEndpoints determines the RPC version of each of the given
+// WebSockets endpoints (unless forced to a specific version) and returns the
+// endpoints' addresses rewritten to use the appropriate routes.
+func resolveRPCEndpoints(endpoints []string, rpcVersion string, logger logging.Logger) ([]string, error) {
+	resolved := make([]string, len(endpoints))
+	for i, endpoint := range endpoints {
+		version := rpcVersion
+		if version == "" || version == RPCVersionAuto {
+			detected, nodeVersion, err := detectRPCVersion(endpoint)
+			if err != nil {
+				// the connection attempt itself will provide a more useful error
+				logger.Warn("Failed to detect RPC version - using endpoint as given", "endpoint", endpoint, "err", err)
+				resolved[i] = endpoint
+				continue
+			}
+			logger.Info("Detected RPC version", "endpoint", endpoint, "rpcVersion", detected, "nodeVersion", nodeVersion)
+			version = detected
+		}
+		addr, err := rpcWebSocketAddr(endpoint, version)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = addr
+	}
+	return resolved, nil
+}
+
+// detectRPCVersion queries the status RPC API via each of the routes we
+// support to find out which RPC version the given WebSockets endpoint's node
+// exposes. Also returns the node's software version.
+func detectRPCVersion(wsAddr string) (string, string, error) {
+	u, err := url.Parse(wsAddr)
+	if err != nil {
+		return "", "", err
+	}
+	errs := make([]string, 0, len(detectableRPCVersions))
+	for _, version := range detectableRPCVersions {
+		versionedAddr, err := rpcWebSocketAddr(u.String(), version)
+		if err != nil {
+			return "", "", err
+		}
+		httpAddr, err := rpcHTTPAddr(versionedAddr)
+		if err != nil {
+			return "", "", err
+		}
+		client := newHttpRpcClient(httpAddr)
+		client.client.Timeout = rpcVersionDetectTimeout
+		status, err := client.status()
+		client.close()
+		if err == nil {
+			return version, status.NodeInfo.Version, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", version, err))
+	}
+	return "", "", fmt.Errorf("no supported RPC version found (%s)", strings.Join(errs, "; "))
+}
+
+// rpcWebSocketAddr rewrites the given WebSockets endpoint address to use the
+// routes for the given RPC version, e.g. "ws://host:26657/websocket" becomes
+// "ws://host:26657/v1/websocket" for v1.
+func rpcWebSocketAddr(wsAddr, rpcVersion string) (string, error) {
+	u, err := url.Parse(wsAddr)
+	if err != nil {
+		return "", err
+	}
+	p := u.Path
+	if p == "/v1" || strings.HasPrefix(p, "/v1/") {
+		p = strings.TrimPrefix(p, "/v1")
+	}
+	switch rpcVersion {
+	case RPCVersionLegacy:
+	case RPCVersionV1:
+		p = "/v1" + p
+	default:
+		return "", fmt.Errorf("unsupported RPC version: %s", rpcVersion)
+	}
+	u.Path = p
+	return u.String(), nil
+}
+
+// rpcPathPrefix returns the path prefix of the given RPC route, e.g. "/v1"
+// for "/v1/websocket".
+func rpcPathPrefix(p string) string {
+	if p == "" {
+		return ""
+	}
+	return strings.TrimSuffix(path.Dir(p), "/")
+}
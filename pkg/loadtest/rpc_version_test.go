@@ -0,0 +1,45 @@
+package loadtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCWebSocketAddr(t *testing.T) {
+	testCases := []struct {
+		addr     string
+		version  string
+		expected string
+	}{
+		{"ws://host:26657/websocket", RPCVersionLegacy, "ws://host:26657/websocket"},
+		{"ws://host:26657/websocket", RPCVersionV1, "ws://host:26657/v1/websocket"},
+		{"ws://host:26657/v1/websocket", RPCVersionV1, "ws://host:26657/v1/websocket"},
+		{"ws://host:26657/v1/websocket", RPCVersionLegacy, "ws://host:26657/websocket"},
+		{"wss://host/websocket", RPCVersionV1, "wss://host/v1/websocket"},
+	}
+	for _, tc := range testCases {
+		addr, err := rpcWebSocketAddr(tc.addr, tc.version)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, addr)
+	}
+	_, err := rpcWebSocketAddr("ws://host:26657/websocket", "v2")
+	assert.Error(t, err)
+}
+
+func TestRPCHTTPAddr(t *testing.T) {
+	testCases := []struct {
+		addr     string
+		expected string
+	}{
+		{"ws://host:26657/websocket", "http://host:26657"},
+		{"wss://host:26657/v1/websocket", "https://host:26657/v1"},
+		{"ws://host:26657", "http://host:26657"},
+	}
+	for _, tc := range testCases {
+		addr, err := rpcHTTPAddr(tc.addr)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, addr)
+	}
+}
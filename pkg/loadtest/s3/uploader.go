@@ -0,0 +1,71 @@
+// Package s3 adds Amazon S3 (and S3-compatible) statistics output URLs of the
+// form s3://bucket/key to tm-load-test. Importing the package registers its
+// statistics uploader:
+//
+//	import _ "github.com/informalsystems/tm-load-test/pkg/loadtest/s3"
+//
+// It lives in its own package so that programs that embed the loadtest
+// package without it don't depend on the AWS SDK.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+)
+
+const awsDefaultRegion = "us-east-1"
+
+func init() {
+	if err := loadtest.RegisterStatsUploader("s3", &s3Uploader{}); err != nil {
+		panic(err)
+	}
+}
+
+// s3Uploader uploads objects to S3 (or an S3-compatible service) with the AWS
+// SDK, which obtains the region, endpoint and credentials from the
+// environment, the shared configuration files, the container credentials
+// endpoint or the EC2 instance metadata service, as usual.
+type s3Uploader struct{}
+
+var _ loadtest.StatsUploader = (*s3Uploader)(nil)
+
+func (u *s3Uploader) Upload(ctx context.Context, bucket, key, contentType string, body []byte) (bool, error) {
+	// we retry uploads ourselves
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRetryer(func() aws.Retryer { return aws.NopRetryer{} }))
+	if err != nil {
+		return false, err
+	}
+	if len(cfg.Region) == 0 {
+		cfg.Region = awsDefaultRegion
+	}
+	// there's no point in retrying without credentials
+	if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+		return false, err
+	}
+	client := awss3.NewFromConfig(cfg, func(o *awss3.Options) {
+		// custom (e.g. S3-compatible) endpoints are addressed path-style, and
+		// AWS itself virtual-hosted-style
+		o.UsePathStyle = o.BaseEndpoint != nil
+	})
+	_, err = client.PutObject(ctx, &awss3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        bytes.NewReader(body),
+	})
+	if err != nil {
+		var resErr *awshttp.ResponseError
+		if errors.As(err, &resErr) {
+			return loadtest.RetryableUploadStatus(resErr.HTTPStatusCode()), err
+		}
+		return true, err
+	}
+	return false, nil
+}
@@ -0,0 +1,85 @@
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// s3Stub is an S3-compatible stub that records uploaded objects.
+type s3Stub struct {
+	mtx      sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int // The statuses with which to respond to successive requests (200 once exhausted).
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.requests = append(s.requests, req)
+	s.bodies = append(s.bodies, body)
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+// newS3Stub starts an S3-compatible stub at which the AWS SDK is pointed,
+// isolating it from any credentials on the machine running the tests.
+func newS3Stub(t *testing.T, statuses ...int) *s3Stub {
+	stub := &s3Stub{statuses: statuses}
+	svr := httptest.NewServer(stub)
+	t.Cleanup(svr.Close)
+	t.Setenv("AWS_ENDPOINT_URL", svr.URL)
+	t.Setenv("AWS_REGION", "us-west-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	return stub
+}
+
+func TestS3Upload(t *testing.T) {
+	stub := newS3Stub(t)
+	retry, err := (&s3Uploader{}).Upload(context.Background(), "bucket", "runs/stats 1.csv", "text/csv", []byte("a,b\n"))
+	require.NoError(t, err)
+	assert.False(t, retry)
+
+	require.Len(t, stub.requests, 1)
+	req := stub.requests[0]
+	assert.Equal(t, http.MethodPut, req.Method)
+	assert.Equal(t, "/bucket/runs/stats%201.csv", req.URL.EscapedPath())
+	assert.Equal(t, "a,b\n", string(stub.bodies[0]))
+	assert.Equal(t, "text/csv", req.Header.Get("Content-Type"))
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	auth := req.Header.Get("Authorization")
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), auth)
+	assert.Contains(t, auth, "/us-west-2/s3/aws4_request")
+}
+
+func TestS3UploadFailure(t *testing.T) {
+	stub := newS3Stub(t, http.StatusServiceUnavailable, http.StatusForbidden)
+	uploader := &s3Uploader{}
+
+	// server errors are worth retrying
+	retry, err := uploader.Upload(context.Background(), "bucket", "stats.csv", "text/csv", []byte("a,b\n"))
+	assert.Error(t, err)
+	assert.True(t, retry)
+	// but client errors aren't, and the SDK doesn't retry either
+	retry, err = uploader.Upload(context.Background(), "bucket", "stats.csv", "text/csv", []byte("a,b\n"))
+	assert.Error(t, err)
+	assert.False(t, retry)
+	assert.Len(t, stub.requests, 2)
+}
@@ -8,6 +8,11 @@ const (
 	workerAccepted  workerState = "accepted"
 	workerRejected  workerState = "rejected"
 	workerTesting   workerState = "testing"
+	workerPaused    workerState = "paused"
 	workerFailed    workerState = "failed"
 	workerCompleted workerState = "completed"
+	workerCancelled workerState = "cancelled"
+	workerHeartbeat workerState = "heartbeat" // Not a state as such, but sent by workers to show that they're still alive.
+	workerShutdown  workerState = "shutdown"  // Not a state as such, but sent by the coordinator to tell workers to shut down.
+	workerRate      workerState = "rate"      // Not a state as such, but sent by the coordinator to change the rate at which workers send transactions.
 )
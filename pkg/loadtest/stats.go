@@ -1,59 +1,387 @@
 package loadtest
 
 import (
-	"encoding/csv"
+	"bytes"
 	"fmt"
 	"os"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
 )
 
+// The status of the aggregate statistics of a load test that was cancelled.
+const StatsStatusCancelled = "cancelled"
+
+// The status of the aggregate statistics of a load test that was terminated
+// (by SIGTERM) before it completed.
+const StatsStatusTerminated = "terminated"
+
+// The status of the aggregate statistics flushed while a load test is still
+// underway, which only cover what was sent until then.
+const StatsStatusRunning = "running"
+
 type AggregateStats struct {
-	TotalTxs         int     // The total number of transactions sent.
-	TotalTimeSeconds float64 // The total time taken to send `TotalTxs` transactions.
-	TotalBytes       int64   // The cumulative number of bytes sent as transactions.
+	Status string `json:"status,omitempty"` // Set to "cancelled" (or "terminated") if the load test was cancelled before it completed, or "running" if it's still underway, in which case the statistics only cover what was sent until then.
+
+	TotalTxs         int     `json:"total_txs"`          // The total number of transactions sent.
+	TotalTimeSeconds float64 `json:"total_time_seconds"` // The total time taken to send `TotalTxs` transactions.
+	TotalBytes       int64   `json:"total_bytes"`        // The cumulative number of bytes sent as transactions.
+
+	TargetTxRate       float64 `json:"target_tx_rate"`      // The configured transaction rate (tx/sec) across all connections (and workers), after applying any endpoint rate limits.
+	PeakTxRate         float64 `json:"peak_tx_rate"`        // The highest transaction rate (tx/sec) achieved during any rate window, excluding the first and last windows.
+	MinTxRate          float64 `json:"min_tx_rate"`         // The lowest transaction rate (tx/sec) achieved during any rate window, excluding the first and last windows.
+	TxRateStdDev       float64 `json:"tx_rate_stddev"`      // The standard deviation of the transaction rates achieved during each rate window, excluding the first and last windows.
+	FailedTxs          int     `json:"failed_txs"`          // The number of transactions to which an endpoint responded with an error.
+	ErroredConnections int     `json:"errored_connections"` // The number of connections to endpoints that failed during the load test.
+
+	PregeneratedTxs int `json:"pregenerated_txs,omitempty"` // The number of transactions generated before the load test started (only if transactions were pre-generated).
+
+	BroadcastLatency *LatencyStats `json:"broadcast_latency,omitempty"` // Broadcast round-trip latency statistics (write-completion latency for broadcast_tx_async).
+
+	Mempool        *MempoolStats        `json:"mempool,omitempty"`         // Mempool throttling statistics (only if mempool monitoring is enabled).
+	EndpointHealth *EndpointHealthStats `json:"endpoint_health,omitempty"` // Endpoint blacklisting statistics (only if endpoints are blacklisted after repeated failures).
+	CommitLatency  *LatencyStats        `json:"commit_latency,omitempty"`  // Send-to-commit latency statistics (only if commit latency tracking is enabled).
+	Endpoints      []EndpointStats      `json:"endpoints,omitempty"`       // Per-endpoint statistics (only written to CSV output if endpoint rate limits, weights or names are configured, re-discovery is enabled or endpoints are selected by latency).
+
+	CommitLatencyByPriority []PriorityLatencyStats `json:"commit_latency_by_priority,omitempty"` // Send-to-commit latency statistics per transaction priority (only if commit latency tracking is enabled and the client produces prioritized transactions).
+
+	Chain *ChainStats `json:"chain,omitempty"` // Block-level statistics obtained from the chain (only if chain statistics are enabled and could be obtained).
+
+	ExcludedEndpoints []ExcludedEndpoint `json:"excluded_endpoints,omitempty"` // The endpoints left out of the load test for failing their health checks (only if health checks are enabled).
+	EndpointLatencies []EndpointLatency  `json:"endpoint_latencies,omitempty"` // The latency of each endpoint measured before the load test, from the fastest to the slowest (only if endpoints are selected by latency in standalone mode - workers report their own).
 
 	// Computed statistics
-	AvgTxRate   float64 // The rate at which transactions were submitted (tx/sec).
-	AvgDataRate float64 // The rate at which data was transmitted in transactions (bytes/sec).
+	AvgTxRate    float64 `json:"avg_tx_rate"`   // The rate at which transactions were submitted (tx/sec).
+	AvgDataRate  float64 `json:"avg_data_rate"` // The rate at which data was transmitted in transactions (bytes/sec).
+	SuccessRatio float64 `json:"success_ratio"` // The fraction of transactions sent that did not fail.
+
+	AchievedTxRate       float64 `json:"achieved_tx_rate"`       // The transaction rate (tx/sec) actually achieved (the same as AvgTxRate).
+	RateDeviationPercent float64 `json:"rate_deviation_percent"` // By how much (as a percentage of the target rate) the achieved rate fell short of the target rate. Negative if the target rate was exceeded.
 }
 
 func (s *AggregateStats) String() string {
 	return fmt.Sprintf(
-		"AggregateStats{TotalTimeSeconds: %.3f, TotalTxs: %d, TotalBytes: %d, AvgTxRate: %.6f, AvgDataRate: %.6f}",
+		"AggregateStats{TotalTimeSeconds: %.3f, TotalTxs: %d, TotalBytes: %d, FailedTxs: %d, AvgTxRate: %.6f, AvgDataRate: %.6f, SuccessRatio: %.6f}",
 		s.TotalTimeSeconds,
 		s.TotalTxs,
 		s.TotalBytes,
+		s.FailedTxs,
 		s.AvgTxRate,
 		s.AvgDataRate,
+		s.SuccessRatio,
 	)
 }
 
+func (s *AggregateStats) setIntervalRates(rates IntervalRateStats) {
+	s.PeakTxRate = rates.Peak
+	s.MinTxRate = rates.Min
+	s.TxRateStdDev = rates.StdDev
+}
+
 func (s *AggregateStats) Compute() {
 	s.AvgTxRate = 0
 	s.AvgDataRate = 0
+	s.SuccessRatio = 0
 	if s.TotalTimeSeconds > 0.0 {
 		s.AvgTxRate = float64(s.TotalTxs) / s.TotalTimeSeconds
 		s.AvgDataRate = float64(s.TotalBytes) / s.TotalTimeSeconds
 	}
+	if s.TotalTxs > 0 {
+		s.SuccessRatio = float64(s.TotalTxs-s.FailedTxs) / float64(s.TotalTxs)
+	}
+	s.AchievedTxRate = s.AvgTxRate
+	s.RateDeviationPercent = 0
+	if s.TargetTxRate > 0 {
+		s.RateDeviationPercent = (s.TargetTxRate - s.AchievedTxRate) / s.TargetTxRate * 100
+	}
+	for i := range s.Endpoints {
+		s.Endpoints[i].AvgTxRate = 0
+		// rediscovered endpoints only took part from when they were added
+		if activeSeconds := s.TotalTimeSeconds - s.Endpoints[i].JoinedSeconds; activeSeconds > 0.0 {
+			s.Endpoints[i].AvgTxRate = float64(s.Endpoints[i].TotalTxs) / activeSeconds
+		}
+	}
+	computeEndpointShares(s.Endpoints)
 }
 
-func writeAggregateStats(filename string, stats AggregateStats) error {
+func writeAggregateStats(filename string, sw *StatsWriter, stats AggregateStats, workers []WorkerStats) error {
 	stats.Compute()
-	f, err := os.Create(filename)
+	var buf bytes.Buffer
+	if err := sw.WriteAggregate(&buf, stats, workers); err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, buf.Bytes(), 0o644)
+}
+
+// csvAggregateStats returns the given statistics as written to CSV statistics
+// output files, which only include the per-endpoint statistics if endpoint
+// rate limits, weights or aliases, re-discovery or lowest latency endpoint
+// selection are configured, so that the output is otherwise unchanged.
+func csvAggregateStats(cfg Config, stats AggregateStats) AggregateStats {
+	if len(cfg.EndpointRateLimits) == 0 && len(cfg.EndpointWeights) == 0 && len(cfg.EndpointNames) == 0 &&
+		cfg.RediscoveryInterval <= 0 && cfg.EndpointSelectMethod != SelectLowestLatencyEndpoints {
+		stats.Endpoints = nil
+	}
+	return stats
+}
+
+// aggregateStatsRecords returns the parameters in the aggregate statistics
+// CSV output, in order.
+func aggregateStatsRecords(stats AggregateStats, workers []WorkerStats) []statsRecord {
+	records := []statsRecord{
+		{"total_time", fmt.Sprintf("%.3f", stats.TotalTimeSeconds), UnitSeconds},
+		{"total_txs", fmt.Sprintf("%d", stats.TotalTxs), UnitCount},
+		{"total_bytes", fmt.Sprintf("%d", stats.TotalBytes), UnitBytes},
+		{"avg_tx_rate", fmt.Sprintf("%.6f", stats.AvgTxRate), UnitTxsPerSecond},
+		{"avg_data_rate", fmt.Sprintf("%.6f", stats.AvgDataRate), UnitBytesPerSecond},
+		{"failed_txs", fmt.Sprintf("%d", stats.FailedTxs), UnitCount},
+		{"success_ratio", fmt.Sprintf("%.6f", stats.SuccessRatio), UnitRatio},
+		{"errored_connections", fmt.Sprintf("%d", stats.ErroredConnections), UnitCount},
+		{"target_tx_rate", fmt.Sprintf("%.6f", stats.TargetTxRate), UnitTxsPerSecond},
+		{"achieved_tx_rate", fmt.Sprintf("%.6f", stats.AchievedTxRate), UnitTxsPerSecond},
+		{"rate_deviation", fmt.Sprintf("%.3f", stats.RateDeviationPercent), UnitPercent},
+		{"peak_tx_rate", fmt.Sprintf("%.6f", stats.PeakTxRate), UnitTxsPerSecond},
+		{"min_tx_rate", fmt.Sprintf("%.6f", stats.MinTxRate), UnitTxsPerSecond},
+		{"tx_rate_stddev", fmt.Sprintf("%.6f", stats.TxRateStdDev), UnitTxsPerSecond},
+	}
+	if len(stats.Status) > 0 {
+		records = append(records, statsRecord{"status", stats.Status, UnitLabel})
+	}
+	if stats.PregeneratedTxs > 0 {
+		records = append(records, statsRecord{"pregenerated_txs", fmt.Sprintf("%d", stats.PregeneratedTxs), UnitCount})
+	}
+	if stats.BroadcastLatency != nil {
+		records = append(records, latencyRecords("broadcast_latency", stats.BroadcastLatency)...)
+	}
+	if stats.Mempool != nil {
+		records = append(
+			records,
+			statsRecord{"mempool_pauses", fmt.Sprintf("%d", stats.Mempool.Pauses), UnitCount},
+			statsRecord{"mempool_paused_time", fmt.Sprintf("%.3f", stats.Mempool.PausedSeconds), UnitSeconds},
+		)
+	}
+	if stats.EndpointHealth != nil {
+		records = append(
+			records,
+			statsRecord{"endpoint_blacklistings", fmt.Sprintf("%d", stats.EndpointHealth.Blacklistings), UnitCount},
+			statsRecord{"endpoint_recoveries", fmt.Sprintf("%d", stats.EndpointHealth.Recoveries), UnitCount},
+			statsRecord{"healthy_endpoints", fmt.Sprintf("%d", stats.EndpointHealth.HealthyEndpoints), UnitCount},
+			statsRecord{"blacklisted_endpoints", fmt.Sprintf("%d", stats.EndpointHealth.BlacklistedEndpoints), UnitCount},
+		)
+	}
+	if stats.CommitLatency != nil {
+		records = append(records, latencyRecords("commit_latency", stats.CommitLatency)...)
+	}
+	for _, p := range stats.CommitLatencyByPriority {
+		records = append(records, latencyRecords(fmt.Sprintf("commit_latency_priority_%d", p.Priority), &p.LatencyStats)...)
+	}
+	if stats.Chain != nil {
+		records = append(
+			records,
+			statsRecord{"chain_start_height", fmt.Sprintf("%d", stats.Chain.StartHeight), UnitHeight},
+			statsRecord{"chain_end_height", fmt.Sprintf("%d", stats.Chain.EndHeight), UnitHeight},
+			statsRecord{"chain_blocks", fmt.Sprintf("%d", stats.Chain.Blocks), UnitCount},
+			statsRecord{"chain_committed_txs", fmt.Sprintf("%d", stats.Chain.CommittedTxs), UnitCount},
+			statsRecord{"chain_avg_block_interval", fmt.Sprintf("%.3f", stats.Chain.AvgBlockIntervalSeconds), UnitSeconds},
+			statsRecord{"chain_avg_txs_per_block", fmt.Sprintf("%.3f", stats.Chain.AvgTxsPerBlock), UnitTxsPerBlock},
+			statsRecord{"chain_committed_tx_rate", fmt.Sprintf("%.6f", stats.Chain.CommittedTxRate), UnitTxsPerSecond},
+		)
+	}
+	for _, ep := range stats.Endpoints {
+		// endpoints with aliases are labelled with them, recording their
+		// addresses separately
+		label := ep.Endpoint
+		if len(ep.Name) > 0 {
+			label = ep.Name
+			records = append(records, statsRecord{fmt.Sprintf("endpoint_url[%s]", label), ep.Endpoint, UnitLabel})
+		}
+		records = append(
+			records,
+			statsRecord{fmt.Sprintf("endpoint_total_txs[%s]", label), fmt.Sprintf("%d", ep.TotalTxs), UnitCount},
+			statsRecord{fmt.Sprintf("endpoint_target_rate[%s]", label), fmt.Sprintf("%.6f", ep.TargetRate), UnitTxsPerSecond},
+			statsRecord{fmt.Sprintf("endpoint_avg_tx_rate[%s]", label), fmt.Sprintf("%.6f", ep.AvgTxRate), UnitTxsPerSecond},
+		)
+		if ep.Weight > 0 {
+			records = append(
+				records,
+				statsRecord{fmt.Sprintf("endpoint_weight[%s]", label), fmt.Sprintf("%g", ep.Weight), UnitCount},
+				statsRecord{fmt.Sprintf("endpoint_share[%s]", label), fmt.Sprintf("%.6f", ep.Share), UnitRatio},
+			)
+		}
+		if ep.JoinedSeconds > 0 {
+			records = append(records, statsRecord{fmt.Sprintf("endpoint_joined_time[%s]", label), fmt.Sprintf("%.3f", ep.JoinedSeconds), UnitSeconds})
+		}
+	}
+	for _, ep := range stats.ExcludedEndpoints {
+		records = append(records, statsRecord{fmt.Sprintf("excluded_endpoint[%s]", ep.Endpoint), ep.Reason, UnitLabel})
+	}
+	records = append(records, endpointLatencyRecords("endpoint", "", stats.EndpointLatencies)...)
+	for _, ws := range workers {
+		records = append(
+			records,
+			statsRecord{fmt.Sprintf("worker_total_txs[%s]", ws.ID), fmt.Sprintf("%d", ws.TotalTxs), UnitCount},
+			statsRecord{fmt.Sprintf("worker_total_bytes[%s]", ws.ID), fmt.Sprintf("%d", ws.TotalBytes), UnitBytes},
+			statsRecord{fmt.Sprintf("worker_avg_tx_rate[%s]", ws.ID), fmt.Sprintf("%.6f", ws.AvgTxRate), UnitTxsPerSecond},
+			statsRecord{fmt.Sprintf("worker_failures[%s]", ws.ID), fmt.Sprintf("%d", ws.Failures), UnitCount},
+			statsRecord{fmt.Sprintf("worker_errored_connections[%s]", ws.ID), fmt.Sprintf("%d", ws.ErroredConnections), UnitCount},
+		)
+		if r := ws.PeakResources; r != nil {
+			records = append(
+				records,
+				statsRecord{fmt.Sprintf("worker_peak_cpu[%s]", ws.ID), fmt.Sprintf("%.1f", r.CPUPercent), UnitPercent},
+				statsRecord{fmt.Sprintf("worker_peak_rss[%s]", ws.ID), fmt.Sprintf("%d", r.RSSBytes), UnitBytes},
+				statsRecord{fmt.Sprintf("worker_peak_goroutines[%s]", ws.ID), fmt.Sprintf("%d", r.Goroutines), UnitCount},
+				statsRecord{fmt.Sprintf("worker_gc_pause_time[%s]", ws.ID), fmt.Sprintf("%.3f", r.GCPauseSeconds), UnitSeconds},
+			)
+		}
+		for _, name := range sortedLabelNames(ws.Labels) {
+			records = append(records, statsRecord{fmt.Sprintf("worker_label[%s][%s]", ws.ID, name), ws.Labels[name], UnitLabel})
+		}
+		records = append(records, endpointLatencyRecords("worker_endpoint", fmt.Sprintf("[%s]", ws.ID), ws.EndpointLatencies)...)
+	}
+	return records
+}
+
+// appendedStatsHeader is the header of the statistics output file in append
+// mode, where each row holds the aggregate statistics of a single run.
+var appendedStatsHeader = []string{
+	"run_id",
+	"timestamp",
+	"total_time",
+	"total_txs",
+	"total_bytes",
+	"avg_tx_rate",
+	"avg_data_rate",
+	"failed_txs",
+	"success_ratio",
+	"errored_connections",
+	"target_tx_rate",
+	"rate_deviation",
+	"broadcast_latency_samples",
+	"broadcast_latency_p50",
+	"broadcast_latency_p90",
+	"broadcast_latency_p95",
+	"broadcast_latency_p99",
+	"broadcast_latency_max",
+	"mempool_pauses",
+	"mempool_paused_time",
+	"commit_latency_samples",
+	"commit_latency_p50",
+	"commit_latency_p90",
+	"commit_latency_p95",
+	"commit_latency_p99",
+	"commit_latency_max",
+}
+
+// appendAggregateStats appends a single row containing the given run's
+// aggregate statistics to the specified file, writing the header first if the
+// file is new. Per-endpoint and per-worker statistics are omitted.
+func appendAggregateStats(filename string, sw *StatsWriter, runID string, stats AggregateStats) error {
+	stats.Compute()
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	w := csv.NewWriter(f)
-	defer w.Flush()
+	// guard against concurrent appends from other processes
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", filename, err)
+	}
+	defer func() { _ = unlockFile(f) }()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return sw.WriteRun(f, runID, stats, fi.Size() == 0)
+}
 
-	records := [][]string{
-		{"Parameter", "Value", "Units"},
-		{"total_time", fmt.Sprintf("%.3f", stats.TotalTimeSeconds), "seconds"},
-		{"total_txs", fmt.Sprintf("%d", stats.TotalTxs), "count"},
-		{"total_bytes", fmt.Sprintf("%d", stats.TotalBytes), "bytes"},
-		{"avg_tx_rate", fmt.Sprintf("%.6f", stats.AvgTxRate), "transactions per second"},
-		{"avg_data_rate", fmt.Sprintf("%.6f", stats.AvgDataRate), "bytes per second"},
+// runStatsColumns returns the given run's row of aggregate statistics in
+// append mode.
+func runStatsColumns(runID string, stats AggregateStats) []string {
+	record := []string{
+		runID,
+		time.Now().UTC().Format(time.RFC3339),
+		fmt.Sprintf("%.3f", stats.TotalTimeSeconds),
+		fmt.Sprintf("%d", stats.TotalTxs),
+		fmt.Sprintf("%d", stats.TotalBytes),
+		fmt.Sprintf("%.6f", stats.AvgTxRate),
+		fmt.Sprintf("%.6f", stats.AvgDataRate),
+		fmt.Sprintf("%d", stats.FailedTxs),
+		fmt.Sprintf("%.6f", stats.SuccessRatio),
+		fmt.Sprintf("%d", stats.ErroredConnections),
+		fmt.Sprintf("%.6f", stats.TargetTxRate),
+		fmt.Sprintf("%.3f", stats.RateDeviationPercent),
 	}
-	return w.WriteAll(records)
+	record = append(record, latencyColumns(stats.BroadcastLatency)...)
+	if stats.Mempool != nil {
+		record = append(record, fmt.Sprintf("%d", stats.Mempool.Pauses), fmt.Sprintf("%.3f", stats.Mempool.PausedSeconds))
+	} else {
+		record = append(record, "", "")
+	}
+	return append(record, latencyColumns(stats.CommitLatency)...)
+}
+
+// latencyColumns returns the columns for the given latency statistics in
+// append mode, which are empty if the statistics are unavailable.
+func latencyColumns(stats *LatencyStats) []string {
+	if stats == nil {
+		return []string{"", "", "", "", "", ""}
+	}
+	return []string{
+		fmt.Sprintf("%d", stats.Count),
+		fmt.Sprintf("%.6f", stats.P50),
+		fmt.Sprintf("%.6f", stats.P90),
+		fmt.Sprintf("%.6f", stats.P95),
+		fmt.Sprintf("%.6f", stats.P99),
+		fmt.Sprintf("%.6f", stats.Max),
+	}
+}
+
+// endpointLatencyRecords returns the median round-trip time and the selection
+// of each of the given endpoints, with the given name prefix and key (e.g.
+// "worker_endpoint_median_rtt[worker-1][ws://host:26657/websocket]").
+func endpointLatencyRecords(prefix, key string, latencies []EndpointLatency) []statsRecord {
+	records := make([]statsRecord, 0, 2*len(latencies))
+	for _, l := range latencies {
+		selection := "standby"
+		if l.Preferred {
+			selection = "preferred"
+		}
+		if len(l.Error) > 0 {
+			selection += " (unreachable)"
+		} else {
+			records = append(records, statsRecord{fmt.Sprintf("%s_median_rtt%s[%s]", prefix, key, l.Endpoint), fmt.Sprintf("%.6f", l.MedianRTT), UnitSeconds})
+		}
+		records = append(records, statsRecord{fmt.Sprintf("%s_selection%s[%s]", prefix, key, l.Endpoint), selection, UnitLabel})
+	}
+	return records
+}
+
+func latencyRecords(prefix string, stats *LatencyStats) []statsRecord {
+	return []statsRecord{
+		{prefix + "_samples", fmt.Sprintf("%d", stats.Count), UnitCount},
+		{prefix + "_p50", fmt.Sprintf("%.6f", stats.P50), UnitSeconds},
+		{prefix + "_p90", fmt.Sprintf("%.6f", stats.P90), UnitSeconds},
+		{prefix + "_p95", fmt.Sprintf("%.6f", stats.P95), UnitSeconds},
+		{prefix + "_p99", fmt.Sprintf("%.6f", stats.P99), UnitSeconds},
+		{prefix + "_max", fmt.Sprintf("%.6f", stats.Max), UnitSeconds},
+	}
+}
+
+// warnOnRateShortfall logs a warning if the achieved transaction rate fell
+// short of the target rate by more than the given percentage, since results
+// from such a load test are easily misinterpreted.
+func warnOnRateShortfall(logger logging.Logger, stats AggregateStats, maxDeviationPercent float64) {
+	if maxDeviationPercent <= 0 || stats.RateDeviationPercent <= maxDeviationPercent {
+		return
+	}
+	logger.Warn(
+		"Achieved transaction rate fell short of the target rate - the requested load was NOT generated",
+		"targetRate", fmt.Sprintf("%.2f txs/sec", stats.TargetTxRate),
+		"achievedRate", fmt.Sprintf("%.2f txs/sec", stats.AchievedTxRate),
+		"shortfall", fmt.Sprintf("%.1f%%", stats.RateDeviationPercent),
+		"likelyCauses", "CPU-bound transaction generation (try more workers or machines), high endpoint latency, or backpressure from the endpoints (e.g. full mempools or rate limits)",
+	)
 }
@@ -0,0 +1,119 @@
+package loadtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// statsFlushFile returns the local statistics output file to which to
+// periodically flush the statistics gathered so far while the load test is
+// underway, if any. Statistics that are appended or uploaded are only written
+// once the load test is over.
+func (c Config) statsFlushFile() (string, error) {
+	if c.StatsFlushInterval <= 0 || len(c.StatsOutputFile) == 0 || c.StatsAppend {
+		return "", nil
+	}
+	if u, _ := parseStatsUploadURL(c.StatsOutputFile); u != nil {
+		return "", nil
+	}
+	return prepareOutputFile(c.StatsOutputFile)
+}
+
+// flushStats writes the statistics gathered so far to the given statistics
+// output file, marked as running.
+func flushStats(filename string, cfg Config, stats AggregateStats, workers []WorkerStats) error {
+	stats.Compute()
+	stats.Status = StatsStatusRunning
+	return writeReport(filename, cfg.StatsOutputFormat, NewReport(cfg, stats, workers))
+}
+
+// statsFlusher periodically calls a function that flushes the statistics
+// gathered so far, and on request (e.g. once the load test is cancelled).
+type statsFlusher struct {
+	interval time.Duration
+	flush    func()
+
+	now      chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+func newStatsFlusher(interval time.Duration, flush func()) *statsFlusher {
+	return &statsFlusher{
+		interval: interval,
+		flush:    flush,
+		now:      make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (f *statsFlusher) run() {
+	defer close(f.stopped)
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.flush()
+
+		case <-f.now:
+			f.flush()
+
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// Flush asks for the statistics to be flushed right away, without waiting for
+// them to be. Safe to call on a nil flusher.
+func (f *statsFlusher) Flush() {
+	if f == nil {
+		return
+	}
+	select {
+	case f.now <- struct{}{}:
+	default:
+	}
+}
+
+// Stop blocks until the flusher has stopped, having finished any flush that's
+// underway, so that the final statistics can be written in its place. Safe to
+// call more than once, and on a nil flusher.
+func (f *statsFlusher) Stop() {
+	if f == nil {
+		return
+	}
+	f.stopOnce.Do(func() { close(f.stop) })
+	<-f.stopped
+}
+
+// startStandaloneStatsFlusher starts flushing the statistics gathered so far
+// by the given transactor group to the given statistics output file (and
+// syncing its timeseries statistics, if any), unless there's no file to which
+// to flush them.
+func startStandaloneStatsFlusher(filename string, cfg Config, tg *TransactorGroup, excluded []ExcludedEndpoint, tw *timeseriesWriter, logger logging.Logger) *statsFlusher {
+	if len(filename) == 0 {
+		return nil
+	}
+	f := newStatsFlusher(time.Duration(cfg.StatsFlushInterval), func() {
+		stats := tg.aggregateStats()
+		stats.ExcludedEndpoints = excluded
+		if err := flushStats(filename, cfg, stats, nil); err != nil {
+			logger.Error("Failed to flush aggregate statistics", "err", err)
+		}
+		if tw != nil {
+			if err := tw.Sync(); err != nil {
+				logger.Error("Failed to flush raw statistics", "err", err)
+			}
+		}
+	})
+	go f.run()
+	return f
+}
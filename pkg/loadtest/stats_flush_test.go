@@ -0,0 +1,148 @@
+package loadtest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForFlushedStats waits for the statistics flushed to the given file while
+// the load test is underway to cover some transactions.
+func waitForFlushedStats(t *testing.T, filename string) loadtest.Report {
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		require.True(t, time.Now().Before(deadline), "Timed out waiting for statistics to be flushed")
+		if f, err := os.Open(filename); err == nil {
+			report, err := loadtest.ParseReport(f)
+			f.Close()
+			require.NoError(t, err)
+			if report.Aggregate.TotalTxs > 0 {
+				return report
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func requireNoTempFiles(t *testing.T, dir string) {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	for _, e := range entries {
+		require.Equal(t, "stats.csv", e.Name())
+	}
+}
+
+func TestStandaloneStatsFlush(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(30)
+	cfg.Count = -1
+	cfg.StatsFlushInterval = seconds(1)
+	dir := t.TempDir()
+	cfg.StatsOutputFile = filepath.Join(dir, "stats.csv")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 1)
+	go func() { errs <- loadtest.ExecuteStandaloneWithContext(ctx, cfg) }()
+
+	// a load test killed at this point would leave these statistics behind
+	report := waitForFlushedStats(t, cfg.StatsOutputFile)
+	require.Equal(t, loadtest.StatsStatusRunning, report.Aggregate.Status)
+	sent := svr.Requests()
+	require.LessOrEqual(t, report.Aggregate.TotalTxs, sent)
+	// they're at most a couple of flushes behind
+	require.GreaterOrEqual(t, report.Aggregate.TotalTxs, sent-2*int(cfg.Rate))
+	require.Greater(t, report.Aggregate.TotalTimeSeconds, 0.0)
+
+	cancel()
+	select {
+	case err := <-errs:
+		require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for load test to be cancelled")
+	}
+	// the final statistics take the place of those flushed
+	f, err := os.Open(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	defer f.Close()
+	report, err = loadtest.ParseReport(f)
+	require.NoError(t, err)
+	require.Equal(t, loadtest.StatsStatusCancelled, report.Aggregate.Status)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+	requireNoTempFiles(t, dir)
+}
+
+func TestStandaloneStatsFlushDisabled(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(3)
+	cfg.Count = -1
+	cfg.StatsFlushInterval = 0
+	cfg.StatsOutputFile = filepath.Join(t.TempDir(), "stats.csv")
+
+	errs := make(chan error, 1)
+	go func() { errs <- loadtest.ExecuteStandalone(cfg) }()
+	waitForRequests(t, svr)
+	_, err := os.Stat(cfg.StatsOutputFile)
+	require.True(t, os.IsNotExist(err), "statistics written before the load test completed")
+	require.NoError(t, <-errs)
+	_, err = os.Stat(cfg.StatsOutputFile)
+	require.NoError(t, err)
+}
+
+func TestCoordinatorStatsFlush(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(60)
+	cfg.Count = -1
+	cfg.StatsFlushInterval = seconds(1)
+	dir := t.TempDir()
+	cfg.StatsOutputFile = filepath.Join(dir, "stats.csv")
+
+	addr := freeLocalAddr(t)
+	coord := loadtest.NewCoordinator(&cfg, &loadtest.CoordinatorConfig{
+		BindAddr:             addr,
+		ExpectWorkers:        1,
+		WorkerConnectTimeout: seconds(10),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	coordErrs := make(chan error, 1)
+	go func() { coordErrs <- coord.RunWithContext(ctx) }()
+	worker, err := loadtest.NewWorker(&loadtest.WorkerConfig{
+		ID:                  "worker0",
+		CoordAddr:           "ws://" + addr,
+		CoordConnectTimeout: seconds(10),
+	})
+	require.NoError(t, err)
+	workerErrs := make(chan error, 1)
+	go func() { workerErrs <- worker.Run() }()
+
+	// the coordinator flushes the statistics the workers have reported so far
+	report := waitForFlushedStats(t, cfg.StatsOutputFile)
+	require.Equal(t, loadtest.StatsStatusRunning, report.Aggregate.Status)
+	require.LessOrEqual(t, report.Aggregate.TotalTxs, svr.Requests())
+
+	cancel()
+	select {
+	case err := <-coordErrs:
+		require.ErrorIs(t, err, loadtest.ErrLoadTestCancelled)
+	case <-time.After(30 * time.Second):
+		t.Fatal("Timed out waiting for coordinator to stop")
+	}
+	require.ErrorIs(t, <-workerErrs, loadtest.ErrLoadTestCancelled)
+	f, err := os.Open(cfg.StatsOutputFile)
+	require.NoError(t, err)
+	defer f.Close()
+	report, err = loadtest.ParseReport(f)
+	require.NoError(t, err)
+	require.Equal(t, loadtest.StatsStatusCancelled, report.Aggregate.Status)
+	require.Equal(t, svr.Requests(), report.Aggregate.TotalTxs)
+	requireNoTempFiles(t, dir)
+}
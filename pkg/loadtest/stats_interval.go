@@ -0,0 +1,143 @@
+package loadtest
+
+import (
+	"sync"
+	"time"
+)
+
+// How often workers push their statistics to the coordinator by default.
+const defaultStatsPushInterval = 3 * time.Second
+
+// statsInterval is the statistics a worker gathered between two of its pushes
+// to the coordinator during a run.
+type statsInterval struct {
+	Seq              int            `json:"seq"`                         // The interval's sequence number, starting at 1 for each run.
+	Full             bool           `json:"full,omitempty"`              // Whether the interval covers the whole run thus far, rather than just the time since the previous interval (e.g. after the worker reconnected).
+	Txs              int            `json:"txs,omitempty"`               // The number of transactions sent during the interval.
+	Bytes            int64          `json:"bytes,omitempty"`             // The number of transaction bytes sent during the interval.
+	Failures         int            `json:"failures,omitempty"`          // The number of error responses received during the interval.
+	BroadcastLatency *latencySketch `json:"broadcast_latency,omitempty"` // The broadcast latencies measured during the interval.
+	CommitLatency    *latencySketch `json:"commit_latency,omitempty"`    // The send-to-commit latencies measured during the interval, if commit latency tracking is enabled.
+}
+
+// intervalTotals are the cumulative statistics covered by a run's intervals
+// thus far.
+type intervalTotals struct {
+	seq              int
+	txs              int
+	bytes            int64
+	failures         int
+	broadcastLatency *latencySketch
+	commitLatency    *latencySketch
+}
+
+// statsIntervals splits a worker's cumulative statistics into intervals to
+// push to the coordinator. Each interval is relative to the previous one,
+// unless a resync has been requested (because earlier intervals may have been
+// lost along with the connection to the coordinator), in which case the next
+// interval is a full one.
+type statsIntervals struct {
+	mtx    sync.Mutex
+	pushed intervalTotals
+	resync bool
+}
+
+func newStatsIntervals() *statsIntervals {
+	// the first interval of a run covers the whole of it, by definition
+	return &statsIntervals{resync: true}
+}
+
+// next returns the interval between the previous interval and the given
+// cumulative statistics.
+func (s *statsIntervals) next(txs int, bytes int64, failures int, broadcastLatency, commitLatency *latencySketch) *statsInterval {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	base := s.pushed
+	full := s.resync
+	if full {
+		base = intervalTotals{seq: base.seq}
+	}
+	interval := &statsInterval{
+		Seq:              base.seq + 1,
+		Full:             full,
+		Txs:              txs - base.txs,
+		Bytes:            bytes - base.bytes,
+		Failures:         failures - base.failures,
+		BroadcastLatency: broadcastLatency.Diff(base.broadcastLatency),
+		CommitLatency:    commitLatency.Diff(base.commitLatency),
+	}
+	s.pushed = intervalTotals{
+		seq:              interval.Seq,
+		txs:              txs,
+		bytes:            bytes,
+		failures:         failures,
+		broadcastLatency: broadcastLatency,
+		commitLatency:    commitLatency,
+	}
+	s.resync = false
+	return interval
+}
+
+// requestResync ensures that the next interval covers the whole run thus far.
+func (s *statsIntervals) requestResync() {
+	s.mtx.Lock()
+	s.resync = true
+	s.mtx.Unlock()
+}
+
+// fold adds the given interval to the totals (or, if it's a full interval,
+// replaces them), returning false if it's out of sequence. Intervals that were
+// already folded (e.g. because the worker resent them after reconnecting) are
+// out of sequence, as are those following lost intervals, until the worker
+// sends a full interval again.
+func (t *intervalTotals) fold(interval *statsInterval) bool {
+	switch {
+	case interval.Full && interval.Seq > t.seq:
+		*t = intervalTotals{}
+	case interval.Full || interval.Seq != t.seq+1:
+		return false
+	}
+	t.seq = interval.Seq
+	t.txs += interval.Txs
+	t.bytes += interval.Bytes
+	t.failures += interval.Failures
+	t.broadcastLatency = mergeLatencySketches(t.broadcastLatency, interval.BroadcastLatency)
+	t.commitLatency = mergeLatencySketches(t.commitLatency, interval.CommitLatency)
+	return true
+}
+
+// mergeLatencySketches returns a copy of the given sketch with the other one
+// merged into it, allowing for either of them being nil.
+func mergeLatencySketches(s, other *latencySketch) *latencySketch {
+	if other == nil {
+		return s
+	}
+	merged := newLatencySketch()
+	merged.Merge(s)
+	merged.Merge(other)
+	return merged
+}
+
+// foldStatsInterval folds the statistics interval in the given worker update
+// (if any) into the worker's totals for the run, and replaces the cumulative
+// statistics in the update with those totals. An out of sequence interval
+// leaves the totals as they were, so that nothing is ever counted twice. Must
+// only be called from the coordinator's event loop.
+func (c *Coordinator) foldStatsInterval(msg *workerMsg) {
+	if msg.Interval == nil {
+		return
+	}
+	totals, exists := c.intervalsPerWorker[msg.ID]
+	if !exists {
+		totals = &intervalTotals{}
+		c.intervalsPerWorker[msg.ID] = totals
+	}
+	if !totals.fold(msg.Interval) {
+		c.logger.Debug("Ignoring out of sequence statistics interval from worker", c.workerFields(msg.ID, "seq", msg.Interval.Seq, "full", msg.Interval.Full, "lastSeq", totals.seq)...)
+	}
+	msg.TxCount = totals.txs
+	msg.TotalTxBytes = totals.bytes
+	msg.Failures = totals.failures
+	msg.BroadcastLatency = totals.broadcastLatency
+	msg.CommitLatency = totals.commitLatency
+}
@@ -0,0 +1,68 @@
+package loadtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencySketchDiff(t *testing.T) {
+	base := newLatencySketch()
+	for i := 1; i <= 10; i++ {
+		base.Add(time.Duration(i) * time.Millisecond)
+	}
+	s := base.Copy()
+	for i := 1; i <= 5; i++ {
+		s.Add(time.Duration(i*100) * time.Millisecond)
+	}
+	d := s.Diff(base)
+	require.NotNil(t, d)
+	assert.Equal(t, uint64(5), d.Count)
+	// merging the difference into the base reproduces the sketch
+	base.Merge(d)
+	assert.Equal(t, s, base)
+	assert.Nil(t, s.Diff(s.Copy()))
+	assert.Equal(t, s, s.Diff(nil))
+}
+
+func TestStatsIntervalsFolding(t *testing.T) {
+	intervals := newStatsIntervals()
+	latencies := newLatencySketch()
+	var pushed []*statsInterval
+	for i := 1; i <= 4; i++ {
+		latencies.Add(time.Duration(i) * time.Millisecond)
+		pushed = append(pushed, intervals.next(10*i, int64(100*i), i-1, latencies.Copy(), nil))
+	}
+	assert.True(t, pushed[0].Full)
+	assert.False(t, pushed[1].Full)
+	assert.Equal(t, 10, pushed[1].Txs)
+
+	totals := &intervalTotals{}
+	require.True(t, totals.fold(pushed[0]))
+	require.True(t, totals.fold(pushed[1]))
+	// an interval resent after reconnecting isn't counted again
+	require.False(t, totals.fold(pushed[1]))
+	assert.Equal(t, 20, totals.txs)
+	// nor are intervals following a lost one
+	require.False(t, totals.fold(pushed[3]))
+	assert.Equal(t, 20, totals.txs)
+	assert.Equal(t, int64(200), totals.bytes)
+
+	// until the worker resyncs with a full interval
+	intervals.requestResync()
+	latencies.Add(5 * time.Millisecond)
+	full := intervals.next(50, 500, 4, latencies.Copy(), nil)
+	assert.True(t, full.Full)
+	require.True(t, totals.fold(full))
+	require.False(t, totals.fold(full))
+	assert.Equal(t, 50, totals.txs)
+	assert.Equal(t, int64(500), totals.bytes)
+	assert.Equal(t, 4, totals.failures)
+	assert.Equal(t, latencies, totals.broadcastLatency)
+	assert.Nil(t, totals.commitLatency)
+
+	require.True(t, totals.fold(intervals.next(60, 600, 4, latencies.Copy(), nil)))
+	assert.Equal(t, 60, totals.txs)
+}
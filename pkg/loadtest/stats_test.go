@@ -0,0 +1,139 @@
+package loadtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendAggregateStatsConcurrently(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "stats.csv")
+	stats := AggregateStats{
+		TotalTxs:         100,
+		TotalTimeSeconds: 10,
+		TotalBytes:       25000,
+		BroadcastLatency: &LatencyStats{Count: 100, P50: 0.01, P90: 0.02, P95: 0.03, P99: 0.04, Max: 0.05},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, appendAggregateStats(filename, &StatsWriter{}, fmt.Sprintf("run%d", i), stats))
+		}(i)
+	}
+	wg.Wait()
+
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 11)
+	assert.Equal(t, appendedStatsHeader, records[0])
+	col := make(map[string]int)
+	for i, name := range appendedStatsHeader {
+		col[name] = i
+	}
+	runIDs := make(map[string]bool)
+	for _, record := range records[1:] {
+		require.Len(t, record, len(appendedStatsHeader))
+		runIDs[record[col["run_id"]]] = true
+		assert.Equal(t, "100", record[col["total_txs"]])
+		assert.Equal(t, "10.000000", record[col["avg_tx_rate"]])
+		assert.Equal(t, "1.000000", record[col["success_ratio"]])
+		assert.Equal(t, "0.010000", record[col["broadcast_latency_p50"]])
+		// no mempool or commit latency statistics
+		assert.Equal(t, "", record[col["mempool_pauses"]])
+		assert.Equal(t, "", record[col["commit_latency_samples"]])
+	}
+	assert.Len(t, runIDs, 10)
+}
+
+func TestAggregateStatsSuccessRatio(t *testing.T) {
+	stats := AggregateStats{TotalTxs: 200, FailedTxs: 50}
+	stats.Compute()
+	assert.Equal(t, 0.75, stats.SuccessRatio)
+
+	stats = AggregateStats{}
+	stats.Compute()
+	assert.Equal(t, 0.0, stats.SuccessRatio)
+}
+
+func TestAggregateStatsRateDeviation(t *testing.T) {
+	stats := AggregateStats{TotalTxs: 900, TotalTimeSeconds: 10, TargetTxRate: 100}
+	stats.Compute()
+	assert.Equal(t, 90.0, stats.AchievedTxRate)
+	assert.InDelta(t, 10.0, stats.RateDeviationPercent, 1e-9)
+
+	stats = AggregateStats{TotalTxs: 1100, TotalTimeSeconds: 10, TargetTxRate: 100}
+	stats.Compute()
+	assert.InDelta(t, -10.0, stats.RateDeviationPercent, 1e-9)
+}
+
+func TestWarnOnRateShortfall(t *testing.T) {
+	testCases := []struct {
+		deviation    float64
+		maxDeviation float64
+		expectWarn   bool
+	}{
+		{10, 5, true},
+		{5, 5, false},
+		{-50, 5, false},
+		{10, 0, false},
+	}
+	for _, tc := range testCases {
+		logger := &recordingLogger{}
+		warnOnRateShortfall(logger, AggregateStats{TargetTxRate: 100, RateDeviationPercent: tc.deviation}, tc.maxDeviation)
+		if tc.expectWarn {
+			require.Len(t, logger.warns, 1, "deviation %.1f, max %.1f", tc.deviation, tc.maxDeviation)
+			assert.Contains(t, logger.warns[0], "fell short of the target rate")
+		} else {
+			assert.Empty(t, logger.warns, "deviation %.1f, max %.1f", tc.deviation, tc.maxDeviation)
+		}
+		assert.Empty(t, logger.errors)
+	}
+}
+
+// recordingLogger records the messages logged at each level.
+type recordingLogger struct {
+	logging.NoopLogger
+
+	mtx    sync.Mutex
+	debugs []string
+	infos  []string
+	warns  []string
+	errors []string
+}
+
+func (l *recordingLogger) Debug(msg string, kvpairs ...interface{}) {
+	l.mtx.Lock()
+	l.debugs = append(l.debugs, msg)
+	l.mtx.Unlock()
+}
+
+func (l *recordingLogger) Info(msg string, kvpairs ...interface{}) {
+	l.mtx.Lock()
+	l.infos = append(l.infos, msg)
+	l.mtx.Unlock()
+}
+
+func (l *recordingLogger) Warn(msg string, kvpairs ...interface{}) {
+	l.mtx.Lock()
+	l.warns = append(l.warns, msg)
+	l.mtx.Unlock()
+}
+
+func (l *recordingLogger) Error(msg string, kvpairs ...interface{}) {
+	l.mtx.Lock()
+	l.errors = append(l.errors, msg)
+	l.mtx.Unlock()
+}
@@ -0,0 +1,198 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	statsUploadAttempts       = 3
+	statsUploadRequestTimeout = 60 * time.Second
+)
+
+// The content types of uploaded statistics files, by extension.
+var statsContentTypes = map[string]string{
+	".csv":  "text/csv",
+	".json": "application/json",
+}
+
+// The delay before the first retry, doubled for each subsequent retry.
+var statsUploadRetryBackoff = time.Second
+
+// StatsUploader uploads statistics files to a cloud storage service. Uploaders
+// are registered under a URL scheme with RegisterStatsUploader, and are used
+// for statistics output destinations given as URLs of the form
+// scheme://bucket/key (e.g. s3://results/stats.csv).
+type StatsUploader interface {
+	// Upload stores the given object, returning whether the upload is worth
+	// retrying if it fails.
+	Upload(ctx context.Context, bucket, key, contentType string, body []byte) (retry bool, err error)
+}
+
+var (
+	statsUploadersMtx sync.RWMutex
+	statsUploaders    = make(map[string]StatsUploader)
+)
+
+// RegisterStatsUploader registers the given uploader for statistics output
+// URLs with the given scheme. The Amazon S3 (s3://) and Google Cloud Storage
+// (gs://) uploaders register themselves when the s3 and gcs subpackages are
+// imported. In coordinator/worker mode, only the coordinator uploads
+// statistics.
+func RegisterStatsUploader(scheme string, uploader StatsUploader) error {
+	if len(scheme) == 0 {
+		return fmt.Errorf("statistics uploader scheme must be specified")
+	}
+	statsUploadersMtx.Lock()
+	defer statsUploadersMtx.Unlock()
+	if _, exists := statsUploaders[scheme]; exists {
+		return fmt.Errorf("statistics uploader for the specified scheme already exists: %s", scheme)
+	}
+	statsUploaders[scheme] = uploader
+	return nil
+}
+
+// lookupStatsUploader returns the uploader registered for the given scheme.
+func lookupStatsUploader(scheme string) (StatsUploader, bool) {
+	statsUploadersMtx.RLock()
+	defer statsUploadersMtx.RUnlock()
+	uploader, ok := statsUploaders[scheme]
+	return uploader, ok
+}
+
+// parseStatsUploadURL parses the given statistics output destination,
+// returning nil if it's a plain (local) path rather than a cloud storage URL.
+// URLs with a scheme for which no uploader is registered are rejected.
+func parseStatsUploadURL(dest string) (*url.URL, error) {
+	scheme, _, ok := strings.Cut(dest, "://")
+	if !ok || !isStatsUploadScheme(scheme) {
+		return nil, nil
+	}
+	if _, ok := lookupStatsUploader(scheme); !ok {
+		return nil, fmt.Errorf("no statistics uploader is registered for %s:// URLs (see RegisterStatsUploader)", scheme)
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil, err
+	}
+	if len(u.Host) == 0 || len(strings.TrimPrefix(u.Path, "/")) == 0 {
+		return nil, fmt.Errorf("expected a URL of the form %s://bucket/key, but got %s", u.Scheme, dest)
+	}
+	return u, nil
+}
+
+// isStatsUploadScheme returns whether the given string is a URL scheme. Single
+// letters are taken to be Windows drive letters rather than schemes.
+func isStatsUploadScheme(scheme string) bool {
+	if len(scheme) < 2 {
+		return false
+	}
+	for i, r := range scheme {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '+' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// statsOutput is a statistics output file. If its destination is a cloud
+// storage URL, the file is written to a temporary location and uploaded
+// once complete.
+type statsOutput struct {
+	dest     string   // Where the statistics are ultimately to be stored.
+	filename string   // Where the statistics are to be written locally.
+	remote   *url.URL // The parsed destination, if it's a cloud storage URL.
+}
+
+func newStatsOutput(dest string) (*statsOutput, error) {
+	u, err := parseStatsUploadURL(dest)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		filename, err := prepareOutputFile(dest)
+		if err != nil {
+			return nil, err
+		}
+		return &statsOutput{dest: dest, filename: filename}, nil
+	}
+	f, err := os.CreateTemp("", "tm-load-test-*"+path.Ext(u.Path))
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return &statsOutput{dest: dest, filename: f.Name(), remote: u}, nil
+}
+
+// finish uploads the statistics to their destination, if it's a cloud
+// storage URL, retrying a few times on failure. Upload failures are only
+// logged (and the local copy kept) unless the configuration requires the
+// upload to succeed.
+func (o *statsOutput) finish(cfg Config, logger logging.Logger) error {
+	if o.remote == nil {
+		return nil
+	}
+	uploader, ok := lookupStatsUploader(o.remote.Scheme)
+	if !ok {
+		return fmt.Errorf("no statistics uploader is registered for %s:// URLs", o.remote.Scheme)
+	}
+	err := o.upload(uploader, logger)
+	if err == nil {
+		logger.Info("Uploaded statistics", "url", o.dest)
+		_ = os.Remove(o.filename)
+		return nil
+	}
+	logger.Warn("Failed to upload statistics - a local copy was kept", "url", o.dest, "localFile", o.filename, "err", err)
+	if cfg.RequireStatsUpload {
+		return fmt.Errorf("failed to upload statistics to %s: %w", o.dest, err)
+	}
+	return nil
+}
+
+func (o *statsOutput) upload(uploader StatsUploader, logger logging.Logger) error {
+	body, err := os.ReadFile(o.filename)
+	if err != nil {
+		return err
+	}
+	contentType, ok := statsContentTypes[path.Ext(o.remote.Path)]
+	if !ok {
+		contentType = "application/octet-stream"
+	}
+	bucket, key := o.remote.Host, strings.TrimPrefix(o.remote.Path, "/")
+	backoff := statsUploadRetryBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), statsUploadRequestTimeout)
+		retry, err := uploader.Upload(ctx, bucket, key, contentType, body)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= statsUploadAttempts {
+			return fmt.Errorf("giving up after %d attempt(s): %w", attempt, err)
+		}
+		logger.Debug("Failed to upload statistics - retrying", "url", o.dest, "err", err, "backoff", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// RetryableUploadStatus returns whether an upload request that failed with the
+// given HTTP status code is worth retrying.
+func RetryableUploadStatus(code int) bool {
+	// client errors (other than rate limiting) won't go away by retrying
+	return code >= 500 || code == http.StatusTooManyRequests
+}
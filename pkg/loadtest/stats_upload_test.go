@@ -0,0 +1,155 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubUploader records uploaded objects, failing the first few uploads as
+// configured.
+type stubUploader struct {
+	mtx     sync.Mutex
+	objects []stubObject
+	errs    []error // The errors with which to fail successive uploads.
+	retry   bool    // Whether failed uploads are worth retrying.
+}
+
+type stubObject struct {
+	bucket, key, contentType, body string
+}
+
+func (u *stubUploader) Upload(_ context.Context, bucket, key, contentType string, body []byte) (bool, error) {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	u.objects = append(u.objects, stubObject{bucket, key, contentType, string(body)})
+	if len(u.errs) > 0 {
+		err := u.errs[0]
+		u.errs = u.errs[1:]
+		return u.retry, err
+	}
+	return false, nil
+}
+
+// The uploader registered for stub:// URLs.
+var testStatsUploader = &stubUploader{}
+
+func init() {
+	if err := RegisterStatsUploader("stub", testStatsUploader); err != nil {
+		panic(err)
+	}
+}
+
+// resetStatsUploader clears the objects uploaded to stub:// URLs, and fails the
+// next uploads with the given errors.
+func resetStatsUploader(t *testing.T, retry bool, errs ...error) *stubUploader {
+	testStatsUploader.mtx.Lock()
+	defer testStatsUploader.mtx.Unlock()
+	testStatsUploader.objects, testStatsUploader.errs, testStatsUploader.retry = nil, errs, retry
+
+	backoff := statsUploadRetryBackoff
+	statsUploadRetryBackoff = time.Millisecond
+	t.Cleanup(func() { statsUploadRetryBackoff = backoff })
+	return testStatsUploader
+}
+
+func writeStatsOutput(t *testing.T, dest, content string) *statsOutput {
+	out, err := newStatsOutput(dest)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(out.filename, []byte(content), 0o644))
+	return out
+}
+
+func TestParseStatsUploadURL(t *testing.T) {
+	u, err := parseStatsUploadURL("/tmp/stats.csv")
+	require.NoError(t, err)
+	assert.Nil(t, u)
+
+	u, err = parseStatsUploadURL(`C://stats.csv`)
+	require.NoError(t, err)
+	assert.Nil(t, u)
+
+	u, err = parseStatsUploadURL("stub://bucket/dir/stats.csv")
+	require.NoError(t, err)
+	require.NotNil(t, u)
+	assert.Equal(t, "bucket", u.Host)
+	assert.Equal(t, "/dir/stats.csv", u.Path)
+
+	for _, dest := range []string{"stub://bucket", "stub://bucket/", "stub:///stats.csv"} {
+		_, err = parseStatsUploadURL(dest)
+		assert.Error(t, err, dest)
+	}
+
+	// there's no uploader for unregistered schemes
+	_, err = parseStatsUploadURL("s3://bucket/stats.csv")
+	assert.ErrorContains(t, err, "no statistics uploader is registered for s3:// URLs")
+}
+
+func TestStatsOutputLocal(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "stats.csv")
+	out := writeStatsOutput(t, filename, "a,b\n")
+	assert.Equal(t, filename, out.filename)
+	require.NoError(t, out.finish(Config{RequireStatsUpload: true}, logging.NewNoopLogger()))
+	b, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n", string(b))
+}
+
+func TestStatsOutputUpload(t *testing.T) {
+	uploader := resetStatsUploader(t, true, errors.New("service unavailable"))
+
+	out := writeStatsOutput(t, "stub://bucket/runs/stats 1.csv", "a,b\n")
+	require.NoError(t, out.finish(Config{}, logging.NewNoopLogger()))
+
+	// the first attempt failed with a retryable error
+	require.Len(t, uploader.objects, 2)
+	assert.Equal(t, stubObject{"bucket", "runs/stats 1.csv", "text/csv", "a,b\n"}, uploader.objects[1])
+	// the local copy is only needed until it has been uploaded
+	_, err := os.Stat(out.filename)
+	assert.True(t, os.IsNotExist(err))
+
+	uploader = resetStatsUploader(t, false)
+	out = writeStatsOutput(t, "stub://bucket/stats.json", "{}\n")
+	require.NoError(t, out.finish(Config{}, logging.NewNoopLogger()))
+	require.Len(t, uploader.objects, 1)
+	assert.Equal(t, "application/json", uploader.objects[0].contentType)
+}
+
+func TestStatsOutputUploadFailure(t *testing.T) {
+	uploader := resetStatsUploader(t, false, errors.New("forbidden"), errors.New("forbidden"))
+
+	// by default, upload failures aren't fatal
+	out := writeStatsOutput(t, "stub://bucket/stats.csv", "a,b\n")
+	require.NoError(t, out.finish(Config{}, logging.NewNoopLogger()))
+	// errors that aren't worth retrying aren't retried
+	require.Len(t, uploader.objects, 1)
+	// and the local copy is kept
+	b, err := os.ReadFile(out.filename)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n", string(b))
+	os.Remove(out.filename)
+
+	out = writeStatsOutput(t, "stub://bucket/stats.csv", "a,b\n")
+	defer os.Remove(out.filename)
+	assert.Error(t, out.finish(Config{RequireStatsUpload: true}, logging.NewNoopLogger()))
+
+	// retryable errors are retried a few times
+	uploader = resetStatsUploader(t, true, errors.New("a"), errors.New("b"), errors.New("c"), errors.New("d"))
+	out = writeStatsOutput(t, "stub://bucket/stats.csv", "a,b\n")
+	defer os.Remove(out.filename)
+	assert.ErrorContains(t, out.finish(Config{RequireStatsUpload: true}, logging.NewNoopLogger()), "giving up after 3 attempt(s): c")
+	assert.Len(t, uploader.objects, 3)
+}
+
+func TestRegisterStatsUploader(t *testing.T) {
+	assert.Error(t, RegisterStatsUploader("", &stubUploader{}))
+	assert.Error(t, RegisterStatsUploader("stub", &stubUploader{}))
+}
@@ -0,0 +1,144 @@
+package loadtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// StatsUnit is the unit of a single value in the aggregate statistics CSV
+// output.
+type StatsUnit int
+
+const (
+	UnitCount          StatsUnit = iota // A number of things (transactions, connections, etc.).
+	UnitSeconds                         // A duration, in seconds.
+	UnitBytes                           // A number of bytes.
+	UnitTxsPerSecond                    // A transaction rate.
+	UnitBytesPerSecond                  // A data rate.
+	UnitRatio                           // A fraction between 0 and 1.
+	UnitPercent                         // A percentage.
+	UnitHeight                          // A block height.
+	UnitTxsPerBlock                     // A number of transactions per block.
+	UnitLabel                           // A descriptive label rather than a quantity.
+)
+
+var statsUnitNames = map[StatsUnit]string{
+	UnitCount:          "count",
+	UnitSeconds:        "seconds",
+	UnitBytes:          "bytes",
+	UnitTxsPerSecond:   "txs_per_second",
+	UnitBytesPerSecond: "bytes_per_second",
+	UnitRatio:          "ratio",
+	UnitPercent:        "percent",
+	UnitHeight:         "height",
+	UnitTxsPerBlock:    "txs_per_block",
+	UnitLabel:          "label",
+}
+
+// The descriptive unit labels of the original CSV output, which is kept as-is
+// for compatibility by default.
+var statsUnitLabels = map[StatsUnit]string{
+	UnitTxsPerSecond:   "transactions per second",
+	UnitBytesPerSecond: "bytes per second",
+	UnitTxsPerBlock:    "transactions per block",
+}
+
+// String returns the unit's normalized, machine-readable name (e.g.
+// "txs_per_second").
+func (u StatsUnit) String() string {
+	if name, ok := statsUnitNames[u]; ok {
+		return name
+	}
+	return fmt.Sprintf("StatsUnit(%d)", int(u))
+}
+
+func (u StatsUnit) label() string {
+	if label, ok := statsUnitLabels[u]; ok {
+		return label
+	}
+	return u.String()
+}
+
+// statsRecord is a single parameter in the aggregate statistics CSV output.
+type statsRecord struct {
+	name  string
+	value string
+	unit  StatsUnit
+}
+
+// StatsWriter writes aggregate statistics in CSV format. Its zero value
+// produces the original output format: comma-delimited, with a descriptive
+// header and units.
+type StatsWriter struct {
+	// Write a machine-readable header row ("parameter,value,unit") and
+	// normalized unit names (see StatsUnit.String) instead of the descriptive
+	// ones.
+	Header bool
+	// The field delimiter. Defaults to a comma if zero.
+	Delimiter rune
+}
+
+// NewStatsWriter returns a statistics writer configured according to the
+// given load testing configuration.
+func NewStatsWriter(cfg Config) *StatsWriter {
+	return &StatsWriter{
+		Header:    cfg.StatsCSVHeader,
+		Delimiter: cfg.StatsCSVDelimiter,
+	}
+}
+
+// WriteAggregate writes the given aggregate and per-worker statistics to w,
+// one parameter per row. The statistics must already have been computed.
+func (sw *StatsWriter) WriteAggregate(w io.Writer, stats AggregateStats, workers []WorkerStats) error {
+	cw := sw.csvWriter(w)
+	header := []string{"Parameter", "Value", "Units"}
+	if sw.Header {
+		header = []string{"parameter", "value", "unit"}
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range aggregateStatsRecords(stats, workers) {
+		unit := r.unit.label()
+		if sw.Header {
+			unit = r.unit.String()
+		}
+		if err := cw.Write([]string{r.name, r.value, unit}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteRun writes the given run's aggregate statistics to w as a single row,
+// preceded by the header row if requested. The statistics must already have
+// been computed. Per-endpoint and per-worker statistics are omitted.
+func (sw *StatsWriter) WriteRun(w io.Writer, runID string, stats AggregateStats, header bool) error {
+	cw := sw.csvWriter(w)
+	if header {
+		if err := cw.Write(appendedStatsHeader); err != nil {
+			return err
+		}
+	}
+	if err := cw.Write(runStatsColumns(runID, stats)); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (sw *StatsWriter) csvWriter(w io.Writer) *csv.Writer {
+	cw := csv.NewWriter(w)
+	if sw.Delimiter != 0 {
+		cw.Comma = sw.Delimiter
+	}
+	return cw
+}
+
+// validStatsCSVDelimiter mirrors the delimiter restrictions of encoding/csv.
+func validStatsCSVDelimiter(r rune) bool {
+	return r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
@@ -0,0 +1,104 @@
+package loadtest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStatsWriterStats() AggregateStats {
+	stats := AggregateStats{
+		TotalTxs:         100,
+		TotalTimeSeconds: 10,
+		TotalBytes:       25000,
+		TargetTxRate:     10,
+	}
+	stats.Compute()
+	return stats
+}
+
+const testStatsWriterPrefix = "total_time,10.000,seconds\n" +
+	"total_txs,100,count\n" +
+	"total_bytes,25000,bytes\n" +
+	"avg_tx_rate,10.000000,transactions per second\n" +
+	"avg_data_rate,2500.000000,bytes per second\n" +
+	"failed_txs,0,count\n" +
+	"success_ratio,1.000000,ratio\n"
+
+func TestStatsWriterDefault(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, (&StatsWriter{}).WriteAggregate(&buf, testStatsWriterStats(), nil))
+	// the default output must remain unchanged
+	assert.True(t, strings.HasPrefix(buf.String(), "Parameter,Value,Units\n"+testStatsWriterPrefix), buf.String())
+	assert.Contains(t, buf.String(), "\nrate_deviation,0.000,percent\n")
+}
+
+func TestStatsWriterOptions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		sw        StatsWriter
+		delimiter rune
+		header    []string
+		unit      string
+	}{
+		{"header", StatsWriter{Header: true}, ',', []string{"parameter", "value", "unit"}, "txs_per_second"},
+		{"delimiter", StatsWriter{Delimiter: ';'}, ';', []string{"Parameter", "Value", "Units"}, "transactions per second"},
+		{"header and delimiter", StatsWriter{Header: true, Delimiter: '\t'}, '\t', []string{"parameter", "value", "unit"}, "txs_per_second"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, tc.sw.WriteAggregate(&buf, testStatsWriterStats(), []WorkerStats{{ID: "w1", TotalTxs: 100}}))
+			r := csv.NewReader(&buf)
+			r.Comma = tc.delimiter
+			records, err := r.ReadAll()
+			require.NoError(t, err)
+			assert.Equal(t, tc.header, records[0])
+			units := make(map[string]string)
+			for _, record := range records[1:] {
+				require.Len(t, record, 3)
+				units[record[0]] = record[2]
+			}
+			assert.Equal(t, tc.unit, units["avg_tx_rate"])
+			assert.Equal(t, "count", units["worker_total_txs[w1]"])
+		})
+	}
+}
+
+func TestStatsWriterRun(t *testing.T) {
+	var buf bytes.Buffer
+	sw := &StatsWriter{Delimiter: ';'}
+	require.NoError(t, sw.WriteRun(&buf, "run1", testStatsWriterStats(), true))
+	require.NoError(t, sw.WriteRun(&buf, "run2", testStatsWriterStats(), false))
+	r := csv.NewReader(&buf)
+	r.Comma = ';'
+	records, err := r.ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, appendedStatsHeader, records[0])
+	assert.Equal(t, "run1", records[1][0])
+	assert.Equal(t, "run2", records[2][0])
+}
+
+func TestStatsUnitString(t *testing.T) {
+	assert.Equal(t, "txs_per_second", UnitTxsPerSecond.String())
+	assert.Equal(t, "transactions per second", UnitTxsPerSecond.label())
+	assert.Equal(t, "seconds", UnitSeconds.label())
+	assert.Equal(t, "StatsUnit(100)", StatsUnit(100).String())
+}
+
+func TestRuneValue(t *testing.T) {
+	var r rune
+	v := newRuneValue(',', &r)
+	assert.Equal(t, ',', r)
+	require.NoError(t, v.Set(";"))
+	assert.Equal(t, ';', r)
+	require.NoError(t, v.Set(`\t`))
+	assert.Equal(t, '\t', r)
+	assert.Equal(t, `\t`, v.String())
+	assert.Error(t, v.Set(";;"))
+}
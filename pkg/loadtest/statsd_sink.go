@@ -0,0 +1,204 @@
+package loadtest
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	defaultStatsdPrefix = "tmloadtest"
+
+	statsdFlushInterval = 1 * time.Second
+
+	// The maximum size of each StatsD packet, such that it fits into a single
+	// Ethernet frame.
+	statsdMaxPacketSize = 1432
+
+	// Beyond this transaction rate (per TransactorGroup), only a sample of
+	// broadcast latencies is sent.
+	statsdMaxTimingsPerSecond = 1000
+)
+
+// statsdSink forwards metrics to a StatsD server (such as the Datadog agent)
+// over UDP, with Datadog-style tags. Counters are aggregated in memory and,
+// along with any timings, sent in batches at regular intervals.
+type statsdSink struct {
+	conn        net.Conn
+	prefix      string
+	tags        []string // Tags to add to all metrics, in "key:value" form.
+	sampleEvery uint64   // Only send every N-th broadcast latency.
+	logger      logging.Logger
+
+	latencies atomic.Uint64 // The total number of broadcast latencies received.
+
+	mtx        sync.Mutex
+	counters   map[statsdCounterKey]int64
+	timings    []string // Formatted timing metrics awaiting sending.
+	sendErrors int
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type statsdCounterKey struct {
+	name     string
+	endpoint string
+}
+
+var _ MetricsSink = (*statsdSink)(nil)
+
+// newStatsdSink creates a sink that sends metrics to the StatsD server at the
+// given address, sampling broadcast latencies if the expected transaction
+// rate is high.
+func newStatsdSink(addr, prefix string, tags []string, expectedTxRate float64, logger logging.Logger) (*statsdSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to StatsD server at %s: %w", addr, err)
+	}
+	sampleEvery := uint64(1)
+	if expectedTxRate > statsdMaxTimingsPerSecond {
+		sampleEvery = uint64(expectedTxRate/statsdMaxTimingsPerSecond) + 1
+	}
+	if len(prefix) == 0 {
+		prefix = defaultStatsdPrefix
+	}
+	s := &statsdSink{
+		conn:        conn,
+		prefix:      prefix,
+		tags:        tags,
+		sampleEvery: sampleEvery,
+		logger:      logger,
+		counters:    make(map[statsdCounterKey]int64),
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+func (s *statsdSink) TxsSent(endpoint string, count int, bytes int64) {
+	s.mtx.Lock()
+	s.counters[statsdCounterKey{"txs", endpoint}] += int64(count)
+	s.counters[statsdCounterKey{"bytes", endpoint}] += bytes
+	s.mtx.Unlock()
+}
+
+func (s *statsdSink) TxFailed(endpoint string) {
+	s.mtx.Lock()
+	s.counters[statsdCounterKey{"failures", endpoint}]++
+	s.mtx.Unlock()
+}
+
+func (s *statsdSink) BroadcastLatency(endpoint string, latency time.Duration) {
+	if s.latencies.Add(1)%s.sampleEvery != 0 {
+		return
+	}
+	line := s.format("broadcast_latency", fmt.Sprintf("%.3f", float64(latency.Microseconds())/1000), "ms", endpoint)
+	s.mtx.Lock()
+	s.timings = append(s.timings, line)
+	s.mtx.Unlock()
+}
+
+// Close sends any outstanding metrics and closes the connection to the StatsD
+// server.
+func (s *statsdSink) Close() error {
+	close(s.stop)
+	<-s.stopped
+	s.mtx.Lock()
+	if s.sendErrors > 0 {
+		s.logger.Error("Failed to send some metrics to StatsD server", "failedPackets", s.sendErrors)
+	}
+	s.mtx.Unlock()
+	return s.conn.Close()
+}
+
+func (s *statsdSink) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(statsdFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *statsdSink) flush() {
+	s.mtx.Lock()
+	lines := make([]string, 0, len(s.counters)+len(s.timings))
+	for key, value := range s.counters {
+		lines = append(lines, s.format(key.name, fmt.Sprintf("%d", value), "c", key.endpoint))
+	}
+	// for deterministic output
+	sort.Strings(lines)
+	lines = append(lines, s.timings...)
+	s.counters = make(map[statsdCounterKey]int64)
+	s.timings = nil
+	s.mtx.Unlock()
+
+	for _, packet := range packStatsdLines(lines) {
+		if _, err := s.conn.Write(packet); err != nil {
+			s.mtx.Lock()
+			if s.sendErrors == 0 {
+				s.logger.Error("Failed to send metrics to StatsD server", "err", err)
+			}
+			s.sendErrors++
+			s.mtx.Unlock()
+		}
+	}
+}
+
+// format formats a single metric in the StatsD line protocol, with
+// Datadog-style tags.
+func (s *statsdSink) format(name, value, metricType, endpoint string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s.%s:%s|%s", s.prefix, name, value, metricType)
+	if metricType == "ms" && s.sampleEvery > 1 {
+		fmt.Fprintf(&sb, "|@%g", 1/float64(s.sampleEvery))
+	}
+	tags := s.tags
+	if len(endpoint) > 0 {
+		tags = append(tags[:len(tags):len(tags)], "endpoint:"+endpoint)
+	}
+	if len(tags) > 0 {
+		sb.WriteString("|#")
+		sb.WriteString(strings.Join(tags, ","))
+	}
+	return sb.String()
+}
+
+// packStatsdLines packs the given metric lines into as few packets as
+// possible, each no larger than statsdMaxPacketSize (unless a single line
+// exceeds it).
+func packStatsdLines(lines []string) [][]byte {
+	packets := make([][]byte, 0)
+	var packet []byte
+	for _, line := range lines {
+		if len(packet) > 0 && len(packet)+1+len(line) > statsdMaxPacketSize {
+			packets = append(packets, packet)
+			packet = nil
+		}
+		if len(packet) > 0 {
+			packet = append(packet, '\n')
+		}
+		packet = append(packet, line...)
+	}
+	if len(packet) > 0 {
+		packets = append(packets, packet)
+	}
+	return packets
+}
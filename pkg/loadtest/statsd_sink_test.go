@@ -0,0 +1,80 @@
+package loadtest
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsdSink(t *testing.T) {
+	conn := listenUDP(t)
+	sink, err := newStatsdSink(conn.LocalAddr().String(), "loadtest", []string{"worker:w1"}, 10, logging.NewNoopLogger())
+	require.NoError(t, err)
+
+	sink.TxsSent("ws://localhost:26657/websocket", 2, 500)
+	sink.TxsSent("ws://localhost:26657/websocket", 1, 250)
+	sink.TxFailed("ws://localhost:26657/websocket")
+	sink.BroadcastLatency("ws://localhost:26657/websocket", 1500*time.Microsecond)
+	require.NoError(t, sink.Close())
+
+	lines := readStatsdLines(t, conn)
+	tags := "|#worker:w1,endpoint:ws://localhost:26657/websocket"
+	assert.ElementsMatch(t, []string{
+		"loadtest.txs:3|c" + tags,
+		"loadtest.bytes:750|c" + tags,
+		"loadtest.failures:1|c" + tags,
+		"loadtest.broadcast_latency:1.500|ms" + tags,
+	}, lines)
+}
+
+func TestStatsdSinkSamplesLatencies(t *testing.T) {
+	conn := listenUDP(t)
+	sink, err := newStatsdSink(conn.LocalAddr().String(), "", nil, 9500, logging.NewNoopLogger())
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		sink.BroadcastLatency("ws://host:26657/websocket", time.Millisecond)
+	}
+	require.NoError(t, sink.Close())
+
+	lines := readStatsdLines(t, conn)
+	require.Len(t, lines, 10)
+	for _, line := range lines {
+		assert.Equal(t, "tmloadtest.broadcast_latency:1.000|ms|@0.1|#endpoint:ws://host:26657/websocket", line)
+	}
+}
+
+func TestPackStatsdLines(t *testing.T) {
+	line := strings.Repeat("x", 600)
+	packets := packStatsdLines([]string{line, line, line})
+	require.Len(t, packets, 2)
+	assert.Equal(t, line+"\n"+line, string(packets[0]))
+	assert.Equal(t, line, string(packets[1]))
+}
+
+func listenUDP(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readStatsdLines reads all of the metric lines received on the given
+// connection until no more packets arrive.
+func readStatsdLines(t *testing.T, conn *net.UDPConn) []string {
+	lines := make([]string, 0)
+	buf := make([]byte, 65536)
+	for {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return lines
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+}
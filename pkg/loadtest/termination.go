@@ -0,0 +1,82 @@
+package loadtest
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+// ErrLoadTestTerminated is returned when a load test is terminated (by
+// SIGTERM, e.g. when its container is stopped) before it completes. It wraps
+// ErrLoadTestCancelled, as the statistics gathered up until then are still
+// written, marked as terminated.
+var ErrLoadTestTerminated = fmt.Errorf("%w by termination signal", ErrLoadTestCancelled)
+
+const (
+	// How long a terminated load test waits for the responses to the
+	// transactions still in flight (if --drain-timeout allows that long).
+	terminationDrainTimeout = 5 * time.Second
+
+	// How long tm-load-test has to wrap up after SIGTERM before exiting
+	// regardless, which is within the 30 seconds container orchestrators
+	// usually allow before killing the process.
+	terminationGracePeriod = 25 * time.Second
+)
+
+// forceExit exits the process when it fails to wrap up in time after a
+// signal. Overridden in tests.
+var forceExit = os.Exit
+
+// terminationDrain returns how long the given configuration's load test waits
+// for in-flight responses once terminated.
+func terminationDrain(cfg Config) time.Duration {
+	drain := time.Duration(cfg.DrainTimeout)
+	if drain > terminationDrainTimeout {
+		return terminationDrainTimeout
+	}
+	return drain
+}
+
+// trapSignals calls onInterrupt (in its own goroutine) when SIGINT is
+// received, or onTerminate when SIGTERM is, until the returned channel is
+// closed. Once either has been received, a second signal (or failing to close
+// the channel within terminationGracePeriod of SIGTERM) exits the process
+// immediately.
+func trapSignals(onInterrupt, onTerminate func(), logger logging.Logger) chan struct{} {
+	sigc := make(chan os.Signal, 1)
+	cancelTrap := make(chan struct{})
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigc)
+		var graceC <-chan time.Time
+		select {
+		case sig := <-sigc:
+			if sig == syscall.SIGTERM {
+				logger.Info("Caught termination signal - wrapping up", "gracePeriod", terminationGracePeriod.String())
+				graceC = time.After(terminationGracePeriod)
+				go onTerminate()
+			} else {
+				logger.Info("Caught kill signal")
+				go onInterrupt()
+			}
+		case <-cancelTrap:
+			logger.Debug("Interrupt trap cancelled")
+			return
+		}
+		select {
+		case <-sigc:
+			logger.Error("Caught second signal - exiting immediately")
+			forceExit(ExitCodeCancelled)
+		case <-graceC:
+			logger.Error("Failed to wrap up within grace period after termination signal - exiting immediately", "gracePeriod", terminationGracePeriod.String())
+			forceExit(ExitCodeCancelled)
+		case <-cancelTrap:
+			logger.Debug("Interrupt trap cancelled")
+		}
+	}()
+	return cancelTrap
+}
@@ -0,0 +1,60 @@
+package loadtest
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrapSignalsSecondSignalForcesExit(t *testing.T) {
+	exits := make(chan int, 1)
+	defer func(exit func(int)) { forceExit = exit }(forceExit)
+	forceExit = func(code int) { exits <- code }
+
+	interrupted, terminated := make(chan struct{}), make(chan struct{})
+	wrapUp := make(chan struct{})
+	cancelTrap := trapSignals(
+		func() { close(interrupted) },
+		func() {
+			close(terminated)
+			// a load test that doesn't wrap up in time
+			<-wrapUp
+		},
+		logging.NewNoopLogger(),
+	)
+	defer close(cancelTrap)
+	defer close(wrapUp)
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case <-terminated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for termination handler")
+	}
+	select {
+	case <-interrupted:
+		t.Fatal("Interrupt handler called for termination signal")
+	case <-exits:
+		t.Fatal("Exited before second signal")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGINT))
+	select {
+	case code := <-exits:
+		assert.Equal(t, ExitCodeCancelled, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for second signal to force exit")
+	}
+}
+
+func TestTerminationDrain(t *testing.T) {
+	assert.Equal(t, 2*time.Second, terminationDrain(Config{DrainTimeout: Duration(2 * time.Second)}))
+	assert.Equal(t, terminationDrainTimeout, terminationDrain(Config{DrainTimeout: Duration(time.Minute)}))
+	assert.Zero(t, terminationDrain(Config{}))
+}
@@ -0,0 +1,154 @@
+package loadtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"time"
+)
+
+var timeseriesHeader = []string{"t_seconds", "worker", "txs", "bytes", "failures", "avg_latency_ms"}
+
+// The worker name used in the timeseries CSV in standalone mode.
+const standaloneTimeseriesWorker = "standalone"
+
+// timeseriesSample summarizes the activity of a TransactorGroup over a single
+// sampling interval.
+type timeseriesSample struct {
+	TSeconds     float64 `json:"t"`              // The end of the interval, in seconds since the start of the load test.
+	Txs          int     `json:"txs"`            // The number of transactions sent during the interval.
+	Bytes        int64   `json:"bytes"`          // The number of transaction bytes sent during the interval.
+	Failures     int     `json:"failures"`       // The number of error responses received during the interval.
+	AvgLatencyMs float64 `json:"avg_latency_ms"` // The mean broadcast latency of the transactions measured during the interval.
+}
+
+// timeseriesTotals are the cumulative totals from which timeseries samples are
+// computed.
+type timeseriesTotals struct {
+	txs          int
+	bytes        int64
+	failures     int
+	latencyCount uint64
+	latencySum   time.Duration
+}
+
+// timeseriesRecorder periodically samples a TransactorGroup's activity,
+// passing each sample to a callback.
+type timeseriesRecorder struct {
+	g        *TransactorGroup
+	interval time.Duration
+	callback func(timeseriesSample)
+	last     timeseriesTotals
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func newTimeseriesRecorder(g *TransactorGroup, interval time.Duration, callback func(timeseriesSample)) *timeseriesRecorder {
+	return &timeseriesRecorder{
+		g:        g,
+		interval: interval,
+		callback: callback,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (r *timeseriesRecorder) run() {
+	defer close(r.stopped)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.sample()
+
+		case <-r.stop:
+			// capture whatever happened since the last full interval
+			r.sample()
+			return
+		}
+	}
+}
+
+// Stop takes a final sample and blocks until the recorder has stopped.
+func (r *timeseriesRecorder) Stop() {
+	close(r.stop)
+	<-r.stopped
+}
+
+func (r *timeseriesRecorder) sample() {
+	totals := r.g.timeseriesTotals()
+	s := timeseriesSample{
+		TSeconds: time.Since(r.g.getStartTime()).Seconds(),
+		Txs:      totals.txs - r.last.txs,
+		Bytes:    totals.bytes - r.last.bytes,
+		Failures: totals.failures - r.last.failures,
+	}
+	if latencyCount := totals.latencyCount - r.last.latencyCount; latencyCount > 0 {
+		latencySum := totals.latencySum - r.last.latencySum
+		s.AvgLatencyMs = float64(latencySum.Microseconds()) / 1000 / float64(latencyCount)
+	}
+	r.last = totals
+	r.callback(s)
+}
+
+// timeseriesWriter incrementally writes timeseries samples to a CSV file,
+// flushing after every write so that an interrupted load test still leaves
+// usable data behind.
+type timeseriesWriter struct {
+	f *os.File
+	w *csv.Writer
+}
+
+func newTimeseriesWriter(filename string) (*timeseriesWriter, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	tw := &timeseriesWriter{f: f, w: csv.NewWriter(f)}
+	if err := tw.writeRecords([][]string{timeseriesHeader}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return tw, nil
+}
+
+// Write appends the given worker's samples to the CSV file.
+func (tw *timeseriesWriter) Write(worker string, samples ...timeseriesSample) error {
+	records := make([][]string, 0, len(samples))
+	for _, s := range samples {
+		records = append(records, []string{
+			fmt.Sprintf("%.3f", s.TSeconds),
+			worker,
+			fmt.Sprintf("%d", s.Txs),
+			fmt.Sprintf("%d", s.Bytes),
+			fmt.Sprintf("%d", s.Failures),
+			fmt.Sprintf("%.3f", s.AvgLatencyMs),
+		})
+	}
+	return tw.writeRecords(records)
+}
+
+func (tw *timeseriesWriter) writeRecords(records [][]string) error {
+	for _, record := range records {
+		if err := tw.w.Write(record); err != nil {
+			return err
+		}
+	}
+	tw.w.Flush()
+	return tw.w.Error()
+}
+
+// Sync commits the samples written so far to stable storage. Safe to call
+// concurrently with Write, which flushes its samples to the file itself.
+func (tw *timeseriesWriter) Sync() error {
+	return tw.f.Sync()
+}
+
+func (tw *timeseriesWriter) Close() error {
+	tw.w.Flush()
+	return tw.f.Close()
+}
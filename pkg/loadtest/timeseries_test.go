@@ -0,0 +1,74 @@
+package loadtest_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandaloneTimeseries(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	outFile := filepath.Join(t.TempDir(), "timeseries.csv")
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(3)
+	cfg.Count = -1
+	cfg.RawStatsOutputFile = outFile
+	cfg.RawStatsInterval = seconds(1)
+	require.NoError(t, loadtest.ExecuteStandalone(cfg))
+
+	rows := readTimeseries(t, outFile)
+	// one row per second, plus possibly a partial interval at the end
+	require.GreaterOrEqual(t, len(rows), int(cfg.Time.Seconds()))
+	require.LessOrEqual(t, len(rows), int(cfg.Time.Seconds())+2)
+	totalTxs := 0
+	for _, row := range rows {
+		require.Equal(t, "standalone", row[1])
+		var txs int
+		_, err := fmt.Sscanf(row[2], "%d", &txs)
+		require.NoError(t, err)
+		totalTxs += txs
+	}
+	require.Equal(t, svr.Requests(), totalTxs)
+}
+
+func TestCoordinatorTimeseries(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	outFile := filepath.Join(t.TempDir(), "timeseries.csv")
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(3)
+	cfg.Count = -1
+	cfg.RawStatsOutputFile = outFile
+	cfg.RawStatsInterval = seconds(1)
+	workers := 2
+	runCoordinatorWorkers(t, cfg, workers)
+
+	rows := readTimeseries(t, outFile)
+	expectedRows := workers * int(cfg.Time.Seconds())
+	require.GreaterOrEqual(t, len(rows), expectedRows)
+	require.LessOrEqual(t, len(rows), expectedRows+2*workers)
+	rowsPerWorker := make(map[string]int)
+	for _, row := range rows {
+		rowsPerWorker[row[1]]++
+	}
+	require.Len(t, rowsPerWorker, workers)
+}
+
+// readTimeseries returns the data rows from the given timeseries CSV file,
+// after checking its header.
+func readTimeseries(t *testing.T, filename string) [][]string {
+	f, err := os.Open(filename)
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.NotEmpty(t, records)
+	require.Equal(t, []string{"t_seconds", "worker", "txs", "bytes", "failures", "avg_latency_ms"}, records[0])
+	return records[1:]
+}
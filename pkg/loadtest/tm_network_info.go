@@ -1,9 +1,14 @@
 package loadtest
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
 	"net"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/informalsystems/tm-load-test/internal/logging"
@@ -15,38 +20,62 @@ type peerInfo struct {
 	Client              *httpClient // The client to use to query this peer's Tendermint RPC endpoint.
 	PeerAddrs           []string    // The peers of this peer.
 	SuccessfullyQueried bool        // Has this peer been successfully queried?
+
+	NodeInfo      *DefaultNodeInfo            // The node information of this peer, as reported by a peer connected to it (nil if unknown).
+	PeerNodeInfos map[string]*DefaultNodeInfo // The node information of the peers of this peer, keyed by address.
 }
 
+const (
+	// How often to query peers while waiting for them to connect, if no poll
+	// interval is configured.
+	defaultPeerPollInterval = 1 * time.Second
+	// How often to log a summary of the peers seen while waiting for them to
+	// connect.
+	peerWaitProgressInterval = 5 * time.Second
+)
+
 // Waits for the given minimum number of peers to be present on the network
 // with the given starting list of peer addresses (or until the timeout
-// expires). On success, returns the number of peers connected (for reporting),
-// and on failure returns the relevant error.
+// expires, or the context is cancelled), querying them every pollInterval. On
+// success, returns the number of peers connected (for reporting), and on
+// failure returns the relevant error, which on timeout describes how close the
+// network came to the expected peers and connectivity.
 //
 // NOTE: this only works if the peers' RPC endpoints are bound to port 26657.
 //
 // TODO: Add in a stabilization time parameter (i.e. a minimum number of peers
 // must be present when polled repeatedly for a period of time).
 func waitForNetworkPeers(
+	ctx context.Context,
 	startingPeerAddrs []string,
 	selectionMethod string,
 	minDiscoveredPeers int,
 	minPeerConnectivity int,
 	maxReturnedPeers int,
+	seed int64,
+	filter *discoveryFilter,
 	timeout time.Duration,
+	pollInterval time.Duration,
 	logger logging.Logger,
 ) ([]string, error) {
+	if pollInterval <= 0 {
+		pollInterval = defaultPeerPollInterval
+	}
 	logger.Info(
 		"Waiting for peers to connect",
 		"minDiscoveredPeers", minDiscoveredPeers,
 		"maxReturnedPeers", maxReturnedPeers,
 		"timeout", fmt.Sprintf("%.2f seconds", timeout.Seconds()),
+		"pollInterval", pollInterval,
 		"selectionMethod", selectionMethod,
 	)
 
-	cancelc := make(chan struct{}, 1)
-	cancelTrap := trapInterrupts(func() { close(cancelc) }, logger)
+	cancelc := make(chan struct{})
+	var cancelOnce sync.Once
+	cancel := func() { cancelOnce.Do(func() { close(cancelc) }) }
+	cancelTrap := trapInterrupts(cancel, logger)
 	defer close(cancelTrap)
-	startTime := time.Now()
+	defer afterFunc(ctx, cancel)()
 	suppliedPeers := make(map[string]*peerInfo)
 	for _, peerURL := range startingPeerAddrs {
 		u, err := url.Parse(peerURL)
@@ -54,15 +83,15 @@ func waitForNetworkPeers(
 			return nil, fmt.Errorf("failed to parse peer URL %s: %s", peerURL, err)
 		}
 
-		// find the first IPv4 address for our supplied peer URL (this helps
-		// with deduplication of peer addresses, since peer address books
-		// usually just contain the IP addresses of other peers)
-		peerIP, err := lookupFirstIPv4Addr(u.Hostname())
+		// find the IP address of our supplied peer URL (this helps with
+		// deduplication of peer addresses, since peer address books usually
+		// just contain the IP addresses of other peers)
+		peerIP, err := lookupPeerIP(u.Hostname())
 		if err != nil {
 			return nil, fmt.Errorf("failed to resolve IP address for endpoint %s: %s", peerURL, err)
 		}
 
-		peerAddr := fmt.Sprintf("http://%s:26657", peerIP)
+		peerAddr := peerHTTPAddr(peerIP)
 		client := newHttpRpcClient(peerAddr)
 		suppliedPeers[peerAddr] = &peerInfo{
 			Addr:      peerAddr,
@@ -76,18 +105,51 @@ func waitForNetworkPeers(
 		pc := *p
 		peers[a] = &pc
 	}
+	peers, err := pollNetworkPeers(peers, minDiscoveredPeers, minPeerConnectivity, timeout, pollInterval, cancelc, logger)
+	defer func() {
+		for _, p := range peers {
+			p.Client.close()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	return filterPeerMap(suppliedPeers, peers, selectionMethod, maxReturnedPeers, seed, filter, logger)
+}
 
+// pollNetworkPeers queries the given peers every pollInterval, crawling the
+// network, until at least minDiscoveredPeers peers are known, each connected to
+// at least minPeerConnectivity peers (or until the timeout expires, or a
+// cancel signal is received). It returns the peers known at that point, also
+// on failure.
+func pollNetworkPeers(
+	peers map[string]*peerInfo,
+	minDiscoveredPeers int,
+	minPeerConnectivity int,
+	timeout time.Duration,
+	pollInterval time.Duration,
+	cancelc chan struct{},
+	logger logging.Logger,
+) (map[string]*peerInfo, error) {
+	startTime := time.Now()
+	var lastProgress time.Time
 	for {
 		remainingTimeout := timeout - time.Since(startTime)
 		if remainingTimeout < 0 {
-			return nil, fmt.Errorf("timed out waiting for Tendermint peer crawl to complete")
+			return peers, fmt.Errorf("timed out waiting for Tendermint peer crawl to complete: %s", describePeerWait(peers, minDiscoveredPeers, minPeerConnectivity))
 		}
 		newPeers, err := getNetworkPeers(peers, remainingTimeout, cancelc, logger)
 		if err != nil {
-			return nil, err
+			select {
+			case <-cancelc:
+				return peers, err
+			default:
+				return peers, fmt.Errorf("%w: %s", err, describePeerWait(peers, minDiscoveredPeers, minPeerConnectivity))
+			}
 		}
-		// we only care if we've discovered more peers than in the previous attempt
-		if len(newPeers) > len(peers) {
+		// we only care if we've discovered at least as many peers as in the
+		// previous attempt (whose peers' query results have been reset)
+		if len(newPeers) >= len(peers) {
 			peers = newPeers
 		}
 		peerCount := len(peers)
@@ -95,15 +157,28 @@ func waitForNetworkPeers(
 		if peerCount >= minDiscoveredPeers && peerConnectivity >= minPeerConnectivity {
 			logger.Info("All required peers connected", "count", peerCount, "minConnectivity", minPeerConnectivity)
 			// we're done here
-			return filterPeerMap(suppliedPeers, peers, selectionMethod, maxReturnedPeers, logger)
-		} else {
-			logger.Debug(
-				"Peers discovered so far",
+			return peers, nil
+		}
+		remainingTimeout = timeout - time.Since(startTime)
+		if time.Since(lastProgress) >= peerWaitProgressInterval {
+			logger.Info(
+				"Still waiting for peers to connect",
 				"count", peerCount,
 				"minConnectivity", peerConnectivity,
-				"remainingTimeout", timeout-time.Since(startTime),
+				"connectivity", getPeerConnectivity(peers),
+				"unmet", describePeerWait(peers, minDiscoveredPeers, minPeerConnectivity),
+				"remainingTimeout", remainingTimeout,
 			)
-			time.Sleep(1 * time.Second)
+			lastProgress = time.Now()
+		}
+		wait := pollInterval
+		if remainingTimeout < wait {
+			wait = remainingTimeout
+		}
+		select {
+		case <-cancelc:
+			return peers, fmt.Errorf("cancel signal received")
+		case <-time.After(wait):
 		}
 	}
 }
@@ -133,14 +208,19 @@ func getNetworkPeers(
 				return
 			}
 			peerAddrs := make([]string, 0)
-			for _, peerInfo := range netInfo.Peers {
-				peerAddrs = append(peerAddrs, fmt.Sprintf("http://%s:26657", peerInfo.RemoteIP))
+			peerNodeInfos := make(map[string]*DefaultNodeInfo)
+			for i, peerInfo := range netInfo.Peers {
+				peerAddr := peerHTTPAddr(peerInfo.RemoteIP)
+				peerAddrs = append(peerAddrs, peerAddr)
+				peerNodeInfos[peerAddr] = &netInfo.Peers[i].NodeInfo
 			}
 			peerInfoc <- &peerInfo{
 				Addr:                peer_.Addr,
 				Client:              peer_.Client,
 				PeerAddrs:           peerAddrs,
 				SuccessfullyQueried: true,
+				NodeInfo:            peer_.NodeInfo,
+				PeerNodeInfos:       peerNodeInfos,
 			}
 		}(peer)
 	}
@@ -165,9 +245,6 @@ func getNetworkPeers(
 		}
 		if receivedNetInfoResults >= expectedNetInfoResults {
 			return resolvePeerMap(result), nil
-		} else {
-			// wait a little before polling  again
-			time.Sleep(1 * time.Second)
 		}
 	}
 }
@@ -178,20 +255,25 @@ func resolvePeerMap(peers map[string]*peerInfo) map[string]*peerInfo {
 		result[addr] = peer
 
 		for _, peerAddr := range peer.PeerAddrs {
-			client := newHttpRpcClient(peerAddr)
-			if _, exists := result[peerAddr]; !exists {
+			if existing, exists := result[peerAddr]; !exists {
 				result[peerAddr] = &peerInfo{
 					Addr:      peerAddr,
-					Client:    client,
+					Client:    newHttpRpcClient(peerAddr),
 					PeerAddrs: make([]string, 0),
+					NodeInfo:  peer.PeerNodeInfos[peerAddr],
 				}
+			} else if existing.NodeInfo == nil {
+				existing.NodeInfo = peer.PeerNodeInfos[peerAddr]
 			}
 		}
 	}
 	return result
 }
 
-func filterPeerMap(suppliedPeers, newPeers map[string]*peerInfo, selectionMethod string, maxCount int, logger logging.Logger) ([]string, error) {
+// filterPeerMap returns the endpoints of the peers selected by the given
+// selection method (and filter), sampling maxCount of them at random (with
+// the given seed) if there are more than that and endpoints are discovered.
+func filterPeerMap(suppliedPeers, newPeers map[string]*peerInfo, selectionMethod string, maxCount int, seed int64, filter *discoveryFilter, logger logging.Logger) ([]string, error) {
 	logger.Debug(
 		"Filtering peer map",
 		"suppliedPeers", suppliedPeers,
@@ -199,13 +281,33 @@ func filterPeerMap(suppliedPeers, newPeers map[string]*peerInfo, selectionMethod
 		"selectionMethod", selectionMethod,
 		"maxCount", maxCount,
 	)
+	// only discovered peers are filtered, since the supplied ones were chosen
+	// deliberately
+	excluded := make(map[string]bool)
+	if filter != nil && selectionMethod != SelectSuppliedEndpoints {
+		discovered := make([]discoveredPeer, 0)
+		for peerAddr, peer := range newPeers {
+			if _, ok := suppliedPeers[peerAddr]; !ok {
+				discovered = append(discovered, discoveredPeer{httpAddr: peerAddr, info: peer.NodeInfo})
+			}
+		}
+		for i, allowed := range filter.allowed(discovered, logger) {
+			if !allowed {
+				excluded[discovered[i].httpAddr] = true
+			}
+		}
+		logger.Info("Filtered discovered peers", "discovered", len(discovered), "excluded", len(excluded))
+	}
 	result := make([]string, 0)
 	for peerAddr := range newPeers {
+		if excluded[peerAddr] {
+			continue
+		}
 		u, err := url.Parse(peerAddr)
 		if err != nil {
 			return nil, err
 		}
-		addr := fmt.Sprintf("ws://%s:26657/websocket", u.Hostname())
+		addr := fmt.Sprintf("ws://%s%s", net.JoinHostPort(u.Hostname(), defaultRPCPort), defaultWebSocketPath)
 		switch selectionMethod {
 		case SelectSuppliedEndpoints:
 			// only add it to the result if it was in the original list
@@ -221,11 +323,81 @@ func filterPeerMap(suppliedPeers, newPeers map[string]*peerInfo, selectionMethod
 			// otherwise, always add it
 			result = append(result, addr)
 		}
-		if maxCount > 0 && len(result) >= maxCount {
-			break
+	}
+	// the supplied endpoints were chosen deliberately, so aren't sampled
+	if selectionMethod == SelectSuppliedEndpoints {
+		return result, nil
+	}
+	return sampleEndpoints(result, maxCount, seed, logger), nil
+}
+
+// sampleEndpoints returns a uniformly random sample of maxCount of the given
+// endpoints (in order), or all of them if there aren't more than that (or
+// maxCount is 0). The same seed always yields the same sample of the same
+// endpoints, and 0 means a random seed.
+func sampleEndpoints(endpoints []string, maxCount int, seed int64, logger logging.Logger) []string {
+	// peers are found in no particular order
+	sort.Strings(endpoints)
+	if maxCount <= 0 || len(endpoints) <= maxCount {
+		return endpoints
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	sample := make([]string, 0, maxCount)
+	for _, i := range rnd.Perm(len(endpoints))[:maxCount] {
+		sample = append(sample, endpoints[i])
+	}
+	sort.Strings(sample)
+	logger.Info("Sampled endpoints", "count", len(sample), "of", len(endpoints), "seed", seed, "endpoints", sample)
+	return sample
+}
+
+// getPeerConnectivity returns the number of peers of each of the given peers
+// that have been successfully queried so far, keyed by address.
+func getPeerConnectivity(peers map[string]*peerInfo) map[string]int {
+	result := make(map[string]int)
+	for addr, peer := range peers {
+		if peer.SuccessfullyQueried {
+			result[addr] = len(peer.PeerAddrs)
 		}
 	}
-	return result, nil
+	return result
+}
+
+// describePeerWait describes which of the expected number of peers and their
+// minimum connectivity the given peers fall short of, e.g. "saw 3/4 peers;
+// peer http://10.0.0.1:26657 had connectivity 2/4".
+func describePeerWait(peers map[string]*peerInfo, minDiscoveredPeers, minPeerConnectivity int) string {
+	unmet := make([]string, 0)
+	if len(peers) < minDiscoveredPeers {
+		unmet = append(unmet, fmt.Sprintf("saw %d/%d peers", len(peers), minDiscoveredPeers))
+	}
+	if connectivity := getMinPeerConnectivity(peers); connectivity < minPeerConnectivity {
+		peerConnectivity := getPeerConnectivity(peers)
+		addrs := make([]string, 0, len(peerConnectivity))
+		for addr := range peerConnectivity {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+		described := false
+		for _, addr := range addrs {
+			// as in getMinPeerConnectivity, peers without any peers of their
+			// own are disregarded
+			if count := peerConnectivity[addr]; count > 0 && count < minPeerConnectivity {
+				unmet = append(unmet, fmt.Sprintf("peer %s had connectivity %d/%d", addr, count, minPeerConnectivity))
+				described = true
+			}
+		}
+		if !described {
+			unmet = append(unmet, fmt.Sprintf("connectivity %d/%d", connectivity, minPeerConnectivity))
+		}
+	}
+	if len(unmet) == 0 {
+		return "all expected peers connected"
+	}
+	return strings.Join(unmet, "; ")
 }
 
 func getMinPeerConnectivity(peers map[string]*peerInfo) int {
@@ -251,7 +423,20 @@ func getPeerAddrs(peers map[string]*peerInfo) []string {
 	return results
 }
 
-func lookupFirstIPv4Addr(hostname string) (string, error) {
+// peerHTTPAddr returns the address at which the RPC API of the peer at the
+// given IP address (IPv4 or IPv6) is assumed to be served over HTTP.
+func peerHTTPAddr(ip string) string {
+	return fmt.Sprintf("http://%s", net.JoinHostPort(ip, defaultRPCPort))
+}
+
+// lookupPeerIP returns the IP address of the given host, which is returned as
+// is if it's already an IP address (e.g. an IPv6 literal). Otherwise the first
+// IPv4 address it resolves to is preferred, falling back to the first IPv6
+// address.
+func lookupPeerIP(hostname string) (string, error) {
+	if ip := net.ParseIP(hostname); ip != nil {
+		return ip.String(), nil
+	}
 	ipRecords, err := net.LookupIP(hostname)
 	if err != nil {
 		return "", err
@@ -262,5 +447,8 @@ func lookupFirstIPv4Addr(hostname string) (string, error) {
 			return ipv4.String(), nil
 		}
 	}
-	return "", fmt.Errorf("no IPv4 records for hostname: %s", hostname)
+	if len(ipRecords) > 0 {
+		return ipRecords[0].String(), nil
+	}
+	return "", fmt.Errorf("no IP records for hostname: %s", hostname)
 }
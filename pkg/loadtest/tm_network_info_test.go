@@ -0,0 +1,196 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestPeerMap returns a peer map of the given number of peers, with the
+// first supplied of them as the supplied peers.
+func newTestPeerMap(count, supplied int) (map[string]*peerInfo, map[string]*peerInfo) {
+	peers := make(map[string]*peerInfo, count)
+	suppliedPeers := make(map[string]*peerInfo, supplied)
+	for i := 0; i < count; i++ {
+		addr := fmt.Sprintf("http://10.0.%d.%d:26657", i/256, i%256)
+		peers[addr] = &peerInfo{Addr: addr}
+		if i < supplied {
+			suppliedPeers[addr] = peers[addr]
+		}
+	}
+	return suppliedPeers, peers
+}
+
+func TestFilterPeerMapSamplesEndpoints(t *testing.T) {
+	suppliedPeers, peers := newTestPeerMap(150, 3)
+	logger := logging.NewNoopLogger()
+
+	endpoints, err := filterPeerMap(suppliedPeers, peers, SelectAnyEndpoints, 10, 42, nil, logger)
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 10)
+	// the sample must be reproducible with the same seed
+	for i := 0; i < 5; i++ {
+		again, err := filterPeerMap(suppliedPeers, peers, SelectAnyEndpoints, 10, 42, nil, logger)
+		require.NoError(t, err)
+		assert.Equal(t, endpoints, again)
+	}
+	other, err := filterPeerMap(suppliedPeers, peers, SelectAnyEndpoints, 10, 43, nil, logger)
+	require.NoError(t, err)
+	assert.NotEqual(t, endpoints, other)
+
+	endpoints, err = filterPeerMap(suppliedPeers, peers, SelectDiscoveredEndpoints, 10, 0, nil, logger)
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 10)
+	for _, endpoint := range endpoints {
+		assert.NotContains(t, []string{"ws://10.0.0.0:26657/websocket", "ws://10.0.0.1:26657/websocket", "ws://10.0.0.2:26657/websocket"}, endpoint)
+	}
+
+	// fewer endpoints than the maximum are all used
+	endpoints, err = filterPeerMap(suppliedPeers, peers, SelectAnyEndpoints, 200, 42, nil, logger)
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 150)
+}
+
+func TestFilterPeerMapSuppliedEndpointsNotSampled(t *testing.T) {
+	suppliedPeers, peers := newTestPeerMap(150, 3)
+	endpoints, err := filterPeerMap(suppliedPeers, peers, SelectSuppliedEndpoints, 2, 42, nil, logging.NewNoopLogger())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"ws://10.0.0.0:26657/websocket",
+		"ws://10.0.0.1:26657/websocket",
+		"ws://10.0.0.2:26657/websocket",
+	}, endpoints)
+}
+
+func TestDiscoverIPv6Peers(t *testing.T) {
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, _ := json.Marshal(RPCResponse{JSONRPC: "2.0", ID: -1, Result: json.RawMessage(`{"n_peers":"2","peers":[` +
+			`{"node_info":{"moniker":"validator-1"},"remote_ip":"2001:db8::1"},` +
+			`{"node_info":{"moniker":"validator-2"},"remote_ip":"10.0.0.2"}]}`)})
+		_, _ = w.Write(res)
+	}))
+	t.Cleanup(svr.Close)
+	suppliedPeers := map[string]*peerInfo{svr.URL: {Addr: svr.URL, Client: newHttpRpcClient(svr.URL)}}
+	logger := logging.NewNoopLogger()
+
+	peers, err := getNetworkPeers(suppliedPeers, 5*time.Second, make(chan struct{}), logger)
+	require.NoError(t, err)
+	require.Contains(t, peers, "http://[2001:db8::1]:26657")
+	assert.Equal(t, "validator-1", peers["http://[2001:db8::1]:26657"].NodeInfo.Moniker)
+
+	endpoints, err := filterPeerMap(suppliedPeers, peers, SelectDiscoveredEndpoints, 0, 0, nil, logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ws://10.0.0.2:26657/websocket", "ws://[2001:db8::1]:26657/websocket"}, endpoints)
+}
+
+func TestLookupPeerIP(t *testing.T) {
+	testCases := []struct {
+		host     string
+		expected string
+	}{
+		{"10.0.0.1", "10.0.0.1"},
+		{"::1", "::1"},
+		// IPv6 literals are normalized, to match the addresses peers report
+		{"2001:0db8:0000::0001", "2001:db8::1"},
+	}
+	for _, tc := range testCases {
+		ip, err := lookupPeerIP(tc.host)
+		require.NoError(t, err, tc.host)
+		assert.Equal(t, tc.expected, ip, tc.host)
+	}
+	assert.Equal(t, "http://[2001:db8::1]:26657", peerHTTPAddr("2001:db8::1"))
+	assert.Equal(t, "[2001:db8::1]:26657", endpointKey("ws://[2001:0db8::1]:26657/websocket"))
+}
+
+// newNetInfoServer returns a server whose net_info RPC API reports the peers
+// returned by the given function, which is called with the number of the
+// request (from 1).
+func newNetInfoServer(t *testing.T, peerIPs func(request int) []string) *httptest.Server {
+	var mtx sync.Mutex
+	requests := 0
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		requests++
+		ips := peerIPs(requests)
+		mtx.Unlock()
+		peers := make([]string, len(ips))
+		for i, ip := range ips {
+			peers[i] = fmt.Sprintf(`{"node_info":{"moniker":"node-%d"},"remote_ip":"%s"}`, i, ip)
+		}
+		res, _ := json.Marshal(RPCResponse{JSONRPC: "2.0", ID: -1, Result: json.RawMessage(
+			fmt.Sprintf(`{"n_peers":"%d","peers":[%s]}`, len(peers), strings.Join(peers, ",")),
+		)})
+		_, _ = w.Write(res)
+	}))
+	t.Cleanup(svr.Close)
+	return svr
+}
+
+func TestPollNetworkPeersImproving(t *testing.T) {
+	// the network gains a peer with every poll (the reported peers themselves
+	// can't be queried)
+	svr := newNetInfoServer(t, func(request int) []string {
+		ips := []string{"127.0.0.2", "127.0.0.3", "127.0.0.4"}
+		if request < len(ips) {
+			return ips[:request]
+		}
+		return ips
+	})
+	peers := map[string]*peerInfo{svr.URL: {Addr: svr.URL, Client: newHttpRpcClient(svr.URL)}}
+	logger := &recordingLogger{}
+
+	peers, err := pollNetworkPeers(peers, 4, 3, 10*time.Second, 50*time.Millisecond, make(chan struct{}), logger)
+	require.NoError(t, err)
+	assert.Len(t, peers, 4)
+	assert.Equal(t, map[string]int{svr.URL: 3}, getPeerConnectivity(peers))
+	// the first unsuccessful poll is summarized right away
+	assert.Contains(t, logger.infos, "Still waiting for peers to connect")
+	assert.Contains(t, logger.infos, "All required peers connected")
+}
+
+func TestPollNetworkPeersTimeout(t *testing.T) {
+	svr := newNetInfoServer(t, func(int) []string {
+		return []string{"127.0.0.2", "127.0.0.3"}
+	})
+	peers := map[string]*peerInfo{svr.URL: {Addr: svr.URL, Client: newHttpRpcClient(svr.URL)}}
+	logger := &recordingLogger{}
+
+	startTime := time.Now()
+	peers, err := pollNetworkPeers(peers, 4, 4, 500*time.Millisecond, 50*time.Millisecond, make(chan struct{}), logger)
+	require.Error(t, err)
+	assert.Less(t, time.Since(startTime), 2*time.Second)
+	assert.Len(t, peers, 3)
+	assert.Equal(t, "timed out waiting for Tendermint peer crawl to complete: saw 3/4 peers; peer "+svr.URL+" had connectivity 2/4", err.Error())
+	assert.Contains(t, logger.infos, "Still waiting for peers to connect")
+	assert.NotContains(t, logger.infos, "All required peers connected")
+}
+
+func TestPollNetworkPeersCancelled(t *testing.T) {
+	svr := newNetInfoServer(t, func(int) []string { return nil })
+	peers := map[string]*peerInfo{svr.URL: {Addr: svr.URL, Client: newHttpRpcClient(svr.URL)}}
+	cancelc := make(chan struct{})
+	time.AfterFunc(200*time.Millisecond, func() { close(cancelc) })
+
+	_, err := pollNetworkPeers(peers, 2, 0, 10*time.Second, 50*time.Millisecond, cancelc, logging.NewNoopLogger())
+	require.EqualError(t, err, "cancel signal received")
+}
+
+func TestDescribePeerWait(t *testing.T) {
+	peers := map[string]*peerInfo{
+		"http://10.0.0.1:26657": {SuccessfullyQueried: true, PeerAddrs: []string{"a", "b"}},
+		"http://10.0.0.2:26657": {SuccessfullyQueried: true, PeerAddrs: []string{"a", "b", "c", "d"}},
+		"http://10.0.0.3:26657": {},
+	}
+	assert.Equal(t, "saw 3/4 peers; peer http://10.0.0.1:26657 had connectivity 2/4", describePeerWait(peers, 4, 4))
+	assert.Equal(t, "saw 3/4 peers", describePeerWait(peers, 4, 2))
+	assert.Equal(t, "all expected peers connected", describePeerWait(peers, 3, 2))
+}
@@ -1,11 +1,11 @@
 package loadtest
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,29 +17,65 @@ const (
 	// see https://github.com/tendermint/tendermint/blob/v0.32.x/rpc/lib/server/handlers.go
 	connPingPeriod = (30 * 9 / 10) * time.Second
 
-	jsonRPCID = -1
-
 	defaultProgressCallbackInterval = 5 * time.Second
+
+	// How frequently to check whether all in-flight responses have been
+	// received while draining.
+	drainPollInterval = 50 * time.Millisecond
+
+	// The maximum number of in-flight requests for which to track send times
+	// when measuring broadcast latencies. Bounds memory usage if responses go
+	// missing.
+	maxTrackedRequests = 100000
+
+	// The shortest period at which transactions can be sent in batches.
+	minSendPeriod = time.Millisecond
 )
 
 // Transactor represents a single wire-level connection to a Tendermint RPC
 // endpoint, and this is responsible for sending transactions to that endpoint.
 type Transactor struct {
 	remoteAddr string  // The full URL of the remote WebSockets endpoint.
+	name       string  // The endpoint's alias, if it has one.
 	config     *Config // The configuration for the load test.
 
 	client            Client
+	prioritizedClient PrioritizedClient // Only set if the client supports transaction priorities.
 	logger            logging.Logger
+	errLog            *errorLog // Rate limits the logging of errors that can recur with every transaction.
+	tracing           bool      // Are the details of (a sample of) the transactions logged at the trace level?
 	conn              *websocket.Conn
-	broadcastTxMethod string
+	connected         atomic.Bool   // Is the connection still open?
+	connErrored       atomic.Bool   // Did the connection fail, rather than being closed normally?
+	sendingStopped    atomic.Bool   // Has sending stopped because the load test is being terminated?
+	recvDone          chan struct{} // Closed once the receive loop for the current connection has stopped. Only accessed from the send loop.
+	broadcastMethod   BroadcastMethod
 	wg                sync.WaitGroup
+	nextRequestID     int              // Only accessed from the send loop.
+	pregenerated      []pregeneratedTx // The pre-generated transactions still to be sent, if any. Only accessed from the send loop once started.
+
+	rateMtx   sync.Mutex
+	rate      float64         // The number of transactions to send per send period (may differ from the configured rate if the endpoint is rate limited, or the coordinator changes it).
+	scheduler *batchScheduler // Paces the transactions sent each send period according to the rate.
 
 	// Rudimentary statistics
-	statsMtx  sync.RWMutex
-	startTime time.Time // When did the transaction sending start?
-	txCount   int       // How many transactions have been sent.
-	txBytes   int64     // How many transaction bytes have been sent, cumulatively.
-	txRate    float64   // The number of transactions sent, per second.
+	statsMtx    sync.RWMutex
+	startTime   time.Time // When did the transaction sending start?
+	sendEndTime time.Time // When did the transaction sending stop (excluding any drain period)?
+	txCount     int       // How many transactions have been sent.
+	txBytes     int64     // How many transaction bytes have been sent, cumulatively.
+	txRate      float64   // The number of transactions sent, per second.
+	txResponses int       // How many responses to our transactions have been received.
+	txFailures  int       // How many of those responses were errors.
+
+	pregeneratedCount int // How many transactions were pre-generated before sending started.
+
+	broadcastLatencies *latencySketch    // Broadcast round-trip latencies (or write-completion latencies for broadcast_tx_async).
+	latencySum         time.Duration     // The sum of all broadcast latencies, for computing means.
+	pendingRequests    map[int]time.Time // Send times of in-flight requests, keyed by request ID (not used for broadcast_tx_async).
+	tracedTxs          map[int]tracedTx  // Details of the in-flight transactions sampled for logging at the trace level, keyed by request ID.
+	metricsSink        MetricsSink       // Only set if metrics are to be forwarded to a monitoring system.
+	latencySamples     *latencyReservoir // Only set if raw latency samples are to be exported.
 
 	progressCallbackMtx      sync.RWMutex
 	progressCallbackID       int                                      // A unique identifier for this transactor when calling the progress callback.
@@ -49,11 +85,28 @@ type Transactor struct {
 	stopMtx sync.RWMutex
 	stop    bool
 	stopErr error // Did an error occur that triggered the stop?
+
+	commitTracker *commitTracker     // Only set if commit latency tracking is enabled.
+	blacklist     *endpointBlacklist // Only set if endpoints are to be blacklisted after repeated failures.
+	blacklisted   atomic.Bool        // Is sending stopped because the endpoint has been blacklisted?
+
+	pauseMtx      sync.RWMutex
+	mempoolPaused bool // Is sending paused because the endpoint's mempool is too full?
+
+	pauseClk pauseClock // Tracks whether (and for how long) sending has been paused on request.
 }
 
 // NewTransactor initiates a WebSockets connection to the given host address.
 // Must be a valid WebSockets URL, e.g. "ws://host:port/websocket"
 func NewTransactor(remoteAddr string, config *Config) (*Transactor, error) {
+	return newNamedTransactor(remoteAddr, "", config, nil)
+}
+
+// newNamedTransactor initiates a WebSockets connection to the given endpoint,
+// which is labelled with the given alias (if any) instead of its address in
+// stats, metrics and logs, logging via the given factory (or the one given to
+// SetLogger if that's nil).
+func newNamedTransactor(remoteAddr, name string, config *Config, loggerFactory LoggerFactory) (*Transactor, error) {
 	u, err := url.Parse(remoteAddr)
 	if err != nil {
 		return nil, err
@@ -65,6 +118,10 @@ func NewTransactor(remoteAddr string, config *Config) (*Transactor, error) {
 	if !exists {
 		return nil, fmt.Errorf("unrecognized client factory: %s", config.ClientFactory)
 	}
+	broadcastMethod, err := lookupBroadcastMethod(config.BroadcastTxMethod)
+	if err != nil {
+		return nil, err
+	}
 	client, err := clientFactory.NewClient(*config)
 	if err != nil {
 		return nil, err
@@ -76,17 +133,33 @@ func NewTransactor(remoteAddr string, config *Config) (*Transactor, error) {
 	if resp.StatusCode >= 400 {
 		return nil, fmt.Errorf("failed to connect to remote WebSockets endpoint %s: %s (status code %d)", remoteAddr, resp.Status, resp.StatusCode)
 	}
-	logger := logging.NewLogrusLogger(fmt.Sprintf("transactor[%s]", u.String()))
+	label := u.String()
+	if len(name) > 0 {
+		label = name
+	}
+	logger := newLogger(loggerFactory, fmt.Sprintf("transactor[%s]", label))
 	logger.Info("Connected to remote Tendermint WebSockets RPC", remoteAddr)
-	return &Transactor{
+	prioritizedClient, _ := client.(PrioritizedClient)
+	t := &Transactor{
 		remoteAddr:               u.String(),
+		name:                     name,
 		config:                   config,
 		client:                   client,
+		prioritizedClient:        prioritizedClient,
 		logger:                   logger,
+		errLog:                   newErrorLog(logger, config.errorLogInterval(), errorLogSummaryInterval),
 		conn:                     conn,
-		broadcastTxMethod:        "broadcast_tx_" + config.BroadcastTxMethod,
+		broadcastMethod:          broadcastMethod,
+		rate:                     config.Rate,
+		scheduler:                newBatchScheduler(config.Rate),
 		progressCallbackInterval: defaultProgressCallbackInterval,
-	}, nil
+		broadcastLatencies:       newLatencySketch(),
+		pendingRequests:          make(map[int]time.Time),
+		tracing:                  traceEnabled(logger),
+		tracedTxs:                make(map[int]tracedTx),
+	}
+	t.connected.Store(true)
+	return t, nil
 }
 
 func (t *Transactor) SetProgressCallback(id int, interval time.Duration, callback func(int, int, int64)) {
@@ -97,13 +170,48 @@ func (t *Transactor) SetProgressCallback(id int, interval time.Duration, callbac
 	t.progressCallbackMtx.Unlock()
 }
 
+// setRate overrides the configured number of transactions to send per send
+// period. May be called while the transactor is sending, in which case the new
+// rate applies from the next send period.
+func (t *Transactor) setRate(rate float64) {
+	t.rateMtx.Lock()
+	defer t.rateMtx.Unlock()
+	t.rate = rate
+	t.scheduler.setRate(rate)
+}
+
+// label returns the endpoint's alias, if it has one, or otherwise its address,
+// with which to label its stats and metrics.
+func (t *Transactor) label() string {
+	if len(t.name) > 0 {
+		return t.name
+	}
+	return t.remoteAddr
+}
+
+// getRate returns the number of transactions to send per send period.
+func (t *Transactor) getRate() float64 {
+	t.rateMtx.Lock()
+	defer t.rateMtx.Unlock()
+	return t.rate
+}
+
 // Start kicks off the transactor's operations in separate goroutines (one for
 // reading from the WebSockets endpoint, and one for writing to it).
 func (t *Transactor) Start() {
 	t.logger.Debug("Starting transactor")
 	t.wg.Add(2)
-	go t.receiveLoop() //先接收
-	go t.sendLoop()    //再发送
+	t.recvDone = make(chan struct{})
+	go t.receiveLoop(t.conn, t.recvDone) //先接收
+	go t.sendLoop()                      //再发送
+}
+
+// stopSending stops the transactor from sending any more transactions, while
+// still receiving the responses to those in flight until it is cancelled.
+func (t *Transactor) stopSending() {
+	if !t.sendingStopped.Swap(true) {
+		t.trackSendEndTime()
+	}
 }
 
 // Cancel will indicate to the transactor that it must stop, but does not wait
@@ -144,68 +252,208 @@ func (t *Transactor) GetTxRate() float64 {
 	return t.txRate
 }
 
-func (t *Transactor) receiveLoop() { //接收从节点返回的数据
+// GetTxResponseCount returns the total number of responses received thus far
+// for transactions sent by this transactor.
+func (t *Transactor) GetTxResponseCount() int {
+	t.statsMtx.RLock()
+	defer t.statsMtx.RUnlock()
+	return t.txResponses
+}
+
+// getBroadcastLatencies returns a copy of the sketch of the broadcast
+// latencies of the transactions sent thus far by this transactor.
+func (t *Transactor) getBroadcastLatencies() *latencySketch {
+	t.statsMtx.RLock()
+	defer t.statsMtx.RUnlock()
+	return t.broadcastLatencies.Copy()
+}
+
+// GetBroadcastLatencyStats returns the distribution of the broadcast latencies
+// of the transactions sent thus far by this transactor.
+func (t *Transactor) GetBroadcastLatencyStats() *LatencyStats {
+	return t.getBroadcastLatencies().Stats()
+}
+
+// GetTxFailureCount returns the total number of error responses received
+// thus far for transactions sent by this transactor.
+func (t *Transactor) GetTxFailureCount() int {
+	t.statsMtx.RLock()
+	defer t.statsMtx.RUnlock()
+	return t.txFailures
+}
+
+// getLatencyTotals returns the number of broadcast latency samples taken thus
+// far, and their sum.
+func (t *Transactor) getLatencyTotals() (uint64, time.Duration) {
+	t.statsMtx.RLock()
+	defer t.statsMtx.RUnlock()
+	return t.broadcastLatencies.Count, t.latencySum
+}
+
+// GetSendEndTime returns the time at which this transactor stopped sending
+// transactions, or the zero time if it is still sending. Any time spent
+// draining in-flight responses comes after this point.
+func (t *Transactor) GetSendEndTime() time.Time {
+	t.statsMtx.RLock()
+	defer t.statsMtx.RUnlock()
+	return t.sendEndTime
+}
+
+func (t *Transactor) receiveLoop(conn *websocket.Conn, done chan struct{}) { //接收从节点返回的数据
 	defer t.wg.Done()
+	defer close(done)
+	defer t.connected.Store(false)
 	for { //循环监听
-		// right now we don't care about what we read back from the RPC endpoint
-		_, _, err := t.conn.ReadMessage() //读取数据
+		_, data, err := conn.ReadMessage() //读取数据
 		if err != nil {
-			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-				t.logger.Error("Failed to read response on connection", "err", err)
-				return
+			// the send loop may have closed the connection because it failed
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) && t.connected.Swap(false) {
+				t.errLog.Error("read", "Failed to read response on connection", "err", err)
+				t.connErrored.Store(true)
+				if t.blacklist != nil {
+					t.blacklist.failed(t.remoteAddr, err)
+				}
 			}
+			return
 		}
+		t.handleResponse(data)
 		if t.mustStop() { //负载被取消时退出
 			return
 		}
 	}
 }
 
-func (t *Transactor) sendLoop() {
-	defer t.wg.Done()
-	t.conn.SetPingHandler(func(message string) error { //ping处理，收到ping发出pong，检测连接有效
-		err := t.conn.WriteControl(websocket.PongMessage, []byte(message), time.Now().Add(connSendTimeout))
+func (t *Transactor) handleResponse(data []byte) {
+	var res RPCResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.logger.Debug("Failed to parse response from remote endpoint", "err", err)
+		return
+	}
+	// we only care about responses to our own requests
+	if res.ID < 1 {
+		return
+	}
+	t.traceResponse(res, time.Now())
+	rejectErr := t.broadcastMethod.InterpretResponse(res)
+	t.statsMtx.Lock()
+	t.txResponses++
+	if rejectErr != nil {
+		t.txFailures++
+		if t.metricsSink != nil {
+			t.metricsSink.TxFailed(t.label())
+		}
+	}
+	if sentAt, ok := t.pendingRequests[res.ID]; ok {
+		t.trackBroadcastLatency(sentAt, time.Since(sentAt))
+		delete(t.pendingRequests, res.ID)
+	}
+	t.statsMtx.Unlock()
+	if rejectErr != nil {
+		category := "rejected"
+		if res.Error != nil {
+			category = fmt.Sprintf("rejected[%d]", res.Error.Code)
+		}
+		t.errLog.Error(category, "Transaction rejected by endpoint", "id", res.ID, "err", rejectErr)
+	}
+	if t.blacklist != nil {
+		if rejectErr != nil {
+			t.blacklist.failed(t.remoteAddr, rejectErr)
+		} else {
+			t.blacklist.succeeded(t.remoteAddr)
+		}
+	}
+}
+
+func (t *Transactor) setPingHandler() {
+	conn := t.conn
+	conn.SetPingHandler(func(message string) error { //ping处理，收到ping发出pong，检测连接有效
+		err := conn.WriteControl(websocket.PongMessage, []byte(message), time.Now().Add(connSendTimeout))
 		//[]byte(message)将消息变量转换为字节片。WebSocket消息是以字节片的形式发送的，因此在发送消息之前，需要将消息从字符串转换为字节片。
 		//写入操作的截止日期。它使用时间包来获取当前时间，然后将connSendTimeout持续时间添加到其中。这确保了如果写操作需要太长时间才能完成，则会超时。
 		if err == websocket.ErrCloseSent {
 			return nil
 		}
 		return err
-	}) //时间初始化，定期执行及更新
-	pingTicker := time.NewTicker(connPingPeriod)                                   //定时发送ping，connPingPeriod表示每隔多久发送一次ping
-	timeLimitTicker := time.NewTicker(time.Duration(t.config.Time) * time.Second)  //限制时间，t.config.Time表示设定的时间，将其转换为time.Duration类型并乘以time.Second表示秒。
-	sendTicker := time.NewTicker(time.Duration(t.config.SendPeriod) * time.Second) //发送数据，t.config.SendPeriod表示设定的发送数据的时间间隔，将其转换为time.Duration类型并乘以time.Second表示秒
-	progressTicker := time.NewTicker(t.getProgressCallbackInterval())              //创建了一个Ticker对象progressTicker，用于获取进度回调。t.getProgressCallbackInterval()是一个自定义的方法，用于获取进度回调的时间间隔
-	defer func() {                                                                 //停止Ticker，释放资源
+	})
+}
+
+func (t *Transactor) sendLoop() {
+	defer t.wg.Done()
+	defer t.errLog.Summarize()
+	t.setPingHandler()                                                //时间初始化，定期执行及更新
+	pingTicker := time.NewTicker(connPingPeriod)                      //定时发送ping，connPingPeriod表示每隔多久发送一次ping
+	sendTicker := time.NewTicker(time.Duration(t.config.SendPeriod))  //发送数据，t.config.SendPeriod表示设定的发送数据的时间间隔（Duration类型），直接转换为time.Duration即可
+	progressTicker := time.NewTicker(t.getProgressCallbackInterval()) //创建了一个Ticker对象progressTicker，用于获取进度回调。t.getProgressCallbackInterval()是一个自定义的方法，用于获取进度回调的时间间隔
+	defer func() {                                                    //停止Ticker，释放资源
 		pingTicker.Stop()
-		timeLimitTicker.Stop()
 		sendTicker.Stop()
 		progressTicker.Stop()
 	}()
+	// count-bounded load tests without a cap have no time limit
+	var timeLimitC <-chan time.Time
+	if limit := t.config.timeLimit(); limit > 0 {
+		timeLimitTimer := time.NewTimer(limit) //限制时间
+		defer timeLimitTimer.Stop()
+		timeLimitC = timeLimitTimer.C
+	}
 
 	for {
 		if t.config.Count > 0 && t.GetTxCount() >= t.config.Count {
 			t.logger.Info("Maximum transaction limit reached", "count", t.GetTxCount())
+			t.drain()
 			t.setStop(nil)
+			t.close()
+			return
 		}
 		select {
 		case <-sendTicker.C: //发送事务通道
+			if t.sendingStopped.Load() {
+				break
+			}
+			if t.pauseClk.isPaused() {
+				t.logger.Debug("Skipping batch of transactions while paused")
+				break
+			}
+			if t.isMempoolPaused() {
+				t.logger.Debug("Skipping batch of transactions while endpoint's mempool is full")
+				break
+			}
+			if t.blacklisted.Load() {
+				t.logger.Debug("Skipping batch of transactions while endpoint is blacklisted")
+				break
+			}
+			if t.blacklist != nil && !t.isConnected() {
+				if err := t.reconnect(); err != nil {
+					t.errLog.Error("reconnect", "Failed to reconnect to endpoint", "err", err)
+					t.blacklist.failed(t.remoteAddr, err)
+					break
+				}
+			}
 			if err := t.sendTransactions(); err != nil {
-				t.logger.Error("Failed to send transactions", "err", err)
-				t.setStop(err)
+				t.errLog.Error("send", "Failed to send transactions", "err", err)
+				t.connectionFailed(err)
 			}
 
 		case <-progressTicker.C: //报告测试进度通道
 			t.reportProgress()
+			t.errLog.MaybeSummarize()
 
 		case <-pingTicker.C: //ping通道
+			if t.blacklist != nil && !t.isConnected() {
+				break
+			}
 			if err := t.sendPing(); err != nil {
-				t.logger.Error("Failed to write ping message", "err", err)
-				t.setStop(err)
+				t.errLog.Error("ping", "Failed to write ping message", "err", err)
+				t.connectionFailed(err)
 			}
 
-		case <-timeLimitTicker.C: //到达测试时间通道
-			t.logger.Info("Time limit reached for load testing")
+		case <-timeLimitC: //到达测试时间通道
+			if t.config.countBounded() {
+				t.logger.Warn("Maximum run time reached before sending all transactions", "count", t.GetTxCount(), "maxRunTime", t.config.MaxRunTime)
+			} else {
+				t.logger.Info("Time limit reached for load testing")
+			}
+			t.drain()
 			t.setStop(nil)
 		}
 		if t.mustStop() { //负载被取消时退出
@@ -215,24 +463,146 @@ func (t *Transactor) sendLoop() {
 	}
 }
 
-var sendnum = 0
+// drain stops any further sending and waits, for up to the configured drain
+// timeout, for responses to all in-flight transactions to be received.
+func (t *Transactor) drain() {
+	if !t.sendingStopped.Load() {
+		t.trackSendEndTime()
+	}
+	if t.config.DrainTimeout <= 0 {
+		return
+	}
+	inFlight := t.GetTxCount() - t.GetTxResponseCount()
+	if inFlight < 1 {
+		return
+	}
+	t.logger.Info("Draining in-flight responses", "inFlight", inFlight)
+	timeout := time.After(time.Duration(t.config.DrainTimeout))
+	pollTicker := time.NewTicker(drainPollInterval)
+	defer pollTicker.Stop()
+	for t.GetTxCount() > t.GetTxResponseCount() {
+		select {
+		case <-pollTicker.C:
+			if t.mustStop() {
+				return
+			}
+			if t.blacklist != nil && !t.isConnected() {
+				t.logger.Info("Connection lost while draining in-flight responses", "inFlight", t.GetTxCount()-t.GetTxResponseCount())
+				return
+			}
+
+		case <-timeout:
+			t.logger.Info("Timed out while draining in-flight responses", "inFlight", t.GetTxCount()-t.GetTxResponseCount())
+			return
+		}
+	}
+	t.logger.Debug("Drained all in-flight responses")
+}
+
+// writeTx writes the request submitting the given transaction, with the given
+// parameters if they were pre-generated.
+func (t *Transactor) writeTx(tx []byte, params json.RawMessage) error {
+	t.nextRequestID++
+	id := t.nextRequestID
+	var req RPCRequest
+	if params != nil {
+		req = t.broadcastMethod.request(id, params)
+	} else {
+		var err error
+		if req, err = t.broadcastMethod.buildRequest(id, tx); err != nil {
+			return err
+		}
+	}
+	sentAt := time.Now()
+	async := !t.broadcastMethod.AwaitsResponse
+	if !async {
+		// the response may arrive before the write call returns
+		t.trackPendingRequest(id, sentAt)
+	}
+	t.traceTx(id, tx, sentAt)
+	_ = t.conn.SetWriteDeadline(time.Now().Add(connSendTimeout))
+	//将RPCRequest的JSON编码写入作为消息
+	if err := t.conn.WriteJSON(req); err != nil {
+		return err
+	}
+	if async {
+		// we don't wait for CheckTx, so only the write itself counts
+		t.statsMtx.Lock()
+		t.trackBroadcastLatency(sentAt, time.Since(sentAt))
+		t.statsMtx.Unlock()
+	}
+	return nil
+}
+
+// Must be called with the stats mutex held.
+func (t *Transactor) trackBroadcastLatency(sentAt time.Time, latency time.Duration) {
+	t.broadcastLatencies.Add(latency)
+	t.latencySum += latency
+	if t.latencySamples != nil {
+		t.latencySamples.Add(sentAt, latency)
+	}
+	if t.metricsSink != nil {
+		t.metricsSink.BroadcastLatency(t.label(), latency)
+	}
+}
+
+func (t *Transactor) trackPendingRequest(id int, sentAt time.Time) {
+	t.statsMtx.Lock()
+	defer t.statsMtx.Unlock()
+	if len(t.pendingRequests) < maxTrackedRequests {
+		t.pendingRequests[id] = sentAt
+	}
+}
+
+func (t *Transactor) isConnected() bool {
+	return t.connected.Load()
+}
+
+// connectionFailed handles the failure of the connection (e.g. to send
+// transactions) with the given error, which stops the transactor, unless
+// endpoints are blacklisted after repeated failures. In that case the
+// connection is closed instead, and counts as one of the endpoint's failures,
+// and is reopened at the next send period (unless the endpoint has been
+// blacklisted by then). Must only be called from the send loop.
+func (t *Transactor) connectionFailed(err error) {
+	t.connErrored.Store(true)
+	if t.blacklist == nil {
+		t.setStop(err)
+		return
+	}
+	if t.connected.Swap(false) {
+		_ = t.conn.Close()
+		t.blacklist.failed(t.remoteAddr, err)
+	}
+}
 
-func (t *Transactor) writeTx(tx []byte) error {
-	sendnum += 1
-	txBase64 := base64.StdEncoding.EncodeToString(tx)
-	paramsJSON, err := json.Marshal(map[string]interface{}{"tx": txBase64})
+// reconnect reopens the connection to the endpoint once the previous one has
+// failed. Must only be called from the send loop.
+func (t *Transactor) reconnect() error {
+	// the previous connection's receive loop must be done with it
+	<-t.recvDone
+	conn, _, err := websocket.DefaultDialer.Dial(t.remoteAddr, nil)
 	if err != nil {
 		return err
 	}
-	_ = t.conn.SetWriteDeadline(time.Now().Add(connSendTimeout))
-	//fmt.Println("已经发送事务的个数", sendnum)
-	return t.conn.WriteJSON(RPCRequest{ //将RPCRequest的JSON编码写入作为消息
-		JSONRPC: "2.0",
-		ID:      jsonRPCID,
-		Method:  t.broadcastTxMethod,
-		Params:  json.RawMessage(paramsJSON),
-	})
+	t.logger.Info("Reconnected to remote Tendermint WebSockets RPC", "remoteAddr", t.remoteAddr)
+	t.conn = conn
+	t.setPingHandler()
+	t.connected.Store(true)
+	t.recvDone = make(chan struct{})
+	t.wg.Add(1)
+	go t.receiveLoop(conn, t.recvDone)
+	return nil
+}
 
+func (t *Transactor) setBlacklisted(blacklisted bool) {
+	t.blacklisted.Store(blacklisted)
+}
+
+// hasConnectionErrored returns whether this transactor's connection failed
+// at any point.
+func (t *Transactor) hasConnectionErrored() bool {
+	return t.connErrored.Load()
 }
 
 func (t *Transactor) mustStop() bool {
@@ -241,6 +611,29 @@ func (t *Transactor) mustStop() bool {
 	return t.stop
 }
 
+func (t *Transactor) setMempoolPaused(paused bool) {
+	t.pauseMtx.Lock()
+	t.mempoolPaused = paused
+	t.pauseMtx.Unlock()
+}
+
+func (t *Transactor) isMempoolPaused() bool {
+	t.pauseMtx.RLock()
+	defer t.pauseMtx.RUnlock()
+	return t.mempoolPaused
+}
+
+// Pause stops the transactor from sending any further transactions until it's
+// resumed, while keeping its connection open.
+func (t *Transactor) Pause() {
+	t.pauseClk.pause(time.Now())
+}
+
+// Resume resumes sending transactions after a pause.
+func (t *Transactor) Resume() {
+	t.pauseClk.resume(time.Now())
+}
+
 func (t *Transactor) setStop(err error) {
 	t.stopMtx.Lock()
 	t.stop = true
@@ -253,36 +646,59 @@ func (t *Transactor) setStop(err error) {
 func (t *Transactor) sendTransactions() error { // sendTransaction 发送事务
 	// send as many transactions as we can, up to the send rate
 	totalSent := t.GetTxCount()
-	toSend := t.config.Rate
+	if !t.hasStarted() {
+		t.trackStartTime()
+	}
+	t.rateMtx.Lock()
+	toSend := t.scheduler.Next()
+	t.rateMtx.Unlock()
+	if toSend == 0 {
+		// at sub-1 rates, most send periods are empty
+		return nil
+	}
 	if (t.config.Count > 0) && ((totalSent + toSend) > t.config.Count) {
 		toSend = t.config.Count - totalSent
 		t.logger.Debug("Nearing max transaction count", "totalSent", totalSent, "maxTxCount", t.config.Count, "toSend", toSend)
 	}
-	if totalSent == 0 {
-		t.trackStartTime()
-	}
 	var sent int
 	var sentBytes int64
 	defer func() { t.trackSentTxs(sent, sentBytes) }()
 	t.logger.Info("Sending batch of transactions", "toSend", toSend)
 	batchStartTime := time.Now()
 	for ; sent < toSend; sent++ {
-		tx, err := t.client.GenerateTx()
+		tx, priority, params, err := t.nextTx()
 		if err != nil {
 			return err
 		}
-		if err := t.writeTx(tx); err != nil {
+		if err := t.writeTx(tx, params); err != nil {
 			return err
 		}
+		if t.commitTracker != nil {
+			if t.prioritizedClient != nil {
+				t.commitTracker.TrackSentPrioritized(tx, priority, time.Now())
+			} else {
+				t.commitTracker.TrackSent(tx, time.Now())
+			}
+		}
 		sentBytes += int64(len(tx))
 		// if we have to make way for the next batch
-		if time.Since(batchStartTime) >= time.Duration(t.config.SendPeriod)*time.Second {
+		if time.Since(batchStartTime) >= time.Duration(t.config.SendPeriod) {
 			break
 		}
 	}
 	return nil
 }
 
+// generateTx generates a transaction from our client, along with its priority
+// if the client supports priorities.
+func (t *Transactor) generateTx() ([]byte, int64, error) {
+	if t.prioritizedClient != nil {
+		return t.prioritizedClient.GeneratePrioritizedTx()
+	}
+	tx, err := t.client.GenerateTx()
+	return tx, 0, err
+}
+
 func (t *Transactor) trackStartTime() {
 	t.statsMtx.Lock()
 	t.startTime = time.Now()
@@ -290,17 +706,28 @@ func (t *Transactor) trackStartTime() {
 	t.statsMtx.Unlock()
 }
 
-var sentnum = 0
+func (t *Transactor) hasStarted() bool {
+	t.statsMtx.RLock()
+	defer t.statsMtx.RUnlock()
+	return !t.startTime.IsZero()
+}
+
+func (t *Transactor) trackSendEndTime() {
+	t.statsMtx.Lock()
+	t.sendEndTime = time.Now()
+	t.statsMtx.Unlock()
+}
 
 func (t *Transactor) trackSentTxs(count int, byteCount int64) {
 	t.statsMtx.Lock()
 	defer t.statsMtx.Unlock()
 
 	t.txCount += count
-	sentnum += count
-	fmt.Println("<记录发送事务的个数>", sentnum)
+	if t.metricsSink != nil {
+		t.metricsSink.TxsSent(t.label(), count, byteCount)
+	}
 	t.txBytes += byteCount
-	elapsed := time.Since(t.startTime).Seconds()
+	elapsed := t.pauseClk.activeSeconds(t.startTime, time.Now())
 	if elapsed > 0 {
 		t.txRate = float64(t.txCount) / elapsed
 	} else {
@@ -333,6 +760,10 @@ func (t *Transactor) getProgressCallbackInterval() time.Duration {
 }
 
 func (t *Transactor) close() {
+	if t.blacklist != nil && !t.isConnected() {
+		// the connection failed and has already been closed
+		return
+	}
 	// try to cleanly shut down the connection
 	_ = t.conn.SetWriteDeadline(time.Now().Add(connSendTimeout))
 	err := t.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
@@ -1,29 +1,67 @@
 package loadtest
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // TransactorGroup allows us to encapsulate the management of a group of transactors.
 type TransactorGroup struct {
-	transactors []*Transactor
+	transactorsMtx  sync.RWMutex
+	transactors     []*Transactor            // Only ever appended to, since endpoints may be added by re-discovery during the load test.
+	joinedAt        map[string]time.Duration // How far into the load test each endpoint added by re-discovery was added, by address.
+	config          *Config
+	endpointWeights map[string]float64    // The weight of each endpoint, by address, if endpoint weights are configured.
+	mempoolMon      *mempoolMonitor       // Only set if mempool monitoring is enabled.
+	blacklist       *endpointBlacklist    // Only set if endpoints are to be blacklisted after repeated failures.
+	rediscoverer    *endpointRediscoverer // Only set if endpoints are to be rediscovered during the load test.
+	commitTrk       *commitTracker        // Only set if commit latency tracking is enabled.
 
-	statsMtx  sync.RWMutex
-	startTime time.Time//交易开始时间
-	txCounts  map[int]int   // The counts of all of the total transactions per transactor.
-	txBytes   map[int]int64 // The total number of transaction bytes sent per transactor.
+	endpointLatencies []EndpointLatency // The latencies measured before the load test (from the fastest endpoint to the slowest), if endpoints are selected by latency.
+	standby           []string          // The endpoints (from the fastest to the slowest) that only send while standing in for blacklisted preferred endpoints, if endpoints are selected by latency.
+
+	metricsRegistry prometheus.Registerer // Only set if metrics are to be exposed.
+	metrics         *workerMetrics
+	metricsSinks    metricsSinks
+
+	timeseriesInterval time.Duration
+	timeseriesCallback func(timeseriesSample) // Only set if timeseries statistics are to be recorded.
+	timeseriesRec      *timeseriesRecorder
+
+	intervalTxs    *intervalTxCounts // Only set if interval rate statistics are to be computed.
+	latencySamples *latencyReservoir // Only set if raw latency samples are to be exported.
+	intervalRec    *timeseriesRecorder
+
+	progressStatusInterval time.Duration
+	progressStatusCallback func(progressStatus) // Only set if progress is to be reported.
+	progressMon            *progressMonitor
+
+	ratesMtx    sync.Mutex // Serializes changes to the transactors' rates.
+	rateShares  []float64  // Each transactor's share of the overall rate before any endpoint was blacklisted.
+	fixedTxRate float64    // The overall rate (tx/sec) to keep to as endpoints are added, if it has been set.
+
+	statsMtx   sync.RWMutex
+	startTime  time.Time     //交易开始时间
+	txCounts   map[int]int   // The counts of all of the total transactions per transactor.
+	txBytes    map[int]int64 // The total number of transaction bytes sent per transactor.
+	pauseClk   pauseClock    // Tracks whether (and for how long) the load test has been paused on request.
+	cancelled  atomic.Bool   // Whether the load test was cancelled before it completed.
+	terminated atomic.Bool   // Whether the load test was cancelled by terminating it.
 
 	progressCallbackMtx      sync.RWMutex
-	progressCallbackInterval time.Duration//持续时间
-	progressCallback         func(g *TransactorGroup, txCount int, txBytes int64)//
+	progressCallbackInterval time.Duration                                        //持续时间
+	progressCallback         func(g *TransactorGroup, txCount int, txBytes int64) //
 
 	stopProgressReporter    chan struct{} // Close this to stop the progress reporter.
 	progressReporterStopped chan struct{} // Closed when the progress reporter goroutine has completely stopped.
 
-	logger logging.Logger
+	logger    logging.Logger
+	newLogger LoggerFactory // Creates the loggers of our transactors (nil for the one given to SetLogger).
 }
 
 func NewTransactorGroup() *TransactorGroup {
@@ -42,30 +80,385 @@ func (g *TransactorGroup) SetLogger(logger logging.Logger) {
 	g.logger = logger
 }
 
+// SetMetricsRegistry causes the group's metrics to be registered with the
+// given registry. Must be called before AddAll.
+func (g *TransactorGroup) SetMetricsRegistry(reg prometheus.Registerer) {
+	g.metricsRegistry = reg
+}
+
+// AddMetricsSink causes metrics from all transactors to be forwarded to the
+// given sink. Must be called before Start.
+func (g *TransactorGroup) AddMetricsSink(sink MetricsSink) {
+	g.metricsSinks = append(g.metricsSinks, sink)
+}
+
 // Add will instantiate a new Transactor with the given parameters. If
 // instantiation fails it'll automatically shut down and close all other
 // transactors, returning the error.
 func (g *TransactorGroup) Add(remoteAddr string, config *Config) error {
-	t, err := NewTransactor(remoteAddr, config)
+	return g.add(remoteAddr, "", config)
+}
+
+// add adds a transactor for the given endpoint, labelled with the given alias
+// (if any), as Add does.
+func (g *TransactorGroup) add(remoteAddr, name string, config *Config) error {
+	t, err := newNamedTransactor(remoteAddr, name, config, g.newLogger)
 	if err != nil {
 		g.close()
 		return err
 	}
-	id := len(g.transactors)
-	t.SetProgressCallback(id, g.getProgressCallbackInterval()/2, g.trackTransactorProgress)
-	g.transactors = append(g.transactors, t)
+	g.appendTransactor(t)
+	g.config = config
 	g.logger.Debug("Added transactor", "remoteAddr", remoteAddr)
 	return nil
 }
 
+// appendTransactor adds the given transactor to the group, reporting its
+// progress to the group.
+func (g *TransactorGroup) appendTransactor(t *Transactor) {
+	g.transactorsMtx.Lock()
+	defer g.transactorsMtx.Unlock()
+	t.SetProgressCallback(len(g.transactors), g.getProgressCallbackInterval()/2, g.trackTransactorProgress)
+	g.transactors = append(g.transactors, t)
+}
+
+// startTransactor starts the given transactor, added while the load test is
+// underway, and only then adds it to the group, so that waiting for the
+// group's transactors can't race with it starting (or miss it altogether).
+func (g *TransactorGroup) startTransactor(t *Transactor) {
+	g.transactorsMtx.Lock()
+	defer g.transactorsMtx.Unlock()
+	t.SetProgressCallback(len(g.transactors), g.getProgressCallbackInterval()/2, g.trackTransactorProgress)
+	if g.pauseClk.isPaused() {
+		t.Pause()
+	}
+	t.Start()
+	if g.cancelled.Load() {
+		t.Cancel()
+	}
+	g.transactors = append(g.transactors, t)
+}
+
+// getTransactors returns all of the group's transactors thus far.
+//
+// Since the group's transactors are only ever appended to, it's safe to
+// iterate over the result while more transactors are added.
+func (g *TransactorGroup) getTransactors() []*Transactor {
+	g.transactorsMtx.RLock()
+	defer g.transactorsMtx.RUnlock()
+	return g.transactors
+}
+
 func (g *TransactorGroup) AddAll(cfg *Config) error {
-	for _, endpoint := range cfg.Endpoints {
-		for c := 0; c < cfg.Connections; c++ {
-			if err := g.Add(endpoint, cfg); err != nil {
+	if err := cfg.ParseEndpointRateLimits(); err != nil {
+		return err
+	}
+	if err := cfg.ResolveEndpoints(); err != nil {
+		return err
+	}
+	addrs, err := resolveRPCEndpoints(cfg.Endpoints, cfg.RPCVersion, g.logger)
+	if err != nil {
+		return err
+	}
+	if cfg.EndpointSelectMethod == SelectLowestLatencyEndpoints {
+		addrs = g.selectLowestLatency(cfg, addrs)
+	}
+	conns := cfg.endpointConnections(cfg.Endpoints)
+	for i, addr := range addrs {
+		for c := 0; c < conns[i]; c++ {
+			if err := g.add(addr, cfg.EndpointNames[cfg.Endpoints[i]], cfg); err != nil {
 				return err
 			}
 		}
 	}
+	if len(cfg.EndpointWeights) > 0 {
+		g.endpointWeights = make(map[string]float64, len(addrs))
+		for i, addr := range addrs {
+			g.endpointWeights[addr] = endpointWeight(cfg.EndpointWeights, cfg.Endpoints[i])
+			g.logger.Info("Endpoint connections", "endpoint", cfg.Endpoints[i], "weight", g.endpointWeights[addr], "connections", conns[i])
+		}
+	}
+	if len(cfg.EndpointRateLimits) > 0 {
+		g.applyEndpointRateLimits(cfg)
+	}
+	if cfg.EndpointFailureThreshold > 0 {
+		g.blacklist = newEndpointBlacklist(cfg, g.transactors, g.redistributeRates, g.logger)
+		for _, t := range g.getTransactors() {
+			t.blacklist = g.blacklist
+		}
+	}
+	if len(g.standby) > 0 {
+		g.holdStandbyEndpoints()
+	}
+	if g.metricsRegistry != nil {
+		g.metrics = newWorkerMetrics(g.metricsRegistry, cfg, g)
+		g.AddMetricsSink(g.metrics)
+	}
+	if cfg.RateWindow > 0 {
+		g.intervalTxs = newIntervalTxCounts(time.Duration(cfg.RateWindow))
+	}
+	if len(cfg.LatencySampleFile) > 0 {
+		g.latencySamples = newLatencyReservoir(cfg.LatencySampleCap, cfg.LatencySampleRate)
+		for _, t := range g.getTransactors() {
+			t.latencySamples = g.latencySamples
+		}
+	}
+	if cfg.TrackCommitLatency && len(g.getTransactors()) > 0 {
+		if err := g.startCommitTracker(cfg); err != nil {
+			g.close()
+			return err
+		}
+	}
+	return nil
+}
+
+// addEndpoint opens the configured number of connections to the given
+// endpoint (found by re-discovery) while the load test is underway, and
+// starts sending transactions over them for the remainder of the load test.
+// The new connections send at the average rate of the existing ones, unless
+// the group has been set to keep to an overall rate, which is then shared
+// with them.
+func (g *TransactorGroup) addEndpoint(addr string) error {
+	joinedAt := time.Since(g.getStartTime())
+	cfg := *g.config
+	// without a time limit, the new connections send their Count
+	// transactions like the rest
+	if limit := g.config.timeLimit(); limit > 0 {
+		remaining := limit - joinedAt
+		if remaining < time.Duration(g.config.SendPeriod) {
+			return fmt.Errorf("too little of the load test remains")
+		}
+		if cfg.countBounded() {
+			cfg.MaxRunTime = Duration(remaining)
+		} else {
+			cfg.Time = Duration(remaining)
+		}
+	}
+	transactors := make([]*Transactor, 0, cfg.Connections)
+	for c := 0; c < cfg.Connections; c++ {
+		t, err := newNamedTransactor(addr, "", &cfg, g.newLogger)
+		if err != nil {
+			for _, t := range transactors {
+				t.close()
+			}
+			return err
+		}
+		if len(g.metricsSinks) > 0 {
+			t.metricsSink = g.metricsSinks
+		}
+		t.commitTracker = g.commitTrk
+		t.latencySamples = g.latencySamples
+		t.blacklist = g.blacklist
+		transactors = append(transactors, t)
+	}
+
+	g.transactorsMtx.Lock()
+	if g.joinedAt == nil {
+		g.joinedAt = make(map[string]time.Duration)
+	}
+	g.joinedAt[addr] = joinedAt
+	g.transactorsMtx.Unlock()
+	if g.blacklist != nil {
+		g.blacklist.add(transactors)
+	}
+	if g.mempoolMon != nil {
+		if err := g.mempoolMon.add(transactors); err != nil {
+			g.logger.Warn("Failed to monitor mempool of rediscovered endpoint - not throttling it", "endpoint", addr, "err", err)
+		}
+	}
+	if g.metrics != nil {
+		g.metrics.addEndpoint(addr)
+	}
+
+	g.ratesMtx.Lock()
+	prevTotal := 0.0
+	for _, t := range g.getTransactors() {
+		prevTotal += t.getRate()
+	}
+	rate := g.averageRate()
+	for _, t := range transactors {
+		t.setRate(rate)
+	}
+	total := prevTotal + rate*float64(len(transactors))
+	if g.rateShares != nil && total > 0 {
+		for i := range g.rateShares {
+			g.rateShares[i] *= prevTotal / total
+		}
+		for range transactors {
+			g.rateShares = append(g.rateShares, rate/total)
+		}
+	}
+	if g.fixedTxRate > 0 && total > 0 {
+		for _, t := range g.getTransactors() {
+			t.setRate(t.getRate() * prevTotal / total)
+		}
+		for _, t := range transactors {
+			t.setRate(t.getRate() * prevTotal / total)
+		}
+	}
+	for _, t := range transactors {
+		g.startTransactor(t)
+	}
+	g.ratesMtx.Unlock()
+	g.logger.Info("Added rediscovered endpoint to load test", "endpoint", addr, "connections", len(transactors), "rate", fmt.Sprintf("%.3f txs/sec", g.targetTxRate()))
+	return nil
+}
+
+// averageRate returns the average rate (per send period) of the transactors
+// that are sending. Must be called with the rates mutex held.
+func (g *TransactorGroup) averageRate() float64 {
+	total, sending := 0.0, 0
+	for _, t := range g.getTransactors() {
+		if rate := t.getRate(); rate > 0 {
+			total += rate
+			sending++
+		}
+	}
+	if sending == 0 {
+		return g.config.Rate
+	}
+	return total / float64(sending)
+}
+
+// endpointJoinTimes returns how far into the load test each endpoint added by
+// re-discovery was added, by address.
+func (g *TransactorGroup) endpointJoinTimes() map[string]time.Duration {
+	g.transactorsMtx.RLock()
+	defer g.transactorsMtx.RUnlock()
+	joinedAt := make(map[string]time.Duration, len(g.joinedAt))
+	for addr, d := range g.joinedAt {
+		joinedAt[addr] = d
+	}
+	return joinedAt
+}
+
+// applyEndpointRateLimits paces each transactor such that no endpoint
+// receives more than its rate limit, redistributing the remaining rate
+// amongst the other endpoints in proportion to their weights.
+func (g *TransactorGroup) applyEndpointRateLimits(cfg *Config) {
+	rates, total := cfg.endpointRates(cfg.Endpoints, cfg.EndpointRateLimits, cfg.EndpointWeights)
+	if requested := cfg.expectedTxRate(len(g.getTransactors())); total < requested-1e-6 {
+		g.logger.Warn("Endpoint rate limits cap the overall rate below the requested rate", "rate", total, "requested", requested)
+	}
+	// transactors were added in order of endpoint
+	i := 0
+	for e, conns := range cfg.endpointConnections(cfg.Endpoints) {
+		endpointRate := rates[cfg.Endpoints[e]]
+		for c := 0; c < conns; c++ {
+			g.transactors[i].setRate(endpointRate * cfg.SendPeriod.Seconds() / float64(conns))
+			i++
+		}
+	}
+	for endpoint, rate := range rates {
+		g.logger.Info("Endpoint target rate", "endpoint", endpoint, "rate", fmt.Sprintf("%.3f txs/sec", rate))
+	}
+}
+
+// selectLowestLatency measures the latency of the given endpoints (resolved
+// from the configured ones), returning them ordered from the fastest to the
+// slowest, and reordering the configured endpoints to match. Only the
+// preferred (fastest) endpoints are kept, unless endpoints are blacklisted
+// after repeated failures, in which case the others are kept on standby to
+// stand in for blacklisted preferred endpoints.
+func (g *TransactorGroup) selectLowestLatency(cfg *Config, addrs []string) []string {
+	g.endpointLatencies = probeEndpointLatencies(addrs, cfg.preferredEndpoints(), cfg.healthCheckTimeout(), g.logger)
+	configured := make(map[string]string, len(addrs))
+	for i, addr := range addrs {
+		configured[addr] = cfg.Endpoints[i]
+	}
+	selected := make([]string, 0, len(addrs))
+	endpoints := make([]string, 0, len(addrs))
+	g.standby = nil
+	for _, latency := range g.endpointLatencies {
+		if !latency.Preferred {
+			if cfg.EndpointFailureThreshold <= 0 {
+				continue
+			}
+			g.standby = append(g.standby, latency.Endpoint)
+		}
+		selected = append(selected, latency.Endpoint)
+		endpoints = append(endpoints, configured[latency.Endpoint])
+	}
+	cfg.Endpoints = endpoints
+	return selected
+}
+
+// holdStandbyEndpoints stops the standby endpoints' transactors from sending,
+// while recording the shares of the overall rate they'd take on when standing
+// in for blacklisted preferred endpoints. As without standby endpoints, the
+// overall rate is that of the preferred endpoints' transactors.
+func (g *TransactorGroup) holdStandbyEndpoints() {
+	g.ratesMtx.Lock()
+	defer g.ratesMtx.Unlock()
+	transactors := g.getTransactors()
+	total := 0.0
+	for _, t := range transactors {
+		total += t.getRate()
+	}
+	idle := g.idleStandbyEndpoints()
+	g.rateShares = make([]float64, len(transactors))
+	for i, t := range transactors {
+		// until the rate is set (e.g. as a share of a total rate), each
+		// connection has an equal share
+		g.rateShares[i] = 1 / float64(len(transactors))
+		if total > 0 {
+			g.rateShares[i] = t.getRate() / total
+		}
+		if idle[t.remoteAddr] {
+			t.setRate(0)
+		}
+	}
+	g.logger.Info("Holding endpoints on standby", "endpoints", g.standby)
+}
+
+// idleStandbyEndpoints returns those of the standby endpoints that aren't
+// needed to stand in for blacklisted preferred endpoints (in order of
+// latency), and so mustn't send.
+func (g *TransactorGroup) idleStandbyEndpoints() map[string]bool {
+	if len(g.standby) == 0 {
+		return nil
+	}
+	isStandby := make(map[string]bool, len(g.standby))
+	for _, addr := range g.standby {
+		isStandby[addr] = true
+	}
+	blacklisted := make(map[string]bool)
+	for _, t := range g.getTransactors() {
+		if t.blacklisted.Load() {
+			blacklisted[t.remoteAddr] = true
+		}
+	}
+	needed := 0
+	for addr := range blacklisted {
+		if !isStandby[addr] {
+			needed++
+		}
+	}
+	idle := make(map[string]bool)
+	for _, addr := range g.standby {
+		if blacklisted[addr] {
+			continue
+		}
+		if needed > 0 {
+			needed--
+			continue
+		}
+		idle[addr] = true
+	}
+	return idle
+}
+
+// startCommitTracker subscribes to new blocks on the first of our endpoints
+// so that send-to-commit latencies can be tracked for all transactors.
+func (g *TransactorGroup) startCommitTracker(cfg *Config) error {
+	ct := newCommitTracker(g.transactors[0].remoteAddr, cfg.expectedTxRate(len(g.getTransactors())), g.logger)
+	if err := ct.Start(); err != nil {
+		return err
+	}
+	for _, t := range g.getTransactors() {
+		t.commitTracker = ct
+	}
+	g.commitTrk = ct
 	return nil
 }
 
@@ -76,45 +469,199 @@ func (g *TransactorGroup) SetProgressCallback(interval time.Duration, callback f
 	g.progressCallbackMtx.Unlock()
 }
 
+// setTimeseriesCallback enables the recording of timeseries statistics at the
+// given interval, passing each sample to the given callback. Must be called
+// before Start.
+func (g *TransactorGroup) setTimeseriesCallback(interval time.Duration, callback func(timeseriesSample)) {
+	g.timeseriesInterval = interval
+	g.timeseriesCallback = callback
+}
+
+// setProgressStatusCallback enables periodic progress reporting at the given
+// interval, through the given callback. Must be called before Start.
+func (g *TransactorGroup) setProgressStatusCallback(interval time.Duration, callback func(progressStatus)) {
+	g.progressStatusInterval = interval
+	g.progressStatusCallback = callback
+}
+
 // Start will handle through all transactors and start them.
 func (g *TransactorGroup) Start() {
+	if g.config != nil && g.config.MempoolPauseThreshold > 0 {
+		mon, err := newMempoolMonitor(g.config, g.transactors, g.logger)
+		if err != nil {
+			g.logger.Warn("Failed to create mempool monitor - not throttling endpoints", "err", err)
+		} else {
+			g.mempoolMon = mon
+			go mon.run()
+		}
+	}
+	if g.blacklist != nil {
+		go g.blacklist.run()
+	}
+	if g.config != nil && g.config.RediscoveryInterval > 0 {
+		g.rediscoverer = newEndpointRediscoverer(g, g.config, g.logger)
+	}
 	go g.progressReporter()
-	for _, t := range g.transactors {
+	for _, t := range g.getTransactors() {
+		if len(g.metricsSinks) > 0 {
+			t.metricsSink = g.metricsSinks
+		}
 		t.Start()
 	}
 	g.setStartTime(time.Now())
+	if g.timeseriesCallback != nil {
+		g.timeseriesRec = newTimeseriesRecorder(g, g.timeseriesInterval, g.timeseriesCallback)
+		go g.timeseriesRec.run()
+	}
+	if g.intervalTxs != nil {
+		g.intervalRec = newTimeseriesRecorder(g, g.intervalTxs.window, g.intervalTxs.add)
+		go g.intervalRec.run()
+	}
+	if g.progressStatusCallback != nil {
+		g.progressMon = newProgressMonitor(g, g.progressStatusInterval, g.progressStatusCallback)
+		go g.progressMon.run()
+	}
+	if g.rediscoverer != nil {
+		go g.rediscoverer.run()
+	}
 }
 
-// Cancel signals to all transactors to stop their operations.
+// Cancel signals to all transactors to stop their operations, in which case
+// Wait returns ErrLoadTestCancelled.
 func (g *TransactorGroup) Cancel() {
-	for _, t := range g.transactors {
+	g.cancelled.Store(true)
+	for _, t := range g.getTransactors() {
 		t.Cancel()
 	}
 }
 
+// Terminate stops all transactors from sending transactions, and gives them
+// up to the given time to receive the responses to those still in flight
+// before cancelling them, in which case Wait returns ErrLoadTestTerminated.
+// Blocks until the transactors have been cancelled.
+func (g *TransactorGroup) Terminate(drain time.Duration) {
+	g.terminated.Store(true)
+	g.cancelled.Store(true)
+	for _, t := range g.getTransactors() {
+		t.stopSending()
+	}
+	timeout := time.After(drain)
+	pollTicker := time.NewTicker(drainPollInterval)
+	defer pollTicker.Stop()
+drain:
+	for g.inFlightTxs() > 0 {
+		select {
+		case <-pollTicker.C:
+		case <-timeout:
+			g.logger.Info("Timed out while draining in-flight responses", "inFlight", g.inFlightTxs())
+			break drain
+		}
+	}
+	g.Cancel()
+}
+
+// inFlightTxs returns the number of transactions to which the transactors
+// have yet to receive responses.
+func (g *TransactorGroup) inFlightTxs() int {
+	inFlight := 0
+	for _, t := range g.getTransactors() {
+		inFlight += t.GetTxCount() - t.GetTxResponseCount()
+	}
+	return inFlight
+}
+
+// Pause stops all transactors from sending transactions until Resume is
+// called, while keeping their connections open. The time spent paused is
+// excluded from the statistics' rates. Returns false if already paused.
+func (g *TransactorGroup) Pause() bool {
+	if !g.pauseClk.pause(time.Now()) {
+		return false
+	}
+	for _, t := range g.getTransactors() {
+		t.Pause()
+	}
+	g.logger.Info("Load test paused")
+	return true
+}
+
+// Resume resumes sending transactions after a pause. Returns false if not
+// paused.
+func (g *TransactorGroup) Resume() bool {
+	if !g.pauseClk.resume(time.Now()) {
+		return false
+	}
+	for _, t := range g.getTransactors() {
+		t.Resume()
+	}
+	g.logger.Info("Load test resumed", "totalPaused", g.pauseClk.pausedDuration(time.Now()).Round(time.Millisecond).String())
+	return true
+}
+
 // Wait will wait for all transactors to complete, returning the first error
 // we encounter.
 func (g *TransactorGroup) Wait() error {
 	defer func() {
 		close(g.stopProgressReporter)
 		<-g.progressReporterStopped
+		if g.timeseriesRec != nil {
+			g.timeseriesRec.Stop()
+		}
+		if g.intervalRec != nil {
+			g.intervalRec.Stop()
+		}
+		if g.progressMon != nil {
+			g.progressMon.Stop()
+		}
+		if g.mempoolMon != nil {
+			g.mempoolMon.Stop()
+		}
+		if g.blacklist != nil {
+			g.blacklist.Stop()
+		}
+		if g.commitTrk != nil {
+			// give the network a chance to commit the last of our transactions
+			g.commitTrk.Stop(time.Duration(g.config.DrainTimeout))
+		}
 	}()
 
+	transactors := g.getTransactors()
+	err := g.waitForTransactors(transactors, 0)
+	if g.rediscoverer != nil {
+		// no more transactors can be added once re-discovery has stopped, so
+		// we can then wait for any that were added in the meantime
+		g.rediscoverer.Stop()
+		if e := g.waitForTransactors(g.getTransactors()[len(transactors):], len(transactors)); err == nil {
+			err = e
+		}
+	}
+	if err != nil && g.terminated.Load() {
+		return ErrLoadTestTerminated
+	}
+	if err != nil && g.cancelled.Load() {
+		return ErrLoadTestCancelled
+	}
+	return err
+}
+
+// waitForTransactors waits for the given transactors (the first of which has
+// the given index in the group) to complete, returning the first error we
+// encounter.
+func (g *TransactorGroup) waitForTransactors(transactors []*Transactor, from int) error {
 	var wg sync.WaitGroup
 	var err error
-	errc := make(chan error, len(g.transactors))
-	for i, t := range g.transactors {
+	errc := make(chan error, len(transactors))
+	for i, t := range transactors {
 		wg.Add(1)
 		go func(_i int, _t *Transactor) {
 			errc <- _t.Wait()
 			defer wg.Done()
 			// get the final tx count
 			g.trackTransactorProgress(_i, _t.GetTxCount(), _t.GetTxBytes())
-		}(i, t)
+		}(from+i, t)
 	}
 	wg.Wait()
 	// collect the results
-	for i := 0; i < len(g.transactors); i++ {
+	for i := 0; i < len(transactors); i++ {
 		if e := <-errc; e != nil {
 			err = e
 			break
@@ -124,12 +671,195 @@ func (g *TransactorGroup) Wait() error {
 }
 
 func (g *TransactorGroup) WriteAggregateStats(filename string) error {
+	sw, stats := &StatsWriter{}, g.aggregateStats()
+	if g.config != nil {
+		sw, stats = NewStatsWriter(*g.config), csvAggregateStats(*g.config, stats)
+	}
+	return writeAggregateStats(filename, sw, stats, nil)
+}
+
+// Report returns the results of the load test so far.
+func (g *TransactorGroup) Report() Report {
+	cfg := Config{}
+	if g.config != nil {
+		cfg = *g.config
+	}
+	return NewReport(cfg, g.aggregateStats(), nil)
+}
+
+func (g *TransactorGroup) aggregateStats() AggregateStats {
 	stats := AggregateStats{
-		TotalTxs:         g.totalTxs(),
-		TotalTimeSeconds: time.Since(g.getStartTime()).Seconds(),
-		TotalBytes:       g.totalBytes(),
+		TotalTxs:                g.totalTxs(),
+		TotalTimeSeconds:        g.activeSeconds(),
+		TotalBytes:              g.totalBytes(),
+		TargetTxRate:            g.targetTxRate(),
+		FailedTxs:               g.totalFailures(),
+		ErroredConnections:      g.erroredConnections(),
+		PregeneratedTxs:         g.pregeneratedTxs(),
+		BroadcastLatency:        g.BroadcastLatencyStats(),
+		Mempool:                 g.MempoolStats(),
+		EndpointHealth:          g.EndpointHealthStats(),
+		CommitLatency:           g.CommitLatencyStats(),
+		CommitLatencyByPriority: g.CommitLatencyByPriority(),
+		Endpoints:               g.EndpointStats(),
+		EndpointLatencies:       g.endpointLatencies,
+	}
+	if g.intervalTxs != nil {
+		stats.setIntervalRates(intervalRateStats(g.intervalTxs.Counts(), g.intervalTxs.window))
+	}
+	return stats
+}
+
+// writeLatencySamples writes the raw latency samples retained thus far to the
+// given CSV file.
+func (g *TransactorGroup) writeLatencySamples(filename string) error {
+	if g.latencySamples == nil {
+		return fmt.Errorf("raw latency samples were not recorded")
+	}
+	return writeLatencySamples(filename, g.latencySamples.Samples())
+}
+
+// intervalTxCounts returns the number of transactions sent during each rate
+// window, if interval rate statistics are to be computed.
+func (g *TransactorGroup) intervalTxCounts() []int {
+	if g.intervalTxs == nil {
+		return nil
+	}
+	return g.intervalTxs.Counts()
+}
+
+// EndpointStats returns the target and total number of transactions sent to
+// each endpoint, or nil if the group hasn't been configured yet.
+func (g *TransactorGroup) EndpointStats() []EndpointStats {
+	if g.config == nil {
+		return nil
+	}
+	joinedAt := g.endpointJoinTimes()
+	stats := make([]EndpointStats, 0)
+	byEndpoint := make(map[string]int)
+	for _, t := range g.getTransactors() {
+		idx, exists := byEndpoint[t.remoteAddr]
+		if !exists {
+			idx = len(stats)
+			byEndpoint[t.remoteAddr] = idx
+			stats = append(stats, EndpointStats{
+				Endpoint:      t.remoteAddr,
+				Name:          t.name,
+				Weight:        g.endpointWeights[t.remoteAddr],
+				JoinedSeconds: joinedAt[t.remoteAddr].Seconds(),
+			})
+		}
+		stats[idx].TargetRate += t.getRate() / g.config.SendPeriod.Seconds()
+		stats[idx].TotalTxs += t.GetTxCount()
+	}
+	computeEndpointShares(stats)
+	return stats
+}
+
+// BroadcastLatencyStats returns the distribution of broadcast latencies
+// across all transactors.
+func (g *TransactorGroup) BroadcastLatencyStats() *LatencyStats {
+	return g.broadcastLatencies().Stats()
+}
+
+func (g *TransactorGroup) broadcastLatencies() *latencySketch {
+	merged := newLatencySketch()
+	for _, t := range g.getTransactors() {
+		merged.Merge(t.getBroadcastLatencies())
+	}
+	return merged
+}
+
+// CommitLatencyStats returns the send-to-commit latency distribution of the
+// sampled transactions, or nil if commit latency tracking is not enabled.
+func (g *TransactorGroup) CommitLatencyStats() *LatencyStats {
+	if g.commitTrk == nil {
+		return nil
+	}
+	return g.commitTrk.Latencies().Stats()
+}
+
+// CommitLatencyByPriority returns the send-to-commit latency distributions of
+// the sampled transactions for each transaction priority, ordered by
+// ascending priority. Returns nil if commit latency tracking is not enabled or
+// the client does not produce prioritized transactions.
+func (g *TransactorGroup) CommitLatencyByPriority() []PriorityLatencyStats {
+	return priorityLatencyStats(g.commitLatenciesByPriority())
+}
+
+func (g *TransactorGroup) commitLatenciesByPriority() map[int64]*latencySketch {
+	if g.commitTrk == nil {
+		return nil
+	}
+	return g.commitTrk.LatenciesByPriority()
+}
+
+func (g *TransactorGroup) commitLatencies() *latencySketch {
+	if g.commitTrk == nil {
+		return nil
+	}
+	return g.commitTrk.Latencies()
+}
+
+// MempoolStats returns statistics on how sending was throttled by mempool
+// monitoring, or nil if mempool monitoring is not enabled.
+func (g *TransactorGroup) MempoolStats() *MempoolStats {
+	if g.mempoolMon == nil {
+		return nil
+	}
+	stats := g.mempoolMon.stats()
+	return &stats
+}
+
+// EndpointHealthStats returns statistics on the endpoints blacklisted after
+// repeated failures, or nil if endpoints are never blacklisted.
+func (g *TransactorGroup) EndpointHealthStats() *EndpointHealthStats {
+	if g.blacklist == nil {
+		return nil
+	}
+	stats := g.blacklist.stats()
+	return &stats
+}
+
+// healthyEndpoints returns the number of endpoints that have not been
+// blacklisted.
+func (g *TransactorGroup) healthyEndpoints() int {
+	if g.blacklist == nil {
+		return len(g.transactorsByEndpoint())
 	}
-	return writeAggregateStats(filename, stats)
+	return g.blacklist.stats().HealthyEndpoints
+}
+
+// sendEndTime returns the time at which the last of the transactors stopped
+// sending transactions, which excludes any time spent draining in-flight
+// responses. If any transactor is still sending, the current time is returned.
+func (g *TransactorGroup) sendEndTime() time.Time {
+	var endTime time.Time
+	for _, t := range g.getTransactors() {
+		tEnd := t.GetSendEndTime()
+		if tEnd.IsZero() {
+			return time.Now()
+		}
+		if tEnd.After(endTime) {
+			endTime = tEnd
+		}
+	}
+	if endTime.IsZero() {
+		return time.Now()
+	}
+	return endTime
+}
+
+// activeSeconds returns how long the transactors spent sending transactions,
+// excluding any time spent paused.
+func (g *TransactorGroup) activeSeconds() float64 {
+	return g.pauseClk.activeSeconds(g.getStartTime(), g.sendEndTime())
+}
+
+// drainDuration returns how long has elapsed since the last transactor
+// stopped sending transactions.
+func (g *TransactorGroup) drainDuration() time.Duration {
+	return time.Since(g.sendEndTime())
 }
 
 func (g *TransactorGroup) progressReporter() {
@@ -195,6 +925,187 @@ func (g *TransactorGroup) totalTxs() int {
 	return total
 }
 
+// progress estimates how far along the load test is, given the recently
+// achieved transaction rate.
+func (g *TransactorGroup) progress(txRate float64) progressStatus {
+	totals := g.timeseriesTotals()
+	p := computeProgress(g.config, len(g.getTransactors()), time.Since(g.getStartTime()), totals.txs, txRate, totals.failures)
+	p.TxBytes = totals.bytes
+	return p
+}
+
+// progressRecord returns the structured record of the given progress of the
+// transactor group, which belongs to the given worker.
+func (g *TransactorGroup) progressRecord(worker string, p progressStatus) progressRecord {
+	return newProgressRecord(worker, p, g.broadcastLatencies())
+}
+
+// avgTxRate returns the average transaction rate achieved by all transactors
+// since the start of the load test.
+func (g *TransactorGroup) avgTxRate() float64 {
+	rate := 0.0
+	for _, t := range g.getTransactors() {
+		rate += t.GetTxRate()
+	}
+	return rate
+}
+
+// transactorsByEndpoint groups our transactors by their endpoint addresses.
+func (g *TransactorGroup) transactorsByEndpoint() map[string][]*Transactor {
+	byEndpoint := make(map[string][]*Transactor)
+	for _, t := range g.getTransactors() {
+		byEndpoint[t.remoteAddr] = append(byEndpoint[t.remoteAddr], t)
+	}
+	return byEndpoint
+}
+
+// endpointLabel returns the alias of the given endpoint, if it has one, or
+// otherwise its address, with which to label its metrics.
+func (g *TransactorGroup) endpointLabel(endpoint string) string {
+	for _, t := range g.transactorsByEndpoint()[endpoint] {
+		return t.label()
+	}
+	return endpoint
+}
+
+// targetTxRate returns the configured transaction rate (tx/sec) across all
+// transactors, taking any endpoint rate limits into account.
+func (g *TransactorGroup) targetTxRate() float64 {
+	if g.config == nil || g.config.SendPeriod <= 0 {
+		return 0
+	}
+	rate := 0.0
+	for _, t := range g.getTransactors() {
+		rate += t.getRate()
+	}
+	return rate / g.config.SendPeriod.Seconds()
+}
+
+// setTxRate changes the transaction rate (tx/sec) across all transactors to the
+// given one, keeping each transactor's share of it (e.g. as shaped by endpoint
+// rate limits). May be called while the transactors are sending.
+func (g *TransactorGroup) setTxRate(txRate float64) {
+	if g.config == nil || len(g.getTransactors()) == 0 {
+		return
+	}
+	g.ratesMtx.Lock()
+	defer g.ratesMtx.Unlock()
+	g.fixedTxRate = txRate
+	current := g.targetTxRate()
+	for _, t := range g.getTransactors() {
+		if current > 0 {
+			t.setRate(t.getRate() * txRate / current)
+		} else {
+			t.setRate(g.config.rateFor(txRate, len(g.getTransactors())))
+		}
+	}
+	if current <= 0 && g.blacklist != nil {
+		g.redistributeRatesLocked()
+	}
+}
+
+// redistributeRates gives the rate of the blacklisted endpoints' transactors
+// to those of the healthy endpoints, in proportion to the transactors' shares
+// of the overall rate before any endpoint was blacklisted, so that the overall
+// rate is maintained. If no endpoint is healthy the rates are left as they
+// are, since blacklisted transactors don't send anyway.
+func (g *TransactorGroup) redistributeRates() {
+	g.ratesMtx.Lock()
+	defer g.ratesMtx.Unlock()
+	g.redistributeRatesLocked()
+}
+
+// Must be called with the rates mutex held.
+func (g *TransactorGroup) redistributeRatesLocked() {
+	total := 0.0
+	for _, t := range g.getTransactors() {
+		total += t.getRate()
+	}
+	if total <= 0 {
+		return
+	}
+	if g.rateShares == nil {
+		g.rateShares = make([]float64, len(g.getTransactors()))
+		for i, t := range g.getTransactors() {
+			g.rateShares[i] = t.getRate() / total
+		}
+	}
+	idle := g.idleStandbyEndpoints()
+	healthyShares := 0.0
+	for i, t := range g.getTransactors() {
+		if !t.blacklisted.Load() && !idle[t.remoteAddr] {
+			healthyShares += g.rateShares[i]
+		}
+	}
+	if healthyShares <= 0 {
+		return
+	}
+	for i, t := range g.getTransactors() {
+		if t.blacklisted.Load() || idle[t.remoteAddr] {
+			t.setRate(0)
+		} else {
+			t.setRate(total * g.rateShares[i] / healthyShares)
+		}
+	}
+	if active := len(g.standby) - len(idle); active > 0 {
+		g.logger.Info("Standby endpoints standing in for blacklisted preferred endpoints", "active", active, "standby", len(g.standby))
+	}
+	g.logger.Info("Redistributed rate amongst healthy endpoints", "healthyEndpoints", g.healthyEndpoints(), "rate", fmt.Sprintf("%.3f txs/sec", g.targetTxRate()))
+}
+
+// erroredConnections returns the number of transactors whose connections
+// failed.
+func (g *TransactorGroup) erroredConnections() int {
+	errored := 0
+	for _, t := range g.getTransactors() {
+		if t.hasConnectionErrored() {
+			errored++
+		}
+	}
+	return errored
+}
+
+func (g *TransactorGroup) totalFailures() int {
+	total := 0
+	for _, t := range g.getTransactors() {
+		total += t.GetTxFailureCount()
+	}
+	return total
+}
+
+// workerStats summarizes this group's activity on behalf of the worker with
+// the given ID.
+func (g *TransactorGroup) workerStats(id string) *WorkerStats {
+	stats := &WorkerStats{
+		ID:               id,
+		TotalTxs:         g.totalTxs(),
+		TotalBytes:       g.totalBytes(),
+		TotalTimeSeconds: g.activeSeconds(),
+		Failures:         g.totalFailures(),
+
+		ErroredConnections: g.erroredConnections(),
+		TargetTxRate:       g.targetTxRate(),
+		PregeneratedTxs:    g.pregeneratedTxs(),
+
+		EndpointLatencies: g.endpointLatencies,
+	}
+	stats.Compute()
+	return stats
+}
+
+func (g *TransactorGroup) timeseriesTotals() timeseriesTotals {
+	totals := timeseriesTotals{}
+	for _, t := range g.getTransactors() {
+		totals.txs += t.GetTxCount()
+		totals.bytes += t.GetTxBytes()
+		totals.failures += t.GetTxFailureCount()
+		latencyCount, latencySum := t.getLatencyTotals()
+		totals.latencyCount += latencyCount
+		totals.latencySum += latencySum
+	}
+	return totals
+}
+
 func (g *TransactorGroup) totalBytes() int64 {
 	g.statsMtx.RLock()
 	defer g.statsMtx.RUnlock()
@@ -206,7 +1117,7 @@ func (g *TransactorGroup) totalBytes() int64 {
 }
 
 func (g *TransactorGroup) close() {
-	for _, t := range g.transactors {
+	for _, t := range g.getTransactors() {
 		t.close()
 	}
 }
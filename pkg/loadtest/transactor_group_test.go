@@ -0,0 +1,206 @@
+package loadtest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactorGroupEndpointRateLimits(t *testing.T) {
+	capped := newMockRPCServer(t, 0)
+	uncapped := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(capped.URL()+"|maxrate=5", uncapped.URL())
+	cfg.Time = seconds(3)
+	cfg.Rate = 20
+	cfg.Count = -1
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	require.NoError(t, tg.Wait())
+
+	// the capped endpoint's shortfall must go to the uncapped endpoint
+	stats := tg.EndpointStats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, capped.URL(), stats[0].Endpoint)
+	assert.InDelta(t, 5, stats[0].TargetRate, 1e-9)
+	assert.InDelta(t, 35, stats[1].TargetRate, 1e-9)
+
+	// 3 send periods
+	assert.LessOrEqual(t, capped.Requests(), 15)
+	assert.GreaterOrEqual(t, capped.Requests(), 10)
+	assert.GreaterOrEqual(t, uncapped.Requests(), 70)
+	assert.Equal(t, capped.Requests(), stats[0].TotalTxs)
+}
+
+func TestTransactorGroupEndpointWeights(t *testing.T) {
+	heavy := newMockRPCServer(t, 0)
+	light := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(heavy.URL()+"|weight=3", light.URL())
+	cfg.Connections = 4
+	cfg.Time = seconds(3)
+	cfg.Rate = 5
+	cfg.Count = -1
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	require.NoError(t, tg.Wait())
+
+	// the 8 connections are divided 3:1
+	assert.Equal(t, 6, heavy.Connections())
+	assert.Equal(t, 2, light.Connections())
+	assert.Equal(t, map[string]float64{heavy.URL(): 3}, cfg.EndpointWeights)
+
+	stats := tg.Report().Aggregate.Endpoints
+	require.Len(t, stats, 2)
+	assert.Equal(t, heavy.URL(), stats[0].Endpoint)
+	assert.Equal(t, 3.0, stats[0].Weight)
+	assert.Equal(t, 1.0, stats[1].Weight)
+	assert.InDelta(t, 30, stats[0].TargetRate, 1e-9)
+	assert.InDelta(t, 10, stats[1].TargetRate, 1e-9)
+	assert.Equal(t, heavy.Requests(), stats[0].TotalTxs)
+	assert.InDelta(t, 0.75, stats[0].Share, 0.05)
+	assert.InDelta(t, 1, stats[0].Share+stats[1].Share, 1e-9)
+}
+
+func TestTransactorGroupSubSecondSendPeriod(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(2)
+	cfg.SendPeriod = loadtest.Duration(250 * time.Millisecond)
+	cfg.Rate = 5
+	cfg.Count = -1
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	require.NoError(t, tg.Wait())
+
+	// 8 send periods of 5 transactions each, i.e. 20 tx/sec
+	assert.InDelta(t, 20, tg.Report().Aggregate.TargetTxRate, 1e-9)
+	assert.GreaterOrEqual(t, svr.Requests(), 30)
+	assert.LessOrEqual(t, svr.Requests(), 45)
+}
+
+func TestTransactorGroupPause(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(5)
+	cfg.Count = -1
+	require.NoError(t, cfg.Validate())
+
+	tg := loadtest.NewTransactorGroup()
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	time.Sleep(1500 * time.Millisecond)
+	require.True(t, tg.Pause())
+	require.False(t, tg.Pause())
+	paused := svr.Requests()
+	time.Sleep(2 * time.Second)
+	// nothing is sent while paused
+	assert.Equal(t, paused, svr.Requests())
+	require.True(t, tg.Resume())
+	require.False(t, tg.Resume())
+	require.NoError(t, tg.Wait())
+
+	// the time spent paused doesn't count towards the rates
+	stats := tg.Report().Aggregate
+	assert.InDelta(t, 3, stats.TotalTimeSeconds, 0.5)
+	assert.InDelta(t, float64(stats.TotalTxs)/stats.TotalTimeSeconds, stats.AvgTxRate, 1e-9)
+	assert.Greater(t, svr.Requests(), paused)
+}
+
+func TestTransactorGroupRPCVersionNegotiation(t *testing.T) {
+	legacy := newMockRPCServer(t, 0)
+	v1 := newMockRPCServerV1(t, 0)
+
+	testCases := []struct {
+		name        string
+		rpcVersion  string
+		endpoints   []string
+		expectError bool
+	}{
+		{"legacy auto", loadtest.RPCVersionAuto, []string{legacy.LegacyURL()}, false},
+		// the legacy route must be rewritten to the v1 route
+		{"v1 auto", loadtest.RPCVersionAuto, []string{v1.LegacyURL()}, false},
+		{"mixed auto", loadtest.RPCVersionAuto, []string{legacy.LegacyURL(), v1.LegacyURL()}, false},
+		{"v1 forced", loadtest.RPCVersionV1, []string{v1.LegacyURL()}, false},
+		{"legacy forced against v1", loadtest.RPCVersionLegacy, []string{v1.LegacyURL()}, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			legacyBefore, v1Before := legacy.Requests(), v1.Requests()
+			cfg := mockTestConfig(tc.endpoints...)
+			cfg.RPCVersion = tc.rpcVersion
+			require.NoError(t, cfg.Validate())
+
+			tg := loadtest.NewTransactorGroup()
+			err := tg.AddAll(&cfg)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			tg.Start()
+			require.NoError(t, tg.Wait())
+
+			for _, endpoint := range tc.endpoints {
+				switch endpoint {
+				case legacy.LegacyURL():
+					assert.Equal(t, cfg.Count, legacy.Requests()-legacyBefore)
+				case v1.LegacyURL():
+					assert.Equal(t, cfg.Count, v1.Requests()-v1Before)
+				}
+			}
+		})
+	}
+}
+
+func TestTransactorGroupBroadcastLatencyMetric(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL(), svr.URL())
+	cfg.Time = seconds(2)
+	cfg.BroadcastTxMethod = "sync"
+	cfg.DrainTimeout = seconds(5)
+	cfg.BroadcastLatencyBuckets = []float64{0.001, 0.01, 0.1}
+	require.NoError(t, cfg.Validate())
+
+	reg := prometheus.NewRegistry()
+	tg := loadtest.NewTransactorGroup()
+	tg.SetMetricsRegistry(reg)
+	require.NoError(t, tg.AddAll(&cfg))
+	tg.Start()
+	require.NoError(t, tg.Wait())
+
+	histogram := gatherHistogram(t, reg, "tmloadtest_worker_broadcast_latency_seconds")
+	require.Positive(t, svr.Requests())
+	assert.Equal(t, uint64(svr.Requests()), histogram.GetSampleCount())
+	require.Len(t, histogram.GetBucket(), len(cfg.BroadcastLatencyBuckets))
+	for i, bucket := range histogram.GetBucket() {
+		assert.Equal(t, cfg.BroadcastLatencyBuckets[i], bucket.GetUpperBound())
+	}
+}
+
+// gatherHistogram returns the histogram with the given name from the given
+// registry.
+func gatherHistogram(t *testing.T, reg prometheus.Gatherer, name string) *dto.Histogram {
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == name {
+			require.Len(t, family.GetMetric(), 1)
+			return family.GetMetric()[0].GetHistogram()
+		}
+	}
+	t.Fatalf("Histogram %s not found", name)
+	return nil
+}
@@ -0,0 +1,72 @@
+package loadtest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactorDrainsInFlightResponses(t *testing.T) {
+	testCases := []struct {
+		drainTimeout      int
+		expectedResponses int
+	}{
+		// without draining, the responses lagging behind the sends are lost
+		{0, 0},
+		{2, 10},
+	}
+	for _, tc := range testCases {
+		svr := newMockRPCServer(t, 500*time.Millisecond)
+		cfg := mockTestConfig(svr.URL())
+		cfg.DrainTimeout = seconds(tc.drainTimeout)
+
+		tr, err := loadtest.NewTransactor(svr.URL(), &cfg)
+		require.NoError(t, err)
+		tr.Start()
+		require.NoError(t, tr.Wait())
+
+		assert.Equal(t, cfg.Count, tr.GetTxCount())
+		assert.Equal(t, tc.expectedResponses, tr.GetTxResponseCount(), "drain timeout %d", tc.drainTimeout)
+		// the drain period must not be counted as sending time
+		sendEnd := tr.GetSendEndTime()
+		require.False(t, sendEnd.IsZero())
+		if tc.drainTimeout > 0 {
+			assert.GreaterOrEqual(t, time.Since(sendEnd), 400*time.Millisecond)
+		}
+	}
+}
+
+func TestTransactorBroadcastLatency(t *testing.T) {
+	testCases := []struct {
+		broadcastTxMethod string
+		minP50            time.Duration
+		maxP50            time.Duration
+	}{
+		// round-trip latency, including the endpoint's response delay (less
+		// the latency sketch's 1% error)
+		{"sync", 198 * time.Millisecond, 400 * time.Millisecond},
+		// write-completion latency only
+		{"async", 0, 100 * time.Millisecond},
+	}
+	for _, tc := range testCases {
+		svr := newMockRPCServer(t, 200*time.Millisecond)
+		cfg := mockTestConfig(svr.URL())
+		cfg.BroadcastTxMethod = tc.broadcastTxMethod
+		cfg.DrainTimeout = seconds(2)
+
+		tr, err := loadtest.NewTransactor(svr.URL(), &cfg)
+		require.NoError(t, err)
+		tr.Start()
+		require.NoError(t, tr.Wait())
+
+		stats := tr.GetBroadcastLatencyStats()
+		assert.Equal(t, cfg.Count, stats.Count, tc.broadcastTxMethod)
+		assert.GreaterOrEqual(t, stats.P50, tc.minP50.Seconds(), tc.broadcastTxMethod)
+		assert.Less(t, stats.P50, tc.maxP50.Seconds(), tc.broadcastTxMethod)
+		assert.LessOrEqual(t, stats.P50, stats.P99)
+		assert.LessOrEqual(t, stats.P99, stats.Max)
+	}
+}
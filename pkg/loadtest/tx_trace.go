@@ -0,0 +1,100 @@
+package loadtest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/rand"
+	"time"
+)
+
+// The number of leading bytes of each traced transaction to log, which for
+// the kvstore client covers its key prefix.
+const traceKeyPrefixLen = 8
+
+// tracedTx holds the details of a transaction sampled for logging at the
+// trace level, until the endpoint responds to it.
+type tracedTx struct {
+	keyPrefix string
+	size      int
+	sentAt    time.Time
+}
+
+// traceSampleRate returns the fraction of transactions whose details to log
+// at the trace level.
+func (c Config) traceSampleRate() float64 {
+	if c.TraceSampleRate > 0 {
+		return c.TraceSampleRate
+	}
+	return 1
+}
+
+// traceEnabled returns whether the given logger writes entries at the trace
+// level. Loggers that can't tell us are assumed to, so that custom loggers
+// still see trace entries.
+func traceEnabled(logger Logger) bool {
+	if l, ok := logger.(interface{ TraceEnabled() bool }); ok {
+		return l.TraceEnabled()
+	}
+	return true
+}
+
+// traceTx samples the transaction sent in the request with the given ID for
+// logging at the trace level once the endpoint responds to it, if we're
+// tracing transactions at all. Must be called before the request is written,
+// since the response may arrive before the write call returns.
+func (t *Transactor) traceTx(id int, tx []byte, sentAt time.Time) {
+	if !t.tracing || rand.Float64() >= t.config.traceSampleRate() {
+		return
+	}
+	prefix := tx
+	if len(prefix) > traceKeyPrefixLen {
+		prefix = prefix[:traceKeyPrefixLen]
+	}
+	t.statsMtx.Lock()
+	defer t.statsMtx.Unlock()
+	if len(t.tracedTxs) < maxTrackedRequests {
+		t.tracedTxs[id] = tracedTx{
+			keyPrefix: hex.EncodeToString(prefix),
+			size:      len(tx),
+			sentAt:    sentAt,
+		}
+	}
+}
+
+// traceResponse logs the details of the sampled transaction to which the
+// given response responds, if any, at the trace level.
+func (t *Transactor) traceResponse(res RPCResponse, receivedAt time.Time) {
+	if !t.tracing {
+		return
+	}
+	t.statsMtx.Lock()
+	tx, ok := t.tracedTxs[res.ID]
+	delete(t.tracedTxs, res.ID)
+	t.statsMtx.Unlock()
+	if !ok {
+		return
+	}
+	t.logger.Trace(
+		"Transaction",
+		"id", res.ID,
+		"keyPrefix", tx.keyPrefix,
+		"size", tx.size,
+		"endpoint", t.label(),
+		"code", responseCode(res),
+		"latency", receivedAt.Sub(tx.sentAt),
+	)
+}
+
+// responseCode returns the code of the given response to a broadcast_tx
+// request: the RPC error's code if it carries one, or otherwise the code of
+// the CheckTx result (which is 0 if the result doesn't carry one).
+func responseCode(res RPCResponse) int {
+	if res.Error != nil {
+		return res.Error.Code
+	}
+	var result struct {
+		Code int `json:"code"`
+	}
+	_ = json.Unmarshal(res.Result, &result)
+	return result.Code
+}
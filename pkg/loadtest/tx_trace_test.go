@@ -0,0 +1,86 @@
+package loadtest_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runTracedTransactor runs a transactor with the given configuration against a
+// mock endpoint, logging at the given level, and returns the trace entries
+// logged for its transactions.
+func runTracedTransactor(t *testing.T, cfg loadtest.Config, level string) []string {
+	loadtest.SetLogger(loadtest.NewLogrusLogger)
+	prevLevel, prevOut := logrus.GetLevel(), logrus.StandardLogger().Out
+	defer func() {
+		loadtest.SetLogger(nil)
+		logrus.SetLevel(prevLevel)
+		logrus.SetOutput(prevOut)
+	}()
+	var buf bytes.Buffer
+	logrus.SetOutput(&buf)
+	require.NoError(t, logging.Configure(level, ""))
+
+	tr, err := loadtest.NewTransactor(cfg.Endpoints[0], &cfg)
+	require.NoError(t, err)
+	tr.Start()
+	require.NoError(t, tr.Wait())
+	require.Equal(t, cfg.Count, tr.GetTxResponseCount())
+
+	var traces []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "level=trace") && strings.Contains(line, "msg=Transaction") {
+			traces = append(traces, line)
+		}
+	}
+	return traces
+}
+
+func TestTraceTxs(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.BroadcastTxMethod = "sync"
+	cfg.DrainTimeout = seconds(2)
+	require.NoError(t, cfg.Validate())
+
+	assert.Empty(t, runTracedTransactor(t, cfg, "debug"))
+
+	traces := runTracedTransactor(t, cfg, "trace")
+	require.Len(t, traces, cfg.Count)
+	for _, field := range []string{"id=", "keyPrefix=", "size=100", "endpoint=", "code=0", "latency="} {
+		assert.Contains(t, traces[0], field)
+	}
+}
+
+func TestTraceSampleRate(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.BroadcastTxMethod = "sync"
+	cfg.DrainTimeout = seconds(2)
+	cfg.Rate = 1000
+	cfg.Count = 1000
+	cfg.TraceSampleRate = 0.2
+	require.NoError(t, cfg.Validate())
+
+	// allows for ~6 standard deviations either way
+	traces := runTracedTransactor(t, cfg, "trace")
+	assert.InDelta(t, 200, len(traces), 75)
+}
+
+func TestTraceSampleRateValidation(t *testing.T) {
+	cfg := mockTestConfig("ws://localhost:26657/websocket")
+	for _, rate := range []float64{0, 0.01, 1} {
+		cfg.TraceSampleRate = rate
+		assert.NoError(t, cfg.Validate(), "rate %f", rate)
+	}
+	for _, rate := range []float64{-0.1, 1.5} {
+		cfg.TraceSampleRate = rate
+		assert.Error(t, cfg.Validate(), "rate %f", rate)
+	}
+}
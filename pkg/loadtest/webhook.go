@@ -0,0 +1,127 @@
+package loadtest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+)
+
+const (
+	WebhookStatusCompleted = "completed" // The load test completed successfully.
+	WebhookStatusFailed    = "failed"    // The load test failed.
+	WebhookStatusCancelled = "cancelled" // The load test was cancelled before it completed.
+)
+
+// WebhookSignatureHeader holds the hex-encoded HMAC-SHA256 signature of the
+// webhook request body (prefixed with "sha256="), if a shared secret is
+// configured.
+const WebhookSignatureHeader = "X-Tm-Load-Test-Signature"
+
+const (
+	webhookAttempts       = 3
+	webhookRequestTimeout = 10 * time.Second
+)
+
+// The delay before the first retry, doubled for each subsequent retry.
+var webhookRetryBackoff = time.Second
+
+// WebhookPayload is the JSON document posted to the result webhook once a
+// load test completes or fails.
+type WebhookPayload struct {
+	RunID  string          `json:"run_id"`          // The identifier of the load test run.
+	Status string          `json:"status"`          // Either "completed", "failed" or "cancelled".
+	Error  string          `json:"error,omitempty"` // Why the load test failed, if it did.
+	Stats  *AggregateStats `json:"stats,omitempty"` // The final aggregate statistics, if the load test got far enough to produce any.
+}
+
+// newWebhookPayload builds the result webhook payload for a load test with the
+// given configuration. The statistics are nil if the load test failed before
+// producing any.
+func newWebhookPayload(cfg Config, stats *AggregateStats, err error) WebhookPayload {
+	p := WebhookPayload{
+		RunID:  cfg.RunID,
+		Status: WebhookStatusCompleted,
+		Stats:  stats,
+	}
+	if err != nil {
+		p.Status = WebhookStatusFailed
+		if errors.Is(err, ErrLoadTestCancelled) {
+			p.Status = WebhookStatusCancelled
+		}
+		p.Error = err.Error()
+	}
+	return p
+}
+
+// signWebhookBody computes the value of the signature header for the given
+// request body.
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyResultWebhook posts the final results of a load test to the configured
+// result webhook, if any, retrying a few times on failure. Failures are only
+// logged, since they must not affect the outcome of the load test.
+func notifyResultWebhook(cfg Config, stats *AggregateStats, err error, logger logging.Logger) {
+	if len(cfg.ResultWebhookURL) == 0 {
+		return
+	}
+	body, merr := json.Marshal(newWebhookPayload(cfg, stats, err))
+	if merr != nil {
+		logger.Error("Failed to marshal result webhook payload", "err", merr)
+		return
+	}
+	client := &http.Client{Timeout: webhookRequestTimeout}
+	backoff := webhookRetryBackoff
+	for attempt := 1; ; attempt++ {
+		retry, perr := postWebhook(client, cfg.ResultWebhookURL, cfg.ResultWebhookSecret, body)
+		if perr == nil {
+			logger.Info("Posted results to webhook", "url", cfg.ResultWebhookURL)
+			return
+		}
+		if !retry || attempt >= webhookAttempts {
+			logger.Error("Failed to post results to webhook", "url", cfg.ResultWebhookURL, "attempts", attempt, "err", perr)
+			return
+		}
+		logger.Debug("Failed to post results to webhook - retrying", "err", perr, "backoff", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postWebhook posts the given body to the webhook, returning whether the
+// request is worth retrying if it fails.
+func postWebhook(client *http.Client, url, secret string, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		req.Header.Set(WebhookSignatureHeader, signWebhookBody(secret, body))
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		// client errors (other than rate limiting) won't go away by retrying
+		retry := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("got status %d from webhook: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return false, nil
+}
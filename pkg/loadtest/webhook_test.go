@@ -0,0 +1,109 @@
+package loadtest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookRecorder struct {
+	mtx        sync.Mutex
+	bodies     [][]byte
+	signatures []string
+	statuses   []int // The statuses with which to respond to successive requests (200 once exhausted).
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.bodies = append(r.bodies, body)
+	r.signatures = append(r.signatures, req.Header.Get(WebhookSignatureHeader))
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func TestNotifyResultWebhook(t *testing.T) {
+	rec := &webhookRecorder{}
+	svr := httptest.NewServer(rec)
+	defer svr.Close()
+
+	cfg := Config{RunID: "run-1", ResultWebhookURL: svr.URL, ResultWebhookSecret: "s3cret"}
+	stats := &AggregateStats{TotalTxs: 100, TotalBytes: 25000, TotalTimeSeconds: 10, FailedTxs: 5}
+	stats.Compute()
+	notifyResultWebhook(cfg, stats, nil, logging.NewNoopLogger())
+
+	require.Len(t, rec.bodies, 1)
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal(rec.bodies[0], &payload))
+	assert.Equal(t, "run-1", payload.RunID)
+	assert.Equal(t, WebhookStatusCompleted, payload.Status)
+	assert.Empty(t, payload.Error)
+	require.NotNil(t, payload.Stats)
+	assert.Equal(t, 100, payload.Stats.TotalTxs)
+	assert.Equal(t, 5, payload.Stats.FailedTxs)
+	assert.InDelta(t, 0.95, payload.Stats.SuccessRatio, 1e-9)
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(rec.bodies[0])
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), rec.signatures[0])
+}
+
+func TestNotifyResultWebhookFailure(t *testing.T) {
+	rec := &webhookRecorder{}
+	svr := httptest.NewServer(rec)
+	defer svr.Close()
+
+	// no secret, so no signature
+	cfg := Config{RunID: "run-2", ResultWebhookURL: svr.URL}
+	notifyResultWebhook(cfg, nil, fmt.Errorf("endpoint unreachable"), logging.NewNoopLogger())
+
+	require.Len(t, rec.bodies, 1)
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal(rec.bodies[0], &payload))
+	assert.Equal(t, WebhookStatusFailed, payload.Status)
+	assert.Equal(t, "endpoint unreachable", payload.Error)
+	assert.Nil(t, payload.Stats)
+	assert.Empty(t, rec.signatures[0])
+}
+
+func TestNotifyResultWebhookRetries(t *testing.T) {
+	backoff := webhookRetryBackoff
+	webhookRetryBackoff = time.Millisecond
+	defer func() { webhookRetryBackoff = backoff }()
+
+	testCases := []struct {
+		name     string
+		statuses []int
+		requests int
+	}{
+		{"success after retries", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3},
+		{"gives up after max attempts", []int{500, 500, 500, 500}, webhookAttempts},
+		{"client error not retried", []int{http.StatusBadRequest}, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &webhookRecorder{statuses: tc.statuses}
+			svr := httptest.NewServer(rec)
+			defer svr.Close()
+
+			notifyResultWebhook(Config{ResultWebhookURL: svr.URL}, nil, nil, logging.NewNoopLogger())
+			assert.Len(t, rec.bodies, tc.requests)
+		})
+	}
+}
@@ -3,6 +3,10 @@ package loadtest
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -12,6 +16,10 @@ import (
 const (
 	defaultWSReadTimeout  = 3 * time.Second
 	defaultWSWriteTimeout = 3 * time.Second
+
+	// The maximum size of a message exchanged between the coordinator and a
+	// worker, once decompressed (if compression was negotiated).
+	maxWorkerMsgSize = 256 << 20
 )
 
 // simpleSocket provides a simpler interface to interact with a websockets
@@ -26,6 +34,10 @@ type simpleSocket struct {
 	waitForRemoteClose     bool
 	remoteCloseWaitTimeout time.Duration
 
+	// Once set, worker messages are written as binary (MessagePack) instead
+	// of text (JSON) messages.
+	binary atomic.Bool
+
 	inbound         chan websocketReadRequest
 	outbound        chan websocketWriteRequest
 	stop            chan struct{} // Close this to stop the primary event loop.
@@ -46,6 +58,7 @@ type websocketReadResponse struct {
 }
 
 type websocketWriteRequest struct {
+	mt      int
 	data    []byte
 	timeout time.Duration
 	resp    chan error
@@ -59,6 +72,7 @@ type simpleSocketConfig struct {
 	waitForRemoteClose     bool          // Should we wait for the remote end to close the connection?
 	remoteCloseWaitTimeout time.Duration // If waitForRemoteClose is true, how long should we wait?
 	parentCtx              string        // The context of the parent object responsible for managing this socket (only for logging purposes).
+	loggerFactory          LoggerFactory // Creates our logger (nil for the one given to SetLogger).
 }
 
 func defaultSimpleSocketConfig() *simpleSocketConfig {
@@ -117,6 +131,12 @@ func ssParentCtx(ctx string) simpleSocketOpt {
 	}
 }
 
+func ssLoggerFactory(factory LoggerFactory) simpleSocketOpt {
+	return func(cfg *simpleSocketConfig) {
+		cfg.loggerFactory = factory
+	}
+}
+
 func newSimpleSocket(conn *websocket.Conn, opts ...simpleSocketOpt) *simpleSocket {
 	cfg := defaultSimpleSocketConfig()
 	for _, opt := range opts {
@@ -126,9 +146,12 @@ func newSimpleSocket(conn *websocket.Conn, opts ...simpleSocketOpt) *simpleSocke
 	if len(cfg.parentCtx) > 0 {
 		ctx = fmt.Sprintf("%s.%s", cfg.parentCtx, ctx)
 	}
+	// this only limits the size of messages on the wire, which may be
+	// compressed (see readMessage)
+	conn.SetReadLimit(maxWorkerMsgSize)
 	return &simpleSocket{
 		conn:                   conn,
-		logger:                 logging.NewLogrusLogger(ctx),
+		logger:                 newLogger(cfg.loggerFactory, ctx),
 		inbound:                make(chan websocketReadRequest, cfg.inboundBufSize),
 		outbound:               make(chan websocketWriteRequest, cfg.outboundBufSize),
 		stop:                   make(chan struct{}, 1),
@@ -160,7 +183,11 @@ func (s *simpleSocket) Run() {
 	for {
 		select {
 		case req := <-s.outbound:
-			s.logger.Debug("Attempting to write to WebSocket", "data", string(req.data), "timeout", req.timeout.String())
+			if req.mt == websocket.BinaryMessage {
+				s.logger.Debug("Attempting to write to WebSocket", "bytes", len(req.data), "timeout", req.timeout.String())
+			} else {
+				s.logger.Debug("Attempting to write to WebSocket", "data", string(req.data), "timeout", req.timeout.String())
+			}
 			s.handleWrite(req)
 
 		case <-s.stop:
@@ -194,11 +221,18 @@ func (s *simpleSocket) Read(timeouts ...time.Duration) (int, []byte, error) {
 		timeout: timeout,
 		resp:    make(chan websocketReadResponse, 1),
 	}
-	s.inbound <- req
+	select {
+	case s.inbound <- req:
+	case <-s.stopped:
+		return 0, nil, fmt.Errorf("websocket stopped")
+	}
 	select {
 	case resp := <-req.resp:
 		return resp.mt, resp.data, resp.err
 
+	case <-s.stopped:
+		return 0, nil, fmt.Errorf("websocket stopped")
+
 	case <-time.After(timeout + (100 * time.Millisecond)):
 		return 0, nil, fmt.Errorf("timed out waiting for websocket read to complete")
 	}
@@ -210,25 +244,38 @@ func (s *simpleSocket) ReadWorkerMsg(timeouts ...time.Duration) (workerMsg, erro
 	if err != nil {
 		return msg, err
 	}
-	if mt != websocket.TextMessage {
-		return msg, fmt.Errorf("expected text message (%d), but got message type %d", websocket.TextMessage, mt)
+	switch mt {
+	case websocket.TextMessage:
+		err = json.Unmarshal(data, &msg)
+	case websocket.BinaryMessage:
+		err = unmarshalMsgpack(data, &msg)
+	default:
+		err = fmt.Errorf("expected text (%d) or binary (%d) message, but got message type %d", websocket.TextMessage, websocket.BinaryMessage, mt)
 	}
-	err = json.Unmarshal(data, &msg)
 	return msg, err
 }
 
 // Write is a synchronous, blocking operation.
 func (s *simpleSocket) Write(data []byte, timeouts ...time.Duration) error {
+	return s.write(websocket.TextMessage, data, timeouts...)
+}
+
+func (s *simpleSocket) write(mt int, data []byte, timeouts ...time.Duration) error {
 	timeout := defaultWSWriteTimeout
 	if len(timeouts) > 0 {
 		timeout = timeouts[0]
 	}
 	req := websocketWriteRequest{
+		mt:      mt,
 		data:    data,
 		timeout: timeout,
 		resp:    make(chan error, 1),
 	}
-	s.outbound <- req
+	select {
+	case s.outbound <- req:
+	case <-s.stopped:
+		return fmt.Errorf("websocket stopped")
+	}
 	select {
 	case err := <-req.resp:
 		return err
@@ -239,6 +286,13 @@ func (s *simpleSocket) Write(data []byte, timeouts ...time.Duration) error {
 }
 
 func (s *simpleSocket) WriteWorkerMsg(msg workerMsg, timeouts ...time.Duration) error {
+	if s.binary.Load() {
+		data, err := marshalMsgpack(&msg)
+		if err != nil {
+			return err
+		}
+		return s.write(websocket.BinaryMessage, data, timeouts...)
+	}
 	data, err := json.Marshal(&msg)
 	if err != nil {
 		return err
@@ -246,6 +300,12 @@ func (s *simpleSocket) WriteWorkerMsg(msg workerMsg, timeouts ...time.Duration)
 	return s.Write(data, timeouts...)
 }
 
+// useBinary switches the socket to writing worker messages as MessagePack.
+// Messages of either encoding can always be read.
+func (s *simpleSocket) useBinary() {
+	s.binary.Store(true)
+}
+
 // Stop will end Run's event loop and block until it has completely stopped.
 func (s *simpleSocket) Stop() {
 	close(s.stop)
@@ -256,7 +316,7 @@ func (s *simpleSocket) handleRead(req websocketReadRequest) {
 	// try to read a message
 	deadline := time.Now().Add(req.timeout)
 	_ = s.conn.SetReadDeadline(deadline)
-	mt, data, err := s.conn.ReadMessage()
+	mt, data, err := s.readMessage()
 	req.resp <- websocketReadResponse{
 		mt:   mt,
 		data: data,
@@ -264,10 +324,31 @@ func (s *simpleSocket) handleRead(req websocketReadRequest) {
 	}
 }
 
+// readMessage reads the next message, making sure that it doesn't exceed the
+// maximum message size once decompressed, since a small compressed message can
+// decompress to a huge one.
+func (s *simpleSocket) readMessage() (int, []byte, error) {
+	mt, r, err := s.conn.NextReader()
+	if err != nil {
+		return mt, nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, maxWorkerMsgSize+1))
+	if err == nil && len(data) > maxWorkerMsgSize {
+		err = fmt.Errorf("message exceeds the maximum size of %d bytes", maxWorkerMsgSize)
+	}
+	return mt, data, err
+}
+
+// compressionNegotiated returns whether the WebSockets handshake with the
+// given response agreed to compress messages (with permessage-deflate).
+func compressionNegotiated(resp *http.Response) bool {
+	return resp != nil && strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+}
+
 func (s *simpleSocket) handleWrite(req websocketWriteRequest) {
 	deadline := time.Now().Add(req.timeout)
 	_ = s.conn.SetWriteDeadline(deadline)
-	req.resp <- s.conn.WriteMessage(websocket.TextMessage, req.data)
+	req.resp <- s.conn.WriteMessage(req.mt, req.data)
 }
 
 func (s *simpleSocket) close() {
@@ -1,9 +1,17 @@
 package loadtest
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
 
@@ -15,6 +23,7 @@ import (
 const (
 	workerUpdateInterval       = 3 * time.Second
 	workerConnectRetryInterval = 1 * time.Second
+	workerMaxReconnectBackoff  = 8 * time.Second
 	workerStartPollTimeout     = 60 * time.Second
 )
 
@@ -23,8 +32,12 @@ const (
 // node regularly on its progress.
 type Worker struct {
 	workerCfg *WorkerConfig
-	sock      *simpleSocket
+	dialer    *websocket.Dialer
 	logger    logging.Logger
+	newLogger LoggerFactory // Creates the loggers of our components (nil for the one given to SetLogger).
+
+	sockMtx sync.RWMutex
+	sock    *simpleSocket // Only replaced (by the progress reporter) when reconnecting during the load test.
 
 	idMtx sync.RWMutex
 	id    string
@@ -32,15 +45,41 @@ type Worker struct {
 	cfgMtx sync.RWMutex
 	cfg    Config
 
+	startDelay        time.Duration // How long to wait before sending any transactions, if the coordinator staggers workers' starts.
+	run               int           // The index of the current run, if the coordinator executes several runs back to back.
+	runs              int           // The number of runs the coordinator executes back to back.
+	txRate            float64       // The transaction rate (tx/sec) at which to send across all of our connections, if the coordinator splits a total rate amongst its workers.
+	heartbeatInterval time.Duration // How often to send heartbeats to the coordinator, as requested when it accepted us (if at all).
+
+	timeseriesMtx sync.Mutex
+	timeseries    []timeseriesSample // Timeseries samples not yet reported to the coordinator.
+
+	intervals *statsIntervals  // Splits the current run's statistics into intervals to push to the coordinator.
+	resources *resourceSampler // Samples our resource usage, to report with our progress during the current run.
+
+	shutdownRequested atomic.Bool // Whether the coordinator told us to shut down in the middle of the load test.
+
 	interruptsMtx sync.RWMutex
 	interrupts    map[string]func()
 
-	stop     chan struct{}
-	stopped  chan struct{}
-	tgCancel chan error // Send errors here to cancel the TransactorGroup's operations.
+	stop          chan struct{}
+	stopOnce      sync.Once
+	stopRequested atomic.Bool // Whether we were told to stop (rather than the coordinator cancelling the load test).
+	terminating   atomic.Bool // Whether we were told to stop by a termination signal.
+	stopped       chan struct{}
+	tgCancel      chan error // Send errors here to cancel the TransactorGroup's operations.
 }
 
-func NewWorker(cfg *WorkerConfig) (*Worker, error) {
+// errWorkerShutdown is returned while waiting for the load test to start if the
+// coordinator tells the worker to shut down instead.
+var errWorkerShutdown = errors.New("coordinator requested shutdown")
+
+// errWorkerCancelled is returned if the worker is stopped (e.g. by an
+// interrupt) while it's dealing with the coordinator.
+var errWorkerCancelled = withFailureClass(FailureCancelled, errors.New("worker operations cancelled"))
+
+func NewWorker(cfg *WorkerConfig, opts ...Option) (*Worker, error) {
+	o := newOptions(opts)
 	workerID := cfg.ID
 	if len(workerID) == 0 {
 		workerID = makeWorkerID()
@@ -48,10 +87,19 @@ func NewWorker(cfg *WorkerConfig) (*Worker, error) {
 	if !isValidWorkerID(workerID) {
 		return nil, fmt.Errorf("invalid worker ID \"%s\": worker IDs can only contain lowercase alphanumeric characters", workerID)
 	}
+	if err := validateWorkerLabels(cfg.Labels); err != nil {
+		return nil, err
+	}
+	dialer, err := newCoordinatorDialer(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return &Worker{
 		id:         workerID,
 		workerCfg:  cfg,
-		logger:     logging.NewLogrusLogger(fmt.Sprintf("worker[%s]", workerID)),
+		dialer:     dialer,
+		logger:     newLogger(o.loggerFactory, fmt.Sprintf("worker[%s]", workerID)),
+		newLogger:  o.loggerFactory,
 		interrupts: make(map[string]func()),
 		stop:       make(chan struct{}, 1),
 		stopped:    make(chan struct{}, 1),
@@ -59,16 +107,52 @@ func NewWorker(cfg *WorkerConfig) (*Worker, error) {
 	}, nil
 }
 
+// newCoordinatorDialer returns the dialer with which to connect to the
+// coordinator, configured to verify its TLS certificate as requested.
+func newCoordinatorDialer(cfg *WorkerConfig) (*websocket.Dialer, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = cfg.EnableCompression
+	if len(cfg.TLSCAFile) == 0 && !cfg.TLSInsecure {
+		return &dialer, nil
+	}
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.TLSInsecure,
+	}
+	if len(cfg.TLSCAFile) > 0 {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in TLS CA file %s", cfg.TLSCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	dialer.TLSClientConfig = tlsCfg
+	return &dialer, nil
+}
+
 // Run executes the primary event loop for this worker.
 func (w *Worker) Run() error {
+	return w.RunWithContext(context.Background())
+}
+
+// RunWithContext executes the worker's event loop like Run, but stops the
+// worker (as Stop does) if the given context is cancelled.
+func (w *Worker) RunWithContext(ctx context.Context) error {
 	defer close(w.stopped)
 
-	cancelTrap := trapInterrupts(func() { w.cancel() }, w.logger)
+	cancelTrap := trapSignals(w.cancel, w.terminate, w.logger)
 	defer close(cancelTrap)
+	defer afterFunc(ctx, w.cancel)()
+
+	w.logger.Info("Starting worker", "version", Version(), "protocolVersion", w.protocolVersion())
 
-	if err := w.connectToCoordinator(); err != nil {
+	if err := w.connectToCoordinator(ctx); err != nil {
 		w.logger.Error("Failed to connect to coordinator", "err", err)
-		return err
+		return connectivityError(err)
 	}
 	defer w.close()
 
@@ -77,22 +161,64 @@ func (w *Worker) Run() error {
 
 	if err := w.register(); err != nil {
 		w.logger.Error("Failed to register with coordinator", "err", err)
-		return err
+		return connectivityError(err)
 	}
-
-	if err := w.waitForStart(); err != nil {
-		w.logger.Error("Failed while waiting for load test to start", "err", err)
-		w.fail(err.Error())
-		return err
+	// in a dry run, we only check that we can generate transactions before
+	// the coordinator tells us to shut down
+	if w.Config().DryRun {
+		txSize, err := sampleTxSize(w.Config())
+		if err != nil {
+			w.logger.Error("Dry run failed", "err", err)
+			w.fail(err.Error())
+			return connectivityError(err)
+		}
+		w.logger.Info("Generated sample transactions for dry run", "size", txSize)
 	}
 
-	if err := w.executeLoadTest(); err != nil {
-		w.logger.Error("Failed during load testing", "err", err)
-		w.fail(err.Error())
-		return err
-	}
+	// let the coordinator know we're alive for as long as we're connected
+	heartbeatsDone := make(chan struct{})
+	defer close(heartbeatsDone)
+	go w.sendHeartbeats(heartbeatsDone)
 
-	return nil
+	// we stay connected to the coordinator for as many runs as it executes
+	for {
+		if err := w.waitForStart(); err != nil {
+			if errors.Is(err, errWorkerShutdown) {
+				w.logger.Info("Shutting down at coordinator's request")
+				return nil
+			}
+			w.logger.Error("Failed while waiting for load test to start", "err", err)
+			w.fail(err.Error())
+			return err
+		}
+
+		cancelled, err := w.executeLoadTest()
+		if err != nil {
+			w.logger.Error("Failed during load testing", "err", err)
+			w.fail(err.Error())
+			return err
+		}
+		if cancelled && w.shutdownRequested.Load() {
+			w.logger.Info("Shutting down at coordinator's request")
+			return nil
+		}
+		if cancelled && w.terminating.Load() {
+			w.logger.Info("Load test terminated")
+			return ErrLoadTestTerminated
+		}
+		if cancelled && w.stopRequested.Load() {
+			w.logger.Info("Load test cancelled")
+			return ErrLoadTestCancelled
+		}
+		if cancelled {
+			w.logger.Info("Load test cancelled by coordinator")
+			return ErrLoadTestCancelled
+		}
+
+		if !w.moreRuns() {
+			return nil
+		}
+	}
 }
 
 func (w *Worker) ID() string {
@@ -107,6 +233,21 @@ func (w *Worker) setCfg(cfg Config) {
 	w.cfgMtx.Unlock()
 }
 
+// configureLogging adopts the logging configuration the coordinator gave us,
+// except where we've been given our own.
+func (w *Worker) configureLogging(cfg Config) {
+	level, format := cfg.LogLevel, cfg.LogFormat
+	if len(w.workerCfg.LogLevel) > 0 {
+		level = w.workerCfg.LogLevel
+	}
+	if len(w.workerCfg.LogFormat) > 0 {
+		format = w.workerCfg.LogFormat
+	}
+	if err := logging.Configure(level, format); err != nil {
+		w.logger.Error("Failed to configure logging", "err", err)
+	}
+}
+
 func (w *Worker) Config() Config {
 	w.cfgMtx.RLock()
 	defer w.cfgMtx.RUnlock()
@@ -133,62 +274,83 @@ func (w *Worker) removeInterrupt(id string) {
 	w.interruptsMtx.Unlock()
 }
 
-func (w *Worker) connectToCoordinator() error {
-	timeoutTicker := time.NewTicker(time.Duration(w.workerCfg.CoordConnectTimeout) * time.Second)
-	defer timeoutTicker.Stop()
+func (w *Worker) connectToCoordinator(ctx context.Context) error {
+	// the connect timeout is our budget for all attempts together
+	deadline := time.Now().Add(time.Duration(w.workerCfg.CoordConnectTimeout))
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	backoff := w.workerCfg.coordRetryInterval()
+	maxBackoff := workerMaxReconnectBackoff
+	if backoff > maxBackoff {
+		maxBackoff = backoff
+	}
 
-	w.logger.Info("Waiting for successful connection to remote coordinator", "addr", w.workerCfg.CoordAddr)
+	w.logger.Info("Waiting for successful connection to remote coordinator", "addr", w.workerCfg.CoordAddr, "timeout", w.workerCfg.CoordConnectTimeout.String())
 
-	for {
-		conn, _, err := websocket.DefaultDialer.Dial(w.workerCfg.CoordAddr, nil)
+	for attempt := 1; ; attempt++ {
+		w.logger.Info("Connecting to remote coordinator", "attempt", attempt)
+		conn, resp, err := w.dialer.DialContext(ctx, w.workerCfg.CoordAddr, nil)
 		if err == nil {
-			w.logger.Info("Successfully connected to remote coordinator")
-			w.sock = newSimpleSocket(
-				conn,
-				ssInboundBufSize(10),
-				ssOutboundBufSize(10),
-				ssFlushOnStop(true),
-				ssSendCloseMessage(true),
-				ssWaitForRemoteClose(true),
-				ssRemoteCloseWaitTimeout(60*time.Second),
-				ssParentCtx(fmt.Sprintf("worker[%s]", w.ID())),
-			)
+			w.logger.Info("Successfully connected to remote coordinator", "compressed", compressionNegotiated(resp))
+			w.sock = w.newCoordinatorSocket(conn)
 			return nil
 		}
-		w.logger.Debug(
-			fmt.Sprintf(
-				"Failed to connect to remote coordinator - retrying in %s",
-				workerConnectRetryInterval.String(),
-			),
-			"err", err,
-		)
+		// the coordinator (or a proxy in front of it) won't change its mind
+		// about who we are
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+			return fmt.Errorf("coordinator rejected connection: %s", resp.Status)
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("failed to reach coordinator within connect time limit: %w", err)
+		}
+		delay := jitter(backoff)
+		if delay > remaining {
+			delay = remaining
+		}
+		w.logger.Info("Failed to connect to remote coordinator - retrying", "attempt", attempt, "err", err, "retryIn", delay.Round(time.Millisecond).String())
 
 		select {
-		case <-timeoutTicker.C:
-			return fmt.Errorf("failed to reach coordinator within connect time limit")
-
 		case <-w.stop:
-			return fmt.Errorf("worker operations cancelled")
+			return errWorkerCancelled
 
-		case <-time.After(workerConnectRetryInterval):
-			w.logger.Debug("Retrying to connect to coordinator")
+		case <-time.After(delay):
 		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// jitter returns a random duration between half of the given duration and the
+// whole of it, so that workers started together don't retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
 	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (w *Worker) newCoordinatorSocket(conn *websocket.Conn) *simpleSocket {
+	return newSimpleSocket(
+		conn,
+		ssInboundBufSize(10),
+		ssOutboundBufSize(10),
+		ssFlushOnStop(true),
+		ssSendCloseMessage(true),
+		ssWaitForRemoteClose(true),
+		ssRemoteCloseWaitTimeout(60*time.Second),
+		ssParentCtx(fmt.Sprintf("worker[%s]", w.ID())),
+		ssLoggerFactory(w.newLogger),
+	)
 }
 
 func (w *Worker) register() error {
 	w.logger.Info("Registering with coordinator")
-	if err := w.sock.WriteWorkerMsg(workerMsg{ID: w.ID()}); err != nil {
-		return err
-	}
-	// now wait for a response from the coordinator
-	resp, err := w.sock.ReadWorkerMsg()
+	resp, err := w.requestRegistration(w.sock, false)
 	if err != nil {
 		return err
 	}
-	if resp.State != workerAccepted {
-		return fmt.Errorf("coordinator did not accept worker with state: %s", resp.State)
-	}
 	if resp.Config == nil {
 		// tell the coordinator there's a problem
 		w.fail("missing configuration from coordinator")
@@ -197,22 +359,126 @@ func (w *Worker) register() error {
 	// we need to check the coordinator on its configuration
 	if err := resp.Config.Validate(); err != nil {
 		_ = w.sock.WriteWorkerMsg(workerMsg{ID: w.ID(), State: workerFailed, Error: err.Error()})
-		return err
+		return configError(err)
+	}
+	// our raw latency samples are written to our own machine
+	if !resp.Config.StatsOverwrite {
+		if err := validateOutputFile(resp.Config.LatencySampleFile); err != nil {
+			_ = w.sock.WriteWorkerMsg(workerMsg{ID: w.ID(), State: workerFailed, Error: err.Error()})
+			return configError(err)
+		}
 	}
 
 	// quick check if we've been cancelled
 	select {
 	case <-w.stop:
-		return fmt.Errorf("worker operations cancelled")
+		return errWorkerCancelled
 	default:
 	}
 
-	w.setCfg(*resp.Config)
+	cfg := *resp.Config
+	// a worker joining a load test that's already underway only takes part
+	// in the remainder of it
+	if resp.ElapsedSeconds > 0 {
+		cfg = remainingTestConfig(cfg, resp.ElapsedSeconds)
+		w.logger.Info("Joining load test already underway", "elapsed", fmt.Sprintf("%.1fs", resp.ElapsedSeconds), "remaining", Duration(cfg.timeLimit()).String())
+	}
+	w.setCfg(cfg)
+	w.configureLogging(cfg)
+	w.heartbeatInterval = time.Duration(resp.HeartbeatInterval)
 	w.logger.Info("Successfully registered with coordinator")
 	w.logger.Debug("Got load testing configuration from coordinator", "cfg", w.Config().ToJSON())
 	return nil
 }
 
+// requestRegistration asks the coordinator to accept this worker over the given
+// socket (or, if resuming, to accept it back), and waits for its response.
+func (w *Worker) requestRegistration(sock *simpleSocket, resume bool) (workerMsg, error) {
+	build := GetBuildInfo()
+	if err := sock.WriteWorkerMsg(workerMsg{
+		ID:               w.ID(),
+		Labels:           w.workerCfg.Labels,
+		AuthToken:        w.workerCfg.AuthToken,
+		Resume:           resume,
+		MaxReconnectTime: w.workerCfg.MaxReconnectTime,
+		ProtocolVersion:  w.protocolVersion(),
+		Encodings:        w.encodings(),
+		ClientFactories:  w.clientFactories(),
+		Build:            &build,
+	}); err != nil {
+		return workerMsg{}, err
+	}
+	// now wait for a response from the coordinator
+	resp, err := sock.ReadWorkerMsg()
+	if err != nil {
+		return resp, err
+	}
+	if resp.State == workerRejected && len(resp.Error) > 0 {
+		return resp, fmt.Errorf("coordinator rejected worker: %s", resp.Error)
+	}
+	if resp.State != workerAccepted {
+		return resp, fmt.Errorf("coordinator did not accept worker with state: %s", resp.State)
+	}
+	// a coordinator that predates protocol versioning can't have checked our
+	// version, so we check its version ourselves
+	if err := checkProtocolVersions(resp.ProtocolVersion, w.protocolVersion()); err != nil {
+		_ = sock.WriteWorkerMsg(workerMsg{ID: w.ID(), State: workerFailed, Error: err.Error()})
+		return resp, err
+	}
+	if resp.Encoding == msgEncodingMsgpack {
+		sock.useBinary()
+	}
+	return resp, nil
+}
+
+// encodings returns the message encodings (other than JSON) this worker offers
+// the coordinator when registering.
+func (w *Worker) encodings() []string {
+	if w.workerCfg.JSONMessages {
+		return nil
+	}
+	return []string{msgEncodingMsgpack}
+}
+
+// clientFactories returns the client factories this worker supports, which the
+// coordinator checks when it registers.
+func (w *Worker) clientFactories() []string {
+	if w.workerCfg.ClientFactories != nil {
+		return w.workerCfg.ClientFactories
+	}
+	return registeredClientFactories()
+}
+
+// protocolVersion returns the version of the protocol this worker speaks.
+func (w *Worker) protocolVersion() string {
+	if len(w.workerCfg.ProtocolVersion) > 0 {
+		return w.workerCfg.ProtocolVersion
+	}
+	return WorkerProtocolVersion
+}
+
+// remainingTestConfig returns the configuration with which a worker joining a
+// load test of the given configuration after the given number of seconds takes
+// part in it, i.e. with its time limit shortened to the remainder of the load
+// test (but at least one send period). Count-bounded load tests without a cap
+// have no time limit, so late workers still send their Count transactions.
+func remainingTestConfig(cfg Config, elapsedSeconds float64) Config {
+	limit := Duration(cfg.timeLimit())
+	if limit == 0 {
+		return cfg
+	}
+	remaining := limit - Duration(elapsedSeconds*float64(time.Second))
+	if remaining < cfg.SendPeriod {
+		remaining = cfg.SendPeriod
+	}
+	if cfg.countBounded() {
+		cfg.MaxRunTime = remaining
+	} else {
+		cfg.Time = remaining
+	}
+	return cfg
+}
+
 func (w *Worker) waitForStart() error {
 	w.logger.Info("Waiting for signal from coordinator to start load test")
 
@@ -222,92 +488,536 @@ func (w *Worker) waitForStart() error {
 	for {
 		w.logger.Debug("Polling coordinator for ready message")
 		// try to read a message from the coordinator
-		msg, err = w.sock.ReadWorkerMsg(workerStartPollTimeout)
+		msg, err = w.getSock().ReadWorkerMsg(workerStartPollTimeout)
 		if err == nil {
 			break
 		}
 
 		select {
 		case <-w.stop:
-			return fmt.Errorf("worker operations cancelled")
+			return errWorkerCancelled
 
 		default:
 		}
 	}
 
+	if msg.State == workerShutdown {
+		return errWorkerShutdown
+	}
 	if msg.State != workerTesting {
 		return fmt.Errorf("unexpected state change from coordinator: %s", msg.State)
 	}
+	// the coordinator may have overridden the configuration it gave us when
+	// registering
+	if msg.Config != nil {
+		if err := msg.Config.Validate(); err != nil {
+			return err
+		}
+		w.setCfg(*msg.Config)
+	}
+	w.startDelay = time.Duration(msg.StartDelaySeconds * float64(time.Second))
+	w.run, w.runs = msg.Run, msg.Runs
+	w.txRate = msg.TxRate
 
-	w.logger.Info("Coordinator initiated load test")
+	if w.runs > 1 {
+		w.logger.Info("Coordinator initiated load test", "run", w.run, "runs", w.runs)
+	} else {
+		w.logger.Info("Coordinator initiated load test")
+	}
 	return nil
 }
 
-func (w *Worker) executeLoadTest() error {
+// sendHeartbeats sends heartbeats to the coordinator at the interval it asked
+// for, until done is closed. Failing to send a heartbeat is of no concern
+// here: the progress reporter takes care of reconnecting if the connection is
+// lost.
+func (w *Worker) sendHeartbeats(done chan struct{}) {
+	if w.heartbeatInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(w.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.getSock().WriteWorkerMsg(workerMsg{ID: w.ID(), State: workerHeartbeat}); err != nil {
+				w.logger.Debug("Failed to send heartbeat to coordinator", "err", err)
+			}
+
+		case <-done:
+			return
+		}
+	}
+}
+
+// moreRuns returns whether the coordinator executes any more runs after the
+// current one.
+func (w *Worker) moreRuns() bool {
+	return w.run+1 < w.runs
+}
+
+// delayStart waits for the start delay given by the coordinator, if any,
+// letting the coordinator know that we're still there in the meantime.
+func (w *Worker) delayStart() error {
+	if w.startDelay <= 0 {
+		return nil
+	}
+	w.logger.Info("Delaying start of load test", "delay", w.startDelay.Round(time.Millisecond).String())
+	start := time.NewTimer(w.startDelay)
+	defer start.Stop()
+	keepalive := time.NewTicker(workerUpdateInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-start.C:
+			return nil
+
+		case <-keepalive.C:
+			if err := w.sendToCoordinator(workerMsg{ID: w.ID(), State: workerTesting}); err != nil {
+				return err
+			}
+
+		case <-w.stop:
+			return errWorkerCancelled
+		}
+	}
+}
+
+// executeLoadTest executes a single load test (or run), returning whether the
+// coordinator cancelled it.
+func (w *Worker) executeLoadTest() (bool, error) {
+	w.intervals = newStatsIntervals()
+	w.resources = newResourceSampler()
+	if err := w.delayStart(); err != nil {
+		return false, err
+	}
 	w.logger.Info("Connecting to remote endpoints")
+	// each run starts afresh, with new transactors (and clients)
 	tg := NewTransactorGroup()
+	tg.newLogger = w.newLogger
+	w.takeTimeseries()
 	cfg := w.Config()
+	if len(w.workerCfg.MetricsAddr) > 0 {
+		cfg.WorkerMetricsAddr = w.workerCfg.MetricsAddr
+	}
+	if len(cfg.WorkerMetricsAddr) > 0 {
+		reg := newMetricsRegistry()
+		ms, err := startMetricsServer(cfg.WorkerMetricsAddr, reg, w.logger)
+		if err != nil {
+			return false, connectivityError(err)
+		}
+		defer ms.Stop()
+		tg.SetMetricsRegistry(reg)
+	}
+	// the transactors report their progress in time for each push
+	tg.SetProgressCallback(cfg.statsPushInterval(), w.reportProgress)
 	if err := tg.AddAll(&cfg); err != nil {
-		return err
+		return false, connectivityError(err)
+	}
+	// the coordinator's configured rate assumes we connect to each of the
+	// endpoints it gave us, which we might not (e.g. if discovering peers)
+	if w.txRate > 0 {
+		tg.setTxRate(w.txRate)
+	}
+	rateCfg := cfg
+	if w.txRate > 0 {
+		rateCfg.Rate = cfg.rateFor(w.txRate, len(tg.transactors))
+	}
+	if err := rateCfg.checkRateFeasibility(defaultTxGenerateCost, w.logger); err != nil {
+		return false, configError(err)
+	}
+	if err := tg.pregenerate(); err != nil {
+		tg.close()
+		return false, err
+	}
+	if len(cfg.StatsdAddr) > 0 {
+		sink, err := newStatsdSink(cfg.StatsdAddr, cfg.StatsdPrefix, []string{"worker:" + w.ID()}, cfg.expectedTxRate(len(tg.transactors)), w.logger)
+		if err != nil {
+			return false, connectivityError(err)
+		}
+		defer sink.Close()
+		tg.AddMetricsSink(sink)
+	}
+	if len(cfg.InfluxDBURL) > 0 {
+		sink, err := newInfluxDBSink(cfg.InfluxDBURL, cfg.InfluxDBToken, cfg.InfluxDBOrg, cfg.InfluxDBBucket, map[string]string{"worker": w.ID()}, w.logger)
+		if err != nil {
+			return false, connectivityError(err)
+		}
+		defer sink.Close()
+		tg.AddMetricsSink(sink)
+	}
+	if len(cfg.OTLPEndpoint) > 0 {
+		sink, err := newOTLPSink(
+			cfg.OTLPEndpoint,
+			time.Duration(cfg.OTLPExportInterval),
+			map[string]string{"tmloadtest.run.id": cfg.RunID, "tmloadtest.worker.id": w.ID()},
+			cfg.broadcastLatencyBuckets(),
+			w.logger,
+		)
+		if err != nil {
+			return false, configError(err)
+		}
+		defer sink.Close()
+		tg.AddMetricsSink(sink)
+	}
+	if cfg.ProgressInterval > 0 {
+		tg.setProgressStatusCallback(time.Duration(cfg.ProgressInterval), func(p progressStatus) {
+			w.logger.Info("Progress", p.logKVs()...)
+			tg.progressRecord(w.ID(), p).log(w.logger)
+		})
+	}
+	if len(cfg.RawStatsOutputFile) > 0 {
+		tg.setTimeseriesCallback(time.Duration(cfg.RawStatsInterval), w.trackTimeseriesSample)
 	}
-	tg.SetProgressCallback(workerUpdateInterval, w.reportProgress)
 
 	w.logger.Info("Initiating load test")
 	tg.Start()
 
-	w.setInterrupt("ExecuteStandalone", func() { tg.Cancel() })
+	// the coordinator may pause, resume and cancel the load test
+	ctrlDone, ctrlAcked, ctrlCancelled := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	defer close(ctrlDone)
+	go w.receiveControlMessages(tg, ctrlDone, ctrlAcked, ctrlCancelled)
+
+	w.setInterrupt("ExecuteStandalone", func() { w.cancelLoadTest(tg) })
 	defer w.removeInterrupt("ExecuteStandalone")
+	// we may have been told to stop before the interrupt was in place
+	if w.stopRequested.Load() {
+		w.cancelLoadTest(tg)
+	}
 
+	// if the coordinator cancelled the load test (or we were told to stop),
+	// we still report on what we sent until then
+	cancelled := false
 	if err := tg.Wait(); err != nil {
-		w.logger.Error("Failed to execute load test", "err", err)
-		return err
+		select {
+		case <-ctrlCancelled:
+			cancelled = errors.Is(err, ErrLoadTestCancelled)
+		default:
+			cancelled = w.stopRequested.Load() && errors.Is(err, ErrLoadTestCancelled)
+		}
+		if !cancelled {
+			w.logger.Error("Failed to execute load test", "err", err)
+			return false, err
+		}
+	}
+	// raw latency samples are kept on the worker's own machine
+	if len(cfg.LatencySampleFile) > 0 {
+		w.logger.Info("Writing raw latency samples", "outputFile", cfg.LatencySampleFile)
+		if err := tg.writeLatencySamples(cfg.LatencySampleFile); err != nil {
+			w.logger.Error("Failed to write raw latency samples", "err", err)
+		}
 	}
 
 	// send the completion notification to the coordinator
-	if err := w.reportFinalResults(tg.totalTxs(), tg.totalBytes()); err != nil {
+	finalState := workerCompleted
+	if cancelled {
+		finalState = workerCancelled
+	}
+	if err := w.reportFinalResults(tg, finalState); err != nil {
 		w.logger.Error("Failed to report final results for load test", "err", err)
-		return err
+		return false, err
+	}
+	if cancelled {
+		return true, nil
+	}
+	// we mustn't be reading from the connection anymore by the time the
+	// coordinator starts its next run
+	if w.moreRuns() {
+		select {
+		case <-ctrlAcked:
+		case <-w.stop:
+			return false, errWorkerCancelled
+		case <-time.After(workerStartPollTimeout):
+			return false, fmt.Errorf("timed out waiting for coordinator to acknowledge final results")
+		}
 	}
 
 	w.logger.Info("Load test complete!")
-	return nil
+	return false, nil
+}
+
+// receiveControlMessages relays the coordinator's requests to pause, resume and
+// cancel the load test to the given transactor group, until done is closed, the
+// coordinator cancels the load test (in which case cancelled is closed) or it
+// acknowledges our final results (in which case acked is closed).
+func (w *Worker) receiveControlMessages(tg *TransactorGroup, done, acked, cancelled chan struct{}) {
+	// reads that time out leave the connection unreadable, so we wait for as
+	// long as the load test could possibly last
+	timeout := w.Config().maxRunTime() + workerStartPollTimeout
+	var lost *simpleSocket
+	for {
+		sock := w.getSock()
+		if sock == lost {
+			// the progress reporter takes care of reconnecting
+			select {
+			case <-done:
+				return
+
+			case <-time.After(workerConnectRetryInterval):
+			}
+			continue
+		}
+		msg, err := sock.ReadWorkerMsg(timeout)
+		select {
+		case <-done:
+			return
+
+		default:
+		}
+		if err != nil {
+			w.logger.Debug("Failed to read from coordinator", "err", err)
+			lost = sock
+			continue
+		}
+		switch msg.State {
+		case workerPaused:
+			tg.Pause()
+
+		case workerTesting:
+			tg.Resume()
+
+		case workerRate:
+			if msg.TxRate > 0 {
+				w.logger.Info("Coordinator changed our transaction rate", "rate", fmt.Sprintf("%.2f txs/sec", msg.TxRate))
+				tg.setTxRate(msg.TxRate)
+			}
+
+		case workerCancelled:
+			close(cancelled)
+			tg.Cancel()
+			return
+
+		case workerCompleted:
+			close(acked)
+			return
+
+		case workerShutdown:
+			// we still report on what we sent until then, as when cancelled
+			if !msg.Force {
+				w.logger.Warn("Refusing to shut down in the middle of the load test without being forced to")
+				continue
+			}
+			w.shutdownRequested.Store(true)
+			close(cancelled)
+			tg.Cancel()
+			return
+
+		default:
+			w.logger.Debug("Ignoring unexpected message from coordinator", "state", msg.State)
+		}
+	}
 }
 
+// reportProgress pushes the statistics gathered since our previous push to the
+// coordinator, along with our cumulative totals (for coordinators that predate
+// statistics intervals) and progress. Latencies are only pushed in intervals,
+// until the final results.
 func (w *Worker) reportProgress(tg *TransactorGroup, totalTxs int, totalTxBytes int64) {
 	w.logger.Debug("Reporting progress back to coordinator", "totalTxs", totalTxs)
-	if err := w.sock.WriteWorkerMsg(workerMsg{
-		ID:           w.ID(),
-		State:        workerTesting,
-		TxCount:      totalTxs,
-		TotalTxBytes: totalTxBytes,
+	progress := tg.progress(tg.avgTxRate())
+	if err := w.sendToCoordinator(workerMsg{
+		ID:                      w.ID(),
+		State:                   workerTesting,
+		TxCount:                 totalTxs,
+		TotalTxBytes:            totalTxBytes,
+		Progress:                progress.Ratio,
+		ETASeconds:              progress.ETA.Seconds(),
+		Failures:                progress.Failures,
+		Interval:                w.intervals.next(totalTxs, totalTxBytes, progress.Failures, tg.broadcastLatencies(), tg.commitLatencies()),
+		Mempool:                 tg.MempoolStats(),
+		EndpointHealth:          tg.EndpointHealthStats(),
+		CommitLatencyByPriority: tg.commitLatenciesByPriority(),
+		Endpoints:               tg.EndpointStats(),
+		Timeseries:              w.takeTimeseries(),
+		Resources:               w.sampleResources(),
 	}); err != nil {
 		w.logger.Error("Failed to report progress to coordinator", "err", err)
 		tg.Cancel()
 	}
 }
 
-func (w *Worker) reportFinalResults(totalTxs int, totalTxBytes int64) error {
-	w.logger.Debug("Reporting final results back to coordinator", "totalTxs", totalTxs)
-	return w.sock.WriteWorkerMsg(workerMsg{
-		ID:           w.ID(),
-		State:        workerCompleted,
-		TxCount:      totalTxs,
-		TotalTxBytes: totalTxBytes,
+// reportFinalResults reports our final results to the coordinator, along with
+// the given final state (completed or cancelled).
+func (w *Worker) reportFinalResults(tg *TransactorGroup, state workerState) error {
+	totalTxs := tg.totalTxs()
+	w.logger.Debug("Reporting final results back to coordinator", "totalTxs", totalTxs, "state", state)
+	progress := 1.0
+	if state == workerCancelled {
+		progress = tg.progress(tg.avgTxRate()).Ratio
+	}
+	return w.sendToCoordinator(workerMsg{
+		ID:                      w.ID(),
+		State:                   state,
+		TxCount:                 totalTxs,
+		TotalTxBytes:            tg.totalBytes(),
+		Progress:                progress,
+		Failures:                tg.totalFailures(),
+		DrainSeconds:            tg.drainDuration().Seconds(),
+		Stats:                   tg.workerStats(w.ID()),
+		BroadcastLatency:        tg.broadcastLatencies(),
+		Mempool:                 tg.MempoolStats(),
+		EndpointHealth:          tg.EndpointHealthStats(),
+		CommitLatency:           tg.commitLatencies(),
+		CommitLatencyByPriority: tg.commitLatenciesByPriority(),
+		Endpoints:               tg.EndpointStats(),
+		Timeseries:              w.takeTimeseries(),
+		IntervalTxs:             tg.intervalTxCounts(),
+		Resources:               w.sampleResources(),
 	})
 }
 
+// sampleResources samples our resource usage since our previous progress
+// update.
+func (w *Worker) sampleResources() *ResourceUsage {
+	usage := w.resources.sample()
+	return &usage
+}
+
+// sendToCoordinator sends the given message to the coordinator. If the
+// connection to the coordinator has been lost, we try to reconnect and resend
+// it, while the load test carries on. The coordinator reconciles the totals we
+// report, so resending them doesn't double count them.
+func (w *Worker) sendToCoordinator(msg workerMsg) error {
+	err := w.getSock().WriteWorkerMsg(msg)
+	if err == nil || w.workerCfg.MaxReconnectTime <= 0 {
+		return err
+	}
+	w.logger.Warn("Lost connection to coordinator - attempting to reconnect", "err", err)
+	if err := w.reconnect(); err != nil {
+		return err
+	}
+	return w.getSock().WriteWorkerMsg(msg)
+}
+
+// reconnect replaces our lost connection to the coordinator, retrying with
+// backoff for up to the configured maximum reconnect time.
+func (w *Worker) reconnect() error {
+	lost := w.getSock()
+	// the connection's broken, so there's no point in waiting on it
+	_ = lost.conn.Close()
+
+	deadline := time.Now().Add(time.Duration(w.workerCfg.MaxReconnectTime))
+	backoff := workerConnectRetryInterval
+	for {
+		sock, retry, err := w.resume()
+		if err == nil {
+			w.setSock(sock)
+			lost.Stop()
+			// intervals we sent just before losing the connection may never
+			// have arrived
+			w.intervals.requestResync()
+			w.logger.Info("Reconnected to coordinator")
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if !retry || remaining <= 0 {
+			return fmt.Errorf("failed to reconnect to coordinator: %w", err)
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		w.logger.Debug("Failed to reconnect to coordinator - retrying", "err", err, "backoff", backoff)
+		select {
+		case <-w.stop:
+			return errWorkerCancelled
+
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > workerMaxReconnectBackoff {
+			backoff = workerMaxReconnectBackoff
+		}
+	}
+}
+
+// resume connects to the coordinator again and asks it to accept us back into
+// the load test, returning whether it's worth retrying if that fails.
+func (w *Worker) resume() (*simpleSocket, bool, error) {
+	conn, _, err := w.dialer.Dial(w.workerCfg.CoordAddr, nil)
+	if err != nil {
+		return nil, true, err
+	}
+	sock := w.newCoordinatorSocket(conn)
+	go sock.Run()
+	resp, err := w.requestRegistration(sock, true)
+	if err != nil {
+		_ = conn.Close()
+		sock.Stop()
+		// the coordinator won't change its mind
+		return nil, resp.State != workerRejected, err
+	}
+	return sock, true, nil
+}
+
+func (w *Worker) trackTimeseriesSample(s timeseriesSample) {
+	w.timeseriesMtx.Lock()
+	w.timeseries = append(w.timeseries, s)
+	w.timeseriesMtx.Unlock()
+}
+
+// takeTimeseries returns all of the timeseries samples not yet reported to
+// the coordinator.
+func (w *Worker) takeTimeseries() []timeseriesSample {
+	w.timeseriesMtx.Lock()
+	defer w.timeseriesMtx.Unlock()
+	samples := w.timeseries
+	w.timeseries = nil
+	return samples
+}
+
+func (w *Worker) setSock(sock *simpleSocket) {
+	w.sockMtx.Lock()
+	w.sock = sock
+	w.sockMtx.Unlock()
+}
+
+func (w *Worker) getSock() *simpleSocket {
+	w.sockMtx.RLock()
+	defer w.sockMtx.RUnlock()
+	return w.sock
+}
+
 func (w *Worker) fail(reason string) {
-	_ = w.sock.WriteWorkerMsg(workerMsg{ID: w.ID(), State: workerFailed, Error: reason})
+	_ = w.getSock().WriteWorkerMsg(workerMsg{ID: w.ID(), State: workerFailed, Error: reason})
+}
+
+// Stop stops the worker's operations, as if interrupted. If the load test is
+// underway, the worker stops sending transactions and reports the statistics
+// it gathered until then to the coordinator as its final update (as when the
+// coordinator cancels the load test), after which Run returns
+// ErrLoadTestCancelled. Safe to call more than once, from any goroutine.
+func (w *Worker) Stop() {
+	w.cancel()
 }
 
 func (w *Worker) cancel() {
-	w.logger.Error("Worker operations cancelled")
-	defer close(w.stop)
-	w.triggerInterrupts()
+	w.stopOnce.Do(func() {
+		w.logger.Error("Worker operations cancelled")
+		w.stopRequested.Store(true)
+		defer close(w.stop)
+		w.triggerInterrupts()
+	})
+}
+
+// terminate stops the worker's operations as cancel does, but first gives the
+// load test (if underway) a moment to receive the responses to its in-flight
+// transactions.
+func (w *Worker) terminate() {
+	w.terminating.Store(true)
+	w.cancel()
+}
+
+// cancelLoadTest cancels the given load test once we've been told to stop,
+// draining it briefly first if we're being terminated.
+func (w *Worker) cancelLoadTest(tg *TransactorGroup) {
+	if w.terminating.Load() {
+		tg.Terminate(terminationDrain(w.Config()))
+		return
+	}
+	tg.Cancel()
 }
 
 func (w *Worker) close() {
-	w.sock.Stop()
+	w.getSock().Stop()
 	w.logger.Info("Closed connection to remote coordinator")
 }
 
@@ -320,6 +1030,24 @@ func isValidWorkerID(id string) bool {
 	return true
 }
 
+// makeWorkerID generates an ID for a worker from the host's name (reduced to
+// the characters allowed in worker IDs) and a short random suffix, so that
+// workers can be told apart in the coordinator's output. If the host's name
+// can't be determined, the ID is a random UUID instead.
 func makeWorkerID() string {
-	return strings.ReplaceAll(uuid.NewV4().String(), "-", "")
+	random := strings.ReplaceAll(uuid.NewV4().String(), "-", "")
+	hostname, err := os.Hostname()
+	if err != nil {
+		return random
+	}
+	var prefix strings.Builder
+	for _, r := range strings.ToLower(hostname) {
+		if isValidWorkerID(string(r)) {
+			prefix.WriteRune(r)
+		}
+	}
+	if prefix.Len() == 0 {
+		return random
+	}
+	return prefix.String() + random[:6]
 }
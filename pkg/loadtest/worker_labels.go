@@ -0,0 +1,149 @@
+package loadtest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Worker label names must be usable as Prometheus label names.
+var workerLabelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// The label under which the coordinator's per-worker metrics carry the
+// worker's ID, which therefore can't be used as the name of a worker label.
+const workerIDLabel = "worker"
+
+func validateWorkerLabels(labels map[string]string) error {
+	for name := range labels {
+		if !workerLabelNameRe.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid worker label name \"%s\": label names can only contain alphanumeric characters and underscores, and can't start with a digit or \"__\"", name)
+		}
+		if name == workerIDLabel {
+			return fmt.Errorf("invalid worker label name \"%s\": reserved for the worker's ID", name)
+		}
+	}
+	return nil
+}
+
+// sortedLabelNames returns the names of the given labels in order.
+func sortedLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatWorkerLabels formats the given labels as a comma-separated list of
+// name=value pairs, ordered by name.
+func formatWorkerLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, name := range sortedLabelNames(labels) {
+		pairs = append(pairs, name+"="+labels[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// workerInfoCollector exposes the labels of each worker that has registered
+// with the coordinator as an "info" metric, with a constant value of 1, which
+// can be joined with the coordinator's other per-worker metrics on the worker
+// label. As workers can have different labels, each series carries the union
+// of all workers' label names, with missing labels left empty.
+type workerInfoCollector struct {
+	name, help string
+
+	mtx    sync.Mutex
+	labels map[string]map[string]string // The labels of each worker, by ID.
+}
+
+var _ prometheus.Collector = (*workerInfoCollector)(nil)
+
+func newWorkerInfoCollector(name, help string) *workerInfoCollector {
+	return &workerInfoCollector{
+		name:   name,
+		help:   help,
+		labels: make(map[string]map[string]string),
+	}
+}
+
+func (c *workerInfoCollector) set(id string, labels map[string]string) {
+	c.mtx.Lock()
+	c.labels[id] = labels
+	c.mtx.Unlock()
+}
+
+func (c *workerInfoCollector) get(id string) map[string]string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.labels[id]
+}
+
+// Describe doesn't describe any metrics, since the label names depend on the
+// workers that have registered, which makes this an unchecked collector.
+func (c *workerInfoCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *workerInfoCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if len(c.labels) == 0 {
+		return
+	}
+	allNames := make(map[string]string)
+	for _, labels := range c.labels {
+		for name := range labels {
+			allNames[name] = ""
+		}
+	}
+	names := sortedLabelNames(allNames)
+	desc := prometheus.NewDesc(c.name, c.help, append([]string{workerIDLabel}, names...), nil)
+	for id, labels := range c.labels {
+		values := make([]string, 0, len(names)+1)
+		values = append(values, id)
+		for _, name := range names {
+			values = append(values, labels[name])
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+	}
+}
+
+// isWorkerLabelSelector returns whether the given worker override key is a
+// label selector (e.g. "region=eu,instance=c5") rather than a worker ID.
+func isWorkerLabelSelector(key string) bool {
+	return strings.Contains(key, "=")
+}
+
+// parseWorkerLabelSelector parses a comma-separated list of name=value pairs,
+// all of which a worker's labels must match for the selector to select it.
+func parseWorkerLabelSelector(selector string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, term := range strings.Split(selector, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(term), "=")
+		if !ok || len(value) == 0 {
+			return nil, fmt.Errorf("invalid worker label selector \"%s\": expected comma-separated name=value pairs", selector)
+		}
+		if _, exists := labels[name]; exists {
+			return nil, fmt.Errorf("invalid worker label selector \"%s\": label %s is given more than once", selector, name)
+		}
+		labels[name] = value
+	}
+	if err := validateWorkerLabels(labels); err != nil {
+		return nil, fmt.Errorf("invalid worker label selector \"%s\": %w", selector, err)
+	}
+	return labels, nil
+}
+
+// matchesWorkerLabels returns whether the given labels have all of the
+// selected labels' values.
+func matchesWorkerLabels(selected, labels map[string]string) bool {
+	for name, value := range selected {
+		if v, ok := labels[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
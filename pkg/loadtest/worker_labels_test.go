@@ -0,0 +1,48 @@
+package loadtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWorkerLabelSelector(t *testing.T) {
+	labels, err := parseWorkerLabelSelector("region=eu, instance=c5")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"region": "eu", "instance": "c5"}, labels)
+
+	for _, selector := range []string{"region", "region=", "=eu", "region=eu,", "region=eu,region=us", "worker=w1", "1region=eu"} {
+		_, err := parseWorkerLabelSelector(selector)
+		assert.Error(t, err, selector)
+	}
+}
+
+func TestCoordinatorConfigWorkerOverride(t *testing.T) {
+	c := CoordinatorConfig{WorkerOverrides: map[string]WorkerOverride{
+		"eu1":                  {Rate: 1},
+		"region=eu":            {Rate: 2},
+		"region=eu,instance=a": {Rate: 3},
+		"instance=a,zone=b":    {Rate: 4},
+		"instance=a,region=eu": {Rate: 5},
+	}}
+	testCases := []struct {
+		id           string
+		labels       map[string]string
+		expectedRate float64
+	}{
+		// a worker's ID takes precedence over its labels
+		{"eu1", map[string]string{"region": "eu", "instance": "a"}, 1},
+		{"eu2", map[string]string{"region": "eu"}, 2},
+		// the selector with the most labels applies, or the first in order
+		{"eu2", map[string]string{"region": "eu", "instance": "a"}, 5},
+		{"eu2", map[string]string{"region": "us", "instance": "a", "zone": "b"}, 4},
+		{"us1", map[string]string{"region": "us", "instance": "a"}, 0},
+		{"us1", nil, 0},
+	}
+	for _, tc := range testCases {
+		o, ok := c.workerOverride(tc.id, tc.labels)
+		assert.Equal(t, tc.expectedRate > 0, ok, tc.labels)
+		assert.Equal(t, tc.expectedRate, o.Rate, tc.labels)
+	}
+}
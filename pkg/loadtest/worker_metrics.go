@@ -0,0 +1,182 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsServerShutdownTimeout = 5 * time.Second
+
+// The minimum window over which the current transaction rate is computed.
+const workerTxRateMinWindow = 1 * time.Second
+
+// workerMetrics are the Prometheus metrics exposed by a worker (or by a
+// standalone load test) for its own transactors.
+type workerMetrics struct {
+	totalTxs         prometheus.Counter
+	totalBytes       prometheus.Counter
+	failedTxs        prometheus.Counter
+	broadcastLatency prometheus.Histogram
+
+	factory promauto.Factory
+	group   *TransactorGroup
+
+	rateMtx      sync.Mutex
+	rateTxs      int       // The total number of transactions sent at the start of the current rate window.
+	rateTime     time.Time // The start of the current rate window.
+	lastRate     float64
+	totalTxsFunc func() int
+}
+
+func newWorkerMetrics(reg prometheus.Registerer, cfg *Config, g *TransactorGroup) *workerMetrics {
+	metrics := promauto.With(reg)
+	wm := &workerMetrics{
+		totalTxs: metrics.NewCounter(prometheus.CounterOpts{
+			Name: "tmloadtest_worker_total_txs",
+			Help: "The total number of transactions sent by this worker",
+		}),
+		totalBytes: metrics.NewCounter(prometheus.CounterOpts{
+			Name: "tmloadtest_worker_total_bytes",
+			Help: "The total number of bytes of transactions sent by this worker",
+		}),
+		failedTxs: metrics.NewCounter(prometheus.CounterOpts{
+			Name: "tmloadtest_worker_failed_txs",
+			Help: "The total number of error responses to transactions sent by this worker",
+		}),
+		broadcastLatency: metrics.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tmloadtest_worker_broadcast_latency_seconds",
+			Help:    "The broadcast round-trip latency of each transaction (write-completion latency for broadcast_tx_async)",
+			Buckets: cfg.broadcastLatencyBuckets(),
+		}),
+		factory:      metrics,
+		group:        g,
+		rateTime:     time.Now(),
+		totalTxsFunc: func() int { return g.timeseriesTotals().txs },
+	}
+	metrics.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "tmloadtest_worker_tx_rate",
+		Help: "The current rate (in txs/sec) at which this worker is sending transactions, measured between scrapes",
+	}, wm.txRate)
+	metrics.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "tmloadtest_worker_healthy_endpoints",
+		Help: "The number of this worker's endpoints that are currently healthy (i.e. not blacklisted after repeated failures)",
+	}, func() float64 { return float64(g.healthyEndpoints()) })
+	for endpoint := range g.transactorsByEndpoint() {
+		wm.addEndpoint(endpoint)
+	}
+	return wm
+}
+
+// addEndpoint exposes the metrics for the given endpoint, which may have been
+// added by re-discovery during the load test.
+func (wm *workerMetrics) addEndpoint(endpoint string) {
+	wm.factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "tmloadtest_worker_open_connections",
+		Help:        "The number of connections from this worker to an endpoint that are currently open",
+		ConstLabels: prometheus.Labels{"endpoint": wm.group.endpointLabel(endpoint)},
+	}, func() float64 {
+		open := 0
+		for _, t := range wm.group.transactorsByEndpoint()[endpoint] {
+			if t.isConnected() {
+				open++
+			}
+		}
+		return float64(open)
+	})
+}
+
+var _ MetricsSink = (*workerMetrics)(nil)
+
+func (wm *workerMetrics) TxsSent(_ string, count int, bytes int64) {
+	wm.totalTxs.Add(float64(count))
+	wm.totalBytes.Add(float64(bytes))
+}
+
+func (wm *workerMetrics) TxFailed(_ string) {
+	wm.failedTxs.Inc()
+}
+
+func (wm *workerMetrics) BroadcastLatency(_ string, latency time.Duration) {
+	wm.broadcastLatency.Observe(latency.Seconds())
+}
+
+// txRate computes the transaction rate since it was last computed, as long as
+// enough time has passed since then to give a meaningful rate.
+func (wm *workerMetrics) txRate() float64 {
+	wm.rateMtx.Lock()
+	defer wm.rateMtx.Unlock()
+	elapsed := time.Since(wm.rateTime)
+	if elapsed < workerTxRateMinWindow {
+		return wm.lastRate
+	}
+	totalTxs := wm.totalTxsFunc()
+	wm.lastRate = float64(totalTxs-wm.rateTxs) / elapsed.Seconds()
+	wm.rateTxs = totalTxs
+	wm.rateTime = time.Now()
+	return wm.lastRate
+}
+
+// metricsServer serves the Prometheus metrics from a worker (or from a
+// standalone load test) at the /metrics endpoint.
+type metricsServer struct {
+	svr     *http.Server
+	stopped chan struct{}
+	logger  logging.Logger
+}
+
+// newMetricsRegistry creates a registry for a worker's metrics, including the
+// standard Go runtime and process metrics.
+func newMetricsRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	registerBuildInfo(reg)
+	return reg
+}
+
+// startMetricsServer binds to the given address and starts serving the
+// metrics in the given registry in the background. Fails immediately if the
+// address cannot be bound.
+func startMetricsServer(addr string, reg *prometheus.Registry, logger logging.Logger) (*metricsServer, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind metrics endpoint to %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	ms := &metricsServer{
+		svr:     &http.Server{Handler: mux},
+		stopped: make(chan struct{}),
+		logger:  logger,
+	}
+	go func() {
+		defer close(ms.stopped)
+		if err := ms.svr.Serve(l); err != nil && err != http.ErrServerClosed {
+			logger.Error("Metrics endpoint failed", "err", err)
+		}
+	}()
+	logger.Info("Serving metrics", "addr", l.Addr().String())
+	return ms, nil
+}
+
+// Stop shuts down the metrics server, waiting for it to stop completely.
+func (ms *metricsServer) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), metricsServerShutdownTimeout)
+	defer cancel()
+	if err := ms.svr.Shutdown(ctx); err != nil {
+		ms.logger.Error("Failed to cleanly shut down metrics endpoint", "err", err)
+	}
+	<-ms.stopped
+}
@@ -0,0 +1,66 @@
+package loadtest_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/informalsystems/tm-load-test/pkg/loadtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandaloneWorkerMetrics(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	cfg := mockTestConfig(svr.URL())
+	cfg.Time = seconds(3)
+	cfg.Count = -1
+	cfg.WorkerMetricsAddr = freeLocalAddr(t)
+	metricsURL := "http://" + cfg.WorkerMetricsAddr + "/metrics"
+
+	done := make(chan error, 1)
+	go func() { done <- loadtest.ExecuteStandalone(cfg) }()
+
+	// scrape the metrics mid-run
+	time.Sleep(2 * time.Second)
+	resp, err := http.Get(metricsURL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	metrics := string(body)
+	for _, name := range []string{
+		"tmloadtest_worker_total_txs ",
+		"tmloadtest_worker_total_bytes ",
+		"tmloadtest_worker_failed_txs 0",
+		"tmloadtest_worker_tx_rate ",
+		"tmloadtest_worker_broadcast_latency_seconds_count ",
+		`tmloadtest_worker_open_connections{endpoint="` + svr.URL() + `"} 1`,
+	} {
+		assert.Contains(t, metrics, name)
+	}
+	assert.NotContains(t, metrics, "tmloadtest_worker_total_txs 0\n")
+
+	require.NoError(t, <-done)
+	// the metrics endpoint must be shut down along with the load test
+	_, err = http.Get(metricsURL)
+	require.Error(t, err)
+}
+
+func TestStandaloneWorkerMetricsPortConflict(t *testing.T) {
+	svr := newMockRPCServer(t, 0)
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	cfg := mockTestConfig(svr.URL())
+	cfg.WorkerMetricsAddr = l.Addr().String()
+	err = loadtest.ExecuteStandalone(cfg)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "failed to bind metrics endpoint"), err.Error())
+	require.Equal(t, loadtest.FailureConnectivity, loadtest.ClassifyError(err))
+	require.Zero(t, svr.Requests())
+}